@@ -0,0 +1,93 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+
+	"github.com/mendersoftware/deployments/app"
+	"github.com/mendersoftware/deployments/model"
+)
+
+func tenantContext(r *rest.Request) context.Context {
+	ctx := r.Context()
+	if tenantID := r.PathParam("tenant"); tenantID != "" && tenantID != "default" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	}
+	return ctx
+}
+
+// ImportArtifactsInternal starts importing the artifacts stored in the object
+// storage under the requested prefix.
+func (d *DeploymentsApiHandlers) ImportArtifactsInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	var req model.ArtifactImportRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "malformed request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	job, err := d.app.ImportArtifacts(tenantContext(r), req)
+	if errors.Is(err, app.ErrArtifactImportPrefix) {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	location := strings.Replace(
+		ApiUrlInternalTenantArtifactsImportID, "#tenant", r.PathParam("tenant"), 1,
+	)
+	w.Header().Set("Location", strings.Replace(location, "#id", job.ID, 1))
+	w.WriteHeader(http.StatusAccepted)
+	_ = w.WriteJson(job)
+}
+
+// GetArtifactImportInternal returns the progress of an artifact import.
+func (d *DeploymentsApiHandlers) GetArtifactImportInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	job, err := d.app.GetArtifactImportJob(tenantContext(r), r.PathParam("id"))
+	switch err {
+	case nil:
+		d.view.RenderSuccessGet(w, job)
+	case app.ErrArtifactImportNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/app"
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func tenantMatcher(tenantID string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		id := identity.FromContext(ctx)
+		if tenantID == "" {
+			return id == nil
+		}
+		return id != nil && id.Tenant == tenantID
+	})
+}
+
+func TestImportArtifactsInternal(t *testing.T) {
+	t.Parallel()
+
+	job := model.NewArtifactImportJob("import/")
+
+	testCases := map[string]struct {
+		tenant string
+		body   interface{}
+
+		callApp bool
+		appErr  error
+
+		code int
+	}{
+		"ok": {
+			tenant:  "tenant",
+			body:    model.ArtifactImportRequest{Prefix: "import/"},
+			callApp: true,
+			code:    http.StatusAccepted,
+		},
+		"ok, default tenant": {
+			tenant:  "default",
+			body:    model.ArtifactImportRequest{Prefix: "import/", RateLimit: 5},
+			callApp: true,
+			code:    http.StatusAccepted,
+		},
+		"error, missing prefix": {
+			tenant: "tenant",
+			body:   model.ArtifactImportRequest{},
+			code:   http.StatusBadRequest,
+		},
+		"error, rate limit too high": {
+			tenant: "tenant",
+			body: model.ArtifactImportRequest{
+				Prefix:    "import/",
+				RateLimit: model.ArtifactImportMaxRateLimit + 1,
+			},
+			code: http.StatusBadRequest,
+		},
+		"error, prefix outside the tenant namespace": {
+			tenant:  "tenant",
+			body:    model.ArtifactImportRequest{Prefix: "other/"},
+			callApp: true,
+			appErr:  app.ErrArtifactImportPrefix,
+			code:    http.StatusBadRequest,
+		},
+		"error, internal": {
+			tenant:  "tenant",
+			body:    model.ArtifactImportRequest{Prefix: "import/"},
+			callApp: true,
+			appErr:  errors.New("mongo: connection refused"),
+			code:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.callApp {
+				tenantID := tc.tenant
+				if tenantID == "default" {
+					tenantID = ""
+				}
+				var ret *model.ArtifactImportJob
+				if tc.appErr == nil {
+					ret = job
+				}
+				app.On("ImportArtifacts", tenantMatcher(tenantID), tc.body).
+					Return(ret, tc.appErr)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantArtifactsImport,
+				rest.Post,
+				d.ImportArtifactsInternal,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlInternalTenantArtifactsImport, "#tenant", tc.tenant, 1)
+			req := test.MakeSimpleRequest(http.MethodPost, url, tc.body)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusAccepted {
+				location := ApiUrlInternal + "/tenants/" + tc.tenant +
+					"/artifacts/import/" + job.ID
+				assert.Equal(t, location, recorded.Recorder.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestGetArtifactImportInternal(t *testing.T) {
+	t.Parallel()
+
+	job := model.NewArtifactImportJob("import/")
+
+	testCases := map[string]struct {
+		appJob *model.ArtifactImportJob
+		appErr error
+
+		code int
+	}{
+		"ok": {
+			appJob: job,
+			code:   http.StatusOK,
+		},
+		"error, not found": {
+			appErr: app.ErrArtifactImportNotFound,
+			code:   http.StatusNotFound,
+		},
+		"error, internal": {
+			appErr: errors.New("mongo: connection refused"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			app.On("GetArtifactImportJob", tenantMatcher("tenant"), job.ID).
+				Return(tc.appJob, tc.appErr)
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantArtifactsImportID,
+				rest.Get,
+				d.GetArtifactImportInternal,
+			)
+			url := "http://localhost" + strings.NewReplacer(
+				"#tenant", "tenant",
+				"#id", job.ID,
+			).Replace(ApiUrlInternalTenantArtifactsImportID)
+			req := test.MakeSimpleRequest(http.MethodGet, url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusOK {
+				var res model.ArtifactImportJob
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, job.ID, res.ID)
+				assert.Equal(t, model.ArtifactImportStatusRunning, res.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/asaskevich/govalidator"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+
+	"github.com/mendersoftware/deployments/app"
+	"github.com/mendersoftware/deployments/model"
+)
+
+var ErrInvalidRange = errors.New("requested range not satisfiable")
+
+// byteRange is a single, resolved range of a "Range: bytes=..." header.
+type byteRange struct {
+	offset int64
+	length int64
+}
+
+// parseByteRange resolves the Range header value against an object of the
+// given size. A nil range with no error means the whole object should be
+// served: either the header is absent, or it requests multiple ranges which
+// we don't support.
+func parseByteRange(header string, size int64) (*byteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, ErrInvalidRange
+	}
+	spec := strings.TrimPrefix(header, "bytes=")
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, ErrInvalidRange
+	}
+
+	var start, end int64
+	if first == "" {
+		// suffix range: the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, ErrInvalidRange
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	} else {
+		var err error
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, ErrInvalidRange
+		}
+		end = size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, ErrInvalidRange
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+	}
+	if start >= size {
+		return nil, ErrInvalidRange
+	}
+	return &byteRange{offset: start, length: end - start + 1}, nil
+}
+
+// StreamArtifact serves the artifact file through the service itself,
+// honoring single byte-range requests, for clients that cannot reach
+// the storage backend using a presigned link.
+func (d *DeploymentsApiHandlers) StreamArtifact(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	image, err := d.app.GetImage(r.Context(), id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	} else if image == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.streamArtifact(w, r, image)
+}
+
+// StreamArtifactForDevice serves the artifact file to a device; only the
+// artifact of the deployment the device is currently getting is served.
+func (d *DeploymentsApiHandlers) StreamArtifactForDevice(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+	idata := identity.FromContext(ctx)
+	if idata == nil {
+		d.view.RenderError(w, r, ErrMissingIdentity, http.StatusBadRequest, l)
+		return
+	}
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	image, err := d.app.GetImageForDevice(ctx, idata.Subject, id)
+	switch errors.Cause(err) {
+	case nil:
+		d.streamArtifact(w, r, image)
+	case app.ErrArtifactNotAssigned:
+		d.view.RenderError(w, r, err, http.StatusForbidden, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) streamArtifact(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	image *model.Image,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	rw := w.(http.ResponseWriter)
+	hdr := rw.Header()
+	hdr.Set("Accept-Ranges", "bytes")
+
+	byteRange, err := parseByteRange(r.Header.Get("Range"), image.Size)
+	if err != nil {
+		hdr.Set("Content-Range", fmt.Sprintf("bytes */%d", image.Size))
+		d.view.RenderError(w, r, err, http.StatusRequestedRangeNotSatisfiable, l)
+		return
+	}
+
+	var (
+		body   io.ReadCloser
+		status = http.StatusOK
+		length = image.Size
+	)
+	if byteRange != nil {
+		body, err = d.app.DownloadArtifact(r.Context(), image,
+			byteRange.offset, byteRange.length)
+		status = http.StatusPartialContent
+		length = byteRange.length
+	} else {
+		body, err = d.app.DownloadArtifact(r.Context(), image, 0, -1)
+	}
+	switch errors.Cause(err) {
+	case nil:
+	case app.ErrUploadNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	case app.ErrArtifactRangeNotSatisfiable:
+		hdr.Set("Content-Range", fmt.Sprintf("bytes */%d", image.Size))
+		d.view.RenderError(w, r, ErrInvalidRange, http.StatusRequestedRangeNotSatisfiable, l)
+		return
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	defer body.Close()
+
+	filename := image.Name + model.ArtifactFileSuffix
+	hdr.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	hdr.Set("Content-Type", app.ArtifactContentType)
+	hdr.Set("Content-Length", strconv.FormatInt(length, 10))
+	if byteRange != nil {
+		hdr.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d",
+			byteRange.offset, byteRange.offset+byteRange.length-1, image.Size))
+	}
+	rw.WriteHeader(status)
+	if _, err := io.Copy(rw, body); err != nil {
+		// the response is already on its way, all we can do is log
+		l.Errorf("failed to stream artifact %s: %s", image.Id, err)
+	}
+}
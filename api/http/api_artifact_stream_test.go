@@ -0,0 +1,358 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/app"
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func TestParseByteRange(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		header string
+
+		res *byteRange
+		err error
+	}{
+		"no header": {},
+		"ok, bounded": {
+			header: "bytes=0-9",
+			res:    &byteRange{offset: 0, length: 10},
+		},
+		"ok, open ended": {
+			header: "bytes=90-",
+			res:    &byteRange{offset: 90, length: 10},
+		},
+		"ok, end past size": {
+			header: "bytes=90-1000",
+			res:    &byteRange{offset: 90, length: 10},
+		},
+		"ok, suffix": {
+			header: "bytes=-5",
+			res:    &byteRange{offset: 95, length: 5},
+		},
+		"ok, suffix larger than size": {
+			header: "bytes=-500",
+			res:    &byteRange{offset: 0, length: 100},
+		},
+		"ok, multiple ranges": {
+			header: "bytes=0-9,20-29",
+		},
+		"error, unit": {
+			header: "items=0-9",
+			err:    ErrInvalidRange,
+		},
+		"error, start past size": {
+			header: "bytes=100-",
+			err:    ErrInvalidRange,
+		},
+		"error, end before start": {
+			header: "bytes=10-5",
+			err:    ErrInvalidRange,
+		},
+		"error, empty suffix": {
+			header: "bytes=-0",
+			err:    ErrInvalidRange,
+		},
+		"error, garbage": {
+			header: "bytes=foo",
+			err:    ErrInvalidRange,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := parseByteRange(tc.header, 100)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.res, res)
+		})
+	}
+}
+
+func TestStreamArtifact(t *testing.T) {
+	t.Parallel()
+
+	const content = "0123456789abcdefghij"
+	imageID := uuid.NewString()
+	image := &model.Image{
+		Id:   imageID,
+		Size: int64(len(content)),
+		ArtifactMeta: &model.ArtifactMeta{
+			Name: "release-1",
+		},
+	}
+
+	testCases := map[string]struct {
+		id          string
+		rangeHeader string
+
+		image    *model.Image
+		imageErr error
+
+		callDownload bool
+		offset       int64
+		length       int64
+		downloadErr  error
+
+		code         int
+		body         string
+		contentRange string
+	}{
+		"ok": {
+			id:           imageID,
+			image:        image,
+			callDownload: true,
+			length:       -1,
+			code:         http.StatusOK,
+			body:         content,
+		},
+		"ok, partial": {
+			id:           imageID,
+			rangeHeader:  "bytes=5-9",
+			image:        image,
+			callDownload: true,
+			offset:       5,
+			length:       5,
+			code:         http.StatusPartialContent,
+			body:         content[5:10],
+			contentRange: "bytes 5-9/20",
+		},
+		"ok, suffix": {
+			id:           imageID,
+			rangeHeader:  "bytes=-4",
+			image:        image,
+			callDownload: true,
+			offset:       16,
+			length:       4,
+			code:         http.StatusPartialContent,
+			body:         content[16:],
+			contentRange: "bytes 16-19/20",
+		},
+		"ok, multiple ranges fall back to full body": {
+			id:           imageID,
+			rangeHeader:  "bytes=0-1,5-6",
+			image:        image,
+			callDownload: true,
+			length:       -1,
+			code:         http.StatusOK,
+			body:         content,
+		},
+		"error, invalid id": {
+			id:   "not-a-uuid",
+			code: http.StatusBadRequest,
+		},
+		"error, image not found": {
+			id:   imageID,
+			code: http.StatusNotFound,
+		},
+		"error, range not satisfiable": {
+			id:           imageID,
+			rangeHeader:  "bytes=20-",
+			image:        image,
+			code:         http.StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */20",
+		},
+		"error, storage range not satisfiable": {
+			id:           imageID,
+			rangeHeader:  "bytes=0-4",
+			image:        image,
+			callDownload: true,
+			offset:       0,
+			length:       5,
+			downloadErr:  app.ErrArtifactRangeNotSatisfiable,
+			code:         http.StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */20",
+		},
+		"error, file not found": {
+			id:           imageID,
+			image:        image,
+			callDownload: true,
+			length:       -1,
+			downloadErr:  app.ErrUploadNotFound,
+			code:         http.StatusNotFound,
+		},
+		"error, internal": {
+			id:       imageID,
+			imageErr: errors.New("mongo: connection refused"),
+			code:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.id == imageID {
+				appMock.On("GetImage", contextMatcher(), tc.id).
+					Return(tc.image, tc.imageErr)
+			}
+			if tc.callDownload {
+				var body io.ReadCloser
+				if tc.downloadErr == nil {
+					body = io.NopCloser(strings.NewReader(tc.body))
+				}
+				appMock.On("DownloadArtifact", contextMatcher(), tc.image,
+					tc.offset, tc.length).
+					Return(body, tc.downloadErr)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, appMock)
+			api := setUpRestTest(
+				ApiUrlManagementArtifactsIdStream,
+				rest.Get,
+				d.StreamArtifact,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementArtifactsIdStream, "#id", tc.id, 1)
+			req := test.MakeSimpleRequest(http.MethodGet, url, nil)
+			if tc.rangeHeader != "" {
+				req.Header.Set("Range", tc.rangeHeader)
+			}
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			hdr := recorded.Recorder.Header()
+			assert.Equal(t, tc.contentRange, hdr.Get("Content-Range"))
+			if tc.code == http.StatusOK || tc.code == http.StatusPartialContent {
+				recorded.BodyIs(tc.body)
+				assert.Equal(t, "bytes", hdr.Get("Accept-Ranges"))
+				assert.Equal(t, app.ArtifactContentType, hdr.Get("Content-Type"))
+				assert.Equal(t,
+					`attachment; filename="release-1.mender"`,
+					hdr.Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+func TestStreamArtifactForDevice(t *testing.T) {
+	t.Parallel()
+
+	const content = "0123456789"
+	deviceID := uuid.NewString()
+	imageID := uuid.NewString()
+	image := &model.Image{
+		Id:   imageID,
+		Size: int64(len(content)),
+		ArtifactMeta: &model.ArtifactMeta{
+			Name: "release-1",
+		},
+	}
+
+	testCases := map[string]struct {
+		id         string
+		noIdentity bool
+
+		callApp  bool
+		image    *model.Image
+		imageErr error
+
+		code int
+	}{
+		"ok": {
+			id:      imageID,
+			callApp: true,
+			image:   image,
+			code:    http.StatusOK,
+		},
+		"error, artifact not assigned to the device": {
+			id:       imageID,
+			callApp:  true,
+			imageErr: app.ErrArtifactNotAssigned,
+			code:     http.StatusForbidden,
+		},
+		"error, missing identity": {
+			id:         imageID,
+			noIdentity: true,
+			code:       http.StatusBadRequest,
+		},
+		"error, invalid id": {
+			id:   "not-a-uuid",
+			code: http.StatusBadRequest,
+		},
+		"error, internal": {
+			id:       imageID,
+			callApp:  true,
+			imageErr: errors.New("mongo: connection refused"),
+			code:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetImageForDevice", contextMatcher(), deviceID, tc.id).
+					Return(tc.image, tc.imageErr)
+			}
+			if tc.image != nil {
+				appMock.On("DownloadArtifact", contextMatcher(), tc.image,
+					int64(0), int64(-1)).
+					Return(io.NopCloser(strings.NewReader(content)), nil)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, appMock)
+			api := setUpRestTest(
+				ApiUrlDevicesDownloadArtifact,
+				rest.Get,
+				d.StreamArtifactForDevice,
+			)
+			ctx := context.Background()
+			if !tc.noIdentity {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Subject:  deviceID,
+					IsDevice: true,
+				})
+			}
+			url := "http://localhost" + strings.Replace(
+				ApiUrlDevicesDownloadArtifact, "#id", tc.id, 1)
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusOK {
+				recorded.BodyIs(content)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+const (
+	ParamFormat = "format"
+
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+var ErrInvalidReportFormat = errors.New(
+	"format: must be one of \"" + reportFormatJSON + "\" or \"" + reportFormatCSV + "\"",
+)
+
+// complianceReportOutcomes are the device outcomes summed up in the CSV
+// report, in column order.
+var complianceReportOutcomes = []model.DeviceDeploymentStatus{
+	model.DeviceDeploymentStatusSuccess,
+	model.DeviceDeploymentStatusAlreadyInst,
+	model.DeviceDeploymentStatusFailure,
+	model.DeviceDeploymentStatusAborted,
+	model.DeviceDeploymentStatusNoArtifact,
+	model.DeviceDeploymentStatusDecommissioned,
+}
+
+func complianceReportHeader() []string {
+	header := []string{
+		"id", "name", "artifact_name", "type",
+		"created", "created_by", "status", "finished",
+		"aborted", "aborted_by", "device_count",
+	}
+	for _, status := range complianceReportOutcomes {
+		header = append(header, "devices_"+status.String())
+	}
+	return append(header, "devices_active")
+}
+
+func formatReportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func complianceReportRecord(report *model.DeploymentReport) []string {
+	deploymentType := report.Type
+	if deploymentType == "" {
+		deploymentType = model.DeploymentTypeSoftware
+	}
+	record := []string{
+		report.ID,
+		report.Name,
+		report.ArtifactName,
+		string(deploymentType),
+		formatReportTime(&report.Created),
+		report.CreatedBy,
+		string(report.Status),
+		formatReportTime(report.Finished),
+		formatReportTime(report.Aborted),
+		report.AbortedBy,
+		strconv.Itoa(report.DeviceCount),
+	}
+	for _, status := range complianceReportOutcomes {
+		record = append(record, strconv.Itoa(report.Stats.Get(status)))
+	}
+	return append(record, strconv.Itoa(report.Stats.Active()))
+}
+
+// GetComplianceReport lists the deployments created in a time range with
+// who created and aborted them, and their device outcomes. The report is
+// paginated JSON, or the whole range streamed as CSV.
+func (d *DeploymentsApiHandlers) GetComplianceReport(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+	q := r.URL.Query()
+
+	if q.Get("from") == "" {
+		d.view.RenderError(w, r, errors.New("missing from parameter"),
+			http.StatusBadRequest, l)
+		return
+	}
+	from, err := parseEpochToTimestamp(q.Get("from"))
+	if err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "timestamp parsing failed for from parameter"),
+			http.StatusBadRequest, l)
+		return
+	}
+	to := time.Now().UTC()
+	if q.Get("to") != "" {
+		to, err = parseEpochToTimestamp(q.Get("to"))
+		if err != nil {
+			d.view.RenderError(w, r,
+				errors.Wrap(err, "timestamp parsing failed for to parameter"),
+				http.StatusBadRequest, l)
+			return
+		}
+	}
+	if !from.Before(to) {
+		d.view.RenderError(w, r, ErrInvalidTrendRange, http.StatusBadRequest, l)
+		return
+	}
+
+	switch q.Get(ParamFormat) {
+	case "", reportFormatJSON:
+	case reportFormatCSV:
+		d.streamComplianceReport(w, r, from, to)
+		return
+	default:
+		d.view.RenderError(w, r, ErrInvalidReportFormat, http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	reports, totalCount, err := d.app.GetComplianceReport(ctx, from, to,
+		int((page-1)*perPage), int(perPage))
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	d.view.RenderSuccessGet(w, reports)
+}
+
+func (d *DeploymentsApiHandlers) streamComplianceReport(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	from, to time.Time,
+) {
+	l := requestlog.GetRequestLogger(r)
+	rw := w.(http.ResponseWriter)
+	csvWriter := csv.NewWriter(rw)
+	started := false
+	err := d.app.StreamComplianceReport(r.Context(), from, to,
+		func(report *model.DeploymentReport) error {
+			if !started {
+				rw.Header().Set("Content-Type", "text/csv")
+				rw.Header().Set("Content-Disposition",
+					`attachment; filename="compliance-report.csv"`)
+				rw.WriteHeader(http.StatusOK)
+				if err := csvWriter.Write(complianceReportHeader()); err != nil {
+					return err
+				}
+				started = true
+			}
+			return csvWriter.Write(complianceReportRecord(report))
+		})
+	if err == nil && !started {
+		// empty report: the header only
+		rw.Header().Set("Content-Type", "text/csv")
+		rw.Header().Set("Content-Disposition",
+			`attachment; filename="compliance-report.csv"`)
+		rw.WriteHeader(http.StatusOK)
+		err = csvWriter.Write(complianceReportHeader())
+		started = true
+	}
+	if err != nil {
+		if !started {
+			d.view.RenderInternalError(w, r, err, l)
+			return
+		}
+		// the status is already sent: truncate the report
+		l.Errorf("failed to stream the compliance report: %s", err)
+		return
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		l.Errorf("failed to stream the compliance report: %s", err)
+	}
+}
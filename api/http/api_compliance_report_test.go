@@ -0,0 +1,255 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func TestGetComplianceReport(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	aborted := from.Add(2 * time.Hour)
+	stats := model.NewDeviceDeploymentStats()
+	stats.Set(model.DeviceDeploymentStatusSuccess, 3)
+	stats.Set(model.DeviceDeploymentStatusAborted, 1)
+	stats.Set(model.DeviceDeploymentStatusPending, 2)
+	reports := []model.DeploymentReport{{
+		ID:           "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		Name:         "production",
+		ArtifactName: "release-1",
+		Type:         model.DeploymentTypeSoftware,
+		Status:       model.DeploymentStatusFinished,
+		Created:      from.Add(time.Hour),
+		Finished:     &aborted,
+		DeviceCount:  6,
+		Stats:        stats,
+		CreatedBy:    "user-1",
+		AbortedBy:    "user-2",
+		Aborted:      &aborted,
+	}}
+
+	testCases := map[string]struct {
+		query string
+
+		callApp    bool
+		callStream bool
+		to         interface{}
+		skip       int
+		limit      int
+		reports    []model.DeploymentReport
+		count      int
+		err        error
+
+		responseCode int
+		csv          string
+		// truncated reports are sent with their status only
+		truncated bool
+	}{
+		"ok": {
+			query: fmt.Sprintf("?from=%d&to=%d&page=2&per_page=1",
+				from.Unix(), to.Unix()),
+			callApp:      true,
+			to:           to,
+			skip:         1,
+			limit:        1,
+			reports:      reports,
+			count:        3,
+			responseCode: http.StatusOK,
+		},
+		"ok, defaults": {
+			query:        fmt.Sprintf("?from=%d", from.Unix()),
+			callApp:      true,
+			to:           mock.AnythingOfType("time.Time"),
+			limit:        20,
+			reports:      []model.DeploymentReport{},
+			responseCode: http.StatusOK,
+		},
+		"ok, csv": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=csv",
+				from.Unix(), to.Unix()),
+			callStream:   true,
+			to:           to,
+			reports:      reports,
+			responseCode: http.StatusOK,
+			csv: "id,name,artifact_name,type,created,created_by,status,finished," +
+				"aborted,aborted_by,device_count,devices_success," +
+				"devices_already-installed,devices_failure,devices_aborted," +
+				"devices_noartifact,devices_decommissioned,devices_active\n" +
+				"d50eda0d-2cea-4de1-8d42-9cd3e7e86701,production,release-1," +
+				"software,2024-03-01T01:00:00Z,user-1,finished," +
+				"2024-03-01T02:00:00Z,2024-03-01T02:00:00Z,user-2,6," +
+				"3,0,0,1,0,0,2\n",
+		},
+		"ok, csv, deployment in progress": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=csv",
+				from.Unix(), to.Unix()),
+			callStream: true,
+			to:         to,
+			reports: []model.DeploymentReport{{
+				ID:           "d50eda0d-2cea-4de1-8d42-9cd3e7e86702",
+				Name:         "staging, eu",
+				ArtifactName: "release-2",
+				Status:       model.DeploymentStatusInProgress,
+				Created:      from.Add(time.Hour),
+				DeviceCount:  2,
+				Stats:        model.Stats{model.DeviceDeploymentStatusDownloadingStr: 2},
+				CreatedBy:    "user-1",
+			}},
+			responseCode: http.StatusOK,
+			csv: "id,name,artifact_name,type,created,created_by,status,finished," +
+				"aborted,aborted_by,device_count,devices_success," +
+				"devices_already-installed,devices_failure,devices_aborted," +
+				"devices_noartifact,devices_decommissioned,devices_active\n" +
+				"d50eda0d-2cea-4de1-8d42-9cd3e7e86702,\"staging, eu\",release-2," +
+				"software,2024-03-01T01:00:00Z,user-1,inprogress,,,,2," +
+				"0,0,0,0,0,0,2\n",
+		},
+		"ok, csv empty": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=csv",
+				from.Unix(), to.Unix()),
+			callStream:   true,
+			to:           to,
+			responseCode: http.StatusOK,
+			csv: "id,name,artifact_name,type,created,created_by,status,finished," +
+				"aborted,aborted_by,device_count,devices_success," +
+				"devices_already-installed,devices_failure,devices_aborted," +
+				"devices_noartifact,devices_decommissioned,devices_active\n",
+		},
+		"ko, missing from": {
+			query:        fmt.Sprintf("?to=%d", to.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid to": {
+			query:        fmt.Sprintf("?from=%d&to=now", from.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, empty range": {
+			query: fmt.Sprintf("?from=%d&to=%d",
+				to.Unix(), from.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid format": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=xml",
+				from.Unix(), to.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			query: fmt.Sprintf("?from=%d&to=%d",
+				from.Unix(), to.Unix()),
+			callApp:      true,
+			to:           to,
+			limit:        20,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+		"ko, csv error while streaming": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=csv",
+				from.Unix(), to.Unix()),
+			callStream:   true,
+			to:           to,
+			reports:      reports,
+			err:          errors.New("error"),
+			responseCode: http.StatusOK,
+			truncated:    true,
+		},
+		"ko, csv error": {
+			query: fmt.Sprintf("?from=%d&to=%d&format=csv",
+				from.Unix(), to.Unix()),
+			callStream:   true,
+			to:           to,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetComplianceReport",
+					contextMatcher(),
+					from,
+					tc.to,
+					tc.skip,
+					tc.limit,
+				).Return(tc.reports, tc.count, tc.err)
+			}
+			if tc.callStream {
+				appMock.On("StreamComplianceReport",
+					contextMatcher(),
+					from,
+					tc.to,
+					mock.AnythingOfType("func(*model.DeploymentReport) error"),
+				).Run(func(args mock.Arguments) {
+					write := args.Get(3).(func(*model.DeploymentReport) error)
+					for i := range tc.reports {
+						assert.NoError(t, write(&tc.reports[i]))
+					}
+				}).Return(tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsCompliance,
+				rest.Get,
+				d.GetComplianceReport,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsCompliance + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			if tc.truncated {
+				assert.True(t, strings.HasPrefix(
+					recorded.Recorder.Header().Get("Content-Type"), "text/csv"))
+				assert.Empty(t, recorded.Recorder.Body.String())
+				return
+			}
+			if tc.csv != "" {
+				assert.True(t, strings.HasPrefix(
+					recorded.Recorder.Header().Get("Content-Type"), "text/csv"))
+				assert.Equal(t, tc.csv, recorded.Recorder.Body.String())
+				return
+			}
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []model.DeploymentReport
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.reports, res)
+				recorded.HeaderIs(hdrTotalCount, fmt.Sprint(tc.count))
+			}
+		})
+	}
+}
@@ -16,9 +16,12 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -40,6 +43,7 @@ import (
 	"github.com/mendersoftware/deployments/app"
 	dconfig "github.com/mendersoftware/deployments/config"
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/store"
 	"github.com/mendersoftware/deployments/utils"
 )
@@ -60,12 +64,30 @@ const (
 	DefaultPerPage                      = 20
 	MaximumPerPage                      = 500
 	MaximumPerPageListDeviceDeployments = 20
+
+	// Largest deployments
+	DefaultLargestDeploymentsLimit = 10
+	MaxLargestDeploymentsLimit     = 100
+
+	// Stale deployments
+	DefaultStaleDeploymentsLimit = 20
+	MaxStaleDeploymentsLimit     = 500
+
+	// Tenants with active deployments
+	MaxActiveDeploymentsCountRateLimit = 100
+	MaxActiveDeploymentsCountLimit     = 500
 )
 
 const (
 	// Header Constants
-	hdrTotalCount    = "X-Total-Count"
-	hdrForwardedHost = "X-Forwarded-Host"
+	hdrTotalCount     = "X-Total-Count"
+	hdrForwardedHost  = "X-Forwarded-Host"
+	hdrChecksumSHA256 = "X-Checksum-Sha256"
+	hdrCacheControl   = "Cache-Control"
+	hdrWarning        = "Warning"
+	hdrETag           = "ETag"
+	hdrIfNoneMatch    = "If-None-Match"
+	hdrIfMatch        = "If-Match"
 )
 
 // storage keys
@@ -84,6 +106,27 @@ const (
 	ParamPerPage      = "per_page"
 	ParamSort         = "sort"
 	ParamID           = "id"
+	ParamAttempt      = "attempt"
+	ParamPartNumber   = "part_number"
+	ParamExpand       = "expand"
+	ParamDryRun       = "dry_run"
+
+	// ParamProvides filters artifacts by provides, given as "key:value"
+	ParamProvides = "provides"
+
+	// ParamExpireSeconds requests the validity of a download link
+	ParamExpireSeconds = "expire_seconds"
+
+	// ParamSize gives the size of a direct upload in bytes
+	ParamSize = "size"
+
+	// ParamDeviceIDPrefix filters device deployments by device ID prefix
+	ParamDeviceIDPrefix = "device_id_prefix"
+
+	// Paging and filtering of the device deployment logs
+	ParamOffset   = "offset"
+	ParamLimit    = "limit"
+	ParamMinLevel = "min_level"
 )
 
 const Redacted = "REDACTED"
@@ -115,6 +158,9 @@ var (
 	)
 	ErrArtifactFileMissing       = errors.New("request does not contain the artifact file")
 	ErrModelArtifactFileTooLarge = errors.New("Artifact file too large")
+	ErrInvalidChecksum           = errors.New(
+		"header " + hdrChecksumSHA256 + ": must be a hex-encoded SHA256 digest",
+	)
 
 	ErrInternal                   = errors.New("Internal error")
 	ErrDeploymentAlreadyFinished  = errors.New("Deployment already finished")
@@ -122,9 +168,36 @@ var (
 	ErrMissingIdentity            = errors.New("Missing identity data")
 	ErrMissingSize                = errors.New("missing size form-data")
 	ErrMissingGroupName           = errors.New("Missing group name")
+	ErrDuplicateDeviceIDs         = errors.New("devices: must not contain duplicate device IDs")
+	ErrInvalidProvidesParam       = errors.New(
+		"invalid provides parameter: must be \"key:value\" with distinct keys",
+	)
 
+	ErrInvalidTrendRange    = errors.New("invalid time range: from must be before to")
+	ErrInvalidAttempt       = errors.New("attempt: must be a positive integer")
 	ErrInvalidSortDirection = fmt.Errorf("invalid form value: must be one of \"%s\" or \"%s\"",
 		model.SortDirectionAscending, model.SortDirectionDescending)
+	ErrInvalidPartNumber = fmt.Errorf(
+		"part_number: must be an integer between 1 and %d", model.MaxUploadParts,
+	)
+	ErrInvalidExpand       = errors.New("expand: must be a boolean")
+	ErrInvalidDryRun       = errors.New("dry_run: must be a boolean")
+	ErrInvalidLargestLimit = fmt.Errorf(
+		"limit: must be an integer between 1 and %d", MaxLargestDeploymentsLimit,
+	)
+	ErrInvalidStaleLimit = fmt.Errorf(
+		"limit: must be an integer between 1 and %d", MaxStaleDeploymentsLimit,
+	)
+	ErrMissingCreatedBefore = errors.New("created_before: cannot be blank")
+	ErrInvalidRateLimit     = fmt.Errorf(
+		"rate_limit: must be an integer between 1 and %d",
+		MaxActiveDeploymentsCountRateLimit,
+	)
+	ErrInvalidSkipEmpty    = errors.New("skip_empty: must be a boolean")
+	ErrInvalidTenantsLimit = fmt.Errorf(
+		"limit: must be an integer between 1 and %d", MaxActiveDeploymentsCountLimit,
+	)
+	ErrInvalidSize = errors.New("size: must be a positive integer")
 )
 
 type Config struct {
@@ -132,6 +205,8 @@ type Config struct {
 
 	// PresignSecret holds the secret value used by the signature algorithm.
 	PresignSecret []byte
+	// PresignAlgorithm is the algorithm used for signing URLs.
+	PresignAlgorithm string
 	// PresignExpire duration until the link expires.
 	PresignExpire time.Duration
 	// PresignHostname is the signed url hostname.
@@ -150,14 +225,45 @@ type Config struct {
 	// related to releases; helpful in performing long-running maintenance and data
 	// migrations on the artifacts and releases collections.
 	DisableNewReleasesFeature bool
+
+	// DeletedDeploymentStatusResponse selects the response to status reports
+	// for deleted deployments (see config.SettingDeletedDeploymentStatusResponse).
+	DeletedDeploymentStatusResponse string
+
+	// DuplicateDeviceIDs selects the handling of device IDs listed more
+	// than once in new deployments (see config.SettingDuplicateDeviceIDs).
+	DuplicateDeviceIDs string
+
+	// MaintenanceRetryAfter is sent to the devices in the Retry-After
+	// header during maintenance.
+	MaintenanceRetryAfter time.Duration
+
+	// GenerateRetryAfter is sent to the devices in the Retry-After header
+	// when too many configuration artifacts are being generated.
+	GenerateRetryAfter time.Duration
+
+	// CompressResponses enables the gzip compression of the responses of
+	// the management list endpoints of at least CompressMinSize bytes.
+	CompressResponses bool
+	CompressMinSize   int
 }
 
 func NewConfig() *Config {
 	return &Config{
+		PresignAlgorithm:    model.SignatureAlgorithmHMAC256,
 		PresignExpire:       DefaultDownloadLinkExpire,
 		PresignScheme:       "https",
 		MaxImageSize:        DefaultMaxImageSize,
 		MaxGenerateDataSize: DefaultMaxGenerateDataSize,
+
+		DeletedDeploymentStatusResponse: dconfig.DeletedDeploymentStatusResponseNotFound,
+		DuplicateDeviceIDs:              dconfig.DuplicateDeviceIDsDedupe,
+		MaintenanceRetryAfter: time.Duration(
+			dconfig.SettingMaintenanceRetryAfterDefault,
+		) * time.Second,
+		GenerateRetryAfter: time.Duration(
+			dconfig.SettingConfigurationGenerationRetryAfterDefault,
+		) * time.Second,
 	}
 }
 
@@ -166,6 +272,11 @@ func (conf *Config) SetPresignSecret(key []byte) *Config {
 	return conf
 }
 
+func (conf *Config) SetPresignAlgorithm(algorithm string) *Config {
+	conf.PresignAlgorithm = algorithm
+	return conf
+}
+
 func (conf *Config) SetPresignExpire(duration time.Duration) *Config {
 	conf.PresignExpire = duration
 	return conf
@@ -206,6 +317,32 @@ func (conf *Config) SetDisableNewReleasesFeature(disable bool) *Config {
 	return conf
 }
 
+func (conf *Config) SetDeletedDeploymentStatusResponse(mode string) *Config {
+	conf.DeletedDeploymentStatusResponse = mode
+	return conf
+}
+
+func (conf *Config) SetDuplicateDeviceIDs(mode string) *Config {
+	conf.DuplicateDeviceIDs = mode
+	return conf
+}
+
+func (conf *Config) SetMaintenanceRetryAfter(retryAfter time.Duration) *Config {
+	conf.MaintenanceRetryAfter = retryAfter
+	return conf
+}
+
+func (conf *Config) SetCompressResponses(enable bool, minSize int) *Config {
+	conf.CompressResponses = enable
+	conf.CompressMinSize = minSize
+	return conf
+}
+
+func (conf *Config) SetGenerateRetryAfter(retryAfter time.Duration) *Config {
+	conf.GenerateRetryAfter = retryAfter
+	return conf
+}
+
 type DeploymentsApiHandlers struct {
 	view   RESTView
 	store  store.DataStore
@@ -227,6 +364,9 @@ func NewDeploymentsApiHandlers(
 		if c.PresignSecret != nil {
 			conf.PresignSecret = c.PresignSecret
 		}
+		if c.PresignAlgorithm != "" {
+			conf.PresignAlgorithm = c.PresignAlgorithm
+		}
 		if c.PresignExpire != 0 {
 			conf.PresignExpire = c.PresignExpire
 		}
@@ -242,6 +382,18 @@ func NewDeploymentsApiHandlers(
 		if c.MaxGenerateDataSize > 0 {
 			conf.MaxGenerateDataSize = c.MaxGenerateDataSize
 		}
+		if c.DeletedDeploymentStatusResponse != "" {
+			conf.DeletedDeploymentStatusResponse = c.DeletedDeploymentStatusResponse
+		}
+		if c.DuplicateDeviceIDs != "" {
+			conf.DuplicateDeviceIDs = c.DuplicateDeviceIDs
+		}
+		if c.MaintenanceRetryAfter > 0 {
+			conf.MaintenanceRetryAfter = c.MaintenanceRetryAfter
+		}
+		if c.GenerateRetryAfter > 0 {
+			conf.GenerateRetryAfter = c.GenerateRetryAfter
+		}
 		conf.DisableNewReleasesFeature = c.DisableNewReleasesFeature
 		conf.EnableDirectUpload = c.EnableDirectUpload
 		conf.EnableDirectUploadSkipVerify = c.EnableDirectUploadSkipVerify
@@ -285,10 +437,7 @@ func getReleaseOrImageFilter(r *rest.Request, version listReleasesVersion,
 		filter.Description = q.Get(ParamDescription)
 		filter.DeviceType = q.Get(ParamDeviceType)
 	} else if version == listReleasesV2 {
-		filter.Tags = q[ParamTag]
-		for i, t := range filter.Tags {
-			filter.Tags[i] = strings.ToLower(t)
-		}
+		filter.Tags = getTagsFilter(r)
 	}
 
 	if paginated {
@@ -314,9 +463,41 @@ func getReleaseOrImageFilter(r *rest.Request, version listReleasesVersion,
 	return filter
 }
 
+// getTagsFilter returns the lowercased tags from the repeated "tag" query
+// parameter.
+func getTagsFilter(r *rest.Request) []string {
+	tags := r.URL.Query()[ParamTag]
+	for i, t := range tags {
+		tags[i] = strings.ToLower(t)
+	}
+	return tags
+}
+
+// parseProvidesFilter sets the provides the artifacts must match from the
+// repeated "key:value" query parameters.
+func parseProvidesFilter(r *rest.Request, filter *model.ReleaseOrImageFilter) error {
+	params := r.URL.Query()[ParamProvides]
+	if len(params) == 0 {
+		return nil
+	}
+	filter.Provides = make(map[string]string, len(params))
+	for _, param := range params {
+		key, value, found := strings.Cut(param, ":")
+		if !found || key == "" {
+			return ErrInvalidProvidesParam
+		}
+		if _, ok := filter.Provides[key]; ok {
+			return ErrInvalidProvidesParam
+		}
+		filter.Provides[key] = value
+	}
+	return nil
+}
+
 type limitResponse struct {
-	Limit uint64 `json:"limit"`
-	Usage uint64 `json:"usage"`
+	Limit uint64   `json:"limit"`
+	Usage uint64   `json:"usage"`
+	Keys  []string `json:"keys,omitempty"`
 }
 
 func (d *DeploymentsApiHandlers) GetLimit(w rest.ResponseWriter, r *rest.Request) {
@@ -340,7 +521,72 @@ func (d *DeploymentsApiHandlers) GetLimit(w rest.ResponseWriter, r *rest.Request
 	d.view.RenderSuccessGet(w, limitResponse{
 		Limit: limit.Value,
 		Usage: 0, // TODO fill this when ready
+		Keys:  limit.Keys,
+	})
+}
+
+type limitRequest struct {
+	Limit *uint64  `json:"limit"`
+	Keys  []string `json:"keys"`
+}
+
+// PutTenantLimitInternal sets the value of the named limit for the tenant.
+func (d *DeploymentsApiHandlers) PutTenantLimitInternal(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	name := r.PathParam("name")
+	if !model.IsValidLimit(name) {
+		d.view.RenderError(w, r,
+			errors.Errorf("unsupported limit %s", name),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	var req limitRequest
+	if err := r.DecodeJsonPayload(&req); err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "malformed request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	limit := &model.Limit{Name: name}
+	if model.IsKeysLimit(name) {
+		if req.Limit != nil {
+			d.view.RenderError(w, r,
+				errors.Errorf("limit: not supported by the %s limit", name),
+				http.StatusBadRequest, l)
+			return
+		} else if err := model.ValidateLimitKeys(req.Keys); err != nil {
+			d.view.RenderError(w, r,
+				errors.Wrap(err, "keys"),
+				http.StatusBadRequest, l)
+			return
+		}
+		// an empty allowlist lifts the restriction
+		limit.Keys = req.Keys
+	} else if req.Keys != nil {
+		d.view.RenderError(w, r,
+			errors.Errorf("keys: not supported by the %s limit", name),
+			http.StatusBadRequest, l)
+		return
+	} else if req.Limit == nil {
+		d.view.RenderError(w, r,
+			errors.New("limit: cannot be blank"),
+			http.StatusBadRequest, l)
+		return
+	} else {
+		limit.Value = *req.Limit
+	}
+
+	ctx := identity.WithContext(r.Context(), &identity.Identity{
+		Tenant: r.PathParam("tenant"),
 	})
+	err := d.app.SetLimit(ctx, limit)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // images
@@ -369,11 +615,54 @@ func (d *DeploymentsApiHandlers) GetImage(w rest.ResponseWriter, r *rest.Request
 	d.view.RenderSuccessGet(w, image)
 }
 
+// notModified sets the ETag header of a list response and reports whether
+// the If-None-Match header of the request matches it, in which case the
+// 304 response has been written already.
+func notModified(w rest.ResponseWriter, r *rest.Request, version *model.ListVersion) bool {
+	if version == nil {
+		return false
+	}
+	etag := version.ETag()
+	w.Header().Set(hdrETag, etag)
+	for _, match := range strings.Split(r.Header.Get(hdrIfNoneMatch), ",") {
+		match = strings.TrimSpace(match)
+		// If-None-Match uses the weak comparison
+		if match == "*" ||
+			strings.TrimPrefix(match, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DeploymentsApiHandlers) imagesNotModified(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	filter *model.ReleaseOrImageFilter,
+) bool {
+	version, err := d.app.GetImagesListVersion(r.Context(), filter)
+	if err != nil {
+		requestlog.GetRequestLogger(r).
+			Warnf("failed to get the version of the image list: %s", err)
+		return false
+	}
+	return notModified(w, r, version)
+}
+
 func (d *DeploymentsApiHandlers) GetImages(w rest.ResponseWriter, r *rest.Request) {
 	l := requestlog.GetRequestLogger(r)
 
 	defer redactReleaseName(r)
 	filter := getReleaseOrImageFilter(r, listReleasesV1, false)
+	filter.Tags = getTagsFilter(r)
+	if err := parseProvidesFilter(r, filter); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if d.imagesNotModified(w, r, filter) {
+		return
+	}
 
 	list, _, err := d.app.ListImages(r.Context(), filter)
 	if err != nil {
@@ -389,6 +678,14 @@ func (d *DeploymentsApiHandlers) ListImages(w rest.ResponseWriter, r *rest.Reque
 
 	defer redactReleaseName(r)
 	filter := getReleaseOrImageFilter(r, listReleasesV1, true)
+	filter.Tags = getTagsFilter(r)
+	if err := parseProvidesFilter(r, filter); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if d.imagesNotModified(w, r, filter) {
+		return
+	}
 
 	list, totalCount, err := d.app.ListImages(r.Context(), filter)
 	if err != nil {
@@ -416,9 +713,31 @@ func (d *DeploymentsApiHandlers) DownloadLink(w rest.ResponseWriter, r *rest.Req
 		return
 	}
 
-	expireSeconds := config.Config.GetInt(dconfig.SettingsStorageDownloadExpireSeconds)
-	link, err := d.app.DownloadLink(r.Context(), id, time.Duration(expireSeconds)*time.Second)
-	if err != nil {
+	expire := time.Duration(
+		config.Config.GetInt(dconfig.SettingsStorageDownloadExpireSeconds),
+	) * time.Second
+	if param := r.URL.Query().Get(ParamExpireSeconds); param != "" {
+		expireSeconds, err := strconv.ParseInt(param, 10, 64)
+		// bound the expiry before converting it to a duration overflows
+		if errors.Is(err, strconv.ErrRange) && expireSeconds > 0 ||
+			expireSeconds > int64(math.MaxInt64/time.Second) {
+			d.view.RenderError(w, r, app.ErrDownloadLinkExpireTooLong,
+				http.StatusBadRequest, l)
+			return
+		} else if err != nil || expireSeconds <= 0 {
+			d.view.RenderError(w, r, ErrInvalidExpireParam, http.StatusBadRequest, l)
+			return
+		}
+		expire = time.Duration(expireSeconds) * time.Second
+	}
+	link, err := d.app.DownloadLink(r.Context(), id, expire)
+	if errors.Is(err, app.ErrDownloadLinkExpireTooLong) {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	} else if errors.Is(err, storage.ErrTooManyRequests) {
+		d.view.RenderError(w, r, err, http.StatusTooManyRequests, l)
+		return
+	} else if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
 		return
 	}
@@ -434,13 +753,28 @@ func (d *DeploymentsApiHandlers) DownloadLink(w rest.ResponseWriter, r *rest.Req
 func (d *DeploymentsApiHandlers) UploadLink(w rest.ResponseWriter, r *rest.Request) {
 	l := requestlog.GetRequestLogger(r)
 
+	var size int64
+	if param := r.URL.Query().Get(ParamSize); param != "" {
+		var err error
+		size, err = strconv.ParseInt(param, 10, 64)
+		if err != nil || size <= 0 {
+			d.view.RenderError(w, r, ErrInvalidSize, http.StatusBadRequest, l)
+			return
+		}
+	}
+
 	expireSeconds := config.Config.GetInt(dconfig.SettingsStorageUploadExpireSeconds)
 	link, err := d.app.UploadLink(
 		r.Context(),
 		time.Duration(expireSeconds)*time.Second,
 		d.config.EnableDirectUploadSkipVerify,
+		size,
 	)
-	if err != nil {
+	var tooLargeErr *app.ArtifactTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		d.view.RenderError(w, r, err, http.StatusRequestEntityTooLarge, l)
+		return
+	} else if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
 		return
 	}
@@ -461,6 +795,14 @@ func (d *DeploymentsApiHandlers) CompleteUpload(w rest.ResponseWriter, r *rest.R
 
 	artifactID := r.PathParam(ParamID)
 
+	checksum := r.Header.Get(hdrChecksumSHA256)
+	if checksum != "" {
+		if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+			d.view.RenderError(w, r, ErrInvalidChecksum, http.StatusBadRequest, l)
+			return
+		}
+	}
+
 	var metadata *model.DirectUploadMetadata
 	if d.config.EnableDirectUploadSkipVerify {
 		var directMetadata model.DirectUploadMetadata
@@ -481,13 +823,20 @@ func (d *DeploymentsApiHandlers) CompleteUpload(w rest.ResponseWriter, r *rest.R
 		}
 	}
 
-	err := d.app.CompleteUpload(ctx, artifactID, d.config.EnableDirectUploadSkipVerify, metadata)
-	switch errors.Cause(err) {
-	case nil:
+	err := d.app.CompleteUpload(
+		ctx, artifactID, d.config.EnableDirectUploadSkipVerify, metadata, checksum,
+	)
+	var tooLargeErr *app.ArtifactTooLargeError
+	switch cause := errors.Cause(err); {
+	case cause == nil:
 		// w.Header().Set("Link", "FEAT: Upload status API")
 		w.WriteHeader(http.StatusAccepted)
-	case app.ErrUploadNotFound:
+	case cause == app.ErrUploadNotFound:
 		d.view.RenderErrorNotFound(w, r, l)
+	case cause == app.ErrUploadIncomplete:
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	case errors.As(err, &tooLargeErr):
+		d.view.RenderError(w, r, err, http.StatusRequestEntityTooLarge, l)
 	default:
 		l.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -498,6 +847,113 @@ func (d *DeploymentsApiHandlers) CompleteUpload(w rest.ResponseWriter, r *rest.R
 	}
 }
 
+// renderUploadSessionError renders the errors of the resumable upload
+// handlers.
+func (d *DeploymentsApiHandlers) renderUploadSessionError(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	err error,
+	l *log.Logger,
+) {
+	switch errors.Cause(err) {
+	case app.ErrUploadNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+	case app.ErrUploadNotResumable, app.ErrResumableUploadNotSupported:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) CreateUploadSession(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	expireSeconds := config.Config.GetInt(dconfig.SettingsStorageUploadExpireSeconds)
+	session, err := d.app.CreateUploadSession(
+		r.Context(),
+		time.Duration(expireSeconds)*time.Second,
+		d.config.EnableDirectUploadSkipVerify,
+	)
+	if err != nil {
+		d.renderUploadSessionError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, session)
+}
+
+func (d *DeploymentsApiHandlers) GetUploadSession(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	session, err := d.app.GetUploadSession(r.Context(), r.PathParam(ParamID))
+	if err != nil {
+		d.renderUploadSessionError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, session)
+}
+
+func parsePartNumber(r *rest.Request) (int, error) {
+	n, err := strconv.Atoi(r.PathParam(ParamPartNumber))
+	if err != nil || n < 1 || n > model.MaxUploadParts {
+		return 0, ErrInvalidPartNumber
+	}
+	return n, nil
+}
+
+func (d *DeploymentsApiHandlers) UploadPartLink(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	partNumber, err := parsePartNumber(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	expireSeconds := config.Config.GetInt(dconfig.SettingsStorageUploadExpireSeconds)
+	link, err := d.app.UploadPartLink(
+		r.Context(),
+		r.PathParam(ParamID),
+		partNumber,
+		time.Duration(expireSeconds)*time.Second,
+	)
+	if err != nil {
+		d.renderUploadSessionError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, link)
+}
+
+type uploadPartRequest struct {
+	ETag string `json:"etag"`
+}
+
+func (d *DeploymentsApiHandlers) ReportUploadPart(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	partNumber, err := parsePartNumber(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	var req uploadPartRequest
+	if err = r.DecodeJsonPayload(&req); err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "malformed request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	part := model.UploadPart{PartNumber: partNumber, ETag: req.ETag}
+	if err = part.Validate(); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	err = d.app.ReportUploadPart(r.Context(), r.PathParam(ParamID), part)
+	if err != nil {
+		d.renderUploadSessionError(w, r, err, l)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (d *DeploymentsApiHandlers) DownloadConfiguration(w rest.ResponseWriter, r *rest.Request) {
 	if d.config.PresignSecret == nil {
 		rest.NotFound(w, r)
@@ -534,7 +990,7 @@ func (d *DeploymentsApiHandlers) DownloadConfiguration(w rest.ResponseWriter, r
 		return
 	}
 
-	if !sig.VerifyHMAC256() {
+	if !sig.Verify() {
 		d.view.RenderError(w, r,
 			errors.New("signature invalid"),
 			http.StatusForbidden, l,
@@ -560,6 +1016,11 @@ func (d *DeploymentsApiHandlers) DownloadConfiguration(w rest.ResponseWriter, r
 				),
 				http.StatusNotFound, l,
 			)
+		case app.ErrTooManyArtifactGenerations:
+			w.Header().Set("Retry-After",
+				strconv.Itoa(int(d.config.GenerateRetryAfter.Seconds())),
+			)
+			d.view.RenderError(w, r, cause, http.StatusServiceUnavailable, l)
 		default:
 			l.Error(err.Error())
 			d.view.RenderInternalError(w, r, err, l)
@@ -611,6 +1072,43 @@ func (d *DeploymentsApiHandlers) DeleteImage(w rest.ResponseWriter, r *rest.Requ
 	d.view.RenderSuccessDelete(w)
 }
 
+func (d *DeploymentsApiHandlers) ListArtifactDeletions(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	deletions, totalCount, err := d.app.ListArtifactDeletions(ctx,
+		model.ArtifactDeletionsQuery{
+			ArtifactName: r.URL.Query().Get(ParamArtifactName),
+			Skip:         int((page - 1) * perPage),
+			Limit:        int(perPage),
+		},
+	)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	if deletions == nil {
+		deletions = []model.ArtifactDeletion{}
+	}
+	d.view.RenderSuccessGet(w, deletions)
+}
+
 func (d *DeploymentsApiHandlers) EditImage(w rest.ResponseWriter, r *rest.Request) {
 	l := requestlog.GetRequestLogger(r)
 
@@ -714,16 +1212,27 @@ func (d *DeploymentsApiHandlers) newImageWithContext(
 		return
 	}
 
-	// parse multipart message
-	multipartUploadMsg, err := d.ParseMultipart(formReader)
-
+	limit, err := d.app.GetLimit(ctx, model.LimitArtifactSize)
 	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	maxSize := limit.Lower(d.config.MaxImageSize)
+
+	// parse multipart message
+	multipartUploadMsg, err := d.ParseMultipart(formReader, maxSize)
+	if err == ErrModelArtifactFileTooLarge {
+		d.view.RenderError(w, r, &app.ArtifactTooLargeError{MaxSize: maxSize},
+			http.StatusRequestEntityTooLarge, l)
+		return
+	} else if err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
 
 	imgID, err := d.app.CreateImage(ctx, multipartUploadMsg)
 	if err == nil {
+		d.warnUnknownDeviceTypes(ctx, w, r, imgID)
 		d.view.RenderSuccessPost(w, r, imgID)
 		return
 	}
@@ -739,6 +1248,16 @@ func (d *DeploymentsApiHandlers) newImageWithContext(
 		}
 		return
 	}
+	var limitErr *app.LimitExceededError
+	if errors.As(err, &limitErr) {
+		d.view.RenderError(w, r, limitErr, http.StatusForbidden, l)
+		return
+	}
+	var keysErr *app.ArtifactKeysError
+	if errors.As(err, &keysErr) {
+		d.view.RenderError(w, r, keysErr, http.StatusBadRequest, l)
+		return
+	}
 	cause := errors.Cause(err)
 	switch cause {
 	default:
@@ -753,7 +1272,8 @@ func (d *DeploymentsApiHandlers) newImageWithContext(
 		d.view.RenderError(w, r, formatArtifactUploadError(err), http.StatusBadRequest, l)
 		return
 	case utils.ErrStreamTooLarge, ErrModelArtifactFileTooLarge:
-		d.view.RenderError(w, r, ErrModelArtifactFileTooLarge, http.StatusRequestEntityTooLarge, l)
+		d.view.RenderError(w, r, &app.ArtifactTooLargeError{MaxSize: maxSize},
+			http.StatusRequestEntityTooLarge, l)
 		return
 	case app.ErrModelMissingInputMetadata, app.ErrModelMissingInputArtifact,
 		app.ErrModelInvalidMetadata, app.ErrModelMultipartUploadMsgMalformed,
@@ -764,9 +1284,133 @@ func (d *DeploymentsApiHandlers) newImageWithContext(
 	}
 }
 
-func formatArtifactUploadError(err error) error {
-	// remove generic message
-	errMsg := strings.TrimSuffix(err.Error(), ": "+app.ErrModelParsingArtifactFailed.Error())
+// ReplaceImage uploads a new file for the artifact, keeping its ID and
+// the deployments referencing it.
+func (d *DeploymentsApiHandlers) ReplaceImage(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	formReader, err := r.MultipartReader()
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	limit, err := d.app.GetLimit(ctx, model.LimitArtifactSize)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	maxSize := limit.Lower(d.config.MaxImageSize)
+
+	multipartUploadMsg, err := d.ParseMultipart(formReader, maxSize)
+	if err == ErrModelArtifactFileTooLarge {
+		d.view.RenderError(w, r, &app.ArtifactTooLargeError{MaxSize: maxSize},
+			http.StatusRequestEntityTooLarge, l)
+		return
+	} else if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	err = d.app.ReplaceImage(ctx, id, multipartUploadMsg)
+	var keysErr *app.ArtifactKeysError
+	if errors.As(err, &keysErr) {
+		d.view.RenderError(w, r, keysErr, http.StatusBadRequest, l)
+		return
+	}
+	cause := errors.Cause(err)
+	switch cause {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrImageMetaNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+	case app.ErrArtifactReplaceConflict:
+		d.view.RenderError(w, r, cause, http.StatusConflict, l)
+	case app.ErrArtifactReplaceNameMismatch, app.ErrArtifactReplaceIncompatible:
+		d.view.RenderError(w, r, cause, http.StatusUnprocessableEntity, l)
+	case app.ErrModelParsingArtifactFailed:
+		d.view.RenderError(w, r, formatArtifactUploadError(err), http.StatusBadRequest, l)
+	case utils.ErrStreamTooLarge:
+		d.view.RenderError(w, r, &app.ArtifactTooLargeError{MaxSize: maxSize},
+			http.StatusRequestEntityTooLarge, l)
+	case app.ErrModelInvalidMetadata, io.ErrUnexpectedEOF:
+		d.view.RenderError(w, r, cause, http.StatusBadRequest, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// PutImageTags replaces the tags of the artifact.
+func (d *DeploymentsApiHandlers) PutImageTags(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	var tags model.Tags
+	if err := r.DecodeJsonPayload(&tags); err != nil {
+		d.view.RenderError(w, r,
+			errors.WithMessage(err, "malformed JSON in request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if err := tags.Validate(); err != nil {
+		d.view.RenderError(w, r,
+			errors.WithMessage(err, "invalid request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	err := d.app.ReplaceImageTags(ctx, id, tags)
+	switch err {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrImageMetaNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+	case model.ErrTooManyUniqueTags:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// warnUnknownDeviceTypes sets a warning header listing the compatible
+// device types of the artifact no device reports. The check is best-effort:
+// failing to look them up does not fail the upload.
+func (d *DeploymentsApiHandlers) warnUnknownDeviceTypes(
+	ctx context.Context,
+	w rest.ResponseWriter,
+	r *rest.Request,
+	artifactID string,
+) {
+	unknown, err := d.app.UnknownArtifactDeviceTypes(ctx, artifactID)
+	if err != nil {
+		requestlog.GetRequestLogger(r).
+			Warnf("failed to check the device types of the artifact: %s", err)
+		return
+	}
+	if len(unknown) > 0 {
+		w.Header().Set(hdrWarning, fmt.Sprintf(
+			`199 - "no device has the device type(s): %s"`,
+			strings.Join(unknown, ", "),
+		))
+	}
+}
+
+func formatArtifactUploadError(err error) error {
+	// remove generic message
+	errMsg := strings.TrimSuffix(err.Error(), ": "+app.ErrModelParsingArtifactFailed.Error())
 
 	// handle specific cases
 
@@ -831,9 +1475,11 @@ func (d *DeploymentsApiHandlers) GenerateImage(w rest.ResponseWriter, r *rest.Re
 	}
 }
 
-// ParseMultipart parses multipart/form-data message.
+// ParseMultipart parses multipart/form-data message; the artifact is read
+// up to maxSize bytes.
 func (d *DeploymentsApiHandlers) ParseMultipart(
 	r *multipart.Reader,
+	maxSize int64,
 ) (*model.MultipartUploadMsg, error) {
 	uploadMsg := &model.MultipartUploadMsg{
 		MetaConstructor: &model.ImageMeta{},
@@ -870,7 +1516,7 @@ func (d *DeploymentsApiHandlers) ParseMultipart(
 			if err != nil {
 				return nil, err
 			}
-			if size > d.config.MaxImageSize {
+			if size > maxSize {
 				return nil, ErrModelArtifactFileTooLarge
 			}
 
@@ -894,10 +1540,7 @@ func (d *DeploymentsApiHandlers) ParseMultipart(
 			if size > 0 {
 				uploadMsg.ArtifactReader = utils.ReadExactly(part, size)
 			} else {
-				uploadMsg.ArtifactReader = utils.ReadAtMost(
-					part,
-					d.config.MaxImageSize,
-				)
+				uploadMsg.ArtifactReader = utils.ReadAtMost(part, maxSize)
 			}
 			return uploadMsg, nil
 
@@ -1020,6 +1663,30 @@ func (d *DeploymentsApiHandlers) createDeployment(
 		)
 		return
 	}
+	if n := constructor.RemoveDuplicateDevices(); n > 0 {
+		if d.config.DuplicateDeviceIDs == dconfig.DuplicateDeviceIDsReject {
+			d.view.RenderError(w, r, ErrDuplicateDeviceIDs, http.StatusBadRequest, l)
+			return
+		}
+		w.Header().Set(hdrWarning, fmt.Sprintf(
+			`199 - "removed %d duplicate device ID(s)"`, n,
+		))
+	}
+	if strings.Contains(r.Header.Get(hdrCacheControl), "no-cache") {
+		ctx = app.WithoutGroupCache(ctx)
+	}
+
+	if value := r.URL.Query().Get(ParamDryRun); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			d.view.RenderError(w, r, ErrInvalidDryRun, http.StatusBadRequest, l)
+			return
+		}
+		if dryRun {
+			d.previewDeployment(w, r, ctx, l, constructor)
+			return
+		}
+	}
 
 	id, err := d.app.CreateDeployment(ctx, constructor)
 	switch err {
@@ -1030,10 +1697,34 @@ func (d *DeploymentsApiHandlers) createDeployment(
 		d.view.RenderSuccessPost(w, r, id)
 	case app.ErrNoArtifact:
 		d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
-	case app.ErrNoDevices:
+	case app.ErrNoDevices, app.ErrDownloadLinkTTLTooLong, app.ErrNoCompatibleArtifact:
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 	case app.ErrConflictingDeployment:
 		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	case app.ErrArtifactPreconditionFailed:
+		d.view.RenderError(w, r, err, http.StatusPreconditionFailed, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) previewDeployment(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	ctx context.Context,
+	l *log.Logger,
+	constructor *model.DeploymentConstructor,
+) {
+	preview, err := d.app.PreviewDeployment(ctx, constructor)
+	switch err {
+	case nil:
+		d.view.RenderSuccessGet(w, preview)
+	case app.ErrNoArtifact:
+		d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+	case app.ErrNoDevices, app.ErrDownloadLinkTTLTooLong, app.ErrNoCompatibleArtifact:
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	case app.ErrArtifactPreconditionFailed:
+		d.view.RenderError(w, r, err, http.StatusPreconditionFailed, l)
 	default:
 		d.view.RenderInternalError(w, r, err, l)
 	}
@@ -1143,6 +1834,7 @@ func (d *DeploymentsApiHandlers) getDeploymentConstructorFromBody(
 	}
 
 	constructor.Group = group
+	constructor.ExpectedArtifacts = parseIfMatch(r.Header.Get(hdrIfMatch))
 
 	if err := constructor.ValidateNew(); err != nil {
 		return nil, err
@@ -1151,6 +1843,24 @@ func (d *DeploymentsApiHandlers) getDeploymentConstructorFromBody(
 	return constructor, nil
 }
 
+// parseIfMatch returns the entity tags listed in an If-Match header without
+// their quotes. Weak tags are kept as is: If-Match uses the strong
+// comparison, they never match an artifact ID.
+func parseIfMatch(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if !strings.HasPrefix(tag, "W/") {
+			tag = strings.Trim(tag, `"`)
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 func (d *DeploymentsApiHandlers) GetDeployment(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
@@ -1263,7 +1973,12 @@ func (d *DeploymentsApiHandlers) GetDeploymentDeviceList(w rest.ResponseWriter,
 	d.view.RenderSuccessGet(w, deployment.DeviceList)
 }
 
-func (d *DeploymentsApiHandlers) AbortDeployment(w rest.ResponseWriter, r *rest.Request) {
+// GetDeploymentTargetDevices returns a paginated list of the devices targeted
+// by the deployment, including the ones which did not yet check in.
+func (d *DeploymentsApiHandlers) GetDeploymentTargetDevices(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
 
@@ -1274,122 +1989,451 @@ func (d *DeploymentsApiHandlers) AbortDeployment(w rest.ResponseWriter, r *rest.
 		return
 	}
 
-	// receive request body
-	var status struct {
-		Status model.DeviceDeploymentStatus
-	}
-
-	err := r.DecodeJsonPayload(&status)
+	page, perPage, err := rest_utils.ParsePagination(r)
 	if err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
-	// "aborted" is the only supported status
-	if status.Status != model.DeviceDeploymentStatusAborted {
-		d.view.RenderError(w, r, ErrUnexpectedDeploymentStatus, http.StatusBadRequest, l)
-	}
-
-	l.Infof("Abort deployment: %s", id)
 
-	// Check if deployment is finished
-	isDeploymentFinished, err := d.app.IsDeploymentFinished(ctx, id)
-	if err != nil {
+	devices, totalCount, err := d.app.GetDeploymentTargetDevices(
+		ctx, id, int((page-1)*perPage), int(perPage),
+	)
+	switch err {
+	case nil:
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+		return
+	default:
 		d.view.RenderInternalError(w, r, err, l)
 		return
 	}
-	if isDeploymentFinished {
-		d.view.RenderError(w, r, ErrDeploymentAlreadyFinished, http.StatusUnprocessableEntity, l)
+
+	hasNext := totalCount > int(page*perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	if devices == nil {
+		devices = []string{}
+	}
+	d.view.RenderSuccessGet(w, devices)
+}
+
+// GetFleetSoftwareInventory lists the artifact each device received with
+// its latest successful deployment, optionally only for one artifact name.
+func (d *DeploymentsApiHandlers) GetFleetSoftwareInventory(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
 
-	// Abort deployments for devices and update deployment stats
-	if err := d.app.AbortDeployment(ctx, id); err != nil {
+	inventory, totalCount, err := d.app.GetFleetSoftwareInventory(ctx,
+		model.SoftwareInventoryQuery{
+			ArtifactName: r.URL.Query().Get(ParamArtifactName),
+			Skip:         int((page - 1) * perPage),
+			Limit:        int(perPage),
+		},
+	)
+	if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
+		return
 	}
 
-	d.view.RenderEmptySuccessResponse(w)
+	hasNext := totalCount > int(page*perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	if inventory == nil {
+		inventory = []model.DeviceSoftware{}
+	}
+	d.view.RenderSuccessGet(w, inventory)
 }
 
-func (d *DeploymentsApiHandlers) GetDeploymentForDevice(w rest.ResponseWriter, r *rest.Request) {
-	var (
-		installed *model.InstalledDeviceDeployment
-		ctx       = r.Context()
-		l         = requestlog.GetRequestLogger(r)
-		idata     = identity.FromContext(ctx)
-	)
-	if idata == nil {
-		d.view.RenderError(w, r, ErrMissingIdentity, http.StatusBadRequest, l)
+func (d *DeploymentsApiHandlers) GetDeploymentCreationTrend(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+	q := r.URL.Query()
+
+	if q.Get("from") == "" {
+		d.view.RenderError(w, r, errors.New("missing from parameter"),
+			http.StatusBadRequest, l)
 		return
 	}
-
-	q := r.URL.Query()
-	defer func() {
-		var reEncode bool = false
-		if name := q.Get(ParamArtifactName); name != "" {
-			q.Set(ParamArtifactName, Redacted)
-			reEncode = true
-		}
-		if typ := q.Get(ParamDeviceType); typ != "" {
-			q.Set(ParamDeviceType, Redacted)
-			reEncode = true
-		}
-		if reEncode {
-			r.URL.RawQuery = q.Encode()
-		}
-	}()
-	if strings.EqualFold(r.Method, http.MethodPost) {
-		// POST
-		installed = new(model.InstalledDeviceDeployment)
-		if err := r.DecodeJsonPayload(&installed); err != nil {
+	from, err := parseEpochToTimestamp(q.Get("from"))
+	if err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "timestamp parsing failed for from parameter"),
+			http.StatusBadRequest, l)
+		return
+	}
+	to := time.Now().UTC()
+	if q.Get("to") != "" {
+		to, err = parseEpochToTimestamp(q.Get("to"))
+		if err != nil {
 			d.view.RenderError(w, r,
-				errors.Wrap(err, "invalid schema"),
+				errors.Wrap(err, "timestamp parsing failed for to parameter"),
 				http.StatusBadRequest, l)
 			return
 		}
-	} else {
-		// GET or HEAD
-		installed = &model.InstalledDeviceDeployment{
-			ArtifactName: q.Get(ParamArtifactName),
-			DeviceType:   q.Get(ParamDeviceType),
-		}
 	}
-
-	if err := installed.Validate(); err != nil {
-		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	if !from.Before(to) {
+		d.view.RenderError(w, r, ErrInvalidTrendRange, http.StatusBadRequest, l)
 		return
 	}
-
-	request := &model.DeploymentNextRequest{
-		DeviceProvides: installed,
+	granularity := model.TrendGranularityDay
+	if g := q.Get("granularity"); g != "" {
+		granularity = model.TrendGranularity(g)
+		if err = granularity.Validate(); err != nil {
+			d.view.RenderError(w, r,
+				errors.Wrap(err, "invalid granularity parameter"),
+				http.StatusBadRequest, l)
+			return
+		}
 	}
 
-	d.getDeploymentForDevice(w, r, idata, request)
+	buckets, err := d.app.GetDeploymentCreationTrend(ctx, from, to, granularity)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, buckets)
 }
 
-func (d *DeploymentsApiHandlers) getDeploymentForDevice(
+func (d *DeploymentsApiHandlers) GetLargestDeployments(
 	w rest.ResponseWriter,
 	r *rest.Request,
-	idata *identity.Identity,
-	request *model.DeploymentNextRequest,
 ) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
 
-	deployment, err := d.app.GetDeploymentForDeviceWithCurrent(ctx, idata.Subject, request)
-	if err != nil {
-		if err == app.ErrConflictingRequestData {
-			d.view.RenderError(w, r, err, http.StatusConflict, l)
-		} else {
-			d.view.RenderInternalError(w, r, err, l)
+	limit := DefaultLargestDeploymentsLimit
+	if s := r.URL.Query().Get(ParamLimit); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxLargestDeploymentsLimit {
+			d.view.RenderError(w, r, ErrInvalidLargestLimit, http.StatusBadRequest, l)
+			return
 		}
-		return
 	}
 
-	if deployment == nil {
-		d.view.RenderNoUpdateForDevice(w)
+	deployments, err := d.app.GetLargestDeployments(ctx, limit)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
 		return
-	} else if deployment.Type == model.DeploymentTypeConfiguration {
-		// Generate pre-signed URL
+	}
+	d.view.RenderSuccessGet(w, deployments)
+}
+
+func (d *DeploymentsApiHandlers) GetDeploymentsSummary(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	counts, err := d.app.CountDeploymentsByStatus(r.Context())
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, counts)
+}
+
+func (d *DeploymentsApiHandlers) AbortDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	// receive request body
+	var status struct {
+		Status model.DeviceDeploymentStatus
+	}
+
+	err := r.DecodeJsonPayload(&status)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	// "aborted" is the only supported status
+	if status.Status != model.DeviceDeploymentStatusAborted {
+		d.view.RenderError(w, r, ErrUnexpectedDeploymentStatus, http.StatusBadRequest, l)
+	}
+
+	l.Infof("Abort deployment: %s", id)
+
+	// Check if deployment is finished
+	isDeploymentFinished, err := d.app.IsDeploymentFinished(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	if isDeploymentFinished {
+		d.view.RenderError(w, r, ErrDeploymentAlreadyFinished, http.StatusUnprocessableEntity, l)
+		return
+	}
+
+	// Abort deployments for devices and update deployment stats
+	if err := d.app.AbortDeployment(ctx, id); err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsApiHandlers) AbortDeploymentsByArtifactName(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	artifactName := r.PathParam("name")
+	l.Infof("Abort deployments of artifact: %s", artifactName)
+
+	aborted, err := d.app.AbortDeploymentsByArtifactName(ctx, artifactName)
+	if err != nil {
+		if aborted != nil && aborted.Deployments > 0 {
+			l.Errorf("aborted %d deployments before failing", aborted.Deployments)
+		}
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, aborted)
+}
+
+func (d *DeploymentsApiHandlers) DeleteDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	switch err := d.app.DeleteDeployment(ctx, id); err {
+	case nil:
+		l.Infof("Deleted deployment: %s", id)
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) RestoreDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	switch err := d.app.RestoreDeployment(ctx, id); err {
+	case nil:
+		l.Infof("Restored deployment: %s", id)
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) StartDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	switch err := d.app.StartDeployment(ctx, id); err {
+	case nil:
+		l.Infof("Started deployment: %s", id)
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	case app.ErrDeploymentNotPaused:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// PatchDeployment edits the name and the description of a deployment;
+// any other field in the request body, such as the artifact name or the
+// targeted devices, is rejected.
+func (d *DeploymentsApiHandlers) PatchDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	var patch model.DeploymentPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "malformed JSON in request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "Validating request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	switch err := d.app.UpdateDeployment(ctx, id, patch); err {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) GetDeploymentForDevice(w rest.ResponseWriter, r *rest.Request) {
+	var (
+		installed *model.InstalledDeviceDeployment
+		ctx       = r.Context()
+		l         = requestlog.GetRequestLogger(r)
+		idata     = identity.FromContext(ctx)
+	)
+	if idata == nil {
+		d.view.RenderError(w, r, ErrMissingIdentity, http.StatusBadRequest, l)
+		return
+	}
+
+	q := r.URL.Query()
+	defer func() {
+		var reEncode bool = false
+		if name := q.Get(ParamArtifactName); name != "" {
+			q.Set(ParamArtifactName, Redacted)
+			reEncode = true
+		}
+		if typ := q.Get(ParamDeviceType); typ != "" {
+			q.Set(ParamDeviceType, Redacted)
+			reEncode = true
+		}
+		if reEncode {
+			r.URL.RawQuery = q.Encode()
+		}
+	}()
+	if strings.EqualFold(r.Method, http.MethodPost) {
+		// POST
+		installed = new(model.InstalledDeviceDeployment)
+		if err := r.DecodeJsonPayload(&installed); err != nil {
+			d.view.RenderError(w, r,
+				errors.Wrap(err, "invalid schema"),
+				http.StatusBadRequest, l)
+			return
+		}
+	} else {
+		// GET or HEAD
+		installed = &model.InstalledDeviceDeployment{
+			ArtifactName: q.Get(ParamArtifactName),
+			DeviceType:   q.Get(ParamDeviceType),
+		}
+	}
+
+	if err := installed.Validate(); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	request := &model.DeploymentNextRequest{
+		DeviceProvides: installed,
+	}
+
+	d.getDeploymentForDevice(w, r, idata, request)
+}
+
+// CheckDeploymentForDevice tells the device whether a deployment is pending
+// for it without building the deployment instructions.
+func (d *DeploymentsApiHandlers) CheckDeploymentForDevice(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+	idata := identity.FromContext(ctx)
+	if idata == nil {
+		d.view.RenderError(w, r, ErrMissingIdentity, http.StatusBadRequest, l)
+		return
+	}
+
+	deploymentID, err := d.app.CheckDeploymentForDevice(ctx, idata.Subject)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	} else if deploymentID == "" {
+		d.view.RenderNoUpdateForDevice(w)
+		return
+	}
+	d.view.RenderSuccessGet(w, map[string]string{"id": deploymentID})
+}
+
+func (d *DeploymentsApiHandlers) getDeploymentForDevice(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	idata *identity.Identity,
+	request *model.DeploymentNextRequest,
+) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	deployment, err := d.app.GetDeploymentForDeviceWithCurrent(ctx, idata.Subject, request)
+	if err != nil {
+		if err == app.ErrConflictingRequestData {
+			d.view.RenderError(w, r, err, http.StatusConflict, l)
+		} else if errors.Is(err, storage.ErrTooManyRequests) {
+			d.view.RenderError(w, r, err, http.StatusTooManyRequests, l)
+		} else {
+			d.view.RenderInternalError(w, r, err, l)
+		}
+		return
+	}
+
+	if deployment == nil {
+		d.view.RenderNoUpdateForDevice(w)
+		return
+	} else if deployment.Type == model.DeploymentTypeConfiguration {
+		// Generate pre-signed URL
 		var hostName string = d.config.PresignHostname
 		if hostName == "" {
 			if hostName = r.Header.Get(hdrForwardedHost); hostName == "" {
@@ -1414,7 +2458,8 @@ func (d *DeploymentsApiHandlers) getDeploymentForDevice(
 			q.Set(model.ParamTenantID, idata.Tenant)
 			req.URL.RawQuery = q.Encode()
 		}
-		sig := model.NewRequestSignature(req, d.config.PresignSecret)
+		sig := model.NewRequestSignature(req, d.config.PresignSecret).
+			WithAlgorithm(d.config.PresignAlgorithm)
 		expireTS := time.Now().Add(d.config.PresignExpire)
 		sig.SetExpire(expireTS)
 		deployment.Artifact.Source = model.Link{
@@ -1459,6 +2504,16 @@ func (d *DeploymentsApiHandlers) PutDeploymentStatusForDevice(
 
 		if err == app.ErrDeploymentAborted || err == app.ErrDeviceDecommissioned {
 			d.view.RenderError(w, r, err, http.StatusConflict, l)
+		} else if err == app.ErrDeploymentDeleted {
+			switch d.config.DeletedDeploymentStatusResponse {
+			case dconfig.DeletedDeploymentStatusResponseIgnore:
+				l.Infof("ignoring status report for deleted deployment %s", did)
+				d.view.RenderEmptySuccessResponse(w)
+			case dconfig.DeletedDeploymentStatusResponseGone:
+				d.view.RenderError(w, r, err, http.StatusGone, l)
+			default:
+				d.view.RenderErrorNotFound(w, r, l)
+			}
 		} else if err == app.ErrStorageNotFound {
 			d.view.RenderErrorNotFound(w, r, l)
 		} else {
@@ -1484,19 +2539,25 @@ func (d *DeploymentsApiHandlers) GetDeviceStatusesForDeployment(
 		return
 	}
 
-	statuses, err := d.app.GetDeviceStatusesForDeployment(ctx, did)
-	if err != nil {
-		switch err {
-		case app.ErrModelDeploymentNotFound:
-			d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	switch r.URL.Query().Get(ParamFormat) {
+	case "", reportFormatJSON:
+	case reportFormatCSV:
+		deployment, err := d.app.GetDeployment(ctx, did)
+		if err != nil {
+			d.view.RenderInternalError(w, r, err, l)
 			return
-		default:
-			d.view.RenderInternalError(w, r, ErrInternal, l)
+		} else if deployment == nil {
+			d.view.RenderError(w, r, app.ErrModelDeploymentNotFound, http.StatusNotFound, l)
 			return
 		}
+		d.streamDeploymentReport(w, r, deployment)
+		return
+	default:
+		d.view.RenderError(w, r, ErrInvalidReportFormat, http.StatusBadRequest, l)
+		return
 	}
 
-	d.view.RenderSuccessGet(w, statuses)
+	d.streamDevicesListForDeployment(w, r, store.ListQuery{DeploymentID: did})
 }
 
 func (d *DeploymentsApiHandlers) GetDevicesListForDeployment(
@@ -1527,10 +2588,17 @@ func (d *DeploymentsApiHandlers) GetDevicesListForDeployment(
 	if status := r.URL.Query().Get("status"); status != "" {
 		lq.Status = &status
 	}
+	lq.Sort = strings.ToLower(r.URL.Query().Get(ParamSort))
 	if err = lq.Validate(); err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
+	if q := r.URL.Query(); !q.Has(ParamPage) && !q.Has(ParamPerPage) {
+		// no pagination requested: all the device deployments
+		lq.Skip, lq.Limit = 0, 0
+		d.streamDevicesListForDeployment(w, r, lq)
+		return
+	}
 
 	statuses, totalCount, err := d.app.GetDevicesListForDeployment(ctx, lq)
 	if err != nil {
@@ -1553,6 +2621,57 @@ func (d *DeploymentsApiHandlers) GetDevicesListForDeployment(
 	d.view.RenderSuccessGet(w, statuses)
 }
 
+// streamDevicesListForDeployment writes the device deployments of the list
+// query as a JSON array, encoding them one at a time from the database
+// cursor instead of loading the whole list. The status is sent along with
+// the first element: an error after that is only logged, and leaves the
+// array truncated.
+func (d *DeploymentsApiHandlers) streamDevicesListForDeployment(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	lq store.ListQuery,
+) {
+	l := requestlog.GetRequestLogger(r)
+	rw := w.(http.ResponseWriter)
+	enc := json.NewEncoder(rw)
+	started := false
+	writeHeader := func() error {
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		started = true
+		_, err := io.WriteString(rw, "[")
+		return err
+	}
+	err := d.app.StreamDevicesListForDeployment(r.Context(), lq,
+		func(deviceDeployment *model.DeviceDeployment) error {
+			if !started {
+				if err := writeHeader(); err != nil {
+					return err
+				}
+			} else if _, err := io.WriteString(rw, ","); err != nil {
+				return err
+			}
+			return enc.Encode(deviceDeployment)
+		})
+	if err == nil && !started {
+		// no devices: an empty array
+		err = writeHeader()
+	}
+	if err == nil {
+		_, err = io.WriteString(rw, "]")
+	}
+	if err != nil {
+		if started {
+			l.Errorf("failed to stream the device deployments: %s", err)
+		} else if err == app.ErrModelDeploymentNotFound {
+			d.view.RenderError(w, r, err, http.StatusNotFound, l)
+		} else {
+			l.Error(err)
+			d.view.RenderInternalError(w, r, ErrInternal, l)
+		}
+	}
+}
+
 func ParseLookupQuery(vals url.Values) (model.Query, error) {
 	query := model.Query{}
 
@@ -1561,6 +2680,8 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 		query.SearchText = search
 	}
 
+	query.DeviceID = vals.Get("device_id")
+
 	createdBefore := vals.Get("created_before")
 	if createdBefore != "" {
 		if createdBeforeTime, err := parseEpochToTimestamp(createdBefore); err != nil {
@@ -1598,6 +2719,8 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 		query.Status = model.StatusQueryPending
 	case "aborted":
 		query.Status = model.StatusQueryAborted
+	case "paused":
+		query.Status = model.StatusQueryPaused
 	case "":
 		query.Status = model.StatusQueryAny
 	default:
@@ -1605,46 +2728,166 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 
 	}
 
-	dType := vals.Get("type")
-	if dType == "" {
-		return query, nil
+	if includeDeleted := vals.Get("include_deleted"); includeDeleted != "" {
+		var err error
+		query.IncludeDeleted, err = strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return query, errors.Wrap(err, "invalid include_deleted parameter")
+		}
+	}
+
+	dType := vals.Get("type")
+	if dType == "" {
+		return query, nil
+	}
+	deploymentType := model.DeploymentType(dType)
+	if deploymentType == model.DeploymentTypeSoftware ||
+		deploymentType == model.DeploymentTypeConfiguration {
+		query.Type = deploymentType
+	} else {
+		return query, errors.Errorf("unknown deployment type %s", dType)
+	}
+
+	return query, nil
+}
+
+func parseEpochToTimestamp(epoch string) (time.Time, error) {
+	if epochInt64, err := strconv.ParseInt(epoch, 10, 64); err != nil {
+		return time.Time{}, errors.Errorf("invalid timestamp: " + epoch)
+	} else {
+		return time.Unix(epochInt64, 0).UTC(), nil
+	}
+}
+
+func (d *DeploymentsApiHandlers) LookupDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+	q := r.URL.Query()
+	defer func() {
+		if search := q.Get("search"); search != "" {
+			q.Set("search", Redacted)
+			r.URL.RawQuery = q.Encode()
+		}
+	}()
+
+	query, err := ParseLookupQuery(q)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	envelope, err := parseEnvelope(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	deps, totalCount, err := d.app.LookupDeployment(ctx, query)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	w.Header().Add(hdrTotalCount, strconv.FormatInt(totalCount, 10))
+
+	len := len(deps)
+	hasNext := false
+	if uint64(len) > perPage {
+		hasNext = true
+		len = int(perPage)
+	}
+
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+
+	d.view.RenderSuccessGet(w, listBody(envelope, deps[:len],
+		totalCount, int64(page), int64(perPage)))
+}
+
+// ListArtifactDeployments lists the summaries of the deployments, finished or
+// not, that used the artifact. Deployments are matched by the artifact name
+// as well as the ID, so the history remains available after the artifact
+// is deleted.
+func (d *DeploymentsApiHandlers) ListArtifactDeployments(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	query, err := ParseLookupQuery(r.URL.Query())
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	var artifactName string
+	image, err := d.app.GetImage(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	} else if image != nil && image.ArtifactMeta != nil {
+		artifactName = image.ArtifactMeta.Name
+	}
+
+	deps, totalCount, err := d.app.FindDeploymentsByArtifact(ctx, artifactName, id, query)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	w.Header().Add(hdrTotalCount, strconv.FormatInt(totalCount, 10))
+
+	hasNext := false
+	if uint64(len(deps)) > perPage {
+		hasNext = true
+		deps = deps[:perPage]
 	}
-	deploymentType := model.DeploymentType(dType)
-	if deploymentType == model.DeploymentTypeSoftware ||
-		deploymentType == model.DeploymentTypeConfiguration {
-		query.Type = deploymentType
-	} else {
-		return query, errors.Errorf("unknown deployment type %s", dType)
+	for _, link := range rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext) {
+		w.Header().Add("Link", link)
 	}
 
-	return query, nil
-}
-
-func parseEpochToTimestamp(epoch string) (time.Time, error) {
-	if epochInt64, err := strconv.ParseInt(epoch, 10, 64); err != nil {
-		return time.Time{}, errors.Errorf("invalid timestamp: " + epoch)
-	} else {
-		return time.Unix(epochInt64, 0).UTC(), nil
+	summaries := make([]model.DeploymentSummary, len(deps))
+	for i, dep := range deps {
+		summaries[i] = dep.Summary()
 	}
+	d.view.RenderSuccessGet(w, summaries)
 }
 
-func (d *DeploymentsApiHandlers) LookupDeployment(w rest.ResponseWriter, r *rest.Request) {
+// ListGroupDeployments lists the summaries of the deployments, finished or
+// not, that target the device group.
+func (d *DeploymentsApiHandlers) ListGroupDeployments(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
-	q := r.URL.Query()
-	defer func() {
-		if search := q.Get("search"); search != "" {
-			q.Set("search", Redacted)
-			r.URL.RawQuery = q.Encode()
-		}
-	}()
 
-	query, err := ParseLookupQuery(q)
+	group := r.PathParam("name")
+	if group == "" {
+		d.view.RenderError(w, r, ErrMissingGroupName, http.StatusBadRequest, l)
+		return
+	}
+
+	query, err := ParseLookupQuery(r.URL.Query())
 	if err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
-
 	page, perPage, err := rest_utils.ParsePagination(r)
 	if err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
@@ -1653,26 +2896,27 @@ func (d *DeploymentsApiHandlers) LookupDeployment(w rest.ResponseWriter, r *rest
 	query.Skip = int((page - 1) * perPage)
 	query.Limit = int(perPage + 1)
 
-	deps, totalCount, err := d.app.LookupDeployment(ctx, query)
+	deps, totalCount, err := d.app.FindDeploymentsByGroup(ctx, group, query)
 	if err != nil {
-		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		d.view.RenderInternalError(w, r, err, l)
 		return
 	}
 	w.Header().Add(hdrTotalCount, strconv.FormatInt(totalCount, 10))
 
-	len := len(deps)
 	hasNext := false
-	if uint64(len) > perPage {
+	if uint64(len(deps)) > perPage {
 		hasNext = true
-		len = int(perPage)
+		deps = deps[:perPage]
 	}
-
-	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
-	for _, l := range links {
-		w.Header().Add("Link", l)
+	for _, link := range rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext) {
+		w.Header().Add("Link", link)
 	}
 
-	d.view.RenderSuccessGet(w, deps[:len])
+	summaries := make([]model.DeploymentSummary, len(deps))
+	for i, dep := range deps {
+		summaries[i] = dep.Summary()
+	}
+	d.view.RenderSuccessGet(w, summaries)
 }
 
 func (d *DeploymentsApiHandlers) PutDeploymentLogForDevice(w rest.ResponseWriter, r *rest.Request) {
@@ -1711,26 +2955,64 @@ func (d *DeploymentsApiHandlers) PutDeploymentLogForDevice(w rest.ResponseWriter
 	d.view.RenderEmptySuccessResponse(w)
 }
 
+// parseLogRange parses the optional, non-negative integer query parameter
+// bounding the range of log messages.
+func parseLogRange(r *rest.Request, param string) (int, error) {
+	s := r.URL.Query().Get(param)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("%s: must be a non-negative integer", param)
+	}
+	return n, nil
+}
+
 func (d *DeploymentsApiHandlers) GetDeploymentLogForDevice(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
 
-	did := r.PathParam("id")
-	devid := r.PathParam("devid")
-
-	depl, err := d.app.GetDeviceDeploymentLog(ctx, devid, did)
-
-	if err != nil {
-		d.view.RenderInternalError(w, r, err, l)
+	query := store.ListQueryDeviceDeploymentLog{
+		DeviceID:     r.PathParam("devid"),
+		DeploymentID: r.PathParam("id"),
+		MinLevel:     r.URL.Query().Get(ParamMinLevel),
+	}
+	if s := r.URL.Query().Get(ParamAttempt); s != "" {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n < 1 {
+			d.view.RenderError(w, r, ErrInvalidAttempt, http.StatusBadRequest, l)
+			return
+		}
+		// attempts are counted from 1 in the API
+		a := uint(n - 1)
+		query.Attempt = &a
+	}
+	var err error
+	if query.Skip, err = parseLogRange(r, ParamOffset); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if query.Limit, err = parseLogRange(r, ParamLimit); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
 
-	if depl == nil {
+	logs, totalCount, err := d.app.GetDeviceDeploymentLog(ctx, query)
+	if err == app.ErrStorageNotFound {
 		d.view.RenderErrorNotFound(w, r, l)
 		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
 	}
 
-	d.view.RenderDeploymentLog(w, *depl)
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	d.view.RenderDeploymentLog(w, logs...)
 }
 
 func (d *DeploymentsApiHandlers) AbortDeviceDeployments(w rest.ResponseWriter, r *rest.Request) {
@@ -1769,6 +3051,13 @@ func (d *DeploymentsApiHandlers) ListDeviceDeployments(w rest.ResponseWriter, r
 	d.listDeviceDeployments(ctx, w, r, true)
 }
 
+// SearchDeviceDeployments lists the device deployments of a device, or of
+// all the devices whose ID starts with the device_id_prefix parameter.
+func (d *DeploymentsApiHandlers) SearchDeviceDeployments(w rest.ResponseWriter,
+	r *rest.Request) {
+	d.listDeviceDeployments(r.Context(), w, r, false)
+}
+
 func (d *DeploymentsApiHandlers) ListDeviceDeploymentsInternal(w rest.ResponseWriter,
 	r *rest.Request) {
 	ctx := r.Context()
@@ -1800,14 +3089,19 @@ func (d *DeploymentsApiHandlers) listDeviceDeployments(ctx context.Context,
 	l := requestlog.GetRequestLogger(r)
 
 	did := ""
+	didPrefix := ""
 	var IDs []string
 	if byDeviceID {
 		did = r.PathParam("id")
 	} else {
 		values := r.URL.Query()
+		did = values.Get(ParamDeviceID)
+		didPrefix = values.Get(ParamDeviceIDPrefix)
 		if values.Has("id") && len(values["id"]) > 0 {
+			// the device filters do not apply to a list of IDs
 			IDs = values["id"]
-		} else {
+			did, didPrefix = "", ""
+		} else if did == "" && didPrefix == "" {
 			d.view.RenderError(w, r, ErrEmptyID, http.StatusBadRequest, l)
 			return
 		}
@@ -1823,10 +3117,11 @@ func (d *DeploymentsApiHandlers) listDeviceDeployments(ctx context.Context,
 	}
 
 	lq := store.ListQueryDeviceDeployments{
-		Skip:     int((page - 1) * perPage),
-		Limit:    int(perPage),
-		DeviceID: did,
-		IDs:      IDs,
+		Skip:           int((page - 1) * perPage),
+		Limit:          int(perPage),
+		DeviceID:       did,
+		DeviceIDPrefix: didPrefix,
+		IDs:            IDs,
 	}
 	if status := r.URL.Query().Get("status"); status != "" {
 		lq.Status = &status
@@ -1835,21 +3130,48 @@ func (d *DeploymentsApiHandlers) listDeviceDeployments(ctx context.Context,
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
 	}
+	var expand bool
+	if value := r.URL.Query().Get(ParamExpand); value != "" {
+		expand, err = strconv.ParseBool(value)
+		if err != nil {
+			d.view.RenderError(w, r, ErrInvalidExpand, http.StatusBadRequest, l)
+			return
+		}
+	}
+	envelope, err := parseEnvelope(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
 
-	deps, totalCount, err := d.app.GetDeviceDeploymentListForDevice(ctx, lq)
+	var (
+		deps       interface{}
+		count      int
+		totalCount int
+	)
+	if expand {
+		var views []model.DeviceDeploymentView
+		views, totalCount, err = d.app.GetDeviceDeploymentViewsForDevice(ctx, lq)
+		deps, count = views, len(views)
+	} else {
+		var items []model.DeviceDeploymentListItem
+		items, totalCount, err = d.app.GetDeviceDeploymentListForDevice(ctx, lq)
+		deps, count = items, len(items)
+	}
 	if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
 		return
 	}
 	w.Header().Add(hdrTotalCount, strconv.FormatInt(int64(totalCount), 10))
 
-	hasNext := totalCount > lq.Skip+len(deps)
+	hasNext := totalCount > lq.Skip+count
 	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
 	for _, l := range links {
 		w.Header().Add("Link", l)
 	}
 
-	d.view.RenderSuccessGet(w, deps)
+	d.view.RenderSuccessGet(w, listBody(envelope, deps,
+		int64(totalCount), int64(page), int64(perPage)))
 }
 
 func (d *DeploymentsApiHandlers) AbortDeviceDeploymentsInternal(w rest.ResponseWriter,
@@ -1879,6 +3201,60 @@ func (d *DeploymentsApiHandlers) AbortDeviceDeploymentsInternal(w rest.ResponseW
 	}
 }
 
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+func (d *DeploymentsApiHandlers) CountActiveDeploymentsByDeviceInternal(w rest.ResponseWriter,
+	r *rest.Request) {
+	ctx := r.Context()
+	tenantID := r.PathParam("tenant")
+	if tenantID != "" {
+		ctx = identity.WithContext(r.Context(), &identity.Identity{
+			Tenant:   tenantID,
+			IsDevice: true,
+		})
+	}
+
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if id == "" {
+		d.view.RenderError(w, r, ErrEmptyID, http.StatusBadRequest, l)
+		return
+	}
+
+	count, err := d.app.CountActiveDeploymentsByDevice(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, countResponse{Count: count})
+}
+
+// ListActiveDeploymentsForDevice lists the unfinished deployments targeting
+// the device, whether or not the device picked them up already.
+func (d *DeploymentsApiHandlers) ListActiveDeploymentsForDevice(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if id == "" {
+		d.view.RenderError(w, r, ErrEmptyID, http.StatusBadRequest, l)
+		return
+	}
+
+	deployments, err := d.app.GetActiveDeploymentsForDevice(r.Context(), id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, deployments)
+}
+
 // tenants
 
 func (d *DeploymentsApiHandlers) ProvisionTenantsHandler(w rest.ResponseWriter, r *rest.Request) {
@@ -1920,6 +3296,211 @@ func (d *DeploymentsApiHandlers) DeploymentsPerTenantHandler(
 	d.LookupDeployment(w, r)
 }
 
+// ListTenantsActiveDeploymentsInternal counts the active deployments of a
+// page of the tenants, starting after the given tenant, at most rate_limit
+// tenants per second.
+func (d *DeploymentsApiHandlers) ListTenantsActiveDeploymentsInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	q := r.URL.Query()
+	query := model.TenantActiveDeploymentsQuery{
+		After: q.Get("after"),
+	}
+	var err error
+	if s := q.Get(ParamLimit); s != "" {
+		query.Limit, err = strconv.Atoi(s)
+		if err != nil || query.Limit < 1 || query.Limit > MaxActiveDeploymentsCountLimit {
+			d.view.RenderError(w, r, ErrInvalidTenantsLimit, http.StatusBadRequest, l)
+			return
+		}
+	}
+	if s := q.Get("rate_limit"); s != "" {
+		query.RateLimit, err = strconv.Atoi(s)
+		if err != nil || query.RateLimit < 1 ||
+			query.RateLimit > MaxActiveDeploymentsCountRateLimit {
+			d.view.RenderError(w, r, ErrInvalidRateLimit, http.StatusBadRequest, l)
+			return
+		}
+	}
+	if s := q.Get("skip_empty"); s != "" {
+		query.SkipEmpty, err = strconv.ParseBool(s)
+		if err != nil {
+			d.view.RenderError(w, r, ErrInvalidSkipEmpty, http.StatusBadRequest, l)
+			return
+		}
+	}
+
+	page, err := d.app.CountActiveDeploymentsPerTenant(r.Context(), query)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, page)
+}
+
+// ListStaleDeploymentsInternal lists the active deployments of the tenant
+// created before created_before with devices still pending or downloading.
+func (d *DeploymentsApiHandlers) ListStaleDeploymentsInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	q := r.URL.Query()
+	if q.Get("created_before") == "" {
+		d.view.RenderError(w, r, ErrMissingCreatedBefore, http.StatusBadRequest, l)
+		return
+	}
+	createdBefore, err := parseEpochToTimestamp(q.Get("created_before"))
+	if err != nil {
+		d.view.RenderError(w, r,
+			errors.Wrap(err, "timestamp parsing failed for created_before parameter"),
+			http.StatusBadRequest, l)
+		return
+	}
+	limit := DefaultStaleDeploymentsLimit
+	if s := q.Get(ParamLimit); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxStaleDeploymentsLimit {
+			d.view.RenderError(w, r, ErrInvalidStaleLimit, http.StatusBadRequest, l)
+			return
+		}
+	}
+
+	deployments, err := d.app.FindStaleActiveDeployments(tenantContext(r), createdBefore, limit)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, deployments)
+}
+
+// ListOrphanedArtifactsInternal lists the artifacts of the tenant not part of
+// any release, to spot inconsistencies before rebuilding the releases.
+func (d *DeploymentsApiHandlers) ListOrphanedArtifactsInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	artifacts, totalCount, err := d.app.ListOrphanedArtifacts(tenantContext(r),
+		int((page-1)*perPage), int(perPage))
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := totalCount > int(page*perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
+	d.view.RenderSuccessGet(w, artifacts)
+}
+
+// ArtifactsSync is a page of the artifacts modified since a given time.
+type ArtifactsSync struct {
+	Artifacts []*model.Image `json:"artifacts"`
+	// Cursor is the latest modification time of the artifacts, to pass as
+	// since when synchronizing next.
+	Cursor time.Time `json:"cursor"`
+	// CursorID is the ID of the last artifact modified at Cursor, to pass
+	// as after_id when synchronizing next.
+	CursorID string `json:"cursor_id,omitempty"`
+}
+
+// ListArtifactsModifiedInternal lists the artifacts of the tenant modified at
+// or after the since query parameter, oldest first, together with the cursor
+// to synchronize from next. The after_id query parameter skips the artifacts
+// modified at since up to that ID, which were synchronized already.
+func (d *DeploymentsApiHandlers) ListArtifactsModifiedInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	var since time.Time
+	if param := r.URL.Query().Get("since"); param != "" {
+		since, err = time.Parse(time.RFC3339Nano, param)
+		if err != nil {
+			d.view.RenderError(w, r,
+				errors.Wrap(err, "invalid since parameter"),
+				http.StatusBadRequest, l)
+			return
+		}
+	}
+	afterID := r.URL.Query().Get("after_id")
+	if afterID != "" && r.URL.Query().Get("since") == "" {
+		d.view.RenderError(w, r,
+			errors.New("after_id requires the since parameter"),
+			http.StatusBadRequest, l)
+		return
+	}
+	artifacts, err := d.app.ListArtifactsModifiedSince(tenantContext(r),
+		since, afterID, int((page-1)*perPage), int(perPage))
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	res := ArtifactsSync{
+		Artifacts: artifacts,
+		Cursor:    since,
+		CursorID:  afterID,
+	}
+	if n := len(artifacts); n > 0 && artifacts[n-1].Modified != nil {
+		res.Cursor = *artifacts[n-1].Modified
+		res.CursorID = artifacts[n-1].Id
+	}
+	hasNext := len(artifacts) == int(perPage)
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	d.view.RenderSuccessGet(w, res)
+}
+
+// ReconcileDeviceCountInternal sets the device count of the deployment to the
+// number of its device deployments, fixing a count that drifted.
+func (d *DeploymentsApiHandlers) ReconcileDeviceCountInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam("id")
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	res, err := d.app.ReconcileDeviceCount(tenantContext(r), id)
+	switch err {
+	case nil:
+		d.view.RenderSuccessGet(w, res)
+	case app.ErrModelDeploymentNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	case app.ErrDeviceCountChanged:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
 func (d *DeploymentsApiHandlers) GetTenantStorageSettingsHandler(
 	w rest.ResponseWriter,
 	r *rest.Request,
@@ -1958,7 +3539,20 @@ func (d *DeploymentsApiHandlers) PutTenantStorageSettingsHandler(
 	)
 
 	settings, err := model.ParseStorageSettingsRequest(r.Body)
-	if err != nil {
+	var validationErr *model.ValidationError
+	if errors.As(err, &validationErr) {
+		l.Error(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		err = w.WriteJson(model.StorageSettingsValidationError{
+			Error:     err.Error(),
+			RequestID: requestid.GetReqId(r),
+			Fields:    validationErr.Fields,
+		})
+		if err != nil {
+			l.Errorf("failed to serialize JSON response: %s", err.Error())
+		}
+		return
+	} else if err != nil {
 		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
 		return
 	}
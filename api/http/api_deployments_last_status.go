@@ -25,6 +25,7 @@ import (
 	"github.com/mendersoftware/go-lib-micro/requestlog"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
 
+	"github.com/mendersoftware/deployments/app"
 	"github.com/mendersoftware/deployments/model"
 )
 
@@ -54,9 +55,10 @@ func (d *DeploymentsApiHandlers) GetDeviceDeploymentLastStatus(
 			w,
 			r,
 			l,
-			errors.Wrap(err, "device ids array cannot be empty"),
+			app.ErrNoIdsGiven,
 			http.StatusBadRequest,
 		)
+		return
 	}
 
 	l.Debugf("querying %d devices ids", len(req.DeviceIds))
@@ -73,6 +75,8 @@ func (d *DeploymentsApiHandlers) GetDeviceDeploymentLastStatus(
 	switch err {
 	default:
 		d.view.RenderInternalError(w, r, err, l)
+	case app.ErrNoIdsGiven, app.ErrArrayTooBig:
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 	case nil:
 		l.Infof("outputting: %+v", lastDeployments)
 		w.WriteHeader(http.StatusOK)
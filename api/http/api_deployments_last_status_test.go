@@ -27,6 +27,7 @@ import (
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/deployments/app"
 	mapp "github.com/mendersoftware/deployments/app/mocks"
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
@@ -80,6 +81,19 @@ func TestGetDeviceDeploymentLastStatus(t *testing.T) {
 			AppError:     errors.New("some error"),
 			ResponseCode: http.StatusInternalServerError,
 		},
+		{
+			Name:         "error: no device ids",
+			InputBody:    model.DeviceDeploymentLastStatusReq{},
+			ResponseCode: http.StatusBadRequest,
+		},
+		{
+			Name: "error: too many device ids",
+			InputBody: model.DeviceDeploymentLastStatusReq{
+				DeviceIds: deviceIds,
+			},
+			AppError:     app.ErrArrayTooBig,
+			ResponseCode: http.StatusBadRequest,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -20,6 +20,7 @@ import (
 	"strconv"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/asaskevich/govalidator"
 	"github.com/pkg/errors"
 
 	"github.com/mendersoftware/go-lib-micro/log"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/mendersoftware/deployments/app"
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
 )
 
 type listReleasesVersion int64
@@ -42,8 +44,42 @@ const (
 var (
 	ErrReleaseNameNotProvided        = errors.New("at least one release name has to be provided")
 	ErrReleaseUsedInActiveDeployment = errors.New("release(s) used in active deployment")
+	ErrInvalidArtifactsSort          = errors.New(
+		"invalid artifacts_sort parameter: must be one of \"size:asc\" or \"size:desc\"",
+	)
 )
 
+const (
+	ParamArtifactsSort     = "artifacts_sort"
+	ParamArtifactsCountMin = "artifacts_count_min"
+	ParamArtifactsCountMax = "artifacts_count_max"
+)
+
+// parseArtifactsCountFilter sets the bounds of the number of artifacts of
+// the releases from the query parameters.
+func parseArtifactsCountFilter(r *rest.Request, filter *model.ReleaseOrImageFilter) error {
+	q := r.URL.Query()
+	parse := func(param string) (*int, error) {
+		value := q.Get(param)
+		if value == "" {
+			return nil, nil
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s parameter: must be an integer", param)
+		}
+		return &count, nil
+	}
+	var err error
+	if filter.ArtifactsCountMin, err = parse(ParamArtifactsCountMin); err != nil {
+		return err
+	}
+	if filter.ArtifactsCountMax, err = parse(ParamArtifactsCountMax); err != nil {
+		return err
+	}
+	return filter.Validate()
+}
+
 func redactReleaseName(r *rest.Request) {
 	q := r.URL.Query()
 	if q.Get(ParamName) != "" {
@@ -52,11 +88,32 @@ func redactReleaseName(r *rest.Request) {
 	}
 }
 
+func (d *DeploymentsApiHandlers) releasesNotModified(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	filter *model.ReleaseOrImageFilter,
+) bool {
+	version, err := d.store.GetReleasesListVersion(r.Context(), filter)
+	if err != nil {
+		requestlog.GetRequestLogger(r).
+			Warnf("failed to get the version of the release list: %s", err)
+		return false
+	}
+	return notModified(w, r, version)
+}
+
 func (d *DeploymentsApiHandlers) GetReleases(w rest.ResponseWriter, r *rest.Request) {
 	l := requestlog.GetRequestLogger(r)
 
 	defer redactReleaseName(r)
 	filter := getReleaseOrImageFilter(r, listReleasesV1, false)
+	if err := parseArtifactsCountFilter(r, filter); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if d.releasesNotModified(w, r, filter) {
+		return
+	}
 	releases, _, err := d.store.GetReleases(r.Context(), filter)
 	if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
@@ -72,6 +129,18 @@ func (d *DeploymentsApiHandlers) listReleases(w rest.ResponseWriter, r *rest.Req
 
 	defer redactReleaseName(r)
 	filter := getReleaseOrImageFilter(r, version, true)
+	if err := parseArtifactsCountFilter(r, filter); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	envelope, err := parseEnvelope(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if d.releasesNotModified(w, r, filter) {
+		return
+	}
 	releases, totalCount, err := d.store.GetReleases(r.Context(), filter)
 	if err != nil {
 		d.view.RenderInternalError(w, r, err, l)
@@ -85,11 +154,12 @@ func (d *DeploymentsApiHandlers) listReleases(w rest.ResponseWriter, r *rest.Req
 	}
 	w.Header().Add(hdrTotalCount, strconv.Itoa(totalCount))
 
+	var items interface{} = releases
 	if version == listReleasesV1 {
-		d.view.RenderSuccessGet(w, model.ConvertReleasesToV1(releases))
-	} else {
-		d.view.RenderSuccessGet(w, releases)
+		items = model.ConvertReleasesToV1(releases)
 	}
+	d.view.RenderSuccessGet(w, listBody(envelope, items,
+		int64(totalCount), int64(filter.Page), int64(filter.PerPage)))
 }
 
 func (d *DeploymentsApiHandlers) ListReleases(w rest.ResponseWriter, r *rest.Request) {
@@ -100,6 +170,72 @@ func (d *DeploymentsApiHandlers) ListReleasesV2(w rest.ResponseWriter, r *rest.R
 	d.listReleases(w, r, listReleasesV2)
 }
 
+func (d *DeploymentsApiHandlers) GetRelease(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	var artifactsSort string
+	switch sort := r.URL.Query().Get(ParamArtifactsSort); sort {
+	case "":
+	case "size:" + model.SortDirectionAscending:
+		artifactsSort = model.SortDirectionAscending
+	case "size:" + model.SortDirectionDescending:
+		artifactsSort = model.SortDirectionDescending
+	default:
+		d.view.RenderError(w, r, ErrInvalidArtifactsSort, http.StatusBadRequest, l)
+		return
+	}
+
+	release, err := d.store.GetRelease(r.Context(), r.PathParam(ParamName), artifactsSort)
+	if errors.Is(err, store.ErrNotFound) {
+		d.view.RenderError(w, r, app.ErrReleaseNotFound, http.StatusNotFound, l)
+		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, release)
+}
+
+// GetReleaseGraph returns the dependency graph of the artifacts of the
+// release.
+func (d *DeploymentsApiHandlers) GetReleaseGraph(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	release, err := d.store.GetRelease(r.Context(), r.PathParam(ParamName), "")
+	if errors.Is(err, store.ErrNotFound) {
+		d.view.RenderError(w, r, app.ErrReleaseNotFound, http.StatusNotFound, l)
+		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, model.NewReleaseGraph(release))
+}
+
+// GetReleaseForArtifact returns the release the artifact belongs to.
+func (d *DeploymentsApiHandlers) GetReleaseForArtifact(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	id := r.PathParam(ParamID)
+	if !govalidator.IsUUID(id) {
+		d.view.RenderError(w, r, ErrIDNotUUID, http.StatusBadRequest, l)
+		return
+	}
+
+	release, err := d.store.GetReleaseForArtifact(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		d.view.RenderError(w, r, app.ErrReleaseNotFound, http.StatusNotFound, l)
+		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, release)
+}
+
 func (d *DeploymentsApiHandlers) PatchRelease(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
@@ -229,6 +365,26 @@ func (d *DeploymentsApiHandlers) GetReleasesUpdateTypes(
 	}
 }
 
+func (d *DeploymentsApiHandlers) GetReleasesUpdateTypesWithCounts(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	counts, err := d.app.GetReleasesUpdateTypesWithCounts(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = w.WriteJson(counts)
+	if err != nil {
+		l.Errorf("failed to serialize JSON response: %s", err.Error())
+	}
+}
+
 func (d *DeploymentsApiHandlers) DeleteReleases(
 	w rest.ResponseWriter,
 	r *rest.Request,
@@ -267,3 +423,58 @@ func (d *DeploymentsApiHandlers) DeleteReleases(
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// DeleteRelease deletes the release along with all its artifacts.
+func (d *DeploymentsApiHandlers) DeleteRelease(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	err := d.app.DeleteRelease(r.Context(), r.PathParam(ParamName))
+	switch {
+	case err == nil:
+		d.view.RenderSuccessDelete(w)
+	case errors.Is(err, app.ErrReleaseNotFound):
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	case errors.Is(err, app.ErrReleaseInActiveDeployment):
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// GetReleaseRollout returns a page of the deployments of the release along
+// with the status of the rollout across all of them.
+func (d *DeploymentsApiHandlers) GetReleaseRollout(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	query, err := ParseLookupQuery(r.URL.Query())
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	rollout, totalCount, err := d.app.GetReleaseRollout(
+		r.Context(), r.PathParam(ParamName), query,
+	)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	w.Header().Add(hdrTotalCount, strconv.FormatInt(totalCount, 10))
+
+	hasNext := false
+	if uint64(len(rollout.Deployments)) > perPage {
+		hasNext = true
+		rollout.Deployments = rollout.Deployments[:perPage]
+	}
+	for _, link := range rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext) {
+		w.Header().Add("Link", link)
+	}
+	d.view.RenderSuccessGet(w, rollout)
+}
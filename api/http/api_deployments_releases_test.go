@@ -35,6 +35,7 @@ import (
 	"github.com/mendersoftware/deployments/model"
 	dmodel "github.com/mendersoftware/deployments/model"
 	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
 	store_mocks "github.com/mendersoftware/deployments/store/mocks"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
 	deployments_testing "github.com/mendersoftware/deployments/utils/testing"
@@ -126,6 +127,8 @@ func TestGetReleases(t *testing.T) {
 
 			store.On("GetReleases", deployments_testing.ContextMatcher(), tc.filter).
 				Return(tc.storeReleases, len(tc.storeReleases), tc.storeErr)
+			store.On("GetReleasesListVersion", deployments_testing.ContextMatcher(), tc.filter).
+				Return(nil, nil)
 
 			fileStorage := &fs_mocks.ObjectStorage{}
 
@@ -337,6 +340,8 @@ func TestListReleases(t *testing.T) {
 
 			store.On("GetReleases", deployments_testing.ContextMatcher(), tc.filter).
 				Return(tc.storeReleases, len(tc.storeReleases), tc.storeErr)
+			store.On("GetReleasesListVersion", deployments_testing.ContextMatcher(), tc.filter).
+				Return(nil, nil)
 
 			fileStorage := &fs_mocks.ObjectStorage{}
 
@@ -366,6 +371,100 @@ func TestListReleases(t *testing.T) {
 	}
 }
 
+func TestListReleasesArtifactsCount(t *testing.T) {
+	one, five := 1, 5
+	testCases := map[string]struct {
+		queryString string
+		filter      *dmodel.ReleaseOrImageFilter
+		checker     mt.ResponseChecker
+	}{
+		"ok, exactly one": {
+			queryString: "artifacts_count_min=1&artifacts_count_max=1",
+			filter: &dmodel.ReleaseOrImageFilter{
+				Page:              1,
+				PerPage:           20,
+				ArtifactsCountMin: &one,
+				ArtifactsCountMax: &one,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]dmodel.ReleaseV1{}),
+		},
+		"ok, more than": {
+			queryString: "artifacts_count_min=5",
+			filter: &dmodel.ReleaseOrImageFilter{
+				Page:              1,
+				PerPage:           20,
+				ArtifactsCountMin: &five,
+			},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				[]dmodel.ReleaseV1{}),
+		},
+		"error: min greater than max": {
+			queryString: "artifacts_count_min=5&artifacts_count_max=1",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError(
+					dmodel.ErrInvalidArtifactsCountRange.Error())),
+		},
+		"error: negative": {
+			queryString: "artifacts_count_max=-1",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError(
+					dmodel.ErrNegativeArtifactsCount.Error())),
+		},
+		"error: not a number": {
+			queryString: "artifacts_count_min=one",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError(
+					"invalid artifacts_count_min parameter: must be an integer")),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			store := &store_mocks.DataStore{}
+			defer store.AssertExpectations(t)
+
+			if tc.filter != nil {
+				store.On("GetReleases", deployments_testing.ContextMatcher(), tc.filter).
+					Return([]dmodel.Release{}, 0, nil)
+				store.On("GetReleasesListVersion",
+					deployments_testing.ContextMatcher(), tc.filter).
+					Return(nil, nil)
+			}
+
+			restView := new(view.RESTView)
+			app := app.NewDeployments(store, &fs_mocks.ObjectStorage{}, 0, false)
+
+			c := NewDeploymentsApiHandlers(store, restView, app)
+
+			api := deployments_testing.SetUpTestApi(
+				"/api/management/v1/deployments/releases/list", rest.Get, c.ListReleases)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/management/v1/deployments/releases/list?"+
+					tc.queryString,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
 func TestListReleasesV2(t *testing.T) {
 	testCases := map[string]struct {
 		filter        *dmodel.ReleaseOrImageFilter
@@ -450,6 +549,8 @@ func TestListReleasesV2(t *testing.T) {
 
 			store.On("GetReleases", deployments_testing.ContextMatcher(), tc.filter).
 				Return(tc.storeReleases, len(tc.storeReleases), tc.storeErr)
+			store.On("GetReleasesListVersion", deployments_testing.ContextMatcher(), tc.filter).
+				Return(nil, nil)
 
 			fileStorage := &fs_mocks.ObjectStorage{}
 
@@ -479,6 +580,36 @@ func TestListReleasesV2(t *testing.T) {
 	}
 }
 
+func TestListReleasesV2NotModified(t *testing.T) {
+	version := &dmodel.ListVersion{Count: 2}
+	filter := &dmodel.ReleaseOrImageFilter{Page: 1, PerPage: 20}
+
+	store := &store_mocks.DataStore{}
+	defer store.AssertExpectations(t)
+	store.On("GetReleasesListVersion", deployments_testing.ContextMatcher(), filter).
+		Return(version, nil)
+	store.On("GetReleases", deployments_testing.ContextMatcher(), filter).
+		Return([]dmodel.Release{}, 0, nil).
+		Once()
+
+	c := NewDeploymentsApiHandlers(store, new(view.RESTView), &mapp.App{})
+	api := deployments_testing.SetUpTestApi(
+		"/api/management/v2/deployments/releases", rest.Get, c.ListReleasesV2,
+	)
+	reqUrl := "http://1.2.3.4/api/management/v2/deployments/releases"
+
+	recorded := test.RunRequest(t, api, test.MakeSimpleRequest("GET", reqUrl, nil))
+	recorded.CodeIs(http.StatusOK)
+	etag := recorded.Recorder.Header().Get("ETag")
+	assert.Equal(t, version.ETag(), etag)
+
+	req := test.MakeSimpleRequest("GET", reqUrl, nil)
+	req.Header.Set("If-None-Match", etag)
+	recorded = test.RunRequest(t, api, req)
+	recorded.CodeIs(http.StatusNotModified)
+	assert.Equal(t, etag, recorded.Recorder.Header().Get("ETag"))
+}
+
 func TestPutReleaseTags(t *testing.T) {
 	t.Parallel()
 
@@ -925,6 +1056,108 @@ func TestGetReleasesUpdateTypes(t *testing.T) {
 	}
 }
 
+func TestGetReleasesUpdateTypesWithCounts(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Name string
+
+		App func(t *testing.T, self *testCase) *mapp.App
+		*http.Request
+
+		StatusCode int
+		Counts     map[string]int
+	}
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(
+			http.MethodGet,
+			fmt.Sprintf("http://localhost:1234%s",
+				ApiUrlManagementV2ReleaseAllUpdateTypesCounts,
+			),
+			nil,
+		)
+		return req
+	}
+	testCases := []testCase{
+		{
+			Name: "ok",
+
+			Request: newRequest(),
+
+			App: func(t *testing.T, self *testCase) *mapp.App {
+				appie := new(mapp.App)
+				appie.On("GetReleasesUpdateTypesWithCounts",
+					contextMatcher()).
+					Return(self.Counts, nil)
+				return appie
+			},
+
+			StatusCode: http.StatusOK,
+			Counts:     map[string]int{"rootfs-image": 2, "single-file": 1},
+		},
+		{
+			Name: "ok, no releases",
+
+			Request: newRequest(),
+
+			App: func(t *testing.T, self *testCase) *mapp.App {
+				appie := new(mapp.App)
+				appie.On("GetReleasesUpdateTypesWithCounts",
+					contextMatcher()).
+					Return(self.Counts, nil)
+				return appie
+			},
+
+			StatusCode: http.StatusOK,
+			Counts:     map[string]int{},
+		},
+		{
+			Name: "error/internal",
+
+			Request: newRequest(),
+
+			App: func(t *testing.T, self *testCase) *mapp.App {
+				appie := new(mapp.App)
+				appie.On("GetReleasesUpdateTypesWithCounts",
+					contextMatcher()).
+					Return(nil, errors.New("internal"))
+				return appie
+			},
+
+			StatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			appie := tc.App(t, &tc)
+			defer appie.AssertExpectations(t)
+
+			handlers := NewDeploymentsApiHandlers(nil, &view.RESTView{}, appie)
+			routes := ReleasesRoutes(handlers)
+			router, _ := rest.MakeRouter(routes...)
+			api := rest.NewApi()
+			api.SetApp(router)
+			handler := api.MakeHandler()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tc.Request)
+
+			rsp := w.Result()
+			assert.Equal(t, tc.StatusCode, rsp.StatusCode,
+				"unexpected status code from request")
+			if tc.Counts != nil {
+				var actual map[string]int
+				err := json.Unmarshal(w.Body.Bytes(), &actual)
+				if assert.NoError(t, err, "unexpected request body") {
+					assert.Equal(t, tc.Counts, actual)
+				}
+			}
+		})
+	}
+}
+
 func TestPatchRelease(t *testing.T) {
 	t.Parallel()
 
@@ -1046,6 +1279,290 @@ func TestPatchRelease(t *testing.T) {
 	}
 }
 
+func TestGetRelease(t *testing.T) {
+	t.Parallel()
+
+	release := &dmodel.Release{
+		Name: "release-mc-release-face",
+		Artifacts: []model.Image{
+			{Id: "2", Size: 2048},
+			{Id: "1", Size: 1024},
+		},
+		ArtifactsCount: 2,
+	}
+
+	testCases := map[string]struct {
+		query string
+
+		callStore     bool
+		artifactsSort string
+		release       *dmodel.Release
+		storeErr      error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			callStore: true,
+			release:   release,
+			checker:   mt.NewJSONResponse(http.StatusOK, nil, release),
+		},
+		"ok, artifacts by size descending": {
+			query:         "?artifacts_sort=size:desc",
+			callStore:     true,
+			artifactsSort: model.SortDirectionDescending,
+			release:       release,
+			checker:       mt.NewJSONResponse(http.StatusOK, nil, release),
+		},
+		"ok, artifacts by size ascending": {
+			query:         "?artifacts_sort=size:asc",
+			callStore:     true,
+			artifactsSort: model.SortDirectionAscending,
+			release:       release,
+			checker:       mt.NewJSONResponse(http.StatusOK, nil, release),
+		},
+		"error, invalid artifacts sort": {
+			query: "?artifacts_sort=name:desc",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError(ErrInvalidArtifactsSort.Error()),
+			),
+		},
+		"error, not found": {
+			callStore: true,
+			storeErr:  store.ErrNotFound,
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				deployments_testing.RestError(app.ErrReleaseNotFound.Error()),
+			),
+		},
+		"error, internal": {
+			callStore: true,
+			storeErr:  errors.New("database error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				deployments_testing.RestError("internal error"),
+			),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := &store_mocks.DataStore{}
+			defer ds.AssertExpectations(t)
+			if tc.callStore {
+				ds.On("GetRelease",
+					deployments_testing.ContextMatcher(),
+					"release-mc-release-face",
+					tc.artifactsSort,
+				).Return(tc.release, tc.storeErr)
+			}
+
+			c := NewDeploymentsApiHandlers(ds, &view.RESTView{}, &mapp.App{})
+			api := deployments_testing.SetUpTestApi(
+				ApiUrlManagementV2ReleasesName, rest.Get, c.GetRelease,
+			)
+
+			req := test.MakeSimpleRequest(http.MethodGet,
+				"http://localhost"+strings.ReplaceAll(ApiUrlManagementV2ReleasesName,
+					"#name", "release-mc-release-face")+tc.query,
+				nil,
+			)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetReleaseGraph(t *testing.T) {
+	t.Parallel()
+
+	release := &model.Release{
+		Name: "release-mc-release-face",
+		Artifacts: []model.Image{{
+			Id: "full",
+			ArtifactMeta: &model.ArtifactMeta{
+				DeviceTypesCompatible: []string{"rpi4"},
+				Provides:              map[string]string{"rootfs-image.checksum": "abc"},
+			},
+		}, {
+			Id: "delta",
+			ArtifactMeta: &model.ArtifactMeta{
+				DeviceTypesCompatible: []string{"rpi4"},
+				Depends:               map[string]interface{}{"rootfs-image.checksum": "abc"},
+			},
+		}},
+		ArtifactsCount: 2,
+	}
+
+	testCases := map[string]struct {
+		release  *model.Release
+		storeErr error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			release: release,
+			checker: mt.NewJSONResponse(http.StatusOK, nil, map[string]interface{}{
+				"nodes": []map[string]interface{}{{
+					"id":                "full",
+					"device_types":      []string{"rpi4"},
+					"artifact_provides": map[string]string{"rootfs-image.checksum": "abc"},
+				}, {
+					"id":               "delta",
+					"device_types":     []string{"rpi4"},
+					"artifact_depends": map[string]string{"rootfs-image.checksum": "abc"},
+				}},
+				"edges": []map[string]interface{}{{
+					"from": "full",
+					"to":   "delta",
+					"keys": []string{"rootfs-image.checksum"},
+				}},
+			}),
+		},
+		"error, not found": {
+			storeErr: store.ErrNotFound,
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				deployments_testing.RestError(app.ErrReleaseNotFound.Error()),
+			),
+		},
+		"error, internal": {
+			storeErr: errors.New("database error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				deployments_testing.RestError("internal error"),
+			),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := &store_mocks.DataStore{}
+			defer ds.AssertExpectations(t)
+			ds.On("GetRelease",
+				deployments_testing.ContextMatcher(),
+				"release-mc-release-face",
+				"",
+			).Return(tc.release, tc.storeErr)
+
+			c := NewDeploymentsApiHandlers(ds, &view.RESTView{}, &mapp.App{})
+			api := deployments_testing.SetUpTestApi(
+				ApiUrlManagementV2ReleaseGraph, rest.Get, c.GetReleaseGraph,
+			)
+
+			req := test.MakeSimpleRequest(http.MethodGet,
+				"http://localhost"+strings.ReplaceAll(ApiUrlManagementV2ReleaseGraph,
+					"#name", "release-mc-release-face"),
+				nil,
+			)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetReleaseForArtifact(t *testing.T) {
+	t.Parallel()
+
+	const artifactID = "6d4f6e27-c3bb-438c-ad9c-d9de30e59d80"
+	release := &dmodel.Release{
+		Name:           "release-mc-release-face",
+		Artifacts:      []model.Image{{Id: artifactID, Size: 1024}},
+		ArtifactsCount: 1,
+	}
+
+	testCases := map[string]struct {
+		artifactID string
+
+		callStore bool
+		release   *dmodel.Release
+		storeErr  error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			artifactID: artifactID,
+			callStore:  true,
+			release:    release,
+			checker:    mt.NewJSONResponse(http.StatusOK, nil, release),
+		},
+		"error, invalid id": {
+			artifactID: "foo",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError(ErrIDNotUUID.Error()),
+			),
+		},
+		"error, orphan artifact": {
+			artifactID: artifactID,
+			callStore:  true,
+			storeErr:   store.ErrNotFound,
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				deployments_testing.RestError(app.ErrReleaseNotFound.Error()),
+			),
+		},
+		"error, internal": {
+			artifactID: artifactID,
+			callStore:  true,
+			storeErr:   errors.New("database error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				deployments_testing.RestError("internal error"),
+			),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := &store_mocks.DataStore{}
+			defer ds.AssertExpectations(t)
+			if tc.callStore {
+				ds.On("GetReleaseForArtifact",
+					deployments_testing.ContextMatcher(),
+					tc.artifactID,
+				).Return(tc.release, tc.storeErr)
+			}
+
+			c := NewDeploymentsApiHandlers(ds, &view.RESTView{}, &mapp.App{})
+			api := deployments_testing.SetUpTestApi(
+				ApiUrlManagementArtifactsIdRelease, rest.Get, c.GetReleaseForArtifact,
+			)
+
+			req := test.MakeSimpleRequest(http.MethodGet,
+				"http://localhost"+strings.ReplaceAll(ApiUrlManagementArtifactsIdRelease,
+					"#id", tc.artifactID),
+				nil,
+			)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
 func TestDeleteReleases(t *testing.T) {
 	type testCase struct {
 		name         string
@@ -1144,3 +1661,190 @@ func TestDeleteReleases(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteRelease(t *testing.T) {
+	testCases := map[string]struct {
+		appErr  error
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			checker: mt.NewJSONResponse(http.StatusNoContent, nil, nil),
+		},
+		"not found": {
+			appErr: app.ErrReleaseNotFound,
+			checker: mt.NewJSONResponse(
+				http.StatusNotFound,
+				nil,
+				deployments_testing.RestError(app.ErrReleaseNotFound.Error()),
+			),
+		},
+		"conflict": {
+			appErr: app.ErrReleaseInActiveDeployment,
+			checker: mt.NewJSONResponse(
+				http.StatusConflict,
+				nil,
+				deployments_testing.RestError(app.ErrReleaseInActiveDeployment.Error()),
+			),
+		},
+		"internal error": {
+			appErr: errors.New("mongo error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				deployments_testing.RestError("internal error"),
+			),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			appie := new(mapp.App)
+			defer appie.AssertExpectations(t)
+			appie.On("DeleteRelease", contextMatcher(), "foo").Return(tc.appErr)
+
+			c := NewDeploymentsApiHandlers(nil, new(view.RESTView), appie)
+			api := deployments_testing.SetUpTestApi(
+				ApiUrlManagementV2ReleasesName, rest.Delete, c.DeleteRelease,
+			)
+			req := test.MakeSimpleRequest("DELETE",
+				"http://1.2.3.4"+strings.Replace(
+					ApiUrlManagementV2ReleasesName, "#name", "foo", 1),
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestGetReleaseRollout(t *testing.T) {
+	t.Parallel()
+
+	deviceCount := 2
+	summary := model.DeploymentSummary{
+		Id:           "d1",
+		Name:         "first",
+		ArtifactName: "release-1",
+		Type:         model.DeploymentTypeSoftware,
+		Status:       model.DeploymentStatusInProgress,
+		DeviceCount:  &deviceCount,
+	}
+	stats := model.ReleaseRolloutStats{
+		DeploymentCount: 2,
+		DeviceCount:     4,
+		Statistics: model.Stats{
+			model.DeviceDeploymentStatusSuccessStr:     3,
+			model.DeviceDeploymentStatusDownloadingStr: 1,
+		},
+	}
+
+	testCases := map[string]struct {
+		query string
+
+		callApp  bool
+		appQuery model.Query
+		rollout  *model.ReleaseRollout
+		count    int64
+		appErr   error
+
+		checker mt.ResponseChecker
+		links   int
+	}{
+		"ok": {
+			callApp:  true,
+			appQuery: model.Query{Limit: DefaultPerPage + 1, Sort: "desc"},
+			rollout: &model.ReleaseRollout{
+				Deployments:         []model.DeploymentSummary{summary},
+				ReleaseRolloutStats: stats,
+			},
+			count: 1,
+			checker: mt.NewJSONResponse(http.StatusOK, nil, map[string]interface{}{
+				"deployments":      []model.DeploymentSummary{summary},
+				"deployment_count": 2,
+				"device_count":     4,
+				"statistics":       stats.Statistics,
+			}),
+			links: 1,
+		},
+		"ok, next page": {
+			query:   "?page=1&per_page=1&status=inprogress",
+			callApp: true,
+			appQuery: model.Query{
+				Limit:  2,
+				Sort:   "desc",
+				Status: model.StatusQueryInProgress,
+			},
+			rollout: &model.ReleaseRollout{
+				Deployments:         []model.DeploymentSummary{summary, summary},
+				ReleaseRolloutStats: stats,
+			},
+			count: 2,
+			checker: mt.NewJSONResponse(http.StatusOK, nil, map[string]interface{}{
+				"deployments":      []model.DeploymentSummary{summary},
+				"deployment_count": 2,
+				"device_count":     4,
+				"statistics":       stats.Statistics,
+			}),
+			links: 2,
+		},
+		"error, bad pagination": {
+			query: "?per_page=0",
+			checker: mt.NewJSONResponse(
+				http.StatusBadRequest,
+				nil,
+				deployments_testing.RestError("Param per_page is out of bounds"),
+			),
+		},
+		"error, internal": {
+			callApp:  true,
+			appQuery: model.Query{Limit: DefaultPerPage + 1, Sort: "desc"},
+			appErr:   errors.New("database error"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				deployments_testing.RestError("internal error"),
+			),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetReleaseRollout",
+					deployments_testing.ContextMatcher(),
+					"release-1",
+					tc.appQuery,
+				).Return(tc.rollout, tc.count, tc.appErr)
+			}
+
+			c := NewDeploymentsApiHandlers(nil, &view.RESTView{}, appMock)
+			api := deployments_testing.SetUpTestApi(
+				ApiUrlManagementReleasesNameDeployments, rest.Get, c.GetReleaseRollout,
+			)
+
+			req := test.MakeSimpleRequest(http.MethodGet,
+				"http://localhost"+strings.ReplaceAll(ApiUrlManagementReleasesNameDeployments,
+					"#name", "release-1")+tc.query,
+				nil,
+			)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+
+			mt.CheckResponse(t, tc.checker, recorded)
+			if tc.links > 0 {
+				assert.Len(t, recorded.Recorder.Header().Values("Link"), tc.links)
+				assert.Equal(t, strconv.FormatInt(tc.count, 10),
+					recorded.Recorder.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/csv"
+	"mime"
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+var deploymentReportHeader = []string{
+	"device_id", "status", "substate", "finished", "artifact_name",
+}
+
+func deploymentReportRecord(deviceDeployment *model.DeviceDeployment) []string {
+	var artifactName string
+	if deviceDeployment.Image != nil && deviceDeployment.Image.ArtifactMeta != nil {
+		artifactName = deviceDeployment.Image.ArtifactMeta.Name
+	}
+	return []string{
+		deviceDeployment.DeviceId,
+		deviceDeployment.Status.String(),
+		deviceDeployment.SubState,
+		formatReportTime(deviceDeployment.Finished),
+		artifactName,
+	}
+}
+
+// deploymentReportDisposition names the CSV report after the deployment,
+// falling back to its ID if the name cannot be used as a file name.
+func deploymentReportDisposition(deployment *model.Deployment) string {
+	var disposition string
+	if deployment.DeploymentConstructor != nil && deployment.Name != "" {
+		disposition = mime.FormatMediaType("attachment", map[string]string{
+			"filename": deployment.Name + ".csv",
+		})
+	}
+	if disposition == "" {
+		disposition = mime.FormatMediaType("attachment", map[string]string{
+			"filename": deployment.Id + ".csv",
+		})
+	}
+	return disposition
+}
+
+// streamDeploymentReport writes the device deployment statuses of the
+// deployment as CSV, without holding them all in memory.
+func (d *DeploymentsApiHandlers) streamDeploymentReport(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	deployment *model.Deployment,
+) {
+	l := requestlog.GetRequestLogger(r)
+	rw := w.(http.ResponseWriter)
+	csvWriter := csv.NewWriter(rw)
+	started := false
+	writeHeader := func() error {
+		rw.Header().Set("Content-Type", "text/csv")
+		rw.Header().Set("Content-Disposition", deploymentReportDisposition(deployment))
+		rw.WriteHeader(http.StatusOK)
+		started = true
+		return csvWriter.Write(deploymentReportHeader)
+	}
+	err := d.app.StreamDeviceStatusesForDeployment(r.Context(), deployment.Id,
+		func(deviceDeployment *model.DeviceDeployment) error {
+			if !started {
+				if err := writeHeader(); err != nil {
+					return err
+				}
+			}
+			return csvWriter.Write(deploymentReportRecord(deviceDeployment))
+		})
+	if err == nil && !started {
+		// no devices: the header only
+		err = writeHeader()
+	}
+	if err != nil {
+		if !started {
+			d.view.RenderInternalError(w, r, err, l)
+			return
+		}
+		// the status is already sent: truncate the report
+		l.Errorf("failed to stream the deployment report: %s", err)
+		return
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		l.Errorf("failed to stream the deployment report: %s", err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func TestGetDeviceStatusesForDeploymentCSV(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	finished := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	deployment := &model.Deployment{
+		Id: deploymentID,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name: "production rollout",
+		},
+	}
+	deviceDeployments := []model.DeviceDeployment{{
+		DeviceId: "device-1",
+		Status:   model.DeviceDeploymentStatusSuccess,
+		Finished: &finished,
+		Image: &model.Image{
+			ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+		},
+	}, {
+		DeviceId: "device-2",
+		Status:   model.DeviceDeploymentStatusDownloading,
+		SubState: "fetching, 50%",
+	}}
+
+	testCases := map[string]struct {
+		query string
+
+		deployment        *model.Deployment
+		deploymentErr     error
+		callStream        bool
+		deviceDeployments []model.DeviceDeployment
+		streamErr         error
+
+		responseCode int
+		disposition  string
+		csv          string
+	}{
+		"ok": {
+			query:             "?format=csv",
+			deployment:        deployment,
+			callStream:        true,
+			deviceDeployments: deviceDeployments,
+			responseCode:      http.StatusOK,
+			disposition:       `attachment; filename="production rollout.csv"`,
+			csv: "device_id,status,substate,finished,artifact_name\n" +
+				"device-1,success,,2024-03-01T02:00:00Z,release-1\n" +
+				"device-2,downloading,\"fetching, 50%\",,\n",
+		},
+		"ok, no devices": {
+			query:        "?format=csv",
+			deployment:   deployment,
+			callStream:   true,
+			responseCode: http.StatusOK,
+			disposition:  `attachment; filename="production rollout.csv"`,
+			csv:          "device_id,status,substate,finished,artifact_name\n",
+		},
+		"ko, invalid format": {
+			query:        "?format=xml",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, deployment not found": {
+			query:        "?format=csv",
+			responseCode: http.StatusNotFound,
+		},
+		"ko, deployment error": {
+			query:         "?format=csv",
+			deploymentErr: errors.New("error"),
+			responseCode:  http.StatusInternalServerError,
+		},
+		"ko, stream error": {
+			query:        "?format=csv",
+			deployment:   deployment,
+			callStream:   true,
+			streamErr:    errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.query != "?format=xml" {
+				appMock.On("GetDeployment", contextMatcher(), deploymentID).
+					Return(tc.deployment, tc.deploymentErr)
+			}
+			if tc.callStream {
+				appMock.On("StreamDeviceStatusesForDeployment",
+					contextMatcher(),
+					deploymentID,
+					mock.AnythingOfType("func(*model.DeviceDeployment) error"),
+				).Run(func(args mock.Arguments) {
+					write := args.Get(2).(func(*model.DeviceDeployment) error)
+					for i := range tc.deviceDeployments {
+						assert.NoError(t, write(&tc.deviceDeployments[i]))
+					}
+				}).Return(tc.streamErr)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsDevices,
+				rest.Get,
+				d.GetDeviceStatusesForDeployment,
+			)
+			url := "http://localhost" +
+				strings.Replace(ApiUrlManagementDeploymentsDevices, "#id", deploymentID, 1) +
+				tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			if tc.csv != "" {
+				assert.True(t, strings.HasPrefix(
+					recorded.Recorder.Header().Get("Content-Type"), "text/csv"))
+				recorded.HeaderIs("Content-Disposition", tc.disposition)
+				assert.Equal(t, tc.csv, recorded.Recorder.Body.String())
+				return
+			}
+			recorded.ContentTypeIsJson()
+		})
+	}
+}
+
+func TestDeploymentReportDisposition(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		`attachment; filename="my \"release\".csv"`,
+		deploymentReportDisposition(&model.Deployment{
+			Id:                    "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+			DeploymentConstructor: &model.DeploymentConstructor{Name: `my "release"`},
+		}))
+	assert.Equal(t,
+		`attachment; filename=d50eda0d-2cea-4de1-8d42-9cd3e7e86701.csv`,
+		deploymentReportDisposition(&model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		}))
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -31,10 +32,13 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/mendersoftware/deployments/app"
 	mapp "github.com/mendersoftware/deployments/app/mocks"
+	dconfig "github.com/mendersoftware/deployments/config"
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/store"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
 	h "github.com/mendersoftware/deployments/utils/testing"
@@ -241,7 +245,8 @@ func TestUploadLink(t *testing.T) {
 	type testCase struct {
 		Name string
 
-		App func(t *testing.T) *mapp.App
+		Query string
+		App   func(t *testing.T) *mapp.App
 
 		StatusCode        int
 		BodyAssertionFunc func(t *testing.T, body string) bool
@@ -252,7 +257,7 @@ func TestUploadLink(t *testing.T) {
 		App: func(t *testing.T) *mapp.App {
 			app := new(mapp.App)
 			expire := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false).
+			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false, int64(0)).
 				Return(&model.UploadLink{
 					ArtifactID: "00000000-0000-0000-0000-000000000000",
 					Link: model.Link{
@@ -283,7 +288,7 @@ func TestUploadLink(t *testing.T) {
 
 		App: func(t *testing.T) *mapp.App {
 			app := new(mapp.App)
-			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false).
+			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false, int64(0)).
 				Return(nil, errors.New("error generating URL"))
 
 			return app
@@ -298,7 +303,7 @@ func TestUploadLink(t *testing.T) {
 
 		App: func(t *testing.T) *mapp.App {
 			app := new(mapp.App)
-			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false).
+			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false, int64(0)).
 				Return(nil, nil)
 
 			return app
@@ -308,6 +313,58 @@ func TestUploadLink(t *testing.T) {
 		BodyAssertionFunc: func(t *testing.T, body string) bool {
 			return true
 		},
+	}, {
+		Name: "ok/size",
+
+		Query: "?size=1024",
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false, int64(1024)).
+				Return(&model.UploadLink{
+					ArtifactID: "00000000-0000-0000-0000-000000000000",
+					Link: model.Link{
+						Uri:    "http://localhost:8080",
+						Method: "PUT",
+						Header: map[string]string{"Content-Length": "1024"},
+					},
+				}, nil)
+
+			return app
+		},
+
+		StatusCode: http.StatusOK,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Contains(t, body, `"header":{"Content-Length":"1024"}`)
+		},
+	}, {
+		Name: "error/invalid size",
+
+		Query: "?size=-1",
+		App: func(t *testing.T) *mapp.App {
+			return new(mapp.App)
+		},
+
+		StatusCode: http.StatusBadRequest,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Contains(t, body, ErrInvalidSize.Error())
+		},
+	}, {
+		Name: "error/too large",
+
+		Query: "?size=2048",
+		App: func(t *testing.T) *mapp.App {
+			tooLarge := &app.ArtifactTooLargeError{MaxSize: 1024}
+			app := new(mapp.App)
+			app.On("UploadLink", contextMatcher(), mock.AnythingOfType("time.Duration"), false, int64(2048)).
+				Return(nil, tooLarge)
+
+			return app
+		},
+
+		StatusCode: http.StatusRequestEntityTooLarge,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Contains(t, body, "the maximum size is 1024 bytes")
+		},
 	}}
 
 	for i := range testCases {
@@ -317,7 +374,7 @@ func TestUploadLink(t *testing.T) {
 			ctx := context.Background()
 			req, _ := http.NewRequest(
 				http.MethodPost,
-				"https://localhost:8443"+ApiUrlManagementArtifactsDirectUpload,
+				"https://localhost:8443"+ApiUrlManagementArtifactsDirectUpload+tc.Query,
 				nil)
 			app := tc.App(t)
 			defer app.AssertExpectations(t)
@@ -350,8 +407,9 @@ func TestCompleteUpload(t *testing.T) {
 	type testCase struct {
 		Name string
 
-		ID  string
-		App func(t *testing.T) *mapp.App
+		ID       string
+		Checksum string
+		App      func(t *testing.T) *mapp.App
 
 		StatusCode        int
 		BodyAssertionFunc func(t *testing.T, body string) bool
@@ -362,7 +420,7 @@ func TestCompleteUpload(t *testing.T) {
 		ID: sampleID,
 		App: func(t *testing.T) *mapp.App {
 			app := new(mapp.App)
-			app.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata")).
+			app.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata"), "").
 				Return(nil)
 			return app
 		},
@@ -377,7 +435,7 @@ func TestCompleteUpload(t *testing.T) {
 		ID: sampleID,
 		App: func(t *testing.T) *mapp.App {
 			app := new(mapp.App)
-			app.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata")).
+			app.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata"), "").
 				Return(errors.New("internal error"))
 
 			return app
@@ -397,7 +455,7 @@ func TestCompleteUpload(t *testing.T) {
 		ID: sampleID,
 		App: func(t *testing.T) *mapp.App {
 			mockApp := new(mapp.App)
-			mockApp.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata")).
+			mockApp.On("CompleteUpload", contextMatcher(), sampleID, false, mock.AnythingOfType("*model.DirectUploadMetadata"), "").
 				Return(app.ErrUploadNotFound)
 			return mockApp
 		},
@@ -406,6 +464,53 @@ func TestCompleteUpload(t *testing.T) {
 		BodyAssertionFunc: func(t *testing.T, body string) bool {
 			return true
 		},
+	}, {
+		Name: "ok/checksum",
+
+		ID:       sampleID,
+		Checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("CompleteUpload", contextMatcher(), sampleID, false,
+				mock.AnythingOfType("*model.DirectUploadMetadata"),
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855").
+				Return(nil)
+			return app
+		},
+
+		StatusCode: http.StatusAccepted,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Empty(t, body, body, "expected body to be empty")
+		},
+	}, {
+		Name: "error/invalid checksum",
+
+		ID:       sampleID,
+		Checksum: "not-a-digest",
+		App: func(t *testing.T) *mapp.App {
+			return new(mapp.App)
+		},
+
+		StatusCode: http.StatusBadRequest,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Contains(t, body, ErrInvalidChecksum.Error())
+		},
+	}, {
+		Name: "error/artifact too large",
+
+		ID: sampleID,
+		App: func(t *testing.T) *mapp.App {
+			mockApp := new(mapp.App)
+			mockApp.On("CompleteUpload", contextMatcher(), sampleID, false,
+				mock.AnythingOfType("*model.DirectUploadMetadata"), "").
+				Return(&app.ArtifactTooLargeError{MaxSize: 1024})
+			return mockApp
+		},
+
+		StatusCode: http.StatusRequestEntityTooLarge,
+		BodyAssertionFunc: func(t *testing.T, body string) bool {
+			return assert.Contains(t, body, "the maximum size is 1024 bytes")
+		},
 	}}
 	pathGen := func(id string) string {
 		return strings.ReplaceAll(
@@ -423,6 +528,9 @@ func TestCompleteUpload(t *testing.T) {
 				"https://localhost:8443"+pathGen(tc.ID),
 				nil,
 			)
+			if tc.Checksum != "" {
+				req.Header.Set(hdrChecksumSHA256, tc.Checksum)
+			}
 			app := tc.App(t)
 			defer app.AssertExpectations(t)
 
@@ -447,16 +555,235 @@ func TestCompleteUpload(t *testing.T) {
 	}
 }
 
+func TestUploadSessions(t *testing.T) {
+	t.Parallel()
+
+	const sampleID = "a5522c47-3c99-459b-ae6b-6049c744db7f"
+	partPath := func(part string) string {
+		return strings.NewReplacer("#id", sampleID, "#part_number", part).
+			Replace(ApiUrlManagementArtifactsDirectUploadPart)
+	}
+	expire := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name string
+
+		Method  string
+		Path    string
+		Request string
+		App     func(t *testing.T) *mapp.App
+
+		StatusCode int
+		Body       string
+	}{{
+		Name: "ok/create session",
+
+		Method: http.MethodPost,
+		Path:   ApiUrlManagementArtifactsDirectUploadResumable,
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("CreateUploadSession", contextMatcher(),
+				mock.AnythingOfType("time.Duration"), false).
+				Return(&model.UploadSession{
+					ID:        sampleID,
+					Status:    model.LinkStatusPending,
+					Expire:    expire,
+					Multipart: true,
+					Parts:     []model.UploadPart{},
+				}, nil)
+			return app
+		},
+
+		StatusCode: http.StatusOK,
+		Body: `{"id":"` + sampleID + `","status":"pending",` +
+			`"expire":"2024-01-01T00:00:00Z","multipart":true,"parts":[]}`,
+	}, {
+		Name: "error/create session not supported",
+
+		Method: http.MethodPost,
+		Path:   ApiUrlManagementArtifactsDirectUploadResumable,
+		App: func(t *testing.T) *mapp.App {
+			mockApp := new(mapp.App)
+			mockApp.On("CreateUploadSession", contextMatcher(),
+				mock.AnythingOfType("time.Duration"), false).
+				Return(nil, app.ErrResumableUploadNotSupported)
+			return mockApp
+		},
+
+		StatusCode: http.StatusConflict,
+	}, {
+		Name: "ok/get session",
+
+		Method: http.MethodGet,
+		Path: strings.ReplaceAll(
+			ApiUrlManagementArtifactsDirectUploadId, "#id", sampleID,
+		),
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("GetUploadSession", contextMatcher(), sampleID).
+				Return(&model.UploadSession{
+					ID:        sampleID,
+					Status:    model.LinkStatusPending,
+					Expire:    expire,
+					Multipart: true,
+					Parts:     []model.UploadPart{{PartNumber: 1, ETag: `"a"`}},
+				}, nil)
+			return app
+		},
+
+		StatusCode: http.StatusOK,
+		Body: `{"id":"` + sampleID + `","status":"pending",` +
+			`"expire":"2024-01-01T00:00:00Z","multipart":true,` +
+			`"parts":[{"part_number":1,"etag":"\"a\""}]}`,
+	}, {
+		Name: "error/get session not found",
+
+		Method: http.MethodGet,
+		Path: strings.ReplaceAll(
+			ApiUrlManagementArtifactsDirectUploadId, "#id", sampleID,
+		),
+		App: func(t *testing.T) *mapp.App {
+			mockApp := new(mapp.App)
+			mockApp.On("GetUploadSession", contextMatcher(), sampleID).
+				Return(nil, app.ErrUploadNotFound)
+			return mockApp
+		},
+
+		StatusCode: http.StatusNotFound,
+	}, {
+		Name: "ok/part link",
+
+		Method: http.MethodPost,
+		Path:   partPath("3") + "/link",
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("UploadPartLink", contextMatcher(), sampleID, 3,
+				mock.AnythingOfType("time.Duration")).
+				Return(&model.Link{
+					Uri:    "http://localhost/part",
+					Expire: expire,
+					Method: http.MethodPut,
+				}, nil)
+			return app
+		},
+
+		StatusCode: http.StatusOK,
+		Body: `{"uri":"http://localhost/part",` +
+			`"expire":"2024-01-01T00:00:00Z","method":"PUT"}`,
+	}, {
+		Name: "error/part link invalid part number",
+
+		Method: http.MethodPost,
+		Path:   partPath("10001") + "/link",
+		App: func(t *testing.T) *mapp.App {
+			return new(mapp.App)
+		},
+
+		StatusCode: http.StatusBadRequest,
+	}, {
+		Name: "error/part link not resumable",
+
+		Method: http.MethodPost,
+		Path:   partPath("1") + "/link",
+		App: func(t *testing.T) *mapp.App {
+			mockApp := new(mapp.App)
+			mockApp.On("UploadPartLink", contextMatcher(), sampleID, 1,
+				mock.AnythingOfType("time.Duration")).
+				Return(nil, app.ErrUploadNotResumable)
+			return mockApp
+		},
+
+		StatusCode: http.StatusConflict,
+	}, {
+		Name: "ok/report part",
+
+		Method:  http.MethodPut,
+		Path:    partPath("2"),
+		Request: `{"etag":"\"b\""}`,
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("ReportUploadPart", contextMatcher(), sampleID,
+				model.UploadPart{PartNumber: 2, ETag: `"b"`}).
+				Return(nil)
+			return app
+		},
+
+		StatusCode: http.StatusNoContent,
+	}, {
+		Name: "error/report part without etag",
+
+		Method:  http.MethodPut,
+		Path:    partPath("2"),
+		Request: `{}`,
+		App: func(t *testing.T) *mapp.App {
+			return new(mapp.App)
+		},
+
+		StatusCode: http.StatusBadRequest,
+	}, {
+		Name: "error/report part internal error",
+
+		Method:  http.MethodPut,
+		Path:    partPath("2"),
+		Request: `{"etag":"b"}`,
+		App: func(t *testing.T) *mapp.App {
+			app := new(mapp.App)
+			app.On("ReportUploadPart", contextMatcher(), sampleID,
+				model.UploadPart{PartNumber: 2, ETag: "b"}).
+				Return(errors.New("internal error"))
+			return app
+		},
+
+		StatusCode: http.StatusInternalServerError,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			var body io.Reader
+			if tc.Request != "" {
+				body = strings.NewReader(tc.Request)
+			}
+			req, _ := http.NewRequest(tc.Method, "https://localhost:8443"+tc.Path, body)
+			if body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			app := tc.App(t)
+			defer app.AssertExpectations(t)
+
+			conf := NewConfig().
+				SetEnableDirectUpload(true)
+			apiHandler, err := NewHandler(context.Background(), app, nil, conf)
+			if err != nil {
+				panic(err)
+			}
+			w := httptest.NewRecorder()
+			apiHandler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.StatusCode, w.Code, "Unexpected HTTP status code")
+			if tc.Body != "" {
+				assert.JSONEq(t, tc.Body, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestPostDeployment(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
 		Name      string
+		Config    *Config
 		InputBody interface{}
+		IfMatch   string
+		// AppInput is the constructor passed to the app, defaults to InputBody
+		AppInput *model.DeploymentConstructor
 
 		AppError               error
 		ResponseCode           int
 		ResponseLocationHeader string
+		ResponseWarningHeader  string
 		ResponseBody           interface{}
 	}{{
 		Name: "ok, device list",
@@ -467,6 +794,44 @@ func TestPostDeployment(t *testing.T) {
 		},
 		ResponseCode:           http.StatusCreated,
 		ResponseLocationHeader: "./management/v1/deployments/deployments/foo",
+	}, {
+		Name: "ok, duplicate devices removed",
+		InputBody: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			Devices: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				"f826484e-1157-4109-af21-304e6d711561",
+				"f826484e-1157-4109-af21-304e6d711560",
+			},
+		},
+		AppInput: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			Devices: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				"f826484e-1157-4109-af21-304e6d711561",
+			},
+		},
+		ResponseCode:           http.StatusCreated,
+		ResponseLocationHeader: "./management/v1/deployments/deployments/foo",
+		ResponseWarningHeader:  `199 - "removed 1 duplicate device ID(s)"`,
+	}, {
+		Name:   "error: duplicate devices rejected",
+		Config: NewConfig().SetDuplicateDeviceIDs(dconfig.DuplicateDeviceIDsReject),
+		InputBody: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			Devices: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				"f826484e-1157-4109-af21-304e6d711560",
+			},
+		},
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   ErrDuplicateDeviceIDs.Error(),
+			ReqId: "test",
+		},
 	}, {
 		Name: "ok, all devices",
 		InputBody: &model.DeploymentConstructor{
@@ -522,6 +887,73 @@ func TestPostDeployment(t *testing.T) {
 			Err:   app.ErrConflictingDeployment.Error(),
 			ReqId: "test",
 		},
+	}, {
+		Name: "error: app error: download link ttl too long",
+		InputBody: &model.DeploymentConstructor{
+			Name:            "foo",
+			ArtifactName:    "bar",
+			AllDevices:      true,
+			DownloadLinkTTL: 30 * 24 * 60 * 60,
+		},
+		AppError:     app.ErrDownloadLinkTTLTooLong,
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   app.ErrDownloadLinkTTLTooLong.Error(),
+			ReqId: "test",
+		},
+	}, {
+		Name: "ok, expected artifacts",
+		InputBody: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			AllDevices:   true,
+		},
+		IfMatch: `"f826484e-1157-4109-af21-304e6d711560", W/"weak", *`,
+		AppInput: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			AllDevices:   true,
+			ExpectedArtifacts: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				`W/"weak"`,
+				"*",
+			},
+		},
+		ResponseCode:           http.StatusCreated,
+		ResponseLocationHeader: "./management/v1/deployments/deployments/foo",
+	}, {
+		Name: "error: app error: precondition failed",
+		InputBody: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			AllDevices:   true,
+		},
+		IfMatch: `"f826484e-1157-4109-af21-304e6d711560"`,
+		AppInput: &model.DeploymentConstructor{
+			Name:              "foo",
+			ArtifactName:      "bar",
+			AllDevices:        true,
+			ExpectedArtifacts: []string{"f826484e-1157-4109-af21-304e6d711560"},
+		},
+		AppError:     app.ErrArtifactPreconditionFailed,
+		ResponseCode: http.StatusPreconditionFailed,
+		ResponseBody: rest_utils.ApiError{
+			Err:   app.ErrArtifactPreconditionFailed.Error(),
+			ReqId: "test",
+		},
+	}, {
+		Name: "error: app error: no compatible artifact",
+		InputBody: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			AllDevices:   true,
+		},
+		AppError:     app.ErrNoCompatibleArtifact,
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   app.ErrNoCompatibleArtifact.Error(),
+			ReqId: "test",
+		},
 	}, {
 		Name: "error: conflict",
 		InputBody: &model.DeploymentConstructor{
@@ -549,7 +981,9 @@ func TestPostDeployment(t *testing.T) {
 	}}
 	var constructor *model.DeploymentConstructor
 	for _, tc := range testCases {
-		if tc.InputBody != nil {
+		if tc.AppInput != nil {
+			constructor = tc.AppInput
+		} else if tc.InputBody != nil {
 			constructor = tc.InputBody.(*model.DeploymentConstructor)
 		} else {
 			constructor = nil
@@ -566,7 +1000,7 @@ func TestPostDeployment(t *testing.T) {
 				constructor,
 			).Return("foo", tc.AppError)
 			restView := new(view.RESTView)
-			d := NewDeploymentsApiHandlers(nil, restView, app)
+			d := NewDeploymentsApiHandlers(nil, restView, app, tc.Config)
 			api := setUpRestTest(
 				ApiUrlManagementDeployments,
 				rest.Post,
@@ -579,11 +1013,15 @@ func TestPostDeployment(t *testing.T) {
 				tc.InputBody,
 			)
 			req.Header.Set("X-MEN-RequestID", "test")
+			if tc.IfMatch != "" {
+				req.Header.Set(hdrIfMatch, tc.IfMatch)
+			}
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 			recorded.CodeIs(tc.ResponseCode)
 			if tc.ResponseLocationHeader != "" {
 				recorded.HeaderIs("Location", tc.ResponseLocationHeader)
 			}
+			recorded.HeaderIs(hdrWarning, tc.ResponseWarningHeader)
 			if tc.ResponseBody != nil {
 				b, _ := json.Marshal(tc.ResponseBody)
 				assert.JSONEq(t, string(b), recorded.Recorder.Body.String())
@@ -594,15 +1032,127 @@ func TestPostDeployment(t *testing.T) {
 	}
 }
 
-func TestPostDeploymentToGroup(t *testing.T) {
+func TestPostDeploymentDryRun(t *testing.T) {
 	t.Parallel()
 
-	testCases := []struct {
-		Name       string
-		InputBody  interface{}
-		InputGroup string
-
-		AppError               error
+	constructor := &model.DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		AllDevices:   true,
+	}
+	preview := &model.DeploymentPreview{
+		DeviceCount: 2,
+		Devices:     []string{"device-1", "device-2"},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callApp bool
+		appErr  error
+
+		responseCode int
+		responseBody interface{}
+	}{
+		"ok": {
+			query:        "?dry_run=true",
+			callApp:      true,
+			responseCode: http.StatusOK,
+			responseBody: preview,
+		},
+		"error: invalid dry_run": {
+			query:        "?dry_run=maybe",
+			responseCode: http.StatusBadRequest,
+			responseBody: rest_utils.ApiError{
+				Err:   ErrInvalidDryRun.Error(),
+				ReqId: "test",
+			},
+		},
+		"error: no devices": {
+			query:        "?dry_run=1",
+			callApp:      true,
+			appErr:       app.ErrNoDevices,
+			responseCode: http.StatusBadRequest,
+			responseBody: rest_utils.ApiError{
+				Err:   app.ErrNoDevices.Error(),
+				ReqId: "test",
+			},
+		},
+		"error: no artifact": {
+			query:        "?dry_run=true",
+			callApp:      true,
+			appErr:       app.ErrNoArtifact,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: rest_utils.ApiError{
+				Err:   app.ErrNoArtifact.Error(),
+				ReqId: "test",
+			},
+		},
+		"error: artifact precondition failed": {
+			query:        "?dry_run=true",
+			callApp:      true,
+			appErr:       app.ErrArtifactPreconditionFailed,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: rest_utils.ApiError{
+				Err:   app.ErrArtifactPreconditionFailed.Error(),
+				ReqId: "test",
+			},
+		},
+		"error: internal": {
+			query:        "?dry_run=true",
+			callApp:      true,
+			appErr:       errors.New("inventory unreachable"),
+			responseCode: http.StatusInternalServerError,
+			responseBody: rest_utils.ApiError{
+				Err:   "internal error",
+				ReqId: "test",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				var res *model.DeploymentPreview
+				if tc.appErr == nil {
+					res = preview
+				}
+				appMock.On("PreviewDeployment", contextMatcher(), constructor).
+					Return(res, tc.appErr).Once()
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeployments,
+				rest.Post,
+				d.PostDeployment,
+			)
+			req := test.MakeSimpleRequest(
+				"POST",
+				"http://localhost"+ApiUrlManagementDeployments+tc.query,
+				constructor,
+			)
+			req.Header.Set("X-MEN-RequestID", "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			b, _ := json.Marshal(tc.responseBody)
+			assert.JSONEq(t, string(b), recorded.Recorder.Body.String())
+		})
+	}
+}
+
+func TestPostDeploymentToGroup(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Name       string
+		InputBody  interface{}
+		InputGroup string
+
+		AppError               error
 		ResponseCode           int
 		ResponseLocationHeader string
 		ResponseBody           interface{}
@@ -900,6 +1450,48 @@ func TestDownloadConfiguration(t *testing.T) {
 			return app
 		}(),
 
+		Headers: http.Header{
+			"Content-Disposition": []string{"attachment; filename=\"artifact.mender\""},
+			"Content-Type":        []string{app.ArtifactContentType},
+			"Content-Length":      []string{"31"},
+		},
+		StatusCode: http.StatusOK,
+		Body:       []byte("*Just imagine an artifact here*"),
+	}, {
+		Name: "ok, HMAC512",
+
+		Request: func() *http.Request {
+			req, _ := http.NewRequest(
+				http.MethodGet,
+				FMTConfigURL(
+					"http", "localhost",
+					uuid.NewSHA1(uuid.NameSpaceOID, []byte("deployment")).String(),
+					"Bagelbone",
+					uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String(),
+				),
+				nil,
+			)
+			sig := model.NewRequestSignature(req, []byte("test")).
+				WithAlgorithm(model.SignatureAlgorithmHMAC512)
+			sig.SetExpire(time.Now().Add(time.Minute))
+			sig.PresignURL()
+			return req
+		}(),
+		Config: NewConfig().
+			SetPresignExpire(time.Minute).
+			SetPresignSecret([]byte("test")).
+			SetPresignHostname("localhost").
+			SetPresignScheme("http"),
+		App: func() *mapp.App {
+			app := new(mapp.App)
+			app.On("GenerateConfigurationImage",
+				contextMatcher(),
+				"Bagelbone",
+				uuid.NewSHA1(uuid.NameSpaceOID, []byte("deployment")).String(),
+			).Return(bytes.NewReader([]byte("*Just imagine an artifact here*")), nil)
+			return app
+		}(),
+
 		Headers: http.Header{
 			"Content-Disposition": []string{"attachment; filename=\"artifact.mender\""},
 			"Content-Type":        []string{app.ArtifactContentType},
@@ -1119,6 +1711,43 @@ func TestDownloadConfiguration(t *testing.T) {
 
 		StatusCode: http.StatusInternalServerError,
 		Error:      errors.New("internal error"),
+	}, {
+		Name: "error, too many artifact generations",
+
+		Config: NewConfig().
+			SetPresignSecret([]byte("test")).
+			SetGenerateRetryAfter(time.Second * 30),
+		Request: func() *http.Request {
+			req, _ := http.NewRequest(
+				http.MethodGet,
+				FMTConfigURL(
+					"http", "localhost",
+					uuid.NewSHA1(uuid.NameSpaceOID, []byte("deployment")).String(),
+					"Bagelbone",
+					uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String(),
+				),
+				nil,
+			)
+			sig := model.NewRequestSignature(req, []byte("test"))
+			sig.SetExpire(time.Now().Add(time.Minute))
+			sig.PresignURL()
+			return req
+		}(),
+		App: func() *mapp.App {
+			appl := new(mapp.App)
+			appl.On("GenerateConfigurationImage",
+				contextMatcher(),
+				"Bagelbone",
+				uuid.NewSHA1(uuid.NameSpaceOID, []byte("deployment")).String(),
+			).Return(nil, app.ErrTooManyArtifactGenerations)
+			return appl
+		}(),
+
+		StatusCode: http.StatusServiceUnavailable,
+		Error:      app.ErrTooManyArtifactGenerations,
+		Headers: http.Header{
+			"Retry-After": []string{"30"},
+		},
 	}, {
 		Name: "error, broken artifact reader",
 		Config: NewConfig().
@@ -1157,6 +1786,7 @@ func TestDownloadConfiguration(t *testing.T) {
 		t.Run(tc.Name, func(t *testing.T) {
 			t.Parallel()
 			defer tc.App.AssertExpectations(t)
+			tc.App.On("GetMaintenanceMode", contextMatcher()).Return(false, nil)
 			reqClone := tc.Request.Clone(context.Background())
 			handlers := NewDeploymentsApiHandlers(nil, &view.RESTView{}, tc.App, tc.Config)
 			routes := NewDeploymentsResourceRoutes(handlers)
@@ -1177,18 +1807,18 @@ func TestDownloadConfiguration(t *testing.T) {
 			} else {
 				assert.Equal(t, w.Body.Bytes(), tc.Body)
 				model.NewRequestSignature(reqClone, []byte("test"))
-				rspHdr := w.Header()
-				for key := range tc.Headers {
-					if assert.Contains(t,
-						rspHdr,
-						key,
-						"missing expected header",
-					) {
-						assert.Equal(t,
-							tc.Headers.Get(key),
-							rspHdr.Get(key),
-						)
-					}
+			}
+			rspHdr := w.Header()
+			for key := range tc.Headers {
+				if assert.Contains(t,
+					rspHdr,
+					key,
+					"missing expected header",
+				) {
+					assert.Equal(t,
+						tc.Headers.Get(key),
+						rspHdr.Get(key),
+					)
 				}
 			}
 		})
@@ -1561,6 +2191,41 @@ func TestGetDeploymentForDevice(t *testing.T) {
 
 		StatusCode: http.StatusInternalServerError,
 		Error:      errors.New("internal error"),
+	}, {
+		Name: "error, storage request limit reached",
+
+		Request: func() *http.Request {
+			req, _ := http.NewRequestWithContext(
+				identity.WithContext(context.Background(), &identity.Identity{
+					Subject:  uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String(),
+					IsDevice: true,
+				}),
+				http.MethodGet,
+				"http://localhost"+ApiUrlDevicesDeploymentsNext+
+					"?device_type=bagelShins&artifact_name=bagelOS1.0.1",
+				nil,
+			)
+			return req
+		}(),
+		App: func() *mapp.App {
+			app := new(mapp.App)
+			app.On("GetDeploymentForDeviceWithCurrent",
+				contextMatcher(),
+				uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String(),
+				&model.DeploymentNextRequest{
+					DeviceProvides: &model.InstalledDeviceDeployment{
+						ArtifactName: "bagelOS1.0.1",
+						DeviceType:   "bagelShins",
+					},
+				},
+			).Return(nil, errors.Wrap(storage.ErrTooManyRequests,
+				"Generating download link for the device"))
+			return app
+		}(),
+
+		StatusCode: http.StatusTooManyRequests,
+		Error: errors.New("Generating download link for the device: " +
+			storage.ErrTooManyRequests.Error()),
 	}, {
 		Name: "error, internal app error",
 
@@ -1601,6 +2266,7 @@ func TestGetDeploymentForDevice(t *testing.T) {
 		t.Run(tc.Name, func(t *testing.T) {
 			t.Parallel()
 			defer tc.App.AssertExpectations(t)
+			tc.App.On("GetMaintenanceMode", contextMatcher()).Return(false, nil)
 			config := NewConfig().
 				SetPresignScheme("https").
 				SetPresignSecret([]byte("test")).
@@ -1647,6 +2313,10 @@ func TestGetDeploymentForDevice(t *testing.T) {
 					if assert.NoError(t, err) {
 						assert.WithinDuration(t, time.Now().Add(time.Hour), expire, time.Minute)
 					}
+					assert.Equal(t,
+						model.SignatureAlgorithmHMAC256,
+						q.Get(model.ParamAlgorithm),
+					)
 				}
 				assert.WithinDuration(t, time.Now().Add(time.Hour), instr.Artifact.Source.Expire, time.Minute)
 			}
@@ -1654,6 +2324,172 @@ func TestGetDeploymentForDevice(t *testing.T) {
 	}
 }
 
+func TestCheckDeploymentForDevice(t *testing.T) {
+	t.Parallel()
+
+	deviceID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String()
+	deploymentID := uuid.NewSHA1(uuid.NameSpaceURL, []byte("deployment")).String()
+
+	testCases := map[string]struct {
+		identity *identity.Identity
+
+		callApp      bool
+		deploymentID string
+		appErr       error
+
+		statusCode int
+		body       string
+	}{
+		"ok, pending": {
+			identity:     &identity.Identity{Subject: deviceID, IsDevice: true},
+			callApp:      true,
+			deploymentID: deploymentID,
+			statusCode:   http.StatusOK,
+			body:         `{"id":"` + deploymentID + `"}`,
+		},
+		"ok, nothing pending": {
+			identity:   &identity.Identity{Subject: deviceID, IsDevice: true},
+			callApp:    true,
+			statusCode: http.StatusNoContent,
+		},
+		"error, missing identity": {
+			statusCode: http.StatusBadRequest,
+		},
+		"error, internal": {
+			identity:   &identity.Identity{Subject: deviceID, IsDevice: true},
+			callApp:    true,
+			appErr:     errors.New("internal error"),
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			appMock := new(mapp.App)
+			defer appMock.AssertExpectations(t)
+			appMock.On("GetMaintenanceMode", contextMatcher()).Return(false, nil)
+			if tc.callApp {
+				appMock.On("CheckDeploymentForDevice", contextMatcher(), deviceID).
+					Return(tc.deploymentID, tc.appErr)
+			}
+
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = identity.WithContext(ctx, tc.identity)
+			}
+			req, _ := http.NewRequestWithContext(ctx,
+				http.MethodGet,
+				"http://localhost"+ApiUrlDevicesDeploymentsCheck,
+				nil,
+			)
+
+			handlers := NewDeploymentsApiHandlers(nil, &view.RESTView{}, appMock)
+			router, _ := rest.MakeRouter(NewDeploymentsResourceRoutes(handlers)...)
+			api := rest.NewApi()
+			api.SetApp(router)
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			switch tc.statusCode {
+			case http.StatusOK:
+				assert.JSONEq(t, tc.body, w.Body.String())
+			case http.StatusNoContent:
+				assert.Empty(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestPutDeploymentStatusForDevice(t *testing.T) {
+	t.Parallel()
+
+	deviceID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("device")).String()
+	deploymentID := uuid.NewSHA1(uuid.NameSpaceURL, []byte("deployment")).String()
+
+	testCases := map[string]struct {
+		deletedResponse string
+		appErr          error
+
+		statusCode int
+	}{
+		"ok": {
+			statusCode: http.StatusNoContent,
+		},
+		"ok, not found": {
+			appErr:     app.ErrStorageNotFound,
+			statusCode: http.StatusNotFound,
+		},
+		"deleted deployment, default": {
+			appErr:     app.ErrDeploymentDeleted,
+			statusCode: http.StatusNotFound,
+		},
+		"deleted deployment, ignore": {
+			deletedResponse: dconfig.DeletedDeploymentStatusResponseIgnore,
+			appErr:          app.ErrDeploymentDeleted,
+			statusCode:      http.StatusNoContent,
+		},
+		"deleted deployment, gone": {
+			deletedResponse: dconfig.DeletedDeploymentStatusResponseGone,
+			appErr:          app.ErrDeploymentDeleted,
+			statusCode:      http.StatusGone,
+		},
+		"aborted": {
+			appErr:     app.ErrDeploymentAborted,
+			statusCode: http.StatusConflict,
+		},
+		"internal error": {
+			appErr:     errors.New("internal error"),
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			app.On("UpdateDeviceDeploymentStatus",
+				contextMatcher(),
+				deploymentID,
+				deviceID,
+				model.DeviceDeploymentState{
+					Status: model.DeviceDeploymentStatusInstalling,
+				},
+			).Return(tc.appErr)
+
+			conf := NewConfig().SetDeletedDeploymentStatusResponse(tc.deletedResponse)
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app, conf)
+			api := setUpRestTest(
+				ApiUrlDevicesDeploymentStatus,
+				rest.Put,
+				d.PutDeploymentStatusForDevice,
+			)
+
+			b, _ := json.Marshal(model.StatusReport{
+				Status: model.DeviceDeploymentStatusInstalling,
+			})
+			req, _ := http.NewRequestWithContext(
+				identity.WithContext(context.Background(), &identity.Identity{
+					Subject:  deviceID,
+					IsDevice: true,
+				}),
+				http.MethodPut,
+				"http://localhost"+strings.Replace(
+					ApiUrlDevicesDeploymentStatus, "#id", deploymentID, 1,
+				),
+				bytes.NewReader(b),
+			)
+			req.Header.Set("Content-Type", "application/json")
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.statusCode)
+		})
+	}
+}
+
 func TestGetTenantStorageSettings(t *testing.T) {
 	testCases := map[string]struct {
 		tenantID   string
@@ -1861,17 +2697,61 @@ func TestPutTenantStorageSettings(t *testing.T) {
 	}
 }
 
+func TestPutTenantStorageSettingsValidationError(t *testing.T) {
+	app := &mapp.App{}
+	defer app.AssertExpectations(t)
+
+	restView := new(view.RESTView)
+	d := NewDeploymentsApiHandlers(nil, restView, app)
+	api := setUpRestTest(
+		ApiUrlInternalTenantStorageSettings,
+		rest.Put,
+		d.PutTenantStorageSettingsHandler,
+	)
+	body, _ := json.Marshal(&model.StorageSettings{
+		Region: "region",
+		Key:    "secretkey",
+		Uri:    "not a url",
+	})
+	url := strings.Replace(ApiUrlInternalTenantStorageSettings, "#tenant", "tenant1", -1)
+	req, _ := http.NewRequest(
+		http.MethodPut,
+		"http://localhost"+url,
+		bytes.NewBuffer(body),
+	)
+	req.Header.Set("X-MEN-RequestID", "test")
+
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusBadRequest)
+
+	var response model.StorageSettingsValidationError
+	err := json.Unmarshal(recorded.Recorder.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StorageSettingsValidationError{
+		Error: "invalid settings schema: bucket: cannot be blank; " +
+			"secret: cannot be blank; uri: must be a valid URL.",
+		RequestID: "test",
+		Fields: map[string]string{
+			"bucket": "cannot be blank",
+			"secret": "cannot be blank",
+			"uri":    "must be a valid URL",
+		},
+	}, response)
+}
+
 func TestLookupDeployment(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		Name         string
-		appError     error
-		query        *model.Query
-		deployments  []*model.Deployment
-		count        int64
-		sort         string
-		ResponseCode int
+		Name           string
+		appError       error
+		query          *model.Query
+		deployments    []*model.Deployment
+		count          int64
+		sort           string
+		includeDeleted string
+		deviceID       string
+		ResponseCode   int
 	}{
 		{
 			Name: "ok, discending",
@@ -1905,8 +2785,38 @@ func TestLookupDeployment(t *testing.T) {
 			count:        0,
 			ResponseCode: http.StatusOK,
 		},
-	}
-
+		{
+			Name: "ok, include deleted",
+			query: &model.Query{
+				Limit:          rest_utils.PerPageDefault + 1,
+				Sort:           model.SortDirectionDescending,
+				IncludeDeleted: true,
+			},
+			deployments:    []*model.Deployment{},
+			count:          0,
+			includeDeleted: "true",
+			ResponseCode:   http.StatusOK,
+		},
+		{
+			Name: "ok, device ID",
+			query: &model.Query{
+				Limit:    rest_utils.PerPageDefault + 1,
+				Sort:     model.SortDirectionDescending,
+				DeviceID: "device-1",
+			},
+			deployments:  []*model.Deployment{},
+			count:        0,
+			deviceID:     "device-1",
+			ResponseCode: http.StatusOK,
+		},
+		{
+			Name:           "error, include deleted",
+			query:          &model.Query{},
+			includeDeleted: "maybe",
+			ResponseCode:   http.StatusBadRequest,
+		},
+	}
+
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			app := &mapp.App{}
@@ -1923,10 +2833,17 @@ func TestLookupDeployment(t *testing.T) {
 				rest.Get,
 				d.LookupDeployment,
 			)
-			url := "http://localhost" + ApiUrlManagementDeployments
+			q := url.Values{}
 			if tc.sort != "" {
-				url = "http://localhost" + ApiUrlManagementDeployments + "?sort=" + tc.sort
+				q.Set("sort", tc.sort)
+			}
+			if tc.includeDeleted != "" {
+				q.Set("include_deleted", tc.includeDeleted)
 			}
+			if tc.deviceID != "" {
+				q.Set("device_id", tc.deviceID)
+			}
+			url := "http://localhost" + ApiUrlManagementDeployments + "?" + q.Encode()
 			req := test.MakeSimpleRequest(
 				"GET",
 				url,
@@ -1938,6 +2855,300 @@ func TestLookupDeployment(t *testing.T) {
 	}
 }
 
+func TestListArtifactDeployments(t *testing.T) {
+	t.Parallel()
+
+	const artifactID = "f826484e-1157-4109-af21-304e6d711560"
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deviceCount := 2
+	deployment := &model.Deployment{
+		Id: "f826484e-1157-4109-af21-304e6d711561",
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+		},
+		Created:     &created,
+		Status:      model.DeploymentStatusFinished,
+		DeviceCount: &deviceCount,
+		DeviceList:  []string{"device1", "device2"},
+	}
+
+	testCases := []struct {
+		Name string
+
+		ArtifactID string
+		Query      string
+
+		Image          *model.Image
+		ImageError     error
+		ArtifactName   string
+		AppQuery       model.Query
+		Deployments    []*model.Deployment
+		Count          int64
+		AppError       error
+		ResponseCode   int
+		ResponseBody   interface{}
+		ResponseHeader http.Header
+	}{{
+		Name: "ok",
+
+		ArtifactID: artifactID,
+		Query:      "sort=asc&per_page=1",
+		Image: &model.Image{
+			Id:           artifactID,
+			ArtifactMeta: &model.ArtifactMeta{Name: "bar"},
+		},
+		ArtifactName: "bar",
+		AppQuery: model.Query{
+			Limit: 2,
+			Sort:  model.SortDirectionAscending,
+		},
+		Deployments:  []*model.Deployment{deployment, deployment},
+		Count:        3,
+		ResponseCode: http.StatusOK,
+		ResponseBody: []model.DeploymentSummary{{
+			Id:           "f826484e-1157-4109-af21-304e6d711561",
+			Name:         "foo",
+			ArtifactName: "bar",
+			Type:         model.DeploymentTypeSoftware,
+			Status:       model.DeploymentStatusFinished,
+			Created:      &created,
+			DeviceCount:  &deviceCount,
+		}},
+		ResponseHeader: http.Header{hdrTotalCount: []string{"3"}},
+	}, {
+		Name: "ok, artifact deleted",
+
+		ArtifactID: artifactID,
+		AppQuery: model.Query{
+			Limit: rest_utils.PerPageDefault + 1,
+			Sort:  model.SortDirectionDescending,
+		},
+		Deployments:    []*model.Deployment{},
+		ResponseCode:   http.StatusOK,
+		ResponseBody:   []model.DeploymentSummary{},
+		ResponseHeader: http.Header{hdrTotalCount: []string{"0"}},
+	}, {
+		Name: "error, invalid artifact ID",
+
+		ArtifactID:   "not-an-uuid",
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   ErrIDNotUUID.Error(),
+			ReqId: "test",
+		},
+	}, {
+		Name: "error, invalid sort",
+
+		ArtifactID:   artifactID,
+		Query:        "sort=sideways",
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   ErrInvalidSortDirection.Error(),
+			ReqId: "test",
+		},
+	}, {
+		Name: "error, getting the artifact",
+
+		ArtifactID:   artifactID,
+		ImageError:   errors.New("internal error"),
+		ResponseCode: http.StatusInternalServerError,
+		ResponseBody: rest_utils.ApiError{
+			Err:   "internal error",
+			ReqId: "test",
+		},
+	}, {
+		Name: "error, searching deployments",
+
+		ArtifactID: artifactID,
+		AppQuery: model.Query{
+			Limit: rest_utils.PerPageDefault + 1,
+			Sort:  model.SortDirectionDescending,
+		},
+		AppError:     errors.New("internal error"),
+		ResponseCode: http.StatusInternalServerError,
+		ResponseBody: rest_utils.ApiError{
+			Err:   "internal error",
+			ReqId: "test",
+		},
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.Image != nil || tc.ImageError != nil || tc.AppQuery.Limit > 0 {
+				app.On("GetImage", contextMatcher(), tc.ArtifactID).
+					Return(tc.Image, tc.ImageError)
+			}
+			if tc.AppQuery.Limit > 0 {
+				app.On("FindDeploymentsByArtifact",
+					contextMatcher(),
+					tc.ArtifactName,
+					tc.ArtifactID,
+					tc.AppQuery,
+				).Return(tc.Deployments, tc.Count, tc.AppError)
+			}
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementArtifactsIdDeployments,
+				rest.Get,
+				d.ListArtifactDeployments,
+			)
+			req := test.MakeSimpleRequest(
+				"GET",
+				"http://localhost"+strings.Replace(
+					ApiUrlManagementArtifactsIdDeployments, "#id", tc.ArtifactID, 1,
+				)+"?"+tc.Query,
+				nil,
+			)
+			req.Header.Set("X-MEN-RequestID", "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.ResponseCode)
+			for key := range tc.ResponseHeader {
+				recorded.HeaderIs(key, tc.ResponseHeader.Get(key))
+			}
+			b, _ := json.Marshal(tc.ResponseBody)
+			assert.JSONEq(t, string(b), recorded.Recorder.Body.String())
+		})
+	}
+}
+
+func TestListGroupDeployments(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deviceCount := 2
+	deployment := &model.Deployment{
+		Id: "f826484e-1157-4109-af21-304e6d711561",
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+		},
+		Created:     &created,
+		Status:      model.DeploymentStatusInProgress,
+		DeviceCount: &deviceCount,
+		Groups:      []string{"prod"},
+	}
+
+	testCases := []struct {
+		Name string
+
+		Group string
+		Query string
+
+		AppQuery       model.Query
+		Deployments    []*model.Deployment
+		Count          int64
+		AppError       error
+		ResponseCode   int
+		ResponseBody   interface{}
+		ResponseHeader http.Header
+	}{{
+		Name: "ok",
+
+		Group: "prod",
+		Query: "status=inprogress&per_page=1",
+		AppQuery: model.Query{
+			Limit:  2,
+			Sort:   model.SortDirectionDescending,
+			Status: model.StatusQueryInProgress,
+		},
+		Deployments:  []*model.Deployment{deployment, deployment},
+		Count:        3,
+		ResponseCode: http.StatusOK,
+		ResponseBody: []model.DeploymentSummary{{
+			Id:           "f826484e-1157-4109-af21-304e6d711561",
+			Name:         "foo",
+			ArtifactName: "bar",
+			Type:         model.DeploymentTypeSoftware,
+			Status:       model.DeploymentStatusInProgress,
+			Created:      &created,
+			DeviceCount:  &deviceCount,
+		}},
+		ResponseHeader: http.Header{
+			hdrTotalCount: []string{"3"},
+			"Link": []string{
+				`</api/management/v1/deployments/deployments/group/prod` +
+					`?page=2&per_page=1&status=inprogress>; rel="next"`,
+			},
+		},
+	}, {
+		Name: "ok, unknown group",
+
+		Group: "staging",
+		AppQuery: model.Query{
+			Limit: rest_utils.PerPageDefault + 1,
+			Sort:  model.SortDirectionDescending,
+		},
+		Deployments:    []*model.Deployment{},
+		ResponseCode:   http.StatusOK,
+		ResponseBody:   []model.DeploymentSummary{},
+		ResponseHeader: http.Header{hdrTotalCount: []string{"0"}},
+	}, {
+		Name: "error, invalid sort",
+
+		Group:        "prod",
+		Query:        "sort=sideways",
+		ResponseCode: http.StatusBadRequest,
+		ResponseBody: rest_utils.ApiError{
+			Err:   ErrInvalidSortDirection.Error(),
+			ReqId: "test",
+		},
+	}, {
+		Name: "error, searching deployments",
+
+		Group: "prod",
+		AppQuery: model.Query{
+			Limit: rest_utils.PerPageDefault + 1,
+			Sort:  model.SortDirectionDescending,
+		},
+		AppError:     errors.New("internal error"),
+		ResponseCode: http.StatusInternalServerError,
+		ResponseBody: rest_utils.ApiError{
+			Err:   "internal error",
+			ReqId: "test",
+		},
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.AppQuery.Limit > 0 {
+				app.On("FindDeploymentsByGroup",
+					contextMatcher(),
+					tc.Group,
+					tc.AppQuery,
+				).Return(tc.Deployments, tc.Count, tc.AppError)
+			}
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsGroup,
+				rest.Get,
+				d.ListGroupDeployments,
+			)
+			req := test.MakeSimpleRequest(
+				"GET",
+				"http://localhost"+strings.Replace(
+					ApiUrlManagementDeploymentsGroup, "#name", tc.Group, 1,
+				)+"?"+tc.Query,
+				nil,
+			)
+			req.Header.Set("X-MEN-RequestID", "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.ResponseCode)
+			for key := range tc.ResponseHeader {
+				recorded.HeaderIs(key, tc.ResponseHeader.Get(key))
+			}
+			b, _ := json.Marshal(tc.ResponseBody)
+			assert.JSONEq(t, string(b), recorded.Recorder.Body.String())
+		})
+	}
+}
+
 func TestAbortDeviceDeployments(t *testing.T) {
 	t.Parallel()
 
@@ -1989,6 +3200,148 @@ func TestAbortDeviceDeployments(t *testing.T) {
 	}
 }
 
+func TestCountActiveDeploymentsByDeviceInternal(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		tenantID     string
+		deviceID     string
+		count        int
+		countErr     error
+		responseCode int
+		responseBody interface{}
+	}{
+		"ok": {
+			tenantID:     "tenant",
+			deviceID:     "1",
+			count:        2,
+			responseCode: http.StatusOK,
+			responseBody: map[string]int{"count": 2},
+		},
+		"ok, no tenant": {
+			deviceID:     "1",
+			responseCode: http.StatusOK,
+			responseBody: map[string]int{"count": 0},
+		},
+		"ko": {
+			tenantID:     "tenant",
+			deviceID:     "1",
+			countErr:     errors.New("internal error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			app.On("CountActiveDeploymentsByDevice",
+				mock.MatchedBy(func(ctx context.Context) bool {
+					if tc.tenantID == "" {
+						return true
+					}
+					id := identity.FromContext(ctx)
+					return id != nil && id.Tenant == tc.tenantID
+				}),
+				tc.deviceID,
+			).Return(tc.count, tc.countErr)
+
+			restView := new(view.RESTView)
+			d := NewDeploymentsApiHandlers(nil, restView, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantDeploymentsDeviceActiveCount,
+				rest.Get,
+				d.CountActiveDeploymentsByDeviceInternal,
+			)
+			url := "http://localhost" + ApiUrlInternalTenantDeploymentsDeviceActiveCount
+			url = strings.Replace(url, "#tenant", tc.tenantID, 1)
+			url = strings.Replace(url, "#id", tc.deviceID, 1)
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			if tc.responseBody != nil {
+				body, _ := json.Marshal(tc.responseBody)
+				assert.JSONEq(t, string(body), recorded.Recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestListActiveDeploymentsForDevice(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Round(time.Second)
+	deployments := []*model.Deployment{{
+		Id:      "d1",
+		Created: &now,
+		Status:  model.DeploymentStatusInProgress,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "static",
+			ArtifactName: "artifact",
+		},
+	}, {
+		Id:      "d2",
+		Created: &now,
+		Status:  model.DeploymentStatusPending,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "dynamic",
+			ArtifactName: "artifact",
+		},
+	}}
+
+	testCases := map[string]struct {
+		deployments  []*model.Deployment
+		appErr       error
+		responseCode int
+		responseBody interface{}
+	}{
+		"ok": {
+			deployments:  deployments,
+			responseCode: http.StatusOK,
+			responseBody: deployments,
+		},
+		"ok, no deployments": {
+			deployments:  []*model.Deployment{},
+			responseCode: http.StatusOK,
+			responseBody: []*model.Deployment{},
+		},
+		"error": {
+			appErr:       errors.New("internal error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			app.On("GetActiveDeploymentsForDevice", contextMatcher(), "device-1").
+				Return(tc.deployments, tc.appErr)
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsDeviceActive,
+				rest.Get,
+				d.ListActiveDeploymentsForDevice,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsDeviceActive, "#id", "device-1", 1)
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest(http.MethodGet, url, nil))
+			recorded.CodeIs(tc.responseCode)
+			if tc.responseBody != nil {
+				body, _ := json.Marshal(tc.responseBody)
+				assert.JSONEq(t, string(body), recorded.Recorder.Body.String())
+			}
+		})
+	}
+}
+
 func TestDeleteDeviceDeploymentsHistory(t *testing.T) {
 	t.Parallel()
 
@@ -2194,80 +3547,1587 @@ func TestListDeviceDeployments(t *testing.T) {
 					Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
 				},
 			},
-			count: 1,
+			count: 1,
+		},
+		"ko, too high per_page": {
+			deviceID:     deviceID,
+			limit:        MaximumPerPageListDeviceDeployments + 1,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, empty device ID": {
+			deviceID:     "",
+			responseCode: http.StatusNotFound,
+			err:          errors.New("error"),
+		},
+		"ko, wrong limit": {
+			deviceID:     deviceID,
+			limit:        -10,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, wrong status": {
+			deviceID:     deviceID,
+			status:       "dummy",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			deviceID: deviceID,
+			query: &store.ListQueryDeviceDeployments{
+				DeviceID: deviceID,
+				Limit:    DefaultPerPage,
+			},
+			responseCode: http.StatusInternalServerError,
+			count:        -1,
+			err:          errors.New("error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			app := &mapp.App{}
+			if tc.query != nil {
+				app.On("GetDeviceDeploymentListForDevice",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						return true
+					}),
+					*tc.query,
+				).Return(
+					tc.deployments,
+					tc.count,
+					tc.err,
+				)
+			}
+
+			restView := new(view.RESTView)
+			d := NewDeploymentsApiHandlers(nil, restView, app)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsDeviceId,
+				rest.Get,
+				d.ListDeviceDeployments,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsDeviceId
+			url = strings.Replace(url, "#id", tc.deviceID, 1)
+			if tc.status != "" {
+				url = url + "?status=" + tc.status
+			}
+			if tc.limit != 0 {
+				url = url + fmt.Sprintf("?per_page=%d", tc.limit)
+			}
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			res := []model.DeviceDeploymentListItem{}
+			recorded.DecodeJsonPayload(&res)
+			if tc.responseCode == http.StatusOK {
+				assert.Equal(t, tc.deployments, res, "Unexpected response body")
+			}
+		})
+	}
+}
+
+func TestListDeviceDeploymentsExpanded(t *testing.T) {
+	const deviceID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	views := []model.DeviceDeploymentView{{
+		ID:               "a3c0f8a5-4f2a-4f0e-8b8a-8d5c4f7e2b10",
+		DeviceID:         deviceID,
+		DeploymentID:     "e2d7bd5a-6a77-4b11-9f3c-1e6a2f2f6d4c",
+		DeploymentName:   "production",
+		DeploymentStatus: model.DeploymentStatusInProgress,
+		ArtifactID:       "24436884-a710-4d20-aec4-82c89fbfe29e",
+		ArtifactName:     "release-1",
+		Status:           model.DeviceDeploymentStatusDownloading,
+		Created:          &created,
+	}}
+	query := store.ListQueryDeviceDeployments{
+		DeviceID: deviceID,
+		Limit:    DefaultPerPage,
+	}
+	testCases := map[string]struct {
+		expand string
+
+		callViews bool
+		callList  bool
+		views     []model.DeviceDeploymentView
+		count     int
+		err       error
+
+		responseCode int
+	}{
+		"ok, expanded": {
+			expand:       "true",
+			callViews:    true,
+			views:        views,
+			count:        3,
+			responseCode: http.StatusOK,
+		},
+		"ok, not expanded": {
+			expand:       "false",
+			callList:     true,
+			responseCode: http.StatusOK,
+		},
+		"ko, invalid expand": {
+			expand:       "maybe",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			expand:       "1",
+			callViews:    true,
+			count:        -1,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.callViews {
+				app.On("GetDeviceDeploymentViewsForDevice", contextMatcher(), query).
+					Return(tc.views, tc.count, tc.err)
+			}
+			if tc.callList {
+				app.On("GetDeviceDeploymentListForDevice", contextMatcher(), query).
+					Return([]model.DeviceDeploymentListItem{}, 0, nil)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsDeviceId,
+				rest.Get,
+				d.ListDeviceDeployments,
+			)
+			url := "http://localhost" +
+				strings.Replace(ApiUrlManagementDeploymentsDeviceId, "#id", deviceID, 1) +
+				"?expand=" + tc.expand
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.callViews && tc.responseCode == http.StatusOK {
+				res := []model.DeviceDeploymentView{}
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.views, res)
+				recorded.HeaderIs(hdrTotalCount, fmt.Sprint(tc.count))
+			}
+		})
+	}
+}
+
+func TestGetDeploymentTargetDevices(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+	devices := []string{
+		"1c8e4f3c-a2a4-4d23-a1f6-9c5d63bbb0a1",
+		"2d1e1ab8-bd57-4d1b-a2f2-02f2c1f4c8b2",
+	}
+	testCases := map[string]struct {
+		deploymentID string
+		query        string
+
+		callApp bool
+		skip    int
+		limit   int
+		devices []string
+		count   int
+		err     error
+
+		responseCode int
+		totalCount   string
+	}{
+		"ok": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			limit:        DefaultPerPage,
+			devices:      devices,
+			count:        2,
+			responseCode: http.StatusOK,
+			totalCount:   "2",
+		},
+		"ok, second page": {
+			deploymentID: deploymentID,
+			query:        "?page=2&per_page=1",
+			callApp:      true,
+			skip:         1,
+			limit:        1,
+			devices:      devices[1:],
+			count:        2,
+			responseCode: http.StatusOK,
+			totalCount:   "2",
+		},
+		"ok, no devices": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			limit:        DefaultPerPage,
+			devices:      []string{},
+			responseCode: http.StatusOK,
+			totalCount:   "0",
+		},
+		"ko, id not UUID": {
+			deploymentID: "foo",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, wrong page": {
+			deploymentID: deploymentID,
+			query:        "?page=-1",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, not found": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			limit:        DefaultPerPage,
+			count:        -1,
+			err:          app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, error": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			limit:        DefaultPerPage,
+			count:        -1,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetDeploymentTargetDevices",
+					contextMatcher(),
+					tc.deploymentID,
+					tc.skip,
+					tc.limit,
+				).Return(tc.devices, tc.count, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsTargetDevices,
+				rest.Get,
+				d.GetDeploymentTargetDevices,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsTargetDevices, "#id", tc.deploymentID, 1,
+			) + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []string
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.devices, res)
+				assert.Equal(t, tc.totalCount,
+					recorded.Recorder.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
+
+func TestGetFleetSoftwareInventory(t *testing.T) {
+	t.Parallel()
+	finished := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	inventory := []model.DeviceSoftware{{
+		DeviceID:           "1c8e4f3c-a2a4-4d23-a1f6-9c5d63bbb0a1",
+		ArtifactID:         "8f1ba7d6-3c5e-4b77-9a61-0c5c1b0f3d11",
+		ArtifactName:       "release-2",
+		DeploymentID:       "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		DeviceDeploymentID: "0c5a9f4e-7b1d-4c68-8b9f-2f5e6d7c8a90",
+		Finished:           &finished,
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callApp   bool
+		appQuery  model.SoftwareInventoryQuery
+		inventory []model.DeviceSoftware
+		count     int
+		err       error
+
+		responseCode int
+		totalCount   string
+	}{
+		"ok": {
+			callApp:      true,
+			appQuery:     model.SoftwareInventoryQuery{Limit: DefaultPerPage},
+			inventory:    inventory,
+			count:        1,
+			responseCode: http.StatusOK,
+			totalCount:   "1",
+		},
+		"ok, artifact name and page": {
+			query:   "?artifact_name=release-2&page=3&per_page=5",
+			callApp: true,
+			appQuery: model.SoftwareInventoryQuery{
+				ArtifactName: "release-2",
+				Skip:         10,
+				Limit:        5,
+			},
+			inventory:    inventory,
+			count:        11,
+			responseCode: http.StatusOK,
+			totalCount:   "11",
+		},
+		"ok, empty": {
+			callApp:      true,
+			appQuery:     model.SoftwareInventoryQuery{Limit: DefaultPerPage},
+			responseCode: http.StatusOK,
+			totalCount:   "0",
+		},
+		"ko, wrong page": {
+			query:        "?page=-1",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			callApp:      true,
+			appQuery:     model.SoftwareInventoryQuery{Limit: DefaultPerPage},
+			count:        -1,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetFleetSoftwareInventory",
+					contextMatcher(),
+					tc.appQuery,
+				).Return(tc.inventory, tc.count, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementFleetSoftwareInventory,
+				rest.Get,
+				d.GetFleetSoftwareInventory,
+			)
+			url := "http://localhost" + ApiUrlManagementFleetSoftwareInventory + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []model.DeviceSoftware
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				if tc.inventory == nil {
+					assert.Empty(t, res)
+					assert.NotNil(t, res)
+				} else {
+					assert.Equal(t, tc.inventory, res)
+				}
+				assert.Equal(t, tc.totalCount,
+					recorded.Recorder.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
+
+func TestListArtifactDeletions(t *testing.T) {
+	t.Parallel()
+	deleted := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	deletions := []model.ArtifactDeletion{{
+		ID:           "5a1f6c7e-8d3b-4f2a-9e0c-1b2d3e4f5a6b",
+		ArtifactID:   "8f1ba7d6-3c5e-4b77-9a61-0c5c1b0f3d11",
+		ArtifactName: "release-2",
+		StorageKey:   "tenant/8f1ba7d6-3c5e-4b77-9a61-0c5c1b0f3d11",
+		DeletedBy:    "user-1",
+		Deleted:      deleted,
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callApp   bool
+		appQuery  model.ArtifactDeletionsQuery
+		deletions []model.ArtifactDeletion
+		count     int
+		err       error
+
+		responseCode int
+		totalCount   string
+	}{
+		"ok": {
+			callApp:      true,
+			appQuery:     model.ArtifactDeletionsQuery{Limit: DefaultPerPage},
+			deletions:    deletions,
+			count:        1,
+			responseCode: http.StatusOK,
+			totalCount:   "1",
+		},
+		"ok, artifact name and page": {
+			query:   "?artifact_name=release-2&page=2&per_page=10",
+			callApp: true,
+			appQuery: model.ArtifactDeletionsQuery{
+				ArtifactName: "release-2",
+				Skip:         10,
+				Limit:        10,
+			},
+			deletions:    deletions,
+			count:        11,
+			responseCode: http.StatusOK,
+			totalCount:   "11",
+		},
+		"ok, empty": {
+			callApp:      true,
+			appQuery:     model.ArtifactDeletionsQuery{Limit: DefaultPerPage},
+			responseCode: http.StatusOK,
+			totalCount:   "0",
+		},
+		"ko, wrong page": {
+			query:        "?per_page=0",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			callApp:      true,
+			appQuery:     model.ArtifactDeletionsQuery{Limit: DefaultPerPage},
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("ListArtifactDeletions",
+					contextMatcher(),
+					tc.appQuery,
+				).Return(tc.deletions, tc.count, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementArtifactsDeletions,
+				rest.Get,
+				d.ListArtifactDeletions,
+			)
+			url := "http://localhost" + ApiUrlManagementArtifactsDeletions + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []model.ArtifactDeletion
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				if tc.deletions == nil {
+					assert.Empty(t, res)
+					assert.NotNil(t, res)
+				} else {
+					assert.Equal(t, tc.deletions, res)
+				}
+				assert.Equal(t, tc.totalCount,
+					recorded.Recorder.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
+
+func TestGetDeploymentCreationTrend(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	buckets := []model.TrendBucket{
+		{Time: from, Count: 3},
+		{Time: from.AddDate(0, 0, 2), Count: 1},
+	}
+	testCases := map[string]struct {
+		query string
+
+		callApp     bool
+		to          interface{}
+		granularity model.TrendGranularity
+		buckets     []model.TrendBucket
+		err         error
+
+		responseCode int
+	}{
+		"ok": {
+			query: fmt.Sprintf("?from=%d&to=%d&granularity=day",
+				from.Unix(), to.Unix()),
+			callApp:      true,
+			to:           to,
+			granularity:  model.TrendGranularityDay,
+			buckets:      buckets,
+			responseCode: http.StatusOK,
+		},
+		"ok, defaults": {
+			query:        fmt.Sprintf("?from=%d", from.Unix()),
+			callApp:      true,
+			to:           mock.AnythingOfType("time.Time"),
+			granularity:  model.TrendGranularityDay,
+			buckets:      []model.TrendBucket{},
+			responseCode: http.StatusOK,
+		},
+		"ok, week": {
+			query: fmt.Sprintf("?from=%d&to=%d&granularity=week",
+				from.Unix(), to.Unix()),
+			callApp:      true,
+			to:           to,
+			granularity:  model.TrendGranularityWeek,
+			buckets:      buckets[:1],
+			responseCode: http.StatusOK,
+		},
+		"ko, missing from": {
+			query:        fmt.Sprintf("?to=%d", to.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid from": {
+			query:        "?from=yesterday",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid to": {
+			query:        fmt.Sprintf("?from=%d&to=now", from.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, empty range": {
+			query: fmt.Sprintf("?from=%d&to=%d",
+				to.Unix(), from.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid granularity": {
+			query: fmt.Sprintf("?from=%d&to=%d&granularity=month",
+				from.Unix(), to.Unix()),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			query: fmt.Sprintf("?from=%d&to=%d",
+				from.Unix(), to.Unix()),
+			callApp:      true,
+			to:           to,
+			granularity:  model.TrendGranularityDay,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetDeploymentCreationTrend",
+					contextMatcher(),
+					from,
+					tc.to,
+					tc.granularity,
+				).Return(tc.buckets, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsTrend,
+				rest.Get,
+				d.GetDeploymentCreationTrend,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsTrend + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []model.TrendBucket
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.buckets, res)
+			}
+		})
+	}
+}
+
+func TestGetLargestDeployments(t *testing.T) {
+	t.Parallel()
+
+	deviceCount := func(n int) *int { return &n }
+	deployments := []*model.Deployment{{
+		Id:          "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		DeviceCount: deviceCount(250),
+		Type:        model.DeploymentTypeSoftware,
+	}, {
+		Id:          "d50eda0d-2cea-4de1-8d42-9cd3e7e86702",
+		DeviceCount: deviceCount(40),
+		Type:        model.DeploymentTypeSoftware,
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callApp     bool
+		limit       int
+		deployments []*model.Deployment
+		err         error
+
+		responseCode int
+	}{
+		"ok": {
+			query:        "?limit=2",
+			callApp:      true,
+			limit:        2,
+			deployments:  deployments,
+			responseCode: http.StatusOK,
+		},
+		"ok, default limit": {
+			callApp:      true,
+			limit:        DefaultLargestDeploymentsLimit,
+			deployments:  []*model.Deployment{},
+			responseCode: http.StatusOK,
+		},
+		"ko, invalid limit": {
+			query:        "?limit=ten",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, limit too low": {
+			query:        "?limit=0",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, limit too high": {
+			query:        fmt.Sprintf("?limit=%d", MaxLargestDeploymentsLimit+1),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			callApp:      true,
+			limit:        DefaultLargestDeploymentsLimit,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("GetLargestDeployments", contextMatcher(), tc.limit).
+					Return(tc.deployments, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsLargest,
+				rest.Get,
+				d.GetLargestDeployments,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsLargest + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []*model.Deployment
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.deployments, res)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentsSummary(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		counts map[model.DeploymentStatus]int
+		err    error
+
+		responseCode int
+	}{
+		"ok": {
+			counts: map[model.DeploymentStatus]int{
+				model.DeploymentStatusPaused:     0,
+				model.DeploymentStatusPending:    3,
+				model.DeploymentStatusInProgress: 2,
+				model.DeploymentStatusFinished:   10,
+			},
+			responseCode: http.StatusOK,
+		},
+		"ko, error": {
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			appMock.On("CountDeploymentsByStatus", contextMatcher()).
+				Return(tc.counts, tc.err)
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsSummary,
+				rest.Get,
+				d.GetDeploymentsSummary,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsSummary
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res map[model.DeploymentStatus]int
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.counts, res)
+			}
+		})
+	}
+}
+
+func TestListStaleDeploymentsInternal(t *testing.T) {
+	t.Parallel()
+
+	createdBefore := time.Unix(1700000000, 0).UTC()
+	deployments := []*model.Deployment{{
+		Id:   "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		Type: model.DeploymentTypeSoftware,
+	}}
+	testCases := map[string]struct {
+		tenant string
+		query  string
+
+		callApp     bool
+		limit       int
+		deployments []*model.Deployment
+		err         error
+
+		responseCode int
+	}{
+		"ok": {
+			tenant:       "tenant",
+			query:        "?created_before=1700000000&limit=5",
+			callApp:      true,
+			limit:        5,
+			deployments:  deployments,
+			responseCode: http.StatusOK,
+		},
+		"ok, default tenant and limit": {
+			tenant:       "default",
+			query:        "?created_before=1700000000",
+			callApp:      true,
+			limit:        DefaultStaleDeploymentsLimit,
+			deployments:  []*model.Deployment{},
+			responseCode: http.StatusOK,
+		},
+		"ko, missing created_before": {
+			tenant:       "tenant",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid created_before": {
+			tenant:       "tenant",
+			query:        "?created_before=yesterday",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, limit too high": {
+			tenant: "tenant",
+			query: fmt.Sprintf("?created_before=1700000000&limit=%d",
+				MaxStaleDeploymentsLimit+1),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			tenant:       "tenant",
+			query:        "?created_before=1700000000",
+			callApp:      true,
+			limit:        DefaultStaleDeploymentsLimit,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("FindStaleActiveDeployments",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tc.tenant == "default" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tc.tenant
+					}),
+					createdBefore, tc.limit).
+					Return(tc.deployments, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlInternalTenantDeploymentsStale,
+				rest.Get,
+				d.ListStaleDeploymentsInternal,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlInternalTenantDeploymentsStale, "#tenant", tc.tenant, 1,
+			) + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res []*model.Deployment
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, tc.deployments, res)
+			}
+		})
+	}
+}
+
+func TestListTenantsActiveDeploymentsInternal(t *testing.T) {
+	t.Parallel()
+
+	counts := []model.TenantActiveDeployments{{
+		TenantID:    "tenant1",
+		ActiveCount: 2,
+	}, {
+		TenantID:    "tenant2",
+		ActiveCount: 0,
+	}}
+	testCases := map[string]struct {
+		query string
+
+		callApp  bool
+		appQuery model.TenantActiveDeploymentsQuery
+		page     *model.TenantActiveDeploymentsPage
+		err      error
+
+		responseCode int
+	}{
+		"ok": {
+			query:   "?after=tenant0&limit=2&rate_limit=5&skip_empty=true",
+			callApp: true,
+			appQuery: model.TenantActiveDeploymentsQuery{
+				After:     "tenant0",
+				Limit:     2,
+				RateLimit: 5,
+				SkipEmpty: true,
+			},
+			page: &model.TenantActiveDeploymentsPage{
+				Tenants: counts[:1],
+				Next:    "tenant2",
+			},
+			responseCode: http.StatusOK,
+		},
+		"ok, defaults": {
+			callApp:      true,
+			page:         &model.TenantActiveDeploymentsPage{Tenants: counts},
+			responseCode: http.StatusOK,
+		},
+		"ko, limit too high": {
+			query: fmt.Sprintf("?limit=%d",
+				MaxActiveDeploymentsCountLimit+1),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, rate_limit too high": {
+			query: fmt.Sprintf("?rate_limit=%d",
+				MaxActiveDeploymentsCountRateLimit+1),
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid rate_limit": {
+			query:        "?rate_limit=fast",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, invalid skip_empty": {
+			query:        "?skip_empty=maybe",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, error": {
+			callApp:      true,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("CountActiveDeploymentsPerTenant",
+					contextMatcher(), tc.appQuery).
+					Return(tc.page, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlInternalDeploymentsActiveTenants,
+				rest.Get,
+				d.ListTenantsActiveDeploymentsInternal,
+			)
+			url := "http://localhost" + ApiUrlInternalDeploymentsActiveTenants + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res model.TenantActiveDeploymentsPage
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, *tc.page, res)
+			}
+		})
+	}
+}
+
+func TestDeleteDeployment(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	testCases := map[string]struct {
+		deploymentID string
+
+		callApp bool
+		err     error
+
+		responseCode int
+	}{
+		"ok": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			responseCode: http.StatusNoContent,
+		},
+		"ko, id not UUID": {
+			deploymentID: "foo",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, not found": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, error": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("DeleteDeployment", contextMatcher(), tc.deploymentID).
+					Return(tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsId,
+				rest.Delete,
+				d.DeleteDeployment,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsId, "#id", tc.deploymentID, 1,
+			)
+			req := test.MakeSimpleRequest("DELETE", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+		})
+	}
+}
+
+func TestRestoreDeployment(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	testCases := map[string]struct {
+		deploymentID string
+
+		callApp bool
+		err     error
+
+		responseCode int
+	}{
+		"ok": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			responseCode: http.StatusNoContent,
+		},
+		"ko, id not UUID": {
+			deploymentID: "foo",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, not found": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, error": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("RestoreDeployment", contextMatcher(), tc.deploymentID).
+					Return(tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsRestore,
+				rest.Post,
+				d.RestoreDeployment,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsRestore, "#id", tc.deploymentID, 1,
+			)
+			req := test.MakeSimpleRequest("POST", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+		})
+	}
+}
+
+func TestStartDeployment(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	testCases := map[string]struct {
+		deploymentID string
+
+		callApp bool
+		err     error
+
+		responseCode int
+	}{
+		"ok": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			responseCode: http.StatusNoContent,
+		},
+		"ko, id not UUID": {
+			deploymentID: "foo",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, not found": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, not paused": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          app.ErrDeploymentNotPaused,
+			responseCode: http.StatusConflict,
+		},
+		"ko, error": {
+			deploymentID: deploymentID,
+			callApp:      true,
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				appMock.On("StartDeployment", contextMatcher(), tc.deploymentID).
+					Return(tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsStart,
+				rest.Post,
+				d.StartDeployment,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsStart, "#id", tc.deploymentID, 1,
+			)
+			req := test.MakeSimpleRequest("POST", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+		})
+	}
+}
+
+func TestPatchDeployment(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	name := "NYC Production"
+	testCases := map[string]struct {
+		deploymentID string
+		body         string
+
+		patch *model.DeploymentPatch
+		err   error
+
+		responseCode int
+	}{
+		"ok": {
+			deploymentID: deploymentID,
+			body:         `{"name":"NYC Production"}`,
+			patch:        &model.DeploymentPatch{Name: &name},
+			responseCode: http.StatusNoContent,
+		},
+		"ko, id not UUID": {
+			deploymentID: "foo",
+			body:         `{"name":"NYC Production"}`,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, artifact name": {
+			deploymentID: deploymentID,
+			body:         `{"name":"NYC Production","artifact_name":"App 123"}`,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, devices": {
+			deploymentID: deploymentID,
+			body:         `{"devices":["b532b01a-9313-404f-8d19-e7fcbe5cc347"]}`,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, empty name": {
+			deploymentID: deploymentID,
+			body:         `{"name":""}`,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, not found": {
+			deploymentID: deploymentID,
+			body:         `{"name":"NYC Production"}`,
+			patch:        &model.DeploymentPatch{Name: &name},
+			err:          app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, error": {
+			deploymentID: deploymentID,
+			body:         `{"name":"NYC Production"}`,
+			patch:        &model.DeploymentPatch{Name: &name},
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.patch != nil {
+				appMock.On("UpdateDeployment",
+					contextMatcher(), tc.deploymentID, *tc.patch).
+					Return(tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsId,
+				rest.Patch,
+				d.PatchDeployment,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsId, "#id", tc.deploymentID, 1,
+			)
+			req, _ := http.NewRequest(http.MethodPatch, url,
+				strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+		})
+	}
+}
+
+func TestStreamDevicesListForDeployment(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	deviceDeployments := []model.DeviceDeployment{{
+		Id:           "a1b2c3d4-0000-4000-8000-000000000001",
+		DeviceId:     "device-1",
+		DeploymentId: deploymentID,
+		Status:       model.DeviceDeploymentStatusSuccess,
+	}, {
+		Id:           "a1b2c3d4-0000-4000-8000-000000000002",
+		DeviceId:     "device-2",
+		DeploymentId: deploymentID,
+		Status:       model.DeviceDeploymentStatusDownloading,
+	}}
+	jsonArray := func(deviceDeployments ...model.DeviceDeployment) string {
+		elems := make([]string, len(deviceDeployments))
+		for i := range deviceDeployments {
+			b, _ := json.Marshal(deviceDeployments[i])
+			elems[i] = string(b) + "\n"
+		}
+		return "[" + strings.Join(elems, ",")
+	}
+	status := "finished"
+
+	testCases := map[string]struct {
+		url     string
+		handler rest.HandlerFunc
+		query   string
+
+		listQuery         store.ListQuery
+		deviceDeployments []model.DeviceDeployment
+		streamErr         error
+
+		responseCode int
+		body         string
+	}{
+		"ok, device statuses": {
+			url:               ApiUrlManagementDeploymentsDevices,
+			listQuery:         store.ListQuery{DeploymentID: deploymentID},
+			deviceDeployments: deviceDeployments,
+			responseCode:      http.StatusOK,
+			body:              jsonArray(deviceDeployments...) + "]",
+		},
+		"ok, device statuses, no devices": {
+			url:          ApiUrlManagementDeploymentsDevices,
+			listQuery:    store.ListQuery{DeploymentID: deploymentID},
+			responseCode: http.StatusOK,
+			body:         "[]",
+		},
+		"ok, devices list without pagination": {
+			url:   ApiUrlManagementDeploymentsDevicesList,
+			query: "?status=finished&sort=created:asc",
+			listQuery: store.ListQuery{
+				DeploymentID: deploymentID,
+				Status:       &status,
+				Sort:         store.ListQuerySortCreatedAsc,
+			},
+			deviceDeployments: deviceDeployments[:1],
+			responseCode:      http.StatusOK,
+			body:              jsonArray(deviceDeployments[0]) + "]",
+		},
+		"ko, not found": {
+			url:          ApiUrlManagementDeploymentsDevices,
+			listQuery:    store.ListQuery{DeploymentID: deploymentID},
+			streamErr:    app.ErrModelDeploymentNotFound,
+			responseCode: http.StatusNotFound,
+		},
+		"ko, error": {
+			url:          ApiUrlManagementDeploymentsDevicesList,
+			listQuery:    store.ListQuery{DeploymentID: deploymentID},
+			streamErr:    errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+		"ko, error while streaming": {
+			url:               ApiUrlManagementDeploymentsDevices,
+			listQuery:         store.ListQuery{DeploymentID: deploymentID},
+			deviceDeployments: deviceDeployments,
+			streamErr:         errors.New("error"),
+			// the status is already sent: truncated array
+			responseCode: http.StatusOK,
+			body:         jsonArray(deviceDeployments...),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			appMock.On("StreamDevicesListForDeployment",
+				contextMatcher(),
+				tc.listQuery,
+				mock.AnythingOfType("func(*model.DeviceDeployment) error"),
+			).Run(func(args mock.Arguments) {
+				write := args.Get(2).(func(*model.DeviceDeployment) error)
+				for i := range tc.deviceDeployments {
+					assert.NoError(t, write(&tc.deviceDeployments[i]))
+				}
+			}).Return(tc.streamErr)
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			handler := d.GetDeviceStatusesForDeployment
+			if tc.url == ApiUrlManagementDeploymentsDevicesList {
+				handler = d.GetDevicesListForDeployment
+			}
+			api := setUpRestTest(tc.url, rest.Get, handler)
+			url := "http://localhost" +
+				strings.Replace(tc.url, "#id", deploymentID, 1) +
+				tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.body != "" {
+				assert.Equal(t, tc.body, recorded.Recorder.Body.String())
+				assert.Empty(t, recorded.Recorder.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
+
+func TestGetDevicesListForDeploymentPaginated(t *testing.T) {
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	t.Parallel()
+
+	appMock := &mapp.App{}
+	defer appMock.AssertExpectations(t)
+	appMock.On("GetDevicesListForDeployment",
+		contextMatcher(),
+		store.ListQuery{Skip: 10, Limit: 10, DeploymentID: deploymentID},
+	).Return([]model.DeviceDeployment{{
+		DeviceId:     "device-1",
+		DeploymentId: deploymentID,
+		Status:       model.DeviceDeploymentStatusSuccess,
+	}}, 11, nil)
+
+	d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+	api := setUpRestTest(
+		ApiUrlManagementDeploymentsDevicesList,
+		rest.Get,
+		d.GetDevicesListForDeployment,
+	)
+	url := "http://localhost" + strings.Replace(
+		ApiUrlManagementDeploymentsDevicesList, "#id", deploymentID, 1,
+	) + "?page=2&per_page=10"
+	req := test.MakeSimpleRequest("GET", url, nil)
+
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs(hdrTotalCount, "11")
+	var actual []model.DeviceDeployment
+	assert.NoError(t, recorded.DecodeJsonPayload(&actual))
+	assert.Len(t, actual, 1)
+}
+
+func TestAbortDeploymentsByArtifactName(t *testing.T) {
+	const artifactName = "bad-artifact"
+	t.Parallel()
+
+	testCases := map[string]struct {
+		aborted *model.AbortedDeployments
+		err     error
+
+		responseCode int
+		responseBody string
+	}{
+		"ok": {
+			aborted:      &model.AbortedDeployments{Deployments: 2, Devices: 5},
+			responseCode: http.StatusOK,
+			responseBody: `{"deployments":2,"devices":5}`,
+		},
+		"ko, error": {
+			aborted:      &model.AbortedDeployments{Deployments: 1, Devices: 3},
+			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			appMock.On("AbortDeploymentsByArtifactName", contextMatcher(), artifactName).
+				Return(tc.aborted, tc.err)
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsArtifactAbort,
+				rest.Post,
+				d.AbortDeploymentsByArtifactName,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementDeploymentsArtifactAbort, "#name", artifactName, 1,
+			)
+			req := test.MakeSimpleRequest("POST", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			if tc.responseBody != "" {
+				assert.JSONEq(t, tc.responseBody, recorded.Recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetDeploymentLogForDevice(t *testing.T) {
+	const (
+		deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+		deviceID     = "device"
+	)
+	t.Parallel()
+
+	ts := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	logs := []model.DeploymentLog{{
+		Messages: []model.LogMessage{{Timestamp: &ts, Level: "error", Message: "foo"}},
+	}, {
+		Attempt:  1,
+		Messages: []model.LogMessage{{Timestamp: &ts, Level: "error", Message: "bar"}},
+	}}
+
+	testCases := map[string]struct {
+		query string
+
+		callApp   bool
+		listQuery store.ListQueryDeviceDeploymentLog
+		logs      []model.DeploymentLog
+		total     int
+		err       error
+
+		responseCode int
+		body         string
+		totalCount   string
+	}{
+		"ok, all attempts": {
+			callApp:      true,
+			logs:         logs,
+			total:        2,
+			responseCode: http.StatusOK,
+			totalCount:   "2",
+			body: "=== attempt 1 ===\n" +
+				"2006-01-02 15:04:05 +0000 UTC error: foo\n" +
+				"=== attempt 2 ===\n" +
+				"2006-01-02 15:04:05 +0000 UTC error: bar\n",
+		},
+		"ok, single attempt": {
+			query:   "?attempt=2",
+			callApp: true,
+			listQuery: store.ListQueryDeviceDeploymentLog{
+				Attempt: func() *uint { a := uint(1); return &a }(),
+			},
+			logs:         logs[1:],
+			total:        1,
+			responseCode: http.StatusOK,
+			body:         "2006-01-02 15:04:05 +0000 UTC error: bar\n",
+			totalCount:   "1",
+		},
+		"ok, paged and filtered": {
+			query:   "?offset=1&limit=1&min_level=warning",
+			callApp: true,
+			listQuery: store.ListQueryDeviceDeploymentLog{
+				MinLevel: "warning",
+				Skip:     1,
+				Limit:    1,
+			},
+			logs:         []model.DeploymentLog{{Messages: []model.LogMessage{}}, logs[1]},
+			total:        5,
+			responseCode: http.StatusOK,
+			body: "=== attempt 2 ===\n" +
+				"2006-01-02 15:04:05 +0000 UTC error: bar\n",
+			totalCount: "5",
+		},
+		"ok, offset beyond the log": {
+			query:   "?offset=10",
+			callApp: true,
+			listQuery: store.ListQueryDeviceDeploymentLog{
+				Skip: 10,
+			},
+			logs:         []model.DeploymentLog{{Messages: []model.LogMessage{}}},
+			total:        2,
+			responseCode: http.StatusOK,
+			body:         "",
+			totalCount:   "2",
 		},
-		"ko, too high per_page": {
-			deviceID:     deviceID,
-			limit:        MaximumPerPageListDeviceDeployments + 1,
+		"ko, invalid offset": {
+			query:        "?offset=-1",
 			responseCode: http.StatusBadRequest,
 		},
-		"ko, empty device ID": {
-			deviceID:     "",
-			responseCode: http.StatusNotFound,
-			err:          errors.New("error"),
+		"ko, invalid limit": {
+			query:        "?limit=many",
+			responseCode: http.StatusBadRequest,
 		},
-		"ko, wrong limit": {
-			deviceID:     deviceID,
-			limit:        -10,
+		"ko, invalid level": {
+			query:        "?min_level=loud",
 			responseCode: http.StatusBadRequest,
 		},
-		"ko, wrong status": {
-			deviceID:     deviceID,
-			status:       "dummy",
+		"ko, invalid attempt": {
+			query:        "?attempt=0",
 			responseCode: http.StatusBadRequest,
 		},
+		"ko, not found": {
+			callApp:      true,
+			err:          app.ErrStorageNotFound,
+			responseCode: http.StatusNotFound,
+		},
 		"ko, error": {
-			deviceID: deviceID,
-			query: &store.ListQueryDeviceDeployments{
-				DeviceID: deviceID,
-				Limit:    DefaultPerPage,
-			},
-			responseCode: http.StatusInternalServerError,
-			count:        -1,
+			callApp:      true,
 			err:          errors.New("error"),
+			responseCode: http.StatusInternalServerError,
 		},
 	}
 
 	for name, tc := range testCases {
+		tc := tc
 		t.Run(name, func(t *testing.T) {
-			app := &mapp.App{}
-			if tc.query != nil {
-				app.On("GetDeviceDeploymentListForDevice",
-					mock.MatchedBy(func(ctx context.Context) bool {
-						return true
-					}),
-					*tc.query,
-				).Return(
-					tc.deployments,
-					tc.count,
-					tc.err,
-				)
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				query := tc.listQuery
+				query.DeviceID = deviceID
+				query.DeploymentID = deploymentID
+				appMock.On("GetDeviceDeploymentLog", contextMatcher(), query).
+					Return(tc.logs, tc.total, tc.err)
 			}
 
-			restView := new(view.RESTView)
-			d := NewDeploymentsApiHandlers(nil, restView, app)
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
 			api := setUpRestTest(
-				ApiUrlManagementDeploymentsDeviceId,
+				ApiUrlManagementDeploymentsLog,
 				rest.Get,
-				d.ListDeviceDeployments,
+				d.GetDeploymentLogForDevice,
 			)
-			url := "http://localhost" + ApiUrlManagementDeploymentsDeviceId
-			url = strings.Replace(url, "#id", tc.deviceID, 1)
-			if tc.status != "" {
-				url = url + "?status=" + tc.status
-			}
-			if tc.limit != 0 {
-				url = url + fmt.Sprintf("?per_page=%d", tc.limit)
-			}
+			url := "http://localhost" + strings.NewReplacer(
+				"#id", deploymentID, "#devid", deviceID,
+			).Replace(ApiUrlManagementDeploymentsLog) + tc.query
 			req := test.MakeSimpleRequest("GET", url, nil)
 
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 			recorded.CodeIs(tc.responseCode)
-			recorded.ContentTypeIsJson()
-			res := []model.DeviceDeploymentListItem{}
-			recorded.DecodeJsonPayload(&res)
 			if tc.responseCode == http.StatusOK {
-				assert.Equal(t, tc.deployments, res, "Unexpected response body")
+				recorded.BodyIs(tc.body)
+				recorded.HeaderIs(hdrTotalCount, tc.totalCount)
 			}
 		})
 	}
@@ -2445,6 +5305,7 @@ func TestListDeviceDeploymentsByIDsInternal(t *testing.T) {
 		ID           string
 		status       string
 		limit        int
+		extraQuery   string
 		query        *store.ListQueryDeviceDeployments
 		responseCode int
 		deployments  []model.DeviceDeploymentListItem
@@ -2465,6 +5326,21 @@ func TestListDeviceDeploymentsByIDsInternal(t *testing.T) {
 			},
 			count: 1,
 		},
+		"ok, device filters ignored": {
+			ID:         ID,
+			extraQuery: "&device_id=device&device_id_prefix=device",
+			query: &store.ListQueryDeviceDeployments{
+				IDs:   []string{ID},
+				Limit: DefaultPerPage,
+			},
+			responseCode: http.StatusOK,
+			deployments: []model.DeviceDeploymentListItem{
+				{
+					Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+				},
+			},
+			count: 1,
+		},
 		"ok, no records": {
 			ID: ID,
 			query: &store.ListQueryDeviceDeployments{
@@ -2574,6 +5450,7 @@ func TestListDeviceDeploymentsByIDsInternal(t *testing.T) {
 			if tc.limit != 0 {
 				url = url + fmt.Sprintf("&per_page=%d", tc.limit)
 			}
+			url += tc.extraQuery
 			req := test.MakeSimpleRequest("GET", url, nil)
 
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
@@ -2588,6 +5465,84 @@ func TestListDeviceDeploymentsByIDsInternal(t *testing.T) {
 	}
 }
 
+func TestSearchDeviceDeployments(t *testing.T) {
+	t.Parallel()
+
+	const deviceID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86700"
+	deployments := []model.DeviceDeploymentListItem{{
+		Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+	}}
+	testCases := map[string]struct {
+		query string
+
+		appQuery     *store.ListQueryDeviceDeployments
+		responseCode int
+	}{
+		"ok, device ID": {
+			query: "?device_id=" + deviceID,
+			appQuery: &store.ListQueryDeviceDeployments{
+				DeviceID: deviceID,
+				Limit:    DefaultPerPage,
+			},
+			responseCode: http.StatusOK,
+		},
+		"ok, device ID prefix": {
+			query: "?device_id_prefix=d50eda&status=finished&page=2&per_page=10",
+			appQuery: &store.ListQueryDeviceDeployments{
+				DeviceIDPrefix: "d50eda",
+				Status:         str2ptr("finished"),
+				Skip:           10,
+				Limit:          10,
+			},
+			responseCode: http.StatusOK,
+		},
+		"ko, no filter": {
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, device ID prefix too short": {
+			query:        "?device_id_prefix=d50ed",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, device ID prefix beyond the result cap": {
+			query: fmt.Sprintf("?device_id_prefix=d50eda&page=%d&per_page=20",
+				store.DeviceIDPrefixMaxResults/20+1),
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.appQuery != nil {
+				app.On("GetDeviceDeploymentListForDevice",
+					contextMatcher(),
+					*tc.appQuery,
+				).Return(deployments, len(deployments), nil)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeploymentsDevicesSearch,
+				rest.Get,
+				d.SearchDeviceDeployments,
+			)
+			url := "http://localhost" + ApiUrlManagementDeploymentsDevicesSearch + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			if tc.responseCode == http.StatusOK {
+				var res []model.DeviceDeploymentListItem
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, deployments, res)
+			}
+		})
+	}
+}
+
 func TestNewConfig(t *testing.T) {
 	conf := NewConfig()
 
@@ -2597,3 +5552,429 @@ func TestNewConfig(t *testing.T) {
 	conf.SetDisableNewReleasesFeature(true)
 	assert.True(t, conf.DisableNewReleasesFeature)
 }
+
+func TestDownloadLinkExpire(t *testing.T) {
+	t.Parallel()
+
+	const artifactID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	link := &model.Link{
+		Uri:    "https://example.com/artifact",
+		Expire: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+	testCases := map[string]struct {
+		query string
+
+		callApp bool
+		expire  interface{}
+		err     error
+
+		responseCode int
+	}{
+		"ok": {
+			query:        "?expire_seconds=3600",
+			callApp:      true,
+			expire:       time.Hour,
+			responseCode: http.StatusOK,
+		},
+		"ok, default expiry": {
+			callApp:      true,
+			expire:       mock.AnythingOfType("time.Duration"),
+			responseCode: http.StatusOK,
+		},
+		"ko, invalid expiry": {
+			query:        "?expire_seconds=hour",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, negative expiry": {
+			query:        "?expire_seconds=-60",
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, expiry too long": {
+			query:        "?expire_seconds=31536000",
+			callApp:      true,
+			expire:       365 * 24 * time.Hour,
+			err:          app.ErrDownloadLinkExpireTooLong,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, expiry overflows the duration": {
+			query:        "?expire_seconds=9223372036854775807",
+			err:          app.ErrDownloadLinkExpireTooLong,
+			responseCode: http.StatusBadRequest,
+		},
+		"ko, expiry out of range": {
+			query:        "?expire_seconds=99999999999999999999",
+			err:          app.ErrDownloadLinkExpireTooLong,
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.callApp {
+				var res *model.Link
+				if tc.err == nil {
+					res = link
+				}
+				appMock.On("DownloadLink", contextMatcher(), artifactID, tc.expire).
+					Return(res, tc.err)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(
+				ApiUrlManagementArtifactsIdDownload,
+				rest.Get,
+				d.DownloadLink,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementArtifactsIdDownload, "#id", artifactID, 1,
+			) + tc.query
+			req := test.MakeSimpleRequest("GET", url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			recorded.ContentTypeIsJson()
+			if tc.responseCode == http.StatusOK {
+				var res model.Link
+				if assert.NoError(t, recorded.DecodeJsonPayload(&res)) {
+					assert.Equal(t, link.Uri, res.Uri)
+					assert.True(t, link.Expire.Equal(res.Expire))
+				}
+			} else if tc.err != nil {
+				var res struct {
+					Error string `json:"error"`
+				}
+				if assert.NoError(t, recorded.DecodeJsonPayload(&res)) {
+					assert.Equal(t, tc.err.Error(), res.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestListOrphanedArtifactsInternal(t *testing.T) {
+	t.Parallel()
+
+	artifacts := []*model.Image{{
+		Id: "24436884-a710-4d20-aec4-82c89fbfe29e",
+		ArtifactMeta: &model.ArtifactMeta{
+			Name: "release-1",
+		},
+	}}
+	testCases := map[string]struct {
+		tenant string
+		query  string
+
+		skip      int
+		limit     int
+		artifacts []*model.Image
+		count     int
+		err       error
+
+		code  int
+		total string
+	}{
+		"ok": {
+			tenant:    "acme",
+			query:     "?page=2&per_page=1",
+			skip:      1,
+			limit:     1,
+			artifacts: artifacts,
+			count:     3,
+			code:      http.StatusOK,
+			total:     "3",
+		},
+		"ok, default tenant": {
+			tenant:    "default",
+			limit:     20,
+			artifacts: []*model.Image{},
+			code:      http.StatusOK,
+			total:     "0",
+		},
+		"error, pagination": {
+			tenant: "acme",
+			query:  "?page=zero",
+			code:   http.StatusBadRequest,
+		},
+		"error, app": {
+			tenant: "acme",
+			limit:  20,
+			err:    errors.New("internal error"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.code != http.StatusBadRequest {
+				app.On("ListOrphanedArtifacts",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tc.tenant == "default" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tc.tenant
+					}),
+					tc.skip,
+					tc.limit,
+				).Return(tc.artifacts, tc.count, tc.err)
+			}
+
+			handler, err := NewHandler(context.Background(), app, nil, NewConfig())
+			require.NoError(t, err)
+
+			path := strings.Replace(ApiUrlInternalTenantArtifactsOrphaned,
+				"#tenant", tc.tenant, 1)
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost"+path+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.code, w.Code)
+			if tc.code == http.StatusOK {
+				var res []*model.Image
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tc.artifacts, res)
+				assert.Equal(t, tc.total, w.Header().Get(hdrTotalCount))
+			}
+		})
+	}
+}
+
+func TestListArtifactsModifiedInternal(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 500000000, time.UTC)
+	modified := since.Add(time.Hour)
+	artifacts := []*model.Image{{
+		Id:           "24436884-a710-4d20-aec4-82c89fbfe29e",
+		ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+		Modified:     &since,
+	}, {
+		Id:           "24436884-a710-4d20-aec4-82c89fbfe29f",
+		ArtifactMeta: &model.ArtifactMeta{Name: "release-2"},
+		Modified:     &modified,
+	}}
+	testCases := map[string]struct {
+		tenant string
+		query  string
+
+		since     time.Time
+		afterID   string
+		skip      int
+		limit     int
+		artifacts []*model.Image
+		err       error
+
+		code     int
+		cursor   time.Time
+		cursorID string
+	}{
+		"ok": {
+			tenant:    "acme",
+			query:     "?since=2024-05-01T12:00:00.5Z&page=2&per_page=2",
+			since:     since,
+			skip:      2,
+			limit:     2,
+			artifacts: artifacts,
+			code:      http.StatusOK,
+			cursor:    modified,
+			cursorID:  artifacts[1].Id,
+		},
+		"ok, after an artifact": {
+			tenant:    "acme",
+			query:     "?since=2024-05-01T12:00:00.5Z&after_id=" + artifacts[0].Id,
+			since:     since,
+			afterID:   artifacts[0].Id,
+			limit:     20,
+			artifacts: artifacts[1:],
+			code:      http.StatusOK,
+			cursor:    modified,
+			cursorID:  artifacts[1].Id,
+		},
+		"ok, no changes": {
+			tenant:    "default",
+			query:     "?since=2024-05-01T12:00:00.5Z&after_id=" + artifacts[0].Id,
+			since:     since,
+			afterID:   artifacts[0].Id,
+			limit:     20,
+			artifacts: []*model.Image{},
+			code:      http.StatusOK,
+			cursor:    since,
+			cursorID:  artifacts[0].Id,
+		},
+		"ok, full sync": {
+			tenant:    "acme",
+			limit:     20,
+			artifacts: artifacts,
+			code:      http.StatusOK,
+			cursor:    modified,
+			cursorID:  artifacts[1].Id,
+		},
+		"error, after_id without since": {
+			tenant: "acme",
+			query:  "?after_id=" + artifacts[0].Id,
+			code:   http.StatusBadRequest,
+		},
+		"error, since": {
+			tenant: "acme",
+			query:  "?since=yesterday",
+			code:   http.StatusBadRequest,
+		},
+		"error, pagination": {
+			tenant: "acme",
+			query:  "?page=zero",
+			code:   http.StatusBadRequest,
+		},
+		"error, app": {
+			tenant: "acme",
+			limit:  20,
+			err:    errors.New("internal error"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			if tc.code != http.StatusBadRequest {
+				app.On("ListArtifactsModifiedSince",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tc.tenant == "default" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tc.tenant
+					}),
+					mock.MatchedBy(tc.since.Equal),
+					tc.afterID,
+					tc.skip,
+					tc.limit,
+				).Return(tc.artifacts, tc.err)
+			}
+
+			handler, err := NewHandler(context.Background(), app, nil, NewConfig())
+			require.NoError(t, err)
+
+			path := strings.Replace(ApiUrlInternalTenantArtifactsModified,
+				"#tenant", tc.tenant, 1)
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost"+path+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.code, w.Code)
+			if tc.code == http.StatusOK {
+				var res ArtifactsSync
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Len(t, res.Artifacts, len(tc.artifacts))
+				assert.True(t, tc.cursor.Equal(res.Cursor),
+					"cursor %s, expected %s", res.Cursor, tc.cursor)
+				assert.Equal(t, tc.cursorID, res.CursorID)
+			}
+		})
+	}
+}
+
+func TestReconcileDeviceCountInternal(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	previous := 3
+	testCases := map[string]struct {
+		tenant string
+		id     string
+
+		res *model.DeviceCountReconciliation
+		err error
+
+		code int
+	}{
+		"ok": {
+			tenant: "acme",
+			id:     deploymentID,
+			res: &model.DeviceCountReconciliation{
+				DeploymentID:        deploymentID,
+				PreviousDeviceCount: &previous,
+				DeviceCount:         2,
+				Corrected:           true,
+			},
+			code: http.StatusOK,
+		},
+		"ok, default tenant": {
+			tenant: "default",
+			id:     deploymentID,
+			res: &model.DeviceCountReconciliation{
+				DeploymentID:        deploymentID,
+				PreviousDeviceCount: &previous,
+				DeviceCount:         3,
+			},
+			code: http.StatusOK,
+		},
+		"error, invalid ID": {
+			tenant: "acme",
+			id:     "not-a-uuid",
+			code:   http.StatusBadRequest,
+		},
+		"error, not found": {
+			tenant: "acme",
+			id:     deploymentID,
+			err:    app.ErrModelDeploymentNotFound,
+			code:   http.StatusNotFound,
+		},
+		"error, count changed": {
+			tenant: "acme",
+			id:     deploymentID,
+			err:    app.ErrDeviceCountChanged,
+			code:   http.StatusConflict,
+		},
+		"error, app": {
+			tenant: "acme",
+			id:     deploymentID,
+			err:    errors.New("internal error"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			appMock := &mapp.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.code != http.StatusBadRequest {
+				appMock.On("ReconcileDeviceCount",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tc.tenant == "default" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tc.tenant
+					}),
+					tc.id,
+				).Return(tc.res, tc.err)
+			}
+
+			handler, err := NewHandler(context.Background(), appMock, nil, NewConfig())
+			require.NoError(t, err)
+
+			path := strings.NewReplacer("#tenant", tc.tenant, "#id", tc.id).
+				Replace(ApiUrlInternalTenantDeploymentDeviceCount)
+			req, _ := http.NewRequest(http.MethodPost, "http://localhost"+path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.code, w.Code)
+			if tc.code == http.StatusOK {
+				var res model.DeviceCountReconciliation
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, *tc.res, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+
+	"github.com/mendersoftware/deployments/app"
+)
+
+// ExportDeploymentsInternal starts exporting the tenant's deployments and
+// device deployments to Parquet files in the object storage.
+func (d *DeploymentsApiHandlers) ExportDeploymentsInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	job, err := d.app.ExportDeployments(tenantContext(r))
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	location := strings.Replace(
+		ApiUrlInternalTenantExportID, "#tenant", r.PathParam("tenant"), 1,
+	)
+	w.Header().Set("Location", strings.Replace(location, "#id", job.ID, 1))
+	w.WriteHeader(http.StatusAccepted)
+	_ = w.WriteJson(job)
+}
+
+// GetExportJobInternal returns the progress of an export.
+func (d *DeploymentsApiHandlers) GetExportJobInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	job, err := d.app.GetExportJob(tenantContext(r), r.PathParam("id"))
+	switch err {
+	case nil:
+		d.view.RenderSuccessGet(w, job)
+	case app.ErrExportJobNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// RunExportJobInternal performs the export; it is called by the export
+// workflow and returns when all the files are uploaded.
+func (d *DeploymentsApiHandlers) RunExportJobInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	err := d.app.RunExportJob(tenantContext(r), r.PathParam("id"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case app.ErrExportJobNotFound:
+		d.view.RenderError(w, r, err, http.StatusNotFound, l)
+	case app.ErrExportJobStarted:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/app"
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func TestExportDeploymentsInternal(t *testing.T) {
+	t.Parallel()
+
+	job := model.NewExportJob()
+
+	testCases := map[string]struct {
+		appErr error
+
+		code int
+	}{
+		"ok": {
+			code: http.StatusAccepted,
+		},
+		"error, internal": {
+			appErr: errors.New("workflows: connection refused"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			var ret *model.ExportJob
+			if tc.appErr == nil {
+				ret = job
+			}
+			app.On("ExportDeployments", tenantMatcher("tenant")).
+				Return(ret, tc.appErr)
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantExports,
+				rest.Post,
+				d.ExportDeploymentsInternal,
+			)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlInternalTenantExports, "#tenant", "tenant", 1)
+			req := test.MakeSimpleRequest(http.MethodPost, url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusAccepted {
+				location := ApiUrlInternal + "/tenants/tenant/exports/" + job.ID
+				assert.Equal(t, location, recorded.Recorder.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestGetExportJobInternal(t *testing.T) {
+	t.Parallel()
+
+	job := model.NewExportJob()
+
+	testCases := map[string]struct {
+		appErr error
+
+		code int
+	}{
+		"ok": {
+			code: http.StatusOK,
+		},
+		"error, not found": {
+			appErr: app.ErrExportJobNotFound,
+			code:   http.StatusNotFound,
+		},
+		"error, internal": {
+			appErr: errors.New("mongo: connection refused"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			var ret *model.ExportJob
+			if tc.appErr == nil {
+				ret = job
+			}
+			app.On("GetExportJob", tenantMatcher("tenant"), job.ID).
+				Return(ret, tc.appErr)
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantExportID,
+				rest.Get,
+				d.GetExportJobInternal,
+			)
+			url := strings.Replace(ApiUrlInternalTenantExportID, "#tenant", "tenant", 1)
+			url = "http://localhost" + strings.Replace(url, "#id", job.ID, 1)
+			req := test.MakeSimpleRequest(http.MethodGet, url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			if tc.code == http.StatusOK {
+				var res model.ExportJob
+				assert.NoError(t, recorded.DecodeJsonPayload(&res))
+				assert.Equal(t, job.ID, res.ID)
+				assert.Equal(t, model.ExportStatusPending, res.Status)
+			}
+		})
+	}
+}
+
+func TestRunExportJobInternal(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		appErr error
+
+		code int
+	}{
+		"ok": {
+			code: http.StatusNoContent,
+		},
+		"error, not found": {
+			appErr: app.ErrExportJobNotFound,
+			code:   http.StatusNotFound,
+		},
+		"error, already started": {
+			appErr: app.ErrExportJobStarted,
+			code:   http.StatusConflict,
+		},
+		"error, internal": {
+			appErr: errors.New("failed to export deployments: bucket not found"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			app.On("RunExportJob", tenantMatcher("tenant"), "job").
+				Return(tc.appErr)
+
+			d := NewDeploymentsApiHandlers(nil, &view.RESTView{}, app)
+			api := setUpRestTest(
+				ApiUrlInternalTenantExportRun,
+				rest.Post,
+				d.RunExportJobInternal,
+			)
+			url := strings.Replace(ApiUrlInternalTenantExportRun, "#tenant", "tenant", 1)
+			url = "http://localhost" + strings.Replace(url, "#id", "job", 1)
+			req := test.MakeSimpleRequest(http.MethodPost, url, nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+)
+
+var ErrMaintenanceMode = errors.New(
+	"the service is under maintenance, please retry later",
+)
+
+// MaintenanceMode is the state of the maintenance mode in the internal API.
+type MaintenanceMode struct {
+	Enabled bool `json:"enabled"`
+}
+
+// DeviceMaintenanceMiddleware responds to the devices with 503 Service
+// Unavailable and a Retry-After header while in maintenance mode, so that
+// they back off until it is over.
+func (d *DeploymentsApiHandlers) DeviceMaintenanceMiddleware(
+	h rest.HandlerFunc,
+) rest.HandlerFunc {
+	retryAfter := strconv.Itoa(int(d.config.MaintenanceRetryAfter.Seconds()))
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		l := requestlog.GetRequestLogger(r)
+		enabled, err := d.app.GetMaintenanceMode(r.Context())
+		if err != nil {
+			// the devices keep being served if the mode is unknown
+			l.Errorf("failed to check the maintenance mode: %s", err)
+		}
+		if !enabled {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", retryAfter)
+		d.view.RenderError(w, r, ErrMaintenanceMode, http.StatusServiceUnavailable, l)
+	}
+}
+
+func (d *DeploymentsApiHandlers) GetMaintenanceModeInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	enabled, err := d.app.GetMaintenanceMode(r.Context())
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	d.view.RenderSuccessGet(w, MaintenanceMode{Enabled: enabled})
+}
+
+// PutMaintenanceModeInternal toggles the maintenance mode of all the
+// instances of the service.
+func (d *DeploymentsApiHandlers) PutMaintenanceModeInternal(
+	w rest.ResponseWriter,
+	r *rest.Request,
+) {
+	l := requestlog.GetRequestLogger(r)
+
+	var mode MaintenanceMode
+	if err := r.DecodeJsonPayload(&mode); err != nil {
+		d.view.RenderError(w, r,
+			errors.WithMessage(err, "malformed request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if err := d.app.SetMaintenanceMode(r.Context(), mode.Enabled); err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	l.Infof("maintenance mode enabled: %t", mode.Enabled)
+	d.view.RenderEmptySuccessResponse(w)
+}
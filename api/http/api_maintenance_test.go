@@ -0,0 +1,149 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Parallel()
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+
+	// the mode shared through the database
+	enabled := true
+	app := &mapp.App{}
+	defer app.AssertExpectations(t)
+	app.On("GetDeployment", contextMatcher(), deploymentID).
+		Return(&model.Deployment{Id: deploymentID}, nil)
+	app.On("GetMaintenanceMode", contextMatcher()).
+		Return(func(context.Context) bool { return enabled }, nil)
+	app.On("SetMaintenanceMode", contextMatcher(), false).
+		Run(func(args mock.Arguments) { enabled = false }).
+		Return(nil).
+		Once()
+
+	handler, err := NewHandler(context.Background(), app, nil, NewConfig().
+		SetMaintenanceRetryAfter(time.Minute))
+	require.NoError(t, err)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	replacer := strings.NewReplacer(
+		"#deployment_id", deploymentID,
+		"#device_type", "rpi4",
+		"#device_id", "device",
+		"#id", deploymentID,
+	)
+	deviceEndpoints := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: ApiUrlDevicesDeploymentsNext},
+		{method: http.MethodPost, path: ApiUrlDevicesDeploymentsNext, body: `{}`},
+		{method: http.MethodGet, path: ApiUrlDevicesDeploymentsCheck},
+		{method: http.MethodPut, path: ApiUrlDevicesDeploymentStatus,
+			body: `{"status":"success"}`},
+		{method: http.MethodPut, path: ApiUrlDevicesDeploymentsLog, body: `{}`},
+		{method: http.MethodGet, path: ApiUrlDevicesDownloadConfig},
+		{method: http.MethodGet, path: ApiUrlDevicesDownloadArtifact},
+	}
+
+	// device endpoints are gated, without reaching the app
+	for _, e := range deviceEndpoints {
+		w := serve(e.method, replacer.Replace(e.path), e.body)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "%s %s", e.method, e.path)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"), "%s %s", e.method, e.path)
+	}
+
+	// management and internal endpoints remain available
+	w := serve(http.MethodGet, replacer.Replace(ApiUrlManagementDeploymentsId), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodGet, ApiUrlInternalAlive, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(http.MethodGet, ApiUrlInternalMaintenance, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled":true}`, w.Body.String())
+
+	// invalid toggle
+	w = serve(http.MethodPut, ApiUrlInternalMaintenance, `{"enabled":"no"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// leaving maintenance reopens the device endpoints
+	w = serve(http.MethodPut, ApiUrlInternalMaintenance, `{"enabled":false}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(http.MethodGet, ApiUrlInternalMaintenance, "")
+	assert.JSONEq(t, `{"enabled":false}`, w.Body.String())
+	for _, e := range deviceEndpoints {
+		w := serve(e.method, replacer.Replace(e.path), e.body)
+		assert.NotEqual(t, http.StatusServiceUnavailable, w.Code, "%s %s", e.method, e.path)
+		assert.Empty(t, w.Header().Get("Retry-After"), "%s %s", e.method, e.path)
+	}
+}
+
+func TestMaintenanceModeError(t *testing.T) {
+	t.Parallel()
+
+	app := &mapp.App{}
+	defer app.AssertExpectations(t)
+	app.On("GetMaintenanceMode", contextMatcher()).
+		Return(false, errors.New("connection refused"))
+	app.On("SetMaintenanceMode", contextMatcher(), true).
+		Return(errors.New("connection refused"))
+
+	handler, err := NewHandler(context.Background(), app, nil, NewConfig())
+	require.NoError(t, err)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the devices are served if the mode cannot be read
+	w := serve(http.MethodGet, ApiUrlDevicesDeploymentsNext, "")
+	assert.NotEqual(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w = serve(http.MethodGet, ApiUrlInternalMaintenance, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = serve(http.MethodPut, ApiUrlInternalMaintenance, `{"enabled":true}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+)
+
+// compressedRoutes lists the (GET) management endpoints whose responses
+// are gzip-compressed when enabled: the lists which can grow large.
+var compressedRoutes = map[string]bool{
+	ApiUrlManagementReleases:               true,
+	ApiUrlManagementReleasesList:           true,
+	ApiUrlManagementV2Releases:             true,
+	ApiUrlManagementArtifacts:              true,
+	ApiUrlManagementArtifactsList:          true,
+	ApiUrlManagementDeployments:            true,
+	ApiUrlManagementDeploymentsDevices:     true,
+	ApiUrlManagementDeploymentsDevicesList: true,
+	ApiUrlManagementDeploymentsDeviceId:    true,
+	ApiUrlManagementDeploymentsDeviceList:  true,
+}
+
+// acceptsGzip tells whether the Accept-Encoding header of the request
+// allows gzip content coding.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			if strings.TrimSpace(strings.ToLower(name)) != "gzip" {
+				continue
+			}
+			_, q, found := strings.Cut(strings.ReplaceAll(params, " ", ""), "q=")
+			if !found {
+				return true
+			}
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the start of the response until minSize
+// bytes were written, to decide on compressing it; past them, the response
+// is streamed through gzip.
+type gzipResponseWriter struct {
+	rest.ResponseWriter
+	minSize    int
+	buf        []byte
+	statusCode int
+	// started is set once the header is sent; zw is only set when the
+	// body is compressed.
+	started bool
+	zw      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.statusCode == 0 {
+		w.statusCode = code
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.started {
+		if w.statusCode >= http.StatusMultipleChoices {
+			// the error responses are not compressed
+			if err := w.start(false); err != nil {
+				return 0, err
+			}
+		} else {
+			w.buf = append(w.buf, b...)
+			if len(w.buf) >= w.minSize {
+				if err := w.start(true); err != nil {
+					return 0, err
+				}
+			}
+			return len(b), nil
+		}
+	}
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.(http.ResponseWriter).Write(b)
+}
+
+func (w *gzipResponseWriter) WriteJson(v interface{}) error {
+	b, err := w.EncodeJson(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Flush sends what was written so far: a response flushed before reaching
+// minSize is streamed, and compressed as such.
+func (w *gzipResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if !w.started {
+		if err := w.start(w.statusCode < http.StatusMultipleChoices); err != nil {
+			return
+		}
+	}
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start sends the header, then the body held back so far.
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+	hdr := w.Header()
+	hdr.Add("Vary", "Accept-Encoding")
+	if compress && hdr.Get("Content-Encoding") == "" {
+		hdr.Set("Content-Encoding", "gzip")
+		hdr.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		w.zw = gzip.NewWriter(w.ResponseWriter.(http.ResponseWriter))
+		_, err := w.zw.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, err := w.ResponseWriter.(http.ResponseWriter).Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// finish sends the response, uncompressed, if it remained below minSize,
+// or completes the compressed one.
+func (w *gzipResponseWriter) finish() error {
+	if !w.started {
+		w.WriteHeader(http.StatusOK)
+		if len(w.buf) > 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
+
+// GzipMiddleware compresses the successful responses of at least minSize
+// bytes for the clients accepting gzip content coding.
+type GzipMiddleware struct {
+	MinSize int
+}
+
+func (mw *GzipMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if !acceptsGzip(r.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			h(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: mw.MinSize}
+		h(gw, r)
+		if err := gw.finish(); err != nil {
+			requestlog.GetRequestLogger(r).
+				Errorf("failed to write the response: %s", err.Error())
+		}
+	}
+}
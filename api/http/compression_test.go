@@ -0,0 +1,276 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]bool{
+		"":                      false,
+		"gzip":                  true,
+		"deflate, GZIP":         true,
+		"br;q=1.0, gzip;q=0.5":  true,
+		"gzip;q=0":              false,
+		"gzip; q=0.000":         false,
+		"identity, x-gzip-ish":  false,
+		"deflate;q=0.8, br, *":  false,
+		"gzip;level=9, deflate": true,
+	}
+	for header, expected := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		if header != "" {
+			req.Header.Set("Accept-Encoding", header)
+		}
+		assert.Equal(t, expected, acceptsGzip(req), header)
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	t.Parallel()
+
+	large := []byte(strings.Repeat("release", 200))
+	small := []byte("release")
+
+	testCases := map[string]struct {
+		acceptEncoding string
+		statusCode     int
+		body           []byte
+		// chunkSize, if set, writes the body in chunks, flushing each
+		chunkSize int
+
+		compressed    bool
+		contentLength bool
+	}{
+		"ok, compressed": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusOK,
+			body:           large,
+			compressed:     true,
+		},
+		"ok, compressed, streamed": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusOK,
+			body:           large,
+			chunkSize:      100,
+			compressed:     true,
+		},
+		"ok, below the threshold": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusOK,
+			body:           small,
+			contentLength:  true,
+		},
+		"ok, empty": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusOK,
+		},
+		"ok, not modified": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusNotModified,
+		},
+		"ok, gzip not accepted": {
+			statusCode: http.StatusOK,
+			body:       large,
+		},
+		"ok, error response": {
+			acceptEncoding: "gzip",
+			statusCode:     http.StatusBadRequest,
+			body:           large,
+		},
+	}
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			api := rest.NewApi()
+			router, err := rest.MakeRouter(rest.Get("/list",
+				(&GzipMiddleware{MinSize: 1024}).MiddlewareFunc(
+					func(w rest.ResponseWriter, r *rest.Request) {
+						w.WriteHeader(tc.statusCode)
+						rw := w.(http.ResponseWriter)
+						if tc.chunkSize == 0 {
+							_, _ = rw.Write(tc.body)
+							return
+						}
+						for i := 0; i < len(tc.body); i += tc.chunkSize {
+							end := i + tc.chunkSize
+							if end > len(tc.body) {
+								end = len(tc.body)
+							}
+							_, _ = rw.Write(tc.body[i:end])
+							rw.(http.Flusher).Flush()
+						}
+					},
+				),
+			))
+			require.NoError(t, err)
+			api.SetApp(router)
+
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost/list", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			if tc.contentLength {
+				assert.Equal(t, strconv.Itoa(len(tc.body)), w.Header().Get("Content-Length"))
+			} else {
+				assert.Empty(t, w.Header().Get("Content-Length"))
+			}
+			body := w.Body.Bytes()
+			if tc.compressed {
+				assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = io.ReadAll(zr)
+				require.NoError(t, err)
+			} else {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+			}
+			assert.Equal(t, string(tc.body), string(body))
+		})
+	}
+}
+
+func TestNewHandlerCompressResponses(t *testing.T) {
+	t.Parallel()
+
+	images := make([]*model.Image, 50)
+	for i := range images {
+		images[i] = &model.Image{
+			Id: strconv.Itoa(i),
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "release-" + strconv.Itoa(i),
+				DeviceTypesCompatible: []string{"raspberrypi4"},
+			},
+		}
+	}
+
+	for _, enabled := range []bool{true, false} {
+		app := new(mapp.App)
+		app.On("GetImagesListVersion", mock.Anything, mock.Anything).
+			Return((*model.ListVersion)(nil), errors.New("no version"))
+		app.On("ListImages", mock.Anything, mock.Anything).
+			Return(images, len(images), nil)
+
+		handler, err := NewHandler(context.Background(), app, nil,
+			NewConfig().SetCompressResponses(enabled, 1024))
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest(http.MethodGet,
+			"http://localhost"+ApiUrlManagementArtifactsList, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.Bytes()
+		if enabled {
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			require.NoError(t, err)
+			body, err = io.ReadAll(zr)
+			require.NoError(t, err)
+		} else {
+			assert.Empty(t, w.Header().Get("Content-Encoding"))
+		}
+		var actual []*model.Image
+		if assert.NoError(t, json.Unmarshal(body, &actual)) {
+			assert.Len(t, actual, len(images))
+		}
+		app.AssertExpectations(t)
+	}
+}
+
+func TestNewHandlerCompressStreamedDevices(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	deviceDeployments := make([]model.DeviceDeployment, 50)
+	for i := range deviceDeployments {
+		deviceDeployments[i] = model.DeviceDeployment{
+			Id:           strconv.Itoa(i),
+			DeviceId:     "device-" + strconv.Itoa(i),
+			DeploymentId: deploymentID,
+			Status:       model.DeviceDeploymentStatusSuccess,
+		}
+	}
+
+	app := new(mapp.App)
+	defer app.AssertExpectations(t)
+	app.On("StreamDevicesListForDeployment",
+		mock.Anything,
+		store.ListQuery{DeploymentID: deploymentID},
+		mock.AnythingOfType("func(*model.DeviceDeployment) error"),
+	).Run(func(args mock.Arguments) {
+		write := args.Get(2).(func(*model.DeviceDeployment) error)
+		for i := range deviceDeployments {
+			assert.NoError(t, write(&deviceDeployments[i]))
+		}
+	}).Return(nil)
+
+	handler, err := NewHandler(context.Background(), app, nil,
+		NewConfig().SetCompressResponses(true, 1024))
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost"+
+		strings.Replace(ApiUrlManagementDeploymentsDevices, "#id", deploymentID, 1), nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	var actual []model.DeviceDeployment
+	if assert.NoError(t, json.Unmarshal(body, &actual)) &&
+		assert.Len(t, actual, len(deviceDeployments)) {
+		for i := range actual {
+			assert.Equal(t, deviceDeployments[i].DeviceId, actual[i].DeviceId)
+			assert.Equal(t, deviceDeployments[i].Status, actual[i].Status)
+		}
+	}
+}
@@ -16,9 +16,11 @@ package http
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ant0ine/go-json-rest/rest"
@@ -57,7 +59,34 @@ func TestPostArtifacts(t *testing.T) {
 		appCreateImage         bool
 		appCreateImageResponse string
 		appCreateImageError    error
+
+		unknownDeviceTypes      []string
+		unknownDeviceTypesError error
+		warning                 string
+
+		artifactSizeLimit uint64
 	}{
+		{
+			requestBodyObject: []h.Part{
+				{
+					FieldName:  "artifact_id",
+					FieldValue: "24436884-a710-4d20-aec4-82c89fbfe29e",
+				},
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			requestContentType: "multipart/form-data",
+			responseCode:       http.StatusRequestEntityTooLarge,
+			responseBody:       "Artifact file too large: the maximum size is 5 bytes",
+			artifactSizeLimit:  5,
+		},
 		{
 			requestBodyObject:  []h.Part{},
 			requestContentType: "",
@@ -164,6 +193,133 @@ func TestPostArtifacts(t *testing.T) {
 			appCreateImageResponse: "24436884-a710-4d20-aec4-82c89fbfe29e",
 			appCreateImageError:    testConflictError,
 		},
+		{
+			requestBodyObject: []h.Part{
+				{
+					FieldName:  "id",
+					FieldValue: "5e2fbcf6a6a7eca56cbc9476",
+				},
+				{
+					FieldName:  "artifact_id",
+					FieldValue: "24436884-a710-4d20-aec4-82c89fbfe29e",
+				},
+				{
+					FieldName:  "description",
+					FieldValue: "description",
+				},
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			requestContentType:     "multipart/form-data",
+			responseCode:           http.StatusCreated,
+			responseBody:           "",
+			appCreateImage:         true,
+			appCreateImageResponse: "24436884-a710-4d20-aec4-82c89fbfe29e",
+			unknownDeviceTypes:     []string{"raspberrypi", "qemux86"},
+			warning:                `199 - "no device has the device type(s): raspberrypi, qemux86"`,
+		},
+		{
+			requestBodyObject: []h.Part{
+				{
+					FieldName:  "id",
+					FieldValue: "5e2fbcf6a6a7eca56cbc9476",
+				},
+				{
+					FieldName:  "artifact_id",
+					FieldValue: "24436884-a710-4d20-aec4-82c89fbfe29e",
+				},
+				{
+					FieldName:  "description",
+					FieldValue: "description",
+				},
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			requestContentType:      "multipart/form-data",
+			responseCode:            http.StatusCreated,
+			responseBody:            "",
+			appCreateImage:          true,
+			appCreateImageResponse:  "24436884-a710-4d20-aec4-82c89fbfe29e",
+			unknownDeviceTypesError: errors.New("inventory unavailable"),
+		},
+		{
+			requestBodyObject: []h.Part{
+				{
+					FieldName:  "id",
+					FieldValue: "5e2fbcf6a6a7eca56cbc9476",
+				},
+				{
+					FieldName:  "artifact_id",
+					FieldValue: "24436884-a710-4d20-aec4-82c89fbfe29e",
+				},
+				{
+					FieldName:  "description",
+					FieldValue: "description",
+				},
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			requestContentType:     "multipart/form-data",
+			responseCode:           http.StatusForbidden,
+			responseBody:           "limit exceeded: releases (10)",
+			appCreateImage:         true,
+			appCreateImageResponse: "24436884-a710-4d20-aec4-82c89fbfe29e",
+			appCreateImageError: &app.LimitExceededError{
+				Name:  model.LimitReleases,
+				Value: 10,
+			},
+		},
+		{
+			requestBodyObject: []h.Part{
+				{
+					FieldName:  "id",
+					FieldValue: "5e2fbcf6a6a7eca56cbc9476",
+				},
+				{
+					FieldName:  "artifact_id",
+					FieldValue: "24436884-a710-4d20-aec4-82c89fbfe29e",
+				},
+				{
+					FieldName:  "description",
+					FieldValue: "description",
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			requestContentType:     "multipart/form-data",
+			responseCode:           http.StatusBadRequest,
+			responseBody:           "artifact provides keys not allowed: rootfs-image.version",
+			appCreateImage:         true,
+			appCreateImageResponse: "24436884-a710-4d20-aec4-82c89fbfe29e",
+			appCreateImageError: &app.ArtifactKeysError{
+				Field: "provides",
+				Keys:  []string{"rootfs-image.version"},
+			},
+		},
 	}
 
 	store := &store_mocks.DataStore{}
@@ -174,6 +330,12 @@ func TestPostArtifacts(t *testing.T) {
 
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
 			app := &app_mocks.App{}
+			app.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+				Return(&model.Limit{
+					Name:  model.LimitArtifactSize,
+					Value: tc.artifactSizeLimit,
+				}, nil).
+				Maybe()
 
 			if tc.appCreateImage {
 				app.On("CreateImage",
@@ -186,6 +348,12 @@ func TestPostArtifacts(t *testing.T) {
 					}),
 				).Return(tc.appCreateImageResponse, tc.appCreateImageError)
 			}
+			if tc.appCreateImage && tc.appCreateImageError == nil {
+				app.On("UnknownArtifactDeviceTypes",
+					h.ContextMatcher(),
+					tc.appCreateImageResponse,
+				).Return(tc.unknownDeviceTypes, tc.unknownDeviceTypesError)
+			}
 
 			d := NewDeploymentsApiHandlers(store, restView, app)
 			api := setUpRestTest("/api/0.0.1/artifacts", rest.Post, d.NewImage)
@@ -196,6 +364,7 @@ func TestPostArtifacts(t *testing.T) {
 			w := httptest.NewRecorder()
 			api.MakeHandler().ServeHTTP(w, req)
 			assert.Equal(t, tc.responseCode, w.Code)
+			assert.Equal(t, tc.warning, w.Header().Get(hdrWarning))
 			if tc.responseBody == "" {
 				assert.Empty(t, w.Body.String())
 			} else {
@@ -210,6 +379,230 @@ func TestPostArtifacts(t *testing.T) {
 
 }
 
+func TestReplaceArtifact(t *testing.T) {
+	const artifactID = "24436884-a710-4d20-aec4-82c89fbfe29e"
+	imageBody := []byte("123456790")
+	artifactParts := []h.Part{
+		{
+			FieldName:  "description",
+			FieldValue: "description",
+		},
+		{
+			FieldName:   "artifact",
+			ContentType: "application/octet-stream",
+			ImageData:   imageBody,
+		},
+	}
+
+	testCases := map[string]struct {
+		id    string
+		parts []h.Part
+
+		callApp bool
+		appErr  error
+
+		code int
+		body string
+	}{
+		"ok": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			code:    http.StatusNoContent,
+		},
+		"error, invalid ID": {
+			id:    "not-a-uuid",
+			parts: artifactParts,
+			code:  http.StatusBadRequest,
+			body:  ErrIDNotUUID.Error(),
+		},
+		"error, missing artifact": {
+			id:    artifactID,
+			parts: artifactParts[:1],
+			code:  http.StatusBadRequest,
+			body:  ErrArtifactFileMissing.Error(),
+		},
+		"error, not found": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			appErr:  app.ErrImageMetaNotFound,
+			code:    http.StatusNotFound,
+			body:    "Resource not found",
+		},
+		"error, name mismatch": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			appErr:  app.ErrArtifactReplaceNameMismatch,
+			code:    http.StatusUnprocessableEntity,
+			body:    app.ErrArtifactReplaceNameMismatch.Error(),
+		},
+		"error, incompatible": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			appErr:  app.ErrArtifactReplaceIncompatible,
+			code:    http.StatusUnprocessableEntity,
+			body:    app.ErrArtifactReplaceIncompatible.Error(),
+		},
+		"error, concurrent replacement": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			appErr:  app.ErrArtifactReplaceConflict,
+			code:    http.StatusConflict,
+			body:    app.ErrArtifactReplaceConflict.Error(),
+		},
+		"error, internal": {
+			id:      artifactID,
+			parts:   artifactParts,
+			callApp: true,
+			appErr:  errors.New("mongo: internal error"),
+			code:    http.StatusInternalServerError,
+			body:    "internal error",
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			appMock := &app_mocks.App{}
+			defer appMock.AssertExpectations(t)
+			appMock.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+				Return(&model.Limit{Name: model.LimitArtifactSize}, nil).
+				Maybe()
+			if tc.callApp {
+				appMock.On("ReplaceImage",
+					h.ContextMatcher(),
+					tc.id,
+					mock.MatchedBy(func(msg *model.MultipartUploadMsg) bool {
+						return msg.MetaConstructor.Description == "description" &&
+							msg.ArtifactReader != nil
+					}),
+				).Return(tc.appErr)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(ApiUrlManagementArtifactsIdFile, rest.Put, d.ReplaceImage)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementArtifactsIdFile, "#id", tc.id, 1)
+			req := h.MakeMultipartRequest(http.MethodPut, url,
+				"multipart/form-data", tc.parts)
+
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+			assert.Equal(t, tc.code, w.Code)
+			if tc.body == "" {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func TestPutImageTags(t *testing.T) {
+	const artifactID = "24436884-a710-4d20-aec4-82c89fbfe29e"
+
+	testCases := map[string]struct {
+		id   string
+		body string
+
+		tags   model.Tags
+		appErr error
+
+		code    int
+		errBody string
+	}{
+		"ok": {
+			id:   artifactID,
+			body: `["qa-approved", "Beta"]`,
+			tags: model.Tags{"qa-approved", "beta"},
+			code: http.StatusNoContent,
+		},
+		"ok, clear tags": {
+			id:   artifactID,
+			body: `[]`,
+			tags: model.Tags{},
+			code: http.StatusNoContent,
+		},
+		"error, invalid ID": {
+			id:      "not-a-uuid",
+			body:    `["qa-approved"]`,
+			code:    http.StatusBadRequest,
+			errBody: ErrIDNotUUID.Error(),
+		},
+		"error, malformed body": {
+			id:      artifactID,
+			body:    `{"tags": "qa-approved"}`,
+			code:    http.StatusBadRequest,
+			errBody: "malformed JSON in request body",
+		},
+		"error, invalid tag": {
+			id:      artifactID,
+			body:    `["qa approved"]`,
+			code:    http.StatusBadRequest,
+			errBody: "invalid request body",
+		},
+		"error, not found": {
+			id:      artifactID,
+			body:    `["qa-approved"]`,
+			tags:    model.Tags{"qa-approved"},
+			appErr:  app.ErrImageMetaNotFound,
+			code:    http.StatusNotFound,
+			errBody: "Resource not found",
+		},
+		"error, too many unique tags": {
+			id:      artifactID,
+			body:    `["qa-approved"]`,
+			tags:    model.Tags{"qa-approved"},
+			appErr:  model.ErrTooManyUniqueTags,
+			code:    http.StatusConflict,
+			errBody: model.ErrTooManyUniqueTags.Error(),
+		},
+		"error, internal": {
+			id:      artifactID,
+			body:    `["qa-approved"]`,
+			tags:    model.Tags{"qa-approved"},
+			appErr:  errors.New("mongo: internal error"),
+			code:    http.StatusInternalServerError,
+			errBody: "internal error",
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			appMock := &app_mocks.App{}
+			defer appMock.AssertExpectations(t)
+			if tc.tags != nil {
+				appMock.On("ReplaceImageTags",
+					h.ContextMatcher(),
+					tc.id,
+					tc.tags,
+				).Return(tc.appErr)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), appMock)
+			api := setUpRestTest(ApiUrlManagementArtifactsIdTags, rest.Put, d.PutImageTags)
+			url := "http://localhost" + strings.Replace(
+				ApiUrlManagementArtifactsIdTags, "#id", tc.id, 1)
+			req := test.MakeSimpleRequest(http.MethodPut, url, nil)
+			req.Body = io.NopCloser(strings.NewReader(tc.body))
+
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+			assert.Equal(t, tc.code, w.Code)
+			if tc.errBody == "" {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), tc.errBody)
+			}
+		})
+	}
+}
+
 func TestPostArtifactsInternal(t *testing.T) {
 	imageBody := []byte("123456790")
 	var testConflictError = model.NewConflictError(
@@ -343,6 +736,9 @@ func TestPostArtifactsInternal(t *testing.T) {
 
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
 			app := &app_mocks.App{}
+			app.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+				Return(&model.Limit{Name: model.LimitArtifactSize}, nil).
+				Maybe()
 
 			if tc.appCreateImage {
 				app.On("CreateImage",
@@ -355,6 +751,12 @@ func TestPostArtifactsInternal(t *testing.T) {
 					}),
 				).Return(tc.appCreateImageResponse, tc.appCreateImageError)
 			}
+			if tc.appCreateImage && tc.appCreateImageError == nil {
+				app.On("UnknownArtifactDeviceTypes",
+					h.ContextMatcher(),
+					tc.appCreateImageResponse,
+				).Return(nil, nil)
+			}
 
 			d := NewDeploymentsApiHandlers(store, restView, app)
 			api := setUpRestTest("/api/0.0.1/tenants/:tenant/artifacts", rest.Post, d.NewImageForTenantHandler)
@@ -816,6 +1218,10 @@ func TestGetImages(t *testing.T) {
 			app := &app_mocks.App{}
 			defer app.AssertExpectations(t)
 
+			app.On("GetImagesListVersion",
+				deployments_testing.ContextMatcher(),
+				tc.filter,
+			).Return(nil, nil)
 			app.On("ListImages",
 				deployments_testing.ContextMatcher(),
 				tc.filter,
@@ -908,6 +1314,10 @@ func TestListImages(t *testing.T) {
 			app := &app_mocks.App{}
 			defer app.AssertExpectations(t)
 
+			app.On("GetImagesListVersion",
+				deployments_testing.ContextMatcher(),
+				tc.filter,
+			).Return(nil, nil)
 			app.On("ListImages",
 				deployments_testing.ContextMatcher(),
 				tc.filter,
@@ -935,3 +1345,175 @@ func TestListImages(t *testing.T) {
 		})
 	}
 }
+
+func TestListImagesProvides(t *testing.T) {
+	testCases := map[string]struct {
+		query string
+
+		filter *dmodel.ReleaseOrImageFilter
+		status int
+	}{
+		"ok": {
+			query: "provides=rootfs-image.version:3.1" +
+				"&provides=rootfs-image.checksum:a:b",
+			filter: &dmodel.ReleaseOrImageFilter{
+				Page:    1,
+				PerPage: 20,
+				Provides: map[string]string{
+					"rootfs-image.version":  "3.1",
+					"rootfs-image.checksum": "a:b",
+				},
+			},
+			status: http.StatusOK,
+		},
+		"ok, tags": {
+			query: "tag=QA-approved&tag=beta",
+			filter: &dmodel.ReleaseOrImageFilter{
+				Page:    1,
+				PerPage: 20,
+				Tags:    []string{"qa-approved", "beta"},
+			},
+			status: http.StatusOK,
+		},
+		"error: missing value separator": {
+			query:  "provides=rootfs-image.version",
+			status: http.StatusBadRequest,
+		},
+		"error: empty key": {
+			query:  "provides=:3.1",
+			status: http.StatusBadRequest,
+		},
+		"error: duplicate key": {
+			query: "provides=rootfs-image.version:3.0" +
+				"&provides=rootfs-image.version:3.1",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			app := &app_mocks.App{}
+			defer app.AssertExpectations(t)
+
+			if tc.filter != nil {
+				app.On("GetImagesListVersion",
+					deployments_testing.ContextMatcher(),
+					tc.filter,
+				).Return(nil, nil)
+				app.On("ListImages",
+					deployments_testing.ContextMatcher(),
+					tc.filter,
+				).Return([]*dmodel.Image{}, 0, nil)
+			}
+
+			c := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := deployments_testing.SetUpTestApi(
+				"/api/management/v1/artifacts/list", rest.Get, c.ListImages,
+			)
+			req := test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/management/v1/artifacts/list?"+tc.query,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+
+			recorded := test.RunRequest(t, api, req)
+			recorded.CodeIs(tc.status)
+			if tc.status == http.StatusBadRequest {
+				mt.CheckResponse(t, mt.NewJSONResponse(
+					http.StatusBadRequest,
+					nil,
+					deployments_testing.RestError(ErrInvalidProvidesParam.Error()),
+				), recorded)
+			}
+		})
+	}
+}
+
+func TestListImagesNotModified(t *testing.T) {
+	version := &dmodel.ListVersion{Count: 1}
+	filter := &dmodel.ReleaseOrImageFilter{Page: 1, PerPage: 20}
+
+	testCases := map[string]struct {
+		ifNoneMatch string
+		version     *dmodel.ListVersion
+		versionErr  error
+
+		status int
+		etag   string
+	}{
+		"ok": {
+			version: version,
+			status:  http.StatusOK,
+			etag:    version.ETag(),
+		},
+		"ok, etag does not match": {
+			ifNoneMatch: `W/"foo"`,
+			version:     version,
+			status:      http.StatusOK,
+			etag:        version.ETag(),
+		},
+		"ok, version error": {
+			ifNoneMatch: version.ETag(),
+			versionErr:  errors.New("database error"),
+			status:      http.StatusOK,
+		},
+		"not modified": {
+			ifNoneMatch: version.ETag(),
+			version:     version,
+			status:      http.StatusNotModified,
+			etag:        version.ETag(),
+		},
+		"not modified, strong etag in list": {
+			ifNoneMatch: `"foo", ` + strings.TrimPrefix(version.ETag(), "W/"),
+			version:     version,
+			status:      http.StatusNotModified,
+			etag:        version.ETag(),
+		},
+		"not modified, any": {
+			ifNoneMatch: "*",
+			version:     version,
+			status:      http.StatusNotModified,
+			etag:        version.ETag(),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			app := &app_mocks.App{}
+			defer app.AssertExpectations(t)
+
+			app.On("GetImagesListVersion",
+				deployments_testing.ContextMatcher(),
+				filter,
+			).Return(tc.version, tc.versionErr)
+			if tc.status == http.StatusOK {
+				app.On("ListImages",
+					deployments_testing.ContextMatcher(),
+					filter,
+				).Return([]*model.Image{}, 0, nil)
+			}
+
+			c := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := deployments_testing.SetUpTestApi(
+				"/api/management/v1/artifacts/list", rest.Get, c.ListImages,
+			)
+			req := test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/management/v1/artifacts/list",
+				nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			recorded := test.RunRequest(t, api, req)
+
+			recorded.CodeIs(tc.status)
+			assert.Equal(t, tc.etag, recorded.Recorder.Header().Get("ETag"))
+			if tc.status == http.StatusNotModified {
+				assert.Empty(t, recorded.Recorder.Body.Bytes())
+			}
+		})
+	}
+}
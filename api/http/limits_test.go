@@ -23,6 +23,7 @@ import (
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
@@ -97,3 +98,135 @@ func TestGetLimits(t *testing.T) {
 		})
 	}
 }
+
+func TestPutTenantLimitInternal(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload interface{}
+		code    int
+
+		limit  *model.Limit
+		appErr error
+	}{
+		{
+			name:    model.LimitDownloadBandwidth,
+			payload: map[string]interface{}{"limit": 1048576},
+			code:    http.StatusNoContent,
+			limit: &model.Limit{
+				Name:  model.LimitDownloadBandwidth,
+				Value: 1048576,
+			},
+		},
+		{
+			name:    model.LimitDownloadBandwidth,
+			payload: map[string]interface{}{"limit": 0},
+			code:    http.StatusNoContent,
+			limit: &model.Limit{
+				Name: model.LimitDownloadBandwidth,
+			},
+		},
+		{
+			name:    model.LimitDownloadBandwidth,
+			payload: map[string]interface{}{"limit": 1024},
+			code:    http.StatusInternalServerError,
+			limit: &model.Limit{
+				Name:  model.LimitDownloadBandwidth,
+				Value: 1024,
+			},
+			appErr: errors.New("failed"),
+		},
+		{
+			name:    model.LimitDownloadBandwidth,
+			payload: map[string]interface{}{},
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    model.LimitDownloadBandwidth,
+			payload: map[string]interface{}{"limit": -1},
+			code:    http.StatusBadRequest,
+		},
+		{
+			name: model.LimitArtifactProvidesKeys,
+			payload: map[string]interface{}{
+				"keys": []string{"rootfs-image.*"},
+			},
+			code: http.StatusNoContent,
+			limit: &model.Limit{
+				Name: model.LimitArtifactProvidesKeys,
+				Keys: []string{"rootfs-image.*"},
+			},
+		},
+		{
+			name:    model.LimitArtifactDependsKeys,
+			payload: map[string]interface{}{},
+			code:    http.StatusNoContent,
+			limit: &model.Limit{
+				Name: model.LimitArtifactDependsKeys,
+			},
+		},
+		{
+			name: model.LimitArtifactProvidesKeys,
+			payload: map[string]interface{}{
+				"keys": []string{"*"},
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: model.LimitArtifactProvidesKeys,
+			payload: map[string]interface{}{
+				"keys": []string{"rootfs-image.version", ""},
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: model.LimitArtifactDependsKeys,
+			payload: map[string]interface{}{
+				"limit": 1024,
+				"keys":  []string{"rootfs-image.version"},
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: model.LimitReleases,
+			payload: map[string]interface{}{
+				"limit": 10,
+				"keys":  []string{"rootfs-image.version"},
+			},
+			code: http.StatusBadRequest,
+		},
+		{
+			name:    "foobar",
+			payload: map[string]interface{}{"limit": 1024},
+			code:    http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			app := &app_mocks.App{}
+			defer app.AssertExpectations(t)
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+
+			api := setUpRestTest(ApiUrlInternalTenantLimitsName,
+				rest.Put, d.PutTenantLimitInternal)
+
+			if tc.limit != nil {
+				app.On("SetLimit",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						return id != nil && id.Tenant == "tenant1"
+					}),
+					tc.limit,
+				).Return(tc.appErr)
+			}
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest(http.MethodPut,
+					"http://localhost"+ApiUrlInternal+"/tenants/tenant1/limits/"+tc.name,
+					tc.payload))
+			recorded.CodeIs(tc.code)
+		})
+	}
+}
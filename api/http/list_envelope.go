@@ -0,0 +1,63 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"strconv"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/pkg/errors"
+)
+
+// ParamEnvelope requests a list wrapped with its pagination metadata
+const ParamEnvelope = "envelope"
+
+var ErrInvalidEnvelope = errors.New("envelope: must be a boolean")
+
+// listEnvelope carries a page of a list along with the pagination metadata
+// otherwise only sent in the response headers.
+type listEnvelope struct {
+	Items   interface{} `json:"items"`
+	Total   int64       `json:"total"`
+	Page    int64       `json:"page"`
+	PerPage int64       `json:"per_page"`
+}
+
+// parseEnvelope tells whether the request asks for an enveloped list.
+func parseEnvelope(r *rest.Request) (bool, error) {
+	value := r.URL.Query().Get(ParamEnvelope)
+	if value == "" {
+		return false, nil
+	}
+	envelope, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, ErrInvalidEnvelope
+	}
+	return envelope, nil
+}
+
+// listBody returns the response body of a list: the bare items unless an
+// envelope was requested.
+func listBody(envelope bool, items interface{}, total, page, perPage int64) interface{} {
+	if !envelope {
+		return items
+	}
+	return listEnvelope{
+		Items:   items,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+}
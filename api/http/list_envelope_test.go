@@ -0,0 +1,207 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	mapp "github.com/mendersoftware/deployments/app/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+	store_mocks "github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+)
+
+func TestLookupDeploymentEnvelope(t *testing.T) {
+	t.Parallel()
+
+	deployments := []*model.Deployment{
+		{Id: "a3c0f8a5-4f2a-4f0e-8b8a-8d5c4f7e2b10"},
+		{Id: "e2d7bd5a-6a77-4b11-9f3c-1e6a2f2f6d4c"},
+	}
+	testCases := map[string]struct {
+		envelope string
+
+		callApp      bool
+		responseCode int
+		ids          []string
+	}{
+		"ok, bare list": {
+			envelope:     "false",
+			callApp:      true,
+			responseCode: http.StatusOK,
+			ids:          []string{deployments[0].Id, deployments[1].Id},
+		},
+		"ok, envelope": {
+			envelope:     "true",
+			callApp:      true,
+			responseCode: http.StatusOK,
+			ids:          []string{deployments[0].Id},
+		},
+		"error, invalid envelope": {
+			envelope:     "maybe",
+			responseCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			page, perPage := "1", "20"
+			query := model.Query{
+				Limit: rest_utils.PerPageDefault + 1,
+				Sort:  model.SortDirectionDescending,
+			}
+			result := deployments
+			if tc.envelope == "true" {
+				page, perPage = "2", "1"
+				query.Skip, query.Limit = 1, 2
+				result = deployments[:1]
+			}
+			if tc.callApp {
+				app.On("LookupDeployment", contextMatcher(), query).
+					Return(result, int64(5), nil)
+			}
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeployments,
+				rest.Get,
+				d.LookupDeployment,
+			)
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost"+ApiUrlManagementDeployments+
+					"?page="+page+"&per_page="+perPage+"&envelope="+tc.envelope,
+				nil)
+
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.responseCode)
+			type item struct {
+				ID string `json:"id"`
+			}
+			if tc.responseCode == http.StatusOK && tc.envelope == "true" {
+				var body struct {
+					Items   []item `json:"items"`
+					Total   int    `json:"total"`
+					Page    int    `json:"page"`
+					PerPage int    `json:"per_page"`
+				}
+				assert.NoError(t, recorded.DecodeJsonPayload(&body))
+				assert.Equal(t, []item{{ID: tc.ids[0]}}, body.Items)
+				assert.Equal(t, 5, body.Total)
+				assert.Equal(t, 2, body.Page)
+				assert.Equal(t, 1, body.PerPage)
+			} else if tc.responseCode == http.StatusOK {
+				var body []item
+				assert.NoError(t, recorded.DecodeJsonPayload(&body))
+				assert.Equal(t, []item{{ID: tc.ids[0]}, {ID: tc.ids[1]}}, body)
+				assert.Equal(t, "5", recorded.Recorder.Header().Get(hdrTotalCount))
+			} else {
+				var body struct{ Error string }
+				assert.NoError(t, recorded.DecodeJsonPayload(&body))
+				assert.Equal(t, ErrInvalidEnvelope.Error(), body.Error)
+			}
+		})
+	}
+}
+
+func TestListReleasesEnvelope(t *testing.T) {
+	t.Parallel()
+
+	filter := &model.ReleaseOrImageFilter{Page: 1, PerPage: 20}
+	store := &store_mocks.DataStore{}
+	defer store.AssertExpectations(t)
+	store.On("GetReleasesListVersion", contextMatcher(), filter).
+		Return(nil, nil)
+	store.On("GetReleases", contextMatcher(), filter).
+		Return([]model.Release{{Name: "release-1"}}, 1, nil)
+
+	d := NewDeploymentsApiHandlers(store, new(view.RESTView), &mapp.App{})
+	api := setUpRestTest(
+		ApiUrlManagementV2Releases,
+		rest.Get,
+		d.ListReleasesV2,
+	)
+	req := test.MakeSimpleRequest("GET",
+		"http://localhost"+ApiUrlManagementV2Releases+"?envelope=1",
+		nil)
+
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusOK)
+	var body struct {
+		Items   []model.Release `json:"items"`
+		Total   int             `json:"total"`
+		Page    int             `json:"page"`
+		PerPage int             `json:"per_page"`
+	}
+	assert.NoError(t, recorded.DecodeJsonPayload(&body))
+	if assert.Len(t, body.Items, 1) {
+		assert.Equal(t, "release-1", body.Items[0].Name)
+	}
+	assert.Equal(t, 1, body.Total)
+	assert.Equal(t, 1, body.Page)
+	assert.Equal(t, 20, body.PerPage)
+}
+
+func TestListDeviceDeploymentsEnvelope(t *testing.T) {
+	t.Parallel()
+
+	const deviceID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	query := store.ListQueryDeviceDeployments{
+		DeviceID: deviceID,
+		Limit:    DefaultPerPage,
+	}
+	app := &mapp.App{}
+	defer app.AssertExpectations(t)
+	app.On("GetDeviceDeploymentListForDevice", contextMatcher(), query).
+		Return([]model.DeviceDeploymentListItem{}, 0, nil)
+
+	d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+	api := setUpRestTest(
+		ApiUrlManagementDeploymentsDeviceId,
+		rest.Get,
+		d.ListDeviceDeployments,
+	)
+	req := test.MakeSimpleRequest("GET",
+		"http://localhost"+
+			strings.Replace(ApiUrlManagementDeploymentsDeviceId, "#id", deviceID, 1)+
+			"?envelope=true",
+		nil)
+
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusOK)
+	assertJSONFields(t,
+		`{"items":[],"total":0,"page":1,"per_page":20}`,
+		recorded.Recorder.Body.Bytes())
+}
+
+// assertJSONFields compares the JSON documents regardless of formatting.
+func assertJSONFields(t *testing.T, expected string, actual []byte) {
+	var exp, act interface{}
+	assert.NoError(t, json.Unmarshal([]byte(expected), &exp))
+	assert.NoError(t, json.Unmarshal(actual, &act))
+	assert.Equal(t, exp, act)
+}
@@ -29,7 +29,7 @@ type RESTView interface {
 	RenderSuccessPost(w rest.ResponseWriter, r *rest.Request, id string)
 	RenderEmptySuccessResponse(w rest.ResponseWriter)
 	RenderErrorNotFound(w rest.ResponseWriter, r *rest.Request, l *log.Logger)
-	RenderDeploymentLog(w rest.ResponseWriter, dlog model.DeploymentLog)
+	RenderDeploymentLog(w rest.ResponseWriter, dlogs ...model.DeploymentLog)
 	RenderSuccessDelete(w rest.ResponseWriter)
 	RenderSuccessPut(w rest.ResponseWriter)
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/mendersoftware/deployments/app"
 	"github.com/mendersoftware/deployments/store"
@@ -41,12 +42,26 @@ const (
 
 	ApiUrlManagementArtifacts               = ApiUrlManagement + "/artifacts"
 	ApiUrlManagementArtifactsList           = ApiUrlManagement + "/artifacts/list"
+	ApiUrlManagementArtifactsDeletions      = ApiUrlManagement + "/artifacts/deletions"
 	ApiUrlManagementArtifactsGenerate       = ApiUrlManagement + "/artifacts/generate"
 	ApiUrlManagementArtifactsDirectUpload   = ApiUrlManagement + "/artifacts/directupload"
 	ApiUrlManagementArtifactsCompleteUpload = ApiUrlManagementArtifactsDirectUpload +
 		"/#id/complete"
-	ApiUrlManagementArtifactsId         = ApiUrlManagement + "/artifacts/#id"
-	ApiUrlManagementArtifactsIdDownload = ApiUrlManagement + "/artifacts/#id/download"
+	ApiUrlManagementArtifactsDirectUploadResumable = ApiUrlManagementArtifactsDirectUpload +
+		"/resumable"
+	ApiUrlManagementArtifactsDirectUploadId = ApiUrlManagementArtifactsDirectUpload +
+		"/#id"
+	ApiUrlManagementArtifactsDirectUploadPart = ApiUrlManagementArtifactsDirectUpload +
+		"/#id/parts/#part_number"
+	ApiUrlManagementArtifactsDirectUploadPartLink = ApiUrlManagementArtifactsDirectUploadPart +
+		"/link"
+	ApiUrlManagementArtifactsId            = ApiUrlManagement + "/artifacts/#id"
+	ApiUrlManagementArtifactsIdDownload    = ApiUrlManagement + "/artifacts/#id/download"
+	ApiUrlManagementArtifactsIdStream      = ApiUrlManagement + "/artifacts/#id/stream"
+	ApiUrlManagementArtifactsIdRelease     = ApiUrlManagement + "/artifacts/#id/release"
+	ApiUrlManagementArtifactsIdFile        = ApiUrlManagement + "/artifacts/#id/file"
+	ApiUrlManagementArtifactsIdTags        = ApiUrlManagement + "/artifacts/#id/tags"
+	ApiUrlManagementArtifactsIdDeployments = ApiUrlManagement + "/artifacts/#id/deployments"
 
 	ApiUrlManagementDeployments                   = ApiUrlManagement + "/deployments"
 	ApiUrlManagementMultipleDeploymentsStatistics = ApiUrlManagement +
@@ -59,38 +74,83 @@ const (
 	ApiUrlManagementDeploymentsDevicesList = ApiUrlManagement + "/deployments/#id/devices/list"
 	ApiUrlManagementDeploymentsLog         = ApiUrlManagement +
 		"/deployments/#id/devices/#devid/log"
+	ApiUrlManagementDeploymentsDevicesSearch = ApiUrlManagement + "/deployments/devices"
 	ApiUrlManagementDeploymentsDeviceId      = ApiUrlManagement + "/deployments/devices/#id"
 	ApiUrlManagementDeploymentsDeviceHistory = ApiUrlManagement + "/deployments/devices/#id/history"
+	ApiUrlManagementDeploymentsDeviceActive  = ApiUrlManagement + "/deployments/devices/#id/active"
 	ApiUrlManagementDeploymentsDeviceList    = ApiUrlManagement + "/deployments/#id/device_list"
-
-	ApiUrlManagementReleases     = ApiUrlManagement + "/deployments/releases"
-	ApiUrlManagementReleasesList = ApiUrlManagement + "/deployments/releases/list"
+	ApiUrlManagementDeploymentsTargetDevices = ApiUrlManagement +
+		"/deployments/#id/target_devices"
+	ApiUrlManagementDeploymentsTrend         = ApiUrlManagement + "/deployments/trend"
+	ApiUrlManagementDeploymentsLargest       = ApiUrlManagement + "/deployments/largest"
+	ApiUrlManagementDeploymentsSummary       = ApiUrlManagement + "/deployments/summary"
+	ApiUrlManagementDeploymentsRestore       = ApiUrlManagement + "/deployments/#id/restore"
+	ApiUrlManagementDeploymentsStart         = ApiUrlManagement + "/deployments/#id/start"
+	ApiUrlManagementDeploymentsArtifactAbort = ApiUrlManagement +
+		"/deployments/artifacts/#name/abort"
+	ApiUrlManagementDeploymentsCompliance = ApiUrlManagement +
+		"/deployments/reports/compliance"
+
+	ApiUrlManagementFleetSoftwareInventory = ApiUrlManagement + "/fleet/software_inventory"
+
+	ApiUrlManagementReleases                = ApiUrlManagement + "/deployments/releases"
+	ApiUrlManagementReleasesList            = ApiUrlManagement + "/deployments/releases/list"
+	ApiUrlManagementReleasesNameDeployments = ApiUrlManagement +
+		"/deployments/releases/#name/deployments"
 
 	ApiUrlManagementLimitsName = ApiUrlManagement + "/limits/#name"
 
-	ApiUrlManagementV2                      = "/api/management/v2/deployments"
-	ApiUrlManagementV2Releases              = ApiUrlManagementV2 + "/deployments/releases"
-	ApiUrlManagementV2ReleasesName          = ApiUrlManagementV2Releases + "/#name"
-	ApiUrlManagementV2ReleaseTags           = ApiUrlManagementV2Releases + "/#name/tags"
-	ApiUrlManagementV2ReleaseAllTags        = ApiUrlManagementV2 + "/releases/all/tags"
-	ApiUrlManagementV2ReleaseAllUpdateTypes = ApiUrlManagementV2 + "/releases/all/types"
+	ApiUrlManagementV2                            = "/api/management/v2/deployments"
+	ApiUrlManagementV2Releases                    = ApiUrlManagementV2 + "/deployments/releases"
+	ApiUrlManagementV2ReleasesName                = ApiUrlManagementV2Releases + "/#name"
+	ApiUrlManagementV2ReleaseTags                 = ApiUrlManagementV2Releases + "/#name/tags"
+	ApiUrlManagementV2ReleaseGraph                = ApiUrlManagementV2Releases + "/#name/graph"
+	ApiUrlManagementV2ReleaseAllTags              = ApiUrlManagementV2 + "/releases/all/tags"
+	ApiUrlManagementV2ReleaseAllUpdateTypes       = ApiUrlManagementV2 + "/releases/all/types"
+	ApiUrlManagementV2ReleaseAllUpdateTypesCounts = ApiUrlManagementV2 + "/releases/all/types/counts"
 
 	ApiUrlDevicesDeploymentsNext  = ApiUrlDevices + "/device/deployments/next"
+	ApiUrlDevicesDeploymentsCheck = ApiUrlDevices + "/device/deployments/check"
 	ApiUrlDevicesDeploymentStatus = ApiUrlDevices + "/device/deployments/#id/status"
 	ApiUrlDevicesDeploymentsLog   = ApiUrlDevices + "/device/deployments/#id/log"
 	ApiUrlDevicesDownloadConfig   = ApiUrlDevices +
 		"/download/configuration/#deployment_id/#device_type/#device_id"
+	ApiUrlDevicesDownloadArtifact = ApiUrlDevices + "/download/artifacts/#id"
 
 	ApiUrlInternalAlive                    = ApiUrlInternal + "/alive"
 	ApiUrlInternalHealth                   = ApiUrlInternal + "/health"
+	ApiUrlInternalMetrics                  = ApiUrlInternal + "/metrics"
+	ApiUrlInternalMaintenance              = ApiUrlInternal + "/maintenance"
 	ApiUrlInternalTenants                  = ApiUrlInternal + "/tenants"
 	ApiUrlInternalTenantDeployments        = ApiUrlInternal + "/tenants/#tenant/deployments"
 	ApiUrlInternalTenantDeploymentsDevices = ApiUrlInternal + "/tenants/#tenant/deployments/devices"
+	ApiUrlInternalTenantDeploymentsStale   = ApiUrlInternal + "/tenants/#tenant/deployments/stale"
+	ApiUrlInternalDeploymentsActiveTenants = ApiUrlInternal + "/deployments/active/tenants"
 	ApiUrlInternalTenantDeploymentsDevice  = ApiUrlInternal +
 		"/tenants/#tenant/deployments/devices/#id"
+	ApiUrlInternalTenantDeploymentsDeviceActiveCount = ApiUrlInternal +
+		"/tenants/#tenant/deployments/devices/#id/active/count"
 	ApiUrlInternalTenantArtifacts       = ApiUrlInternal + "/tenants/#tenant/artifacts"
+	ApiUrlInternalTenantArtifactsImport = ApiUrlInternal +
+		"/tenants/#tenant/artifacts/import"
+	ApiUrlInternalTenantArtifactsImportID = ApiUrlInternal +
+		"/tenants/#tenant/artifacts/import/#id"
+	ApiUrlInternalTenantDeploymentDeviceCount = ApiUrlInternal +
+		"/tenants/#tenant/deployments/#id/device_count/reconcile"
+	ApiUrlInternalTenantArtifactsOrphaned = ApiUrlInternal +
+		"/tenants/#tenant/artifacts/orphaned"
+	ApiUrlInternalTenantArtifactsModified = ApiUrlInternal +
+		"/tenants/#tenant/artifacts/modified"
+	ApiUrlInternalTenantExports = ApiUrlInternal +
+		"/tenants/#tenant/exports"
+	ApiUrlInternalTenantExportID = ApiUrlInternal +
+		"/tenants/#tenant/exports/#id"
+	ApiUrlInternalTenantExportRun = ApiUrlInternal +
+		"/tenants/#tenant/exports/#id/run"
 	ApiUrlInternalTenantStorageSettings = ApiUrlInternal +
 		"/tenants/#tenant/storage/settings"
+	ApiUrlInternalTenantLimitsName = ApiUrlInternal +
+		"/tenants/#tenant/limits/#name"
 	ApiUrlInternalDeviceConfigurationDeployments = ApiUrlInternal +
 		"/tenants/#tenant/configuration/deployments/#deployment_id/devices/#device_id"
 	ApiUrlInternalDeviceDeploymentLastStatusDeployments = ApiUrlInternal +
@@ -145,7 +205,8 @@ func NewHandler(
 			DisableLog: func(statusCode int, r *rest.Request) bool {
 				if statusCode < 300 {
 					if r.URL.Path == ApiUrlInternalHealth ||
-						r.URL.Path == ApiUrlInternalAlive {
+						r.URL.Path == ApiUrlInternalAlive ||
+						r.URL.Path == ApiUrlInternalMetrics {
 						return true
 					}
 				}
@@ -182,6 +243,14 @@ func NewHandler(
 		rest.MiddlewareSimple(contentTypeMiddleware),
 		publicRoutes...,
 	)
+	if cfg.CompressResponses {
+		gzipMiddleware := &GzipMiddleware{MinSize: cfg.CompressMinSize}
+		for _, route := range publicRoutes {
+			if route.HttpMethod == http.MethodGet && compressedRoutes[route.PathExp] {
+				route.Func = gzipMiddleware.MiddlewareFunc(route.Func)
+			}
+		}
+	}
 	routes := append(publicRoutes, internalRoutes...)
 
 	restApp, err := rest.MakeRouter(routes...)
@@ -202,8 +271,11 @@ func NewImagesResourceRoutes(controller *DeploymentsApiHandlers, cfg *Config) []
 	routes := []*rest.Route{
 		rest.Get(ApiUrlManagementArtifacts, controller.GetImages),
 		rest.Get(ApiUrlManagementArtifactsList, controller.ListImages),
+		rest.Get(ApiUrlManagementArtifactsDeletions, controller.ListArtifactDeletions),
 		rest.Get(ApiUrlManagementArtifactsId, controller.GetImage),
 		rest.Get(ApiUrlManagementArtifactsIdDownload, controller.DownloadLink),
+		rest.Get(ApiUrlManagementArtifactsIdStream, controller.StreamArtifact),
+		rest.Get(ApiUrlManagementArtifactsIdDeployments, controller.ListArtifactDeployments),
 	}
 	if !controller.config.DisableNewReleasesFeature {
 		routes = append(routes,
@@ -211,6 +283,9 @@ func NewImagesResourceRoutes(controller *DeploymentsApiHandlers, cfg *Config) []
 			rest.Post(ApiUrlManagementArtifactsGenerate, controller.GenerateImage),
 			rest.Delete(ApiUrlManagementArtifactsId, controller.DeleteImage),
 			rest.Put(ApiUrlManagementArtifactsId, controller.EditImage),
+			rest.Put(ApiUrlManagementArtifactsIdFile, controller.ReplaceImage),
+			rest.Put(ApiUrlManagementArtifactsIdTags, controller.PutImageTags),
+			rest.Get(ApiUrlManagementArtifactsIdRelease, controller.GetReleaseForArtifact),
 		)
 	} else {
 		routes = append(routes,
@@ -238,6 +313,24 @@ func NewImagesResourceRoutes(controller *DeploymentsApiHandlers, cfg *Config) []
 			ApiUrlManagementArtifactsCompleteUpload,
 			controller.CompleteUpload,
 		))
+		routes = append(routes,
+			rest.Post(
+				ApiUrlManagementArtifactsDirectUploadResumable,
+				controller.CreateUploadSession,
+			),
+			rest.Get(
+				ApiUrlManagementArtifactsDirectUploadId,
+				controller.GetUploadSession,
+			),
+			rest.Post(
+				ApiUrlManagementArtifactsDirectUploadPartLink,
+				controller.UploadPartLink,
+			),
+			rest.Put(
+				ApiUrlManagementArtifactsDirectUploadPart,
+				controller.ReportUploadPart,
+			),
+		)
 	}
 	return routes
 }
@@ -248,16 +341,45 @@ func NewDeploymentsResourceRoutes(controller *DeploymentsApiHandlers) []*rest.Ro
 		return []*rest.Route{}
 	}
 
-	return []*rest.Route{
+	// Devices
+	deviceRoutes := wrapMiddleware(
+		rest.MiddlewareSimple(controller.DeviceMaintenanceMiddleware),
+		rest.Get(ApiUrlDevicesDeploymentsNext, controller.GetDeploymentForDevice),
+		rest.Post(ApiUrlDevicesDeploymentsNext, controller.GetDeploymentForDevice),
+		rest.Get(ApiUrlDevicesDeploymentsCheck, controller.CheckDeploymentForDevice),
+		rest.Put(ApiUrlDevicesDeploymentStatus,
+			controller.PutDeploymentStatusForDevice),
+		rest.Put(ApiUrlDevicesDeploymentsLog,
+			controller.PutDeploymentLogForDevice),
+		rest.Get(ApiUrlDevicesDownloadConfig,
+			controller.DownloadConfiguration),
+		rest.Get(ApiUrlDevicesDownloadArtifact,
+			controller.StreamArtifactForDevice),
+	)
+
+	return append([]*rest.Route{
 		// Deployments
 		rest.Post(ApiUrlManagementDeployments, controller.PostDeployment),
 		rest.Post(ApiUrlManagementDeploymentsGroup, controller.DeployToGroup),
+		rest.Get(ApiUrlManagementDeploymentsGroup, controller.ListGroupDeployments),
 		rest.Get(ApiUrlManagementDeployments, controller.LookupDeployment),
+		// must precede ApiUrlManagementDeploymentsId, which also matches
+		rest.Get(ApiUrlManagementDeploymentsTrend, controller.GetDeploymentCreationTrend),
+		rest.Get(ApiUrlManagementDeploymentsLargest, controller.GetLargestDeployments),
+		rest.Get(ApiUrlManagementDeploymentsSummary, controller.GetDeploymentsSummary),
+		rest.Get(ApiUrlManagementDeploymentsCompliance, controller.GetComplianceReport),
+		rest.Get(ApiUrlManagementDeploymentsDevicesSearch, controller.SearchDeviceDeployments),
 		rest.Get(ApiUrlManagementDeploymentsId, controller.GetDeployment),
+		rest.Patch(ApiUrlManagementDeploymentsId, controller.PatchDeployment),
+		rest.Delete(ApiUrlManagementDeploymentsId, controller.DeleteDeployment),
 		rest.Post(ApiUrlManagementMultipleDeploymentsStatistics,
 			controller.GetDeploymentsStats),
 		rest.Get(ApiUrlManagementDeploymentsStatistics, controller.GetDeploymentStats),
 		rest.Put(ApiUrlManagementDeploymentsStatus, controller.AbortDeployment),
+		rest.Post(ApiUrlManagementDeploymentsRestore, controller.RestoreDeployment),
+		rest.Post(ApiUrlManagementDeploymentsStart, controller.StartDeployment),
+		rest.Post(ApiUrlManagementDeploymentsArtifactAbort,
+			controller.AbortDeploymentsByArtifactName),
 		rest.Get(ApiUrlManagementDeploymentsDevices,
 			controller.GetDeviceStatusesForDeployment),
 		rest.Get(ApiUrlManagementDeploymentsDevicesList,
@@ -270,19 +392,15 @@ func NewDeploymentsResourceRoutes(controller *DeploymentsApiHandlers) []*rest.Ro
 			controller.DeleteDeviceDeploymentsHistory),
 		rest.Get(ApiUrlManagementDeploymentsDeviceId,
 			controller.ListDeviceDeployments),
+		rest.Get(ApiUrlManagementDeploymentsDeviceActive,
+			controller.ListActiveDeploymentsForDevice),
 		rest.Get(ApiUrlManagementDeploymentsDeviceList,
 			controller.GetDeploymentDeviceList),
-
-		// Devices
-		rest.Get(ApiUrlDevicesDeploymentsNext, controller.GetDeploymentForDevice),
-		rest.Post(ApiUrlDevicesDeploymentsNext, controller.GetDeploymentForDevice),
-		rest.Put(ApiUrlDevicesDeploymentStatus,
-			controller.PutDeploymentStatusForDevice),
-		rest.Put(ApiUrlDevicesDeploymentsLog,
-			controller.PutDeploymentLogForDevice),
-		rest.Get(ApiUrlDevicesDownloadConfig,
-			controller.DownloadConfiguration),
-	}
+		rest.Get(ApiUrlManagementDeploymentsTargetDevices,
+			controller.GetDeploymentTargetDevices),
+		rest.Get(ApiUrlManagementFleetSoftwareInventory,
+			controller.GetFleetSoftwareInventory),
+	}, deviceRoutes...)
 }
 
 func NewLimitsResourceRoutes(controller *DeploymentsApiHandlers) []*rest.Route {
@@ -311,9 +429,21 @@ func InternalRoutes(controller *DeploymentsApiHandlers) []*rest.Route {
 			controller.ListDeviceDeploymentsInternal),
 		rest.Delete(ApiUrlInternalTenantDeploymentsDevice,
 			controller.AbortDeviceDeploymentsInternal),
+		rest.Get(ApiUrlInternalTenantDeploymentsDeviceActiveCount,
+			controller.CountActiveDeploymentsByDeviceInternal),
+		rest.Get(ApiUrlInternalTenantDeploymentsStale,
+			controller.ListStaleDeploymentsInternal),
+		rest.Get(ApiUrlInternalDeploymentsActiveTenants,
+			controller.ListTenantsActiveDeploymentsInternal),
+		// analytics exports
+		rest.Post(ApiUrlInternalTenantExports, controller.ExportDeploymentsInternal),
+		rest.Get(ApiUrlInternalTenantExportID, controller.GetExportJobInternal),
+		rest.Post(ApiUrlInternalTenantExportRun, controller.RunExportJobInternal),
 		// per-tenant storage settings
 		rest.Get(ApiUrlInternalTenantStorageSettings, controller.GetTenantStorageSettingsHandler),
 		rest.Put(ApiUrlInternalTenantStorageSettings, controller.PutTenantStorageSettingsHandler),
+		// per-tenant limits
+		rest.Put(ApiUrlInternalTenantLimitsName, controller.PutTenantLimitInternal),
 
 		// Configuration deployments (internal)
 		rest.Post(ApiUrlInternalDeviceConfigurationDeployments,
@@ -326,15 +456,31 @@ func InternalRoutes(controller *DeploymentsApiHandlers) []*rest.Route {
 		// Health Check
 		rest.Get(ApiUrlInternalAlive, controller.AliveHandler),
 		rest.Get(ApiUrlInternalHealth, controller.HealthHandler),
+		rest.Get(ApiUrlInternalMetrics, MetricsHandler),
+
+		// Maintenance mode
+		rest.Get(ApiUrlInternalMaintenance, controller.GetMaintenanceModeInternal),
+		rest.Put(ApiUrlInternalMaintenance, controller.PutMaintenanceModeInternal),
+		rest.Get(ApiUrlInternalTenantArtifactsOrphaned,
+			controller.ListOrphanedArtifactsInternal),
+		rest.Get(ApiUrlInternalTenantArtifactsModified,
+			controller.ListArtifactsModifiedInternal),
+		rest.Post(ApiUrlInternalTenantDeploymentDeviceCount,
+			controller.ReconcileDeviceCountInternal),
 	}
 
 	if !controller.config.DisableNewReleasesFeature {
 		routes = append(routes,
 			rest.Post(ApiUrlInternalTenantArtifacts, controller.NewImageForTenantHandler),
+			rest.Post(ApiUrlInternalTenantArtifactsImport, controller.ImportArtifactsInternal),
+			rest.Get(ApiUrlInternalTenantArtifactsImportID,
+				controller.GetArtifactImportInternal),
 		)
 	} else {
 		routes = append(routes,
 			rest.Post(ApiUrlInternalTenantArtifacts, ServiceUnavailable),
+			rest.Post(ApiUrlInternalTenantArtifactsImport, ServiceUnavailable),
+			rest.Get(ApiUrlInternalTenantArtifactsImportID, ServiceUnavailable),
 		)
 	}
 
@@ -350,16 +496,23 @@ func ReleasesRoutes(controller *DeploymentsApiHandlers) []*rest.Route {
 		return []*rest.Route{
 			rest.Get(ApiUrlManagementReleases, controller.GetReleases),
 			rest.Get(ApiUrlManagementReleasesList, controller.ListReleases),
+			rest.Get(ApiUrlManagementReleasesNameDeployments, controller.GetReleaseRollout),
 		}
 	} else {
 		return []*rest.Route{
 			rest.Get(ApiUrlManagementReleases, controller.GetReleases),
 			rest.Get(ApiUrlManagementReleasesList, controller.ListReleases),
+			rest.Get(ApiUrlManagementReleasesNameDeployments, controller.GetReleaseRollout),
 			rest.Get(ApiUrlManagementV2Releases, controller.ListReleasesV2),
 			rest.Put(ApiUrlManagementV2ReleaseTags, controller.PutReleaseTags),
 			rest.Get(ApiUrlManagementV2ReleaseAllTags, controller.GetReleaseTagKeys),
 			rest.Get(ApiUrlManagementV2ReleaseAllUpdateTypes, controller.GetReleasesUpdateTypes),
+			rest.Get(ApiUrlManagementV2ReleaseAllUpdateTypesCounts,
+				controller.GetReleasesUpdateTypesWithCounts),
+			rest.Get(ApiUrlManagementV2ReleasesName, controller.GetRelease),
+			rest.Get(ApiUrlManagementV2ReleaseGraph, controller.GetReleaseGraph),
 			rest.Patch(ApiUrlManagementV2ReleasesName, controller.PatchRelease),
+			rest.Delete(ApiUrlManagementV2ReleasesName, controller.DeleteRelease),
 			rest.Delete(ApiUrlManagementV2Releases, controller.DeleteReleases),
 		}
 	}
@@ -374,6 +527,13 @@ func FMTConfigURL(scheme, hostname, deploymentID, deviceType, deviceID string) s
 	return scheme + "://" + hostname + repl.Replace(ApiUrlDevicesDownloadConfig)
 }
 
+var metricsHandler = promhttp.Handler()
+
+// MetricsHandler exposes the Prometheus metrics of the service.
+func MetricsHandler(w rest.ResponseWriter, r *rest.Request) {
+	metricsHandler.ServeHTTP(w.(http.ResponseWriter), r.Request)
+}
+
 func ServiceUnavailable(w rest.ResponseWriter, r *rest.Request) {
 	w.WriteHeader(http.StatusServiceUnavailable)
 }
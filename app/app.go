@@ -17,10 +17,14 @@ package app
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"path"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -50,17 +54,31 @@ const (
 	ArtifactConfigureProvidesCleared = "data-partition.mender-configure.*"
 
 	DefaultUpdateDownloadLinkExpire  = 24 * time.Hour
+	DefaultUpdateDownloadLinkMaxTTL  = 7 * 24 * time.Hour
+	DefaultDownloadLinkMaxExpire     = 24 * time.Hour
 	DefaultImageGenerationLinkExpire = 7 * 24 * time.Hour
 	PerPageInventoryDevices          = 512
+	DeploymentPreviewSampleSize      = 20
 	InventoryGroupScope              = "system"
 	InventoryIdentityScope           = "identity"
 	InventoryGroupAttributeName      = "group"
 	InventoryStatusAttributeName     = "status"
 	InventoryStatusAccepted          = "accepted"
+	InventoryInventoryScope          = "inventory"
+	InventoryDeviceTypeAttributeName = "device_type"
 
 	fileSuffixTmp = ".tmp"
 
 	inprogressIdleTime = time.Hour
+
+	abortDeploymentsBatchSize = 100
+
+	// DefaultActiveDeploymentsCountRateLimit is the default number of
+	// tenant databases counted per second.
+	DefaultActiveDeploymentsCountRateLimit = 10
+	// DefaultActiveDeploymentsCountLimit is the default number of tenants
+	// counted per page.
+	DefaultActiveDeploymentsCountLimit = 20
 )
 
 var (
@@ -82,7 +100,14 @@ var (
 	ErrModelImageUsedInAnyDeployment = errors.New("Image has already been used in deployment")
 	ErrModelParsingArtifactFailed    = errors.New("Cannot parse artifact file")
 	ErrUploadNotFound                = errors.New("artifact object not found")
-	ErrEmptyArtifact                 = errors.New("artifact cannot be nil")
+	ErrUploadNotResumable            = errors.New("upload is not resumable")
+	ErrUploadIncomplete              = errors.New("no part of the upload was reported")
+	ErrResumableUploadNotSupported   = errors.New(
+		"resumable uploads are not supported by the storage provider",
+	)
+	ErrEmptyArtifact               = errors.New("artifact cannot be nil")
+	ErrArtifactRangeNotSatisfiable = errors.New("requested range not satisfiable")
+	ErrArtifactNotAssigned         = errors.New("the artifact is not assigned to the device")
 
 	ErrMsgArtifactConflict = "An artifact with the same name has conflicting dependencies"
 
@@ -95,6 +120,8 @@ var (
 	ErrStorageNotFound         = errors.New("Not found")
 	ErrDeploymentAborted       = errors.New("Deployment aborted")
 	ErrDeviceDecommissioned    = errors.New("Device decommissioned")
+	ErrDeploymentDeleted       = errors.New("Deployment deleted")
+	ErrDeploymentNotPaused     = errors.New("Deployment already started")
 	ErrNoArtifact              = errors.New("No artifact for the deployment")
 	ErrNoDevices               = errors.New("No devices for the deployment")
 	ErrDuplicateDeployment     = errors.New("Deployment with given ID already exists")
@@ -104,6 +131,22 @@ var (
 		"Invalid deployment definition: there is already an active deployment with " +
 			"the same parameters",
 	)
+	ErrDownloadLinkTTLTooLong = errors.New(
+		"Invalid deployment definition: download_link_ttl exceeds the maximum allowed",
+	)
+	ErrDownloadLinkExpireTooLong = errors.New(
+		"Requested download link expiry exceeds the maximum allowed",
+	)
+	ErrNoCompatibleArtifact = errors.New(
+		"Invalid deployment definition: no artifact is compatible with the " +
+			"device types of the devices",
+	)
+	ErrArtifactPreconditionFailed = errors.New(
+		"None of the expected artifacts is available for the deployment",
+	)
+	ErrDeviceCountChanged = errors.New(
+		"The device count of the deployment changed while reconciling it, try again",
+	)
 )
 
 //deployments
@@ -113,7 +156,10 @@ type App interface {
 	HealthCheck(ctx context.Context) error
 	// limits
 	GetLimit(ctx context.Context, name string) (*model.Limit, error)
+	SetLimit(ctx context.Context, limit *model.Limit) error
 	ProvisionTenant(ctx context.Context, tenant_id string) error
+	CountActiveDeploymentsPerTenant(ctx context.Context,
+		query model.TenantActiveDeploymentsQuery) (*model.TenantActiveDeploymentsPage, error)
 
 	// Storage Settings
 	GetStorageSettings(ctx context.Context) (*model.StorageSettings, error)
@@ -124,23 +170,50 @@ type App interface {
 		ctx context.Context,
 		filters *model.ReleaseOrImageFilter,
 	) ([]*model.Image, int, error)
+	GetImagesListVersion(
+		ctx context.Context,
+		filters *model.ReleaseOrImageFilter,
+	) (*model.ListVersion, error)
 	DownloadLink(ctx context.Context, imageID string,
 		expire time.Duration) (*model.Link, error)
+	DownloadArtifact(ctx context.Context, image *model.Image,
+		offset, length int64) (io.ReadCloser, error)
 	UploadLink(
 		ctx context.Context,
 		expire time.Duration,
 		skipVerify bool,
+		size int64,
 	) (*model.UploadLink, error)
 	CompleteUpload(
 		ctx context.Context,
 		intentID string,
 		skipVerify bool,
 		metadata *model.DirectUploadMetadata,
+		expectedSHA256 string,
 	) error
+	CreateUploadSession(
+		ctx context.Context,
+		expire time.Duration,
+		skipVerify bool,
+	) (*model.UploadSession, error)
+	GetUploadSession(ctx context.Context, intentID string) (*model.UploadSession, error)
+	UploadPartLink(
+		ctx context.Context,
+		intentID string,
+		partNumber int,
+		expire time.Duration,
+	) (*model.Link, error)
+	ReportUploadPart(ctx context.Context, intentID string, part model.UploadPart) error
 	GetImage(ctx context.Context, id string) (*model.Image, error)
+	GetImageForDevice(ctx context.Context, deviceID, imageID string) (*model.Image, error)
 	DeleteImage(ctx context.Context, imageID string) error
+	ListArtifactDeletions(
+		ctx context.Context,
+		query model.ArtifactDeletionsQuery,
+	) ([]model.ArtifactDeletion, int, error)
 	CreateImage(ctx context.Context,
 		multipartUploadMsg *model.MultipartUploadMsg) (string, error)
+	UnknownArtifactDeviceTypes(ctx context.Context, artifactID string) ([]string, error)
 	GenerateImage(ctx context.Context,
 		multipartUploadMsg *model.MultipartGenerateImageMsg) (string, error)
 	GenerateConfigurationImage(
@@ -150,34 +223,79 @@ type App interface {
 	) (io.Reader, error)
 	EditImage(ctx context.Context, id string,
 		constructorData *model.ImageMeta) (bool, error)
+	ReplaceImage(ctx context.Context, imageID string,
+		multipartUploadMsg *model.MultipartUploadMsg) error
+	ReplaceImageTags(ctx context.Context, imageID string, tags model.Tags) error
+	ImportArtifacts(ctx context.Context,
+		req model.ArtifactImportRequest) (*model.ArtifactImportJob, error)
+	GetArtifactImportJob(ctx context.Context, id string) (*model.ArtifactImportJob, error)
 
 	// deployments
 	CreateDeployment(ctx context.Context,
 		constructor *model.DeploymentConstructor) (string, error)
+	PreviewDeployment(ctx context.Context,
+		constructor *model.DeploymentConstructor) (*model.DeploymentPreview, error)
 	GetDeployment(ctx context.Context, deploymentID string) (*model.Deployment, error)
+	DeleteDeployment(ctx context.Context, deploymentID string) error
+	RestoreDeployment(ctx context.Context, deploymentID string) error
+	StartDeployment(ctx context.Context, deploymentID string) error
+	UpdateDeployment(ctx context.Context,
+		deploymentID string, patch model.DeploymentPatch) error
 	IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error)
 	AbortDeployment(ctx context.Context, deploymentID string) error
+	AbortDeploymentsByArtifactName(
+		ctx context.Context,
+		artifactName string,
+	) (*model.AbortedDeployments, error)
 	GetDeploymentStats(ctx context.Context, deploymentID string) (model.Stats, error)
 	GetDeploymentsStats(ctx context.Context,
 		deploymentIDs ...string) ([]*model.DeploymentStats, error)
+	ReconcileDeviceCount(ctx context.Context,
+		deploymentID string) (*model.DeviceCountReconciliation, error)
 	GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string,
 		request *model.DeploymentNextRequest) (*model.DeploymentInstructions, error)
+	CheckDeploymentForDevice(ctx context.Context, deviceID string) (string, error)
 	HasDeploymentForDevice(ctx context.Context, deploymentID string,
 		deviceID string) (bool, error)
+	CountActiveDeploymentsByDevice(ctx context.Context, deviceID string) (int, error)
+	GetActiveDeploymentsForDevice(ctx context.Context,
+		deviceID string) ([]*model.Deployment, error)
 	UpdateDeviceDeploymentStatus(ctx context.Context, deploymentID string,
 		deviceID string, state model.DeviceDeploymentState) error
-	GetDeviceStatusesForDeployment(ctx context.Context,
-		deploymentID string) ([]model.DeviceDeployment, error)
+	StreamDeviceStatusesForDeployment(ctx context.Context, deploymentID string,
+		write func(*model.DeviceDeployment) error) error
 	GetDevicesListForDeployment(ctx context.Context,
 		query store.ListQuery) ([]model.DeviceDeployment, int, error)
+	StreamDevicesListForDeployment(ctx context.Context, query store.ListQuery,
+		write func(*model.DeviceDeployment) error) error
+	GetDeploymentTargetDevices(ctx context.Context,
+		deploymentID string, skip, limit int) ([]string, int, error)
+	GetFleetSoftwareInventory(ctx context.Context,
+		query model.SoftwareInventoryQuery) ([]model.DeviceSoftware, int, error)
+	GetDeploymentCreationTrend(ctx context.Context, from, to time.Time,
+		granularity model.TrendGranularity) ([]model.TrendBucket, error)
+	GetLargestDeployments(ctx context.Context, limit int) ([]*model.Deployment, error)
+	CountDeploymentsByStatus(ctx context.Context) (map[model.DeploymentStatus]int, error)
+	FindStaleActiveDeployments(ctx context.Context,
+		olderThan time.Time, limit int) ([]*model.Deployment, error)
+	GetComplianceReport(ctx context.Context, from, to time.Time,
+		skip, limit int) ([]model.DeploymentReport, int, error)
+	StreamComplianceReport(ctx context.Context, from, to time.Time,
+		write func(*model.DeploymentReport) error) error
 	GetDeviceDeploymentListForDevice(ctx context.Context,
 		query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentListItem, int, error)
+	GetDeviceDeploymentViewsForDevice(ctx context.Context,
+		query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error)
 	LookupDeployment(ctx context.Context,
 		query model.Query) ([]*model.Deployment, int64, error)
+	FindDeploymentsByGroup(ctx context.Context,
+		group string, query model.Query) ([]*model.Deployment, int64, error)
+	FindDeploymentsByArtifact(ctx context.Context,
+		name, id string, query model.Query) ([]*model.Deployment, int64, error)
 	SaveDeviceDeploymentLog(ctx context.Context, deviceID string,
 		deploymentID string, logs []model.LogMessage) error
 	GetDeviceDeploymentLog(ctx context.Context,
-		deviceID, deploymentID string) (*model.DeploymentLog, error)
+		query store.ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error)
 	AbortDeviceDeployments(ctx context.Context, deviceID string) error
 	DeleteDeviceDeploymentsHistory(ctx context.Context, deviceId string) error
 	DecommissionDevice(ctx context.Context, deviceID string) error
@@ -196,12 +314,28 @@ type App interface {
 		error,
 	)
 
+	// exports
+	ExportDeployments(ctx context.Context) (*model.ExportJob, error)
+	GetExportJob(ctx context.Context, id string) (*model.ExportJob, error)
+	RunExportJob(ctx context.Context, id string) error
+
 	// releases
 	ReplaceReleaseTags(ctx context.Context, releaseName string, tags model.Tags) error
 	UpdateRelease(ctx context.Context, releaseName string, release model.ReleasePatch) error
 	ListReleaseTags(ctx context.Context) (model.Tags, error)
 	GetReleasesUpdateTypes(ctx context.Context) ([]string, error)
+	GetReleasesUpdateTypesWithCounts(ctx context.Context) (map[string]int, error)
 	DeleteReleases(ctx context.Context, releaseNames []string) ([]string, error)
+	DeleteRelease(ctx context.Context, name string) error
+	GetReleaseRollout(ctx context.Context,
+		releaseName string, query model.Query) (*model.ReleaseRollout, int64, error)
+	ListOrphanedArtifacts(ctx context.Context, skip, limit int) ([]*model.Image, int, error)
+	ListArtifactsModifiedSince(ctx context.Context,
+		since time.Time, afterID string, skip, limit int) ([]*model.Image, error)
+
+	// maintenance mode
+	GetMaintenanceMode(ctx context.Context) (bool, error)
+	SetMaintenanceMode(ctx context.Context, enabled bool) error
 }
 
 type Deployments struct {
@@ -210,6 +344,119 @@ type Deployments struct {
 	workflowsClient workflows.Client
 	inventoryClient inventory.Client
 	reportingClient reporting.Client
+
+	// deletedRetention is how long deleted deployments can be restored
+	// before being purged; zero keeps them indefinitely.
+	deletedRetention time.Duration
+	// logsRetention is how long the logs of finished device deployments
+	// are kept before being purged; zero keeps them indefinitely.
+	logsRetention time.Duration
+	// groupCache holds the devices recently resolved for a device group;
+	// nil disables caching.
+	groupCache *groupCache
+	// maintenanceCache holds the maintenance mode recently read from the
+	// database; nil disables caching.
+	maintenanceCache *maintenanceModeCache
+	// downloadLimiters throttle the artifact downloads streamed through
+	// the service to the tenants' bandwidth limits.
+	downloadLimiters bandwidthLimiters
+	// deviceAttributes selects the inventory attributes attached to the
+	// reporting events of the device deployments; nil disables it.
+	deviceAttributes *deviceAttributes
+	// deploymentFinishedWorkflow is started when a deployment finishes;
+	// empty disables it.
+	deploymentFinishedWorkflow string
+	// autoFinish finishes the deployments without a device count to reach
+	// once none of their device deployments is active.
+	autoFinish bool
+	// generateLimiter bounds the concurrent generation of configuration
+	// artifacts; nil disables the limit.
+	generateLimiter *generateLimiter
+	// downloadLinkTTL is the validity of the artifact download links for
+	// the deployments not setting their own; downloadLinkMaxTTL caps it.
+	downloadLinkTTL    time.Duration
+	downloadLinkMaxTTL time.Duration
+	// downloadLinkMaxExpire caps the validity of the artifact download
+	// links requested through the management API.
+	downloadLinkMaxExpire time.Duration
+	// confirmationTimeout, if set, has the devices reporting success
+	// right after rebooting await a confirming status report for up to
+	// that long before the update is considered failed.
+	confirmationTimeout time.Duration
+	// importStaleTimeout is how long a running artifact import job may go
+	// without progress before it is considered interrupted.
+	importStaleTimeout time.Duration
+	// statusDedupInterval is how long the last update time of a device
+	// deployment is left as is when the device repeats its status.
+	statusDedupInterval time.Duration
+	// verificationKeys are matched against the signatures of the
+	// uploaded artifacts to record the signing key.
+	verificationKeys []*ArtifactVerificationKey
+	// deviceTypeCheck looks up the device types of the uploaded
+	// artifacts in the inventory.
+	deviceTypeCheck bool
+	// compatibleArtifactCheck rejects the deployments with no artifact
+	// compatible with the target devices.
+	compatibleArtifactCheck bool
+	// maxArtifactSize caps the size of the direct uploads; zero disables
+	// the check.
+	maxArtifactSize int64
+	// clearAssignedImages lets the images assigned to devices in active
+	// deployments be deleted, unassigning them, instead of refusing to.
+	clearAssignedImages bool
+	// dbName is the name of the default database, prefixing the tenant
+	// databases.
+	dbName string
+}
+
+// ChecksumMismatchError is returned when the checksum of an uploaded
+// artifact does not match the one provided by the client.
+type ChecksumMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (err *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf(
+		"artifact checksum mismatch: expected sha256 %s, computed %s",
+		err.Expected, err.Actual,
+	)
+}
+
+// ArtifactTooLargeError is returned when an uploaded artifact exceeds the
+// maximum artifact size.
+type ArtifactTooLargeError struct {
+	MaxSize int64
+}
+
+func (err *ArtifactTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"Artifact file too large: the maximum size is %d bytes", err.MaxSize,
+	)
+}
+
+// LimitExceededError is returned when an operation would exceed a tenant
+// limit.
+type LimitExceededError struct {
+	Name  string
+	Value uint64
+}
+
+func (err *LimitExceededError) Error() string {
+	return fmt.Sprintf("limit exceeded: %s (%d)", err.Name, err.Value)
+}
+
+// ArtifactKeysError is returned when an artifact provides, or depends on,
+// keys outside of the tenant's allowlist.
+type ArtifactKeysError struct {
+	Field string
+	Keys  []string
+}
+
+func (err *ArtifactKeysError) Error() string {
+	return fmt.Sprintf(
+		"artifact %s keys not allowed: %s", err.Field, strings.Join(err.Keys, ", "),
+	)
 }
 
 // Compile-time check
@@ -226,6 +473,9 @@ func NewDeployments(
 		objectStorage:   objectStorage,
 		workflowsClient: workflows.NewClient(),
 		inventoryClient: inventory.NewClient(),
+		dbName:          mongo.DbName,
+
+		importStaleTimeout: DefaultArtifactImportStaleTimeout,
 	}
 }
 
@@ -309,6 +559,85 @@ func (d *Deployments) GetLimit(ctx context.Context, name string) (*model.Limit,
 	return limit, nil
 }
 
+// checkReleasesLimit returns a LimitExceededError if an artifact of the
+// given release would create a release beyond the tenant's releases limit.
+func (d *Deployments) checkReleasesLimit(ctx context.Context, releaseName string) error {
+	limit, err := d.GetLimit(ctx, model.LimitReleases)
+	if err != nil {
+		return err
+	}
+	if limit.Value == 0 {
+		return nil
+	}
+	count, err := d.db.CountReleases(ctx)
+	if err != nil {
+		return err
+	}
+	if limit.IsLess(uint64(count)) {
+		return nil
+	}
+	// at the limit, only the existing releases can take more artifacts
+	_, err = d.db.GetRelease(ctx, releaseName, "")
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return &LimitExceededError{Name: model.LimitReleases, Value: limit.Value}
+}
+
+// artifactHeaderKeys are the provides and depends keys set from the header
+// of the artifacts, always allowed.
+var artifactHeaderKeys = map[string]bool{
+	"artifact_name":                 true,
+	"artifact_group":                true,
+	model.ArtifactDependsDeviceType: true,
+}
+
+// checkArtifactKeys returns an ArtifactKeysError if the artifact provides,
+// or depends on, keys outside of the tenant's allowlists.
+func (d *Deployments) checkArtifactKeys(ctx context.Context, meta *model.ArtifactMeta) error {
+	provides := make([]string, 0, len(meta.Provides))
+	for key := range meta.Provides {
+		provides = append(provides, key)
+	}
+	depends := make([]string, 0, len(meta.Depends))
+	for key := range meta.Depends {
+		depends = append(depends, key)
+	}
+	for _, check := range []struct {
+		field string
+		limit string
+		keys  []string
+	}{
+		{field: "provides", limit: model.LimitArtifactProvidesKeys, keys: provides},
+		{field: "depends", limit: model.LimitArtifactDependsKeys, keys: depends},
+	} {
+		limit, err := d.GetLimit(ctx, check.limit)
+		if err != nil {
+			return err
+		}
+		var denied []string
+		for _, key := range check.keys {
+			if !artifactHeaderKeys[key] && !limit.AllowsKey(key) {
+				denied = append(denied, key)
+			}
+		}
+		if len(denied) > 0 {
+			sort.Strings(denied)
+			return &ArtifactKeysError{Field: check.field, Keys: denied}
+		}
+	}
+	return nil
+}
+
+func (d *Deployments) SetLimit(ctx context.Context, limit *model.Limit) error {
+	if err := d.db.SetLimit(ctx, limit); err != nil {
+		return errors.Wrap(err, "failed to store limit")
+	}
+	return nil
+}
+
 func (d *Deployments) ProvisionTenant(ctx context.Context, tenant_id string) error {
 	if err := d.db.ProvisionTenant(ctx, tenant_id); err != nil {
 		return errors.Wrap(err, "failed to provision tenant")
@@ -317,6 +646,43 @@ func (d *Deployments) ProvisionTenant(ctx context.Context, tenant_id string) err
 	return nil
 }
 
+// CountActiveDeploymentsPerTenant counts the active deployments of a page of
+// the tenants, counting query.RateLimit tenants per second at most; the
+// tenants without active deployments are left out with query.SkipEmpty.
+func (d *Deployments) CountActiveDeploymentsPerTenant(
+	ctx context.Context,
+	query model.TenantActiveDeploymentsQuery,
+) (*model.TenantActiveDeploymentsPage, error) {
+	rateLimit := query.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = DefaultActiveDeploymentsCountRateLimit
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultActiveDeploymentsCountLimit
+	}
+	counts, err := d.db.CountActiveDeploymentsPerTenant(ctx,
+		query.After, limit, time.Second/time.Duration(rateLimit))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count the active deployments")
+	}
+	page := &model.TenantActiveDeploymentsPage{Tenants: counts}
+	// a full page may be followed by more tenants
+	if len(counts) == limit {
+		page.Next = counts[len(counts)-1].TenantID
+	}
+	if query.SkipEmpty {
+		active := counts[:0]
+		for _, count := range counts {
+			if count.ActiveCount > 0 {
+				active = append(active, count)
+			}
+		}
+		page.Tenants = active
+	}
+	return page, nil
+}
+
 // CreateImage parses artifact and uploads artifact file to the file storage - in parallel,
 // and creates image structure in the system.
 // Returns image ID and nil on success.
@@ -362,19 +728,104 @@ func (d *Deployments) handleArtifact(ctx context.Context,
 		return "", err
 	}
 
-	// create pipe
-	pR, pW := io.Pipe()
-
-	artifactReader := utils.CountReads(multipartUploadMsg.ArtifactReader)
-
-	tee := io.TeeReader(artifactReader, pW)
-
 	uid, err := uuid.Parse(multipartUploadMsg.ArtifactID)
 	if err != nil {
 		uid, _ = uuid.NewRandom()
 	}
 	artifactID := uid.String()
 
+	validMetadata := false
+	metaArtifactConstructor, size, err := d.uploadArtifact(
+		ctx,
+		multipartUploadMsg.ArtifactReader,
+		model.ImagePathFromContext(ctx, artifactID),
+		skipVerify,
+		multipartUploadMsg.ChecksumSHA256,
+		func(meta *model.ArtifactMeta) error {
+			if skipVerify && metadata != nil {
+				// this means we got files and metadata separately
+				// we can now put it in the metaArtifactConstructor
+				// after validating that the files information match the artifact
+				validMetadata = validUpdates(meta.Updates, metadata.Updates)
+				if validMetadata {
+					meta.Updates = metadata.Updates
+				}
+			}
+			// validate artifact metadata
+			if err := meta.Validate(); err != nil {
+				return ErrModelInvalidMetadata
+			}
+			if err := d.checkArtifactKeys(ctx, meta); err != nil {
+				return err
+			}
+			return d.checkReleasesLimit(ctx, meta.Name)
+		},
+	)
+	if err != nil {
+		return artifactID, err
+	}
+
+	if skipVerify && validMetadata {
+		size = metadata.Size
+	}
+	image := model.NewImage(
+		artifactID,
+		multipartUploadMsg.MetaConstructor,
+		metaArtifactConstructor,
+		size,
+	)
+
+	// save image structure in the system
+	if err = d.db.InsertImage(ctx, image); err != nil {
+		// Try to remove the storage from s3.
+		if errDelete := d.objectStorage.DeleteObject(
+			ctx, model.ImagePathFromContext(ctx, artifactID),
+		); errDelete != nil {
+			l.Errorf(
+				"failed to clean up artifact storage after failure: %s",
+				errDelete,
+			)
+		}
+		if idxErr, ok := err.(*model.ConflictError); ok {
+			return artifactID, idxErr
+		}
+		return artifactID, errors.Wrap(err, "Fail to store the metadata")
+	}
+	d.saveUpdateTypes(ctx, image)
+
+	// update release
+	if err := d.updateRelease(ctx, image, nil); err != nil {
+		return "", err
+	}
+
+	if err := d.UpdateDeploymentsWithArtifactName(ctx, metaArtifactConstructor.Name); err != nil {
+		return "", errors.Wrap(err, "fail to update deployments")
+	}
+
+	return artifactID, nil
+}
+
+// uploadArtifact parses the artifact read from r and uploads it to the file
+// storage at objectPath - in parallel. The check, if not nil, runs on the
+// parsed metadata before the upload completes; an error aborts the upload,
+// as does a SHA256 digest of the data not matching expectedSHA256, if set.
+// Returns the artifact metadata and the number of bytes read.
+func (d *Deployments) uploadArtifact(
+	ctx context.Context,
+	r io.Reader,
+	objectPath string,
+	skipVerify bool,
+	expectedSHA256 string,
+	check func(meta *model.ArtifactMeta) error,
+) (*model.ArtifactMeta, int64, error) {
+	// create pipe
+	pR, pW := io.Pipe()
+
+	hash := sha256.New()
+	artifactReader := utils.CountReads(io.TeeReader(r, hash))
+
+	tee := io.TeeReader(artifactReader, pW)
+
 	ch := make(chan error)
 	// create goroutine for artifact upload
 	//
@@ -390,9 +841,7 @@ func (d *Deployments) handleArtifact(ctx context.Context,
 			io.Copy(io.Discard, pR)
 			return nil
 		}
-		err = d.objectStorage.PutObject(
-			ctx, model.ImagePathFromContext(ctx, artifactID), pR,
-		)
+		err = d.objectStorage.PutObject(ctx, objectPath, pR)
 		if err != nil {
 			pR.CloseWithError(err)
 		}
@@ -401,28 +850,21 @@ func (d *Deployments) handleArtifact(ctx context.Context,
 
 	// parse artifact
 	// artifact library reads all the data from the given reader
-	metaArtifactConstructor, err := getMetaFromArchive(&tee, skipVerify)
+	meta, err := getMetaFromArchive(&tee, skipVerify, d.verificationKeys)
 	if err != nil {
 		_ = pW.CloseWithError(err)
 		<-ch
-		return artifactID, errors.Wrap(ErrModelParsingArtifactFailed, err.Error())
+		return nil, 0, errors.Wrap(ErrModelParsingArtifactFailed, err.Error())
 	}
-	validMetadata := false
-	if skipVerify && metadata != nil {
-		// this means we got files and metadata separately
-		// we can now put it in the metaArtifactConstructor
-		// after validating that the files information match the artifact
-		validMetadata = validUpdates(metaArtifactConstructor.Updates, metadata.Updates)
-		if validMetadata {
-			metaArtifactConstructor.Updates = metadata.Updates
+	if check != nil {
+		if err = check(meta); err != nil {
+			_ = pW.CloseWithError(err)
+			<-ch
+			return nil, 0, err
 		}
 	}
-	// validate artifact metadata
-	if err = metaArtifactConstructor.Validate(); err != nil {
-		return artifactID, ErrModelInvalidMetadata
-	}
 
-	if !skipVerify {
+	if !skipVerify || expectedSHA256 != "" {
 		// read the rest of the data,
 		// just in case the artifact library did not read all the data from the reader
 		_, err = io.Copy(io.Discard, tee)
@@ -430,7 +872,19 @@ func (d *Deployments) handleArtifact(ctx context.Context,
 			// CloseWithError will cause the reading end to abort upload.
 			_ = pW.CloseWithError(err)
 			<-ch
-			return artifactID, err
+			return nil, 0, err
+		}
+	}
+	if expectedSHA256 != "" {
+		actual := hex.EncodeToString(hash.Sum(nil))
+		if !strings.EqualFold(actual, expectedSHA256) {
+			err = &ChecksumMismatchError{
+				Expected: strings.ToLower(expectedSHA256),
+				Actual:   actual,
+			}
+			_ = pW.CloseWithError(err)
+			<-ch
+			return nil, 0, err
 		}
 	}
 
@@ -439,48 +893,9 @@ func (d *Deployments) handleArtifact(ctx context.Context,
 
 	// collect output from the goroutine
 	if uploadResponseErr := <-ch; uploadResponseErr != nil {
-		return artifactID, uploadResponseErr
-	}
-
-	size := artifactReader.Count()
-	if skipVerify && validMetadata {
-		size = metadata.Size
-	}
-	image := model.NewImage(
-		artifactID,
-		multipartUploadMsg.MetaConstructor,
-		metaArtifactConstructor,
-		size,
-	)
-
-	// save image structure in the system
-	if err = d.db.InsertImage(ctx, image); err != nil {
-		// Try to remove the storage from s3.
-		if errDelete := d.objectStorage.DeleteObject(
-			ctx, model.ImagePathFromContext(ctx, artifactID),
-		); errDelete != nil {
-			l.Errorf(
-				"failed to clean up artifact storage after failure: %s",
-				errDelete,
-			)
-		}
-		if idxErr, ok := err.(*model.ConflictError); ok {
-			return artifactID, idxErr
-		}
-		return artifactID, errors.Wrap(err, "Fail to store the metadata")
-	}
-	d.saveUpdateTypes(ctx, image)
-
-	// update release
-	if err := d.updateRelease(ctx, image, nil); err != nil {
-		return "", err
+		return nil, 0, uploadResponseErr
 	}
-
-	if err := d.UpdateDeploymentsWithArtifactName(ctx, metaArtifactConstructor.Name); err != nil {
-		return "", errors.Wrap(err, "fail to update deployments")
-	}
-
-	return artifactID, nil
+	return meta, artifactReader.Count(), nil
 }
 
 func validUpdates(constructorUpdates []model.Update, metadataUpdates []model.Update) bool {
@@ -534,7 +949,7 @@ func (d *Deployments) GenerateConfigurationImage(
 	deploymentID string,
 ) (io.Reader, error) {
 	var buf bytes.Buffer
-	dpl, err := d.db.FindDeploymentByID(ctx, deploymentID)
+	dpl, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
 	if err != nil {
 		return nil, err
 	} else if dpl == nil {
@@ -546,6 +961,14 @@ func (d *Deployments) GenerateConfigurationImage(
 		return nil, errors.Wrapf(err, "malformed configuration in deployment")
 	}
 
+	release, err := d.generateLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	artifactGenerationsInFlight.Inc()
+	defer artifactGenerationsInFlight.Dec()
+
 	artieWriter := awriter.NewWriter(&buf, artifact.NewCompressorNone())
 	module := handlers.NewModuleImage(ArtifactConfigureType)
 	err = artieWriter.WriteArtifact(&awriter.WriteArtifactArgs{
@@ -655,6 +1078,32 @@ func (d *Deployments) GetImage(ctx context.Context, id string) (*model.Image, er
 	return image, nil
 }
 
+// GetImageForDevice returns the image with the given id if it is the artifact
+// of the device deployment the device is currently getting; otherwise, it
+// returns ErrArtifactNotAssigned, whether or not the image exists.
+func (d *Deployments) GetImageForDevice(
+	ctx context.Context,
+	deviceID, imageID string,
+) (*model.Image, error) {
+	deviceDeployment, err := d.db.FindOldestActiveDeviceDeployment(ctx, deviceID)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			"Searching for oldest active deployment for the device")
+	} else if deviceDeployment == nil ||
+		deviceDeployment.Image == nil ||
+		deviceDeployment.Image.Id != imageID {
+		return nil, ErrArtifactNotAssigned
+	}
+
+	image, err := d.GetImage(ctx, imageID)
+	if err != nil {
+		return nil, err
+	} else if image == nil {
+		return nil, ErrArtifactNotAssigned
+	}
+	return image, nil
+}
+
 // DeleteImage removes metadata and image file
 // Noop for not existing images
 // Allowed to remove image only if image is not scheduled or in progress for an updates - then image
@@ -682,6 +1131,11 @@ func (d *Deployments) DeleteImage(ctx context.Context, imageID string) error {
 	if inUse {
 		return ErrModelImageInActiveDeployment
 	}
+	// the deployments list the images of the release at their creation:
+	// the devices may have been assigned an image uploaded later
+	if err := d.releaseAssignedImage(ctx, imageID); err != nil {
+		return err
+	}
 
 	// Delete image file (call to external service)
 	// Noop for not existing file
@@ -689,7 +1143,14 @@ func (d *Deployments) DeleteImage(ctx context.Context, imageID string) error {
 	if err != nil {
 		return err
 	}
-	imagePath := model.ImagePathFromContext(ctx, imageID)
+	// Record the deletion first: no image is deleted without a record
+	if err := d.db.InsertArtifactDeletion(
+		ctx, model.NewArtifactDeletion(ctx, found),
+	); err != nil {
+		return errors.Wrap(err, "Recording image deletion")
+	}
+
+	imagePath := found.ObjectPath(ctx)
 	if err := d.objectStorage.DeleteObject(ctx, imagePath); err != nil {
 		return errors.Wrap(err, "Deleting image file")
 	}
@@ -707,6 +1168,46 @@ func (d *Deployments) DeleteImage(ctx context.Context, imageID string) error {
 	return nil
 }
 
+// releaseAssignedImage refuses to delete the image if it is assigned to
+// devices in active deployments or, if configured to, unassigns it.
+func (d *Deployments) releaseAssignedImage(ctx context.Context, imageID string) error {
+	activeStatuses := model.ActiveDeploymentStatuses()
+	if d.clearAssignedImages {
+		cleared, err := d.db.ClearAssignedImage(ctx, imageID, activeStatuses)
+		if err != nil {
+			return errors.Wrap(err, "Unassigning image from device deployments")
+		}
+		if cleared > 0 {
+			log.FromContext(ctx).Infof(
+				"image %s unassigned from %d device deployments", imageID, cleared,
+			)
+		}
+		return nil
+	}
+	assigned, err := d.db.ExistAssignedImageWithIDAndStatuses(
+		ctx, imageID, activeStatuses,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Checking if image is assigned to devices")
+	} else if assigned {
+		return ErrModelImageInActiveDeployment
+	}
+	return nil
+}
+
+// ListArtifactDeletions returns a page of the artifact deletion audit
+// records, latest first, and the total number of records.
+func (d *Deployments) ListArtifactDeletions(
+	ctx context.Context,
+	query model.ArtifactDeletionsQuery,
+) ([]model.ArtifactDeletion, int, error) {
+	deletions, count, err := d.db.ListArtifactDeletions(ctx, query)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "retrieving the artifact deletions")
+	}
+	return deletions, count, nil
+}
+
 // ListImages according to specified filers.
 func (d *Deployments) ListImages(
 	ctx context.Context,
@@ -724,6 +1225,19 @@ func (d *Deployments) ListImages(
 	return imageList, count, nil
 }
 
+// GetImagesListVersion returns the version of the image list matching the
+// filters, changing whenever an image is added, modified or removed.
+func (d *Deployments) GetImagesListVersion(
+	ctx context.Context,
+	filters *model.ReleaseOrImageFilter,
+) (*model.ListVersion, error) {
+	version, err := d.db.GetImagesListVersion(ctx, filters)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for image metadata")
+	}
+	return version, nil
+}
+
 // EditObject allows editing only if image have not been used yet in any deployment.
 func (d *Deployments) EditImage(ctx context.Context, imageID string,
 	constructor *model.ImageMeta) (bool, error) {
@@ -765,11 +1279,41 @@ func (d *Deployments) EditImage(ctx context.Context, imageID string,
 	return true, nil
 }
 
-// DownloadLink presigned GET link to download image file.
+// ReplaceImageTags sets the tags of the artifact; the tags of its release
+// are not affected.
+func (d *Deployments) ReplaceImageTags(
+	ctx context.Context,
+	imageID string,
+	tags model.Tags,
+) error {
+	err := d.db.ReplaceImageTags(ctx, imageID, tags)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			err = ErrImageMetaNotFound
+
+		case model.ErrTooManyTags, model.ErrTooManyUniqueTags:
+			// pass
+
+		default:
+			log.FromContext(ctx).
+				Errorf("failed to replace artifact tags in database: %s", err.Error())
+			err = ErrModelInternal
+		}
+	}
+	return err
+}
+
+// DownloadLink presigned GET link to download image file, valid for expire
+// which must not exceed the maximum management download link validity.
 // Returns error if image have not been uploaded.
 func (d *Deployments) DownloadLink(ctx context.Context, imageID string,
 	expire time.Duration) (*model.Link, error) {
 
+	if expire > d.maxDownloadLinkExpire() {
+		return nil, ErrDownloadLinkExpireTooLong
+	}
+
 	image, err := d.GetImage(ctx, imageID)
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for image with specified ID")
@@ -783,7 +1327,7 @@ func (d *Deployments) DownloadLink(ctx context.Context, imageID string,
 	if err != nil {
 		return nil, err
 	}
-	imagePath := model.ImagePathFromContext(ctx, imageID)
+	imagePath := image.ObjectPath(ctx)
 	_, err = d.objectStorage.StatObject(ctx, imagePath)
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for image file")
@@ -802,22 +1346,91 @@ func (d *Deployments) DownloadLink(ctx context.Context, imageID string,
 	return link, nil
 }
 
-func (d *Deployments) UploadLink(
-	ctx context.Context,
-	expire time.Duration,
-	skipVerify bool,
-) (*model.UploadLink, error) {
+// throttledBody paces the reads from an artifact file, while closing the
+// original body.
+type throttledBody struct {
+	io.Reader
+	io.Closer
+}
+
+// DownloadArtifact opens the artifact file for reading length bytes starting
+// at offset; a negative length reads the whole file. The reads of all the
+// downloads of the tenant are throttled together to the tenant's download
+// bandwidth limit, if any.
+func (d *Deployments) DownloadArtifact(ctx context.Context, image *model.Image,
+	offset, length int64) (io.ReadCloser, error) {
+
 	ctx, err := d.contextWithStorageSettings(ctx)
 	if err != nil {
 		return nil, err
 	}
+	imagePath := image.ObjectPath(ctx)
+	bandwidth, err := d.GetLimit(ctx, model.LimitDownloadBandwidth)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	if length < 0 {
+		body, err = d.objectStorage.GetObject(ctx, imagePath)
+	} else {
+		body, err = d.objectStorage.GetObjectRange(ctx, imagePath, offset, length)
+	}
+	switch err {
+	case nil:
+		var tenantID string
+		if id := identity.FromContext(ctx); id != nil {
+			tenantID = id.Tenant
+		}
+		limiter := d.downloadLimiters.get(tenantID, int(bandwidth.Value))
+		if limiter != nil {
+			body = throttledBody{
+				Reader: utils.ThrottleReader(ctx, body, limiter),
+				Closer: body,
+			}
+		}
+		return body, nil
+	case storage.ErrObjectNotFound:
+		return nil, ErrUploadNotFound
+	case storage.ErrInvalidRange:
+		return nil, ErrArtifactRangeNotSatisfiable
+	default:
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, ErrUploadNotFound
+		} else if errors.Is(err, storage.ErrInvalidRange) {
+			return nil, ErrArtifactRangeNotSatisfiable
+		}
+		return nil, errors.Wrap(err, "Reading image file")
+	}
+}
+
+// UploadLink issues a link for uploading an artifact directly to the
+// storage. If the size of the artifact is given, the storage rejects uploads
+// of any other size; an oversized upload is otherwise only detected when
+// completed.
+func (d *Deployments) UploadLink(
+	ctx context.Context,
+	expire time.Duration,
+	skipVerify bool,
+	size int64,
+) (*model.UploadLink, error) {
+	maxSize, err := d.maxUploadSize(ctx)
+	if err != nil {
+		return nil, err
+	} else if maxSize > 0 && size > maxSize {
+		return nil, &ArtifactTooLargeError{MaxSize: maxSize}
+	}
+	ctx, err = d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	artifactID := uuid.New().String()
 	path := model.ImagePathFromContext(ctx, artifactID) + fileSuffixTmp
 	if skipVerify {
 		path = model.ImagePathFromContext(ctx, artifactID)
 	}
-	link, err := d.objectStorage.PutRequest(ctx, path, expire)
+	link, err := d.objectStorage.PutRequest(ctx, path, expire, size)
 	if err != nil {
 		return nil, errors.WithMessage(err, "app: failed to generate signed URL")
 	}
@@ -840,6 +1453,7 @@ func (d *Deployments) processUploadedArtifact(
 	artifact io.ReadCloser,
 	skipVerify bool,
 	metadata *model.DirectUploadMetadata,
+	expectedSHA256 string,
 ) error {
 	linkStatus := model.LinkStatusCompleted
 
@@ -873,6 +1487,7 @@ func (d *Deployments) processUploadedArtifact(
 	_, err := d.handleArtifact(ctx, &model.MultipartUploadMsg{
 		ArtifactID:     artifactID,
 		ArtifactReader: artifact,
+		ChecksumSHA256: expectedSHA256,
 	},
 		skipVerify,
 		metadata,
@@ -896,6 +1511,7 @@ func (d *Deployments) CompleteUpload(
 	intentID string,
 	skipVerify bool,
 	metadata *model.DirectUploadMetadata,
+	expectedSHA256 string,
 ) error {
 	l := log.FromContext(ctx)
 	idty := identity.FromContext(ctx)
@@ -903,6 +1519,16 @@ func (d *Deployments) CompleteUpload(
 	if err != nil {
 		return err
 	}
+	objectPath := model.ImagePathFromContext(ctx, intentID)
+	if !skipVerify {
+		objectPath += fileSuffixTmp
+	}
+	if err = d.assembleUploadParts(ctx, intentID); err != nil {
+		return err
+	}
+	if err = d.checkUploadSize(ctx, intentID, objectPath); err != nil {
+		return err
+	}
 	// Create an async context that doesn't cancel when server connection
 	// closes.
 	ctxAsync := context.Background()
@@ -911,18 +1537,7 @@ func (d *Deployments) CompleteUpload(
 
 	settings, _ := storage.SettingsFromContext(ctx)
 	ctxAsync = storage.SettingsWithContext(ctxAsync, settings)
-	var artifactReader io.ReadCloser
-	if skipVerify {
-		artifactReader, err = d.objectStorage.GetObject(
-			ctxAsync,
-			model.ImagePathFromContext(ctx, intentID),
-		)
-	} else {
-		artifactReader, err = d.objectStorage.GetObject(
-			ctxAsync,
-			model.ImagePathFromContext(ctx, intentID)+fileSuffixTmp,
-		)
-	}
+	artifactReader, err := d.objectStorage.GetObject(ctxAsync, objectPath)
 	if err != nil {
 		if errors.Is(err, storage.ErrObjectNotFound) {
 			return ErrUploadNotFound
@@ -947,11 +1562,63 @@ func (d *Deployments) CompleteUpload(
 		return err
 	}
 	go d.processUploadedArtifact( // nolint:errcheck
-		ctxAsync, intentID, artifactReader, skipVerify, metadata,
+		ctxAsync, intentID, artifactReader, skipVerify, metadata, expectedSHA256,
 	)
 	return nil
 }
 
+// maxUploadSize returns the maximum size of the direct uploads of the
+// tenant, or zero if unlimited.
+func (d *Deployments) maxUploadSize(ctx context.Context) (int64, error) {
+	limit, err := d.GetLimit(ctx, model.LimitArtifactSize)
+	if err != nil {
+		return 0, err
+	}
+	return limit.Lower(d.maxArtifactSize), nil
+}
+
+// checkUploadSize aborts the upload, and removes the uploaded file, if it
+// exceeds the maximum artifact size of the tenant.
+func (d *Deployments) checkUploadSize(
+	ctx context.Context,
+	intentID string,
+	objectPath string,
+) error {
+	maxSize, err := d.maxUploadSize(ctx)
+	if err != nil {
+		return err
+	} else if maxSize <= 0 {
+		return nil
+	}
+	info, err := d.objectStorage.StatObject(ctx, objectPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return ErrUploadNotFound
+		}
+		return err
+	}
+	if info.Size == nil || *info.Size <= maxSize {
+		return nil
+	}
+	if err = d.objectStorage.DeleteObject(ctx, objectPath); err != nil {
+		log.FromContext(ctx).
+			Warnf("failed to remove the oversized upload %s: %s", intentID, err)
+	}
+	err = d.db.UpdateUploadIntentStatus(
+		ctx,
+		intentID,
+		model.LinkStatusPending,
+		model.LinkStatusAborted,
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUploadNotFound
+		}
+		return err
+	}
+	return &ArtifactTooLargeError{MaxSize: maxSize}
+}
+
 func getArtifactInfo(info artifact.Info) *model.ArtifactInfo {
 	return &model.ArtifactInfo{
 		Format:  info.Format,
@@ -972,15 +1639,35 @@ func getUpdateFiles(uFiles []*handlers.DataFile) ([]model.UpdateFile, error) {
 	return files, nil
 }
 
-func getMetaFromArchive(r *io.Reader, skipVerify bool) (*model.ArtifactMeta, error) {
+func getArtifactCompression(compressor artifact.Compressor) string {
+	switch compressor.(type) {
+	case *artifact.CompressorNone:
+		return model.ArtifactCompressionNone
+	case *artifact.CompressorGzip:
+		return model.ArtifactCompressionGzip
+	case *artifact.CompressorLzma:
+		return model.ArtifactCompressionLzma
+	case *artifact.CompressorZstd:
+		return model.ArtifactCompressionZstd
+	default:
+		return model.ArtifactCompressionUnknown
+	}
+}
+
+func getMetaFromArchive(
+	r *io.Reader,
+	skipVerify bool,
+	keys []*ArtifactVerificationKey,
+) (*model.ArtifactMeta, error) {
 	metaArtifact := model.NewArtifactMeta()
 
 	aReader := areader.NewReader(*r)
 
-	// There is no signature verification here.
-	// It is just simple check if artifact is signed or not.
+	// The signature is not enforced here: the artifact is only flagged
+	// as signed, along with the known key it verifies with, if any.
 	aReader.VerifySignatureCallback = func(message, sig []byte) error {
 		metaArtifact.Signed = true
+		metaArtifact.SignerKeyFingerprint = signerFingerprint(keys, message, sig)
 		return nil
 	}
 
@@ -998,6 +1685,7 @@ func getMetaFromArchive(r *io.Reader, skipVerify bool) (*model.ArtifactMeta, err
 	}
 
 	metaArtifact.Info = getArtifactInfo(aReader.GetInfo())
+	metaArtifact.Compression = getArtifactCompression(aReader.Compressor())
 	metaArtifact.DeviceTypesCompatible = aReader.GetCompatibleDevices()
 
 	metaArtifact.Name = aReader.GetArtifactName()
@@ -1050,6 +1738,19 @@ func getArtifactIDs(artifacts []*model.Image) []string {
 	return artifactIDs
 }
 
+// matchExpectedArtifacts reports whether one of the artifacts has one of the
+// expected IDs, "*" matching any artifact.
+func matchExpectedArtifacts(artifacts []*model.Image, expected []string) bool {
+	for _, id := range expected {
+		for _, artifact := range artifacts {
+			if id == "*" || id == artifact.Id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // deployments
 func inventoryDevicesToDevicesIds(devices []model.InvDevice) []string {
 	ids := make([]string, len(devices))
@@ -1083,6 +1784,10 @@ func (d *Deployments) updateDeploymentConstructor(ctx context.Context,
 		},
 	}
 	if len(constructor.Group) > 0 {
+		if devices, ok := d.groupCache.get(ctx, id.Tenant, constructor.Group); ok {
+			constructor.Devices = append(constructor.Devices, devices...)
+			return constructor, nil
+		}
 		searchParams.Filters = append(
 			searchParams.Filters,
 			model.FilterPredicate{
@@ -1092,6 +1797,7 @@ func (d *Deployments) updateDeploymentConstructor(ctx context.Context,
 				Value:     constructor.Group,
 			})
 	}
+	searchParams.Filters = append(searchParams.Filters, constructor.Filter...)
 
 	for {
 		devices, count, err := d.search(ctx, id.Tenant, searchParams)
@@ -1112,6 +1818,9 @@ func (d *Deployments) updateDeploymentConstructor(ctx context.Context,
 		}
 		searchParams.Page++
 	}
+	if len(constructor.Group) > 0 {
+		d.groupCache.set(id.Tenant, constructor.Group, constructor.Devices)
+	}
 
 	return constructor, nil
 }
@@ -1170,35 +1879,42 @@ func (d *Deployments) CreateDeployment(ctx context.Context,
 	if err := constructor.Validate(); err != nil {
 		return "", errors.Wrap(err, "Validating deployment")
 	}
+	// compared in seconds: the TTL could overflow a duration
+	if constructor.DownloadLinkTTL > uint(d.maxDownloadLinkTTL()/time.Second) {
+		return "", ErrDownloadLinkTTLTooLong
+	}
 
-	if len(constructor.Group) > 0 || constructor.AllDevices {
+	if len(constructor.Group) > 0 || constructor.AllDevices || len(constructor.Filter) > 0 {
 		constructor, err = d.updateDeploymentConstructor(ctx, constructor)
 		if err != nil {
 			return "", err
 		}
 	}
+	// Duplicates would create one device deployment per occurrence and
+	// inflate the device count.
+	constructor.RemoveDuplicateDevices()
 
 	deployment, err := model.NewDeploymentFromConstructor(constructor)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create deployment")
 	}
+	if idty := identity.FromContext(ctx); idty != nil {
+		deployment.CreatedBy = idty.Subject
+	}
 
 	// Assign artifacts to the deployment.
 	// When new artifact(s) with the artifact name same as the one in the deployment
 	// will be uploaded to the backend, it will also become part of this deployment.
-	artifacts, err := d.db.ImagesByName(ctx, deployment.ArtifactName)
+	artifacts, err := d.deploymentArtifacts(ctx, constructor)
 	if err != nil {
-		return "", errors.Wrap(err, "Finding artifact with given name")
-	}
-
-	if len(artifacts) == 0 {
-		return "", ErrNoArtifact
+		return "", err
 	}
 
 	deployment.Artifacts = getArtifactIDs(artifacts)
 	deployment.DeviceList = constructor.Devices
 	deployment.MaxDevices = len(constructor.Devices)
 	deployment.Type = model.DeploymentTypeSoftware
+	schedulePhases(deployment)
 	if len(constructor.Group) > 0 {
 		deployment.Groups = []string{constructor.Group}
 	}
@@ -1222,6 +1938,70 @@ func (d *Deployments) CreateDeployment(ctx context.Context,
 	return deployment.Id, nil
 }
 
+// deploymentArtifacts returns the artifacts named in the constructor,
+// checking that there is one, that the expected artifacts match and, if
+// enabled, that the devices are compatible.
+func (d *Deployments) deploymentArtifacts(
+	ctx context.Context,
+	constructor *model.DeploymentConstructor,
+) ([]*model.Image, error) {
+	artifacts, err := d.db.ImagesByName(ctx, constructor.ArtifactName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Finding artifact with given name")
+	}
+
+	if len(constructor.ExpectedArtifacts) > 0 &&
+		!matchExpectedArtifacts(artifacts, constructor.ExpectedArtifacts) {
+		return nil, ErrArtifactPreconditionFailed
+	}
+	if len(artifacts) == 0 {
+		return nil, ErrNoArtifact
+	}
+	if d.compatibleArtifactCheck {
+		if err := d.checkCompatibleArtifact(ctx, artifacts, constructor.Devices); err != nil {
+			return nil, err
+		}
+	}
+	return artifacts, nil
+}
+
+// PreviewDeployment resolves the devices the deployment would target and
+// checks its artifacts as CreateDeployment does, without storing anything.
+func (d *Deployments) PreviewDeployment(ctx context.Context,
+	constructor *model.DeploymentConstructor) (*model.DeploymentPreview, error) {
+	var err error
+
+	if constructor == nil {
+		return nil, ErrModelMissingInput
+	}
+	if err := constructor.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Validating deployment")
+	}
+	if constructor.DownloadLinkTTL > uint(d.maxDownloadLinkTTL()/time.Second) {
+		return nil, ErrDownloadLinkTTLTooLong
+	}
+
+	if len(constructor.Group) > 0 || constructor.AllDevices || len(constructor.Filter) > 0 {
+		constructor, err = d.updateDeploymentConstructor(ctx, constructor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	constructor.RemoveDuplicateDevices()
+	if _, err := d.deploymentArtifacts(ctx, constructor); err != nil {
+		return nil, err
+	}
+
+	sample := constructor.Devices
+	if len(sample) > DeploymentPreviewSampleSize {
+		sample = sample[:DeploymentPreviewSampleSize]
+	}
+	return &model.DeploymentPreview{
+		DeviceCount: len(constructor.Devices),
+		Devices:     append([]string{}, sample...),
+	}, nil
+}
+
 func (d *Deployments) getDeploymentGroups(
 	ctx context.Context,
 	devices []string,
@@ -1260,11 +2040,100 @@ func (d *Deployments) IsDeploymentFinished(
 	return false, nil
 }
 
+// DeleteDeployment marks the deployment as deleted; an unfinished deployment
+// is aborted first, so that its devices stop getting it. The deployment can
+// be restored until it is purged by the storage daemon.
+func (d *Deployments) DeleteDeployment(ctx context.Context, deploymentID string) error {
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment by ID")
+	} else if deployment == nil {
+		return ErrModelDeploymentNotFound
+	}
+
+	if deployment.Active {
+		if err := d.AbortDeployment(ctx, deploymentID); err != nil {
+			return errors.Wrap(err, "aborting the deployment")
+		}
+	}
+
+	if err := d.db.DeleteDeployment(ctx, deploymentID); err != nil {
+		return errors.Wrap(err, "deleting the deployment")
+	}
+	return nil
+}
+
+// RestoreDeployment undoes the deletion of a deployment which has not been
+// purged yet.
+func (d *Deployments) RestoreDeployment(ctx context.Context, deploymentID string) error {
+	var deletedAfter time.Time
+	if d.deletedRetention > 0 {
+		deletedAfter = time.Now().Add(-d.deletedRetention)
+	}
+	err := d.db.RestoreDeployment(ctx, deploymentID, deletedAfter)
+	if err == store.ErrNotFound {
+		return ErrModelDeploymentNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "restoring the deployment")
+	}
+	return nil
+}
+
+// StartDeployment starts a deployment created paused; its devices get it
+// from their next check for an update, as for a deployment created now.
+func (d *Deployments) StartDeployment(ctx context.Context, deploymentID string) error {
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment by ID")
+	} else if deployment == nil {
+		return ErrModelDeploymentNotFound
+	} else if deployment.Status != model.DeploymentStatusPaused {
+		return ErrDeploymentNotPaused
+	}
+
+	started := time.Now().UTC()
+	var phases model.DeploymentPhases
+	if deployment.IsPhased() {
+		phases = deployment.Phases
+		if phases[0].StartTs == nil {
+			// the first phase starts with the deployment
+			phases[0].StartTs = &started
+		}
+	}
+	err = d.db.StartDeployment(ctx, deploymentID, started, phases)
+	if err == store.ErrNotFound {
+		// started or aborted in the meantime
+		return ErrDeploymentNotPaused
+	} else if err != nil {
+		return errors.Wrap(err, "starting the deployment")
+	}
+	return nil
+}
+
+// UpdateDeployment edits the name and the description of the deployment.
+func (d *Deployments) UpdateDeployment(
+	ctx context.Context,
+	deploymentID string,
+	patch model.DeploymentPatch,
+) error {
+	if err := patch.Validate(); err != nil {
+		return errors.Wrap(err, "Validating deployment patch")
+	}
+
+	err := d.db.UpdateDeployment(ctx, deploymentID, patch)
+	if err == store.ErrNotFound {
+		return ErrModelDeploymentNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "updating the deployment")
+	}
+	return nil
+}
+
 // GetDeployment fetches deployment by ID
 func (d *Deployments) GetDeployment(ctx context.Context,
 	deploymentID string) (*model.Deployment, error) {
 
-	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID)
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for deployment by ID")
 	}
@@ -1272,6 +2141,7 @@ func (d *Deployments) GetDeployment(ctx context.Context,
 	if err := d.setDeploymentDeviceCountIfUnset(ctx, deployment); err != nil {
 		return nil, err
 	}
+	refreshCurrentPhase(deployment)
 
 	return deployment, nil
 }
@@ -1306,29 +2176,75 @@ func (d *Deployments) ImageUsedInDeployment(ctx context.Context, imageID string)
 }
 
 // Retrieves the model.Deployment and model.DeviceDeployment structures
-// for the device. Upon error, nil is returned for both deployment structures.
+// for the device. The oldest active device deployment is claimed: if still
+// pending, it is moved to downloading in the same round trip, and claimed
+// is true for the only request which moved it. Upon error, nil is returned
+// for both deployment structures.
 func (d *Deployments) getDeploymentForDevice(ctx context.Context,
-	deviceID string) (*model.Deployment, *model.DeviceDeployment, error) {
-
-	// Retrieve device deployment
-	deviceDeployment, err := d.db.FindOldestActiveDeviceDeployment(ctx, deviceID)
+	deviceID string) (*model.Deployment, *model.DeviceDeployment, bool, error) {
 
+	// Retrieve and claim device deployment
+	deviceDeployment, prevStatus, err := d.db.FindAndClaimOldestActiveDeviceDeployment(
+		ctx, deviceID,
+	)
 	if err != nil {
-		return nil, nil, errors.Wrap(err,
+		return nil, nil, false, errors.Wrap(err,
 			"Searching for oldest active deployment for the device")
 	} else if deviceDeployment == nil {
-		return d.getNewDeploymentForDevice(ctx, deviceID)
+		deployment, _, err := d.getNewDeploymentForDevice(ctx, deviceID)
+		if err != nil || deployment == nil {
+			return nil, nil, false, err
+		}
+		// claim the device deployment just created
+		return d.getDeploymentForDevice(ctx, deviceID)
 	}
+	claimed := prevStatus == model.DeviceDeploymentStatusPending
 
-	deployment, err := d.db.FindDeploymentByID(ctx, deviceDeployment.DeploymentId)
+	deployment, err := d.db.FindDeploymentByID(ctx, deviceDeployment.DeploymentId, false)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "checking deployment id")
+		return nil, nil, false, errors.Wrap(err, "checking deployment id")
 	}
 	if deployment == nil {
-		return nil, nil, errors.New("No deployment corresponding to device deployment")
+		// the deployment was deleted while the device was still enrolled;
+		// abort what is left of it and look for the next one
+		now := time.Now()
+		_, err = d.db.UpdateDeviceDeploymentStatus(ctx, deviceID,
+			deviceDeployment.DeploymentId, model.DeviceDeploymentState{
+				Status:     model.DeviceDeploymentStatusAborted,
+				FinishTime: &now,
+			}, deviceDeployment.Status)
+		if err != nil && err != mongo.ErrStorageNotFound {
+			return nil, nil, false, errors.Wrap(err,
+				"aborting the device deployment of a deleted deployment")
+		}
+		return d.getDeploymentForDevice(ctx, deviceID)
 	}
 
-	return deployment, deviceDeployment, nil
+	return deployment, deviceDeployment, claimed, nil
+}
+
+// countClaimedDeviceDeployment moves the device deployment claimed by the
+// device from pending to downloading in the deployment stats, updating the
+// deployment status accordingly. It returns false, leaving the stats
+// unchanged, if the deployment is at its concurrency cap.
+func (d *Deployments) countClaimedDeviceDeployment(
+	ctx context.Context,
+	deployment *model.Deployment,
+) (bool, error) {
+	beforeStatus := deployment.GetStatus()
+	stats, ok, err := d.claimUpdateSlot(ctx, deployment)
+	if err != nil || !ok {
+		return false, err
+	}
+	deployment.Stats = stats
+	newStatus := d.deploymentStatusAfterUpdate(deployment)
+	if beforeStatus != newStatus {
+		err = d.setDeploymentStatus(ctx, deployment.Id, newStatus)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to update deployment status")
+		}
+	}
+	return true, nil
 }
 
 // getNewDeploymentForDevice returns deployment object and creates and returns
@@ -1361,6 +2277,12 @@ func (d *Deployments) getNewDeploymentForDevice(ctx context.Context,
 		return nil, nil, errors.Wrap(err, "Failed to search for newer active deployments")
 	}
 	if deploy != nil {
+		// devices of the phases yet to start get no deployment for now
+		if ok, err := d.deviceInActivePhase(ctx, deploy, deviceID); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			return nil, nil, nil
+		}
 		deviceDeployment, err := d.createDeviceDeploymentWithStatus(ctx,
 			deviceID, deploy, model.DeviceDeploymentStatusPending)
 		if err != nil {
@@ -1386,7 +2308,9 @@ func (d *Deployments) createDeviceDeploymentWithStatus(
 	deviceDeployment = model.NewDeviceDeployment(deviceID, deployment.Id)
 	deviceDeployment.Status = status
 	deviceDeployment.Active = status.Active()
-	deviceDeployment.Created = deployment.Created
+	// the devices get the deployments newer than their last one: a
+	// deployment started after being created paused counts from its start
+	deviceDeployment.Created = deployment.ActiveSince()
 
 	if err := d.setDeploymentDeviceCountIfUnset(ctx, deployment); err != nil {
 		return nil, err
@@ -1408,12 +2332,9 @@ func (d *Deployments) createDeviceDeploymentWithStatus(
 		if err != nil {
 			return nil, err
 		}
-		newStatus := deployment.GetStatus()
+		newStatus := d.deploymentStatusAfterUpdate(deployment)
 		if beforeStatus != newStatus {
-			err = d.db.SetDeploymentStatus(
-				ctx, deployment.Id,
-				newStatus, time.Now(),
-			)
+			err = d.setDeploymentStatus(ctx, deployment.Id, newStatus)
 			if err != nil {
 				return nil, errors.Wrap(err,
 					"failed to update deployment status")
@@ -1437,22 +2358,102 @@ func (d *Deployments) createDeviceDeploymentWithStatus(
 	return deviceDeployment, nil
 }
 
+// GetActiveDeploymentsForDevice returns the unfinished deployments targeting
+// the device: the ones it is enrolled in, and the ones it will pick up when
+// it next checks for an update, oldest first.
+func (d *Deployments) GetActiveDeploymentsForDevice(ctx context.Context,
+	deviceID string) ([]*model.Deployment, error) {
+
+	// the device picks up only the deployments newer than its last one
+	lastDeployment := &time.Time{}
+	deviceDeployment, err := d.db.FindLatestInactiveDeviceDeployment(ctx, deviceID)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			"Searching for latest active deployment for the device")
+	} else if deviceDeployment != nil {
+		lastDeployment = deviceDeployment.Created
+	}
+	deployments, err := d.db.FindActiveDeploymentsForDevice(ctx, deviceID, lastDeployment)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for active deployments for the device")
+	}
+	for _, deployment := range deployments {
+		refreshCurrentPhase(deployment)
+	}
+	return deployments, nil
+}
+
+// CheckDeploymentForDevice returns the ID of a deployment pending for the
+// device, or an empty string if there is none. Unlike
+// GetDeploymentForDeviceWithCurrent, it neither assigns the device to new
+// deployments nor prepares the deployment instructions.
+func (d *Deployments) CheckDeploymentForDevice(ctx context.Context,
+	deviceID string) (string, error) {
+
+	deploymentID, err := d.db.FindOldestActiveDeviceDeploymentID(ctx, deviceID)
+	if err != nil {
+		return "", errors.Wrap(err,
+			"Searching for oldest active deployment for the device")
+	} else if deploymentID != "" {
+		return deploymentID, nil
+	}
+
+	lastDeployment := &time.Time{}
+	deviceDeployment, err := d.db.FindLatestInactiveDeviceDeployment(ctx, deviceID)
+	if err != nil {
+		return "", errors.Wrap(err,
+			"Searching for latest active deployment for the device")
+	} else if deviceDeployment != nil {
+		lastDeployment = deviceDeployment.Created
+	}
+	deployment, err := d.db.FindNewerActiveDeployment(ctx, lastDeployment, deviceID)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to search for newer active deployments")
+	} else if deployment != nil {
+		if ok, err := d.deviceInActivePhase(ctx, deployment, deviceID); err != nil || !ok {
+			return "", err
+		}
+		return deployment.Id, nil
+	}
+	return "", nil
+}
+
 // GetDeploymentForDeviceWithCurrent returns deployment for the device
 func (d *Deployments) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string,
-	request *model.DeploymentNextRequest) (*model.DeploymentInstructions, error) {
+	request *model.DeploymentNextRequest) (instructions *model.DeploymentInstructions, err error) {
+	var (
+		deployment *model.Deployment
+		first      bool
+	)
+	defer func(start time.Time) {
+		observeDeviceNext(time.Since(start), deployment, first, instructions, err)
+	}(time.Now())
 
-	deployment, deviceDeployment, err := d.getDeploymentForDevice(ctx, deviceID)
+	deployment, deviceDeployment, claimed, err := d.getDeploymentForDevice(ctx, deviceID)
 	if err != nil {
 		return nil, ErrModelInternal
 	} else if deployment == nil {
 		return nil, nil
 	}
+	first = deviceDeployment.Request == nil
+	if deviceDeployment.Status == model.DeviceDeploymentStatusAwaitingConfirmation {
+		// the device is done with this deployment, but for the confirmation
+		return nil, d.handleAwaitingConfirmation(ctx, request, deviceDeployment)
+	}
+	if claimed {
+		// pending devices wait while the deployment is at its concurrency cap
+		if ok, err := d.countClaimedDeviceDeployment(ctx, deployment); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, d.unclaimDeviceDeployment(ctx, deviceDeployment)
+		}
+	}
 
 	err = d.saveDeviceDeploymentRequest(ctx, deviceID, deviceDeployment, request)
 	if err != nil {
 		return nil, err
 	}
-	return d.getDeploymentInstructions(ctx, deployment, deviceDeployment, request)
+	return d.getDeploymentInstructions(ctx, deployment, deviceDeployment, request, claimed)
 }
 
 func (d *Deployments) getDeploymentInstructions(
@@ -1460,6 +2461,7 @@ func (d *Deployments) getDeploymentInstructions(
 	deployment *model.Deployment,
 	deviceDeployment *model.DeviceDeployment,
 	request *model.DeploymentNextRequest,
+	claimed bool,
 ) (*model.DeploymentInstructions, error) {
 
 	var newArtifactAssigned bool
@@ -1499,11 +2501,11 @@ func (d *Deployments) getDeploymentInstructions(
 
 	// if the deployment is not forcing the installation, and
 	// if artifact was recognized as already installed, and this is
-	// a new device deployment - indicated by device deployment status "pending",
-	// handle already installed artifact case
+	// a new device deployment - indicated by the device deployment just
+	// claimed from "pending", handle already installed artifact case
 	if !deployment.ForceInstallation &&
 		d.isAlreadyInstalled(request, deviceDeployment) &&
-		deviceDeployment.Status == model.DeviceDeploymentStatusPending {
+		claimed {
 		return nil, d.handleAlreadyInstalled(ctx, deviceDeployment)
 	}
 
@@ -1522,12 +2524,12 @@ func (d *Deployments) getDeploymentInstructions(
 		return nil, err
 	}
 
-	imagePath := model.ImagePathFromContext(ctx, deviceDeployment.Image.Id)
+	imagePath := deviceDeployment.Image.ObjectPath(ctx)
 	link, err := d.objectStorage.GetRequest(
 		ctx,
 		imagePath,
 		deviceDeployment.Image.Name+model.ArtifactFileSuffix,
-		DefaultUpdateDownloadLinkExpire,
+		d.downloadLinkExpire(deployment),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "Generating download link for the device")
@@ -1599,6 +2601,14 @@ func (d *Deployments) UpdateDeviceDeploymentStatus(
 		ctx, deviceID, deploymentID, false,
 	)
 	if err == mongo.ErrStorageNotFound {
+		// tell apart deployments which never existed from deleted ones,
+		// so that the device can be told to stop reporting
+		deviceDeployment, err = d.db.GetDeviceDeployment(
+			ctx, deviceID, deploymentID, true,
+		)
+		if err == nil && deviceDeployment.Deleted != nil {
+			return ErrDeploymentDeleted
+		}
 		return ErrStorageNotFound
 	} else if err != nil {
 		return err
@@ -1619,6 +2629,14 @@ func (d *Deployments) updateDeviceDeploymentStatus(
 		ddState.Status, dd.DeviceId, dd.DeploymentId,
 	)
 
+	if d.confirmationTimeout > 0 &&
+		dd.Status == model.DeviceDeploymentStatusRebooting &&
+		ddState.Status == model.DeviceDeploymentStatusSuccess {
+		deadline := time.Now().Add(d.confirmationTimeout)
+		ddState.Status = model.DeviceDeploymentStatusAwaitingConfirmation
+		ddState.ConfirmationDeadline = &deadline
+	}
+
 	var finishTime *time.Time = nil
 	if model.IsDeviceDeploymentStatusFinished(ddState.Status) {
 		now := time.Now()
@@ -1635,9 +2653,8 @@ func (d *Deployments) updateDeviceDeploymentStatus(
 		return ErrDeviceDecommissioned
 	}
 
-	// nothing to do
 	if ddState.Status == currentStatus {
-		return nil
+		return d.repeatDeviceDeploymentStatus(ctx, dd, ddState)
 	}
 
 	// update finish time
@@ -1651,7 +2668,7 @@ func (d *Deployments) updateDeviceDeploymentStatus(
 
 	if old != ddState.Status {
 		// fetch deployment stats and update deployment status
-		deployment, err := d.db.FindDeploymentByID(ctx, dd.DeploymentId)
+		deployment, err := d.db.FindDeploymentByID(ctx, dd.DeploymentId, true)
 		if err != nil {
 			return errors.Wrap(err, "failed when searching for deployment")
 		}
@@ -1661,13 +2678,33 @@ func (d *Deployments) updateDeviceDeploymentStatus(
 		if err != nil {
 			return err
 		}
-		newStatus := deployment.GetStatus()
+		if ddState.Status == model.DeviceDeploymentStatusFailure &&
+			deployment.DeploymentConstructor != nil &&
+			dd.Attempts < deployment.Retries {
+			retried, err := d.retryDeviceDeployment(ctx, dd, deployment)
+			if err != nil {
+				return err
+			} else if retried {
+				ddState.Status = model.DeviceDeploymentStatusPending
+			}
+		}
+		newStatus := d.deploymentStatusAfterUpdate(deployment)
 		if beforeStatus != newStatus {
-			err = d.db.SetDeploymentStatus(ctx, dd.DeploymentId, newStatus, time.Now())
+			err = d.setDeploymentStatus(ctx, dd.DeploymentId, newStatus)
 			if err != nil {
 				return errors.Wrap(err, "failed to update deployment status")
 			}
 		}
+		if ddState.Status == model.DeviceDeploymentStatusFailure &&
+			deployment.Rollback == nil &&
+			deployment.FailureThresholdCrossed() {
+			// the status of the device is updated already, whatever
+			// happens to the rollback
+			if err := d.triggerDeploymentRollback(ctx, deployment); err != nil {
+				l.Errorf("failed to roll back deployment %s: %s",
+					dd.DeploymentId, err)
+			}
+		}
 	}
 
 	if !ddState.Status.Active() {
@@ -1692,10 +2729,57 @@ func (d *Deployments) updateDeviceDeploymentStatus(
 	return nil
 }
 
+// repeatDeviceDeploymentStatus handles a status report not changing the
+// status: the stats are left alone, a new substate is stored, and
+// otherwise only the last update time is refreshed once in a while.
+func (d *Deployments) repeatDeviceDeploymentStatus(
+	ctx context.Context,
+	dd *model.DeviceDeployment,
+	ddState model.DeviceDeploymentState,
+) error {
+	if ddState.SubState != "" && ddState.SubState != dd.SubState {
+		_, err := d.db.UpdateDeviceDeploymentStatus(ctx,
+			dd.DeviceId, dd.DeploymentId, ddState, dd.Status)
+		return err
+	}
+	if dd.LastUpdated != nil && time.Since(*dd.LastUpdated) < d.statusDedupInterval {
+		return nil
+	}
+	return d.db.TouchDeviceDeployment(ctx, dd.DeviceId, dd.DeploymentId, dd.Status)
+}
+
+// retryDeviceDeployment reschedules the failed device deployment if the
+// deployment allows for more attempts, updating the deployment stats.
+func (d *Deployments) retryDeviceDeployment(
+	ctx context.Context,
+	dd *model.DeviceDeployment,
+	deployment *model.Deployment,
+) (bool, error) {
+	retried, err := d.db.RetryDeviceDeployment(
+		ctx, dd.DeviceId, dd.DeploymentId, deployment.Retries,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to reschedule the device deployment")
+	} else if !retried {
+		return false, nil
+	}
+	log.FromContext(ctx).Infof(
+		"Rescheduling deployment %s for device %s (attempt %d of %d)",
+		dd.DeploymentId, dd.DeviceId, dd.Attempts+1, deployment.Retries,
+	)
+
+	deployment.Stats, err = d.db.UpdateStatsInc(ctx, dd.DeploymentId,
+		model.DeviceDeploymentStatusFailure, model.DeviceDeploymentStatusPending)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (d *Deployments) GetDeploymentStats(ctx context.Context,
 	deploymentID string) (model.Stats, error) {
 
-	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID)
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "checking deployment id")
@@ -1723,31 +2807,68 @@ func (d *Deployments) GetDeploymentsStats(ctx context.Context,
 	return deploymentStats, nil
 }
 
-// GetDeviceStatusesForDeployment retrieve device deployment statuses for a given deployment.
-func (d *Deployments) GetDeviceStatusesForDeployment(ctx context.Context,
-	deploymentID string) ([]model.DeviceDeployment, error) {
+// StreamDeviceStatusesForDeployment passes the device deployment statuses
+// of the deployment, one at a time, to write.
+func (d *Deployments) StreamDeviceStatusesForDeployment(ctx context.Context,
+	deploymentID string, write func(*model.DeviceDeployment) error) error {
 
-	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID)
+	it, err := d.db.IterateDeviceStatusesForDeployment(ctx, deploymentID)
 	if err != nil {
-		return nil, ErrModelInternal
+		return errors.Wrap(err, "retrieving the device deployments")
+	}
+	return streamDeviceDeployments(ctx, it, write)
+}
+
+// StreamDevicesListForDeployment passes the device deployments of the list
+// query, one at a time, to write; a zero Limit streams all of them.
+func (d *Deployments) StreamDevicesListForDeployment(ctx context.Context,
+	query store.ListQuery, write func(*model.DeviceDeployment) error) error {
+
+	deployment, err := d.db.FindDeploymentByID(ctx, query.DeploymentID, false)
+	if err != nil {
+		return ErrModelInternal
 	}
 
 	if deployment == nil {
-		return nil, ErrModelDeploymentNotFound
+		return ErrModelDeploymentNotFound
 	}
 
-	statuses, err := d.db.GetDeviceStatusesForDeployment(ctx, deploymentID)
+	it, err := d.db.IterateDevicesListForDeployment(ctx, query)
 	if err != nil {
-		return nil, ErrModelInternal
+		return errors.Wrap(err, "retrieving the device deployments")
 	}
+	return streamDeviceDeployments(ctx, it, write)
+}
 
-	return statuses, nil
+// streamDeviceDeployments passes the device deployments of the cursor, one
+// at a time, to write, and closes it.
+func streamDeviceDeployments(
+	ctx context.Context,
+	it store.Iterator[model.DeviceDeployment],
+	write func(*model.DeviceDeployment) error,
+) error {
+	defer it.Close(ctx)
+	for {
+		next, err := it.Next(ctx)
+		if err != nil {
+			return errors.Wrap(err, "retrieving the device deployments")
+		} else if !next {
+			return nil
+		}
+		var deviceDeployment model.DeviceDeployment
+		if err = it.Decode(&deviceDeployment); err != nil {
+			return errors.Wrap(err, "decoding the device deployments")
+		}
+		if err = write(&deviceDeployment); err != nil {
+			return err
+		}
+	}
 }
 
 func (d *Deployments) GetDevicesListForDeployment(ctx context.Context,
 	query store.ListQuery) ([]model.DeviceDeployment, int, error) {
 
-	deployment, err := d.db.FindDeploymentByID(ctx, query.DeploymentID)
+	deployment, err := d.db.FindDeploymentByID(ctx, query.DeploymentID, false)
 	if err != nil {
 		return nil, -1, ErrModelInternal
 	}
@@ -1764,6 +2885,103 @@ func (d *Deployments) GetDevicesListForDeployment(ctx context.Context,
 	return statuses, totalCount, nil
 }
 
+// GetDeploymentTargetDevices returns a page of the devices targeted by the
+// deployment; for group and all-devices deployments this is the device list
+// resolved when the deployment was created.
+func (d *Deployments) GetDeploymentTargetDevices(ctx context.Context,
+	deploymentID string, skip, limit int) ([]string, int, error) {
+
+	devices, totalCount, err := d.db.GetDeploymentTargetDevices(
+		ctx, deploymentID, skip, limit,
+	)
+	if err == store.ErrNotFound {
+		return nil, -1, ErrModelDeploymentNotFound
+	} else if err != nil {
+		return nil, -1, errors.Wrap(err, "retrieving the deployment device list")
+	}
+
+	return devices, totalCount, nil
+}
+
+// GetFleetSoftwareInventory returns a page of the devices with the artifact
+// they received with their latest successful deployment.
+func (d *Deployments) GetFleetSoftwareInventory(ctx context.Context,
+	query model.SoftwareInventoryQuery) ([]model.DeviceSoftware, int, error) {
+
+	inventory, totalCount, err := d.db.GetFleetSoftwareInventory(ctx, query)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "retrieving the fleet software inventory")
+	}
+	return inventory, totalCount, nil
+}
+
+// GetDeploymentCreationTrend returns the number of deployments created in
+// each time bucket of the given granularity between from and to.
+func (d *Deployments) GetDeploymentCreationTrend(ctx context.Context,
+	from, to time.Time, granularity model.TrendGranularity) ([]model.TrendBucket, error) {
+
+	buckets, err := d.db.GetDeploymentCreationTrend(ctx, from, to, granularity)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving the deployment creation trend")
+	}
+	return buckets, nil
+}
+
+// GetLargestDeployments returns up to limit deployments with the most
+// devices, largest first.
+func (d *Deployments) GetLargestDeployments(
+	ctx context.Context,
+	limit int,
+) ([]*model.Deployment, error) {
+	deployments, err := d.db.GetLargestDeployments(ctx, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving the largest deployments")
+	}
+	return deployments, nil
+}
+
+// CountDeploymentsByStatus returns the number of deployments in each status,
+// including the statuses without any.
+func (d *Deployments) CountDeploymentsByStatus(
+	ctx context.Context,
+) (map[model.DeploymentStatus]int, error) {
+	counts, err := d.db.CountDeploymentsByStatus(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting the deployments by status")
+	}
+	for _, status := range []model.DeploymentStatus{
+		model.DeploymentStatusPaused,
+		model.DeploymentStatusPending,
+		model.DeploymentStatusInProgress,
+		model.DeploymentStatusFinished,
+	} {
+		if _, ok := counts[status]; !ok {
+			counts[status] = 0
+		}
+	}
+	return counts, nil
+}
+
+// FindStaleActiveDeployments returns up to limit active deployments created
+// before olderThan whose devices are still pending or downloading; they are
+// logged as a warning as the rollouts most likely stalled.
+func (d *Deployments) FindStaleActiveDeployments(
+	ctx context.Context,
+	olderThan time.Time,
+	limit int,
+) ([]*model.Deployment, error) {
+	deployments, err := d.db.FindStaleActiveDeployments(ctx, olderThan, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for stale deployments")
+	}
+	l := log.FromContext(ctx)
+	for _, deployment := range deployments {
+		l.Warnf("deployment %s created at %s has devices which "+
+			"never finished the update", deployment.Id, deployment.Created)
+	}
+	return deployments, nil
+}
+
 func (d *Deployments) GetDeviceDeploymentListForDevice(ctx context.Context,
 	query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentListItem, int, error) {
 	deviceDeployments, totalCount, err := d.db.GetDeviceDeploymentsForDevice(ctx, query)
@@ -1811,6 +3029,17 @@ func (d *Deployments) GetDeviceDeploymentListForDevice(ctx context.Context,
 	return res, totalCount, nil
 }
 
+// GetDeviceDeploymentViewsForDevice lists the device deployments with the
+// name and status of their deployment and the name of their artifact.
+func (d *Deployments) GetDeviceDeploymentViewsForDevice(ctx context.Context,
+	query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error) {
+	views, totalCount, err := d.db.GetDeviceDeploymentViewsForDevice(ctx, query)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "retrieving the list of deployment statuses")
+	}
+	return views, totalCount, nil
+}
+
 func (d *Deployments) setDeploymentDeviceCountIfUnset(
 	ctx context.Context,
 	deployment *model.Deployment,
@@ -1830,6 +3059,62 @@ func (d *Deployments) setDeploymentDeviceCountIfUnset(
 	return nil
 }
 
+// ReconcileDeviceCount sets the device count of the deployment to the number
+// of its device deployments; the two can drift apart when inserting the
+// device deployments fails halfway.
+func (d *Deployments) ReconcileDeviceCount(
+	ctx context.Context,
+	deploymentID string,
+) (*model.DeviceCountReconciliation, error) {
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for deployment by ID")
+	} else if deployment == nil {
+		return nil, ErrModelDeploymentNotFound
+	}
+
+	return d.reconcileDeviceCount(ctx, deployment)
+}
+
+func (d *Deployments) reconcileDeviceCount(
+	ctx context.Context,
+	deployment *model.Deployment,
+) (*model.DeviceCountReconciliation, error) {
+	deploymentID := deployment.Id
+	deviceCount, err := d.db.DeviceCountByDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting device deployments")
+	}
+	res := &model.DeviceCountReconciliation{
+		DeploymentID:        deploymentID,
+		PreviousDeviceCount: deployment.DeviceCount,
+		DeviceCount:         deviceCount,
+	}
+	if deployment.DeviceCount != nil && *deployment.DeviceCount == deviceCount {
+		return res, nil
+	}
+
+	err = d.db.ResetDeploymentDeviceCount(
+		ctx, deploymentID, deployment.DeviceCount, deviceCount,
+	)
+	if err == store.ErrNotFound {
+		return nil, ErrModelDeploymentNotFound
+	} else if err == mongo.ErrDeviceCountChanged {
+		return nil, ErrDeviceCountChanged
+	} else if err != nil {
+		return nil, errors.Wrap(err, "setting the device count for the deployment")
+	}
+	res.Corrected = true
+	if deployment.DeviceCount != nil {
+		log.FromContext(ctx).Warnf(
+			"deployment %s: corrected device count from %d to %d (%+d)",
+			deploymentID, *deployment.DeviceCount, deviceCount,
+			deviceCount-*deployment.DeviceCount,
+		)
+	}
+	return res, nil
+}
+
 func (d *Deployments) LookupDeployment(ctx context.Context,
 	query model.Query) ([]*model.Deployment, int64, error) {
 	list, totalCount, err := d.db.Find(ctx, query)
@@ -1851,6 +3136,49 @@ func (d *Deployments) LookupDeployment(ctx context.Context,
 	return list, totalCount, nil
 }
 
+// FindDeploymentsByGroup returns the deployments, finished or not, that
+// target the device group; none for an unknown group.
+func (d *Deployments) FindDeploymentsByGroup(ctx context.Context,
+	group string, query model.Query) ([]*model.Deployment, int64, error) {
+	list, totalCount, err := d.db.FindDeploymentsByGroup(ctx, group, query)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "searching for deployments of the group")
+	}
+
+	if list == nil {
+		return make([]*model.Deployment, 0), 0, nil
+	}
+
+	for _, deployment := range list {
+		if err := d.setDeploymentDeviceCountIfUnset(ctx, deployment); err != nil {
+			return nil, 0, err
+		}
+	}
+	return list, totalCount, nil
+}
+
+// FindDeploymentsByArtifact returns the deployments, finished or not, that
+// used the artifact with the given name or ID.
+func (d *Deployments) FindDeploymentsByArtifact(ctx context.Context,
+	name, id string, query model.Query) ([]*model.Deployment, int64, error) {
+	list, totalCount, err := d.db.FindDeploymentsByArtifact(ctx, name, id, query)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "searching for deployments of the artifact")
+	}
+
+	if list == nil {
+		return make([]*model.Deployment, 0), 0, nil
+	}
+
+	for _, deployment := range list {
+		if err := d.setDeploymentDeviceCountIfUnset(ctx, deployment); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return list, totalCount, nil
+}
+
 // SaveDeviceDeploymentLog will save the deployment log for device of
 // ID `deviceID`. Returns nil if log was saved successfully.
 func (d *Deployments) SaveDeviceDeploymentLog(ctx context.Context, deviceID string,
@@ -1866,13 +3194,13 @@ func (d *Deployments) SaveDeviceDeploymentLog(ctx context.Context, deviceID stri
 		return errors.Wrapf(err, ErrStorageInvalidLog.Error())
 	}
 
-	if has, err := d.HasDeploymentForDevice(ctx, deploymentID, deviceID); !has {
-		if err != nil {
-			return err
-		} else {
-			return ErrModelDeploymentNotFound
-		}
+	deviceDeployment, err := d.db.GetDeviceDeployment(ctx, deploymentID, deviceID, false)
+	if err == mongo.ErrStorageNotFound {
+		return ErrModelDeploymentNotFound
+	} else if err != nil {
+		return err
 	}
+	dlog.Attempt = deviceDeployment.Attempts
 
 	if err := d.db.SaveDeviceDeploymentLog(ctx, dlog); err != nil {
 		return err
@@ -1882,11 +3210,18 @@ func (d *Deployments) SaveDeviceDeploymentLog(ctx context.Context, deviceID stri
 		deviceID, deploymentID, true)
 }
 
+// GetDeviceDeploymentLog returns the page of the logs of the device
+// deployment selected by the query, and the number of messages matching
+// its level filter. It returns ErrStorageNotFound if the device did not
+// upload any log.
 func (d *Deployments) GetDeviceDeploymentLog(ctx context.Context,
-	deviceID, deploymentID string) (*model.DeploymentLog, error) {
+	query store.ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error) {
 
-	return d.db.GetDeviceDeploymentLog(ctx,
-		deviceID, deploymentID)
+	logs, total, err := d.db.GetDeviceDeploymentLog(ctx, query)
+	if err == store.ErrNotFound {
+		return nil, 0, ErrStorageNotFound
+	}
+	return logs, total, err
 }
 
 func (d *Deployments) HasDeploymentForDevice(ctx context.Context,
@@ -1894,35 +3229,80 @@ func (d *Deployments) HasDeploymentForDevice(ctx context.Context,
 	return d.db.HasDeploymentForDevice(ctx, deploymentID, deviceID)
 }
 
+// CountActiveDeploymentsByDevice returns the number of active device
+// deployments for the given device.
+func (d *Deployments) CountActiveDeploymentsByDevice(ctx context.Context,
+	deviceID string) (int, error) {
+	count, err := d.db.CountActiveDeploymentsByDevice(ctx, deviceID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count active device deployments")
+	}
+	return count, nil
+}
+
 // AbortDeployment aborts deployment for devices and updates deployment stats
 func (d *Deployments) AbortDeployment(ctx context.Context, deploymentID string) error {
 
-	if err := d.db.AbortDeviceDeployments(ctx, deploymentID); err != nil {
+	if _, err := d.db.AbortDeviceDeployments(ctx, deploymentID); err != nil {
 		return err
 	}
-
-	stats, err := d.db.AggregateDeviceDeploymentByStatus(
-		ctx, deploymentID)
-	if err != nil {
+	if err := d.recordDeploymentAbort(ctx, deploymentID); err != nil {
 		return err
 	}
 
-	// update statistics
-	if err := d.db.UpdateStats(ctx, deploymentID, stats); err != nil {
-		return errors.Wrap(err, "failed to update deployment stats")
-	}
-
 	// when aborting the deployment we need to set status directly instead of
 	// using recalcDeploymentStatus method;
 	// it is possible that the deployment does not have any device deployments yet;
 	// in that case, all statistics are 0 and calculating status based on statistics
-	// will not work - the calculated status will be "pending"
-	if err := d.db.SetDeploymentStatus(ctx,
-		deploymentID, model.DeploymentStatusFinished, time.Now()); err != nil {
-		return errors.Wrap(err, "failed to update deployment status")
+	// will not work - the calculated status will be "pending";
+	// finishing the deployment recalculates and updates the statistics
+	return d.setDeploymentStatus(ctx, deploymentID, model.DeploymentStatusFinished)
+}
+
+// AbortDeploymentsByArtifactName aborts all the unfinished deployments of
+// the artifact, a batch of deployments at a time.
+func (d *Deployments) AbortDeploymentsByArtifactName(
+	ctx context.Context,
+	artifactName string,
+) (*model.AbortedDeployments, error) {
+	aborted := &model.AbortedDeployments{}
+	for {
+		ids, err := d.db.FindUnfinishedIDsByArtifactName(
+			ctx, artifactName, abortDeploymentsBatchSize,
+		)
+		if err != nil {
+			return aborted, errors.Wrap(err, "failed to find the deployments to abort")
+		}
+		for _, id := range ids {
+			devices, err := d.db.AbortDeviceDeployments(ctx, id)
+			if err != nil {
+				return aborted, errors.Wrapf(err, "failed to abort deployment %s", id)
+			}
+			if err = d.recordDeploymentAbort(ctx, id); err != nil {
+				return aborted, err
+			}
+			err = d.setDeploymentStatus(ctx, id, model.DeploymentStatusFinished)
+			if err != nil {
+				return aborted, errors.Wrapf(err, "failed to finish deployment %s", id)
+			}
+			aborted.Deployments++
+			aborted.Devices += devices
+		}
+		if len(ids) < abortDeploymentsBatchSize {
+			return aborted, nil
+		}
 	}
+}
 
-	return nil
+// recordDeploymentAbort records the identity aborting the deployment for
+// the compliance report.
+func (d *Deployments) recordDeploymentAbort(ctx context.Context, deploymentID string) error {
+	var abortedBy string
+	if idty := identity.FromContext(ctx); idty != nil {
+		abortedBy = idty.Subject
+	}
+	err := d.db.SetDeploymentAborted(ctx, deploymentID, abortedBy, time.Now())
+	return errors.Wrap(err, "failed to record the deployment abort")
 }
 
 func (d *Deployments) updateDeviceDeploymentsStatus(
@@ -1993,11 +3373,51 @@ func (d *Deployments) updateDeviceDeploymentsStatus(
 // DecommissionDevice updates the status of all the pending and active deployments for a device
 // to decommissioned
 func (d *Deployments) DecommissionDevice(ctx context.Context, deviceId string) error {
-	return d.updateDeviceDeploymentsStatus(
+	err := d.updateDeviceDeploymentsStatus(
 		ctx,
 		deviceId,
 		model.DeviceDeploymentStatusDecommissioned,
 	)
+	if err != nil {
+		return err
+	}
+	// a failing deployment must not leave the device counted in the
+	// others: carry on, and return the first error
+	l := log.FromContext(ctx)
+	var firstErr error
+	recordErr := func(deploymentID string, err error) {
+		l.Errorf("failed to decommission the device %s in deployment %s: %s",
+			deviceId, deploymentID, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// the device may be left active in other deployments than the oldest
+	deviceDeployments, err := d.db.DecommissionDeviceDeployments(ctx, deviceId)
+	// the ones decommissioned before an error need their stats updated too
+	for _, deviceDeployment := range deviceDeployments {
+		if err := d.finishDeploymentIfInactive(ctx, deviceDeployment); err != nil {
+			recordErr(deviceDeployment.DeploymentId, err)
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to decommission the device deployments")
+	}
+
+	// and be targeted by newer deployments it has not picked up yet
+	deployments, err := d.GetActiveDeploymentsForDevice(ctx, deviceId)
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments {
+		_, err := d.createDeviceDeploymentWithStatus(ctx, deviceId,
+			deployment, model.DeviceDeploymentStatusDecommissioned)
+		if err != nil {
+			recordErr(deployment.Id, err)
+		}
+	}
+	return firstErr
 }
 
 // AbortDeviceDeployments aborts all the pending and active deployments for a device
@@ -2075,6 +3495,134 @@ func (d *Deployments) WithReporting(c reporting.Client) *Deployments {
 	return d
 }
 
+// WithDbName sets the name of the default database the tenant databases
+// are named after.
+func (d *Deployments) WithDbName(name string) *Deployments {
+	d.dbName = name
+	return d
+}
+
+// WithDeletedDeploymentsRetention sets the period during which deleted
+// deployments can be restored before they are purged.
+func (d *Deployments) WithDeletedDeploymentsRetention(retention time.Duration) *Deployments {
+	d.deletedRetention = retention
+	return d
+}
+
+// WithDeviceDeploymentLogsRetention sets the period during which the logs of
+// finished device deployments are kept before they are purged.
+func (d *Deployments) WithDeviceDeploymentLogsRetention(retention time.Duration) *Deployments {
+	d.logsRetention = retention
+	return d
+}
+
+// WithGroupCache caches the devices resolved for group deployments for up
+// to ttl, keeping at most maxSize groups. A non-positive ttl disables the
+// cache.
+func (d *Deployments) WithGroupCache(ttl time.Duration, maxSize int) *Deployments {
+	d.groupCache = newGroupCache(ttl, maxSize)
+	return d
+}
+
+// WithConfigurationGenerationLimit limits the number of configuration
+// artifacts generated concurrently to maxConcurrent; the requests above the
+// limit wait up to maxWait before failing with
+// ErrTooManyArtifactGenerations. A maxConcurrent less than 1 disables the
+// limit.
+func (d *Deployments) WithConfigurationGenerationLimit(
+	maxConcurrent int,
+	maxWait time.Duration,
+) *Deployments {
+	d.generateLimiter = newGenerateLimiter(maxConcurrent, maxWait)
+	return d
+}
+
+// WithDownloadLinkTTL sets the validity of the artifact download links
+// handed out to the devices when the deployment does not set its own, and
+// the maximum a deployment may ask for. Zero values keep the defaults.
+func (d *Deployments) WithDownloadLinkTTL(ttl, maxTTL time.Duration) *Deployments {
+	d.downloadLinkTTL = ttl
+	d.downloadLinkMaxTTL = maxTTL
+	return d
+}
+
+// WithDownloadLinkMaxExpire caps the validity of the artifact download
+// links requested through the management API. Zero keeps the default.
+func (d *Deployments) WithDownloadLinkMaxExpire(maxExpire time.Duration) *Deployments {
+	d.downloadLinkMaxExpire = maxExpire
+	return d
+}
+
+// WithMaxArtifactSize rejects the direct uploads larger than the given
+// size, or than the artifact size limit of the tenant if lower.
+func (d *Deployments) WithMaxArtifactSize(size int64) *Deployments {
+	d.maxArtifactSize = size
+	return d
+}
+
+// WithClearAssignedImages lets DeleteImage delete the images assigned to
+// devices in active deployments: the devices get another artifact of the
+// release, if any, on their next request for an update.
+func (d *Deployments) WithClearAssignedImages(clear bool) *Deployments {
+	d.clearAssignedImages = clear
+	return d
+}
+
+// WithDeviceDeploymentConfirmation has the devices reporting success right
+// after rebooting await confirmation: the update succeeds on the next
+// success report, or fails if none comes within the timeout. A zero timeout
+// disables the confirmation step.
+func (d *Deployments) WithDeviceDeploymentConfirmation(timeout time.Duration) *Deployments {
+	d.confirmationTimeout = timeout
+	return d
+}
+
+// WithArtifactImportStaleTimeout sets how long a running artifact import
+// job may go without progress before the storage daemon marks it as failed.
+func (d *Deployments) WithArtifactImportStaleTimeout(timeout time.Duration) *Deployments {
+	d.importStaleTimeout = timeout
+	return d
+}
+
+// WithDeviceDeploymentStatusDedup sets how often the repeated status
+// reports of a device refresh the last update time of its deployment;
+// the repeats never touch the deployment stats.
+func (d *Deployments) WithDeviceDeploymentStatusDedup(interval time.Duration) *Deployments {
+	d.statusDedupInterval = interval
+	return d
+}
+
+func (d *Deployments) maxDownloadLinkTTL() time.Duration {
+	if d.downloadLinkMaxTTL > 0 {
+		return d.downloadLinkMaxTTL
+	}
+	return DefaultUpdateDownloadLinkMaxTTL
+}
+
+func (d *Deployments) maxDownloadLinkExpire() time.Duration {
+	if d.downloadLinkMaxExpire > 0 {
+		return d.downloadLinkMaxExpire
+	}
+	return DefaultDownloadLinkMaxExpire
+}
+
+// downloadLinkExpire returns the validity of the download links for the
+// deployment, clamped to the maximum in case it was lowered after the
+// deployment was created.
+func (d *Deployments) downloadLinkExpire(deployment *model.Deployment) time.Duration {
+	expire := d.downloadLinkTTL
+	if expire <= 0 {
+		expire = DefaultUpdateDownloadLinkExpire
+	}
+	if deployment.DeploymentConstructor != nil && deployment.DownloadLinkTTL > 0 {
+		expire = time.Duration(deployment.DownloadLinkTTL) * time.Second
+	}
+	if maxTTL := d.maxDownloadLinkTTL(); expire > maxTTL {
+		expire = maxTTL
+	}
+	return expire
+}
+
 func (d *Deployments) haveReporting() bool {
 	return d.reportingClient != nil
 }
@@ -2127,7 +3675,8 @@ func (d *Deployments) reindexDevice(ctx context.Context, deviceID string) error
 func (d *Deployments) reindexDeployment(ctx context.Context,
 	deviceID, deploymentID, ID string) error {
 	if d.reportingClient != nil {
-		return d.workflowsClient.StartReindexReportingDeployment(ctx, deviceID, deploymentID, ID)
+		return d.workflowsClient.StartReindexReportingDeployment(ctx,
+			deviceID, deploymentID, ID, d.getDeviceAttributes(ctx, deviceID))
 	}
 	return nil
 }
@@ -0,0 +1,286 @@
+// Copyright 2024 Northern.tech AS
+//
+//	Licensed under the Apache License, Version 2.0 (the "License");
+//	you may not use this file except in compliance with the License.
+//	You may obtain a copy of the License at
+//
+//	    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/utils"
+)
+
+const (
+	DefaultArtifactImportRateLimit = 10
+	// DefaultArtifactImportStaleTimeout is how long a running import job
+	// may go without progress before it is considered interrupted
+	DefaultArtifactImportStaleTimeout = time.Hour
+
+	errMsgArtifactImportInterrupted = "the import was interrupted; " +
+		"start a new import to register the remaining artifacts"
+)
+
+var (
+	ErrArtifactImportNotFound = errors.New("artifact import job not found")
+	ErrArtifactImportPrefix   = errors.New(
+		"the prefix must be within the storage namespace of the tenant")
+
+	// artifactImportNamespace is used for deriving the artifact ID from
+	// the checksum of the imported object; importing the same content
+	// twice yields the same ID (and storage key).
+	artifactImportNamespace = uuid.MustParse("c1e1b0a4-0f5e-4d47-9c1a-3e8e2f1d6a57")
+)
+
+// ImportArtifacts starts a background job registering all the artifacts
+// found in the object storage under the given prefix.
+func (d *Deployments) ImportArtifacts(
+	ctx context.Context,
+	req model.ArtifactImportRequest,
+) (*model.ArtifactImportJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid import request")
+	}
+	if !artifactImportKeyAllowed(ctx, req.Prefix) {
+		return nil, ErrArtifactImportPrefix
+	}
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = DefaultArtifactImportRateLimit
+	}
+
+	job := model.NewArtifactImportJob(req.Prefix)
+	if err := d.db.InsertArtifactImportJob(ctx, job); err != nil {
+		return nil, errors.Wrap(err, "failed to save artifact import job")
+	}
+
+	// the job outlives the request: keep only the identity and logger
+	jobCtx := log.WithContext(context.Background(), log.FromContext(ctx))
+	if id := identity.FromContext(ctx); id != nil {
+		jobCtx = identity.WithContext(jobCtx, id)
+	}
+	jobState := *job
+	go func() {
+		_ = d.importArtifacts(jobCtx, &jobState, time.Second/time.Duration(rateLimit))
+	}()
+
+	return job, nil
+}
+
+// GetArtifactImportJob returns the progress of an artifact import job.
+func (d *Deployments) GetArtifactImportJob(
+	ctx context.Context,
+	id string,
+) (*model.ArtifactImportJob, error) {
+	job, err := d.db.FindArtifactImportJobByID(ctx, id)
+	if err == store.ErrNotFound {
+		return nil, ErrArtifactImportNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get artifact import job")
+	}
+	return job, nil
+}
+
+// artifactImportKeyAllowed tells whether an object key may be imported in
+// the context: the images delete their files along with them, so a tenant
+// may import only the objects within its own namespace.
+func artifactImportKeyAllowed(ctx context.Context, key string) bool {
+	if id := identity.FromContext(ctx); id != nil && id.Tenant != "" {
+		return strings.HasPrefix(key, id.Tenant+"/")
+	}
+	return true
+}
+
+// failStaleArtifactImportJobs marks the import jobs left running by a
+// stopped service instance as failed; the job progress is saved after every
+// artifact, so a running job without recent progress is no longer running.
+func (d *Deployments) failStaleArtifactImportJobs(ctx context.Context) error {
+	failed, err := d.db.FailStaleArtifactImportJobs(ctx,
+		time.Now().Add(-d.importStaleTimeout),
+		errMsgArtifactImportInterrupted,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update interrupted artifact import jobs")
+	} else if failed > 0 {
+		log.FromContext(ctx).Infof("marked %d interrupted artifact import jobs as failed",
+			failed)
+	}
+	return nil
+}
+
+func (d *Deployments) importArtifacts(
+	ctx context.Context,
+	job *model.ArtifactImportJob,
+	period time.Duration,
+) (err error) {
+	l := log.FromContext(ctx)
+	defer func() {
+		if err != nil {
+			job.Status = model.ArtifactImportStatusFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = model.ArtifactImportStatusFinished
+		}
+		if errUpdate := d.db.UpdateArtifactImportJob(ctx, job); errUpdate != nil {
+			l.Errorf("failed to save artifact import job %s: %s", job.ID, errUpdate)
+		}
+	}()
+
+	ctx, err = d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	first := true
+	// the listing is processed a page at a time, so the total grows as the
+	// import goes
+	err = d.objectStorage.ListObjects(ctx, job.Prefix,
+		func(objects []storage.ObjectInfo) error {
+			paths := make([]string, 0, len(objects))
+			for _, obj := range objects {
+				if strings.HasSuffix(obj.Path, model.ArtifactFileSuffix) {
+					paths = append(paths, obj.Path)
+				}
+			}
+			job.Total += len(paths)
+			if err := d.db.UpdateArtifactImportJob(ctx, job); err != nil {
+				return err
+			}
+			for _, objPath := range paths {
+				if !first {
+					select {
+					case <-ticker.C:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				first = false
+				d.importArtifactAndCount(ctx, job, objPath)
+			}
+			return nil
+		})
+	if err != nil {
+		return errors.Wrap(err, "failed to list objects")
+	}
+	return nil
+}
+
+// importArtifactAndCount imports the artifact stored at objPath and saves
+// the outcome in the job progress.
+func (d *Deployments) importArtifactAndCount(
+	ctx context.Context,
+	job *model.ArtifactImportJob,
+	objPath string,
+) {
+	l := log.FromContext(ctx)
+	imported, err := d.importArtifact(ctx, objPath)
+	switch {
+	case err != nil:
+		l.Warnf("failed to import artifact %q: %s", objPath, err)
+		job.Failed++
+	case imported:
+		job.Imported++
+	default:
+		job.Skipped++
+	}
+	if err := d.db.UpdateArtifactImportJob(ctx, job); err != nil {
+		l.Errorf("failed to save artifact import job %s: %s", job.ID, err)
+	}
+}
+
+// importArtifact registers the artifact stored at objPath, keeping the file
+// where it is; it returns false if the same artifact content, or an artifact
+// with the same name and device types, is already present.
+func (d *Deployments) importArtifact(
+	ctx context.Context,
+	objPath string,
+) (bool, error) {
+	if !artifactImportKeyAllowed(ctx, objPath) {
+		return false, ErrArtifactImportPrefix
+	}
+	obj, err := d.objectStorage.GetObject(ctx, objPath)
+	if err != nil {
+		return false, err
+	}
+	defer obj.Close()
+
+	// a single pass computes the checksum and parses the artifact
+	hash := sha256.New()
+	counter := utils.CountReads(obj)
+	var r io.Reader = io.TeeReader(counter, hash)
+	meta, err := getMetaFromArchive(&r, false, d.verificationKeys)
+	if err != nil {
+		return false, errors.Wrap(ErrModelParsingArtifactFailed, err.Error())
+	}
+	if _, err = io.Copy(io.Discard, r); err != nil {
+		return false, errors.Wrap(err, "failed to compute checksum")
+	}
+	artifactID := uuid.NewSHA1(artifactImportNamespace, hash.Sum(nil)).String()
+
+	image, err := d.db.FindImageByID(ctx, artifactID)
+	if err != nil {
+		return false, err
+	} else if image != nil {
+		return false, nil
+	}
+
+	if err = meta.Validate(); err != nil {
+		return false, ErrModelInvalidMetadata
+	}
+	unique, err := d.db.IsArtifactUnique(ctx, meta.Name, meta.DeviceTypesCompatible)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check the artifact uniqueness")
+	} else if !unique {
+		return false, nil
+	}
+	if err = d.checkArtifactKeys(ctx, meta); err != nil {
+		return false, err
+	}
+	if err = d.checkReleasesLimit(ctx, meta.Name); err != nil {
+		return false, err
+	}
+
+	image = model.NewImage(artifactID,
+		&model.ImageMeta{Description: "Imported from " + objPath},
+		meta,
+		counter.Count(),
+	)
+	image.StorageKey = objPath
+	if err = d.db.InsertImage(ctx, image); err != nil {
+		if _, ok := err.(*model.ConflictError); ok {
+			// imported or uploaded in the meantime
+			return false, nil
+		}
+		return false, errors.Wrap(err, "Fail to store the metadata")
+	}
+	d.saveUpdateTypes(ctx, image)
+	if err = d.updateRelease(ctx, image, nil); err != nil {
+		return true, err
+	}
+	if err = d.UpdateDeploymentsWithArtifactName(ctx, meta.Name); err != nil {
+		return true, errors.Wrap(err, "fail to update deployments")
+	}
+	return true, nil
+}
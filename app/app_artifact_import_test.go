@@ -0,0 +1,304 @@
+// Copyright 2024 Northern.tech AS
+//
+//	Licensed under the Apache License, Version 2.0 (the "License");
+//	you may not use this file except in compliance with the License.
+//	You may obtain a copy of the License at
+//
+//	    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/mender-artifact/artifact"
+	"github.com/mendersoftware/mender-artifact/awriter"
+	"github.com/mendersoftware/mender-artifact/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
+)
+
+func makeTestArtifact(t *testing.T, name, deviceType string) []byte {
+	return makeSignedTestArtifact(t, name, deviceType, nil)
+}
+
+func makeSignedTestArtifact(
+	t *testing.T,
+	name, deviceType string,
+	signer artifact.Signer,
+) []byte {
+	return writeTestArtifact(t, name, deviceType, artifact.NewCompressorNone(), signer)
+}
+
+func writeTestArtifact(
+	t *testing.T,
+	name, deviceType string,
+	compressor artifact.Compressor,
+	signer artifact.Signer,
+) []byte {
+	var buf bytes.Buffer
+	updateType := "test-module"
+	aw := awriter.NewWriterSigned(&buf, compressor, signer)
+	err := aw.WriteArtifact(&awriter.WriteArtifactArgs{
+		Format:  "mender",
+		Version: 3,
+		Devices: []string{deviceType},
+		Name:    name,
+		Updates: &awriter.Updates{Updates: []handlers.Composer{
+			handlers.NewModuleImage(updateType),
+		}},
+		Depends: &artifact.ArtifactDepends{
+			CompatibleDevices: []string{deviceType},
+		},
+		Provides: &artifact.ArtifactProvides{
+			ArtifactName: name,
+		},
+		TypeInfoV3: &artifact.TypeInfoV3{
+			Type: &updateType,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to generate test artifact: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func importedArtifactID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return uuid.NewSHA1(artifactImportNamespace, sum[:]).String()
+}
+
+func TestImportArtifacts(t *testing.T) {
+	t.Parallel()
+
+	artifactOne := makeTestArtifact(t, "release-1", "foo")
+	artifactTwo := makeTestArtifact(t, "release-2", "bar")
+	objects := map[string][]byte{
+		"import/release-1.mender": artifactOne,
+		"import/release-2.mender": artifactTwo,
+		"import/broken.mender":    []byte("not an artifact"),
+	}
+	// the listing comes in pages
+	listing := [][]storage.ObjectInfo{{
+		{Path: "import/release-1.mender"},
+		{Path: "import/release-2.mender"},
+	}, {
+		{Path: "import/broken.mender"},
+		{Path: "import/README.txt"},
+	}}
+
+	testCases := map[string]struct {
+		listErr  error
+		existing map[string]bool
+		// names of the artifacts uploaded before, under other IDs
+		uploaded map[string]bool
+
+		status   model.ArtifactImportStatus
+		imported int
+		skipped  int
+		failed   int
+	}{
+		"ok": {
+			status:   model.ArtifactImportStatusFinished,
+			imported: 2,
+			failed:   1,
+		},
+		"ok, already imported": {
+			existing: map[string]bool{importedArtifactID(artifactOne): true},
+			status:   model.ArtifactImportStatusFinished,
+			imported: 1,
+			skipped:  1,
+			failed:   1,
+		},
+		"ok, uploaded before": {
+			uploaded: map[string]bool{"release-2": true},
+			status:   model.ArtifactImportStatusFinished,
+			imported: 1,
+			skipped:  1,
+			failed:   1,
+		},
+		"error, listing objects": {
+			listErr: errors.New("bucket not found"),
+			status:  model.ArtifactImportStatusFailed,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			fs := &fs_mocks.ObjectStorage{}
+			defer fs.AssertExpectations(t)
+
+			db.On("GetStorageSettings", mock.Anything).Return(nil, nil)
+			if tc.listErr != nil {
+				fs.On("ListObjects", mock.Anything, "import/", mock.Anything).
+					Return(tc.listErr)
+			} else {
+				fs.On("ListObjects", mock.Anything, "import/", mock.Anything).
+					Return(func(
+						_ context.Context,
+						_ string,
+						fn func([]storage.ObjectInfo) error,
+					) error {
+						for _, page := range listing {
+							if err := fn(page); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+			}
+			for path, data := range objects {
+				data := data
+				fs.On("GetObject", mock.Anything, path).
+					Return(func(context.Context, string) io.ReadCloser {
+						return io.NopCloser(bytes.NewReader(data))
+					}, nil).Maybe()
+				id := importedArtifactID(data)
+				var image *model.Image
+				if tc.existing[id] {
+					image = &model.Image{Id: id}
+				}
+				db.On("FindImageByID", mock.Anything, id).
+					Return(image, nil).Maybe()
+			}
+			db.On("IsArtifactUnique", mock.Anything,
+				mock.AnythingOfType("string"), mock.Anything).
+				Return(func(_ context.Context, name string, _ []string) bool {
+					return !tc.uploaded[name]
+				}, nil).Maybe()
+			db.On("GetLimit", mock.Anything, mock.AnythingOfType("string")).
+				Return(nil, mongo.ErrLimitNotFound).Maybe()
+			// the imported images point at the existing objects: nothing
+			// is uploaded to the storage
+			db.On("InsertImage", mock.Anything,
+				mock.MatchedBy(func(image *model.Image) bool {
+					data, ok := objects[image.StorageKey]
+					return ok && image.Id == importedArtifactID(data) &&
+						image.Size == int64(len(data))
+				})).
+				Return(nil).Maybe()
+			db.On("SaveUpdateTypes", mock.Anything, []string{"test-module"}).
+				Return(nil).Maybe()
+			db.On("UpdateReleaseArtifacts",
+				mock.Anything, mock.AnythingOfType("*model.Image"), (*model.Image)(nil),
+				mock.AnythingOfType("string")).
+				Return(nil).Maybe()
+			db.On("ExistUnfinishedByArtifactName", mock.Anything,
+				mock.AnythingOfType("string")).
+				Return(false, nil).Maybe()
+
+			var saved model.ArtifactImportJob
+			db.On("UpdateArtifactImportJob", mock.Anything,
+				mock.AnythingOfType("*model.ArtifactImportJob")).
+				Run(func(args mock.Arguments) {
+					saved = *args.Get(1).(*model.ArtifactImportJob)
+				}).
+				Return(nil)
+
+			d := NewDeployments(db, fs, 0, false)
+			job := model.NewArtifactImportJob("import/")
+			err := d.importArtifacts(ctx, job, time.Millisecond)
+			if tc.listErr != nil {
+				assert.ErrorIs(t, err, tc.listErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 3, saved.Total)
+			}
+			assert.Equal(t, tc.status, saved.Status)
+			assert.Equal(t, tc.imported, saved.Imported)
+			assert.Equal(t, tc.skipped, saved.Skipped)
+			assert.Equal(t, tc.failed, saved.Failed)
+		})
+	}
+}
+
+func TestFailStaleArtifactImportJobs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errInternal := errors.New("connection refused")
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FailStaleArtifactImportJobs", ctx,
+		mock.MatchedBy(func(updatedBefore time.Time) bool {
+			return assert.WithinDuration(t,
+				time.Now().Add(-time.Minute), updatedBefore, time.Second)
+		}),
+		errMsgArtifactImportInterrupted,
+	).Return(int64(1), nil).Once()
+	db.On("FailStaleArtifactImportJobs", ctx,
+		mock.AnythingOfType("time.Time"), errMsgArtifactImportInterrupted,
+	).Return(int64(0), errInternal).Once()
+
+	d := NewDeployments(db, nil, 0, false).
+		WithArtifactImportStaleTimeout(time.Minute)
+	assert.NoError(t, d.failStaleArtifactImportJobs(ctx))
+	assert.ErrorIs(t, d.failStaleArtifactImportJobs(ctx), errInternal)
+}
+
+func TestImportArtifactsTenantPrefix(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(),
+		&identity.Identity{Tenant: "tenant"})
+
+	// nothing is saved, let alone imported, outside the tenant namespace
+	d := NewDeployments(&mocks.DataStore{}, &fs_mocks.ObjectStorage{}, 0, false)
+	for _, prefix := range []string{"other/", "tenant", "tenantother/"} {
+		_, err := d.ImportArtifacts(ctx, model.ArtifactImportRequest{Prefix: prefix})
+		assert.ErrorIs(t, err, ErrArtifactImportPrefix, prefix)
+	}
+	imported, err := d.importArtifact(ctx, "other/release-1.mender")
+	assert.False(t, imported)
+	assert.ErrorIs(t, err, ErrArtifactImportPrefix)
+}
+
+func TestGetArtifactImportJob(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	job := model.NewArtifactImportJob("import/")
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FindArtifactImportJobByID", ctx, job.ID).Return(job, nil).Once()
+	db.On("FindArtifactImportJobByID", ctx, "missing").
+		Return(nil, store.ErrNotFound).Once()
+
+	d := NewDeployments(db, nil, 0, false)
+
+	res, err := d.GetArtifactImportJob(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, job, res)
+
+	_, err = d.GetArtifactImportJob(ctx, "missing")
+	assert.Equal(t, ErrArtifactImportNotFound, err)
+}
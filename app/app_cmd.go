@@ -19,11 +19,22 @@ import (
 	"path"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/store"
 )
 
+const (
+	confirmationTimeoutBatchSize = 100
+	confirmationTimeoutSubState  = "confirmation timed out"
+)
+
 func (d *Deployments) cleanupExpiredLink(
 	ctx context.Context,
 	link model.UploadLink,
@@ -48,11 +59,32 @@ func (d *Deployments) cleanupExpiredLink(
 	case model.LinkStatusAborted,
 		model.LinkStatusCompleted,
 		model.LinkStatusPending:
+		// the upload went to the storage of the tenant, if set
+		storageCtx := ctx
+		if link.TenantID != "" {
+			storageCtx = identity.WithContext(ctx, &identity.Identity{
+				Tenant: link.TenantID,
+			})
+		}
+		storageCtx, err = d.contextWithStorageSettings(storageCtx)
+		if err != nil {
+			break
+		}
+		if link.Multipart != nil && link.Status != model.LinkStatusCompleted {
+			// Discard the parts of the resumable upload, the object
+			// storage keeps (and bills) them until aborted.
+			err = d.objectStorage.AbortMultipartUpload(
+				storageCtx, link.Multipart.Path, link.Multipart.UploadID,
+			)
+			if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+				break
+			}
+		}
 		objectPath := link.ArtifactID + fileSuffixTmp
 		if link.TenantID != "" {
 			objectPath = path.Join(link.TenantID, objectPath)
 		}
-		err = d.objectStorage.DeleteObject(ctx, objectPath)
+		err = d.objectStorage.DeleteObject(storageCtx, objectPath)
 		if err != nil && err != storage.ErrObjectNotFound {
 			break
 		}
@@ -71,14 +103,41 @@ func (d *Deployments) cleanupExpiredLink(
 	return err
 }
 
+// cleanupExpiredLinks processes the upload links expired before now.
+func (d *Deployments) cleanupExpiredLinks(ctx context.Context, now time.Time) error {
+	it, err := d.db.FindUploadLinks(ctx, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to find expired upload links")
+	}
+	defer it.Close(ctx)
+	for {
+		var link model.UploadLink
+		run, err := it.Next(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to find expired upload links")
+		} else if !run {
+			break
+		}
+		if err = it.Decode(&link); err != nil {
+			return errors.Wrap(err, "failed to decode upload link")
+		}
+		err = d.cleanupExpiredLink(ctx, link, now)
+		if err != nil && err != store.ErrNotFound {
+			return errors.Wrapf(err, "failed to clean up upload link %s",
+				link.ArtifactID)
+		}
+	}
+	return it.Close(ctx)
+}
+
+// CleanupExpiredUploads runs the storage daemon jobs, once if the interval
+// is not positive or periodically until the context is canceled. A failing
+// job is logged and does not prevent the other jobs from running; a single
+// run returns the first error.
 func (d *Deployments) CleanupExpiredUploads(
 	ctx context.Context, interval, jitter time.Duration,
 ) error {
-	var (
-		err error
-		tc  <-chan time.Time
-		run bool = true
-	)
+	var tc <-chan time.Time
 	if interval > 0 {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
@@ -88,42 +147,272 @@ func (d *Deployments) CleanupExpiredUploads(
 		close(c)
 		tc = c
 	}
-	var it store.Iterator[model.UploadLink]
-	defer func() {
-		if it != nil {
-			it.Close(ctx)
+
+	l := log.FromContext(ctx)
+	jobs := []func(ctx context.Context) error{
+		func(ctx context.Context) error {
+			return d.cleanupExpiredLinks(ctx, time.Now().Add(-jitter))
+		},
+		d.purgeDeletedDeployments,
+		d.purgeDeviceDeploymentLogs,
+		d.failUnconfirmedDeviceDeployments,
+		d.purgeReplacedArtifactFiles,
+		d.failStaleArtifactImportJobs,
+		d.retryDeploymentRollbacks,
+	}
+	for {
+		var firstErr error
+		for _, job := range jobs {
+			err := job(ctx)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			} else if err != nil {
+				l.Error(err.Error())
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case _, run := <-tc:
+			if !run {
+				return firstErr
+			}
 		}
-	}()
+	}
+}
 
-	for run && err == nil {
-		now := time.Now().Add(-jitter)
-		it, err = d.db.FindUploadLinks(ctx, now)
+// forEachDb calls f for every tenant database and the default database,
+// with the context carrying the identity of the tenant. A database f fails
+// for is logged and skipped; the first error is returned at the end.
+func (d *Deployments) forEachDb(
+	ctx context.Context,
+	f func(ctx context.Context, db string) error,
+) error {
+	dbs, err := d.db.GetTenantDbs()
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve tenant DBs")
+	}
+	l := log.FromContext(ctx)
+	dbs = append(dbs, d.dbName)
+	var firstErr error
+	for _, db := range dbs {
+		ctx := ctx
+		if tenant := mstore.TenantFromDbName(db, d.dbName); tenant != "" {
+			ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+		}
+		err := f(ctx, db)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			l.Error(err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// purgeDeletedDeployments permanently removes, from every tenant database,
+// the deployments deleted longer than the retention period ago.
+func (d *Deployments) purgeDeletedDeployments(ctx context.Context) error {
+	if d.deletedRetention <= 0 {
+		return nil
+	}
+	l := log.FromContext(ctx)
+	deletedBefore := time.Now().Add(-d.deletedRetention)
+
+	return d.forEachDb(ctx, func(ctx context.Context, db string) error {
+		count, err := d.db.PurgeDeletedDeployments(ctx, deletedBefore)
 		if err != nil {
-			break
+			return errors.Wrapf(err, "failed to purge deleted deployments from %s", db)
+		} else if count > 0 {
+			l.Infof("purged %d deleted deployments from %s", count, db)
 		}
-		for run && err == nil {
-			var link model.UploadLink
-			run, err = it.Next(ctx)
-			if !run {
+		return nil
+	})
+}
+
+// purgeDeviceDeploymentLogs removes, from every tenant database, the logs of
+// the device deployments finished longer than the retention period ago.
+func (d *Deployments) purgeDeviceDeploymentLogs(ctx context.Context) error {
+	if d.logsRetention <= 0 {
+		return nil
+	}
+	l := log.FromContext(ctx)
+	finishedBefore := time.Now().Add(-d.logsRetention)
+
+	return d.forEachDb(ctx, func(ctx context.Context, db string) error {
+		count, err := d.db.PurgeDeviceDeploymentLogs(ctx, finishedBefore)
+		if err != nil {
+			return errors.Wrapf(err, "failed to purge device deployment logs from %s", db)
+		} else if count > 0 {
+			l.Infof("purged %d device deployment logs from %s", count, db)
+		}
+		return nil
+	})
+}
+
+// failUnconfirmedDeviceDeployments marks as failed, in every tenant
+// database, the device deployments left awaiting confirmation past their
+// confirmation deadline.
+func (d *Deployments) failUnconfirmedDeviceDeployments(ctx context.Context) error {
+	if d.confirmationTimeout <= 0 {
+		return nil
+	}
+	l := log.FromContext(ctx)
+	now := time.Now()
+
+	return d.forEachDb(ctx, func(ctx context.Context, db string) error {
+		var count int
+		for {
+			dds, err := d.db.FindDeviceDeploymentsAwaitingConfirmation(
+				ctx, now, confirmationTimeoutBatchSize,
+			)
+			if err != nil {
+				return errors.Wrapf(err,
+					"failed to find unconfirmed device deployments in %s", db)
+			}
+			for i := range dds {
+				err = d.updateDeviceDeploymentStatus(ctx, &dds[i],
+					model.DeviceDeploymentState{
+						Status:   model.DeviceDeploymentStatusFailure,
+						SubState: confirmationTimeoutSubState,
+					})
+				if err != nil {
+					return errors.Wrapf(err,
+						"failed to fail unconfirmed device deployment %s", dds[i].Id)
+				}
+				count++
+			}
+			if len(dds) < confirmationTimeoutBatchSize {
 				break
 			}
-			err = it.Decode(&link)
+		}
+		if count > 0 {
+			l.Infof("failed %d unconfirmed device deployments in %s", count, db)
+		}
+		return nil
+	})
+}
+
+// purgeReplacedArtifactFiles deletes, in every tenant database, the files of
+// the replaced artifact revisions no active device deployment created before
+// the replacement may still be downloading.
+func (d *Deployments) purgeReplacedArtifactFiles(ctx context.Context) error {
+	l := log.FromContext(ctx)
+
+	return d.forEachDb(ctx, func(ctx context.Context, db string) error {
+		files, err := d.db.FindReplacedArtifactFiles(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find replaced artifact files in %s", db)
+		} else if len(files) == 0 {
+			return nil
+		}
+		storageCtx, err := d.contextWithStorageSettings(ctx)
+		if err != nil {
+			return err
+		}
+		var count int
+		for _, file := range files {
+			image, err := d.db.FindImageByID(ctx, file.ArtifactID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to find artifact %s", file.ArtifactID)
+			} else if image != nil && image.ObjectPath(storageCtx) == file.StorageKey {
+				// the replacement failed: the file is still the current one
+				if err := d.db.DeleteReplacedArtifactFile(ctx, file.ID); err != nil {
+					return err
+				}
+				continue
+			} else if image != nil {
+				// completes the replacement if it failed half way
+				if err := d.db.UpdateImageReferences(ctx, image); err != nil {
+					return errors.Wrapf(err,
+						"failed to update the references of artifact %s", image.Id)
+				}
+			}
+			inUse, err := d.db.ExistActiveDeviceDeploymentsOfImage(
+				ctx, file.ArtifactID, file.Replaced,
+			)
 			if err != nil {
-				break
+				return errors.Wrapf(err,
+					"failed to check the device deployments of artifact %s", file.ArtifactID)
+			} else if inUse {
+				continue
+			}
+			err = d.objectStorage.DeleteObject(storageCtx, file.StorageKey)
+			if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+				return errors.Wrapf(err,
+					"failed to delete the replaced file of artifact %s", file.ArtifactID)
+			}
+			if err := d.db.DeleteReplacedArtifactFile(ctx, file.ID); err != nil {
+				return err
 			}
-			err = d.cleanupExpiredLink(ctx, link, now)
+			count++
 		}
-		if err != nil && err != store.ErrNotFound {
-			break
+		if count > 0 {
+			l.Infof("deleted %d replaced artifact files in %s", count, db)
 		}
-		err = it.Close(ctx)
-		it = nil
-		select {
-		case <-ctx.Done():
-			err = ctx.Err()
+		return nil
+	})
+}
 
-		case _, run = <-tc:
+// ReconcileDeviceCounts recounts the devices of all the deployments of the
+// tenant, or of every tenant if tenantID is empty, correcting the counts
+// which drifted.
+func (d *Deployments) ReconcileDeviceCounts(ctx context.Context, tenantID string) error {
+	if tenantID != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		return d.reconcileDeviceCountsInDb(ctx, mstore.DbNameForTenant(tenantID, d.dbName))
+	}
+	return d.forEachDb(ctx, d.reconcileDeviceCountsInDb)
+}
+
+func (d *Deployments) reconcileDeviceCountsInDb(ctx context.Context, db string) error {
+	it, err := d.db.IterateDeployments(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the deployments in %s", db)
+	}
+	defer it.Close(ctx)
+
+	var checked, corrected int
+	for {
+		next, err := it.Next(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the deployments in %s", db)
+		} else if !next {
+			break
+		}
+		var deployment model.Deployment
+		if err = it.Decode(&deployment); err != nil {
+			return errors.Wrapf(err, "failed to decode deployment in %s", db)
+		}
+		if deployment.Deleted != nil {
+			continue
+		}
+		res, err := d.reconcileDeviceCount(ctx, &deployment)
+		if err == ErrModelDeploymentNotFound {
+			continue
+		} else if err == ErrDeviceCountChanged {
+			log.FromContext(ctx).Warnf(
+				"deployment %s: device count changed while reconciling it, skipped",
+				deployment.Id)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err,
+				"failed to reconcile the device count of deployment %s", deployment.Id)
+		}
+		checked++
+		if res.Corrected && res.PreviousDeviceCount != nil {
+			corrected++
 		}
 	}
-	return err
+	log.FromContext(ctx).Infof(
+		"checked the device count of %d deployments in %s, %d corrected",
+		checked, db, corrected)
+	return nil
 }
@@ -21,11 +21,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
+
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/storage"
 	mstorage "github.com/mendersoftware/deployments/storage/mocks"
 	"github.com/mendersoftware/deployments/store"
 	mstore "github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -75,6 +78,43 @@ func (it *ArrayIterator[T]) Close(ctx context.Context) error {
 	return ctx.Err()
 }
 
+func isTenantContext(ctx context.Context, tenantID string) bool {
+	id := identity.FromContext(ctx)
+	if tenantID == "" {
+		return id == nil
+	}
+	return id != nil && id.Tenant == tenantID
+}
+
+// tenantContext matches the contexts of the tenant.
+func tenantContext(tenantID string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		return isTenantContext(ctx, tenantID)
+	})
+}
+
+// storageContext matches the contexts of the tenant carrying its storage
+// settings.
+func storageContext(tenantID string, settings *model.StorageSettings) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		ctxSettings, ok := storage.SettingsFromContext(ctx)
+		return isTenantContext(ctx, tenantID) && ok && ctxSettings == settings
+	})
+}
+
+// expectNothingElseToCleanUp lets the storage daemon find no files of
+// replaced artifact revisions to delete and no interrupted import jobs.
+func expectNothingElseToCleanUp(database *mstore.DataStore) {
+	database.On("GetTenantDbs").Return(nil, nil).Maybe()
+	database.On("FindReplacedArtifactFiles", mock.Anything).Return(nil, nil).Maybe()
+	database.On("FailStaleArtifactImportJobs",
+		mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("string"),
+	).Return(int64(0), nil).Maybe()
+	database.On("FindUnfinishedDeploymentRollbacks",
+		mock.Anything, mock.AnythingOfType("time.Time"),
+	).Return(nil, nil).Maybe()
+}
+
 func TestCleanupExpiredUploads(t *testing.T) {
 	t.Parallel()
 
@@ -109,6 +149,13 @@ func TestCleanupExpiredUploads(t *testing.T) {
 			UpdatedTS: time.Now().Add(-inprogressIdleTime * 3),
 			Status:    model.LinkStatusProcessing,
 		}}
+		tenantSettings := &model.StorageSettings{
+			Type:   model.StorageTypeS3,
+			Region: "eu-west-1",
+			Bucket: "tenant-bucket",
+			Key:    "tenant-key",
+			Secret: "tenant-secret",
+		}
 
 		database := new(mstore.DataStore)
 		objectStore := new(mstorage.ObjectStorage)
@@ -141,8 +188,16 @@ func TestCleanupExpiredUploads(t *testing.T) {
 					statusNew = model.LinkStatusAborted | model.LinkStatusProcessedBit
 					errDelete = storage.ErrObjectNotFound
 				}
+				// the tenant uploads to its own bucket
+				var settings *model.StorageSettings
+				if link.TenantID != "" {
+					settings = tenantSettings
+				}
+				database.On("GetStorageSettings", tenantContext(link.TenantID)).
+					Return(settings, nil).
+					Once()
 				objectStore.On("DeleteObject",
-					ctx,
+					storageContext(link.TenantID, settings),
 					path.Join(link.TenantID, link.ArtifactID)+fileSuffixTmp).
 					Return(errDelete).
 					Once()
@@ -154,11 +209,90 @@ func TestCleanupExpiredUploads(t *testing.T) {
 			}
 		}
 
+		expectNothingElseToCleanUp(database)
 		app := NewDeployments(database, objectStore, 0, false)
 
 		err := app.CleanupExpiredUploads(ctx, 0, jitter)
 		assert.NoError(t, err)
 	})
+	t.Run("single-shot/abort resumable uploads", func(t *testing.T) {
+		ctx := context.Background()
+		links := []model.UploadLink{{
+			ArtifactID: "94a89c91-a905-4c3a-8bfa-62a362851c1f",
+			Link: model.Link{
+				TenantID: "123456789012345678901234",
+				Expire:   time.Now().Add(-time.Hour),
+			},
+			Status: model.LinkStatusPending,
+			Multipart: &model.MultipartUpload{
+				UploadID: "upload1",
+				Path:     "123456789012345678901234/94a89c91-a905-4c3a-8bfa-62a362851c1f",
+			},
+		}, {
+			ArtifactID: "624836fd-29f5-474e-b101-5482b67c9204",
+			Link: model.Link{
+				Expire: time.Now().Add(-time.Hour),
+			},
+			Status: model.LinkStatusAborted,
+			Multipart: &model.MultipartUpload{
+				UploadID: "upload2",
+				Path:     "624836fd-29f5-474e-b101-5482b67c9204.tmp",
+			},
+		}, {
+			ArtifactID: "1ea293ad-c94b-44b7-a137-af1dd9d6b126",
+			Link: model.Link{
+				Expire: time.Now().Add(-time.Hour),
+			},
+			Status: model.LinkStatusCompleted,
+			Multipart: &model.MultipartUpload{
+				UploadID: "upload3",
+				Path:     "1ea293ad-c94b-44b7-a137-af1dd9d6b126.tmp",
+			},
+		}}
+
+		database := new(mstore.DataStore)
+		objectStore := new(mstorage.ObjectStorage)
+		defer database.AssertExpectations(t)
+		defer objectStore.AssertExpectations(t)
+
+		database.On("FindUploadLinks", ctx, mock.Anything).
+			Return(NewArrayIterator[model.UploadLink](links), nil).
+			Once()
+		// the parts of completed uploads are already assembled
+		objectStore.On("AbortMultipartUpload", storageContext(links[0].TenantID, nil),
+			links[0].Multipart.Path, "upload1").
+			Return(nil).
+			Once().
+			On("AbortMultipartUpload", storageContext(links[1].TenantID, nil),
+				links[1].Multipart.Path, "upload2").
+			Return(storage.ErrObjectNotFound).
+			Once()
+		for _, link := range links {
+			database.On("GetStorageSettings", tenantContext(link.TenantID)).
+				Return(nil, nil).
+				Once()
+			objectStore.On("DeleteObject",
+				storageContext(link.TenantID, nil),
+				path.Join(link.TenantID, link.ArtifactID)+fileSuffixTmp).
+				Return(storage.ErrObjectNotFound).
+				Once()
+			statusNew := link.Status
+			if statusNew == model.LinkStatusPending {
+				statusNew = model.LinkStatusAborted
+			}
+			database.On("UpdateUploadIntentStatus",
+				ctx, link.ArtifactID,
+				link.Status, statusNew|model.LinkStatusProcessedBit).
+				Return(nil).
+				Once()
+		}
+
+		expectNothingElseToCleanUp(database)
+		app := NewDeployments(database, objectStore, 0, false)
+
+		err := app.CleanupExpiredUploads(ctx, 0, time.Second)
+		assert.NoError(t, err)
+	})
 	t.Run("periodic/context canceled", func(t *testing.T) {
 		const (
 			jitter = time.Second
@@ -181,6 +315,7 @@ func TestCleanupExpiredUploads(t *testing.T) {
 			Return(iterator, nil).
 			Once()
 
+		expectNothingElseToCleanUp(database)
 		app := NewDeployments(database, objectStore, 0, false)
 
 		go func() {
@@ -225,13 +360,17 @@ func TestCleanupExpiredUploads(t *testing.T) {
 
 		errInternal := errors.New("internal error")
 		for _, link := range links {
+			database.On("GetStorageSettings", tenantContext(link.TenantID)).
+				Return(nil, nil).
+				Once()
 			objectStore.On("DeleteObject",
-				ctx,
+				storageContext(link.TenantID, nil),
 				path.Join(link.TenantID, link.ArtifactID)+fileSuffixTmp).
 				Return(errInternal).
 				Once()
 		}
 
+		expectNothingElseToCleanUp(database)
 		app := NewDeployments(database, objectStore, 0, false)
 
 		err := app.CleanupExpiredUploads(ctx, 0, jitter)
@@ -256,9 +395,474 @@ func TestCleanupExpiredUploads(t *testing.T) {
 			Return(nil, errInternal).
 			Once()
 
+		expectNothingElseToCleanUp(database)
 		app := NewDeployments(database, objectStore, 0, false)
 
 		err := app.CleanupExpiredUploads(ctx, 0, jitter)
 		assert.ErrorIs(t, err, errInternal)
 	})
+	t.Run("error/the other jobs still run", func(t *testing.T) {
+		ctx := context.Background()
+		database := new(mstore.DataStore)
+		defer database.AssertExpectations(t)
+
+		errInternal := errors.New("internal error")
+		database.On("FindUploadLinks", ctx, mock.Anything).
+			Return(nil, errInternal).
+			Once()
+		database.On("GetTenantDbs").Return(nil, nil)
+		database.On("PurgeDeletedDeployments", ctx, mock.AnythingOfType("time.Time")).
+			Return(int64(0), errors.New("connection reset")).
+			Once()
+		database.On("FindReplacedArtifactFiles", ctx).Return(nil, nil).Once()
+		database.On("FailStaleArtifactImportJobs",
+			ctx, mock.AnythingOfType("time.Time"), mock.AnythingOfType("string"),
+		).Return(int64(0), nil).Once()
+		database.On("FindUnfinishedDeploymentRollbacks",
+			ctx, mock.AnythingOfType("time.Time"),
+		).Return(nil, nil).Once()
+
+		app := NewDeployments(database, nil, 0, false).
+			WithDeletedDeploymentsRetention(time.Hour)
+
+		err := app.CleanupExpiredUploads(ctx, 0, time.Second)
+		assert.ErrorIs(t, err, errInternal)
+	})
+}
+
+func TestCleanupExpiredUploadsPurgeDeletedDeployments(t *testing.T) {
+	t.Parallel()
+
+	const retention = 24 * time.Hour
+	errInternal := errors.New("internal error")
+
+	testCases := map[string]struct {
+		dbName       string
+		tenantDbs    []string
+		tenantDbsErr error
+		purgeErr     error
+
+		purged []string
+		err    error
+	}{
+		"ok": {
+			tenantDbs: []string{"deployment_service-tenant1"},
+			purged:    []string{"tenant1", ""},
+		},
+		"ok, custom database name": {
+			dbName:    "staging",
+			tenantDbs: []string{"staging-tenant1"},
+			purged:    []string{"tenant1", ""},
+		},
+		"ok, no tenants": {
+			purged: []string{""},
+		},
+		"error, tenant dbs": {
+			tenantDbsErr: errInternal,
+			err:          errInternal,
+		},
+		"error, purge": {
+			tenantDbs: []string{"deployment_service-tenant1"},
+			purgeErr:  errInternal,
+			purged:    []string{"tenant1", ""},
+			err:       errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			database := new(mstore.DataStore)
+			defer database.AssertExpectations(t)
+
+			database.On("FindUploadLinks", ctx, mock.Anything).
+				Return(NewArrayIterator[model.UploadLink](nil), nil).
+				Once()
+			database.On("GetTenantDbs").Return(tc.tenantDbs, tc.tenantDbsErr)
+			for _, tenant := range tc.purged {
+				tenant := tenant
+				database.On("PurgeDeletedDeployments",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tenant == "" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tenant
+					}),
+					mock.MatchedBy(func(deletedBefore time.Time) bool {
+						return assert.WithinDuration(t,
+							time.Now().Add(-retention), deletedBefore, time.Minute)
+					}),
+				).Return(int64(1), tc.purgeErr).Once()
+			}
+
+			expectNothingElseToCleanUp(database)
+			app := NewDeployments(database, nil, 0, false).
+				WithDeletedDeploymentsRetention(retention)
+			if tc.dbName != "" {
+				app = app.WithDbName(tc.dbName)
+			}
+
+			err := app.CleanupExpiredUploads(ctx, 0, time.Second)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCleanupExpiredUploadsPurgeDeviceDeploymentLogs(t *testing.T) {
+	t.Parallel()
+
+	const retention = 7 * 24 * time.Hour
+	errInternal := errors.New("internal error")
+
+	testCases := map[string]struct {
+		tenantDbs    []string
+		tenantDbsErr error
+		purgeErr     error
+
+		purged []string
+		err    error
+	}{
+		"ok": {
+			tenantDbs: []string{"deployment_service-tenant1"},
+			purged:    []string{"tenant1", ""},
+		},
+		"ok, no tenants": {
+			purged: []string{""},
+		},
+		"error, tenant dbs": {
+			tenantDbsErr: errInternal,
+			err:          errInternal,
+		},
+		"error, purge": {
+			tenantDbs: []string{"deployment_service-tenant1"},
+			purgeErr:  errInternal,
+			purged:    []string{"tenant1", ""},
+			err:       errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			database := new(mstore.DataStore)
+			defer database.AssertExpectations(t)
+
+			database.On("FindUploadLinks", ctx, mock.Anything).
+				Return(NewArrayIterator[model.UploadLink](nil), nil).
+				Once()
+			database.On("GetTenantDbs").Return(tc.tenantDbs, tc.tenantDbsErr)
+			for _, tenant := range tc.purged {
+				tenant := tenant
+				database.On("PurgeDeviceDeploymentLogs",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						if tenant == "" {
+							return id == nil
+						}
+						return id != nil && id.Tenant == tenant
+					}),
+					mock.MatchedBy(func(finishedBefore time.Time) bool {
+						return assert.WithinDuration(t,
+							time.Now().Add(-retention), finishedBefore, time.Minute)
+					}),
+				).Return(int64(1), tc.purgeErr).Once()
+			}
+
+			expectNothingElseToCleanUp(database)
+			app := NewDeployments(database, nil, 0, false).
+				WithDeviceDeploymentLogsRetention(retention)
+
+			err := app.CleanupExpiredUploads(ctx, 0, time.Second)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCleanupExpiredUploadsPurgeReplacedArtifacts(t *testing.T) {
+	t.Parallel()
+
+	replaced := time.Now().Add(-time.Hour)
+	errInternal := errors.New("internal error")
+	file := model.ReplacedArtifactFile{
+		ID:         "d1a8c5a2-4c55-4b0b-9d0b-54c8e0c6b5a1",
+		ArtifactID: "5c1b0a5a-5d6c-4a7e-9a3b-2f1c9d8e7f60",
+		StorageKey: "5c1b0a5a-5d6c-4a7e-9a3b-2f1c9d8e7f60",
+		Replaced:   replaced,
+	}
+	image := &model.Image{
+		Id:           file.ArtifactID,
+		ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+		Revision:     1,
+	}
+
+	testCases := map[string]struct {
+		image   *model.Image
+		inUse   bool
+		refsErr error
+
+		deleted  bool
+		unqueued bool
+		err      error
+	}{
+		"ok, deleted": {
+			image:   image,
+			deleted: true,
+		},
+		"ok, artifact deleted": {
+			deleted: true,
+		},
+		"ok, still in use": {
+			image: image,
+			inUse: true,
+		},
+		"ok, replacement failed": {
+			image: &model.Image{
+				Id:           file.ArtifactID,
+				ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+			},
+			unqueued: true,
+		},
+		"error, updating the references": {
+			image:   image,
+			refsErr: errInternal,
+			err:     errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			database := new(mstore.DataStore)
+			defer database.AssertExpectations(t)
+			objectStore := new(mstorage.ObjectStorage)
+			defer objectStore.AssertExpectations(t)
+
+			database.On("FindUploadLinks", ctx, mock.Anything).
+				Return(NewArrayIterator[model.UploadLink](nil), nil).
+				Once()
+			database.On("GetTenantDbs").Return(nil, nil)
+			database.On("FindReplacedArtifactFiles", ctx).
+				Return([]model.ReplacedArtifactFile{file}, nil)
+			database.On("GetStorageSettings", ctx).Return(nil, nil)
+			database.On("FindImageByID", ctx, file.ArtifactID).Return(tc.image, nil)
+			database.On("FailStaleArtifactImportJobs",
+				ctx, mock.AnythingOfType("time.Time"), mock.AnythingOfType("string"),
+			).Return(int64(0), nil).Maybe()
+			database.On("FindUnfinishedDeploymentRollbacks",
+				ctx, mock.AnythingOfType("time.Time"),
+			).Return(nil, nil).Maybe()
+			if tc.unqueued {
+				// the file is still the current one: only the record goes
+				database.On("DeleteReplacedArtifactFile", ctx, file.ID).Return(nil)
+			} else if tc.image != nil {
+				database.On("UpdateImageReferences", ctx, tc.image).Return(tc.refsErr)
+			}
+			if tc.refsErr == nil && !tc.unqueued {
+				database.On("ExistActiveDeviceDeploymentsOfImage",
+					ctx, file.ArtifactID, replaced).
+					Return(tc.inUse, nil)
+			}
+			if tc.deleted {
+				objectStore.On("DeleteObject", mock.Anything, file.StorageKey).
+					Return(storage.ErrObjectNotFound)
+				database.On("DeleteReplacedArtifactFile", ctx, file.ID).Return(nil)
+			}
+
+			app := NewDeployments(database, objectStore, 0, false)
+
+			err := app.CleanupExpiredUploads(ctx, 0, time.Second)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCleanupExpiredUploadsFailUnconfirmed(t *testing.T) {
+	t.Parallel()
+
+	errInternal := errors.New("internal error")
+
+	testCases := map[string]struct {
+		findErr error
+
+		err error
+	}{
+		"ok": {},
+		"error, find": {
+			findErr: errInternal,
+			err:     errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			database := new(mstore.DataStore)
+			defer database.AssertExpectations(t)
+
+			deployment, err := model.NewDeploymentFromConstructor(
+				&model.DeploymentConstructor{
+					Name:         "foo",
+					ArtifactName: "bar",
+					Devices:      []string{"dev1", "dev2"},
+				},
+			)
+			assert.NoError(t, err)
+			deployment.MaxDevices = 2
+			deployment.Stats.Set(model.DeviceDeploymentStatusAwaitingConfirmation, 1)
+			deployment.Stats.Set(model.DeviceDeploymentStatusRebooting, 1)
+
+			dd := model.NewDeviceDeployment("dev1", deployment.Id)
+			dd.Status = model.DeviceDeploymentStatusAwaitingConfirmation
+
+			database.On("FindUploadLinks", ctx, mock.Anything).
+				Return(NewArrayIterator[model.UploadLink](nil), nil).
+				Once()
+			database.On("GetTenantDbs").Return(nil, nil)
+			if tc.findErr != nil {
+				database.On("FindDeviceDeploymentsAwaitingConfirmation",
+					ctx, mock.AnythingOfType("time.Time"), confirmationTimeoutBatchSize,
+				).Return(nil, tc.findErr).Once()
+			} else {
+				database.On("FindDeviceDeploymentsAwaitingConfirmation",
+					ctx, mock.AnythingOfType("time.Time"), confirmationTimeoutBatchSize,
+				).Return([]model.DeviceDeployment{*dd}, nil).Once()
+				database.On("UpdateDeviceDeploymentStatus", ctx, "dev1", deployment.Id,
+					mock.MatchedBy(func(state model.DeviceDeploymentState) bool {
+						return state.Status == model.DeviceDeploymentStatusFailure &&
+							state.SubState == confirmationTimeoutSubState
+					}),
+					model.DeviceDeploymentStatusAwaitingConfirmation,
+				).Return(model.DeviceDeploymentStatusAwaitingConfirmation, nil).Once()
+				database.On("FindDeploymentByID", ctx, deployment.Id, true).
+					Return(deployment, nil).Once()
+				database.On("UpdateStatsInc", ctx, deployment.Id,
+					model.DeviceDeploymentStatusAwaitingConfirmation,
+					model.DeviceDeploymentStatusFailure,
+				).Return(model.Stats{
+					model.DeviceDeploymentStatusFailureStr:   1,
+					model.DeviceDeploymentStatusRebootingStr: 1,
+				}, nil).Once()
+				database.On("SaveLastDeviceDeploymentStatus", ctx,
+					mock.AnythingOfType("model.DeviceDeployment"),
+				).Return(nil).Once()
+			}
+
+			expectNothingElseToCleanUp(database)
+			app := NewDeployments(database, nil, 0, false).
+				WithDeviceDeploymentConfirmation(time.Hour)
+
+			err = app.CleanupExpiredUploads(ctx, 0, time.Second)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReconcileDeviceCounts(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	wrong := 5
+	deployments := []model.Deployment{
+		{Id: "drifted", DeviceCount: &wrong},
+		{Id: "deleted", DeviceCount: &wrong, Deleted: &now},
+	}
+	errInternal := errors.New("internal error")
+
+	testCases := map[string]struct {
+		tenantID  string
+		tenantDbs []string
+		resetErr  error
+
+		reconciled []string
+		err        error
+	}{
+		"ok, all tenants": {
+			tenantDbs:  []string{"deployment_service-tenant1"},
+			reconciled: []string{"tenant1", ""},
+		},
+		"ok, single tenant": {
+			tenantID:   "tenant1",
+			reconciled: []string{"tenant1"},
+		},
+		"ok, count changed meanwhile": {
+			tenantID:   "tenant1",
+			resetErr:   mongo.ErrDeviceCountChanged,
+			reconciled: []string{"tenant1"},
+		},
+		"error, reset": {
+			tenantID:   "tenant1",
+			resetErr:   errInternal,
+			reconciled: []string{"tenant1"},
+			err:        errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			database := new(mstore.DataStore)
+			defer database.AssertExpectations(t)
+
+			if tc.tenantID == "" {
+				database.On("GetTenantDbs").Return(tc.tenantDbs, nil)
+			}
+			for _, tenant := range tc.reconciled {
+				tenant := tenant
+				tenantCtx := mock.MatchedBy(func(ctx context.Context) bool {
+					id := identity.FromContext(ctx)
+					if tenant == "" {
+						return id == nil
+					}
+					return id != nil && id.Tenant == tenant
+				})
+				database.On("IterateDeployments", tenantCtx).
+					Return(NewArrayIterator(deployments), nil).Once()
+				database.On("DeviceCountByDeployment", tenantCtx, "drifted").
+					Return(3, nil).Once()
+				database.On("ResetDeploymentDeviceCount", tenantCtx, "drifted", &wrong, 3).
+					Return(tc.resetErr).Once()
+			}
+
+			expectNothingElseToCleanUp(database)
+			err := NewDeployments(database, nil, 0, false).
+				ReconcileDeviceCounts(ctx, tc.tenantID)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
 }
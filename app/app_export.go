@@ -0,0 +1,214 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/app/export"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+// exportJobStaleTimeout is how long a running export job may go without
+// updates before it is considered interrupted, and may be started again
+const exportJobStaleTimeout = time.Hour
+
+var (
+	ErrExportJobNotFound = errors.New("export job not found")
+	ErrExportJobStarted  = errors.New("export job is already running or finished")
+)
+
+// ExportDeployments creates a job exporting the tenant's deployments and
+// device deployments to Parquet files, and starts the workflow running it.
+func (d *Deployments) ExportDeployments(ctx context.Context) (*model.ExportJob, error) {
+	job := model.NewExportJob()
+	job.Prefix = model.ImagePathFromContext(ctx, path.Join("exports", job.ID))
+	if err := d.db.InsertExportJob(ctx, job); err != nil {
+		return nil, errors.Wrap(err, "failed to save export job")
+	}
+
+	if err := d.workflowsClient.StartExportDeployments(ctx, job.ID); err != nil {
+		job.Status = model.ExportStatusFailed
+		job.Error = err.Error()
+		if errUpdate := d.db.UpdateExportJob(ctx, job); errUpdate != nil {
+			log.FromContext(ctx).
+				Errorf("failed to save export job %s: %s", job.ID, errUpdate)
+		}
+		return nil, errors.Wrap(err, "failed to start export workflow")
+	}
+	return job, nil
+}
+
+// GetExportJob returns the progress of an export job.
+func (d *Deployments) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	job, err := d.db.FindExportJobByID(ctx, id)
+	if err == store.ErrNotFound {
+		return nil, ErrExportJobNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get export job")
+	}
+	return job, nil
+}
+
+// RunExportJob performs the export; it is invoked by the export workflow.
+// Pending jobs, failed ones (retries) and interrupted ones are exported from
+// scratch: the deployments and device deployments are streamed from the
+// database into one Parquet file per creation date under
+// <prefix>/deployments and <prefix>/device_deployments.
+func (d *Deployments) RunExportJob(ctx context.Context, id string) (err error) {
+	job, err := d.db.StartExportJob(ctx, id, time.Now().Add(-exportJobStaleTimeout))
+	if err == store.ErrNotFound {
+		if _, err = d.GetExportJob(ctx, id); err != nil {
+			return err
+		}
+		return ErrExportJobStarted
+	} else if err != nil {
+		return errors.Wrap(err, "failed to start export job")
+	}
+
+	defer func() {
+		now := time.Now()
+		job.Finished = &now
+		if err != nil {
+			job.Status = model.ExportStatusFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = model.ExportStatusFinished
+		}
+		// the request may have been canceled: save the outcome regardless
+		saveCtx := log.WithContext(context.Background(), log.FromContext(ctx))
+		if idty := identity.FromContext(ctx); idty != nil {
+			saveCtx = identity.WithContext(saveCtx, idty)
+		}
+		if errUpdate := d.db.UpdateExportJob(saveCtx, job); errUpdate != nil {
+			log.FromContext(ctx).
+				Errorf("failed to save export job %s: %s", job.ID, errUpdate)
+		}
+	}()
+
+	touchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go d.touchExportJob(touchCtx, job.ID)
+
+	ctx, err = d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	deployments, err := d.db.IterateDeployments(ctx)
+	if err != nil {
+		return err
+	}
+	dplWriter := export.NewPartitionedWriter[export.DeploymentRecord](
+		d.objectStorage, path.Join(job.Prefix, "deployments"),
+	)
+	dplWriter.OnPartitionDone = func(ctx context.Context, path string, count int) error {
+		job.Files = append(job.Files, path)
+		job.Deployments += count
+		return d.db.UpdateExportJob(ctx, job)
+	}
+	err = exportRecords(ctx, deployments, dplWriter,
+		func(dpl *model.Deployment) (*time.Time, export.DeploymentRecord) {
+			return dpl.Created, export.NewDeploymentRecord(dpl)
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to export deployments")
+	}
+
+	devices, err := d.db.IterateDeviceDeployments(ctx)
+	if err != nil {
+		return err
+	}
+	devWriter := export.NewPartitionedWriter[export.DeviceDeploymentRecord](
+		d.objectStorage, path.Join(job.Prefix, "device_deployments"),
+	)
+	devWriter.OnPartitionDone = func(ctx context.Context, path string, count int) error {
+		job.Files = append(job.Files, path)
+		job.DeviceDeployments += count
+		return d.db.UpdateExportJob(ctx, job)
+	}
+	err = exportRecords(ctx, devices, devWriter,
+		func(dd *model.DeviceDeployment) (*time.Time, export.DeviceDeploymentRecord) {
+			return dd.Created, export.NewDeviceDeploymentRecord(dd)
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to export device deployments")
+	}
+	return nil
+}
+
+// touchExportJob keeps the running export job updated until the context is
+// done, so that it is not started again while a partition takes long.
+func (d *Deployments) touchExportJob(ctx context.Context, id string) {
+	ticker := time.NewTicker(exportJobStaleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.db.TouchExportJob(ctx, id); err != nil {
+				log.FromContext(ctx).
+					Errorf("failed to update export job %s: %s", id, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// exportRecords drains the iterator into the writer, converting every
+// document to its record and creation time.
+func exportRecords[D, R any](
+	ctx context.Context,
+	it store.Iterator[D],
+	w *export.PartitionedWriter[R],
+	convert func(*D) (*time.Time, R),
+) (err error) {
+	defer it.Close(ctx)
+	defer func() {
+		if err != nil {
+			w.Abort()
+		}
+	}()
+	for {
+		next, err := it.Next(ctx)
+		if err != nil {
+			return err
+		} else if !next {
+			break
+		}
+		var doc D
+		if err = it.Decode(&doc); err != nil {
+			return err
+		}
+		created, record := convert(&doc)
+		var date time.Time
+		if created != nil {
+			date = *created
+		}
+		if err = w.Write(ctx, date, record); err != nil {
+			return err
+		}
+	}
+	return w.Close(ctx)
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	workflows_mocks "github.com/mendersoftware/deployments/client/workflows/mocks"
+	"github.com/mendersoftware/deployments/model"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestExportDeployments(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("InsertExportJob", ctx, mock.AnythingOfType("*model.ExportJob")).
+			Return(nil)
+		wf := &workflows_mocks.Client{}
+		defer wf.AssertExpectations(t)
+		wf.On("StartExportDeployments", ctx, mock.AnythingOfType("string")).
+			Return(nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		d.SetWorkflowsClient(wf)
+		job, err := d.ExportDeployments(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, model.ExportStatusPending, job.Status)
+			assert.Equal(t, "tenant/exports/"+job.ID, job.Prefix)
+			wf.AssertCalled(t, "StartExportDeployments", ctx, job.ID)
+		}
+	})
+
+	t.Run("error, starting workflow", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("InsertExportJob", ctx, mock.AnythingOfType("*model.ExportJob")).
+			Return(nil)
+		db.On("UpdateExportJob", ctx, mock.MatchedBy(func(job *model.ExportJob) bool {
+			return job.Status == model.ExportStatusFailed && job.Error != ""
+		})).Return(nil)
+		wf := &workflows_mocks.Client{}
+		wf.On("StartExportDeployments", ctx, mock.AnythingOfType("string")).
+			Return(errors.New("workflows unavailable"))
+
+		d := NewDeployments(db, nil, 0, false)
+		d.SetWorkflowsClient(wf)
+		_, err := d.ExportDeployments(ctx)
+		assert.ErrorContains(t, err, "workflows unavailable")
+	})
+}
+
+func TestRunExportJob(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	deployments := []model.Deployment{
+		{Id: "d1", Created: &day1, DeploymentConstructor: &model.DeploymentConstructor{}},
+		{Id: "d2", Created: &day2, DeploymentConstructor: &model.DeploymentConstructor{}},
+	}
+	devices := []model.DeviceDeployment{
+		{Id: "dd1", DeploymentId: "d1", Created: &day1},
+		{Id: "dd2", DeploymentId: "d1", Created: &day1},
+		{Id: "dd3", DeploymentId: "d2", Created: &day2},
+	}
+
+	testCases := map[string]struct {
+		startErr error
+		jobErr   error
+		putErr   error
+
+		err    error
+		status model.ExportStatus
+		files  []string
+	}{
+		"ok": {
+			status: model.ExportStatusFinished,
+			files: []string{
+				"exports/job/deployments/date=2024-01-01/part-00000.parquet",
+				"exports/job/deployments/date=2024-01-02/part-00000.parquet",
+				"exports/job/device_deployments/date=2024-01-01/part-00000.parquet",
+				"exports/job/device_deployments/date=2024-01-02/part-00000.parquet",
+			},
+		},
+		"error, not found": {
+			startErr: store.ErrNotFound,
+			jobErr:   store.ErrNotFound,
+			err:      ErrExportJobNotFound,
+		},
+		"error, already running": {
+			startErr: store.ErrNotFound,
+			err:      ErrExportJobStarted,
+		},
+		"error, upload": {
+			putErr: errors.New("bucket not found"),
+			err:    errors.New("bucket not found"),
+			status: model.ExportStatusFailed,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			job := &model.ExportJob{
+				ID:     "job",
+				Prefix: "exports/job",
+				Status: model.ExportStatusRunning,
+			}
+			if tc.startErr != nil {
+				db.On("StartExportJob", ctx, "job", mock.AnythingOfType("time.Time")).Return(nil, tc.startErr)
+				db.On("FindExportJobByID", ctx, "job").Return(job, tc.jobErr)
+			} else {
+				db.On("StartExportJob", ctx, "job", mock.AnythingOfType("time.Time")).Return(job, nil)
+				db.On("GetStorageSettings", ctx).Return(nil, nil)
+				db.On("IterateDeployments", mock.Anything).
+					Return(NewArrayIterator(deployments), nil)
+				db.On("IterateDeviceDeployments", mock.Anything).
+					Return(NewArrayIterator(devices), nil).
+					Maybe()
+				db.On("UpdateExportJob", mock.Anything, job).Return(nil)
+			}
+
+			var (
+				mu      sync.Mutex
+				written []string
+			)
+			objStore := &fs_mocks.ObjectStorage{}
+			objStore.On("PutObject",
+				mock.Anything,
+				mock.MatchedBy(func(path string) bool {
+					return strings.HasPrefix(path, "exports/job/")
+				}),
+				mock.Anything,
+			).Run(func(args mock.Arguments) {
+				_, _ = io.Copy(io.Discard, args.Get(2).(io.Reader))
+				mu.Lock()
+				written = append(written, args.String(1))
+				mu.Unlock()
+			}).Return(tc.putErr)
+
+			d := NewDeployments(db, objStore, 0, false)
+			err := d.RunExportJob(ctx, "job")
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.startErr != nil {
+				return
+			}
+			assert.Equal(t, tc.status, job.Status)
+			assert.NotNil(t, job.Finished)
+			if tc.status == model.ExportStatusFinished {
+				sort.Strings(written)
+				assert.Equal(t, tc.files, written)
+				assert.Equal(t, tc.files, job.Files)
+				assert.Equal(t, 2, job.Deployments)
+				assert.Equal(t, 3, job.DeviceDeployments)
+			} else {
+				assert.Empty(t, job.Files)
+				assert.NotEmpty(t, job.Error)
+			}
+		})
+	}
+}
@@ -24,6 +24,10 @@ import (
 	"github.com/mendersoftware/deployments/model"
 )
 
+const (
+	rolledBackSubState = "the device is not running the installed artifact"
+)
+
 func (d *Deployments) isAlreadyInstalled(
 	request *model.DeploymentNextRequest,
 	deviceDeployment *model.DeviceDeployment,
@@ -65,6 +69,32 @@ func (d *Deployments) handleAlreadyInstalled(
 	return nil
 }
 
+// handleAwaitingConfirmation settles the device deployment of a device
+// checking for updates while its update awaits confirmation: running the
+// installed artifact confirms the update, running another one means that
+// the device has rolled back.
+func (d *Deployments) handleAwaitingConfirmation(
+	ctx context.Context,
+	request *model.DeploymentNextRequest,
+	deviceDeployment *model.DeviceDeployment,
+) error {
+	state := model.DeviceDeploymentState{
+		Status: model.DeviceDeploymentStatusSuccess,
+	}
+	if !d.isAlreadyInstalled(request, deviceDeployment) {
+		state = model.DeviceDeploymentState{
+			Status:   model.DeviceDeploymentStatusFailure,
+			SubState: rolledBackSubState,
+		}
+	}
+	// finishing the device deployment reindexes the device
+	err := d.updateDeviceDeploymentStatus(ctx, deviceDeployment, state)
+	if err != nil {
+		return errors.Wrap(err, "Failed to update deployment status")
+	}
+	return nil
+}
+
 // assignArtifact assigns artifact to the device deployment
 func (d *Deployments) assignArtifact(
 	ctx context.Context,
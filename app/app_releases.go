@@ -16,6 +16,7 @@ package app
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -27,7 +28,10 @@ import (
 
 // Errors expected from App interface
 var (
-	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseNotFound           = errors.New("release not found")
+	ErrReleaseInActiveDeployment = errors.New(
+		"Release is used in active deployment and cannot be removed",
+	)
 )
 
 func (d *Deployments) updateReleaseEditArtifact(
@@ -82,6 +86,53 @@ func (d *Deployments) GetReleasesUpdateTypes(ctx context.Context) ([]string, err
 	return updateTypes, err
 }
 
+// GetReleasesUpdateTypesWithCounts returns the number of releases using
+// each update type.
+func (d *Deployments) GetReleasesUpdateTypesWithCounts(
+	ctx context.Context,
+) (map[string]int, error) {
+	counts, err := d.db.GetUpdateTypesWithCounts(ctx)
+	if err != nil {
+		log.FromContext(ctx).
+			Errorf("failed to count release update types: %s", err)
+		err = ErrModelInternal
+	}
+	return counts, err
+}
+
+// ListOrphanedArtifacts lists the artifacts not part of any release, left
+// behind by an inconsistent release collection.
+func (d *Deployments) ListOrphanedArtifacts(
+	ctx context.Context,
+	skip, limit int,
+) ([]*model.Image, int, error) {
+	images, count, err := d.db.FindOrphanedImages(ctx, skip, limit)
+	if err != nil {
+		log.FromContext(ctx).
+			Errorf("failed to list the orphaned artifacts: %s", err)
+		return nil, 0, ErrModelInternal
+	}
+	return images, count, nil
+}
+
+// ListArtifactsModifiedSince lists the artifacts modified at or after since,
+// oldest first, for the incremental synchronization of external mirrors;
+// afterID, if set, skips the artifacts modified at since up to that ID.
+func (d *Deployments) ListArtifactsModifiedSince(
+	ctx context.Context,
+	since time.Time,
+	afterID string,
+	skip, limit int,
+) ([]*model.Image, error) {
+	images, err := d.db.FindImagesModifiedSince(ctx, since, afterID, skip, limit)
+	if err != nil {
+		log.FromContext(ctx).
+			Errorf("failed to list the modified artifacts: %s", err)
+		return nil, ErrModelInternal
+	}
+	return images, nil
+}
+
 func (d *Deployments) ReplaceReleaseTags(
 	ctx context.Context,
 	releaseName string,
@@ -135,9 +186,123 @@ func (d *Deployments) DeleteReleases(
 	if err != nil || len(ids) > 0 {
 		return ids, err
 	}
+	for _, name := range releaseNames {
+		images, err := d.db.ImagesByName(ctx, name)
+		if err != nil {
+			return ids, err
+		}
+		for _, image := range images {
+			if err := d.db.InsertArtifactDeletion(
+				ctx, model.NewArtifactDeletion(ctx, image),
+			); err != nil {
+				return ids, err
+			}
+		}
+	}
 	if err := d.db.DeleteImagesByNames(ctx, releaseNames); err != nil {
 		return ids, err
 	}
 	err = d.db.DeleteReleasesByNames(ctx, releaseNames)
 	return ids, err
 }
+
+// DeleteRelease deletes the release along with all its artifacts and
+// their files, unless an unfinished deployment uses the release.
+func (d *Deployments) DeleteRelease(ctx context.Context, name string) error {
+	inUse, err := d.db.ExistUnfinishedByArtifactName(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "checking if the release is used in active deployments")
+	}
+	if inUse {
+		return ErrReleaseInActiveDeployment
+	}
+
+	ctx, err = d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return err
+	}
+	images, err := d.db.ImagesByName(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "getting the release artifacts")
+	} else if len(images) == 0 {
+		// a release left without artifacts can still be deleted
+		_, err = d.db.GetRelease(ctx, name, "")
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrReleaseNotFound
+		} else if err != nil {
+			return errors.Wrap(err, "getting the release")
+		}
+	}
+	// the files are deleted first, so that an artifact whose file could
+	// not be deleted is kept and the deletion can be retried
+	if err := d.deleteReleaseFiles(ctx, images); err != nil {
+		return err
+	}
+	deleted, err := d.db.DeleteImagesByRelease(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "deleting the release artifacts")
+	}
+	// the artifacts uploaded meanwhile lose their files too
+	seen := make(map[string]struct{}, len(images))
+	for _, image := range images {
+		seen[image.Id] = struct{}{}
+	}
+	uploaded := make([]*model.Image, 0)
+	for _, image := range deleted {
+		if _, ok := seen[image.Id]; !ok {
+			uploaded = append(uploaded, image)
+		}
+	}
+	if err := d.deleteReleaseFiles(ctx, uploaded); err != nil {
+		return err
+	}
+
+	if err := d.db.DeleteReleasesByNames(ctx, []string{name}); err != nil {
+		return errors.Wrap(err, "deleting the release")
+	}
+	return nil
+}
+
+// deleteReleaseFiles records the deletion of the artifacts and deletes
+// their files.
+func (d *Deployments) deleteReleaseFiles(ctx context.Context, images []*model.Image) error {
+	for _, image := range images {
+		if err := d.db.InsertArtifactDeletion(
+			ctx, model.NewArtifactDeletion(ctx, image),
+		); err != nil {
+			return errors.Wrap(err, "recording the artifact deletion")
+		}
+		imagePath := image.ObjectPath(ctx)
+		if err := d.objectStorage.DeleteObject(ctx, imagePath); err != nil {
+			return errors.Wrapf(err, "deleting the file of artifact %s", image.Id)
+		}
+	}
+	return nil
+}
+
+// GetReleaseRollout returns the page of the deployments of the release
+// selected by the query, along with the rollup of all its deployments.
+func (d *Deployments) GetReleaseRollout(
+	ctx context.Context,
+	releaseName string,
+	query model.Query,
+) (*model.ReleaseRollout, int64, error) {
+	deployments, totalCount, err := d.FindDeploymentsByArtifact(
+		ctx, releaseName, "", query,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	stats, err := d.db.GetReleaseRolloutStats(ctx, releaseName, query.IncludeDeleted)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "aggregating the deployments of the release")
+	}
+	rollout := &model.ReleaseRollout{
+		Deployments:         make([]model.DeploymentSummary, len(deployments)),
+		ReleaseRolloutStats: *stats,
+	}
+	for i, deployment := range deployments {
+		rollout.Deployments[i] = deployment.Summary()
+	}
+	return rollout, totalCount, nil
+}
@@ -17,11 +17,14 @@ package app
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"github.com/mendersoftware/deployments/model"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
 	"github.com/mendersoftware/deployments/store"
 	"github.com/mendersoftware/deployments/store/mocks"
 )
@@ -234,6 +237,194 @@ func TestGetReleasesUpdateTypes(t *testing.T) {
 	}
 }
 
+func TestGetReleasesUpdateTypesWithCounts(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Name string
+
+		context.Context
+
+		GetDatabase func(t *testing.T, self *testCase) *mocks.DataStore
+
+		Counts map[string]int
+		Error  error
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Context: context.Background(),
+		Counts:  map[string]int{"rootfs-image": 2, "single-file": 1},
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetUpdateTypesWithCounts", self.Context).
+				Return(self.Counts, nil)
+			return ds
+		},
+	}, {
+		Name: "error/internal error",
+
+		Context: context.Background(),
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetUpdateTypesWithCounts", self.Context).
+				Return(nil, errors.New("internal error with sensitive info"))
+			return ds
+		},
+		Error: ErrModelInternal,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			ds := tc.GetDatabase(t, &tc)
+			defer ds.AssertExpectations(t)
+
+			app := NewDeployments(ds, nil, 0, false)
+
+			counts, err := app.GetReleasesUpdateTypesWithCounts(tc.Context)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Counts, counts)
+			}
+		})
+	}
+}
+
+func TestListOrphanedArtifacts(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Name string
+
+		context.Context
+
+		GetDatabase func(t *testing.T, self *testCase) *mocks.DataStore
+
+		Artifacts []*model.Image
+		Count     int
+		Error     error
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Context: context.Background(),
+		Artifacts: []*model.Image{{
+			Id:           "24436884-a710-4d20-aec4-82c89fbfe29e",
+			ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+		}},
+		Count: 3,
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("FindOrphanedImages", self.Context, 2, 1).
+				Return(self.Artifacts, self.Count, nil)
+			return ds
+		},
+	}, {
+		Name: "error/internal error",
+
+		Context: context.Background(),
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("FindOrphanedImages", self.Context, 2, 1).
+				Return(nil, 0, errors.New("internal error with sensitive info"))
+			return ds
+		},
+		Error: ErrModelInternal,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			ds := tc.GetDatabase(t, &tc)
+			defer ds.AssertExpectations(t)
+
+			app := NewDeployments(ds, nil, 0, false)
+
+			artifacts, count, err := app.ListOrphanedArtifacts(tc.Context, 2, 1)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Artifacts, artifacts)
+				assert.Equal(t, tc.Count, count)
+			}
+		})
+	}
+}
+
+func TestListArtifactsModifiedSince(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	type testCase struct {
+		Name string
+
+		context.Context
+
+		GetDatabase func(t *testing.T, self *testCase) *mocks.DataStore
+
+		Artifacts []*model.Image
+		Error     error
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Context: context.Background(),
+		Artifacts: []*model.Image{{
+			Id:           "24436884-a710-4d20-aec4-82c89fbfe29e",
+			ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+			Modified:     &since,
+		}},
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("FindImagesModifiedSince", self.Context, since, "", 2, 1).
+				Return(self.Artifacts, nil)
+			return ds
+		},
+	}, {
+		Name: "error/internal error",
+
+		Context: context.Background(),
+
+		GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("FindImagesModifiedSince", self.Context, since, "", 2, 1).
+				Return(nil, errors.New("internal error with sensitive info"))
+			return ds
+		},
+		Error: ErrModelInternal,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			ds := tc.GetDatabase(t, &tc)
+			defer ds.AssertExpectations(t)
+
+			app := NewDeployments(ds, nil, 0, false)
+
+			artifacts, err := app.ListArtifactsModifiedSince(tc.Context, since, "", 2, 1)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Artifacts, artifacts)
+			}
+		})
+	}
+}
+
 func TestUpdateRelease(t *testing.T) {
 	t.Parallel()
 
@@ -339,6 +530,15 @@ func TestDeleteReleases(t *testing.T) {
 				ds := new(mocks.DataStore)
 				ds.On("GetDeploymentIDsByArtifactNames", self.Context, self.ReleaseNames).
 					Return([]string{}, nil)
+				ds.On("ImagesByName", self.Context, "foo").
+					Return([]*model.Image{{Id: "image-1"}}, nil)
+				ds.On("ImagesByName", self.Context, "bar").
+					Return([]*model.Image{}, nil)
+				ds.On("InsertArtifactDeletion", self.Context,
+					mock.MatchedBy(func(d *model.ArtifactDeletion) bool {
+						return d.ArtifactID == "image-1"
+					})).
+					Return(nil)
 				ds.On("DeleteImagesByNames", self.Context, self.ReleaseNames).
 					Return(nil)
 				ds.On("DeleteReleasesByNames", self.Context, self.ReleaseNames).
@@ -374,6 +574,25 @@ func TestDeleteReleases(t *testing.T) {
 			},
 			Error: errors.New("some error"),
 		},
+		{
+			Name: "error: recording the artifact deletion",
+
+			Context:      context.Background(),
+			ReleaseNames: []string{"foo", "bar"},
+
+			GetDatabase: func(t *testing.T, self *testCase) *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetDeploymentIDsByArtifactNames", self.Context, self.ReleaseNames).
+					Return([]string{}, nil)
+				ds.On("ImagesByName", self.Context, "foo").
+					Return([]*model.Image{{Id: "image-1"}}, nil)
+				ds.On("InsertArtifactDeletion", self.Context,
+					mock.AnythingOfType("*model.ArtifactDeletion")).
+					Return(errors.New("some error"))
+				return ds
+			},
+			Error: errors.New("some error"),
+		},
 		{
 			Name: "error: delete images error",
 
@@ -384,6 +603,15 @@ func TestDeleteReleases(t *testing.T) {
 				ds := new(mocks.DataStore)
 				ds.On("GetDeploymentIDsByArtifactNames", self.Context, self.ReleaseNames).
 					Return([]string{}, nil)
+				ds.On("ImagesByName", self.Context, "foo").
+					Return([]*model.Image{{Id: "image-1"}}, nil)
+				ds.On("ImagesByName", self.Context, "bar").
+					Return([]*model.Image{}, nil)
+				ds.On("InsertArtifactDeletion", self.Context,
+					mock.MatchedBy(func(d *model.ArtifactDeletion) bool {
+						return d.ArtifactID == "image-1"
+					})).
+					Return(nil)
 				ds.On("DeleteImagesByNames", self.Context, self.ReleaseNames).
 					Return(errors.New("some error"))
 				return ds
@@ -400,6 +628,15 @@ func TestDeleteReleases(t *testing.T) {
 				ds := new(mocks.DataStore)
 				ds.On("GetDeploymentIDsByArtifactNames", self.Context, self.ReleaseNames).
 					Return([]string{}, nil)
+				ds.On("ImagesByName", self.Context, "foo").
+					Return([]*model.Image{{Id: "image-1"}}, nil)
+				ds.On("ImagesByName", self.Context, "bar").
+					Return([]*model.Image{}, nil)
+				ds.On("InsertArtifactDeletion", self.Context,
+					mock.MatchedBy(func(d *model.ArtifactDeletion) bool {
+						return d.ArtifactID == "image-1"
+					})).
+					Return(nil)
 				ds.On("DeleteImagesByNames", self.Context, self.ReleaseNames).
 					Return(nil)
 				ds.On("DeleteReleasesByNames", self.Context, self.ReleaseNames).
@@ -433,3 +670,224 @@ func TestDeleteReleases(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteRelease(t *testing.T) {
+	t.Parallel()
+
+	images := []*model.Image{
+		{Id: "a3c0f8a5-4f2a-4f0e-8b8a-8d5c4f7e2b10",
+			ArtifactMeta: &model.ArtifactMeta{Name: "foo"}},
+		{Id: "e2d7bd5a-6a77-4b11-9f3c-1e6a2f2f6d4c",
+			ArtifactMeta: &model.ArtifactMeta{Name: "foo"}},
+	}
+	testCases := map[string]struct {
+		inUse      bool
+		inUseErr   error
+		images     []*model.Image
+		imagesErr  error
+		releaseErr error
+		files      []*model.Image
+		objectErr  error
+		deleted    []*model.Image
+		deleteErr  error
+		deleteCall bool
+		removeErr  error
+		removeCall bool
+
+		err error
+	}{
+		"ok": {
+			images:     images,
+			files:      images,
+			deleted:    images,
+			deleteCall: true,
+			removeCall: true,
+		},
+		"ok, release without artifacts": {
+			images:     []*model.Image{},
+			deleted:    []*model.Image{},
+			deleteCall: true,
+			removeCall: true,
+		},
+		"ok, artifact uploaded meanwhile": {
+			images:     images[:1],
+			files:      images,
+			deleted:    images,
+			deleteCall: true,
+			removeCall: true,
+		},
+		"error: release in active deployment": {
+			inUse: true,
+			err:   ErrReleaseInActiveDeployment,
+		},
+		"error: release not found": {
+			images:     []*model.Image{},
+			releaseErr: store.ErrNotFound,
+			err:        ErrReleaseNotFound,
+		},
+		"error: checking active deployments": {
+			inUseErr: errors.New("mongo error"),
+			err: errors.New("checking if the release is used in active " +
+				"deployments: mongo error"),
+		},
+		"error: getting the artifacts": {
+			imagesErr: errors.New("mongo error"),
+			err:       errors.New("getting the release artifacts: mongo error"),
+		},
+		"error: getting the release": {
+			images:     []*model.Image{},
+			releaseErr: errors.New("mongo error"),
+			err:        errors.New("getting the release: mongo error"),
+		},
+		"error: deleting a file": {
+			images:    images,
+			files:     images[:1],
+			objectErr: errors.New("storage error"),
+			err: errors.New("deleting the file of artifact " +
+				images[0].Id + ": storage error"),
+		},
+		"error: deleting the artifacts": {
+			images:     images,
+			files:      images,
+			deleteCall: true,
+			deleteErr:  errors.New("mongo error"),
+			err:        errors.New("deleting the release artifacts: mongo error"),
+		},
+		"error: deleting the release": {
+			images:     images,
+			files:      images,
+			deleted:    images,
+			deleteCall: true,
+			removeCall: true,
+			removeErr:  errors.New("mongo error"),
+			err:        errors.New("deleting the release: mongo error"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			fs := new(fs_mocks.ObjectStorage)
+			defer fs.AssertExpectations(t)
+
+			ds.On("ExistUnfinishedByArtifactName", ctx, "foo").
+				Return(tc.inUse, tc.inUseErr)
+			if !tc.inUse && tc.inUseErr == nil {
+				ds.On("GetStorageSettings", ctx).Return(nil, nil)
+				ds.On("ImagesByName", mock.Anything, "foo").
+					Return(tc.images, tc.imagesErr)
+			}
+			if tc.images != nil && len(tc.images) == 0 {
+				ds.On("GetRelease", mock.Anything, "foo", "").
+					Return(&model.Release{Name: "foo"}, tc.releaseErr)
+			}
+			for _, image := range tc.files {
+				ds.On("InsertArtifactDeletion", mock.Anything,
+					mock.MatchedBy(func(d *model.ArtifactDeletion) bool {
+						return d.ArtifactName == "foo"
+					})).Return(nil).Once()
+				fs.On("DeleteObject", mock.Anything, image.Id).
+					Return(tc.objectErr).Once()
+			}
+			if tc.deleteCall {
+				ds.On("DeleteImagesByRelease", mock.Anything, "foo").
+					Return(tc.deleted, tc.deleteErr)
+			}
+			if tc.removeCall {
+				ds.On("DeleteReleasesByNames", mock.Anything, []string{"foo"}).
+					Return(tc.removeErr)
+			}
+
+			app := NewDeployments(ds, fs, 0, false)
+
+			err := app.DeleteRelease(ctx, "foo")
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetReleaseRollout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	query := model.Query{Limit: 21}
+	deviceCount := 3
+	stats := &model.ReleaseRolloutStats{
+		DeploymentCount: 2,
+		DeviceCount:     5,
+		Statistics: model.Stats{
+			model.DeviceDeploymentStatusSuccessStr: 4,
+			model.DeviceDeploymentStatusFailureStr: 1,
+		},
+	}
+	errDB := errors.New("mongo: internal error")
+
+	t.Run("ok", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentsByArtifact", ctx, "release-1", "", query).
+			Return([]*model.Deployment{{
+				Id: "d1",
+				DeploymentConstructor: &model.DeploymentConstructor{
+					Name:         "first",
+					ArtifactName: "release-1",
+				},
+				DeviceCount: &deviceCount,
+				Status:      model.DeploymentStatusFinished,
+			}}, int64(2), nil)
+		db.On("GetReleaseRolloutStats", ctx, "release-1", false).Return(stats, nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		rollout, count, err := d.GetReleaseRollout(ctx, "release-1", query)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(2), count)
+			assert.Equal(t, &model.ReleaseRollout{
+				Deployments: []model.DeploymentSummary{{
+					Id:           "d1",
+					Name:         "first",
+					ArtifactName: "release-1",
+					Type:         model.DeploymentTypeSoftware,
+					Status:       model.DeploymentStatusFinished,
+					DeviceCount:  &deviceCount,
+				}},
+				ReleaseRolloutStats: *stats,
+			}, rollout)
+		}
+	})
+
+	t.Run("ok, including the deleted deployments", func(t *testing.T) {
+		query := model.Query{Limit: 21, IncludeDeleted: true}
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentsByArtifact", ctx, "release-1", "", query).
+			Return(nil, int64(0), nil)
+		db.On("GetReleaseRolloutStats", ctx, "release-1", true).Return(stats, nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		rollout, _, err := d.GetReleaseRollout(ctx, "release-1", query)
+		if assert.NoError(t, err) {
+			assert.Equal(t, stats.DeploymentCount, rollout.DeploymentCount)
+		}
+	})
+
+	t.Run("error aggregating the stats", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentsByArtifact", ctx, "release-1", "", query).
+			Return(nil, int64(0), nil)
+		db.On("GetReleaseRolloutStats", ctx, "release-1", false).Return(nil, errDB)
+
+		d := NewDeployments(db, nil, 0, false)
+		_, _, err := d.GetReleaseRollout(ctx, "release-1", query)
+		assert.ErrorIs(t, err, errDB)
+	})
+}
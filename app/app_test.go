@@ -15,16 +15,23 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/mendersoftware/mender-artifact/artifact"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	inventory_mocks "github.com/mendersoftware/deployments/client/inventory/mocks"
 	reporting_mocks "github.com/mendersoftware/deployments/client/reporting/mocks"
@@ -432,6 +439,563 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 
 }
 
+func TestCreateDeploymentWithFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := []model.FilterPredicate{{
+		Scope:     "inventory",
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "foo",
+	}, {
+		Scope:     "tags",
+		Attribute: "env",
+		Type:      "$eq",
+		Value:     "prod",
+	}}
+	searchParams := model.SearchParams{
+		Page:    1,
+		PerPage: PerPageInventoryDevices,
+		Filters: append([]model.FilterPredicate{{
+			Scope:     InventoryIdentityScope,
+			Attribute: InventoryStatusAttributeName,
+			Type:      "$eq",
+			Value:     InventoryStatusAccepted,
+		}}, filter...),
+	}
+
+	testCases := map[string]struct {
+		devices []model.InvDevice
+
+		err error
+	}{
+		"ok": {
+			devices: []model.InvDevice{{ID: "device-1"}, {ID: "device-2"}},
+		},
+		"error, no devices matching": {
+			err: ErrNoDevices,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant_id"})
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			reportingClient := &reporting_mocks.Client{}
+			defer reportingClient.AssertExpectations(t)
+
+			reportingClient.On("Search", ctx, "tenant_id", searchParams).
+				Return(tc.devices, len(tc.devices), nil)
+			if tc.err == nil {
+				db.On("ImagesByName", ctx, "App 123").
+					Return([]*model.Image{{Id: validUUIDv4}}, nil)
+				db.On("InsertDeployment", ctx,
+					mock.MatchedBy(func(dpl *model.Deployment) bool {
+						return assert.Equal(t, filter, dpl.Filter) &&
+							assert.Equal(t, []string{"device-1", "device-2"},
+								dpl.DeviceList) &&
+							assert.Equal(t, 2, dpl.MaxDevices)
+					})).
+					Return(nil)
+			}
+
+			ds := NewDeployments(db, nil, 0, false).
+				WithReporting(reportingClient)
+			_, err := ds.CreateDeployment(ctx, &model.DeploymentConstructor{
+				Name:         "filtered",
+				ArtifactName: "App 123",
+				Filter:       filter,
+			})
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPreviewDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(),
+		&identity.Identity{Tenant: "tenant_id"})
+	searchParams := model.SearchParams{
+		Page:    1,
+		PerPage: PerPageInventoryDevices,
+		Filters: []model.FilterPredicate{{
+			Scope:     InventoryIdentityScope,
+			Attribute: InventoryStatusAttributeName,
+			Type:      "$eq",
+			Value:     InventoryStatusAccepted,
+		}},
+	}
+	devices := make([]model.InvDevice, DeploymentPreviewSampleSize+5)
+	for i := range devices {
+		devices[i].ID = fmt.Sprintf("device-%02d", i)
+	}
+
+	// the artifacts are only looked up
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{{Id: "artifact-id"}}, nil)
+	db.On("ImagesByName", ctx, "App 456").
+		Return([]*model.Image{}, nil)
+	reportingClient := &reporting_mocks.Client{}
+	defer reportingClient.AssertExpectations(t)
+	reportingClient.On("Search", ctx, "tenant_id", searchParams).
+		Return(devices, len(devices), nil).Once()
+
+	d := NewDeployments(db, nil, 0, false).WithReporting(reportingClient)
+	preview, err := d.PreviewDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "everyone",
+		ArtifactName: "App 123",
+		AllDevices:   true,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, len(devices), preview.DeviceCount)
+		if assert.Len(t, preview.Devices, DeploymentPreviewSampleSize) {
+			assert.Equal(t, "device-00", preview.Devices[0])
+		}
+	}
+
+	preview, err = d.PreviewDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "listed",
+		ArtifactName: "App 123",
+		Devices:      []string{"device-1", "device-2", "device-1"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &model.DeploymentPreview{
+			DeviceCount: 2,
+			Devices:     []string{"device-1", "device-2"},
+		}, preview)
+	}
+
+	// the artifacts are checked as for the creation
+	_, err = d.PreviewDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "missing artifact",
+		ArtifactName: "App 456",
+		Devices:      []string{"device-1"},
+	})
+	assert.Equal(t, ErrNoArtifact, err)
+	_, err = d.PreviewDeployment(ctx, &model.DeploymentConstructor{
+		Name:              "changed artifact",
+		ArtifactName:      "App 123",
+		Devices:           []string{"device-1"},
+		ExpectedArtifacts: []string{"other-artifact-id"},
+	})
+	assert.Equal(t, ErrArtifactPreconditionFailed, err)
+	_, err = d.PreviewDeployment(ctx, &model.DeploymentConstructor{
+		Name:            "long download links",
+		ArtifactName:    "App 123",
+		Devices:         []string{"device-1"},
+		DownloadLinkTTL: math.MaxUint,
+	})
+	assert.Equal(t, ErrDownloadLinkTTLTooLong, err)
+
+	_, err = d.PreviewDeployment(ctx, nil)
+	assert.Equal(t, ErrModelMissingInput, err)
+}
+
+func TestCreateDeploymentDuplicateDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(
+		context.Background(),
+		&identity.Identity{Tenant: "tenant_id"},
+	)
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{model.NewImage(
+			validUUIDv4,
+			&model.ImageMeta{},
+			&model.ArtifactMeta{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer"},
+				Depends:               map[string]interface{}{},
+			}, artifactSize)}, nil)
+	db.On("InsertDeployment", ctx,
+		mock.MatchedBy(func(deployment *model.Deployment) bool {
+			return assert.Equal(t, []string{
+				"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+				"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+			}, deployment.DeviceList) &&
+				assert.Equal(t, 2, deployment.MaxDevices)
+		})).
+		Return(nil)
+
+	d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+	_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "NYC Production",
+		ArtifactName: "App 123",
+		Devices: []string{
+			"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+			"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+			"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestCreateDeploymentPaused(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{model.NewImage(
+			validUUIDv4,
+			&model.ImageMeta{},
+			&model.ArtifactMeta{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer"},
+				Depends:               map[string]interface{}{},
+			}, artifactSize)}, nil)
+	db.On("InsertDeployment", ctx,
+		mock.MatchedBy(func(deployment *model.Deployment) bool {
+			return assert.Equal(t, model.DeploymentStatusPaused, deployment.Status) &&
+				assert.Equal(t, []string{
+					"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+					"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+				}, deployment.DeviceList)
+		})).
+		Return(nil)
+
+	start := false
+	d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+	_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "NYC Production",
+		ArtifactName: "App 123",
+		Devices: []string{
+			"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+			"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+		},
+		StartImmediately: &start,
+	})
+	assert.NoError(t, err)
+}
+
+func TestCreateDeploymentExpectedArtifacts(t *testing.T) {
+	t.Parallel()
+
+	const otherUUIDv4 = "f1bc1c61-0f54-4eb9-9f5c-7d8c3a7f8f6c"
+	testCases := map[string]struct {
+		expected  []string
+		artifacts []*model.Image
+
+		err error
+	}{
+		"ok": {
+			expected: []string{otherUUIDv4, validUUIDv4},
+			artifacts: []*model.Image{
+				{Id: validUUIDv4},
+			},
+		},
+		"ok, any artifact": {
+			expected: []string{"*"},
+			artifacts: []*model.Image{
+				{Id: validUUIDv4},
+			},
+		},
+		"error, artifact replaced": {
+			expected: []string{otherUUIDv4},
+			artifacts: []*model.Image{
+				{Id: validUUIDv4},
+			},
+			err: ErrArtifactPreconditionFailed,
+		},
+		"error, artifact deleted": {
+			expected: []string{"*"},
+			err:      ErrArtifactPreconditionFailed,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("ImagesByName", ctx, "App 123").
+				Return(tc.artifacts, nil).Once()
+			if tc.err == nil {
+				db.On("InsertDeployment", ctx, mock.AnythingOfType("*model.Deployment")).
+					Return(nil).Once()
+			}
+
+			d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+			_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+				Name:         "NYC Production",
+				ArtifactName: "App 123",
+				Devices: []string{
+					"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+					"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+				},
+				ExpectedArtifacts: tc.expected,
+			})
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestCreateDeploymentDownloadLinkTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+
+	d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false).
+		WithDownloadLinkTTL(time.Hour, 2*time.Hour)
+	for _, ttl := range []uint{
+		uint((2*time.Hour + time.Second).Seconds()),
+		// overflows a duration in nanoseconds
+		math.MaxUint,
+	} {
+		_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+			Name:            "NYC Production",
+			ArtifactName:    "App 123",
+			Devices:         []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+			DownloadLinkTTL: ttl,
+		})
+		assert.Equal(t, ErrDownloadLinkTTLTooLong, err)
+	}
+}
+
+func TestDownloadLinkExpireTooLong(t *testing.T) {
+	t.Parallel()
+
+	// the request is rejected before looking up the artifact
+	d := NewDeployments(&mocks.DataStore{}, &fs_mocks.ObjectStorage{}, 0, false).
+		WithDownloadLinkMaxExpire(2 * time.Hour)
+	link, err := d.DownloadLink(context.Background(), "artifact-id", 3*time.Hour)
+	assert.Nil(t, link)
+	assert.Equal(t, ErrDownloadLinkExpireTooLong, err)
+
+	// the device download link maximum does not apply
+	d = NewDeployments(&mocks.DataStore{}, &fs_mocks.ObjectStorage{}, 0, false).
+		WithDownloadLinkTTL(time.Hour, 7*24*time.Hour)
+	link, err = d.DownloadLink(context.Background(), "artifact-id",
+		DefaultDownloadLinkMaxExpire+time.Second)
+	assert.Nil(t, link)
+	assert.Equal(t, ErrDownloadLinkExpireTooLong, err)
+}
+
+func TestGetDeploymentInstructionsDownloadLinkTTL(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		ttl    time.Duration
+		maxTTL time.Duration
+
+		deploymentTTL uint
+		expire        time.Duration
+	}{
+		"ok, default": {
+			expire: DefaultUpdateDownloadLinkExpire,
+		},
+		"ok, configured default": {
+			ttl:    time.Hour,
+			maxTTL: 2 * time.Hour,
+			expire: time.Hour,
+		},
+		"ok, deployment ttl": {
+			ttl:           time.Hour,
+			maxTTL:        48 * time.Hour,
+			deploymentTTL: 36 * 60 * 60,
+			expire:        36 * time.Hour,
+		},
+		"ok, deployment ttl clamped": {
+			ttl:           time.Hour,
+			maxTTL:        2 * time.Hour,
+			deploymentTTL: 36 * 60 * 60,
+			expire:        2 * time.Hour,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetStorageSettings", ctx).Return(nil, nil)
+			objStore := &fs_mocks.ObjectStorage{}
+			defer objStore.AssertExpectations(t)
+			objStore.On("GetRequest",
+				mock.Anything,
+				model.ImagePathFromContext(ctx, "artifact-id"),
+				"artifact"+model.ArtifactFileSuffix,
+				tc.expire,
+			).Return(&model.Link{Uri: "https://example.com/artifact"}, nil)
+
+			d := NewDeployments(db, objStore, 0, false).
+				WithDownloadLinkTTL(tc.ttl, tc.maxTTL)
+			instructions, err := d.getDeploymentInstructions(ctx,
+				&model.Deployment{
+					Id: "deployment-id",
+					DeploymentConstructor: &model.DeploymentConstructor{
+						ForceInstallation: true,
+						DownloadLinkTTL:   tc.deploymentTTL,
+					},
+				},
+				&model.DeviceDeployment{
+					DeploymentId: "deployment-id",
+					Image: &model.Image{
+						Id:           "artifact-id",
+						ImageMeta:    &model.ImageMeta{},
+						ArtifactMeta: &model.ArtifactMeta{Name: "artifact"},
+					},
+				},
+				&model.DeploymentNextRequest{},
+				false,
+			)
+			assert.NoError(t, err)
+			if assert.NotNil(t, instructions) {
+				assert.Equal(t, "https://example.com/artifact",
+					instructions.Artifact.Source.Uri)
+			}
+		})
+	}
+}
+
+func TestFindDeploymentsByArtifact(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	query := model.Query{Limit: 10}
+	counted := 3
+	deployments := []*model.Deployment{
+		{Id: "counted", DeviceCount: &counted},
+		{Id: "uncounted"},
+	}
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FindDeploymentsByArtifact", ctx, "App 123", validUUIDv4, query).
+		Return(deployments, int64(2), nil).Once()
+	db.On("DeviceCountByDeployment", ctx, "uncounted").Return(5, nil).Once()
+	db.On("SetDeploymentDeviceCount", ctx, "uncounted", 5).Return(nil).Once()
+	db.On("FindDeploymentsByArtifact", ctx, "App 456", validUUIDv4, query).
+		Return(nil, int64(0), errors.New("mongo: internal error")).Once()
+
+	d := NewDeployments(db, nil, 0, false)
+	res, count, err := d.FindDeploymentsByArtifact(ctx, "App 123", validUUIDv4, query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), count)
+		if assert.Len(t, res, 2) {
+			assert.Equal(t, 3, *res[0].DeviceCount)
+			assert.Equal(t, 5, *res[1].DeviceCount)
+		}
+	}
+
+	_, _, err = d.FindDeploymentsByArtifact(ctx, "App 456", validUUIDv4, query)
+	assert.EqualError(t, err,
+		"searching for deployments of the artifact: mongo: internal error")
+}
+
+func TestFindStaleActiveDeployments(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	olderThan := time.Now().Add(-24 * time.Hour)
+	created := olderThan.Add(-time.Hour)
+	deployments := []*model.Deployment{{Id: "stale", Created: &created}}
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FindStaleActiveDeployments", ctx, olderThan, 10).
+		Return(deployments, nil).Once()
+	db.On("FindStaleActiveDeployments", ctx, olderThan, 5).
+		Return(nil, errors.New("mongo: internal error")).Once()
+
+	d := NewDeployments(db, nil, 0, false)
+	res, err := d.FindStaleActiveDeployments(ctx, olderThan, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, deployments, res)
+	}
+
+	_, err = d.FindStaleActiveDeployments(ctx, olderThan, 5)
+	assert.EqualError(t, err,
+		"searching for stale deployments: mongo: internal error")
+}
+
+func TestGetMetaFromArchiveCompression(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		model.ArtifactCompressionNone: "none",
+		model.ArtifactCompressionGzip: "gzip",
+		model.ArtifactCompressionLzma: "lzma",
+		model.ArtifactCompressionZstd: "zstd_fast",
+	}
+	for name, compressorID := range testCases {
+		name, compressorID := name, compressorID
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			compressor, err := artifact.NewCompressorFromId(compressorID)
+			require.NoError(t, err)
+			var r io.Reader = bytes.NewReader(
+				writeTestArtifact(t, "release-1", "foo", compressor, nil),
+			)
+			meta, err := getMetaFromArchive(&r, true, nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, name, meta.Compression)
+			}
+		})
+	}
+	assert.Equal(t, model.ArtifactCompressionUnknown, getArtifactCompression(nil))
+}
+
+func TestFindDeploymentsByGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	query := model.Query{Limit: 10}
+	counted := 2
+	deployments := []*model.Deployment{
+		{Id: "counted", DeviceCount: &counted},
+	}
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FindDeploymentsByGroup", ctx, "prod", query).
+		Return(deployments, int64(1), nil).Once()
+	db.On("FindDeploymentsByGroup", ctx, "staging", query).
+		Return(nil, int64(0), nil).Once()
+	db.On("FindDeploymentsByGroup", ctx, "test", query).
+		Return(nil, int64(0), errors.New("mongo: internal error")).Once()
+
+	d := NewDeployments(db, nil, 0, false)
+	res, count, err := d.FindDeploymentsByGroup(ctx, "prod", query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), count)
+		assert.Equal(t, deployments, res)
+	}
+
+	res, count, err = d.FindDeploymentsByGroup(ctx, "staging", query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), count)
+		assert.NotNil(t, res)
+		assert.Empty(t, res)
+	}
+
+	_, _, err = d.FindDeploymentsByGroup(ctx, "test", query)
+	assert.EqualError(t, err,
+		"searching for deployments of the group: mongo: internal error")
+}
+
 func TestUploadLink(t *testing.T) {
 	t.Parallel()
 
@@ -455,11 +1019,14 @@ func TestUploadLink(t *testing.T) {
 		objStore := new(fs_mocks.ObjectStorage)
 		ds := new(mocks.DataStore)
 		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
 		objStore.On("PutRequest",
 			h.ContextMatcher(),
 			regexMatcher(`^[0-9a-f]{8}-([0-9a-f]{4}-){3}[0-9a-f]{12}\`+
 				fileSuffixTmp),
 			time.Minute,
+			int64(0),
 		).Return(link, nil)
 
 		ds.On("GetStorageSettings", ctx).
@@ -468,7 +1035,7 @@ func TestUploadLink(t *testing.T) {
 			On("InsertUploadIntent", h.ContextMatcher(), matchUpLink).
 			Return(nil).
 			Once()
-		upLink, err := deploy.UploadLink(ctx, time.Minute, false)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 0)
 		assert.NoError(t, err)
 		assert.NotNil(t, upLink)
 		objStore.AssertExpectations(t)
@@ -482,12 +1049,15 @@ func TestUploadLink(t *testing.T) {
 		objStore := new(fs_mocks.ObjectStorage)
 		ds := new(mocks.DataStore)
 		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
 		objStore.On("PutRequest",
 			h.ContextMatcher(),
 			regexMatcher(`^123456789012345678901234/`+
 				`[0-9a-f]{8}-([0-9a-f]{4}-){3}[0-9a-f]{12}\`+
 				fileSuffixTmp),
 			time.Minute,
+			int64(0),
 		).Return(link, nil)
 
 		ds.On("GetStorageSettings", h.ContextMatcher()).
@@ -496,7 +1066,7 @@ func TestUploadLink(t *testing.T) {
 			On("InsertUploadIntent", h.ContextMatcher(), matchUpLink).
 			Return(nil).
 			Once()
-		upLink, err := deploy.UploadLink(ctx, time.Minute, false)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 0)
 		assert.NoError(t, err)
 		assert.NotNil(t, upLink)
 		objStore.AssertExpectations(t)
@@ -510,6 +1080,8 @@ func TestUploadLink(t *testing.T) {
 		objStore := new(fs_mocks.ObjectStorage)
 		ds := new(mocks.DataStore)
 		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
 		errInternal := errors.New("internal error")
 		ds.On("GetStorageSettings", ctx).
 			Return(nil, nil).
@@ -520,9 +1092,10 @@ func TestUploadLink(t *testing.T) {
 				`[0-9a-f]{8}-([0-9a-f]{4}-){3}[0-9a-f]{12}\`+
 				fileSuffixTmp),
 			time.Minute,
+			int64(0),
 		).Return(nil, errInternal)
 
-		upLink, err := deploy.UploadLink(ctx, time.Minute, false)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 0)
 		assert.ErrorIs(t, err, errInternal)
 		assert.Nil(t, upLink)
 		objStore.AssertExpectations(t)
@@ -536,6 +1109,8 @@ func TestUploadLink(t *testing.T) {
 		objStore := new(fs_mocks.ObjectStorage)
 		ds := new(mocks.DataStore)
 		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
 		errInternal := errors.New("internal error")
 		objStore.On("PutRequest",
 			h.ContextMatcher(),
@@ -543,6 +1118,7 @@ func TestUploadLink(t *testing.T) {
 				`[0-9a-f]{8}-([0-9a-f]{4}-){3}[0-9a-f]{12}\`+
 				fileSuffixTmp),
 			time.Minute,
+			int64(0),
 		).Return(link, nil)
 
 		ds.On("GetStorageSettings", ctx).
@@ -551,7 +1127,7 @@ func TestUploadLink(t *testing.T) {
 			On("InsertUploadIntent", h.ContextMatcher(), matchUpLink).
 			Return(errInternal).
 			Once()
-		upLink, err := deploy.UploadLink(ctx, time.Minute, false)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 0)
 		assert.ErrorIs(t, err, errInternal)
 		assert.Nil(t, upLink)
 		objStore.AssertExpectations(t)
@@ -564,16 +1140,65 @@ func TestUploadLink(t *testing.T) {
 		objStore := new(fs_mocks.ObjectStorage)
 		ds := new(mocks.DataStore)
 		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
 		errInternal := errors.New("internal error")
 		ds.On("GetStorageSettings", ctx).
 			Return(nil, errInternal).
 			Once()
-		upLink, err := deploy.UploadLink(ctx, time.Minute, false)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 0)
 		assert.ErrorIs(t, err, errInternal)
 		assert.Nil(t, upLink)
 		objStore.AssertExpectations(t)
 		ds.AssertExpectations(t)
 	})
+
+	t.Run("ok/size", func(t *testing.T) {
+		ctx := context.Background()
+		objStore := new(fs_mocks.ObjectStorage)
+		ds := new(mocks.DataStore)
+		deploy := NewDeployments(ds, objStore, 0, false).
+			WithMaxArtifactSize(2048)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(nil, mongo.ErrLimitNotFound)
+		objStore.On("PutRequest",
+			h.ContextMatcher(),
+			mock.AnythingOfType("string"),
+			time.Minute,
+			int64(1024),
+		).Return(link, nil)
+
+		ds.On("GetStorageSettings", ctx).
+			Return(nil, nil).
+			Once().
+			On("InsertUploadIntent", h.ContextMatcher(), matchUpLink).
+			Return(nil).
+			Once()
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 1024)
+		assert.NoError(t, err)
+		assert.NotNil(t, upLink)
+		objStore.AssertExpectations(t)
+		ds.AssertExpectations(t)
+	})
+	t.Run("error/too large for the tenant", func(t *testing.T) {
+		ctx := identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: "123456789012345678901234",
+		})
+		objStore := new(fs_mocks.ObjectStorage)
+		ds := new(mocks.DataStore)
+		// the limit of the tenant applies without a global maximum
+		deploy := NewDeployments(ds, objStore, 0, false)
+		ds.On("GetLimit", h.ContextMatcher(), model.LimitArtifactSize).
+			Return(&model.Limit{Name: model.LimitArtifactSize, Value: 1024}, nil)
+		upLink, err := deploy.UploadLink(ctx, time.Minute, false, 1025)
+		var tooLarge *ArtifactTooLargeError
+		if assert.ErrorAs(t, err, &tooLarge) {
+			assert.Equal(t, int64(1024), tooLarge.MaxSize)
+		}
+		assert.Nil(t, upLink)
+		objStore.AssertExpectations(t)
+		ds.AssertExpectations(t)
+	})
 }
 
 type eofReadCloser struct {
@@ -608,10 +1233,12 @@ func TestCompleteUpload(t *testing.T) {
 	type testCase struct {
 		Name string
 
-		Identity      *identity.Identity
-		Database      func(t *testing.T, self *testCase) *mocks.DataStore
-		ObjectStorage func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage
-		SkipVerify    bool
+		Identity       *identity.Identity
+		Database       func(t *testing.T, self *testCase) *mocks.DataStore
+		ObjectStorage  func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage
+		SkipVerify     bool
+		ExpectedSHA256 string
+		MaxSize        int64
 
 		syncChan chan struct{}
 
@@ -974,42 +1601,386 @@ func TestCompleteUpload(t *testing.T) {
 			assert.ErrorIs(t, err, testErr)
 		},
 	}, {
-		Name: "error/retrieve storage settings",
+		Name: "ok/checksum matches",
 
+		ExpectedSHA256: "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
 		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
 			ds := new(mocks.DataStore)
 			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
-				Return(nil, testErr).
-				Once()
+				Return(nil, nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusPending,
+					model.LinkStatusProcessing).
+				Return(nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusProcessing,
+					model.LinkStatusAborted).
+				Return(nil)
 			return ds
 		},
 		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
 			os := new(fs_mocks.ObjectStorage)
+			r := newEOFReadCloser(nil)
+			os.On("GetObject",
+				contextHasIdentity(t, self.Identity),
+				intentID+fileSuffixTmp).
+				Return(r, nil).
+				Once().
+				On("PutObject",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					mock.AnythingOfType("*io.PipeReader")).
+				Return(nil)
+			self.syncChan = r.ch
 			return os
 		},
 
 		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
-			assert.ErrorIs(t, err, testErr)
-		},
-	}}
-	for i := range testCases {
-		tc := testCases[i]
-		t.Run(tc.Name, func(t *testing.T) {
-			ctx := context.Background()
-			if tc.Identity != nil {
-				ctx = identity.WithContext(ctx, tc.Identity)
+			select {
+			case <-self.syncChan:
+				assert.NoError(t, err)
+			case <-time.After(time.Minute):
+				assert.FailNow(t,
+					"timed out waiting for processUploadedArtifact"+
+						"to be called")
 			}
-			ds := tc.Database(t, tc)
-			defer ds.AssertExpectations(t)
-			objStore := tc.ObjectStorage(t, tc)
-			defer objStore.AssertExpectations(t)
-			deploy := NewDeployments(ds, objStore, 0, false)
-
-			err := deploy.CompleteUpload(ctx, intentID, tc.SkipVerify, nil)
-			tc.ErrorAssertionFunc(t, tc, err)
-		})
-	}
-}
+		},
+	}, {
+		Name: "ok/resumable upload",
+
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, nil).
+				Once().
+				On("GetUploadIntent", contextHasIdentity(t, self.Identity), intentID).
+				Return(&model.UploadLink{
+					ArtifactID: intentID,
+					Status:     model.LinkStatusPending,
+					Multipart: &model.MultipartUpload{
+						UploadID: "upload",
+						Path:     intentID + fileSuffixTmp,
+						Parts:    []model.UploadPart{{PartNumber: 1, ETag: `"etag"`}},
+					},
+				}, nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusPending,
+					model.LinkStatusProcessing).
+				Return(nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusProcessing,
+					model.LinkStatusAborted).
+				Return(nil)
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			os := new(fs_mocks.ObjectStorage)
+			r := newEOFReadCloser(nil)
+			os.On("CompleteMultipartUpload",
+				contextHasIdentity(t, self.Identity),
+				intentID+fileSuffixTmp,
+				"upload",
+				[]model.UploadPart{{PartNumber: 1, ETag: `"etag"`}}).
+				Return(nil).
+				Once().
+				On("GetObject",
+					contextHasIdentity(t, self.Identity),
+					intentID+fileSuffixTmp).
+				Return(r, nil).
+				Once().
+				On("PutObject",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					mock.AnythingOfType("*io.PipeReader")).
+				Return(nil)
+			self.syncChan = r.ch
+			return os
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			select {
+			case <-self.syncChan:
+				assert.NoError(t, err)
+			case <-time.After(time.Minute):
+				assert.FailNow(t,
+					"timed out waiting for processUploadedArtifact"+
+						"to be called")
+			}
+		},
+	}, {
+		Name: "error/resumable upload without parts",
+
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, nil).
+				Once().
+				On("GetUploadIntent", contextHasIdentity(t, self.Identity), intentID).
+				Return(&model.UploadLink{
+					ArtifactID: intentID,
+					Status:     model.LinkStatusPending,
+					Multipart: &model.MultipartUpload{
+						UploadID: "upload",
+						Path:     intentID + fileSuffixTmp,
+					},
+				}, nil).
+				Once()
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			return new(fs_mocks.ObjectStorage)
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			assert.ErrorIs(t, err, ErrUploadIncomplete)
+		},
+	}, {
+		Name: "error/retrieve storage settings",
+
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, testErr).
+				Once()
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			os := new(fs_mocks.ObjectStorage)
+			return os
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			assert.ErrorIs(t, err, testErr)
+		},
+	}, {
+		Name: "error/artifact too large",
+
+		MaxSize: 1024,
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, nil).
+				Once().
+				On("GetLimit", contextHasIdentity(t, self.Identity), model.LimitArtifactSize).
+				Return(&model.Limit{Name: model.LimitArtifactSize, Value: 512}, nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusPending,
+					model.LinkStatusAborted).
+				Return(nil).
+				Once()
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			os := new(fs_mocks.ObjectStorage)
+			size := int64(768)
+			os.On("StatObject",
+				contextHasIdentity(t, self.Identity),
+				intentID+fileSuffixTmp).
+				Return(&storage.ObjectInfo{Size: &size}, nil).
+				Once().
+				On("DeleteObject",
+					contextHasIdentity(t, self.Identity),
+					intentID+fileSuffixTmp).
+				Return(nil).
+				Once()
+			return os
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			var tooLarge *ArtifactTooLargeError
+			if assert.ErrorAs(t, err, &tooLarge) {
+				assert.Equal(t, int64(512), tooLarge.MaxSize)
+			}
+		},
+	}, {
+		Name: "error/artifact too large for the tenant",
+
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, nil).
+				Once().
+				On("GetLimit", contextHasIdentity(t, self.Identity), model.LimitArtifactSize).
+				Return(&model.Limit{Name: model.LimitArtifactSize, Value: 512}, nil).
+				Once().
+				On("UpdateUploadIntentStatus",
+					contextHasIdentity(t, self.Identity),
+					intentID,
+					model.LinkStatusPending,
+					model.LinkStatusAborted).
+				Return(nil).
+				Once()
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			os := new(fs_mocks.ObjectStorage)
+			size := int64(768)
+			os.On("StatObject",
+				contextHasIdentity(t, self.Identity),
+				intentID+fileSuffixTmp).
+				Return(&storage.ObjectInfo{Size: &size}, nil).
+				Once().
+				On("DeleteObject",
+					contextHasIdentity(t, self.Identity),
+					intentID+fileSuffixTmp).
+				Return(nil).
+				Once()
+			return os
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			var tooLarge *ArtifactTooLargeError
+			if assert.ErrorAs(t, err, &tooLarge) {
+				assert.Equal(t, int64(512), tooLarge.MaxSize)
+			}
+		},
+	}, {
+		Name: "error/stat upload not found",
+
+		MaxSize: 1024,
+		Database: func(t *testing.T, self *testCase) *mocks.DataStore {
+			ds := new(mocks.DataStore)
+			ds.On("GetStorageSettings", contextHasIdentity(t, self.Identity)).
+				Return(nil, nil).
+				Once().
+				On("GetLimit", contextHasIdentity(t, self.Identity), model.LimitArtifactSize).
+				Return(nil, mongo.ErrLimitNotFound).
+				Once()
+			return ds
+		},
+		ObjectStorage: func(t *testing.T, self *testCase) *fs_mocks.ObjectStorage {
+			os := new(fs_mocks.ObjectStorage)
+			os.On("StatObject",
+				contextHasIdentity(t, self.Identity),
+				intentID+fileSuffixTmp).
+				Return(nil, storage.ErrObjectNotFound).
+				Once()
+			return os
+		},
+
+		ErrorAssertionFunc: func(t *testing.T, self *testCase, err error) {
+			assert.ErrorIs(t, err, ErrUploadNotFound)
+		},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.Identity != nil {
+				ctx = identity.WithContext(ctx, tc.Identity)
+			}
+			ds := tc.Database(t, tc)
+			defer ds.AssertExpectations(t)
+			// single part upload, unless the test case says otherwise
+			ds.On("GetUploadIntent", contextHasIdentity(t, tc.Identity), intentID).
+				Return(&model.UploadLink{
+					ArtifactID: intentID,
+					Status:     model.LinkStatusPending,
+				}, nil).
+				Maybe()
+			// no artifact size limit, unless the test case says otherwise
+			ds.On("GetLimit", contextHasIdentity(t, tc.Identity), model.LimitArtifactSize).
+				Return(nil, mongo.ErrLimitNotFound).
+				Maybe()
+			objStore := tc.ObjectStorage(t, tc)
+			defer objStore.AssertExpectations(t)
+			deploy := NewDeployments(ds, objStore, 0, false).
+				WithMaxArtifactSize(tc.MaxSize)
+
+			err := deploy.CompleteUpload(ctx, intentID, tc.SkipVerify, nil, tc.ExpectedSHA256)
+			tc.ErrorAssertionFunc(t, tc, err)
+		})
+	}
+}
+
+func TestUploadArtifactChecksum(t *testing.T) {
+	t.Parallel()
+
+	data := makeTestArtifact(t, "release-1", "foo")
+	checksum := sha256.Sum256(data)
+
+	testCases := map[string]struct {
+		expected   string
+		skipVerify bool
+
+		err bool
+	}{
+		"ok": {
+			expected: strings.ToUpper(hex.EncodeToString(checksum[:])),
+		},
+		"ok, skip verify": {
+			expected:   hex.EncodeToString(checksum[:]),
+			skipVerify: true,
+		},
+		"error, mismatch": {
+			expected: strings.Repeat("0", sha256.Size*2),
+			err:      true,
+		},
+		"error, mismatch, skip verify": {
+			expected:   strings.Repeat("0", sha256.Size*2),
+			skipVerify: true,
+			err:        true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			fs := &fs_mocks.ObjectStorage{}
+			defer fs.AssertExpectations(t)
+			var stored []byte
+			if !tc.skipVerify {
+				fs.On("PutObject", ctx, "artifact", mock.Anything).
+					Return(func(_ context.Context, _ string, r io.Reader) error {
+						b, err := io.ReadAll(r)
+						if err == nil {
+							stored = b
+						}
+						return err
+					}).
+					Once()
+			}
+
+			d := NewDeployments(nil, fs, 0, false)
+			_, size, err := d.uploadArtifact(
+				ctx, bytes.NewReader(data), "artifact", tc.skipVerify, tc.expected, nil,
+			)
+			if tc.err {
+				var checksumErr *ChecksumMismatchError
+				if assert.ErrorAs(t, err, &checksumErr) {
+					assert.Equal(t, tc.expected, checksumErr.Expected)
+					assert.Equal(t, hex.EncodeToString(checksum[:]), checksumErr.Actual)
+				}
+				// the upload to the storage is aborted
+				assert.Nil(t, stored)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(data)), size)
+				if !tc.skipVerify {
+					assert.Equal(t, data, stored)
+				}
+			}
+		})
+	}
+}
 
 func TestCreateDeviceConfigurationDeployment(t *testing.T) {
 
@@ -1161,7 +2132,13 @@ func TestAbortDeployment(t *testing.T) {
 			defer db.AssertExpectations(t)
 			db.On("AbortDeviceDeployments",
 				h.ContextMatcher(), tc.InputDeploymentID).
-				Return(tc.AbortDeviceDeploymentsError)
+				Return(int64(0), tc.AbortDeviceDeploymentsError)
+			if tc.AbortDeviceDeploymentsError == nil {
+				db.On("SetDeploymentAborted",
+					h.ContextMatcher(), tc.InputDeploymentID,
+					"", mock.AnythingOfType("time.Time")).
+					Return(nil)
+			}
 			if tc.CallAggregateDeviceDeploymentByStatus {
 				db.On("AggregateDeviceDeploymentByStatus",
 					h.ContextMatcher(), tc.InputDeploymentID).
@@ -1178,7 +2155,7 @@ func TestAbortDeployment(t *testing.T) {
 				db.On("SetDeploymentStatus",
 					h.ContextMatcher(), tc.InputDeploymentID,
 					model.DeploymentStatusFinished, mock.AnythingOfType("time.Time")).
-					Return(tc.SetDeploymentStatusError)
+					Return(true, tc.SetDeploymentStatusError)
 			}
 
 			ds := &Deployments{
@@ -1196,6 +2173,100 @@ func TestAbortDeployment(t *testing.T) {
 	}
 }
 
+func TestAbortDeploymentsByArtifactName(t *testing.T) {
+	t.Parallel()
+
+	const artifactName = "App 123"
+	errInternal := errors.New("internal error")
+
+	fullBatch := make([]string, abortDeploymentsBatchSize)
+	for i := range fullBatch {
+		fullBatch[i] = fmt.Sprintf("deployment-%d", i)
+	}
+
+	testCases := map[string]struct {
+		batches  [][]string
+		findErr  error
+		abortErr error
+
+		aborted *model.AbortedDeployments
+		err     error
+	}{
+		"ok, none": {
+			batches: [][]string{nil},
+			aborted: &model.AbortedDeployments{},
+		},
+		"ok, several batches": {
+			batches: [][]string{fullBatch, {"last"}},
+			aborted: &model.AbortedDeployments{
+				Deployments: abortDeploymentsBatchSize + 1,
+				Devices:     2 * (abortDeploymentsBatchSize + 1),
+			},
+		},
+		"error, find": {
+			findErr: errInternal,
+			aborted: &model.AbortedDeployments{},
+			err: errors.New(
+				"failed to find the deployments to abort: internal error"),
+		},
+		"error, abort": {
+			batches:  [][]string{{"first"}},
+			abortErr: errInternal,
+			aborted:  &model.AbortedDeployments{},
+			err:      errors.New("failed to abort deployment first: internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+
+			if tc.findErr != nil {
+				db.On("FindUnfinishedIDsByArtifactName",
+					ctx, artifactName, abortDeploymentsBatchSize).
+					Return(nil, tc.findErr).Once()
+			}
+			for _, batch := range tc.batches {
+				db.On("FindUnfinishedIDsByArtifactName",
+					ctx, artifactName, abortDeploymentsBatchSize).
+					Return(batch, nil).Once()
+				for _, id := range batch {
+					db.On("AbortDeviceDeployments", ctx, id).
+						Return(int64(2), tc.abortErr).Once()
+					if tc.abortErr != nil {
+						continue
+					}
+					db.On("SetDeploymentAborted", ctx, id, "",
+						mock.AnythingOfType("time.Time")).
+						Return(nil).Once()
+					stats := model.Stats{model.DeviceDeploymentStatusAbortedStr: 2}
+					db.On("AggregateDeviceDeploymentByStatus", ctx, id).
+						Return(stats, nil).Once()
+					db.On("UpdateStats", ctx, id, stats).
+						Return(nil).Once()
+					db.On("SetDeploymentStatus", ctx, id,
+						model.DeploymentStatusFinished, mock.AnythingOfType("time.Time")).
+						Return(true, nil).Once()
+				}
+			}
+
+			d := NewDeployments(db, nil, 0, false)
+			aborted, err := d.AbortDeploymentsByArtifactName(ctx, artifactName)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.aborted, aborted)
+		})
+	}
+}
+
 func TestDeleteDeviceDeploymentsHistory(t *testing.T) {
 	t.Parallel()
 	f := false
@@ -1366,7 +2437,167 @@ func TestImageUsedInActiveDeployment(t *testing.T) {
 
 }
 
-func TestGetDeviceDeploymentListForDevice(t *testing.T) {
+func TestDeleteImageRecordsDeletion(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "user-1",
+		Tenant:  "tenant-1",
+	})
+	image := &model.Image{
+		Id:           "image-1",
+		ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+	}
+	errInsert := errors.New("insert failed")
+
+	testCases := map[string]struct {
+		InsertError error
+		Error       error
+	}{
+		"ok": {},
+		"error recording the deletion": {
+			InsertError: errInsert,
+			Error:       errInsert,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			objStore := &fs_mocks.ObjectStorage{}
+			defer objStore.AssertExpectations(t)
+
+			db.On("FindImageByID", ctx, image.Id).Return(image, nil)
+			db.On("ExistUnfinishedByArtifactId", ctx, image.Id).Return(false, nil)
+			db.On("ExistAssignedImageWithIDAndStatuses", ctx, image.Id,
+				model.ActiveDeploymentStatuses()).Return(false, nil)
+			db.On("GetStorageSettings", ctx).Return(nil, nil)
+			db.On("InsertArtifactDeletion", h.ContextMatcher(),
+				mock.MatchedBy(func(deletion *model.ArtifactDeletion) bool {
+					return deletion.ID != "" &&
+						deletion.ArtifactID == image.Id &&
+						deletion.ArtifactName == "release-1" &&
+						deletion.StorageKey == "tenant-1/"+image.Id &&
+						deletion.DeletedBy == "user-1" &&
+						!deletion.Deleted.IsZero()
+				})).
+				Return(tc.InsertError)
+			if tc.InsertError == nil {
+				objStore.On("DeleteObject", h.ContextMatcher(), "tenant-1/"+image.Id).
+					Return(nil)
+				db.On("DeleteImage", h.ContextMatcher(), image.Id).Return(nil)
+				db.On("UpdateReleaseArtifacts", h.ContextMatcher(),
+					(*model.Image)(nil), image, "release-1").
+					Return(nil)
+			}
+
+			ds := NewDeployments(db, objStore, 0, false)
+			err := ds.DeleteImage(ctx, image.Id)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeleteImageAssigned(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	image := &model.Image{
+		Id:           "image-1",
+		ArtifactMeta: &model.ArtifactMeta{Name: "release-1"},
+	}
+	errInternal := errors.New("internal error")
+	// the devices downloading or installing the image must be covered
+	activeStatuses := mock.MatchedBy(func(statuses []model.DeviceDeploymentStatus) bool {
+		var downloading, installing bool
+		for _, status := range statuses {
+			downloading = downloading || status == model.DeviceDeploymentStatusDownloading
+			installing = installing || status == model.DeviceDeploymentStatusInstalling
+		}
+		return downloading && installing
+	})
+
+	testCases := map[string]struct {
+		Clear bool
+
+		Assigned    bool
+		AssignedErr error
+		Cleared     int64
+		ClearErr    error
+
+		Deleted bool
+		Error   error
+	}{
+		"block, not assigned": {
+			Deleted: true,
+		},
+		"block, assigned": {
+			Assigned: true,
+			Error:    ErrModelImageInActiveDeployment,
+		},
+		"block, error": {
+			AssignedErr: errInternal,
+			Error:       errInternal,
+		},
+		"clear, assigned": {
+			Clear:   true,
+			Cleared: 2,
+			Deleted: true,
+		},
+		"clear, error": {
+			Clear:    true,
+			ClearErr: errInternal,
+			Error:    errInternal,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			objStore := &fs_mocks.ObjectStorage{}
+			defer objStore.AssertExpectations(t)
+
+			db.On("FindImageByID", ctx, image.Id).Return(image, nil)
+			db.On("ExistUnfinishedByArtifactId", ctx, image.Id).Return(false, nil)
+			if tc.Clear {
+				db.On("ClearAssignedImage", ctx, image.Id, activeStatuses).
+					Return(tc.Cleared, tc.ClearErr)
+			} else {
+				db.On("ExistAssignedImageWithIDAndStatuses", ctx, image.Id,
+					activeStatuses).Return(tc.Assigned, tc.AssignedErr)
+			}
+			if tc.Deleted {
+				db.On("GetStorageSettings", ctx).Return(nil, nil)
+				objStore.On("DeleteObject", h.ContextMatcher(), image.Id).
+					Return(nil)
+				db.On("DeleteImage", h.ContextMatcher(), image.Id).Return(nil)
+				db.On("InsertArtifactDeletion", h.ContextMatcher(),
+					mock.AnythingOfType("*model.ArtifactDeletion")).
+					Return(nil)
+				db.On("UpdateReleaseArtifacts", h.ContextMatcher(),
+					(*model.Image)(nil), image, "release-1").
+					Return(nil)
+			}
+
+			ds := NewDeployments(db, objStore, 0, false).
+				WithClearAssignedImages(tc.Clear)
+			err := ds.DeleteImage(ctx, image.Id)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetDeviceDeploymentListForDevice(t *testing.T) {
 	const deviceID = "device_id"
 	testCases := map[string]struct {
 		query                  store.ListQueryDeviceDeployments
@@ -1592,6 +2823,33 @@ func TestGetDeviceDeploymentListForDevice(t *testing.T) {
 	}
 }
 
+func TestGetDeviceDeploymentViewsForDevice(t *testing.T) {
+	ctx := context.Background()
+	query := store.ListQueryDeviceDeployments{DeviceID: "device_id", Limit: 20}
+	views := []model.DeviceDeploymentView{{
+		ID:             "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		DeviceID:       "device_id",
+		DeploymentName: "production",
+		ArtifactName:   "release-1",
+	}}
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("GetDeviceDeploymentViewsForDevice", ctx, query).Return(views, 3, nil).Once()
+	db.On("GetDeviceDeploymentViewsForDevice", ctx, query).
+		Return(nil, -1, errors.New("error")).Once()
+
+	ds := NewDeployments(db, nil, 0, false)
+	res, count, err := ds.GetDeviceDeploymentViewsForDevice(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, views, res)
+	assert.Equal(t, 3, count)
+
+	_, count, err = ds.GetDeviceDeploymentViewsForDevice(ctx, query)
+	assert.EqualError(t, err, "retrieving the list of deployment statuses: error")
+	assert.Equal(t, -1, count)
+}
+
 func TestUpdateDeploymentsWithArtifactName(t *testing.T) {
 	t.Parallel()
 
@@ -1715,7 +2973,8 @@ func TestReindexDeployment(t *testing.T) {
 			name: "ok",
 			workflowsMock: func() workflows.Client {
 				wf := &workflows_mocks.Client{}
-				wf.On("StartReindexReportingDeployment", ctx, deviceID, deploymentID, ID).Return(nil)
+				wf.On("StartReindexReportingDeployment", ctx, deviceID, deploymentID, ID,
+					[]model.DeviceAttribute(nil)).Return(nil)
 				return wf
 			},
 		},
@@ -1723,7 +2982,8 @@ func TestReindexDeployment(t *testing.T) {
 			name: "ko",
 			workflowsMock: func() workflows.Client {
 				wf := &workflows_mocks.Client{}
-				wf.On("StartReindexReportingDeployment", ctx, deviceID, deploymentID, ID).Return(errors.New("error"))
+				wf.On("StartReindexReportingDeployment", ctx, deviceID, deploymentID, ID,
+					[]model.DeviceAttribute(nil)).Return(errors.New("error"))
 				return wf
 			},
 			err: errors.New("error"),
@@ -1748,3 +3008,1420 @@ func TestReindexDeployment(t *testing.T) {
 		})
 	}
 }
+
+func TestGetImageForDevice(t *testing.T) {
+	t.Parallel()
+
+	const imageID = "5c1b0a5a-5d6c-4a7e-9a3b-2f1c9d8e7f60"
+	ctx := context.Background()
+	image := &model.Image{Id: imageID}
+	errInternal := errors.New("connection refused")
+
+	testCases := map[string]struct {
+		deviceDeployment *model.DeviceDeployment
+		findErr          error
+		callGetImage     bool
+		image            *model.Image
+
+		err error
+	}{
+		"ok": {
+			deviceDeployment: &model.DeviceDeployment{Image: image},
+			callGetImage:     true,
+			image:            image,
+		},
+		"error, no active deployment": {
+			err: ErrArtifactNotAssigned,
+		},
+		"error, deployment not started yet": {
+			deviceDeployment: &model.DeviceDeployment{},
+			err:              ErrArtifactNotAssigned,
+		},
+		"error, other artifact": {
+			deviceDeployment: &model.DeviceDeployment{
+				Image: &model.Image{Id: "d2b9ba66-a7ed-4b9d-8f0e-2b8d9f4f05cb"},
+			},
+			err: ErrArtifactNotAssigned,
+		},
+		"error, artifact deleted": {
+			deviceDeployment: &model.DeviceDeployment{Image: image},
+			callGetImage:     true,
+			err:              ErrArtifactNotAssigned,
+		},
+		"error, internal": {
+			findErr: errInternal,
+			err:     errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindOldestActiveDeviceDeployment", ctx, validUUIDv4).
+				Return(tc.deviceDeployment, tc.findErr)
+			if tc.callGetImage {
+				ds.On("FindImageByID", ctx, imageID).Return(tc.image, nil)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, err := deploy.GetImageForDevice(ctx, validUUIDv4, imageID)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Nil(t, res)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.image, res)
+			}
+		})
+	}
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "123456789012345678901234",
+	})
+	imagePath := "123456789012345678901234/" + validUUIDv4
+
+	testCases := map[string]struct {
+		offset    int64
+		length    int64
+		bandwidth uint64
+
+		limitErr   error
+		storageErr error
+		err        error
+	}{
+		"ok, whole file": {
+			length: -1,
+		},
+		"ok, throttled": {
+			length: -1,
+			// the test file, less the initial burst of a tenth of the
+			// bandwidth, is read in 150ms
+			bandwidth: 64 * 1024,
+		},
+		"error, getting the bandwidth limit": {
+			length:   -1,
+			limitErr: errors.New("connection refused"),
+		},
+		"ok, range": {
+			offset: 10,
+			length: 20,
+		},
+		"error, range not satisfiable": {
+			offset:     100,
+			length:     20,
+			storageErr: storage.ErrInvalidRange,
+			err:        ErrArtifactRangeNotSatisfiable,
+		},
+		"error, not found": {
+			length:     -1,
+			storageErr: storage.ErrObjectNotFound,
+			err:        ErrUploadNotFound,
+		},
+		"error, internal": {
+			offset:     10,
+			length:     20,
+			storageErr: errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			objStore := new(fs_mocks.ObjectStorage)
+			defer objStore.AssertExpectations(t)
+
+			data := bytes.Repeat([]byte{'x'}, 16*1024)
+			var body io.ReadCloser
+			if tc.storageErr == nil {
+				body = io.NopCloser(bytes.NewReader(data))
+			}
+			ds.On("GetStorageSettings", ctx).Return(nil, nil)
+			ds.On("GetLimit", h.ContextMatcher(), model.LimitDownloadBandwidth).
+				Return(&model.Limit{
+					Name:  model.LimitDownloadBandwidth,
+					Value: tc.bandwidth,
+				}, tc.limitErr)
+			switch {
+			case tc.limitErr != nil:
+				// the storage is not reached
+			case tc.length < 0:
+				objStore.On("GetObject", h.ContextMatcher(), imagePath).
+					Return(body, tc.storageErr)
+			default:
+				objStore.On("GetObjectRange", h.ContextMatcher(), imagePath,
+					tc.offset, tc.length).
+					Return(body, tc.storageErr)
+			}
+
+			deploy := NewDeployments(ds, objStore, 0, false)
+			res, err := deploy.DownloadArtifact(ctx, &model.Image{Id: validUUIDv4}, tc.offset, tc.length)
+			switch {
+			case tc.err != nil:
+				assert.Equal(t, tc.err, err)
+				assert.Nil(t, res)
+			case tc.limitErr != nil:
+				assert.ErrorIs(t, err, tc.limitErr)
+				assert.Nil(t, res)
+			case tc.storageErr != nil:
+				assert.ErrorIs(t, err, tc.storageErr)
+				assert.Nil(t, res)
+			case tc.bandwidth > 0:
+				assert.NoError(t, err)
+				start := time.Now()
+				b, err := io.ReadAll(res)
+				assert.NoError(t, err)
+				assert.Equal(t, data, b)
+				assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond,
+					"the download must not exceed the bandwidth limit")
+				assert.NoError(t, res.Close())
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, body, res)
+			}
+		})
+	}
+}
+
+func TestDownloadArtifactSharedBandwidth(t *testing.T) {
+	t.Parallel()
+
+	const bandwidth = 64 * 1024
+	tenantCtx := func(tenantID string) context.Context {
+		return identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: tenantID,
+		})
+	}
+	data := bytes.Repeat([]byte{'x'}, bandwidth/4)
+
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	objStore := new(fs_mocks.ObjectStorage)
+	defer objStore.AssertExpectations(t)
+	ds.On("GetStorageSettings", h.ContextMatcher()).Return(nil, nil)
+	ds.On("GetLimit", h.ContextMatcher(), model.LimitDownloadBandwidth).
+		Return(&model.Limit{Name: model.LimitDownloadBandwidth, Value: bandwidth}, nil)
+	objStore.On("GetObject", h.ContextMatcher(), mock.AnythingOfType("string")).
+		Return(func(context.Context, string) io.ReadCloser {
+			return io.NopCloser(bytes.NewReader(data))
+		}, nil)
+
+	deploy := NewDeployments(ds, objStore, 0, false)
+	download := func(ctx context.Context) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := deploy.DownloadArtifact(ctx,
+					&model.Image{Id: validUUIDv4}, 0, -1)
+				if assert.NoError(t, err) {
+					b, err := io.ReadAll(res)
+					assert.NoError(t, err)
+					assert.Equal(t, data, b)
+					assert.NoError(t, res.Close())
+				}
+			}()
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	// two downloads of a quarter of the bandwidth, less the initial burst
+	// of a tenth, take at least 400ms together
+	assert.GreaterOrEqual(t, download(tenantCtx("tenant1")), 390*time.Millisecond,
+		"the downloads of the tenant must share the bandwidth limit")
+	assert.Same(t,
+		deploy.downloadLimiters.get("tenant1", bandwidth),
+		deploy.downloadLimiters.get("tenant1", bandwidth))
+	assert.NotSame(t,
+		deploy.downloadLimiters.get("tenant1", bandwidth),
+		deploy.downloadLimiters.get("tenant2", bandwidth),
+		"the tenants must not share the bandwidth limit")
+	assert.Nil(t, deploy.downloadLimiters.get("tenant2", 0))
+}
+
+func TestGetDeploymentTargetDevices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	devices := []string{"device-1", "device-2"}
+
+	testCases := map[string]struct {
+		dbDevices []string
+		dbCount   int
+		dbErr     error
+
+		err error
+	}{
+		"ok": {
+			dbDevices: devices,
+			dbCount:   5,
+		},
+		"error, deployment not found": {
+			dbCount: -1,
+			dbErr:   store.ErrNotFound,
+			err:     ErrModelDeploymentNotFound,
+		},
+		"error, internal": {
+			dbCount: -1,
+			dbErr:   errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("GetDeploymentTargetDevices", ctx, validUUIDv4, 2, 2).
+				Return(tc.dbDevices, tc.dbCount, tc.dbErr)
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, count, err := deploy.GetDeploymentTargetDevices(ctx, validUUIDv4, 2, 2)
+			switch {
+			case tc.err != nil:
+				assert.Equal(t, tc.err, err)
+			case tc.dbErr != nil:
+				assert.ErrorIs(t, err, tc.dbErr)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, devices, res)
+				assert.Equal(t, tc.dbCount, count)
+			}
+		})
+	}
+}
+
+func TestGetFleetSoftwareInventory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	query := model.SoftwareInventoryQuery{ArtifactName: "release-1", Skip: 2, Limit: 2}
+	inventory := []model.DeviceSoftware{{
+		DeviceID:     "device-1",
+		ArtifactName: "release-1",
+	}}
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+		ds := new(mocks.DataStore)
+		defer ds.AssertExpectations(t)
+		ds.On("GetFleetSoftwareInventory", ctx, query).
+			Return(inventory, 3, nil)
+
+		deploy := NewDeployments(ds, nil, 0, false)
+		res, count, err := deploy.GetFleetSoftwareInventory(ctx, query)
+		assert.NoError(t, err)
+		assert.Equal(t, inventory, res)
+		assert.Equal(t, 3, count)
+	})
+	t.Run("error, internal", func(t *testing.T) {
+		t.Parallel()
+		dbErr := errors.New("connection refused")
+		ds := new(mocks.DataStore)
+		defer ds.AssertExpectations(t)
+		ds.On("GetFleetSoftwareInventory", ctx, query).
+			Return(nil, -1, dbErr)
+
+		deploy := NewDeployments(ds, nil, 0, false)
+		_, _, err := deploy.GetFleetSoftwareInventory(ctx, query)
+		assert.ErrorIs(t, err, dbErr)
+	})
+}
+
+func TestGetDeploymentCreationTrend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	buckets := []model.TrendBucket{{Time: from, Count: 2}}
+
+	testCases := map[string]struct {
+		dbBuckets []model.TrendBucket
+		dbErr     error
+	}{
+		"ok": {
+			dbBuckets: buckets,
+		},
+		"error, internal": {
+			dbErr: errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("GetDeploymentCreationTrend", ctx, from, to, model.TrendGranularityDay).
+				Return(tc.dbBuckets, tc.dbErr)
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, err := deploy.GetDeploymentCreationTrend(
+				ctx, from, to, model.TrendGranularityDay,
+			)
+			if tc.dbErr != nil {
+				assert.ErrorIs(t, err, tc.dbErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, buckets, res)
+			}
+		})
+	}
+}
+
+func TestGetLargestDeployments(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deviceCount := 250
+	deployments := []*model.Deployment{{Id: validUUIDv4, DeviceCount: &deviceCount}}
+
+	testCases := map[string]struct {
+		dbDeployments []*model.Deployment
+		dbErr         error
+	}{
+		"ok": {
+			dbDeployments: deployments,
+		},
+		"error, internal": {
+			dbErr: errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("GetLargestDeployments", ctx, 5).
+				Return(tc.dbDeployments, tc.dbErr)
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, err := deploy.GetLargestDeployments(ctx, 5)
+			if tc.dbErr != nil {
+				assert.ErrorIs(t, err, tc.dbErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, deployments, res)
+			}
+		})
+	}
+}
+
+func TestCountDeploymentsByStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		dbCounts map[model.DeploymentStatus]int
+		dbErr    error
+
+		counts map[model.DeploymentStatus]int
+	}{
+		"ok": {
+			dbCounts: map[model.DeploymentStatus]int{
+				model.DeploymentStatusPending:  3,
+				model.DeploymentStatusFinished: 10,
+			},
+			counts: map[model.DeploymentStatus]int{
+				model.DeploymentStatusPaused:     0,
+				model.DeploymentStatusPending:    3,
+				model.DeploymentStatusInProgress: 0,
+				model.DeploymentStatusFinished:   10,
+			},
+		},
+		"ok, no deployments": {
+			dbCounts: map[model.DeploymentStatus]int{},
+			counts: map[model.DeploymentStatus]int{
+				model.DeploymentStatusPaused:     0,
+				model.DeploymentStatusPending:    0,
+				model.DeploymentStatusInProgress: 0,
+				model.DeploymentStatusFinished:   0,
+			},
+		},
+		"error, internal": {
+			dbErr: errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("CountDeploymentsByStatus", ctx).
+				Return(tc.dbCounts, tc.dbErr)
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, err := deploy.CountDeploymentsByStatus(ctx)
+			if tc.dbErr != nil {
+				assert.ErrorIs(t, err, tc.dbErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.counts, res)
+			}
+		})
+	}
+}
+
+func TestDeleteDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errInternal := errors.New("connection refused")
+
+	testCases := map[string]struct {
+		deployment *model.Deployment
+		findErr    error
+		abortErr   error
+		deleteErr  error
+
+		err error
+	}{
+		"ok, finished": {
+			deployment: &model.Deployment{Id: validUUIDv4},
+		},
+		"ok, active deployment is aborted": {
+			deployment: &model.Deployment{Id: validUUIDv4, Active: true},
+		},
+		"error, not found": {
+			err: ErrModelDeploymentNotFound,
+		},
+		"error, finding the deployment": {
+			findErr: errInternal,
+			err:     errInternal,
+		},
+		"error, aborting the deployment": {
+			deployment: &model.Deployment{Id: validUUIDv4, Active: true},
+			abortErr:   errInternal,
+			err:        errInternal,
+		},
+		"error, deleting the deployment": {
+			deployment: &model.Deployment{Id: validUUIDv4},
+			deleteErr:  errInternal,
+			err:        errInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindDeploymentByID", ctx, validUUIDv4, false).
+				Return(tc.deployment, tc.findErr)
+			if tc.deployment != nil && tc.deployment.Active {
+				ds.On("AbortDeviceDeployments", ctx, validUUIDv4).
+					Return(int64(1), tc.abortErr)
+				if tc.abortErr == nil {
+					ds.On("SetDeploymentAborted", ctx, validUUIDv4,
+						"", mock.AnythingOfType("time.Time")).
+						Return(nil)
+					ds.On("AggregateDeviceDeploymentByStatus", ctx, validUUIDv4).
+						Return(model.Stats{model.DeviceDeploymentStatusAbortedStr: 1}, nil)
+					ds.On("UpdateStats", ctx, validUUIDv4,
+						mock.AnythingOfType("model.Stats")).
+						Return(nil)
+					ds.On("SetDeploymentStatus", ctx, validUUIDv4,
+						model.DeploymentStatusFinished, mock.AnythingOfType("time.Time")).
+						Return(true, nil)
+				}
+			}
+			if tc.deployment != nil && tc.abortErr == nil {
+				ds.On("DeleteDeployment", ctx, validUUIDv4).Return(tc.deleteErr)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			err := deploy.DeleteDeployment(ctx, validUUIDv4)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentForDeviceOfDeletedDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deviceDeployment := model.NewDeviceDeployment(validUUIDv4, "deleted")
+	deviceDeployment.Status = model.DeviceDeploymentStatusDownloading
+
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	ds.On("FindAndClaimOldestActiveDeviceDeployment", ctx, validUUIDv4).
+		Return(deviceDeployment, model.DeviceDeploymentStatusPending, nil).Once()
+	ds.On("FindDeploymentByID", ctx, "deleted", false).
+		Return(nil, nil)
+	ds.On("UpdateDeviceDeploymentStatus", ctx, validUUIDv4, "deleted",
+		mock.MatchedBy(func(state model.DeviceDeploymentState) bool {
+			return state.Status == model.DeviceDeploymentStatusAborted &&
+				state.FinishTime != nil
+		}),
+		model.DeviceDeploymentStatusDownloading,
+	).Return(model.DeviceDeploymentStatusDownloading, nil)
+	ds.On("FindAndClaimOldestActiveDeviceDeployment", ctx, validUUIDv4).
+		Return(nil, model.DeviceDeploymentStatusNull, nil).Once()
+	ds.On("FindLatestInactiveDeviceDeployment", ctx, validUUIDv4).
+		Return(nil, nil)
+	ds.On("FindNewerActiveDeployment", ctx, mock.Anything, validUUIDv4).
+		Return(nil, nil)
+
+	// the left-over device deployment is aborted instead of failing the request
+	deploy := NewDeployments(ds, nil, 0, false)
+	instructions, err := deploy.GetDeploymentForDeviceWithCurrent(ctx, validUUIDv4,
+		&model.DeploymentNextRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, instructions)
+}
+
+func TestGetDeploymentForDeviceClaim(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		prevStatus model.DeviceDeploymentStatus
+
+		countClaim bool
+	}{
+		"ok, claimed from pending": {
+			prevStatus: model.DeviceDeploymentStatusPending,
+			countClaim: true,
+		},
+		"ok, claimed already by a previous request": {
+			prevStatus: model.DeviceDeploymentStatusDownloading,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			deployment := &model.Deployment{
+				DeploymentConstructor: &model.DeploymentConstructor{},
+				Id:                    "deployment",
+				Stats:                 model.NewDeviceDeploymentStats(),
+			}
+			if !tc.countClaim {
+				deployment.Stats.Set(model.DeviceDeploymentStatusDownloading, 1)
+			}
+			deviceDeployment := model.NewDeviceDeployment(validUUIDv4, "deployment")
+			deviceDeployment.Status = model.DeviceDeploymentStatusDownloading
+			deviceDeployment.Image = &model.Image{
+				Id:           "artifact",
+				ArtifactMeta: &model.ArtifactMeta{Name: "artifact"},
+			}
+			request := &model.DeploymentNextRequest{
+				DeviceProvides: &model.InstalledDeviceDeployment{
+					ArtifactName: "installed",
+					DeviceType:   "rpi4",
+				},
+			}
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			objStore := new(fs_mocks.ObjectStorage)
+			defer objStore.AssertExpectations(t)
+			ds.On("FindAndClaimOldestActiveDeviceDeployment", ctx, validUUIDv4).
+				Return(deviceDeployment, tc.prevStatus, nil)
+			ds.On("FindDeploymentByID", ctx, "deployment", false).
+				Return(deployment, nil)
+			if tc.countClaim {
+				stats := model.NewDeviceDeploymentStats()
+				stats.Set(model.DeviceDeploymentStatusDownloading, 1)
+				ds.On("UpdateStatsInc", ctx, "deployment",
+					model.DeviceDeploymentStatusPending,
+					model.DeviceDeploymentStatusDownloading,
+				).Return(stats, nil).Once()
+				ds.On("SetDeploymentStatus", ctx, "deployment",
+					model.DeploymentStatusInProgress, mock.AnythingOfType("time.Time"),
+				).Return(true, nil).Once()
+			}
+			ds.On("SaveDeviceDeploymentRequest", ctx, deviceDeployment.Id, request).
+				Return(nil)
+			ds.On("GetStorageSettings", ctx).Return(nil, nil)
+			objStore.On("GetRequest", h.ContextMatcher(),
+				model.ImagePathFromContext(ctx, "artifact"),
+				"artifact"+model.ArtifactFileSuffix,
+				mock.AnythingOfType("time.Duration"),
+			).Return(&model.Link{Uri: "https://example.com/artifact"}, nil)
+
+			// only the request which claimed it counts the device deployment
+			deploy := NewDeployments(ds, objStore, 0, false)
+			instructions, err := deploy.GetDeploymentForDeviceWithCurrent(ctx,
+				validUUIDv4, request)
+			assert.NoError(t, err)
+			if assert.NotNil(t, instructions) {
+				assert.Equal(t, "deployment", instructions.ID)
+				assert.Equal(t, "artifact", instructions.Artifact.ID)
+			}
+		})
+	}
+}
+
+func TestRestoreDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		retention time.Duration
+		dbErr     error
+
+		err error
+	}{
+		"ok": {
+			retention: 24 * time.Hour,
+		},
+		"ok, unlimited retention": {},
+		"error, not found": {
+			retention: 24 * time.Hour,
+			dbErr:     store.ErrNotFound,
+			err:       ErrModelDeploymentNotFound,
+		},
+		"error, internal": {
+			dbErr: errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("RestoreDeployment", ctx, validUUIDv4,
+				mock.MatchedBy(func(deletedAfter time.Time) bool {
+					if tc.retention == 0 {
+						return deletedAfter.IsZero()
+					}
+					return assert.WithinDuration(t,
+						time.Now().Add(-tc.retention), deletedAfter, time.Minute)
+				})).
+				Return(tc.dbErr)
+
+			deploy := NewDeployments(ds, nil, 0, false).
+				WithDeletedDeploymentsRetention(tc.retention)
+			err := deploy.RestoreDeployment(ctx, validUUIDv4)
+			switch {
+			case tc.err != nil:
+				assert.Equal(t, tc.err, err)
+			case tc.dbErr != nil:
+				assert.ErrorIs(t, err, tc.dbErr)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStartDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	phaseStart := time.Now().Add(time.Hour)
+
+	testCases := map[string]struct {
+		deployment *model.Deployment
+		findErr    error
+		startErr   error
+
+		err error
+	}{
+		"ok": {
+			deployment: &model.Deployment{
+				Id:     validUUIDv4,
+				Status: model.DeploymentStatusPaused,
+			},
+		},
+		"ok, phased": {
+			deployment: &model.Deployment{
+				Id:     validUUIDv4,
+				Status: model.DeploymentStatusPaused,
+				DeploymentConstructor: &model.DeploymentConstructor{
+					Phases: model.DeploymentPhases{
+						{BatchSizePercent: 50, DeviceCount: 1},
+						{StartTs: &phaseStart, DeviceCount: 1},
+					},
+				},
+			},
+		},
+		"error, not found": {
+			err: ErrModelDeploymentNotFound,
+		},
+		"error, already started": {
+			deployment: &model.Deployment{
+				Id:     validUUIDv4,
+				Status: model.DeploymentStatusInProgress,
+			},
+			err: ErrDeploymentNotPaused,
+		},
+		"error, started concurrently": {
+			deployment: &model.Deployment{
+				Id:     validUUIDv4,
+				Status: model.DeploymentStatusPaused,
+			},
+			startErr: store.ErrNotFound,
+			err:      ErrDeploymentNotPaused,
+		},
+		"error, find": {
+			findErr: errors.New("connection refused"),
+			err:     errors.New("Searching for deployment by ID: connection refused"),
+		},
+		"error, start": {
+			deployment: &model.Deployment{
+				Id:     validUUIDv4,
+				Status: model.DeploymentStatusPaused,
+			},
+			startErr: errors.New("connection refused"),
+			err:      errors.New("starting the deployment: connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindDeploymentByID", ctx, validUUIDv4, false).
+				Return(tc.deployment, tc.findErr)
+			if tc.deployment != nil && tc.deployment.Status == model.DeploymentStatusPaused {
+				var phases model.DeploymentPhases
+				if tc.deployment.DeploymentConstructor != nil {
+					phases = tc.deployment.Phases
+				}
+				ds.On("StartDeployment", ctx, validUUIDv4,
+					mock.AnythingOfType("time.Time"),
+					mock.MatchedBy(func(p model.DeploymentPhases) bool {
+						if len(p) != len(phases) {
+							return false
+						}
+						// the first phase starts with the deployment
+						return len(p) == 0 || p[0].StartTs != nil &&
+							p[1].StartTs == &phaseStart
+					})).
+					Return(tc.startErr)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			err := deploy.StartDeployment(ctx, validUUIDv4)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUpdateDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	name := "NYC Production"
+
+	testCases := map[string]struct {
+		patch     model.DeploymentPatch
+		callStore bool
+		updateErr error
+
+		err error
+	}{
+		"ok": {
+			patch:     model.DeploymentPatch{Name: &name},
+			callStore: true,
+		},
+		"error, invalid patch": {
+			err: errors.New("Validating deployment patch: " +
+				model.ErrEmptyDeploymentPatch.Error()),
+		},
+		"error, not found": {
+			patch:     model.DeploymentPatch{Name: &name},
+			callStore: true,
+			updateErr: store.ErrNotFound,
+			err:       ErrModelDeploymentNotFound,
+		},
+		"error, update": {
+			patch:     model.DeploymentPatch{Name: &name},
+			callStore: true,
+			updateErr: errors.New("connection refused"),
+			err:       errors.New("updating the deployment: connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			if tc.callStore {
+				ds.On("UpdateDeployment", ctx, validUUIDv4, tc.patch).
+					Return(tc.updateErr)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			err := deploy.UpdateDeployment(ctx, validUUIDv4, tc.patch)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetActiveDeploymentsForDevice(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lastCreated := time.Now().Add(-time.Hour)
+	deployments := []*model.Deployment{{
+		// the device is enrolled in it
+		Id:     "static",
+		Status: model.DeploymentStatusInProgress,
+	}, {
+		// the device matches it, but did not pick it up yet
+		Id:     "dynamic",
+		Status: model.DeploymentStatusPending,
+	}}
+
+	testCases := map[string]struct {
+		lastInactive *model.DeviceDeployment
+		inactiveErr  error
+		findErr      error
+
+		deployments []*model.Deployment
+		err         error
+	}{
+		"ok": {
+			lastInactive: &model.DeviceDeployment{Created: &lastCreated},
+			deployments:  deployments,
+		},
+		"ok, no previous deployment": {
+			deployments: deployments,
+		},
+		"ok, no deployments": {
+			deployments: []*model.Deployment{},
+		},
+		"error, searching inactive device deployments": {
+			inactiveErr: errors.New("connection refused"),
+			err:         errors.New("connection refused"),
+		},
+		"error, searching deployments": {
+			findErr: errors.New("connection refused"),
+			err:     errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindLatestInactiveDeviceDeployment", ctx, validUUIDv4).
+				Return(tc.lastInactive, tc.inactiveErr)
+			if tc.inactiveErr == nil {
+				ds.On("FindActiveDeploymentsForDevice", ctx, validUUIDv4,
+					mock.MatchedBy(func(created *time.Time) bool {
+						if tc.lastInactive == nil {
+							return created.IsZero()
+						}
+						return created.Equal(lastCreated)
+					})).
+					Return(tc.deployments, tc.findErr)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			res, err := deploy.GetActiveDeploymentsForDevice(ctx, validUUIDv4)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.deployments, res)
+			}
+		})
+	}
+}
+
+func TestCheckDeploymentForDevice(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lastCreated := time.Now().Add(-time.Hour)
+
+	testCases := map[string]struct {
+		activeID     string
+		activeErr    error
+		lastInactive *model.DeviceDeployment
+		inactiveErr  error
+		newer        *model.Deployment
+		newerErr     error
+
+		deploymentID string
+		err          error
+	}{
+		"ok, active device deployment": {
+			activeID:     "active",
+			deploymentID: "active",
+		},
+		"ok, newer deployment": {
+			lastInactive: &model.DeviceDeployment{Created: &lastCreated},
+			newer:        &model.Deployment{Id: "newer"},
+			deploymentID: "newer",
+		},
+		"ok, nothing pending": {},
+		"error, searching active device deployments": {
+			activeErr: errors.New("connection refused"),
+			err:       errors.New("connection refused"),
+		},
+		"error, searching inactive device deployments": {
+			inactiveErr: errors.New("connection refused"),
+			err:         errors.New("connection refused"),
+		},
+		"error, searching newer deployments": {
+			newerErr: errors.New("connection refused"),
+			err:      errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindOldestActiveDeviceDeploymentID", ctx, validUUIDv4).
+				Return(tc.activeID, tc.activeErr)
+			if tc.activeID == "" && tc.activeErr == nil {
+				ds.On("FindLatestInactiveDeviceDeployment", ctx, validUUIDv4).
+					Return(tc.lastInactive, tc.inactiveErr)
+				if tc.inactiveErr == nil {
+					ds.On("FindNewerActiveDeployment", ctx,
+						mock.MatchedBy(func(created *time.Time) bool {
+							if tc.lastInactive == nil {
+								return created.IsZero()
+							}
+							return created.Equal(lastCreated)
+						}), validUUIDv4).
+						Return(tc.newer, tc.newerErr)
+				}
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			deploymentID, err := deploy.CheckDeploymentForDevice(ctx, validUUIDv4)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.deploymentID, deploymentID)
+			}
+		})
+	}
+}
+
+func TestSaveDeviceDeploymentLog(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	const deviceID = "device"
+	messages := []model.LogMessage{{
+		Timestamp: func() *time.Time { t := time.Now(); return &t }(),
+		Level:     "error",
+		Message:   "installation failed",
+	}}
+
+	testCases := map[string]struct {
+		deviceDeployment *model.DeviceDeployment
+		getErr           error
+		saveErr          error
+
+		err error
+	}{
+		"ok, first attempt": {
+			deviceDeployment: &model.DeviceDeployment{},
+		},
+		"ok, retried": {
+			deviceDeployment: &model.DeviceDeployment{Attempts: 2},
+		},
+		"error, not found": {
+			getErr: mongo.ErrStorageNotFound,
+			err:    ErrModelDeploymentNotFound,
+		},
+		"error, internal": {
+			getErr: errors.New("connection refused"),
+			err:    errors.New("connection refused"),
+		},
+		"error, saving log": {
+			deviceDeployment: &model.DeviceDeployment{},
+			saveErr:          errors.New("connection refused"),
+			err:              errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("GetDeviceDeployment", ctx, validUUIDv4, deviceID, false).
+				Return(tc.deviceDeployment, tc.getErr)
+			if tc.deviceDeployment != nil {
+				ds.On("SaveDeviceDeploymentLog", ctx, model.DeploymentLog{
+					DeviceID:     deviceID,
+					DeploymentID: validUUIDv4,
+					Attempt:      tc.deviceDeployment.Attempts,
+					Messages:     messages,
+				}).Return(tc.saveErr)
+				if tc.saveErr == nil {
+					ds.On("UpdateDeviceDeploymentLogAvailability",
+						ctx, deviceID, validUUIDv4, true).
+						Return(nil)
+				}
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			err := deploy.SaveDeviceDeploymentLog(ctx, deviceID, validUUIDv4, messages)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStreamDeviceStatusesForDeployment(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	deviceDeployments := []model.DeviceDeployment{{
+		DeviceId: "device-1",
+		Status:   model.DeviceDeploymentStatusSuccess,
+	}, {
+		DeviceId: "device-2",
+		Status:   model.DeviceDeploymentStatusFailure,
+		SubState: "ArtifactInstall",
+	}}
+
+	t.Run("ok", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("IterateDeviceStatusesForDeployment", ctx, deploymentID).
+			Return(NewArrayIterator(deviceDeployments), nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		var streamed []model.DeviceDeployment
+		err := d.StreamDeviceStatusesForDeployment(ctx, deploymentID,
+			func(deviceDeployment *model.DeviceDeployment) error {
+				streamed = append(streamed, *deviceDeployment)
+				return nil
+			})
+		if assert.NoError(t, err) {
+			assert.Equal(t, deviceDeployments, streamed)
+		}
+	})
+	t.Run("error/write", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("IterateDeviceStatusesForDeployment", ctx, deploymentID).
+			Return(NewArrayIterator(deviceDeployments), nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		errWrite := errors.New("connection closed")
+		err := d.StreamDeviceStatusesForDeployment(ctx, deploymentID,
+			func(*model.DeviceDeployment) error {
+				return errWrite
+			})
+		assert.ErrorIs(t, err, errWrite)
+	})
+	t.Run("error/find", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		errInternal := errors.New("internal error")
+		db.On("IterateDeviceStatusesForDeployment", ctx, deploymentID).
+			Return(nil, errInternal)
+
+		d := NewDeployments(db, nil, 0, false)
+		err := d.StreamDeviceStatusesForDeployment(ctx, deploymentID,
+			func(*model.DeviceDeployment) error {
+				return nil
+			})
+		assert.ErrorIs(t, err, errInternal)
+	})
+}
+
+func TestStreamDevicesListForDeployment(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	query := store.ListQuery{DeploymentID: deploymentID}
+	deviceDeployments := []model.DeviceDeployment{{
+		DeviceId: "device-1",
+		Status:   model.DeviceDeploymentStatusSuccess,
+	}, {
+		DeviceId: "device-2",
+		Status:   model.DeviceDeploymentStatusFailure,
+	}}
+
+	t.Run("ok", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(&model.Deployment{Id: deploymentID}, nil)
+		db.On("IterateDevicesListForDeployment", ctx, query).
+			Return(NewArrayIterator(deviceDeployments), nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		var streamed []model.DeviceDeployment
+		err := d.StreamDevicesListForDeployment(ctx, query,
+			func(deviceDeployment *model.DeviceDeployment) error {
+				streamed = append(streamed, *deviceDeployment)
+				return nil
+			})
+		if assert.NoError(t, err) {
+			assert.Equal(t, deviceDeployments, streamed)
+		}
+	})
+	t.Run("error/not found", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(nil, nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		err := d.StreamDevicesListForDeployment(ctx, query,
+			func(*model.DeviceDeployment) error {
+				return nil
+			})
+		assert.ErrorIs(t, err, ErrModelDeploymentNotFound)
+	})
+	t.Run("error/write", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(&model.Deployment{Id: deploymentID}, nil)
+		db.On("IterateDevicesListForDeployment", ctx, query).
+			Return(NewArrayIterator(deviceDeployments), nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		errWrite := errors.New("connection closed")
+		err := d.StreamDevicesListForDeployment(ctx, query,
+			func(*model.DeviceDeployment) error {
+				return errWrite
+			})
+		assert.ErrorIs(t, err, errWrite)
+	})
+	t.Run("error/find", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		errInternal := errors.New("internal error")
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(&model.Deployment{Id: deploymentID}, nil)
+		db.On("IterateDevicesListForDeployment", ctx, query).
+			Return(nil, errInternal)
+
+		d := NewDeployments(db, nil, 0, false)
+		err := d.StreamDevicesListForDeployment(ctx, query,
+			func(*model.DeviceDeployment) error {
+				return nil
+			})
+		assert.ErrorIs(t, err, errInternal)
+	})
+}
+
+func TestReplaceImageTags(t *testing.T) {
+	t.Parallel()
+
+	const imageID = "a3719bc6-62af-4d65-b781-effa992048ba"
+	tags := model.Tags{"qa-approved"}
+
+	testCases := map[string]struct {
+		dbErr error
+		err   error
+	}{
+		"ok": {},
+		"error, not found": {
+			dbErr: store.ErrNotFound,
+			err:   ErrImageMetaNotFound,
+		},
+		"error, too many unique tags": {
+			dbErr: model.ErrTooManyUniqueTags,
+			err:   model.ErrTooManyUniqueTags,
+		},
+		"error, internal": {
+			dbErr: errors.New("mongo: internal error"),
+			err:   ErrModelInternal,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			ds := &mocks.DataStore{}
+			defer ds.AssertExpectations(t)
+			ds.On("ReplaceImageTags", ctx, imageID, tags).Return(tc.dbErr)
+
+			app := NewDeployments(ds, nil, 0, false)
+			err := app.ReplaceImageTags(ctx, imageID, tags)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReconcileDeviceCount(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86701"
+	intPtr := func(i int) *int { return &i }
+
+	testCases := map[string]struct {
+		deployment *model.Deployment
+		findErr    error
+		count      int
+		countErr   error
+		resetErr   error
+		callReset  bool
+
+		res *model.DeviceCountReconciliation
+		err error
+	}{
+		"ok, in sync": {
+			deployment: &model.Deployment{Id: deploymentID, DeviceCount: intPtr(3)},
+			count:      3,
+			res: &model.DeviceCountReconciliation{
+				DeploymentID:        deploymentID,
+				PreviousDeviceCount: intPtr(3),
+				DeviceCount:         3,
+			},
+		},
+		"ok, corrected": {
+			deployment: &model.Deployment{Id: deploymentID, DeviceCount: intPtr(5)},
+			count:      3,
+			callReset:  true,
+			res: &model.DeviceCountReconciliation{
+				DeploymentID:        deploymentID,
+				PreviousDeviceCount: intPtr(5),
+				DeviceCount:         3,
+				Corrected:           true,
+			},
+		},
+		"ok, count not set": {
+			deployment: &model.Deployment{Id: deploymentID},
+			count:      3,
+			callReset:  true,
+			res: &model.DeviceCountReconciliation{
+				DeploymentID: deploymentID,
+				DeviceCount:  3,
+				Corrected:    true,
+			},
+		},
+		"error, not found": {
+			err: ErrModelDeploymentNotFound,
+		},
+		"error, find": {
+			findErr: errors.New("mongo: internal error"),
+			err:     errors.New("searching for deployment by ID: mongo: internal error"),
+		},
+		"error, count": {
+			deployment: &model.Deployment{Id: deploymentID, DeviceCount: intPtr(5)},
+			countErr:   errors.New("mongo: internal error"),
+			err:        errors.New("counting device deployments: mongo: internal error"),
+		},
+		"error, deleted meanwhile": {
+			deployment: &model.Deployment{Id: deploymentID, DeviceCount: intPtr(5)},
+			count:      3,
+			callReset:  true,
+			resetErr:   store.ErrNotFound,
+			err:        ErrModelDeploymentNotFound,
+		},
+		"error, count changed meanwhile": {
+			deployment: &model.Deployment{Id: deploymentID, DeviceCount: intPtr(5)},
+			count:      3,
+			callReset:  true,
+			resetErr:   mongo.ErrDeviceCountChanged,
+			err:        ErrDeviceCountChanged,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("FindDeploymentByID", ctx, deploymentID, false).
+				Return(tc.deployment, tc.findErr)
+			if tc.deployment != nil {
+				db.On("DeviceCountByDeployment", ctx, deploymentID).
+					Return(tc.count, tc.countErr)
+			}
+			if tc.callReset {
+				db.On("ResetDeploymentDeviceCount",
+					ctx, deploymentID, tc.deployment.DeviceCount, tc.count,
+				).Return(tc.resetErr)
+			}
+
+			d := NewDeployments(db, nil, 0, false)
+			res, err := d.ReconcileDeviceCount(ctx, deploymentID)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+	"github.com/mendersoftware/deployments/store"
+)
+
+// CreateUploadSession starts a resumable upload: the artifact is uploaded
+// in parts, each with its own signed URL, and the parts reported as
+// uploaded survive a lost connection until the session expires.
+func (d *Deployments) CreateUploadSession(
+	ctx context.Context,
+	expire time.Duration,
+	skipVerify bool,
+) (*model.UploadSession, error) {
+	ctx, err := d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactID := uuid.New().String()
+	path := model.ImagePathFromContext(ctx, artifactID) + fileSuffixTmp
+	if skipVerify {
+		path = model.ImagePathFromContext(ctx, artifactID)
+	}
+	uploadID, err := d.objectStorage.CreateMultipartUpload(ctx, path)
+	if errors.Is(err, storage.ErrMultipartNotSupported) {
+		return nil, ErrResumableUploadNotSupported
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "app: failed to create multipart upload")
+	}
+	now := time.Now()
+	upLink := &model.UploadLink{
+		ArtifactID: artifactID,
+		IssuedAt:   now,
+		Link: model.Link{
+			Expire: now.Add(expire),
+		},
+		Multipart: &model.MultipartUpload{
+			UploadID: uploadID,
+			Path:     path,
+		},
+	}
+	err = d.db.InsertUploadIntent(ctx, upLink)
+	if err != nil {
+		return nil, errors.WithMessage(err, "app: error recording the upload intent")
+	}
+	return model.NewUploadSession(upLink), nil
+}
+
+// GetUploadSession returns the progress of an upload.
+func (d *Deployments) GetUploadSession(
+	ctx context.Context,
+	intentID string,
+) (*model.UploadSession, error) {
+	link, err := d.db.GetUploadIntent(ctx, intentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "app: failed to get the upload intent")
+	}
+	return model.NewUploadSession(link), nil
+}
+
+// pendingMultipartUpload returns the upload intent of a resumable upload
+// still accepting parts.
+func (d *Deployments) pendingMultipartUpload(
+	ctx context.Context,
+	intentID string,
+) (*model.UploadLink, error) {
+	link, err := d.db.GetUploadIntent(ctx, intentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "app: failed to get the upload intent")
+	}
+	if link.Status != model.LinkStatusPending || link.Expire.Before(time.Now()) {
+		return nil, ErrUploadNotFound
+	} else if link.Multipart == nil {
+		return nil, ErrUploadNotResumable
+	}
+	return link, nil
+}
+
+// UploadPartLink returns the signed URL uploading a part of a resumable
+// upload; uploading the same part again replaces it.
+func (d *Deployments) UploadPartLink(
+	ctx context.Context,
+	intentID string,
+	partNumber int,
+	expire time.Duration,
+) (*model.Link, error) {
+	ctx, err := d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, err := d.pendingMultipartUpload(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	partLink, err := d.objectStorage.UploadPartRequest(
+		ctx, link.Multipart.Path, link.Multipart.UploadID, partNumber, expire,
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "app: failed to generate signed URL")
+	}
+	return partLink, nil
+}
+
+// ReportUploadPart records a part uploaded by the client with the ETag
+// returned by the object storage.
+func (d *Deployments) ReportUploadPart(
+	ctx context.Context,
+	intentID string,
+	part model.UploadPart,
+) error {
+	if _, err := d.pendingMultipartUpload(ctx, intentID); err != nil {
+		return err
+	}
+	err := d.db.SetUploadIntentPart(ctx, intentID, part)
+	if errors.Is(err, store.ErrNotFound) {
+		// the upload was completed in the meantime
+		return ErrUploadNotFound
+	} else if err != nil {
+		return errors.WithMessage(err, "app: failed to record the uploaded part")
+	}
+	return nil
+}
+
+// assembleUploadParts completes the multipart upload of a resumable upload,
+// creating the artifact object out of the reported parts.
+func (d *Deployments) assembleUploadParts(ctx context.Context, intentID string) error {
+	link, err := d.db.GetUploadIntent(ctx, intentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrUploadNotFound
+	} else if err != nil {
+		return errors.WithMessage(err, "app: failed to get the upload intent")
+	}
+	if link.Multipart == nil || link.Status != model.LinkStatusPending {
+		return nil
+	} else if len(link.Multipart.Parts) == 0 {
+		return ErrUploadIncomplete
+	}
+	err = d.objectStorage.CompleteMultipartUpload(
+		ctx, link.Multipart.Path, link.Multipart.UploadID, link.Multipart.Parts,
+	)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		// The parts were already assembled by a previous request which
+		// failed to start processing the artifact.
+		err = nil
+	}
+	return err
+}
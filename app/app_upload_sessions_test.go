@@ -0,0 +1,218 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestCreateUploadSession(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		ctx := context.Background()
+		ds := new(mocks.DataStore)
+		defer ds.AssertExpectations(t)
+		objStore := new(fs_mocks.ObjectStorage)
+		defer objStore.AssertExpectations(t)
+
+		var path string
+		ds.On("GetStorageSettings", ctx).Return(nil, nil).Once()
+		objStore.On("CreateMultipartUpload", mock.Anything, mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				path = args.String(1)
+			}).
+			Return("upload", nil).
+			Once()
+		ds.On("InsertUploadIntent", mock.Anything, mock.MatchedBy(func(link *model.UploadLink) bool {
+			return assert.NotNil(t, link.Multipart) &&
+				assert.Equal(t, "upload", link.Multipart.UploadID) &&
+				assert.Equal(t, link.ArtifactID+fileSuffixTmp, link.Multipart.Path) &&
+				assert.Equal(t, path, link.Multipart.Path)
+		})).Return(nil).Once()
+
+		app := NewDeployments(ds, objStore, 0, false)
+		session, err := app.CreateUploadSession(ctx, time.Hour, false)
+		if assert.NoError(t, err) {
+			assert.True(t, session.Multipart)
+			assert.Equal(t, model.LinkStatusPending, session.Status)
+			assert.Equal(t, []model.UploadPart{}, session.Parts)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), session.Expire, time.Minute)
+		}
+	})
+	t.Run("error/not supported", func(t *testing.T) {
+		ctx := context.Background()
+		ds := new(mocks.DataStore)
+		defer ds.AssertExpectations(t)
+		objStore := new(fs_mocks.ObjectStorage)
+		defer objStore.AssertExpectations(t)
+
+		ds.On("GetStorageSettings", ctx).Return(nil, nil).Once()
+		objStore.On("CreateMultipartUpload", mock.Anything, mock.AnythingOfType("string")).
+			Return("", storage.ErrMultipartNotSupported).
+			Once()
+
+		app := NewDeployments(ds, objStore, 0, false)
+		_, err := app.CreateUploadSession(ctx, time.Hour, true)
+		assert.ErrorIs(t, err, ErrResumableUploadNotSupported)
+	})
+}
+
+func TestUploadParts(t *testing.T) {
+	t.Parallel()
+
+	const intentID = "9bf1bfff-eeb4-49d4-b55d-d717d407888a"
+	part := model.UploadPart{PartNumber: 2, ETag: `"etag"`}
+	resumable := func() *model.UploadLink {
+		return &model.UploadLink{
+			ArtifactID: intentID,
+			Link:       model.Link{Expire: time.Now().Add(time.Hour)},
+			Status:     model.LinkStatusPending,
+			Multipart: &model.MultipartUpload{
+				UploadID: "upload",
+				Path:     intentID,
+			},
+		}
+	}
+	testCases := []struct {
+		Name string
+
+		Link  *model.UploadLink
+		Error error
+
+		SetPartError error
+		ExpectedErr  error
+	}{{
+		Name: "ok",
+		Link: resumable(),
+	}, {
+		Name:        "error/not found",
+		Error:       store.ErrNotFound,
+		ExpectedErr: ErrUploadNotFound,
+	}, {
+		Name: "error/expired",
+		Link: func() *model.UploadLink {
+			link := resumable()
+			link.Expire = time.Now().Add(-time.Minute)
+			return link
+		}(),
+		ExpectedErr: ErrUploadNotFound,
+	}, {
+		Name: "error/already completed",
+		Link: func() *model.UploadLink {
+			link := resumable()
+			link.Status = model.LinkStatusProcessing
+			return link
+		}(),
+		ExpectedErr: ErrUploadNotFound,
+	}, {
+		Name: "error/not resumable",
+		Link: func() *model.UploadLink {
+			link := resumable()
+			link.Multipart = nil
+			return link
+		}(),
+		ExpectedErr: ErrUploadNotResumable,
+	}, {
+		Name:         "error/completed concurrently",
+		Link:         resumable(),
+		SetPartError: store.ErrNotFound,
+		ExpectedErr:  ErrUploadNotFound,
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := context.Background()
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			objStore := new(fs_mocks.ObjectStorage)
+			defer objStore.AssertExpectations(t)
+
+			ds.On("GetStorageSettings", ctx).Return(nil, nil).Once()
+			ds.On("GetUploadIntent", mock.Anything, intentID).Return(tc.Link, tc.Error).Twice()
+			if tc.ExpectedErr == nil || tc.SetPartError != nil {
+				objStore.On("UploadPartRequest",
+					mock.Anything, intentID, "upload", part.PartNumber, time.Minute).
+					Return(&model.Link{Uri: "http://localhost"}, nil).
+					Once()
+				ds.On("SetUploadIntentPart", ctx, intentID, part).
+					Return(tc.SetPartError).
+					Once()
+			}
+			app := NewDeployments(ds, objStore, 0, false)
+
+			link, err := app.UploadPartLink(ctx, intentID, part.PartNumber, time.Minute)
+			if tc.SetPartError == nil && tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, "http://localhost", link.Uri)
+			}
+			err = app.ReportUploadPart(ctx, intentID, part)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetUploadSession(t *testing.T) {
+	t.Parallel()
+
+	const intentID = "9bf1bfff-eeb4-49d4-b55d-d717d407888a"
+	ctx := context.Background()
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	expire := time.Now().Add(time.Hour)
+	ds.On("GetUploadIntent", ctx, intentID).
+		Return(&model.UploadLink{
+			ArtifactID: intentID,
+			Link:       model.Link{Expire: expire},
+			Status:     model.LinkStatusAborted | model.LinkStatusProcessedBit,
+			Multipart: &model.MultipartUpload{
+				Parts: []model.UploadPart{{PartNumber: 1, ETag: `"etag"`}},
+			},
+		}, nil).
+		Once().
+		On("GetUploadIntent", ctx, "missing").
+		Return(nil, store.ErrNotFound).
+		Once()
+	app := NewDeployments(ds, nil, 0, false)
+
+	session, err := app.GetUploadSession(ctx, intentID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &model.UploadSession{
+			ID:        intentID,
+			Status:    model.LinkStatusAborted,
+			Expire:    expire,
+			Multipart: true,
+			Parts:     []model.UploadPart{{PartNumber: 1, ETag: `"etag"`}},
+		}, session)
+	}
+	_, err = app.GetUploadSession(ctx, "missing")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// WithArtifactDeviceTypeCheck enables looking up the compatible device types
+// of the uploaded artifacts among the devices in the inventory.
+func (d *Deployments) WithArtifactDeviceTypeCheck(enable bool) *Deployments {
+	d.deviceTypeCheck = enable
+	return d
+}
+
+// UnknownArtifactDeviceTypes returns the compatible device types of the
+// artifact which no device of the tenant reports, likely misspelled. It
+// returns nil unless enabled with WithArtifactDeviceTypeCheck.
+func (d *Deployments) UnknownArtifactDeviceTypes(
+	ctx context.Context,
+	artifactID string,
+) ([]string, error) {
+	if !d.deviceTypeCheck {
+		return nil, nil
+	}
+	image, err := d.db.FindImageByID(ctx, artifactID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the artifact")
+	} else if image == nil || image.ArtifactMeta == nil {
+		return nil, ErrImageMetaNotFound
+	}
+
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+	}
+	var unknown []string
+	for _, deviceType := range image.ArtifactMeta.DeviceTypesCompatible {
+		_, count, err := d.search(ctx, tenantID, model.SearchParams{
+			Page:    1,
+			PerPage: 1,
+			Filters: []model.FilterPredicate{{
+				Scope:     InventoryInventoryScope,
+				Attribute: InventoryDeviceTypeAttributeName,
+				Type:      "$eq",
+				Value:     deviceType,
+			}},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to search the devices")
+		}
+		if count == 0 {
+			unknown = append(unknown, deviceType)
+		}
+	}
+	return unknown, nil
+}
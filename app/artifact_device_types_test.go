@@ -0,0 +1,123 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	inventory_mocks "github.com/mendersoftware/deployments/client/inventory/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestUnknownArtifactDeviceTypes(t *testing.T) {
+	const (
+		tenantID   = "tenant"
+		artifactID = "24436884-a710-4d20-aec4-82c89fbfe29e"
+	)
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: tenantID,
+	})
+	image := &model.Image{
+		Id: artifactID,
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"raspberrypi4", "raspbery-pi3"},
+		},
+	}
+	knownDeviceTypes := map[string]int{"raspberrypi4": 12}
+	searchParams := func(deviceType string) model.SearchParams {
+		return model.SearchParams{
+			Page:    1,
+			PerPage: 1,
+			Filters: []model.FilterPredicate{{
+				Scope:     InventoryInventoryScope,
+				Attribute: InventoryDeviceTypeAttributeName,
+				Type:      "$eq",
+				Value:     deviceType,
+			}},
+		}
+	}
+
+	testCases := map[string]struct {
+		disabled  bool
+		image     *model.Image
+		imageErr  error
+		searchErr error
+
+		unknown []string
+		err     error
+	}{
+		"ok": {
+			image:   image,
+			unknown: []string{"raspbery-pi3"},
+		},
+		"ok, disabled": {
+			disabled: true,
+		},
+		"error, artifact not found": {
+			err: ErrImageMetaNotFound,
+		},
+		"error, store": {
+			imageErr: errors.New("store error"),
+			err:      errors.New("failed to get the artifact: store error"),
+		},
+		"error, inventory": {
+			image:     image,
+			searchErr: errors.New("inventory error"),
+			err:       errors.New("failed to search the devices: inventory error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			inv := &inventory_mocks.Client{}
+			defer inv.AssertExpectations(t)
+
+			if !tc.disabled {
+				db.On("FindImageByID", ctx, artifactID).Return(tc.image, tc.imageErr)
+			}
+			if tc.image != nil {
+				for _, deviceType := range tc.image.ArtifactMeta.DeviceTypesCompatible {
+					inv.On("Search", ctx, tenantID, searchParams(deviceType)).
+						Return([]model.InvDevice{}, knownDeviceTypes[deviceType], tc.searchErr).
+						Once()
+					if tc.searchErr != nil {
+						break
+					}
+				}
+			}
+
+			d := NewDeployments(db, nil, 0, false).
+				WithArtifactDeviceTypeCheck(!tc.disabled)
+			d.SetInventoryClient(inv)
+
+			unknown, err := d.UnknownArtifactDeviceTypes(ctx, artifactID)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.unknown, unknown)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+var (
+	ErrArtifactReplaceNameMismatch = errors.New(
+		"the artifact name must not change on replacement",
+	)
+	ErrArtifactReplaceIncompatible = errors.New(
+		"the replacement changes the device types, depends or provides of the artifact",
+	)
+	ErrArtifactReplaceConflict = errors.New(
+		"the artifact was replaced or deleted concurrently",
+	)
+)
+
+// ReplaceImage uploads a new file for the artifact, keeping its ID: the
+// deployments and device deployments referencing the artifact get the new
+// file. The replacement must have the same name and device compatibility.
+func (d *Deployments) ReplaceImage(
+	ctx context.Context,
+	imageID string,
+	multipartUploadMsg *model.MultipartUploadMsg,
+) error {
+	l := log.FromContext(ctx)
+	image, err := d.GetImage(ctx, imageID)
+	if err != nil {
+		return errors.Wrap(err, "Getting image metadata")
+	} else if image == nil || image.ArtifactMeta == nil {
+		return ErrImageMetaNotFound
+	}
+	ctx, err = d.contextWithStorageSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	replacement := *image
+	replacement.Revision++
+	// the new file is stored under the key derived from the image ID,
+	// even for an image imported from an existing object
+	replacement.StorageKey = ""
+	objectPath := replacement.ObjectPath(ctx)
+	meta, size, err := d.uploadArtifact(
+		ctx,
+		multipartUploadMsg.ArtifactReader,
+		objectPath,
+		false,
+		multipartUploadMsg.ChecksumSHA256,
+		func(meta *model.ArtifactMeta) error {
+			if err := meta.Validate(); err != nil {
+				return ErrModelInvalidMetadata
+			}
+			if err := d.checkArtifactKeys(ctx, meta); err != nil {
+				return err
+			}
+			return checkReplacementArtifact(image.ArtifactMeta, meta)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	replacement.ArtifactMeta = meta
+	replacement.Size = size
+	replacement.SetModified(time.Now())
+	if multipartUploadMsg.MetaConstructor != nil &&
+		multipartUploadMsg.MetaConstructor.Description != "" {
+		replacement.ImageMeta = multipartUploadMsg.MetaConstructor
+	}
+
+	cleanup := func() {
+		if errDelete := d.objectStorage.DeleteObject(ctx, objectPath); errDelete != nil {
+			l.Errorf(
+				"failed to clean up artifact storage after failure: %s",
+				errDelete,
+			)
+		}
+	}
+	// the devices may be downloading the previous file with the links
+	// handed out before: the storage daemon deletes it once they are done.
+	// It is queued first so that it cannot be left behind; the daemon
+	// keeps the file if the replacement did not happen.
+	replaced := model.NewReplacedArtifactFile(ctx, image)
+	if err := d.db.InsertReplacedArtifactFile(ctx, replaced); err != nil {
+		cleanup()
+		return errors.Wrap(err, "Queueing the replaced artifact file for deletion")
+	}
+
+	err = d.db.ReplaceImage(ctx, &replacement, image.Revision)
+	if err != nil {
+		cleanup()
+		if errDelete := d.db.DeleteReplacedArtifactFile(ctx, replaced.ID); errDelete != nil {
+			l.Errorf(
+				"failed to unqueue the artifact file after failure: %s",
+				errDelete,
+			)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrArtifactReplaceConflict
+		}
+		return errors.Wrap(err, "Replacing image metadata")
+	}
+
+	// the replaced image is the reference: updating its copies is safe to
+	// retry, and is retried by the storage daemon
+	if err := d.db.UpdateImageReferences(ctx, &replacement); err != nil {
+		return errors.Wrap(err, "Updating the artifact references")
+	}
+	d.saveUpdateTypes(ctx, &replacement)
+	return nil
+}
+
+// checkReplacementArtifact verifies that the new artifact may replace the
+// old one: it must have the same name and be installable on the same
+// devices, leaving them with the same provides keys.
+func checkReplacementArtifact(prev, next *model.ArtifactMeta) error {
+	if prev.Name != next.Name {
+		return ErrArtifactReplaceNameMismatch
+	}
+	if !equalStringSets(prev.DeviceTypesCompatible, next.DeviceTypesCompatible) ||
+		!equalStringSets(prev.ClearsProvides, next.ClearsProvides) ||
+		!equalStringSets(mapKeys(prev.Provides), mapKeys(next.Provides)) {
+		return ErrArtifactReplaceIncompatible
+	}
+	prevDepends := normalizeDepends(prev.Depends)
+	nextDepends := normalizeDepends(next.Depends)
+	if len(prevDepends) != len(nextDepends) {
+		return ErrArtifactReplaceIncompatible
+	}
+	for key, values := range prevDepends {
+		if other, ok := nextDepends[key]; !ok || !equalStringSets(values, other) {
+			return ErrArtifactReplaceIncompatible
+		}
+	}
+	return nil
+}
+
+// normalizeDepends maps the depends to the lists of accepted values,
+// whichever the representation they were decoded from.
+func normalizeDepends(depends map[string]interface{}) map[string][]string {
+	res := make(map[string][]string, len(depends))
+	for key, value := range depends {
+		switch v := value.(type) {
+		case string:
+			res[key] = []string{v}
+		case []string:
+			res[key] = v
+		case bson.A:
+			res[key] = normalizeDepends(map[string]interface{}{
+				key: []interface{}(v),
+			})[key]
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = fmt.Sprint(item)
+			}
+			res[key] = values
+		default:
+			res[key] = []string{fmt.Sprint(v)}
+		}
+	}
+	return res
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func equalStringSets(a, b []string) bool {
+	setA := make(map[string]struct{}, len(a))
+	for _, s := range a {
+		setA[s] = struct{}{}
+	}
+	setB := make(map[string]struct{}, len(b))
+	for _, s := range b {
+		if _, ok := setA[s]; !ok {
+			return false
+		}
+		setB[s] = struct{}{}
+	}
+	return len(setA) == len(setB)
+}
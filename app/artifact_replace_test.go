@@ -0,0 +1,276 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/mendersoftware/deployments/model"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
+)
+
+func parseTestArtifact(t *testing.T, data []byte) *model.ArtifactMeta {
+	var r io.Reader = bytes.NewReader(data)
+	meta, err := getMetaFromArchive(&r, false, nil)
+	if err != nil {
+		t.Fatalf("failed to parse test artifact: %s", err)
+	}
+	// as decoded from the database
+	meta.Depends = map[string]interface{}{
+		model.ArtifactDependsDeviceType: bson.A{"foo"},
+	}
+	return meta
+}
+
+func TestReplaceImage(t *testing.T) {
+	t.Parallel()
+
+	const imageID = "5c1b0a5a-5d6c-4a7e-9a3b-2f1c9d8e7f60"
+	oldMeta := parseTestArtifact(t, makeTestArtifact(t, "release-1", "foo"))
+	errRefs := errors.New("connection refused")
+
+	testCases := map[string]struct {
+		revision int
+		artifact []byte
+		noImage  bool
+		fileErr  error
+		dbErr    error
+		refsErr  error
+
+		oldPath string
+		newPath string
+		err     error
+	}{
+		"ok": {
+			artifact: makeTestArtifact(t, "release-1", "foo"),
+			oldPath:  imageID,
+			newPath:  imageID + ".1",
+		},
+		"ok, replaced before": {
+			revision: 2,
+			artifact: makeTestArtifact(t, "release-1", "foo"),
+			oldPath:  imageID + ".2",
+			newPath:  imageID + ".3",
+		},
+		"error, not found": {
+			noImage: true,
+			err:     ErrImageMetaNotFound,
+		},
+		"error, name changed": {
+			artifact: makeTestArtifact(t, "release-2", "foo"),
+			newPath:  imageID + ".1",
+			err:      ErrArtifactReplaceNameMismatch,
+		},
+		"error, device type changed": {
+			artifact: makeTestArtifact(t, "release-1", "bar"),
+			newPath:  imageID + ".1",
+			err:      ErrArtifactReplaceIncompatible,
+		},
+		"error, updating the references": {
+			artifact: makeTestArtifact(t, "release-1", "foo"),
+			refsErr:  errRefs,
+			oldPath:  imageID,
+			newPath:  imageID + ".1",
+			err:      errRefs,
+		},
+		"error, replaced concurrently": {
+			artifact: makeTestArtifact(t, "release-1", "foo"),
+			dbErr:    store.ErrNotFound,
+			oldPath:  imageID,
+			newPath:  imageID + ".1",
+			err:      ErrArtifactReplaceConflict,
+		},
+		"error, queueing the previous file": {
+			artifact: makeTestArtifact(t, "release-1", "foo"),
+			fileErr:  errRefs,
+			oldPath:  imageID,
+			newPath:  imageID + ".1",
+			err:      errRefs,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			objStore := &fs_mocks.ObjectStorage{}
+			defer objStore.AssertExpectations(t)
+
+			var image *model.Image
+			if !tc.noImage {
+				image = &model.Image{
+					Id:           imageID,
+					ImageMeta:    &model.ImageMeta{Description: "first"},
+					ArtifactMeta: oldMeta,
+					Size:         1,
+					Revision:     tc.revision,
+				}
+			}
+			db.On("FindImageByID", ctx, imageID).Return(image, nil)
+			if !tc.noImage {
+				db.On("GetStorageSettings", ctx).Return(nil, nil)
+				db.On("GetLimit", mock.Anything, mock.AnythingOfType("string")).
+					Return(nil, mongo.ErrLimitNotFound).Maybe()
+				objStore.On("PutObject", mock.Anything, tc.newPath, mock.Anything).
+					Return(func(_ context.Context, _ string, r io.Reader) error {
+						_, err := io.Copy(io.Discard, r)
+						return err
+					}).Maybe()
+			}
+			uploaded := tc.err == nil || tc.fileErr != nil ||
+				tc.dbErr != nil || tc.refsErr != nil
+			if uploaded {
+				// the previous file is queued for deletion first
+				db.On("InsertReplacedArtifactFile", mock.Anything,
+					mock.MatchedBy(func(file *model.ReplacedArtifactFile) bool {
+						return file.ArtifactID == imageID &&
+							file.StorageKey == tc.oldPath
+					}),
+				).Return(tc.fileErr)
+			}
+			if uploaded && tc.fileErr == nil {
+				db.On("ReplaceImage", mock.Anything,
+					mock.MatchedBy(func(img *model.Image) bool {
+						return img.Id == imageID &&
+							img.Revision == tc.revision+1 &&
+							img.Size == int64(len(tc.artifact)) &&
+							img.ImageMeta.Description == "first"
+					}),
+					tc.revision,
+				).Return(tc.dbErr)
+			}
+			if tc.fileErr != nil || tc.dbErr != nil {
+				objStore.On("DeleteObject", mock.Anything, tc.newPath).Return(nil)
+			}
+			if tc.dbErr != nil {
+				db.On("DeleteReplacedArtifactFile", mock.Anything,
+					mock.AnythingOfType("string")).Return(nil)
+			} else if uploaded && tc.fileErr == nil {
+				db.On("UpdateImageReferences", mock.Anything,
+					mock.MatchedBy(func(img *model.Image) bool {
+						return img.Id == imageID && img.Revision == tc.revision+1
+					}),
+				).Return(tc.refsErr)
+			}
+			if tc.err == nil {
+				db.On("SaveUpdateTypes", mock.Anything, []string{"test-module"}).Return(nil)
+			}
+
+			d := NewDeployments(db, objStore, 0, false)
+			err := d.ReplaceImage(ctx, imageID, &model.MultipartUploadMsg{
+				MetaConstructor: &model.ImageMeta{},
+				ArtifactReader:  bytes.NewReader(tc.artifact),
+			})
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckReplacementArtifact(t *testing.T) {
+	t.Parallel()
+
+	base := func() *model.ArtifactMeta {
+		return &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"foo", "bar"},
+			Depends: map[string]interface{}{
+				model.ArtifactDependsDeviceType: bson.A{"foo", "bar"},
+				"rootfs-image.checksum":         "abc",
+			},
+			Provides: map[string]string{
+				"artifact_name":         "release-1",
+				"rootfs-image.checksum": "def",
+			},
+		}
+	}
+	testCases := map[string]struct {
+		modify func(meta *model.ArtifactMeta)
+		err    error
+	}{
+		"ok, same": {
+			modify: func(meta *model.ArtifactMeta) {},
+		},
+		"ok, reordered device types and new provides values": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.DeviceTypesCompatible = []string{"bar", "foo"}
+				meta.Depends[model.ArtifactDependsDeviceType] = []string{"bar", "foo"}
+				meta.Provides["rootfs-image.checksum"] = "123"
+			},
+		},
+		"error, name": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.Name = "release-2"
+			},
+			err: ErrArtifactReplaceNameMismatch,
+		},
+		"error, device types": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.DeviceTypesCompatible = []string{"foo"}
+			},
+			err: ErrArtifactReplaceIncompatible,
+		},
+		"error, depends value": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.Depends["rootfs-image.checksum"] = "123"
+			},
+			err: ErrArtifactReplaceIncompatible,
+		},
+		"error, depends key": {
+			modify: func(meta *model.ArtifactMeta) {
+				delete(meta.Depends, "rootfs-image.checksum")
+			},
+			err: ErrArtifactReplaceIncompatible,
+		},
+		"error, provides key": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.Provides["rootfs-image.version"] = "1"
+			},
+			err: ErrArtifactReplaceIncompatible,
+		},
+		"error, clears provides": {
+			modify: func(meta *model.ArtifactMeta) {
+				meta.ClearsProvides = []string{"rootfs-image.*"}
+			},
+			err: ErrArtifactReplaceIncompatible,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			next := base()
+			tc.modify(next)
+			assert.Equal(t, tc.err, checkReplacementArtifact(base(), next))
+		})
+	}
+}
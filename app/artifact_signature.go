@@ -0,0 +1,71 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+
+	"github.com/mendersoftware/mender-artifact/artifact"
+	"github.com/pkg/errors"
+)
+
+// ArtifactVerificationKey is a public key artifacts may be signed with,
+// used to tell which key signed an uploaded artifact.
+type ArtifactVerificationKey struct {
+	// Fingerprint is the hex encoded SHA-256 digest of the DER encoded
+	// public key, as printed by:
+	// openssl pkey -pubin -outform DER | sha256sum
+	Fingerprint string
+
+	verifier artifact.Verifier
+}
+
+// NewArtifactVerificationKey parses a PEM encoded RSA or ECDSA public key.
+func NewArtifactVerificationKey(keyPEM []byte) (*ArtifactVerificationKey, error) {
+	verifier, err := artifact.NewPKIVerifier(keyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid artifact verification key")
+	}
+	block, _ := pem.Decode(keyPEM)
+	digest := sha256.Sum256(block.Bytes)
+	return &ArtifactVerificationKey{
+		Fingerprint: hex.EncodeToString(digest[:]),
+		verifier:    verifier,
+	}, nil
+}
+
+// WithArtifactVerificationKeys sets the keys checked against the
+// signatures of the uploaded artifacts; the fingerprint of the matching
+// key is stored with the artifact. Artifacts signed with other keys are
+// still accepted.
+func (d *Deployments) WithArtifactVerificationKeys(
+	keys ...*ArtifactVerificationKey,
+) *Deployments {
+	d.verificationKeys = keys
+	return d
+}
+
+// signerFingerprint returns the fingerprint of the key verifying the
+// signature of the message, or an empty string if none does.
+func signerFingerprint(keys []*ArtifactVerificationKey, message, sig []byte) string {
+	for _, key := range keys {
+		if key.verifier.Verify(message, sig) == nil {
+			return key.Fingerprint
+		}
+	}
+	return ""
+}
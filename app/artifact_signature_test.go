@@ -0,0 +1,115 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"github.com/mendersoftware/mender-artifact/artifact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeSigningKey returns a new ECDSA signer, the PEM encoded public key and
+// its fingerprint.
+func makeSigningKey(t *testing.T) (artifact.Signer, []byte, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	signer, err := artifact.NewPKISigner(pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: privDER,
+	}))
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	digest := sha256.Sum256(pubDER)
+	return signer,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+		hex.EncodeToString(digest[:])
+}
+
+func TestNewArtifactVerificationKey(t *testing.T) {
+	t.Parallel()
+
+	_, pubPEM, fingerprint := makeSigningKey(t)
+	key, err := NewArtifactVerificationKey(pubPEM)
+	if assert.NoError(t, err) {
+		assert.Equal(t, fingerprint, key.Fingerprint)
+	}
+
+	_, err = NewArtifactVerificationKey([]byte("not a key"))
+	assert.ErrorContains(t, err, "invalid artifact verification key")
+}
+
+func TestGetMetaFromArchiveSignature(t *testing.T) {
+	t.Parallel()
+
+	signer, pubPEM, fingerprint := makeSigningKey(t)
+	otherSigner, otherPEM, _ := makeSigningKey(t)
+	key, err := NewArtifactVerificationKey(pubPEM)
+	require.NoError(t, err)
+	otherKey, err := NewArtifactVerificationKey(otherPEM)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		signer artifact.Signer
+		keys   []*ArtifactVerificationKey
+
+		signed      bool
+		fingerprint string
+	}{
+		"unsigned": {
+			keys: []*ArtifactVerificationKey{key},
+		},
+		"signed with a known key": {
+			signer:      signer,
+			keys:        []*ArtifactVerificationKey{otherKey, key},
+			signed:      true,
+			fingerprint: fingerprint,
+		},
+		"signed with an unknown key": {
+			signer: otherSigner,
+			keys:   []*ArtifactVerificationKey{key},
+			signed: true,
+		},
+		"signed, no keys": {
+			signer: signer,
+			signed: true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var r io.Reader = bytes.NewReader(
+				makeSignedTestArtifact(t, "release-1", "foo", tc.signer),
+			)
+			meta, err := getMetaFromArchive(&r, false, tc.keys)
+			require.NoError(t, err)
+			assert.Equal(t, tc.signed, meta.Signed)
+			assert.Equal(t, tc.fingerprint, meta.SignerKeyFingerprint)
+		})
+	}
+}
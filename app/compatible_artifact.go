@@ -0,0 +1,81 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// WithCompatibleArtifactCheck enables rejecting the deployments none of
+// whose artifacts is compatible with the device type of a target device.
+func (d *Deployments) WithCompatibleArtifactCheck(enable bool) *Deployments {
+	d.compatibleArtifactCheck = enable
+	return d
+}
+
+// checkCompatibleArtifact returns ErrNoCompatibleArtifact unless at least
+// one of the devices reports, in the inventory, a device type some of the
+// artifacts is compatible with.
+func (d *Deployments) checkCompatibleArtifact(
+	ctx context.Context,
+	artifacts []*model.Image,
+	devices []string,
+) error {
+	var deviceTypes []string
+	seen := make(map[string]struct{})
+	for _, artifact := range artifacts {
+		if artifact.ArtifactMeta == nil {
+			continue
+		}
+		for _, deviceType := range artifact.ArtifactMeta.DeviceTypesCompatible {
+			if _, ok := seen[deviceType]; !ok {
+				seen[deviceType] = struct{}{}
+				deviceTypes = append(deviceTypes, deviceType)
+			}
+		}
+	}
+	if len(deviceTypes) == 0 || len(devices) == 0 {
+		return ErrNoCompatibleArtifact
+	}
+
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+	}
+	_, count, err := d.search(ctx, tenantID, model.SearchParams{
+		Page:    1,
+		PerPage: 1,
+		Filters: []model.FilterPredicate{{
+			Scope:     InventoryInventoryScope,
+			Attribute: InventoryDeviceTypeAttributeName,
+			Type:      "$in",
+			Value:     deviceTypes,
+		}},
+		DeviceIDs: devices,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to search the devices")
+	}
+	if count == 0 {
+		return ErrNoCompatibleArtifact
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	inventory_mocks "github.com/mendersoftware/deployments/client/inventory/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestCreateDeploymentCompatibleArtifact(t *testing.T) {
+	const tenantID = "tenant"
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: tenantID,
+	})
+	devices := []string{
+		"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+		"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+	}
+	artifacts := []*model.Image{
+		model.NewImage(validUUIDv4, &model.ImageMeta{}, &model.ArtifactMeta{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"raspberrypi4", "raspberrypi3"},
+		}, artifactSize),
+		model.NewImage(validUUIDv4, &model.ImageMeta{}, &model.ArtifactMeta{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"raspberrypi4", "qemux86-64"},
+		}, artifactSize),
+	}
+	searchParams := model.SearchParams{
+		Page:    1,
+		PerPage: 1,
+		Filters: []model.FilterPredicate{{
+			Scope:     InventoryInventoryScope,
+			Attribute: InventoryDeviceTypeAttributeName,
+			Type:      "$in",
+			Value:     []string{"raspberrypi4", "raspberrypi3", "qemux86-64"},
+		}},
+		DeviceIDs: devices,
+	}
+
+	testCases := map[string]struct {
+		disabled        bool
+		compatibleCount int
+		searchErr       error
+
+		err error
+	}{
+		"ok": {
+			compatibleCount: 1,
+		},
+		"ok, disabled": {
+			disabled: true,
+		},
+		"error, no compatible artifact": {
+			err: ErrNoCompatibleArtifact,
+		},
+		"error, inventory": {
+			searchErr: errors.New("inventory error"),
+			err:       errors.New("failed to search the devices: inventory error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			inv := &inventory_mocks.Client{}
+			defer inv.AssertExpectations(t)
+
+			db.On("ImagesByName", ctx, "App 123").Return(artifacts, nil)
+			if !tc.disabled {
+				inv.On("Search", ctx, tenantID, searchParams).
+					Return([]model.InvDevice{}, tc.compatibleCount, tc.searchErr)
+			}
+			if tc.err == nil {
+				db.On("InsertDeployment", ctx, mock.AnythingOfType("*model.Deployment")).
+					Return(nil)
+			}
+
+			d := NewDeployments(db, nil, 0, false).
+				WithCompatibleArtifactCheck(!tc.disabled)
+			d.SetInventoryClient(inv)
+
+			_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+				Name:         "NYC Production",
+				ArtifactName: "App 123",
+				Devices:      devices,
+			})
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
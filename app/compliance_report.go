@@ -0,0 +1,83 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// GetComplianceReport returns a page of the deployments created between
+// from and to, oldest first, with who created and aborted them, and the
+// total number of deployments in the range.
+func (d *Deployments) GetComplianceReport(ctx context.Context,
+	from, to time.Time, skip, limit int) ([]model.DeploymentReport, int, error) {
+
+	count, err := d.db.CountDeploymentsCreatedBetween(ctx, from, to)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "counting the deployments of the report")
+	}
+	it, err := d.db.FindDeploymentsCreatedBetween(ctx, from, to, skip, limit)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "retrieving the deployments of the report")
+	}
+	defer it.Close(ctx)
+	reports := make([]model.DeploymentReport, 0, limit)
+	for {
+		next, err := it.Next(ctx)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "retrieving the deployments of the report")
+		} else if !next {
+			break
+		}
+		var report model.DeploymentReport
+		if err = it.Decode(&report); err != nil {
+			return nil, 0, errors.Wrap(err, "decoding the deployments of the report")
+		}
+		reports = append(reports, report)
+	}
+	return reports, count, nil
+}
+
+// StreamComplianceReport calls write with every deployment of the report,
+// without holding the whole range in memory.
+func (d *Deployments) StreamComplianceReport(ctx context.Context,
+	from, to time.Time, write func(*model.DeploymentReport) error) error {
+
+	it, err := d.db.FindDeploymentsCreatedBetween(ctx, from, to, 0, 0)
+	if err != nil {
+		return errors.Wrap(err, "retrieving the deployments of the report")
+	}
+	defer it.Close(ctx)
+	for {
+		next, err := it.Next(ctx)
+		if err != nil {
+			return errors.Wrap(err, "retrieving the deployments of the report")
+		} else if !next {
+			return nil
+		}
+		var report model.DeploymentReport
+		if err = it.Decode(&report); err != nil {
+			return errors.Wrap(err, "decoding the deployments of the report")
+		}
+		if err = write(&report); err != nil {
+			return err
+		}
+	}
+}
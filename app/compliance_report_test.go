@@ -0,0 +1,167 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestComplianceReportAuditFields(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "f826484e-1157-4109-af21-304e6d711561"
+	creatorCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "creator", IsUser: true})
+	aborterCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Subject: "aborter", IsUser: true})
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", creatorCtx, "App 123").
+		Return([]*model.Image{model.NewImage(
+			validUUIDv4,
+			&model.ImageMeta{},
+			&model.ArtifactMeta{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer"},
+				Depends:               map[string]interface{}{},
+			}, artifactSize)}, nil)
+	db.On("InsertDeployment", creatorCtx,
+		mock.MatchedBy(func(deployment *model.Deployment) bool {
+			return assert.Equal(t, "creator", deployment.CreatedBy) &&
+				assert.Empty(t, deployment.AbortedBy)
+		})).
+		Return(nil)
+	db.On("AbortDeviceDeployments", aborterCtx, deploymentID).
+		Return(int64(1), nil).
+		On("SetDeploymentAborted", aborterCtx, deploymentID, "aborter",
+			mock.MatchedBy(func(now time.Time) bool {
+				return assert.WithinDuration(t, time.Now(), now, time.Minute)
+			})).
+		Return(nil).
+		On("AggregateDeviceDeploymentByStatus", aborterCtx, deploymentID).
+		Return(model.Stats{model.DeviceDeploymentStatusAbortedStr: 1}, nil).
+		On("UpdateStats", aborterCtx, deploymentID,
+			model.Stats{model.DeviceDeploymentStatusAbortedStr: 1}).
+		Return(nil).
+		On("SetDeploymentStatus", aborterCtx, deploymentID,
+			model.DeploymentStatusFinished, mock.AnythingOfType("time.Time")).
+		Return(true, nil)
+
+	d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+	_, err := d.CreateDeployment(creatorCtx, &model.DeploymentConstructor{
+		Name:         "NYC Production",
+		ArtifactName: "App 123",
+		Devices: []string{
+			"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+			"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, d.AbortDeployment(aborterCtx, deploymentID))
+}
+
+func TestGetComplianceReport(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	aborted := from.Add(48 * time.Hour)
+	reports := []model.DeploymentReport{{
+		ID:        "created",
+		Status:    model.DeploymentStatusInProgress,
+		Created:   from.Add(time.Hour),
+		CreatedBy: "creator",
+		Stats:     model.Stats{model.DeviceDeploymentStatusSuccessStr: 2},
+	}, {
+		ID:        "aborted",
+		Status:    model.DeploymentStatusFinished,
+		Created:   from.Add(24 * time.Hour),
+		Finished:  &aborted,
+		CreatedBy: "creator",
+		AbortedBy: "aborter",
+		Aborted:   &aborted,
+		Stats:     model.Stats{model.DeviceDeploymentStatusAbortedStr: 3},
+	}}
+
+	t.Run("ok", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("CountDeploymentsCreatedBetween", ctx, from, to).
+			Return(12, nil).
+			On("FindDeploymentsCreatedBetween", ctx, from, to, 10, 10).
+			Return(NewArrayIterator(reports), nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		page, count, err := d.GetComplianceReport(ctx, from, to, 10, 10)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 12, count)
+			assert.Equal(t, reports, page)
+		}
+	})
+	t.Run("error/count", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		errInternal := errors.New("internal error")
+		db.On("CountDeploymentsCreatedBetween", ctx, from, to).
+			Return(0, errInternal)
+
+		d := NewDeployments(db, nil, 0, false)
+		_, _, err := d.GetComplianceReport(ctx, from, to, 0, 10)
+		assert.ErrorIs(t, err, errInternal)
+	})
+	t.Run("stream", func(t *testing.T) {
+		ctx := context.Background()
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentsCreatedBetween", ctx, from, to, 0, 0).
+			Return(NewArrayIterator(reports), nil).
+			Once().
+			On("FindDeploymentsCreatedBetween", ctx, from, to, 0, 0).
+			Return(NewArrayIterator(reports), nil).
+			Once()
+
+		d := NewDeployments(db, nil, 0, false)
+		var streamed []model.DeploymentReport
+		err := d.StreamComplianceReport(ctx, from, to,
+			func(report *model.DeploymentReport) error {
+				streamed = append(streamed, *report)
+				return nil
+			})
+		if assert.NoError(t, err) {
+			assert.Equal(t, reports, streamed)
+		}
+
+		errWrite := errors.New("connection closed")
+		err = d.StreamComplianceReport(ctx, from, to,
+			func(*model.DeploymentReport) error {
+				return errWrite
+			})
+		assert.ErrorIs(t, err, errWrite)
+	})
+}
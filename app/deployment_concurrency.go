@@ -0,0 +1,64 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mongo"
+)
+
+// claimUpdateSlot counts the device deployment just claimed from pending
+// as downloading in the deployment statistics. With a maximum number of
+// devices updating at once, the statistics are only updated, in the same
+// atomic operation, if the claimed one does not exceed it; device
+// deployments already in progress are never held back.
+func (d *Deployments) claimUpdateSlot(
+	ctx context.Context,
+	deployment *model.Deployment,
+) (model.Stats, bool, error) {
+	if deployment.DeploymentConstructor == nil ||
+		deployment.MaxConcurrent == 0 {
+		stats, err := d.db.UpdateStatsInc(ctx, deployment.Id,
+			model.DeviceDeploymentStatusPending, model.DeviceDeploymentStatusDownloading)
+		return stats, err == nil, err
+	}
+	stats, ok, err := d.db.ClaimDeploymentUpdateSlot(ctx,
+		deployment.Id, deployment.MaxConcurrent)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to update deployment stats")
+	}
+	return stats, ok, nil
+}
+
+// unclaimDeviceDeployment moves the device deployment claimed by the device
+// back to pending, for the device to wait; the deployment stats were not
+// updated for the claim.
+func (d *Deployments) unclaimDeviceDeployment(
+	ctx context.Context,
+	deviceDeployment *model.DeviceDeployment,
+) error {
+	_, err := d.db.UpdateDeviceDeploymentStatus(ctx,
+		deviceDeployment.DeviceId, deviceDeployment.DeploymentId,
+		model.DeviceDeploymentState{Status: model.DeviceDeploymentStatusPending},
+		model.DeviceDeploymentStatusDownloading)
+	if err != nil && err != mongo.ErrStorageNotFound {
+		return errors.Wrap(err, "failed to release the device deployment")
+	}
+	return nil
+}
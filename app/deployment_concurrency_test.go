@@ -0,0 +1,135 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestClaimUpdateSlot(t *testing.T) {
+	t.Parallel()
+
+	stats := model.NewDeviceDeploymentStats()
+	stats.Set(model.DeviceDeploymentStatusPending, 4)
+	stats.Set(model.DeviceDeploymentStatusDownloading, 2)
+
+	testCases := map[string]struct {
+		maxConcurrent uint
+		claimed       bool
+		claimErr      error
+
+		ok  bool
+		err error
+	}{
+		"ok, no limit": {
+			ok: true,
+		},
+		"ok, below the limit": {
+			maxConcurrent: 3,
+			claimed:       true,
+			ok:            true,
+		},
+		"ok, at the limit": {
+			maxConcurrent: 2,
+		},
+		"error": {
+			maxConcurrent: 2,
+			claimErr:      errors.New("mongo error"),
+			err:           errors.New("failed to update deployment stats: mongo error"),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			deployment := &model.Deployment{
+				Id: validUUIDv4,
+				DeploymentConstructor: &model.DeploymentConstructor{
+					MaxConcurrent: tc.maxConcurrent,
+				},
+			}
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			if tc.maxConcurrent == 0 {
+				ds.On("UpdateStatsInc", ctx, validUUIDv4,
+					model.DeviceDeploymentStatusPending,
+					model.DeviceDeploymentStatusDownloading,
+				).Return(stats, nil)
+			} else if tc.claimed {
+				ds.On("ClaimDeploymentUpdateSlot", ctx, validUUIDv4, tc.maxConcurrent).
+					Return(stats, true, nil)
+			} else {
+				ds.On("ClaimDeploymentUpdateSlot", ctx, validUUIDv4, tc.maxConcurrent).
+					Return(nil, false, tc.claimErr)
+			}
+
+			d := NewDeployments(ds, nil, 0, false)
+			res, ok, err := d.claimUpdateSlot(ctx, deployment)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, stats, res)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentForDeviceAtConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	deviceDeployment := model.NewDeviceDeployment(validUUIDv4, "throttled")
+	deviceDeployment.Status = model.DeviceDeploymentStatusDownloading
+
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	ds.On("FindAndClaimOldestActiveDeviceDeployment", ctx, validUUIDv4).
+		Return(deviceDeployment, model.DeviceDeploymentStatusPending, nil)
+	ds.On("FindDeploymentByID", ctx, "throttled", false).
+		Return(&model.Deployment{
+			Id: "throttled",
+			DeploymentConstructor: &model.DeploymentConstructor{
+				MaxConcurrent: 1,
+			},
+		}, nil)
+	ds.On("ClaimDeploymentUpdateSlot", ctx, "throttled", uint(1)).
+		Return(nil, false, nil)
+	ds.On("UpdateDeviceDeploymentStatus", ctx, validUUIDv4, "throttled",
+		model.DeviceDeploymentState{Status: model.DeviceDeploymentStatusPending},
+		model.DeviceDeploymentStatusDownloading,
+	).Return(model.DeviceDeploymentStatusDownloading, nil)
+
+	// the device keeps waiting with its device deployment back to pending,
+	// and the stats untouched
+	deploy := NewDeployments(ds, nil, 0, false)
+	instructions, err := deploy.GetDeploymentForDeviceWithCurrent(ctx, validUUIDv4,
+		&model.DeploymentNextRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, instructions)
+}
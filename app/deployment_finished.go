@@ -0,0 +1,164 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+var (
+	deploymentFinishedWorkflows = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "deployments",
+		Subsystem: "deployment_finished_workflow",
+		Name:      "started_total",
+		Help:      "Number of workflows started for finished deployments.",
+	})
+	deploymentFinishedWorkflowFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "deployments",
+		Subsystem: "deployment_finished_workflow",
+		Name:      "failures_total",
+		Help:      "Number of workflows which failed to start for finished deployments.",
+	})
+)
+
+// WithDeploymentFinishedWorkflow starts the named workflow every time a
+// deployment finishes. An empty name disables the workflow.
+func (d *Deployments) WithDeploymentFinishedWorkflow(workflow string) *Deployments {
+	d.deploymentFinishedWorkflow = workflow
+	return d
+}
+
+// WithAutoFinishDeployments enables finishing the deployments without a
+// device count to reach once none of their device deployments is active.
+func (d *Deployments) WithAutoFinishDeployments(enable bool) *Deployments {
+	d.autoFinish = enable
+	return d
+}
+
+// deploymentStatusAfterUpdate returns the status of the deployment after
+// its statistics were incremented for a device deployment. With automatic
+// finish enabled, a deployment without a device count to reach finishes as
+// soon as none of its device deployments is active.
+func (d *Deployments) deploymentStatusAfterUpdate(
+	deployment *model.Deployment,
+) model.DeploymentStatus {
+	if d.autoFinish && deployment.MaxDevices <= 0 &&
+		deployment.Stats.Active() == 0 {
+		return model.DeploymentStatusFinished
+	}
+	return deployment.GetStatus()
+}
+
+// finishDeploymentIfInactive increments the statistics of the deployment
+// for the device deployment decommissioned in bulk, given with its previous
+// status, and updates the status of the deployment, finishing it if none of
+// its devices is left active.
+func (d *Deployments) finishDeploymentIfInactive(
+	ctx context.Context,
+	deviceDeployment model.DeviceDeployment,
+) error {
+	deploymentID := deviceDeployment.DeploymentId
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, true)
+	if err != nil {
+		return errors.Wrap(err, "failed when searching for deployment")
+	} else if deployment == nil {
+		return nil
+	}
+	beforeStatus := deployment.GetStatus()
+	deployment.Stats, err = d.db.UpdateStatsInc(ctx, deploymentID,
+		deviceDeployment.Status, model.DeviceDeploymentStatusDecommissioned)
+	if err != nil {
+		return errors.Wrap(err, "failed to update deployment stats")
+	}
+	newStatus := d.deploymentStatusAfterUpdate(deployment)
+	if beforeStatus != newStatus {
+		err = d.setDeploymentStatus(ctx, deploymentID, newStatus)
+		if err != nil {
+			return errors.Wrap(err, "failed to update deployment status")
+		}
+	}
+	return nil
+}
+
+// setDeploymentStatus updates the status of the deployment. When the
+// deployment finishes, its statistics are first recalculated from the device
+// deployments, so that the final values are stored and sent to the
+// deployment finished workflow.
+func (d *Deployments) setDeploymentStatus(
+	ctx context.Context,
+	deploymentID string,
+	status model.DeploymentStatus,
+) error {
+	var stats model.Stats
+	if status == model.DeploymentStatusFinished {
+		var err error
+		stats, err = d.db.AggregateDeviceDeploymentByStatus(ctx, deploymentID)
+		if err != nil {
+			return err
+		}
+		if err := d.db.UpdateStats(ctx, deploymentID, stats); err != nil {
+			return errors.Wrap(err, "failed to update deployment stats")
+		}
+	}
+	updated, err := d.db.SetDeploymentStatus(ctx, deploymentID, status, time.Now())
+	if err == nil && updated && status == model.DeploymentStatusFinished {
+		// only the update finishing the deployment starts the workflow
+		d.startDeploymentFinishedWorkflow(ctx, deploymentID, stats)
+	}
+	return err
+}
+
+// startDeploymentFinishedWorkflow does not wait for the workflows service:
+// an outage must not hold back the status updates finishing deployments.
+func (d *Deployments) startDeploymentFinishedWorkflow(
+	ctx context.Context,
+	deploymentID string,
+	stats model.Stats,
+) {
+	if d.deploymentFinishedWorkflow == "" {
+		return
+	}
+	// the request context ends with the status update
+	wfCtx := log.WithContext(context.Background(), log.FromContext(ctx))
+	wfCtx = requestid.WithContext(wfCtx, requestid.FromContext(ctx))
+	if id := identity.FromContext(ctx); id != nil {
+		wfCtx = identity.WithContext(wfCtx, id)
+	}
+	workflow := d.deploymentFinishedWorkflow
+	go func() {
+		err := d.workflowsClient.StartDeploymentFinished(
+			wfCtx, workflow, deploymentID, stats,
+		)
+		if err != nil {
+			deploymentFinishedWorkflowFailures.Inc()
+			log.FromContext(wfCtx).Errorf(
+				"failed to start workflow %q for finished deployment %s: %s",
+				workflow, deploymentID, err,
+			)
+			return
+		}
+		deploymentFinishedWorkflows.Inc()
+	}()
+}
@@ -0,0 +1,262 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	workflows_mocks "github.com/mendersoftware/deployments/client/workflows/mocks"
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestSetDeploymentStatusFinishedWorkflow(t *testing.T) {
+	const deploymentID = "f826484e-1157-4109-af21-304e6d711561"
+	// the final breakdown of the device deployment outcomes
+	stats := model.Stats{
+		model.DeviceDeploymentStatusSuccessStr:     2,
+		model.DeviceDeploymentStatusFailureStr:     1,
+		model.DeviceDeploymentStatusAbortedStr:     1,
+		model.DeviceDeploymentStatusNoArtifactStr:  1,
+		model.DeviceDeploymentStatusAlreadyInstStr: 1,
+	}
+
+	testCases := map[string]struct {
+		Workflow string
+		Status   model.DeploymentStatus
+
+		AggregateError           error
+		UpdateStatsError         error
+		SetDeploymentStatusError error
+		AlreadyFinished          bool
+		WorkflowError            error
+
+		CallWorkflow bool
+		Error        error
+	}{
+		"ok": {
+			Workflow:     "deployment_finished",
+			Status:       model.DeploymentStatusFinished,
+			CallWorkflow: true,
+		},
+		"ok, workflow error": {
+			Workflow:      "deployment_finished",
+			Status:        model.DeploymentStatusFinished,
+			WorkflowError: errors.New("workflows unavailable"),
+			CallWorkflow:  true,
+		},
+		"ok, already finished": {
+			Workflow:        "deployment_finished",
+			Status:          model.DeploymentStatusFinished,
+			AlreadyFinished: true,
+		},
+		"ok, disabled": {
+			Status: model.DeploymentStatusFinished,
+		},
+		"ok, not finished": {
+			Workflow: "deployment_finished",
+			Status:   model.DeploymentStatusInProgress,
+		},
+		"error, stats not aggregated": {
+			Workflow:       "deployment_finished",
+			Status:         model.DeploymentStatusFinished,
+			AggregateError: errors.New("mongo: internal error"),
+			Error:          errors.New("mongo: internal error"),
+		},
+		"error, stats not updated": {
+			Workflow:         "deployment_finished",
+			Status:           model.DeploymentStatusFinished,
+			UpdateStatsError: errors.New("mongo: internal error"),
+			Error:            errors.New("failed to update deployment stats: mongo: internal error"),
+		},
+		"error, status not updated": {
+			Workflow:                 "deployment_finished",
+			Status:                   model.DeploymentStatusFinished,
+			SetDeploymentStatusError: errors.New("mongo: internal error"),
+			Error:                    errors.New("mongo: internal error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := identity.WithContext(context.Background(), &identity.Identity{
+				Tenant: "tenant",
+			})
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			finished := tc.Status == model.DeploymentStatusFinished
+			if finished {
+				db.On("AggregateDeviceDeploymentByStatus", ctx, deploymentID).
+					Return(stats, tc.AggregateError)
+			}
+			if finished && tc.AggregateError == nil {
+				db.On("UpdateStats", ctx, deploymentID, stats).
+					Return(tc.UpdateStatsError)
+			}
+			if !finished || tc.AggregateError == nil && tc.UpdateStatsError == nil {
+				db.On("SetDeploymentStatus",
+					ctx, deploymentID, tc.Status, mock.AnythingOfType("time.Time")).
+					Return(!tc.AlreadyFinished, tc.SetDeploymentStatusError)
+			}
+
+			called := make(chan struct{})
+			wf := &workflows_mocks.Client{}
+			defer wf.AssertExpectations(t)
+			if tc.CallWorkflow {
+				wf.On("StartDeploymentFinished",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						id := identity.FromContext(ctx)
+						return id != nil && id.Tenant == "tenant"
+					}),
+					tc.Workflow, deploymentID, stats).
+					Run(func(mock.Arguments) { close(called) }).
+					Return(tc.WorkflowError)
+			}
+			failures := testutil.ToFloat64(deploymentFinishedWorkflowFailures)
+
+			d := NewDeployments(db, nil, 0, false).
+				WithDeploymentFinishedWorkflow(tc.Workflow)
+			d.workflowsClient = wf
+			err := d.setDeploymentStatus(ctx, deploymentID, tc.Status)
+			if tc.Error != nil {
+				assert.EqualError(t, err, tc.Error.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if !tc.CallWorkflow {
+				return
+			}
+			select {
+			case <-called:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the workflow to start")
+			}
+			if tc.WorkflowError != nil {
+				assert.Eventually(t, func() bool {
+					return testutil.ToFloat64(deploymentFinishedWorkflowFailures) > failures
+				}, 5*time.Second, 10*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestAutoFinishAlreadyInstalledDeployment(t *testing.T) {
+	const (
+		deploymentID = "f826484e-1157-4109-af21-304e6d711561"
+		deviceID     = "a4b2cd9f-4bb2-4d2e-9b3e-2a6b0f3c8d1e"
+	)
+	alreadyInstalled := model.Stats{model.DeviceDeploymentStatusAlreadyInstStr: 2}
+
+	testCases := map[string]struct {
+		AutoFinish bool
+		MaxDevices int
+		// Stats returned by the incremental update
+		Stats model.Stats
+
+		// Status stored for the deployment
+		Status model.DeploymentStatus
+	}{
+		"ok, every device already installed": {
+			AutoFinish: true,
+			MaxDevices: 2,
+			Stats:      alreadyInstalled,
+			Status:     model.DeploymentStatusFinished,
+		},
+		"ok, every device already installed, disabled": {
+			MaxDevices: 2,
+			Stats:      alreadyInstalled,
+			Status:     model.DeploymentStatusFinished,
+		},
+		"ok, device not polled yet": {
+			AutoFinish: true,
+			MaxDevices: 3,
+			Stats:      alreadyInstalled,
+			Status:     model.DeploymentStatusInProgress,
+		},
+		"ok, no device count": {
+			AutoFinish: true,
+			Stats:      alreadyInstalled,
+			Status:     model.DeploymentStatusFinished,
+		},
+		"ok, no device count, disabled": {
+			Stats:  alreadyInstalled,
+			Status: model.DeploymentStatusInProgress,
+		},
+		"ok, no device count, device still updating": {
+			AutoFinish: true,
+			Stats: model.Stats{
+				model.DeviceDeploymentStatusAlreadyInstStr: 1,
+				model.DeviceDeploymentStatusDownloadingStr: 1,
+			},
+			Status: model.DeploymentStatusInProgress,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			deployment := &model.Deployment{
+				Id:         deploymentID,
+				MaxDevices: tc.MaxDevices,
+				Stats:      model.Stats{model.DeviceDeploymentStatusPendingStr: 1},
+			}
+			deviceDeployment := model.NewDeviceDeployment(deviceID, deploymentID)
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("UpdateDeviceDeploymentStatus", ctx, deviceID, deploymentID,
+				mock.MatchedBy(func(state model.DeviceDeploymentState) bool {
+					return state.Status == model.DeviceDeploymentStatusAlreadyInst &&
+						state.FinishTime != nil
+				}),
+				model.DeviceDeploymentStatusPending,
+			).Return(model.DeviceDeploymentStatusPending, nil)
+			db.On("FindDeploymentByID", ctx, deploymentID, true).
+				Return(deployment, nil)
+			db.On("UpdateStatsInc", ctx, deploymentID,
+				model.DeviceDeploymentStatusPending,
+				model.DeviceDeploymentStatusAlreadyInst,
+			).Return(tc.Stats, nil)
+			if tc.Status == model.DeploymentStatusFinished {
+				db.On("AggregateDeviceDeploymentByStatus", ctx, deploymentID).
+					Return(tc.Stats, nil)
+				db.On("UpdateStats", ctx, deploymentID, tc.Stats).
+					Return(nil)
+			}
+			db.On("SetDeploymentStatus",
+				ctx, deploymentID, tc.Status, mock.AnythingOfType("time.Time"),
+			).Return(true, nil)
+			db.On("SaveLastDeviceDeploymentStatus", ctx,
+				mock.AnythingOfType("model.DeviceDeployment"),
+			).Return(nil)
+
+			d := NewDeployments(db, nil, 0, false).
+				WithAutoFinishDeployments(tc.AutoFinish)
+			err := d.handleAlreadyInstalled(ctx, deviceDeployment)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// schedulePhases splits the devices of a new phased deployment into the
+// batches of its phases. The first phase of a deployment created paused is
+// left to start with the deployment.
+func schedulePhases(deployment *model.Deployment) {
+	if !deployment.IsPhased() {
+		return
+	}
+	unset := deployment.Phases[0].StartTs == nil
+	deployment.Phases.Schedule(*deployment.Created, len(deployment.DeviceList))
+	if unset && deployment.Status == model.DeploymentStatusPaused {
+		deployment.Phases[0].StartTs = nil
+	}
+	phase := deployment.Phases.Active(time.Now())
+	deployment.CurrentPhase = &phase
+}
+
+// refreshCurrentPhase sets the phase a phased deployment is in according
+// to its schedule, regardless of the devices that checked in so far.
+func refreshCurrentPhase(deployment *model.Deployment) {
+	if deployment == nil || !deployment.IsPhased() ||
+		deployment.Status == model.DeploymentStatusFinished {
+		return
+	}
+	phase := deployment.Phases.Active(time.Now())
+	deployment.CurrentPhase = &phase
+}
+
+// deviceInActivePhase tells whether the device belongs to a batch of the
+// deployment whose phase has started; deployments without phases target
+// all their devices at once. Checking the phase records the progress of
+// the rollout on the deployment.
+func (d *Deployments) deviceInActivePhase(
+	ctx context.Context,
+	deployment *model.Deployment,
+	deviceID string,
+) (bool, error) {
+	if !deployment.IsPhased() {
+		return true, nil
+	}
+	phase := deployment.Phases.Active(time.Now())
+	if phase < 0 {
+		return false, nil
+	}
+	if deployment.CurrentPhase == nil || phase > *deployment.CurrentPhase {
+		err := d.db.SetDeploymentCurrentPhase(ctx, deployment.Id, phase)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to update the deployment phase")
+		}
+		deployment.CurrentPhase = &phase
+	}
+	index, err := d.db.GetDeploymentDeviceIndex(ctx, deployment.Id, deviceID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to look up the device in the deployment")
+	}
+	return index >= 0 && index < deployment.Phases.DevicesUpTo(phase), nil
+}
@@ -0,0 +1,258 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestCreateDeploymentPhased(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+	devices := make([]string, 20)
+	for i := range devices {
+		devices[i] = fmt.Sprintf("device-%d", i)
+	}
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{{Id: validUUIDv4}}, nil)
+	db.On("InsertDeployment", ctx,
+		mock.MatchedBy(func(dpl *model.Deployment) bool {
+			return assert.Len(t, dpl.Phases, 2) &&
+				assert.Equal(t, dpl.Created, dpl.Phases[0].StartTs) &&
+				assert.Equal(t, 1, dpl.Phases[0].DeviceCount) &&
+				assert.Equal(t, 19, dpl.Phases[1].DeviceCount) &&
+				assert.Equal(t, 0, *dpl.CurrentPhase)
+		})).
+		Return(nil)
+
+	ds := NewDeployments(db, nil, 0, false)
+	_, err := ds.CreateDeployment(ctx, &model.DeploymentConstructor{
+		Name:         "canary",
+		ArtifactName: "App 123",
+		Devices:      devices,
+		Phases: model.DeploymentPhases{
+			{BatchSizePercent: 5},
+			{StartTs: &later},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestCreateDeploymentPhasedPaused(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+	start := false
+
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{{Id: validUUIDv4}}, nil)
+	// the first phase starts when the deployment is started
+	db.On("InsertDeployment", ctx,
+		mock.MatchedBy(func(dpl *model.Deployment) bool {
+			return assert.Len(t, dpl.Phases, 2) &&
+				assert.Nil(t, dpl.Phases[0].StartTs) &&
+				assert.Equal(t, 1, dpl.Phases[0].DeviceCount) &&
+				assert.Equal(t, &later, dpl.Phases[1].StartTs)
+		})).
+		Return(nil)
+
+	ds := NewDeployments(db, nil, 0, false)
+	_, err := ds.CreateDeployment(ctx, &model.DeploymentConstructor{
+		Name:             "canary",
+		ArtifactName:     "App 123",
+		Devices:          []string{"device-1", "device-2"},
+		StartImmediately: &start,
+		Phases: model.DeploymentPhases{
+			{BatchSizePercent: 50},
+			{StartTs: &later},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestCheckDeploymentForDevicePhases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	earlier := past.Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	later := future.Add(time.Hour)
+	zero := 0
+
+	testCases := map[string]struct {
+		phases       model.DeploymentPhases
+		currentPhase *int
+
+		lookup      bool
+		deviceIndex int
+		indexErr    error
+		setPhase    *int
+		setPhaseErr error
+
+		deploymentID string
+		err          error
+	}{
+		"ok, device in the first phase": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &past, DeviceCount: 1},
+				{StartTs: &future, DeviceCount: 9},
+			},
+			currentPhase: &zero,
+			lookup:       true,
+			deviceIndex:  0,
+			deploymentID: "phased",
+		},
+		"ok, device in a phase yet to start": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &past, DeviceCount: 1},
+				{StartTs: &future, DeviceCount: 9},
+			},
+			currentPhase: &zero,
+			lookup:       true,
+			deviceIndex:  5,
+		},
+		"ok, rollout not started": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &future, DeviceCount: 1},
+				{StartTs: &later, DeviceCount: 9},
+			},
+		},
+		"ok, next phase started": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &earlier, DeviceCount: 1},
+				{StartTs: &past, DeviceCount: 9},
+			},
+			currentPhase: &zero,
+			setPhase:     func() *int { i := 1; return &i }(),
+			lookup:       true,
+			deviceIndex:  5,
+			deploymentID: "phased",
+		},
+		"error, recording the phase": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &past, DeviceCount: 1},
+				{StartTs: &future, DeviceCount: 9},
+			},
+			setPhase:    &zero,
+			setPhaseErr: errors.New("connection refused"),
+			err:         errors.New("failed to update the deployment phase: connection refused"),
+		},
+		"error, looking up the device": {
+			phases: model.DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &past, DeviceCount: 1},
+				{StartTs: &future, DeviceCount: 9},
+			},
+			currentPhase: &zero,
+			lookup:       true,
+			indexErr:     errors.New("connection refused"),
+			err: errors.New("failed to look up the device in the deployment: " +
+				"connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds := new(mocks.DataStore)
+			defer ds.AssertExpectations(t)
+			ds.On("FindOldestActiveDeviceDeploymentID", ctx, validUUIDv4).
+				Return("", nil)
+			ds.On("FindLatestInactiveDeviceDeployment", ctx, validUUIDv4).
+				Return(nil, nil)
+			ds.On("FindNewerActiveDeployment", ctx, mock.Anything, validUUIDv4).
+				Return(&model.Deployment{
+					Id: "phased",
+					DeploymentConstructor: &model.DeploymentConstructor{
+						Phases: tc.phases,
+					},
+					CurrentPhase: tc.currentPhase,
+				}, nil)
+			if tc.setPhase != nil {
+				ds.On("SetDeploymentCurrentPhase", ctx, "phased", *tc.setPhase).
+					Return(tc.setPhaseErr)
+			}
+			if tc.lookup {
+				ds.On("GetDeploymentDeviceIndex", ctx, "phased", validUUIDv4).
+					Return(tc.deviceIndex, tc.indexErr)
+			}
+
+			deploy := NewDeployments(ds, nil, 0, false)
+			deploymentID, err := deploy.CheckDeploymentForDevice(ctx, validUUIDv4)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.deploymentID, deploymentID)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentForDevicePhaseNotStarted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	zero := 0
+
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	ds.On("FindAndClaimOldestActiveDeviceDeployment", ctx, validUUIDv4).
+		Return(nil, model.DeviceDeploymentStatusNull, nil)
+	ds.On("FindLatestInactiveDeviceDeployment", ctx, validUUIDv4).
+		Return(nil, nil)
+	ds.On("FindNewerActiveDeployment", ctx, mock.Anything, validUUIDv4).
+		Return(&model.Deployment{
+			Id: "phased",
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Phases: model.DeploymentPhases{
+					{BatchSizePercent: 50, StartTs: &past, DeviceCount: 1},
+					{StartTs: &future, DeviceCount: 1},
+				},
+			},
+			CurrentPhase: &zero,
+		}, nil)
+	ds.On("GetDeploymentDeviceIndex", ctx, "phased", validUUIDv4).
+		Return(1, nil)
+
+	// no device deployment is created for the device of the second phase
+	deploy := NewDeployments(ds, nil, 0, false)
+	instructions, err := deploy.GetDeploymentForDeviceWithCurrent(ctx, validUUIDv4,
+		&model.DeploymentNextRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, instructions)
+}
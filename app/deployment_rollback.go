@@ -0,0 +1,199 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+const (
+	// rollbackRetryDelay is how long after being triggered a rollback
+	// which did not finish is retried by the daemon, leaving the first
+	// attempt the time to finish.
+	rollbackRetryDelay = time.Minute
+)
+
+var (
+	// rollbackNamespace is used for deriving the IDs of the follow-up
+	// deployments of a rollback: retrying it does not create them twice.
+	rollbackNamespace = uuid.MustParse("5b0f5d8e-3c3e-4f57-8f0e-6d2a9c4b7e13")
+)
+
+// triggerDeploymentRollback records the rollback of the deployment which
+// crossed its failure threshold and carries it out in the background: the
+// device reporting the failure does not wait for it. A rollback which does
+// not finish is retried by retryDeploymentRollbacks.
+func (d *Deployments) triggerDeploymentRollback(
+	ctx context.Context,
+	deployment *model.Deployment,
+) error {
+	started, err := d.db.StartDeploymentRollback(ctx, deployment.Id, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to record the rollback")
+	} else if !started {
+		// rolled back by a concurrent status report
+		return nil
+	}
+	log.FromContext(ctx).Warnf(
+		"Deployment %s crossed its failure threshold of %d%%: rolling back",
+		deployment.Id, deployment.RollbackOnFailurePercent)
+
+	// the rollback is done by the service, not by the reporting device,
+	// and outlives the request
+	rollbackCtx := log.WithContext(context.Background(), log.FromContext(ctx))
+	if idty := identity.FromContext(ctx); idty != nil {
+		rollbackCtx = identity.WithContext(rollbackCtx,
+			&identity.Identity{Tenant: idty.Tenant})
+	}
+	go func() {
+		if err := d.rollBackDeployment(rollbackCtx, deployment.Id); err != nil {
+			log.FromContext(rollbackCtx).Errorf(
+				"failed to roll back deployment %s, will retry: %s",
+				deployment.Id, err)
+		}
+	}()
+	return nil
+}
+
+// retryDeploymentRollbacks carries out, in every tenant database, the
+// rollbacks which did not finish.
+func (d *Deployments) retryDeploymentRollbacks(ctx context.Context) error {
+	l := log.FromContext(ctx)
+	triggeredBefore := time.Now().Add(-rollbackRetryDelay)
+
+	return d.forEachDb(ctx, func(ctx context.Context, db string) error {
+		deployments, err := d.db.FindUnfinishedDeploymentRollbacks(ctx, triggeredBefore)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the unfinished rollbacks in %s", db)
+		}
+		var firstErr error
+		for _, deployment := range deployments {
+			err := d.rollBackDeployment(ctx, deployment.Id)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to roll back deployment %s", deployment.Id)
+				l.Error(err.Error())
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return firstErr
+	})
+}
+
+// rollBackDeployment aborts the deployment whose rollback was triggered
+// and creates, for the devices it updated successfully, one deployment per
+// artifact they had installed before, as reported when asking for the
+// update. Failed devices are left alone: they rolled back on their own.
+// Calling it again after a failure completes the rollback.
+func (d *Deployments) rollBackDeployment(ctx context.Context, deploymentID string) error {
+	l := log.FromContext(ctx)
+	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to find the deployment")
+	} else if deployment == nil {
+		// deleted meanwhile
+		return nil
+	}
+	// the failure may have been the last device of the deployment
+	if !deployment.IsFinished() {
+		if err = d.AbortDeployment(ctx, deploymentID); err != nil {
+			return errors.Wrap(err, "failed to abort the deployment")
+		}
+	}
+
+	devicesByArtifact, err := d.previousArtifacts(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	artifactNames := make([]string, 0, len(devicesByArtifact))
+	for artifactName := range devicesByArtifact {
+		artifactNames = append(artifactNames, artifactName)
+	}
+	sort.Strings(artifactNames)
+	deploymentIDs := []string{}
+	for _, artifactName := range artifactNames {
+		id := uuid.NewSHA1(rollbackNamespace,
+			[]byte(deploymentID+"/"+artifactName)).String()
+		_, err := d.CreateDeployment(ctx, &model.DeploymentConstructor{
+			ID:           id,
+			Name:         fmt.Sprintf("Rollback of %s", deployment.Name),
+			ArtifactName: artifactName,
+			Devices:      devicesByArtifact[artifactName],
+		})
+		if err == ErrConflictingDeployment {
+			// created by a previous attempt, unless an identical
+			// deployment is active
+			var created *model.Deployment
+			created, err = d.db.FindDeploymentByID(ctx, id, false)
+			if err == nil && created == nil {
+				err = ErrConflictingDeployment
+			}
+		}
+		if err != nil {
+			// e.g. the artifact was deleted meanwhile
+			l.Errorf("failed to roll back deployment %s to artifact %q: %s",
+				deploymentID, artifactName, err)
+			continue
+		}
+		deploymentIDs = append(deploymentIDs, id)
+	}
+	err = d.db.FinishDeploymentRollback(ctx, deploymentID, deploymentIDs, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to record the rollback deployments")
+	}
+	return nil
+}
+
+// previousArtifacts groups the devices updated successfully by the
+// deployment by the name of the artifact they had installed before.
+func (d *Deployments) previousArtifacts(
+	ctx context.Context,
+	deployment *model.Deployment,
+) (map[string][]string, error) {
+	status := model.DeviceDeploymentStatusSuccessStr
+	devicesByArtifact := map[string][]string{}
+	err := d.StreamDevicesListForDeployment(ctx, store.ListQuery{
+		DeploymentID: deployment.Id,
+		Status:       &status,
+	}, func(dd *model.DeviceDeployment) error {
+		if dd.Request == nil || dd.Request.DeviceProvides == nil {
+			return nil
+		}
+		artifactName := dd.Request.DeviceProvides.ArtifactName
+		if artifactName == "" || artifactName == deployment.ArtifactName {
+			return nil
+		}
+		devicesByArtifact[artifactName] = append(
+			devicesByArtifact[artifactName], dd.DeviceId,
+		)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the updated devices")
+	}
+	return devicesByArtifact, nil
+}
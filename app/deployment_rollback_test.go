@@ -0,0 +1,345 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+	"github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
+)
+
+func TestTriggerDeploymentRollback(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "f826484e-1157-4109-af21-304e6d711561"
+	deployment := &model.Deployment{
+		Id: deploymentID,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:                     "production",
+			ArtifactName:             "v2",
+			RollbackOnFailurePercent: 25,
+		},
+	}
+	newContext := func() context.Context {
+		return identity.WithContext(context.Background(), &identity.Identity{
+			Subject:  "device-1",
+			Tenant:   "tenant",
+			IsDevice: true,
+		})
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("StartDeploymentRollback", ctx, deploymentID,
+			mock.AnythingOfType("time.Time")).
+			Return(true, nil)
+		// carried out in the background, as the service
+		done := make(chan struct{})
+		db.On("FindDeploymentByID",
+			mock.MatchedBy(func(ctx context.Context) bool {
+				id := identity.FromContext(ctx)
+				return id != nil && id.Tenant == "tenant" && id.Subject == ""
+			}),
+			deploymentID, false,
+		).Run(func(mock.Arguments) { close(done) }).
+			Return(nil, errors.New("mongo: internal error"))
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.triggerDeploymentRollback(ctx, deployment))
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the rollback did not start")
+		}
+	})
+	t.Run("ok, rolled back already", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("StartDeploymentRollback", ctx, deploymentID,
+			mock.AnythingOfType("time.Time")).
+			Return(false, nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.triggerDeploymentRollback(ctx, deployment))
+	})
+	t.Run("error, recording the rollback", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("StartDeploymentRollback", ctx, deploymentID,
+			mock.AnythingOfType("time.Time")).
+			Return(false, errors.New("mongo: internal error"))
+
+		d := NewDeployments(db, nil, 0, false)
+		err := d.triggerDeploymentRollback(ctx, deployment)
+		assert.EqualError(t, err,
+			"failed to record the rollback: mongo: internal error")
+	})
+}
+
+func TestRollBackDeployment(t *testing.T) {
+	t.Parallel()
+
+	const deploymentID = "f826484e-1157-4109-af21-304e6d711561"
+	newDeployment := func(stats model.Stats) *model.Deployment {
+		return &model.Deployment{
+			Id: deploymentID,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:                     "production",
+				ArtifactName:             "v2",
+				RollbackOnFailurePercent: 25,
+			},
+			MaxDevices: 6,
+			Stats:      stats,
+		}
+	}
+	updated := func(deviceID, previousArtifact string) model.DeviceDeployment {
+		dd := model.NewDeviceDeployment(deviceID, deploymentID)
+		dd.Status = model.DeviceDeploymentStatusSuccess
+		if previousArtifact != "" {
+			dd.Request = &model.DeploymentNextRequest{
+				DeviceProvides: &model.InstalledDeviceDeployment{
+					ArtifactName: previousArtifact,
+				},
+			}
+		}
+		return *dd
+	}
+	success := model.DeviceDeploymentStatusSuccessStr
+	successful := store.ListQuery{DeploymentID: deploymentID, Status: &success}
+	rollbackID := func(artifactName string) string {
+		return uuid.NewSHA1(rollbackNamespace,
+			[]byte(deploymentID+"/"+artifactName)).String()
+	}
+	rollbackOf := func(artifactName string, devices ...string) interface{} {
+		return mock.MatchedBy(func(deployment *model.Deployment) bool {
+			return deployment.Id == rollbackID(artifactName) &&
+				deployment.Name == "Rollback of production" &&
+				deployment.ArtifactName == artifactName &&
+				deployment.CreatedBy == "" &&
+				assert.ObjectsAreEqual(devices, deployment.DeviceList)
+		})
+	}
+	newContext := func() context.Context {
+		return identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: "tenant",
+		})
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+		deployment := newDeployment(model.Stats{
+			model.DeviceDeploymentStatusFailureStr: 2,
+			model.DeviceDeploymentStatusSuccessStr: 4,
+		})
+		deployment.MaxDevices = 8
+		finalStats := model.Stats{
+			model.DeviceDeploymentStatusFailureStr: 2,
+			model.DeviceDeploymentStatusSuccessStr: 4,
+			model.DeviceDeploymentStatusAbortedStr: 2,
+		}
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(deployment, nil)
+		db.On("AbortDeviceDeployments", ctx, deploymentID).
+			Return(int64(2), nil)
+		db.On("SetDeploymentAborted", ctx, deploymentID, "",
+			mock.AnythingOfType("time.Time")).
+			Return(nil)
+		db.On("AggregateDeviceDeploymentByStatus", ctx, deploymentID).
+			Return(finalStats, nil)
+		db.On("UpdateStats", ctx, deploymentID, finalStats).
+			Return(nil)
+		db.On("SetDeploymentStatus", ctx, deploymentID,
+			model.DeploymentStatusFinished, mock.AnythingOfType("time.Time")).
+			Return(true, nil)
+		db.On("IterateDevicesListForDeployment", ctx, successful).
+			Return(NewArrayIterator([]model.DeviceDeployment{
+				updated("device-2", "v1"),
+				updated("device-3", "v0"),
+				updated("device-4", "v1"),
+				updated("device-5", "v0"),
+				// already had the artifact, or never reported it
+				updated("device-6", "v2"),
+				updated("device-7", ""),
+			}), nil)
+		for _, artifactName := range []string{"v0", "v1"} {
+			db.On("ImagesByName", ctx, artifactName).
+				Return([]*model.Image{{Id: "image-" + artifactName}}, nil)
+		}
+		db.On("InsertDeployment", ctx,
+			rollbackOf("v0", "device-3", "device-5")).
+			Return(nil)
+		db.On("InsertDeployment", ctx,
+			rollbackOf("v1", "device-2", "device-4")).
+			Return(nil)
+		db.On("FinishDeploymentRollback", ctx, deploymentID,
+			[]string{rollbackID("v0"), rollbackID("v1")},
+			mock.AnythingOfType("time.Time")).
+			Return(nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.rollBackDeployment(ctx, deploymentID))
+	})
+	t.Run("ok, retried", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+		// aborted by the first attempt
+		now := time.Now()
+		deployment := newDeployment(nil)
+		deployment.Finished = &now
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(deployment, nil)
+		db.On("IterateDevicesListForDeployment", ctx, successful).
+			Return(NewArrayIterator([]model.DeviceDeployment{
+				updated("device-2", "v1"),
+				updated("device-3", "v0"),
+				updated("device-4", "v1"),
+				updated("device-5", "v0"),
+			}), nil)
+		for _, artifactName := range []string{"v0", "v1"} {
+			db.On("ImagesByName", ctx, artifactName).
+				Return([]*model.Image{{Id: "image-" + artifactName}}, nil)
+		}
+		// created by the first attempt
+		db.On("InsertDeployment", ctx, rollbackOf("v0", "device-3", "device-5")).
+			Return(mongo.ErrConflictingDeployment)
+		db.On("FindDeploymentByID", ctx, rollbackID("v0"), false).
+			Return(&model.Deployment{Id: rollbackID("v0")}, nil).Once()
+		// an identical deployment is active
+		db.On("InsertDeployment", ctx, rollbackOf("v1", "device-2", "device-4")).
+			Return(mongo.ErrConflictingDeployment)
+		db.On("FindDeploymentByID", ctx, rollbackID("v1"), false).
+			Return(nil, nil).Once()
+		db.On("FinishDeploymentRollback", ctx, deploymentID,
+			[]string{rollbackID("v0")},
+			mock.AnythingOfType("time.Time")).
+			Return(nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.rollBackDeployment(ctx, deploymentID))
+	})
+	t.Run("ok, finished", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+		// the failure of the last device crossed the threshold
+		deployment := newDeployment(model.Stats{
+			model.DeviceDeploymentStatusFailureStr: 2,
+			model.DeviceDeploymentStatusSuccessStr: 4,
+		})
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(deployment, nil)
+		db.On("IterateDevicesListForDeployment", ctx, successful).
+			Return(NewArrayIterator([]model.DeviceDeployment{
+				updated("device-2", "v1"),
+				updated("device-3", "v1"),
+			}), nil)
+		// the artifact was deleted
+		db.On("ImagesByName", ctx, "v1").
+			Return([]*model.Image{}, nil)
+		db.On("FinishDeploymentRollback", ctx, deploymentID,
+			[]string{}, mock.AnythingOfType("time.Time")).
+			Return(nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.rollBackDeployment(ctx, deploymentID))
+	})
+	t.Run("ok, deleted", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(nil, nil)
+
+		d := NewDeployments(db, nil, 0, false)
+		assert.NoError(t, d.rollBackDeployment(ctx, deploymentID))
+	})
+	t.Run("error, aborting", func(t *testing.T) {
+		t.Parallel()
+		ctx := newContext()
+
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("FindDeploymentByID", ctx, deploymentID, false).
+			Return(newDeployment(nil), nil)
+		db.On("AbortDeviceDeployments", ctx, deploymentID).
+			Return(int64(0), errors.New("mongo: internal error"))
+
+		d := NewDeployments(db, nil, 0, false)
+		err := d.rollBackDeployment(ctx, deploymentID)
+		assert.EqualError(t, err,
+			"failed to abort the deployment: mongo: internal error")
+	})
+}
+
+func TestCleanupExpiredUploadsRetryDeploymentRollbacks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errInternal := errors.New("mongo: internal error")
+	db := &mocks.DataStore{}
+	defer db.AssertExpectations(t)
+	db.On("FindUploadLinks", ctx, mock.Anything).
+		Return(NewArrayIterator[model.UploadLink](nil), nil)
+	db.On("FindUnfinishedDeploymentRollbacks", ctx,
+		mock.MatchedBy(func(before time.Time) bool {
+			return before.Before(time.Now().Add(-rollbackRetryDelay / 2))
+		})).
+		Return([]*model.Deployment{
+			{Id: "f826484e-1157-4109-af21-304e6d711561"},
+			{Id: "f826484e-1157-4109-af21-304e6d711562"},
+		}, nil)
+	// a failing rollback does not hold back the others
+	db.On("FindDeploymentByID", ctx, "f826484e-1157-4109-af21-304e6d711561", false).
+		Return(nil, errInternal)
+	db.On("FindDeploymentByID", ctx, "f826484e-1157-4109-af21-304e6d711562", false).
+		Return(nil, nil)
+	expectNothingElseToCleanUp(db)
+
+	d := NewDeployments(db, nil, 0, false)
+	err := d.CleanupExpiredUploads(ctx, 0, time.Second)
+	assert.ErrorIs(t, err, errInternal)
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// deviceAttributesCacheMaxSize bounds the number of devices whose
+// attributes are cached; past it, the expired entries are evicted and, if
+// none expired, the cache is reset.
+const deviceAttributesCacheMaxSize = 10000
+
+type deviceAttributesCacheKey struct {
+	tenantID string
+	deviceID string
+}
+
+type deviceAttributesCacheEntry struct {
+	attributes []model.DeviceAttribute
+	expires    time.Time
+}
+
+// deviceAttributes selects the inventory attributes attached to the
+// reporting events of the device deployments, caching them per device.
+// A nil *deviceAttributes disables the enrichment.
+type deviceAttributes struct {
+	names map[string]struct{}
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[deviceAttributesCacheKey]deviceAttributesCacheEntry
+}
+
+func newDeviceAttributes(names []string, ttl time.Duration) *deviceAttributes {
+	if len(names) == 0 {
+		return nil
+	}
+	attrs := &deviceAttributes{
+		names:   make(map[string]struct{}, len(names)),
+		ttl:     ttl,
+		entries: make(map[deviceAttributesCacheKey]deviceAttributesCacheEntry),
+	}
+	for _, name := range names {
+		attrs.names[name] = struct{}{}
+	}
+	return attrs
+}
+
+func (a *deviceAttributes) get(key deviceAttributesCacheKey) ([]model.DeviceAttribute, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[key]
+	if !ok {
+		return nil, false
+	} else if !time.Now().Before(entry.expires) {
+		delete(a.entries, key)
+		return nil, false
+	}
+	return entry.attributes, true
+}
+
+func (a *deviceAttributes) set(key deviceAttributesCacheKey, attributes []model.DeviceAttribute) {
+	if a.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.entries) >= deviceAttributesCacheMaxSize {
+		for k, entry := range a.entries {
+			if !now.Before(entry.expires) {
+				delete(a.entries, k)
+			}
+		}
+		if len(a.entries) >= deviceAttributesCacheMaxSize {
+			a.entries = make(map[deviceAttributesCacheKey]deviceAttributesCacheEntry)
+		}
+	}
+	a.entries[key] = deviceAttributesCacheEntry{
+		attributes: attributes,
+		expires:    now.Add(a.ttl),
+	}
+}
+
+// WithDeviceAttributesEnrichment attaches the inventory attributes with the
+// given names to the reporting events emitted on device deployment status
+// changes. The attributes of a device are cached for up to ttl; an empty
+// list of names disables the enrichment.
+func (d *Deployments) WithDeviceAttributesEnrichment(
+	names []string,
+	ttl time.Duration,
+) *Deployments {
+	d.deviceAttributes = newDeviceAttributes(names, ttl)
+	return d
+}
+
+// getDeviceAttributes returns the configured subset of the device's
+// inventory attributes. Failing to fetch them from the inventory is not
+// fatal: the reporting event is then emitted without attributes.
+func (d *Deployments) getDeviceAttributes(
+	ctx context.Context,
+	deviceID string,
+) []model.DeviceAttribute {
+	if d.deviceAttributes == nil {
+		return nil
+	}
+	key := deviceAttributesCacheKey{deviceID: deviceID}
+	if id := identity.FromContext(ctx); id != nil {
+		key.tenantID = id.Tenant
+	}
+	if attributes, ok := d.deviceAttributes.get(key); ok {
+		return attributes
+	}
+
+	devices, _, err := d.inventoryClient.Search(ctx, key.tenantID, model.SearchParams{
+		Page:      1,
+		PerPage:   1,
+		DeviceIDs: []string{deviceID},
+	})
+	if err != nil {
+		log.FromContext(ctx).Warnf(
+			"failed to fetch the inventory attributes of device %s: %s",
+			deviceID, err.Error(),
+		)
+		return nil
+	}
+
+	var attributes []model.DeviceAttribute
+	for _, device := range devices {
+		if device.ID != deviceID {
+			continue
+		}
+		for _, attr := range device.Attributes {
+			if _, ok := d.deviceAttributes.names[attr.Name]; ok {
+				attributes = append(attributes, attr)
+			}
+		}
+	}
+	d.deviceAttributes.set(key, attributes)
+	return attributes
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	inventory_mocks "github.com/mendersoftware/deployments/client/inventory/mocks"
+	reporting_mocks "github.com/mendersoftware/deployments/client/reporting/mocks"
+	workflows_mocks "github.com/mendersoftware/deployments/client/workflows/mocks"
+	"github.com/mendersoftware/deployments/model"
+)
+
+func TestReindexDeploymentDeviceAttributes(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tenantID     = "tenant_id"
+		deviceID     = "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+		deploymentID = "d4ebd81f-ac53-4da1-a4d0-9b26b9ee1d27"
+		ID           = "id"
+	)
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: tenantID,
+	})
+	device := model.InvDevice{
+		ID: deviceID,
+		Attributes: []model.DeviceAttribute{
+			{Name: "device_type", Scope: "inventory", Value: "raspberrypi4"},
+			{Name: "artifact_name", Scope: "inventory", Value: "release-1"},
+			{Name: "mac", Scope: "identity", Value: "de:ad:be:ef:00:01"},
+		},
+	}
+	searchParams := model.SearchParams{
+		Page:      1,
+		PerPage:   1,
+		DeviceIDs: []string{deviceID},
+	}
+
+	testCases := map[string]struct {
+		names []string
+		ttl   time.Duration
+
+		inventoryMock func() *inventory_mocks.Client
+		attributes    []model.DeviceAttribute
+		reindexCalls  int
+	}{
+		"ok, enrichment disabled": {
+			inventoryMock: func() *inventory_mocks.Client {
+				return &inventory_mocks.Client{}
+			},
+			reindexCalls: 2,
+		},
+		"ok, attributes cached": {
+			names: []string{"device_type", "mac"},
+			ttl:   time.Minute,
+
+			inventoryMock: func() *inventory_mocks.Client {
+				inv := &inventory_mocks.Client{}
+				inv.On("Search", ctx, tenantID, searchParams).
+					Return([]model.InvDevice{device}, 1, nil).
+					Once()
+				return inv
+			},
+			attributes: []model.DeviceAttribute{
+				{Name: "device_type", Scope: "inventory", Value: "raspberrypi4"},
+				{Name: "mac", Scope: "identity", Value: "de:ad:be:ef:00:01"},
+			},
+			reindexCalls: 2,
+		},
+		"ok, cache disabled": {
+			names: []string{"artifact_name"},
+
+			inventoryMock: func() *inventory_mocks.Client {
+				inv := &inventory_mocks.Client{}
+				inv.On("Search", ctx, tenantID, searchParams).
+					Return([]model.InvDevice{device}, 1, nil).
+					Twice()
+				return inv
+			},
+			attributes: []model.DeviceAttribute{
+				{Name: "artifact_name", Scope: "inventory", Value: "release-1"},
+			},
+			reindexCalls: 2,
+		},
+		"ok, inventory error": {
+			names: []string{"device_type"},
+			ttl:   time.Minute,
+
+			inventoryMock: func() *inventory_mocks.Client {
+				inv := &inventory_mocks.Client{}
+				inv.On("Search", ctx, tenantID, searchParams).
+					Return(nil, -1, errors.New("inventory unavailable")).
+					Twice()
+				return inv
+			},
+			reindexCalls: 2,
+		},
+	}
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := tc.inventoryMock()
+			defer inv.AssertExpectations(t)
+			wf := &workflows_mocks.Client{}
+			wf.On("StartReindexReportingDeployment", ctx,
+				deviceID, deploymentID, ID, tc.attributes).
+				Return(nil).
+				Times(tc.reindexCalls)
+			defer wf.AssertExpectations(t)
+
+			d := NewDeployments(nil, nil, 0, false).
+				WithReporting(&reporting_mocks.Client{}).
+				WithDeviceAttributesEnrichment(tc.names, tc.ttl)
+			d.SetInventoryClient(inv)
+			d.SetWorkflowsClient(wf)
+
+			for i := 0; i < tc.reindexCalls; i++ {
+				err := d.reindexDeployment(ctx, deviceID, deploymentID, ID)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeviceAttributesCacheExpiry(t *testing.T) {
+	t.Parallel()
+
+	attrs := newDeviceAttributes([]string{"device_type"}, 50*time.Millisecond)
+	key := deviceAttributesCacheKey{tenantID: "tenant", deviceID: "device"}
+	attrs.set(key, []model.DeviceAttribute{{Name: "device_type"}})
+	_, ok := attrs.get(key)
+	assert.True(t, ok)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = attrs.get(key)
+	assert.False(t, ok)
+	assert.Empty(t, attrs.entries)
+
+	assert.Nil(t, newDeviceAttributes(nil, time.Minute))
+}
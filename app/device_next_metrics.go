@@ -0,0 +1,78 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+const (
+	deviceNextResultAssigned     = "assigned"
+	deviceNextResultNoDeployment = "no-deployment"
+	deviceNextResultError        = "error"
+
+	// deviceNextTypeNone labels the requests for which no deployment was
+	// found.
+	deviceNextTypeNone = "none"
+)
+
+var (
+	deviceNextDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "deployments",
+		Subsystem: "device_next",
+		Name:      "duration_seconds",
+		Help:      "Time taken to resolve the next deployment of a device.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result", "type"})
+	deviceNextAssignments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "deployments",
+		Subsystem: "device_next",
+		Name:      "assignments_total",
+		Help:      "Number of deployments handed out to a device for the first time.",
+	}, []string{"type"})
+)
+
+// observeDeviceNext records the outcome of a device-next request; first
+// tells if the device had never requested the deployment before.
+func observeDeviceNext(
+	elapsed time.Duration,
+	deployment *model.Deployment,
+	first bool,
+	instructions *model.DeploymentInstructions,
+	err error,
+) {
+	typ := deviceNextTypeNone
+	if deployment != nil {
+		typ = string(model.DeploymentTypeSoftware)
+		if deployment.Type != "" {
+			typ = string(deployment.Type)
+		}
+	}
+	result := deviceNextResultNoDeployment
+	if err != nil {
+		result = deviceNextResultError
+	} else if instructions != nil {
+		result = deviceNextResultAssigned
+		if first {
+			deviceNextAssignments.WithLabelValues(typ).Inc()
+		}
+	}
+	deviceNextDuration.WithLabelValues(result, typ).Observe(elapsed.Seconds())
+}
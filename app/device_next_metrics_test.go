@@ -0,0 +1,55 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+func TestObserveDeviceNext(t *testing.T) {
+	software := deviceNextAssignments.WithLabelValues(string(model.DeploymentTypeSoftware))
+	configuration := deviceNextAssignments.WithLabelValues(
+		string(model.DeploymentTypeConfiguration))
+	softwareCount := testutil.ToFloat64(software)
+	configurationCount := testutil.ToFloat64(configuration)
+
+	instructions := &model.DeploymentInstructions{ID: "foo"}
+	// deployments created before the type existed are software deployments
+	observeDeviceNext(time.Millisecond, &model.Deployment{}, true, instructions, nil)
+	observeDeviceNext(time.Millisecond, &model.Deployment{
+		Type: model.DeploymentTypeSoftware,
+	}, false, instructions, nil)
+	observeDeviceNext(time.Millisecond, &model.Deployment{
+		Type: model.DeploymentTypeConfiguration,
+	}, true, instructions, nil)
+	observeDeviceNext(time.Millisecond, &model.Deployment{
+		Type: model.DeploymentTypeConfiguration,
+	}, true, nil, errors.New("mongo: internal error"))
+	observeDeviceNext(time.Millisecond, nil, false, nil, nil)
+
+	assert.Equal(t, softwareCount+1, testutil.ToFloat64(software))
+	assert.Equal(t, configurationCount+1, testutil.ToFloat64(configuration))
+	assert.Equal(t, 0., testutil.ToFloat64(deviceNextAssignments.WithLabelValues(
+		deviceNextTypeNone)))
+	// at least one series per observed (result, type) pair
+	assert.GreaterOrEqual(t, testutil.CollectAndCount(deviceNextDuration), 4)
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/mendersoftware/deployments/utils"
+)
+
+// bandwidthLimiters holds the download bandwidth limiter of each tenant,
+// shared by all the downloads of the tenant so that together they do not
+// exceed the tenant's limit. The zero value is ready to use.
+type bandwidthLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// get returns the limiter of the tenant, updated to bytesPerSecond, or nil
+// if the bandwidth is unlimited.
+func (b *bandwidthLimiters) get(tenantID string, bytesPerSecond int) *rate.Limiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bytesPerSecond <= 0 {
+		delete(b.limiters, tenantID)
+		return nil
+	}
+	if b.limiters == nil {
+		b.limiters = make(map[string]*rate.Limiter)
+	}
+	limiter, ok := b.limiters[tenantID]
+	if !ok {
+		limiter = utils.NewBandwidthLimiter(bytesPerSecond)
+		b.limiters[tenantID] = limiter
+	} else if limiter.Limit() != rate.Limit(bytesPerSecond) {
+		utils.SetBandwidthLimit(limiter, bytesPerSecond)
+	}
+	return limiter
+}
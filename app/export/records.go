@@ -0,0 +1,138 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package export
+
+import (
+	"time"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// DeploymentRecord is the Parquet schema of an exported deployment.
+// Timestamps are stored as milliseconds since the Unix epoch (UTC).
+type DeploymentRecord struct {
+	ID                string           `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name              string           `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ArtifactName      string           `parquet:"name=artifact_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type              string           `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status            string           `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Created           int64            `parquet:"name=created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Finished          *int64           `parquet:"name=finished, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	Deleted           *int64           `parquet:"name=deleted, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	DeviceCount       int64            `parquet:"name=device_count, type=INT64"`
+	MaxDevices        int64            `parquet:"name=max_devices, type=INT64"`
+	Groups            []string         `parquet:"name=groups, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Artifacts         []string         `parquet:"name=artifacts, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	ForceInstallation bool             `parquet:"name=force_installation, type=BOOLEAN"`
+	Retries           int64            `parquet:"name=retries, type=INT64"`
+	Statistics        map[string]int64 `parquet:"name=statistics, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=INT64"`
+}
+
+// DeviceDeploymentRecord is the Parquet schema of an exported device
+// deployment.
+type DeviceDeploymentRecord struct {
+	ID           string  `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeploymentID string  `parquet:"name=deployment_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeviceID     string  `parquet:"name=device_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status       string  `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SubState     *string `parquet:"name=substate, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Created      int64   `parquet:"name=created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Started      *int64  `parquet:"name=started, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	Finished     *int64  `parquet:"name=finished, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	Deleted      *int64  `parquet:"name=deleted, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	ArtifactID   *string `parquet:"name=artifact_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ArtifactName *string `parquet:"name=artifact_name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	DeviceType   *string `parquet:"name=device_type, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Attempts     int64   `parquet:"name=attempts, type=INT64"`
+	LogAvailable bool    `parquet:"name=log_available, type=BOOLEAN"`
+}
+
+func millis(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// NewDeploymentRecord converts a deployment to its exported form.
+func NewDeploymentRecord(d *model.Deployment) DeploymentRecord {
+	rec := DeploymentRecord{
+		ID:         d.Id,
+		Type:       string(d.Type),
+		Status:     string(d.Status),
+		Finished:   millis(d.Finished),
+		Deleted:    millis(d.Deleted),
+		MaxDevices: int64(d.MaxDevices),
+		Groups:     d.Groups,
+		Artifacts:  d.Artifacts,
+	}
+	if d.DeploymentConstructor != nil {
+		rec.Name = d.Name
+		rec.ArtifactName = d.ArtifactName
+		rec.ForceInstallation = d.ForceInstallation
+		rec.Retries = int64(d.Retries)
+	}
+	if d.Created != nil {
+		rec.Created = d.Created.UnixMilli()
+	}
+	if d.DeviceCount != nil {
+		rec.DeviceCount = int64(*d.DeviceCount)
+	}
+	if len(d.Stats) > 0 {
+		rec.Statistics = make(map[string]int64, len(d.Stats))
+		for status, count := range d.Stats {
+			rec.Statistics[status] = int64(count)
+		}
+	}
+	return rec
+}
+
+// NewDeviceDeploymentRecord converts a device deployment to its exported
+// form.
+func NewDeviceDeploymentRecord(d *model.DeviceDeployment) DeviceDeploymentRecord {
+	rec := DeviceDeploymentRecord{
+		ID:           d.Id,
+		DeploymentID: d.DeploymentId,
+		DeviceID:     d.DeviceId,
+		Status:       d.Status.String(),
+		SubState:     optionalString(d.SubState),
+		Started:      millis(d.Started),
+		Finished:     millis(d.Finished),
+		Deleted:      millis(d.Deleted),
+		Attempts:     int64(d.Attempts),
+		LogAvailable: d.IsLogAvailable,
+	}
+	if d.Created != nil {
+		rec.Created = d.Created.UnixMilli()
+	}
+	if d.Image != nil {
+		rec.ArtifactID = optionalString(d.Image.Id)
+		if d.Image.ArtifactMeta != nil {
+			rec.ArtifactName = optionalString(d.Image.ArtifactMeta.Name)
+		}
+	}
+	if d.Request != nil && d.Request.DeviceProvides != nil {
+		rec.DeviceType = optionalString(d.Request.DeviceProvides.DeviceType)
+	}
+	return rec
+}
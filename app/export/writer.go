@@ -0,0 +1,156 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package export
+
+import (
+	"context"
+	"io"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+const (
+	// DefaultRowGroupSize bounds the rows buffered in memory before they
+	// are flushed to the object storage.
+	DefaultRowGroupSize = 8 * 1024 * 1024
+
+	partitionDateLayout = "2006-01-02"
+	partitionFileName   = "part-00000.parquet"
+)
+
+// PartitionPath returns the object path of the Parquet file holding the
+// records created on the given date, in Hive-style partitioning:
+// <prefix>/date=YYYY-MM-DD/part-00000.parquet
+func PartitionPath(prefix string, date time.Time) string {
+	return path.Join(
+		prefix,
+		"date="+date.UTC().Format(partitionDateLayout),
+		partitionFileName,
+	)
+}
+
+// PartitionedWriter streams records of type T into date-partitioned Parquet
+// files uploaded to the object storage. Records must be written in
+// chronological order: each partition is written exactly once, and
+// switching to a new date closes the previous file.
+type PartitionedWriter[T any] struct {
+	storage storage.ObjectStorage
+	prefix  string
+
+	// OnPartitionDone, if set, is called after every completed file with
+	// the object path and the number of records it contains.
+	OnPartitionDone func(ctx context.Context, path string, count int) error
+
+	partition string
+	path      string
+	count     int
+	pipe      *io.PipeWriter
+	writer    *writer.ParquetWriter
+	uploadErr chan error
+}
+
+// NewPartitionedWriter returns a writer putting the files under prefix.
+func NewPartitionedWriter[T any](
+	objStorage storage.ObjectStorage,
+	prefix string,
+) *PartitionedWriter[T] {
+	return &PartitionedWriter[T]{
+		storage: objStorage,
+		prefix:  prefix,
+	}
+}
+
+// Write appends the record to the partition of the given creation date.
+func (w *PartitionedWriter[T]) Write(ctx context.Context, created time.Time, record T) error {
+	partition := created.UTC().Format(partitionDateLayout)
+	if w.writer == nil || partition != w.partition {
+		if err := w.closePartition(ctx); err != nil {
+			return err
+		}
+		if err := w.openPartition(ctx, created); err != nil {
+			return err
+		}
+		w.partition = partition
+	}
+	if err := w.writer.Write(record); err != nil {
+		return errors.Wrapf(err, "export: failed to write record to %s", w.path)
+	}
+	w.count++
+	return nil
+}
+
+// Close flushes and uploads the last partition.
+func (w *PartitionedWriter[T]) Close(ctx context.Context) error {
+	return w.closePartition(ctx)
+}
+
+// Abort discards the partition being written.
+func (w *PartitionedWriter[T]) Abort() {
+	if w.pipe != nil {
+		w.pipe.CloseWithError(errors.New("export aborted"))
+		<-w.uploadErr
+		w.pipe, w.writer = nil, nil
+	}
+}
+
+func (w *PartitionedWriter[T]) openPartition(ctx context.Context, created time.Time) error {
+	pr, pw := io.Pipe()
+	w.path = PartitionPath(w.prefix, created)
+	w.count = 0
+	w.pipe = pw
+	w.uploadErr = make(chan error, 1)
+	go func(objectPath string) {
+		err := w.storage.PutObject(ctx, objectPath, pr)
+		// unblock the Parquet writer if the upload returns early
+		pr.CloseWithError(err)
+		w.uploadErr <- err
+	}(w.path)
+
+	pqWriter, err := writer.NewParquetWriterFromWriter(pw, new(T), 1)
+	if err != nil {
+		w.Abort()
+		return errors.Wrap(err, "export: failed to initialize Parquet writer")
+	}
+	pqWriter.RowGroupSize = DefaultRowGroupSize
+	pqWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+	w.writer = pqWriter
+	return nil
+}
+
+func (w *PartitionedWriter[T]) closePartition(ctx context.Context) error {
+	if w.writer == nil {
+		return nil
+	}
+	if err := w.writer.WriteStop(); err != nil {
+		w.Abort()
+		return errors.Wrapf(err, "export: failed to finalize %s", w.path)
+	}
+	_ = w.pipe.Close()
+	err := <-w.uploadErr
+	w.pipe, w.writer = nil, nil
+	if err != nil {
+		return errors.Wrapf(err, "export: failed to upload %s", w.path)
+	}
+	if w.OnPartitionDone != nil {
+		return w.OnPartitionDone(ctx, w.path, w.count)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+
+	"github.com/mendersoftware/deployments/model"
+	storage_mocks "github.com/mendersoftware/deployments/storage/mocks"
+)
+
+func uploadedFiles(objStore *storage_mocks.ObjectStorage) map[string][]byte {
+	files := make(map[string][]byte)
+	objStore.On("PutObject", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Run(func(args mock.Arguments) {
+			b, err := io.ReadAll(args.Get(2).(io.Reader))
+			if err == nil {
+				files[args.String(1)] = b
+			}
+		}).
+		Return(nil)
+	return files
+}
+
+func readRecords[T any](t *testing.T, b []byte) ([]T, *schema.SchemaHandler) {
+	pr, err := reader.NewParquetReader(buffer.NewBufferFileFromBytes(b), new(T), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+	records := make([]T, pr.GetNumRows())
+	require.NoError(t, pr.Read(&records))
+	return records, pr.SchemaHandler
+}
+
+// schemaColumns returns the types of the leaf columns by their name in the
+// file.
+func schemaColumns(sh *schema.SchemaHandler) map[string]parquet.Type {
+	columns := make(map[string]parquet.Type)
+	for i, elem := range sh.SchemaElements {
+		if elem.Type != nil {
+			columns[sh.Infos[i].ExName] = *elem.Type
+		}
+	}
+	return columns
+}
+
+func TestPartitionPath(t *testing.T) {
+	date := time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("CET", -3600))
+	assert.Equal(t,
+		"exports/job/deployments/date=2024-03-02/part-00000.parquet",
+		PartitionPath("exports/job/deployments", date),
+	)
+}
+
+func TestDeploymentsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	objStore := &storage_mocks.ObjectStorage{}
+	files := uploadedFiles(objStore)
+
+	day1 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	deviceCount := 2
+	deployments := []*model.Deployment{{
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:              "first",
+			ArtifactName:      "artifact-1",
+			ForceInstallation: true,
+			Retries:           3,
+		},
+		Id:          "d1",
+		Created:     &day1,
+		Finished:    &day2,
+		Status:      model.DeploymentStatusFinished,
+		Type:        model.DeploymentTypeSoftware,
+		DeviceCount: &deviceCount,
+		MaxDevices:  2,
+		Groups:      []string{"group"},
+		Artifacts:   []string{"a1", "a2"},
+		Stats: model.Stats{
+			model.DeviceDeploymentStatusSuccessStr: 1,
+			model.DeviceDeploymentStatusFailureStr: 1,
+		},
+	}, {
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "second",
+			ArtifactName: "artifact-2",
+		},
+		Id:      "d2",
+		Created: &day1,
+		Status:  model.DeploymentStatusPending,
+		Type:    model.DeploymentTypeConfiguration,
+	}, {
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "third",
+			ArtifactName: "artifact-1",
+		},
+		Id:      "d3",
+		Created: &day2,
+		Status:  model.DeploymentStatusInProgress,
+	}}
+
+	var done []string
+	w := NewPartitionedWriter[DeploymentRecord](objStore, "exports/job/deployments")
+	w.OnPartitionDone = func(ctx context.Context, path string, count int) error {
+		done = append(done, path)
+		return nil
+	}
+	for _, dpl := range deployments {
+		require.NoError(t, w.Write(ctx, *dpl.Created, NewDeploymentRecord(dpl)))
+	}
+	require.NoError(t, w.Close(ctx))
+
+	path1 := "exports/job/deployments/date=2024-01-01/part-00000.parquet"
+	path2 := "exports/job/deployments/date=2024-01-02/part-00000.parquet"
+	assert.Equal(t, []string{path1, path2}, done)
+	require.Len(t, files, 2)
+
+	records, sh := readRecords[DeploymentRecord](t, files[path1])
+	assert.Equal(t, map[string]parquet.Type{
+		"id":                 parquet.Type_BYTE_ARRAY,
+		"name":               parquet.Type_BYTE_ARRAY,
+		"artifact_name":      parquet.Type_BYTE_ARRAY,
+		"type":               parquet.Type_BYTE_ARRAY,
+		"status":             parquet.Type_BYTE_ARRAY,
+		"created":            parquet.Type_INT64,
+		"finished":           parquet.Type_INT64,
+		"deleted":            parquet.Type_INT64,
+		"device_count":       parquet.Type_INT64,
+		"max_devices":        parquet.Type_INT64,
+		"element":            parquet.Type_BYTE_ARRAY,
+		"force_installation": parquet.Type_BOOLEAN,
+		"retries":            parquet.Type_INT64,
+		"key":                parquet.Type_BYTE_ARRAY,
+		"value":              parquet.Type_INT64,
+	}, schemaColumns(sh))
+	if assert.Len(t, records, 2) {
+		finished := day2.UnixMilli()
+		assert.Equal(t, DeploymentRecord{
+			ID:                "d1",
+			Name:              "first",
+			ArtifactName:      "artifact-1",
+			Type:              "software",
+			Status:            "finished",
+			Created:           day1.UnixMilli(),
+			Finished:          &finished,
+			DeviceCount:       2,
+			MaxDevices:        2,
+			Groups:            []string{"group"},
+			Artifacts:         []string{"a1", "a2"},
+			ForceInstallation: true,
+			Retries:           3,
+			Statistics: map[string]int64{
+				model.DeviceDeploymentStatusSuccessStr: 1,
+				model.DeviceDeploymentStatusFailureStr: 1,
+			},
+		}, records[0])
+		assert.Equal(t, "d2", records[1].ID)
+		assert.Equal(t, "configuration", records[1].Type)
+		assert.Nil(t, records[1].Finished)
+	}
+
+	records, _ = readRecords[DeploymentRecord](t, files[path2])
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "d3", records[0].ID)
+		assert.Equal(t, "inprogress", records[0].Status)
+	}
+}
+
+func TestDeviceDeploymentsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	objStore := &storage_mocks.ObjectStorage{}
+	files := uploadedFiles(objStore)
+
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	started := created.Add(time.Minute)
+	dd := &model.DeviceDeployment{
+		Id:           "dd1",
+		DeploymentId: "d1",
+		DeviceId:     "dev1",
+		Created:      &created,
+		Started:      &started,
+		Status:       model.DeviceDeploymentStatusDownloading,
+		SubState:     "fetching",
+		Attempts:     1,
+		Image: &model.Image{
+			Id:           "a1",
+			ArtifactMeta: &model.ArtifactMeta{Name: "artifact-1"},
+		},
+		Request: &model.DeploymentNextRequest{
+			DeviceProvides: &model.InstalledDeviceDeployment{DeviceType: "rpi4"},
+		},
+		IsLogAvailable: true,
+	}
+
+	w := NewPartitionedWriter[DeviceDeploymentRecord](objStore, "device_deployments")
+	require.NoError(t, w.Write(ctx, created, NewDeviceDeploymentRecord(dd)))
+	require.NoError(t, w.Write(ctx, created, NewDeviceDeploymentRecord(
+		&model.DeviceDeployment{Id: "dd2", Created: &created},
+	)))
+	require.NoError(t, w.Close(ctx))
+
+	b, ok := files["device_deployments/date=2024-01-01/part-00000.parquet"]
+	require.True(t, ok)
+	records, sh := readRecords[DeviceDeploymentRecord](t, b)
+	assert.Len(t, schemaColumns(sh), 14)
+	if assert.Len(t, records, 2) {
+		startedMs := started.UnixMilli()
+		subState, artifactID, artifactName, deviceType := "fetching", "a1", "artifact-1", "rpi4"
+		assert.Equal(t, DeviceDeploymentRecord{
+			ID:           "dd1",
+			DeploymentID: "d1",
+			DeviceID:     "dev1",
+			Status:       model.DeviceDeploymentStatusDownloadingStr,
+			SubState:     &subState,
+			Created:      created.UnixMilli(),
+			Started:      &startedMs,
+			ArtifactID:   &artifactID,
+			ArtifactName: &artifactName,
+			DeviceType:   &deviceType,
+			Attempts:     1,
+			LogAvailable: true,
+		}, records[0])
+		assert.Equal(t, "dd2", records[1].ID)
+		assert.Nil(t, records[1].ArtifactID)
+	}
+}
+
+func TestPartitionedWriterUploadError(t *testing.T) {
+	ctx := context.Background()
+	objStore := &storage_mocks.ObjectStorage{}
+	objStore.On("PutObject", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(errors.New("storage unavailable"))
+
+	w := NewPartitionedWriter[DeploymentRecord](objStore, "deployments")
+	now := time.Now()
+	err := w.Write(ctx, now, NewDeploymentRecord(&model.Deployment{Id: "d1", Created: &now}))
+	if err == nil {
+		err = w.Close(ctx)
+	}
+	assert.ErrorContains(t, err, "storage unavailable")
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ErrTooManyArtifactGenerations = errors.New(
+		"too many configuration artifacts being generated",
+	)
+
+	artifactGenerationsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "deployments",
+		Subsystem: "configuration_artifact",
+		Name:      "generations_in_flight",
+		Help:      "Number of configuration artifacts being generated.",
+	})
+)
+
+// generateLimiter bounds the number of configuration artifacts generated
+// in memory at the same time; a nil limiter does not limit anything.
+type generateLimiter struct {
+	sem     chan struct{}
+	maxWait time.Duration
+}
+
+func newGenerateLimiter(maxConcurrent int, maxWait time.Duration) *generateLimiter {
+	if maxConcurrent < 1 {
+		return nil
+	}
+	return &generateLimiter{
+		sem:     make(chan struct{}, maxConcurrent),
+		maxWait: maxWait,
+	}
+}
+
+// acquire waits up to maxWait for a free slot and returns the function
+// releasing it.
+func (l *generateLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release := func() { <-l.sem }
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if l.maxWait <= 0 {
+		return nil, ErrTooManyArtifactGenerations
+	}
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrTooManyArtifactGenerations
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
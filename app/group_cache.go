@@ -0,0 +1,134 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	groupCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "deployments",
+		Subsystem: "inventory_group_cache",
+		Name:      "hits_total",
+		Help:      "Number of device group lookups served from the cache.",
+	})
+	groupCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "deployments",
+		Subsystem: "inventory_group_cache",
+		Name:      "misses_total",
+		Help:      "Number of device group lookups forwarded to the inventory.",
+	})
+)
+
+type skipGroupCacheKey struct{}
+
+// WithoutGroupCache returns a context for which the devices of a group are
+// always resolved from the inventory. The result still refreshes the cache.
+func WithoutGroupCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipGroupCacheKey{}, true)
+}
+
+func skipGroupCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipGroupCacheKey{}).(bool)
+	return skip
+}
+
+type groupCacheKey struct {
+	tenantID string
+	group    string
+}
+
+type groupCacheEntry struct {
+	key     groupCacheKey
+	devices []string
+	expires time.Time
+}
+
+// groupCache is a size-bounded LRU cache of the device IDs belonging to a
+// tenant's device group. A nil *groupCache is a disabled cache.
+type groupCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[groupCacheKey]*list.Element
+}
+
+func newGroupCache(ttl time.Duration, maxSize int) *groupCache {
+	if ttl <= 0 || maxSize < 1 {
+		return nil
+	}
+	return &groupCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[groupCacheKey]*list.Element),
+	}
+}
+
+func (c *groupCache) get(ctx context.Context, tenantID, group string) ([]string, bool) {
+	if c == nil || skipGroupCache(ctx) {
+		return nil, false
+	}
+	key := groupCacheKey{tenantID: tenantID, group: group}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if ok {
+		entry := elem.Value.(*groupCacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			groupCacheHits.Inc()
+			return append([]string(nil), entry.devices...), true
+		}
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	groupCacheMisses.Inc()
+	return nil, false
+}
+
+func (c *groupCache) set(tenantID, group string, devices []string) {
+	if c == nil {
+		return
+	}
+	key := groupCacheKey{tenantID: tenantID, group: group}
+	entry := &groupCacheEntry{
+		key:     key,
+		devices: append([]string(nil), devices...),
+		expires: time.Now().Add(c.ttl),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*groupCacheEntry).key)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	inventory_mocks "github.com/mendersoftware/deployments/client/inventory/mocks"
+	"github.com/mendersoftware/deployments/model"
+	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestGroupCache(t *testing.T) {
+	ctx := context.Background()
+	hits := testutil.ToFloat64(groupCacheHits)
+	misses := testutil.ToFloat64(groupCacheMisses)
+
+	var disabled *groupCache = newGroupCache(0, 10)
+	assert.Nil(t, disabled)
+	disabled.set("tenant", "group", []string{"dev"})
+	_, ok := disabled.get(ctx, "tenant", "group")
+	assert.False(t, ok)
+
+	cache := newGroupCache(time.Minute, 2)
+	_, ok = cache.get(ctx, "tenant", "group1")
+	assert.False(t, ok)
+
+	devices := []string{"dev1", "dev2"}
+	cache.set("tenant", "group1", devices)
+	devices[0] = "modified"
+	cached, ok := cache.get(ctx, "tenant", "group1")
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"dev1", "dev2"}, cached)
+	}
+	_, ok = cache.get(ctx, "other", "group1")
+	assert.False(t, ok, "groups must not be shared between tenants")
+
+	_, ok = cache.get(WithoutGroupCache(ctx), "tenant", "group1")
+	assert.False(t, ok, "cache was not bypassed")
+
+	// group1 was used last: adding a third group evicts group2
+	cache.set("tenant", "group2", []string{"dev3"})
+	_, _ = cache.get(ctx, "tenant", "group1")
+	cache.set("tenant", "group3", []string{"dev4"})
+	_, ok = cache.get(ctx, "tenant", "group2")
+	assert.False(t, ok, "least recently used group was not evicted")
+	_, ok = cache.get(ctx, "tenant", "group1")
+	assert.True(t, ok)
+
+	cache.entries[groupCacheKey{tenantID: "tenant", group: "group3"}].
+		Value.(*groupCacheEntry).expires = time.Now().Add(-time.Second)
+	_, ok = cache.get(ctx, "tenant", "group3")
+	assert.False(t, ok, "expired group was returned")
+	assert.Equal(t, 1, cache.lru.Len())
+
+	assert.Equal(t, hits+3, testutil.ToFloat64(groupCacheHits))
+	assert.Equal(t, misses+4, testutil.ToFloat64(groupCacheMisses))
+}
+
+func TestCreateDeploymentGroupCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(
+		context.Background(),
+		&identity.Identity{Tenant: "tenant_id"},
+	)
+	db := &mocks.DataStore{}
+	db.On("ImagesByName", mock.Anything, "App 123").
+		Return([]*model.Image{model.NewImage(
+			validUUIDv4,
+			&model.ImageMeta{},
+			&model.ArtifactMeta{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer"},
+				Depends:               map[string]interface{}{},
+			}, artifactSize)}, nil)
+	db.On("InsertDeployment", mock.Anything, mock.AnythingOfType("*model.Deployment")).
+		Return(nil)
+
+	inv := &inventory_mocks.Client{}
+	// The inventory is queried once for the first deployment and once for
+	// the deployment bypassing the cache.
+	inv.On("Search", mock.Anything, "tenant_id",
+		mock.AnythingOfType("model.SearchParams")).
+		Return([]model.InvDevice{
+			{ID: "b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+			{ID: "b532b01a-9313-404f-8d19-e7fcbe5cc348"},
+		}, 2, nil).
+		Twice()
+	defer inv.AssertExpectations(t)
+
+	d := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false).
+		WithGroupCache(time.Minute, 10)
+	d.SetInventoryClient(inv)
+
+	for _, ctx := range []context.Context{ctx, ctx, WithoutGroupCache(ctx)} {
+		constructor := &model.DeploymentConstructor{
+			Name:         "group",
+			ArtifactName: "App 123",
+			Group:        "group",
+		}
+		_, err := d.CreateDeployment(ctx, constructor)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{
+				"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+				"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+			}, constructor.Devices)
+		}
+	}
+}
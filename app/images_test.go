@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	workflows_mocks "github.com/mendersoftware/deployments/client/workflows/mocks"
@@ -646,7 +647,7 @@ func TestGenerateConfigurationImage(t *testing.T) {
 			ctx := context.Background()
 			ds := new(mocks.DataStore)
 			defer ds.AssertExpectations(t)
-			ds.On("FindDeploymentByID", ctx, tc.DeploymentID).
+			ds.On("FindDeploymentByID", ctx, tc.DeploymentID, false).
 				Return(tc.Deployment, tc.StoreError)
 			d := NewDeployments(ds, nil, 0, false)
 			artieFact, err := d.GenerateConfigurationImage(
@@ -695,3 +696,42 @@ func TestGenerateConfigurationImage(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateConfigurationImageLimit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	deploymentID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("deployment")).String()
+	ds := new(mocks.DataStore)
+	defer ds.AssertExpectations(t)
+	ds.On("FindDeploymentByID", ctx, deploymentID, false).
+		Return(&model.Deployment{
+			Id:            deploymentID,
+			Type:          model.DeploymentTypeConfiguration,
+			Configuration: []byte("{}"),
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "spicyDeployment",
+				ArtifactName: "spicyPi",
+			},
+		}, nil)
+	d := NewDeployments(ds, nil, 0, false).
+		WithConfigurationGenerationLimit(1, time.Millisecond*10)
+
+	// Occupy the only slot as a concurrent generation would.
+	release, err := d.generateLimiter.acquire(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = d.GenerateConfigurationImage(ctx, "strawberryPlanck", deploymentID)
+	assert.ErrorIs(t, err, ErrTooManyArtifactGenerations)
+
+	release()
+	artieFact, err := d.GenerateConfigurationImage(ctx, "strawberryPlanck", deploymentID)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, artieFact)
+	}
+	// The slot is free again once the artifact is generated.
+	release, err = d.generateLimiter.acquire(ctx)
+	if assert.NoError(t, err) {
+		release()
+	}
+}
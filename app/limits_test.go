@@ -25,6 +25,7 @@ import (
 
 	"github.com/mendersoftware/deployments/model"
 	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
+	"github.com/mendersoftware/deployments/store"
 	"github.com/mendersoftware/deployments/store/mocks"
 	"github.com/mendersoftware/deployments/store/mongo"
 )
@@ -96,3 +97,181 @@ func TestGetLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestSetLimit(t *testing.T) {
+	limit := &model.Limit{
+		Name:  model.LimitDownloadBandwidth,
+		Value: 1024,
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		db := mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("SetLimit", context.Background(), limit).Return(nil)
+
+		d := NewDeployments(&db, &fs_mocks.ObjectStorage{}, 0, false)
+		assert.NoError(t, d.SetLimit(context.Background(), limit))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		db := mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		db.On("SetLimit", context.Background(), limit).
+			Return(errors.New("connection refused"))
+
+		d := NewDeployments(&db, &fs_mocks.ObjectStorage{}, 0, false)
+		err := d.SetLimit(context.Background(), limit)
+		assert.EqualError(t, err, "failed to store limit: connection refused")
+	})
+}
+
+func TestCheckReleasesLimit(t *testing.T) {
+	const releaseName = "release-1"
+	testCases := map[string]struct {
+		limit    *model.Limit
+		limitErr error
+
+		count    int64
+		countErr error
+
+		releaseErr error
+
+		err error
+	}{
+		"ok, no limit": {
+			limitErr: mongo.ErrLimitNotFound,
+		},
+		"ok, below the limit": {
+			limit: &model.Limit{Name: model.LimitReleases, Value: 10},
+			count: 9,
+		},
+		"ok, existing release at the limit": {
+			limit: &model.Limit{Name: model.LimitReleases, Value: 10},
+			count: 10,
+		},
+		"error, new release at the limit": {
+			limit:      &model.Limit{Name: model.LimitReleases, Value: 10},
+			count:      10,
+			releaseErr: store.ErrNotFound,
+			err:        &LimitExceededError{Name: model.LimitReleases, Value: 10},
+		},
+		"error, counting releases": {
+			limit:    &model.Limit{Name: model.LimitReleases, Value: 10},
+			countErr: errors.New("connection refused"),
+			err:      errors.New("connection refused"),
+		},
+	}
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetLimit", ctx, model.LimitReleases).
+				Return(tc.limit, tc.limitErr)
+			if tc.limit != nil {
+				db.On("CountReleases", ctx).
+					Return(tc.count, tc.countErr)
+				if tc.countErr == nil && !tc.limit.IsLess(uint64(tc.count)) {
+					db.On("GetRelease", ctx, releaseName, "").
+						Return(&model.Release{Name: releaseName}, tc.releaseErr)
+				}
+			}
+
+			d := NewDeployments(&db, &fs_mocks.ObjectStorage{}, 0, false)
+			err := d.checkReleasesLimit(ctx, releaseName)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckArtifactKeys(t *testing.T) {
+	meta := &model.ArtifactMeta{
+		Depends: map[string]interface{}{
+			model.ArtifactDependsDeviceType: []string{"foo"},
+			"rootfs-image.checksum":         "abc",
+		},
+		Provides: map[string]string{
+			"artifact_name":         "release-1",
+			"rootfs-image.checksum": "def",
+			"rootfs-image.version":  "1.0",
+		},
+	}
+	testCases := map[string]struct {
+		provides    *model.Limit
+		providesErr error
+		depends     *model.Limit
+
+		err error
+	}{
+		"ok, no allowlists": {
+			providesErr: mongo.ErrLimitNotFound,
+		},
+		"ok, allowed keys": {
+			provides: &model.Limit{
+				Name: model.LimitArtifactProvidesKeys,
+				Keys: []string{"rootfs-image.*"},
+			},
+			depends: &model.Limit{
+				Name: model.LimitArtifactDependsKeys,
+				Keys: []string{"rootfs-image.checksum"},
+			},
+		},
+		"error, provides keys not allowed": {
+			provides: &model.Limit{
+				Name: model.LimitArtifactProvidesKeys,
+				Keys: []string{"data-partition.*"},
+			},
+			err: &ArtifactKeysError{
+				Field: "provides",
+				Keys:  []string{"rootfs-image.checksum", "rootfs-image.version"},
+			},
+		},
+		"error, depends keys not allowed": {
+			provides: &model.Limit{Name: model.LimitArtifactProvidesKeys},
+			depends: &model.Limit{
+				Name: model.LimitArtifactDependsKeys,
+				Keys: []string{"rootfs-image.version"},
+			},
+			err: &ArtifactKeysError{
+				Field: "depends",
+				Keys:  []string{"rootfs-image.checksum"},
+			},
+		},
+		"error, getting limit": {
+			providesErr: errors.New("connection refused"),
+			err:         errors.New("failed to obtain limit from storage: connection refused"),
+		},
+	}
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("GetLimit", ctx, model.LimitArtifactProvidesKeys).
+				Return(tc.provides, tc.providesErr)
+			if tc.providesErr == mongo.ErrLimitNotFound || tc.provides != nil &&
+				tc.provides.AllowsKey("rootfs-image.version") {
+				depends, dependsErr := tc.depends, error(nil)
+				if depends == nil {
+					dependsErr = mongo.ErrLimitNotFound
+				}
+				db.On("GetLimit", ctx, model.LimitArtifactDependsKeys).
+					Return(depends, dependsErr)
+			}
+
+			d := NewDeployments(&db, &fs_mocks.ObjectStorage{}, 0, false)
+			err := d.checkArtifactKeys(ctx, meta)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
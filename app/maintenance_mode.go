@@ -0,0 +1,103 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maintenanceModeCache holds the maintenance mode read from the database,
+// which every device request checks. A nil *maintenanceModeCache is a
+// disabled cache.
+type maintenanceModeCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	enabled bool
+	expires time.Time
+}
+
+func newMaintenanceModeCache(ttl time.Duration) *maintenanceModeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &maintenanceModeCache{ttl: ttl}
+}
+
+func (c *maintenanceModeCache) get() (enabled, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.expires) {
+		return c.enabled, true
+	}
+	return false, false
+}
+
+func (c *maintenanceModeCache) set(enabled bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	c.expires = time.Now().Add(c.ttl)
+}
+
+// WithMaintenanceModeCache caches the maintenance mode for up to ttl, so
+// that the instances notice it being toggled within ttl. A non-positive ttl
+// disables the cache.
+func (d *Deployments) WithMaintenanceModeCache(ttl time.Duration) *Deployments {
+	d.maintenanceCache = newMaintenanceModeCache(ttl)
+	return d
+}
+
+// GetMaintenanceMode returns whether the service is in maintenance mode.
+func (d *Deployments) GetMaintenanceMode(ctx context.Context) (bool, error) {
+	if enabled, ok := d.maintenanceCache.get(); ok {
+		return enabled, nil
+	}
+	enabled, err := d.db.GetMaintenanceMode(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get the maintenance mode")
+	}
+	d.maintenanceCache.set(enabled)
+	return enabled, nil
+}
+
+// SetMaintenanceMode enables or disables the maintenance mode of all the
+// instances of the service.
+func (d *Deployments) SetMaintenanceMode(ctx context.Context, enabled bool) error {
+	if err := d.db.SetMaintenanceMode(ctx, enabled); err != nil {
+		return errors.Wrap(err, "failed to set the maintenance mode")
+	}
+	d.maintenanceCache.set(enabled)
+	return nil
+}
+
+// InitMaintenanceMode sets the maintenance mode of all the instances unless
+// it was set before, e.g. by an instance started earlier, or through the API.
+func (d *Deployments) InitMaintenanceMode(ctx context.Context, enabled bool) error {
+	if err := d.db.InitMaintenanceMode(ctx, enabled); err != nil {
+		return errors.Wrap(err, "failed to initialize the maintenance mode")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/store/mocks"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cached", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		d := NewDeployments(db, nil, 0, false).WithMaintenanceModeCache(time.Minute)
+
+		db.On("GetMaintenanceMode", ctx).Return(false, nil).Once()
+		for i := 0; i < 2; i++ {
+			enabled, err := d.GetMaintenanceMode(ctx)
+			assert.NoError(t, err)
+			assert.False(t, enabled)
+		}
+
+		// setting it refreshes the cache of the instance
+		db.On("SetMaintenanceMode", ctx, true).Return(nil).Once()
+		assert.NoError(t, d.SetMaintenanceMode(ctx, true))
+		enabled, err := d.GetMaintenanceMode(ctx)
+		assert.NoError(t, err)
+		assert.True(t, enabled)
+
+		// the mode set by another instance is read once expired
+		d.maintenanceCache.expires = time.Now().Add(-time.Second)
+		db.On("GetMaintenanceMode", ctx).Return(false, nil).Once()
+		enabled, err = d.GetMaintenanceMode(ctx)
+		assert.NoError(t, err)
+		assert.False(t, enabled)
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		d := NewDeployments(db, nil, 0, false).WithMaintenanceModeCache(0)
+		assert.Nil(t, d.maintenanceCache)
+
+		db.On("GetMaintenanceMode", ctx).Return(true, nil).Twice()
+		for i := 0; i < 2; i++ {
+			enabled, err := d.GetMaintenanceMode(ctx)
+			assert.NoError(t, err)
+			assert.True(t, enabled)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		db := &mocks.DataStore{}
+		defer db.AssertExpectations(t)
+		d := NewDeployments(db, nil, 0, false).WithMaintenanceModeCache(time.Minute)
+
+		db.On("GetMaintenanceMode", ctx).Return(false, errors.New("db error")).Once()
+		_, err := d.GetMaintenanceMode(ctx)
+		assert.EqualError(t, err, "failed to get the maintenance mode: db error")
+
+		db.On("SetMaintenanceMode", ctx, mock.AnythingOfType("bool")).
+			Return(errors.New("db error")).Once()
+		err = d.SetMaintenanceMode(ctx, true)
+		assert.EqualError(t, err, "failed to set the maintenance mode: db error")
+
+		db.On("InitMaintenanceMode", ctx, true).
+			Return(errors.New("db error")).Once()
+		err = d.InitMaintenanceMode(ctx, true)
+		assert.EqualError(t, err, "failed to initialize the maintenance mode: db error")
+
+		// failures are not cached
+		db.On("GetMaintenanceMode", ctx).Return(false, nil).Once()
+		enabled, err := d.GetMaintenanceMode(ctx)
+		assert.NoError(t, err)
+		assert.False(t, enabled)
+	})
+}
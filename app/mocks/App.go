@@ -48,6 +48,29 @@ func (_m *App) AbortDeployment(ctx context.Context, deploymentID string) error {
 	return r0
 }
 
+// AbortDeploymentsByArtifactName provides a mock function with given fields: ctx, artifactName
+func (_m *App) AbortDeploymentsByArtifactName(ctx context.Context, artifactName string) (*model.AbortedDeployments, error) {
+	ret := _m.Called(ctx, artifactName)
+
+	var r0 *model.AbortedDeployments
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.AbortedDeployments); ok {
+		r0 = rf(ctx, artifactName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.AbortedDeployments)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, artifactName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AbortDeviceDeployments provides a mock function with given fields: ctx, deviceID
 func (_m *App) AbortDeviceDeployments(ctx context.Context, deviceID string) error {
 	ret := _m.Called(ctx, deviceID)
@@ -62,13 +85,34 @@ func (_m *App) AbortDeviceDeployments(ctx context.Context, deviceID string) erro
 	return r0
 }
 
-// CompleteUpload provides a mock function with given fields: ctx, intentID, skipVerify, metadata
-func (_m *App) CompleteUpload(ctx context.Context, intentID string, skipVerify bool, metadata *model.DirectUploadMetadata) error {
-	ret := _m.Called(ctx, intentID, skipVerify, metadata)
+// CheckDeploymentForDevice provides a mock function with given fields: ctx, deviceID
+func (_m *App) CheckDeploymentForDevice(ctx context.Context, deviceID string) (string, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CompleteUpload provides a mock function with given fields: ctx, intentID, skipVerify, metadata, expectedSHA256
+func (_m *App) CompleteUpload(ctx context.Context, intentID string, skipVerify bool, metadata *model.DirectUploadMetadata, expectedSHA256 string) error {
+	ret := _m.Called(ctx, intentID, skipVerify, metadata, expectedSHA256)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, bool, *model.DirectUploadMetadata) error); ok {
-		r0 = rf(ctx, intentID, skipVerify, metadata)
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool, *model.DirectUploadMetadata, string) error); ok {
+		r0 = rf(ctx, intentID, skipVerify, metadata, expectedSHA256)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -76,6 +120,73 @@ func (_m *App) CompleteUpload(ctx context.Context, intentID string, skipVerify b
 	return r0
 }
 
+// CountActiveDeploymentsByDevice provides a mock function with given fields: ctx, deviceID
+func (_m *App) CountActiveDeploymentsByDevice(ctx context.Context, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountActiveDeploymentsPerTenant provides a mock function with given fields: ctx, query
+func (_m *App) CountActiveDeploymentsPerTenant(ctx context.Context, query model.TenantActiveDeploymentsQuery) (*model.TenantActiveDeploymentsPage, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 *model.TenantActiveDeploymentsPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.TenantActiveDeploymentsQuery) *model.TenantActiveDeploymentsPage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TenantActiveDeploymentsPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.TenantActiveDeploymentsQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountDeploymentsByStatus provides a mock function with given fields: ctx
+func (_m *App) CountDeploymentsByStatus(ctx context.Context) (map[model.DeploymentStatus]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[model.DeploymentStatus]int
+	if rf, ok := ret.Get(0).(func(context.Context) map[model.DeploymentStatus]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[model.DeploymentStatus]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateDeployment provides a mock function with given fields: ctx, constructor
 func (_m *App) CreateDeployment(ctx context.Context, constructor *model.DeploymentConstructor) (string, error) {
 	ret := _m.Called(ctx, constructor)
@@ -139,6 +250,29 @@ func (_m *App) CreateImage(ctx context.Context, multipartUploadMsg *model.Multip
 	return r0, r1
 }
 
+// CreateUploadSession provides a mock function with given fields: ctx, expire, skipVerify
+func (_m *App) CreateUploadSession(ctx context.Context, expire time.Duration, skipVerify bool) (*model.UploadSession, error) {
+	ret := _m.Called(ctx, expire, skipVerify)
+
+	var r0 *model.UploadSession
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, bool) *model.UploadSession); ok {
+		r0 = rf(ctx, expire, skipVerify)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UploadSession)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration, bool) error); ok {
+		r1 = rf(ctx, expire, skipVerify)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DecommissionDevice provides a mock function with given fields: ctx, deviceID
 func (_m *App) DecommissionDevice(ctx context.Context, deviceID string) error {
 	ret := _m.Called(ctx, deviceID)
@@ -153,6 +287,20 @@ func (_m *App) DecommissionDevice(ctx context.Context, deviceID string) error {
 	return r0
 }
 
+// DeleteDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *App) DeleteDeployment(ctx context.Context, deploymentID string) error {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteDeviceDeploymentsHistory provides a mock function with given fields: ctx, deviceId
 func (_m *App) DeleteDeviceDeploymentsHistory(ctx context.Context, deviceId string) error {
 	ret := _m.Called(ctx, deviceId)
@@ -181,6 +329,20 @@ func (_m *App) DeleteImage(ctx context.Context, imageID string) error {
 	return r0
 }
 
+// DeleteRelease provides a mock function with given fields: ctx, name
+func (_m *App) DeleteRelease(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteReleases provides a mock function with given fields: ctx, releaseNames
 func (_m *App) DeleteReleases(ctx context.Context, releaseNames []string) ([]string, error) {
 	ret := _m.Called(ctx, releaseNames)
@@ -204,6 +366,29 @@ func (_m *App) DeleteReleases(ctx context.Context, releaseNames []string) ([]str
 	return r0, r1
 }
 
+// DownloadArtifact provides a mock function with given fields: ctx, image, offset, length
+func (_m *App) DownloadArtifact(ctx context.Context, image *model.Image, offset int64, length int64) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, image, offset, length)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Image, int64, int64) io.ReadCloser); ok {
+		r0 = rf(ctx, image, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.Image, int64, int64) error); ok {
+		r1 = rf(ctx, image, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DownloadLink provides a mock function with given fields: ctx, imageID, expire
 func (_m *App) DownloadLink(ctx context.Context, imageID string, expire time.Duration) (*model.Link, error) {
 	ret := _m.Called(ctx, imageID, expire)
@@ -248,6 +433,112 @@ func (_m *App) EditImage(ctx context.Context, id string, constructorData *model.
 	return r0, r1
 }
 
+// ExportDeployments provides a mock function with given fields: ctx
+func (_m *App) ExportDeployments(ctx context.Context) (*model.ExportJob, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context) *model.ExportJob); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDeploymentsByArtifact provides a mock function with given fields: ctx, name, id, query
+func (_m *App) FindDeploymentsByArtifact(ctx context.Context, name string, id string, query model.Query) ([]*model.Deployment, int64, error) {
+	ret := _m.Called(ctx, name, id, query)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, model.Query) []*model.Deployment); ok {
+		r0 = rf(ctx, name, id, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, model.Query) int64); ok {
+		r1 = rf(ctx, name, id, query)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, model.Query) error); ok {
+		r2 = rf(ctx, name, id, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FindDeploymentsByGroup provides a mock function with given fields: ctx, group, query
+func (_m *App) FindDeploymentsByGroup(ctx context.Context, group string, query model.Query) ([]*model.Deployment, int64, error) {
+	ret := _m.Called(ctx, group, query)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Query) []*model.Deployment); ok {
+		r0 = rf(ctx, group, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.Query) int64); ok {
+		r1 = rf(ctx, group, query)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, model.Query) error); ok {
+		r2 = rf(ctx, group, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FindStaleActiveDeployments provides a mock function with given fields: ctx, olderThan, limit
+func (_m *App) FindStaleActiveDeployments(ctx context.Context, olderThan time.Time, limit int) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, olderThan, limit)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []*model.Deployment); ok {
+		r0 = rf(ctx, olderThan, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, olderThan, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GenerateConfigurationImage provides a mock function with given fields: ctx, deviceType, deploymentID
 func (_m *App) GenerateConfigurationImage(ctx context.Context, deviceType string, deploymentID string) (io.Reader, error) {
 	ret := _m.Called(ctx, deviceType, deploymentID)
@@ -292,22 +583,22 @@ func (_m *App) GenerateImage(ctx context.Context, multipartUploadMsg *model.Mult
 	return r0, r1
 }
 
-// GetDeployment provides a mock function with given fields: ctx, deploymentID
-func (_m *App) GetDeployment(ctx context.Context, deploymentID string) (*model.Deployment, error) {
-	ret := _m.Called(ctx, deploymentID)
+// GetActiveDeploymentsForDevice provides a mock function with given fields: ctx, deviceID
+func (_m *App) GetActiveDeploymentsForDevice(ctx context.Context, deviceID string) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, deviceID)
 
-	var r0 *model.Deployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Deployment); ok {
-		r0 = rf(ctx, deploymentID)
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*model.Deployment); ok {
+		r0 = rf(ctx, deviceID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Deployment)
+			r0 = ret.Get(0).([]*model.Deployment)
 		}
 	}
 
 	var r1 error
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deploymentID)
+		r1 = rf(ctx, deviceID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -315,22 +606,22 @@ func (_m *App) GetDeployment(ctx context.Context, deploymentID string) (*model.D
 	return r0, r1
 }
 
-// GetDeploymentForDeviceWithCurrent provides a mock function with given fields: ctx, deviceID, request
-func (_m *App) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string, request *model.DeploymentNextRequest) (*model.DeploymentInstructions, error) {
-	ret := _m.Called(ctx, deviceID, request)
+// GetArtifactImportJob provides a mock function with given fields: ctx, id
+func (_m *App) GetArtifactImportJob(ctx context.Context, id string) (*model.ArtifactImportJob, error) {
+	ret := _m.Called(ctx, id)
 
-	var r0 *model.DeploymentInstructions
-	if rf, ok := ret.Get(0).(func(context.Context, string, *model.DeploymentNextRequest) *model.DeploymentInstructions); ok {
-		r0 = rf(ctx, deviceID, request)
+	var r0 *model.ArtifactImportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ArtifactImportJob); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.DeploymentInstructions)
+			r0 = ret.Get(0).(*model.ArtifactImportJob)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, *model.DeploymentNextRequest) error); ok {
-		r1 = rf(ctx, deviceID, request)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -338,28 +629,157 @@ func (_m *App) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID s
 	return r0, r1
 }
 
-// GetDeploymentStats provides a mock function with given fields: ctx, deploymentID
-func (_m *App) GetDeploymentStats(ctx context.Context, deploymentID string) (model.Stats, error) {
-	ret := _m.Called(ctx, deploymentID)
+// GetComplianceReport provides a mock function with given fields: ctx, from, to, skip, limit
+func (_m *App) GetComplianceReport(ctx context.Context, from time.Time, to time.Time, skip int, limit int) ([]model.DeploymentReport, int, error) {
+	ret := _m.Called(ctx, from, to, skip, limit)
 
-	var r0 model.Stats
-	if rf, ok := ret.Get(0).(func(context.Context, string) model.Stats); ok {
-		r0 = rf(ctx, deploymentID)
+	var r0 []model.DeploymentReport
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, int, int) []model.DeploymentReport); ok {
+		r0 = rf(ctx, from, to, skip, limit)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(model.Stats)
+			r0 = ret.Get(0).([]model.DeploymentReport)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deploymentID)
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, int, int) int); ok {
+		r1 = rf(ctx, from, to, skip, limit)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(int)
 	}
 
-	return r0, r1
-}
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time, time.Time, int, int) error); ok {
+		r2 = rf(ctx, from, to, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *App) GetDeployment(ctx context.Context, deploymentID string) (*model.Deployment, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 *model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Deployment); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentCreationTrend provides a mock function with given fields: ctx, from, to, granularity
+func (_m *App) GetDeploymentCreationTrend(ctx context.Context, from time.Time, to time.Time, granularity model.TrendGranularity) ([]model.TrendBucket, error) {
+	ret := _m.Called(ctx, from, to, granularity)
+
+	var r0 []model.TrendBucket
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, model.TrendGranularity) []model.TrendBucket); ok {
+		r0 = rf(ctx, from, to, granularity)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.TrendBucket)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, model.TrendGranularity) error); ok {
+		r1 = rf(ctx, from, to, granularity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentForDeviceWithCurrent provides a mock function with given fields: ctx, deviceID, request
+func (_m *App) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string, request *model.DeploymentNextRequest) (*model.DeploymentInstructions, error) {
+	ret := _m.Called(ctx, deviceID, request)
+
+	var r0 *model.DeploymentInstructions
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.DeploymentNextRequest) *model.DeploymentInstructions); ok {
+		r0 = rf(ctx, deviceID, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeploymentInstructions)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.DeploymentNextRequest) error); ok {
+		r1 = rf(ctx, deviceID, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentStats provides a mock function with given fields: ctx, deploymentID
+func (_m *App) GetDeploymentStats(ctx context.Context, deploymentID string) (model.Stats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 model.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.Stats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentTargetDevices provides a mock function with given fields: ctx, deploymentID, skip, limit
+func (_m *App) GetDeploymentTargetDevices(ctx context.Context, deploymentID string, skip int, limit int) ([]string, int, error) {
+	ret := _m.Called(ctx, deploymentID, skip, limit)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []string); ok {
+		r0 = rf(ctx, deploymentID, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) int); ok {
+		r1 = rf(ctx, deploymentID, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, int, int) error); ok {
+		r2 = rf(ctx, deploymentID, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
 
 // GetDeploymentsStats provides a mock function with given fields: ctx, deploymentIDs
 func (_m *App) GetDeploymentsStats(ctx context.Context, deploymentIDs ...string) ([]*model.DeploymentStats, error) {
@@ -442,50 +862,64 @@ func (_m *App) GetDeviceDeploymentListForDevice(ctx context.Context, query store
 	return r0, r1, r2
 }
 
-// GetDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID
-func (_m *App) GetDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string) (*model.DeploymentLog, error) {
-	ret := _m.Called(ctx, deviceID, deploymentID)
+// GetDeviceDeploymentLog provides a mock function with given fields: ctx, query
+func (_m *App) GetDeviceDeploymentLog(ctx context.Context, query store.ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error) {
+	ret := _m.Called(ctx, query)
 
-	var r0 *model.DeploymentLog
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.DeploymentLog); ok {
-		r0 = rf(ctx, deviceID, deploymentID)
+	var r0 []model.DeploymentLog
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeploymentLog) []model.DeploymentLog); ok {
+		r0 = rf(ctx, query)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.DeploymentLog)
+			r0 = ret.Get(0).([]model.DeploymentLog)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, deviceID, deploymentID)
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeploymentLog) int); ok {
+		r1 = rf(ctx, query)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(int)
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeploymentLog) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// GetDeviceStatusesForDeployment provides a mock function with given fields: ctx, deploymentID
-func (_m *App) GetDeviceStatusesForDeployment(ctx context.Context, deploymentID string) ([]model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, deploymentID)
+// GetDeviceDeploymentViewsForDevice provides a mock function with given fields: ctx, query
+func (_m *App) GetDeviceDeploymentViewsForDevice(ctx context.Context, query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error) {
+	ret := _m.Called(ctx, query)
 
-	var r0 []model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) []model.DeviceDeployment); ok {
-		r0 = rf(ctx, deploymentID)
+	var r0 []model.DeviceDeploymentView
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeployments) []model.DeviceDeploymentView); ok {
+		r0 = rf(ctx, query)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeployment)
+			r0 = ret.Get(0).([]model.DeviceDeploymentView)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deploymentID)
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeployments) int); ok {
+		r1 = rf(ctx, query)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(int)
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeployments) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
 // GetDevicesListForDeployment provides a mock function with given fields: ctx, query
@@ -518,6 +952,59 @@ func (_m *App) GetDevicesListForDeployment(ctx context.Context, query store.List
 	return r0, r1, r2
 }
 
+// GetExportJob provides a mock function with given fields: ctx, id
+func (_m *App) GetExportJob(ctx context.Context, id string) (*model.ExportJob, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ExportJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFleetSoftwareInventory provides a mock function with given fields: ctx, query
+func (_m *App) GetFleetSoftwareInventory(ctx context.Context, query model.SoftwareInventoryQuery) ([]model.DeviceSoftware, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeviceSoftware
+	if rf, ok := ret.Get(0).(func(context.Context, model.SoftwareInventoryQuery) []model.DeviceSoftware); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceSoftware)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.SoftwareInventoryQuery) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SoftwareInventoryQuery) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetImage provides a mock function with given fields: ctx, id
 func (_m *App) GetImage(ctx context.Context, id string) (*model.Image, error) {
 	ret := _m.Called(ctx, id)
@@ -541,6 +1028,75 @@ func (_m *App) GetImage(ctx context.Context, id string) (*model.Image, error) {
 	return r0, r1
 }
 
+// GetImageForDevice provides a mock function with given fields: ctx, deviceID, imageID
+func (_m *App) GetImageForDevice(ctx context.Context, deviceID string, imageID string) (*model.Image, error) {
+	ret := _m.Called(ctx, deviceID, imageID)
+
+	var r0 *model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.Image); ok {
+		r0 = rf(ctx, deviceID, imageID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Image)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deviceID, imageID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetImagesListVersion provides a mock function with given fields: ctx, filters
+func (_m *App) GetImagesListVersion(ctx context.Context, filters *model.ReleaseOrImageFilter) (*model.ListVersion, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 *model.ListVersion
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ReleaseOrImageFilter) *model.ListVersion); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ListVersion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ReleaseOrImageFilter) error); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLargestDeployments provides a mock function with given fields: ctx, limit
+func (_m *App) GetLargestDeployments(ctx context.Context, limit int) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, limit)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*model.Deployment); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetLimit provides a mock function with given fields: ctx, name
 func (_m *App) GetLimit(ctx context.Context, name string) (*model.Limit, error) {
 	ret := _m.Called(ctx, name)
@@ -564,6 +1120,57 @@ func (_m *App) GetLimit(ctx context.Context, name string) (*model.Limit, error)
 	return r0, r1
 }
 
+// GetMaintenanceMode provides a mock function with given fields: ctx
+func (_m *App) GetMaintenanceMode(ctx context.Context) (bool, error) {
+	ret := _m.Called(ctx)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context) bool); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetReleaseRollout provides a mock function with given fields: ctx, releaseName, query
+func (_m *App) GetReleaseRollout(ctx context.Context, releaseName string, query model.Query) (*model.ReleaseRollout, int64, error) {
+	ret := _m.Called(ctx, releaseName, query)
+
+	var r0 *model.ReleaseRollout
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Query) *model.ReleaseRollout); ok {
+		r0 = rf(ctx, releaseName, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReleaseRollout)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.Query) int64); ok {
+		r1 = rf(ctx, releaseName, query)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, model.Query) error); ok {
+		r2 = rf(ctx, releaseName, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetReleasesUpdateTypes provides a mock function with given fields: ctx
 func (_m *App) GetReleasesUpdateTypes(ctx context.Context) ([]string, error) {
 	ret := _m.Called(ctx)
@@ -587,6 +1194,29 @@ func (_m *App) GetReleasesUpdateTypes(ctx context.Context) ([]string, error) {
 	return r0, r1
 }
 
+// GetReleasesUpdateTypesWithCounts provides a mock function with given fields: ctx
+func (_m *App) GetReleasesUpdateTypesWithCounts(ctx context.Context) (map[string]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]int
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetStorageSettings provides a mock function with given fields: ctx
 func (_m *App) GetStorageSettings(ctx context.Context) (*model.StorageSettings, error) {
 	ret := _m.Called(ctx)
@@ -610,6 +1240,29 @@ func (_m *App) GetStorageSettings(ctx context.Context) (*model.StorageSettings,
 	return r0, r1
 }
 
+// GetUploadSession provides a mock function with given fields: ctx, intentID
+func (_m *App) GetUploadSession(ctx context.Context, intentID string) (*model.UploadSession, error) {
+	ret := _m.Called(ctx, intentID)
+
+	var r0 *model.UploadSession
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.UploadSession); ok {
+		r0 = rf(ctx, intentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UploadSession)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, intentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HasDeploymentForDevice provides a mock function with given fields: ctx, deploymentID, deviceID
 func (_m *App) HasDeploymentForDevice(ctx context.Context, deploymentID string, deviceID string) (bool, error) {
 	ret := _m.Called(ctx, deploymentID, deviceID)
@@ -622,8 +1275,66 @@ func (_m *App) HasDeploymentForDevice(ctx context.Context, deploymentID string,
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, deploymentID, deviceID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HealthCheck provides a mock function with given fields: ctx
+func (_m *App) HealthCheck(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ImportArtifacts provides a mock function with given fields: ctx, req
+func (_m *App) ImportArtifacts(ctx context.Context, req model.ArtifactImportRequest) (*model.ArtifactImportJob, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *model.ArtifactImportJob
+	if rf, ok := ret.Get(0).(func(context.Context, model.ArtifactImportRequest) *model.ArtifactImportJob); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ArtifactImportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.ArtifactImportRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsDeploymentFinished provides a mock function with given fields: ctx, deploymentID
+func (_m *App) IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -631,34 +1342,52 @@ func (_m *App) HasDeploymentForDevice(ctx context.Context, deploymentID string,
 	return r0, r1
 }
 
-// HealthCheck provides a mock function with given fields: ctx
-func (_m *App) HealthCheck(ctx context.Context) error {
-	ret := _m.Called(ctx)
+// ListArtifactDeletions provides a mock function with given fields: ctx, query
+func (_m *App) ListArtifactDeletions(ctx context.Context, query model.ArtifactDeletionsQuery) ([]model.ArtifactDeletion, int, error) {
+	ret := _m.Called(ctx, query)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = rf(ctx)
+	var r0 []model.ArtifactDeletion
+	if rf, ok := ret.Get(0).(func(context.Context, model.ArtifactDeletionsQuery) []model.ArtifactDeletion); ok {
+		r0 = rf(ctx, query)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ArtifactDeletion)
+		}
 	}
 
-	return r0
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.ArtifactDeletionsQuery) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.ArtifactDeletionsQuery) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// IsDeploymentFinished provides a mock function with given fields: ctx, deploymentID
-func (_m *App) IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error) {
-	ret := _m.Called(ctx, deploymentID)
+// ListArtifactsModifiedSince provides a mock function with given fields: ctx, since, afterID, skip, limit
+func (_m *App) ListArtifactsModifiedSince(ctx context.Context, since time.Time, afterID string, skip int, limit int) ([]*model.Image, error) {
+	ret := _m.Called(ctx, since, afterID, skip, limit)
 
-	var r0 bool
-	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
-		r0 = rf(ctx, deploymentID)
+	var r0 []*model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, string, int, int) []*model.Image); ok {
+		r0 = rf(ctx, since, afterID, skip, limit)
 	} else {
-		r0 = ret.Get(0).(bool)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Image)
+		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deploymentID)
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, string, int, int) error); ok {
+		r1 = rf(ctx, since, afterID, skip, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -696,6 +1425,36 @@ func (_m *App) ListImages(ctx context.Context, filters *model.ReleaseOrImageFilt
 	return r0, r1, r2
 }
 
+// ListOrphanedArtifacts provides a mock function with given fields: ctx, skip, limit
+func (_m *App) ListOrphanedArtifacts(ctx context.Context, skip int, limit int) ([]*model.Image, int, error) {
+	ret := _m.Called(ctx, skip, limit)
+
+	var r0 []*model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []*model.Image); ok {
+		r0 = rf(ctx, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Image)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
+		r1 = rf(ctx, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // ListReleaseTags provides a mock function with given fields: ctx
 func (_m *App) ListReleaseTags(ctx context.Context) (model.Tags, error) {
 	ret := _m.Called(ctx)
@@ -749,6 +1508,29 @@ func (_m *App) LookupDeployment(ctx context.Context, query model.Query) ([]*mode
 	return r0, r1, r2
 }
 
+// PreviewDeployment provides a mock function with given fields: ctx, constructor
+func (_m *App) PreviewDeployment(ctx context.Context, constructor *model.DeploymentConstructor) (*model.DeploymentPreview, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 *model.DeploymentPreview
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DeploymentConstructor) *model.DeploymentPreview); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeploymentPreview)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.DeploymentConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ProvisionTenant provides a mock function with given fields: ctx, tenant_id
 func (_m *App) ProvisionTenant(ctx context.Context, tenant_id string) error {
 	ret := _m.Called(ctx, tenant_id)
@@ -763,6 +1545,57 @@ func (_m *App) ProvisionTenant(ctx context.Context, tenant_id string) error {
 	return r0
 }
 
+// ReconcileDeviceCount provides a mock function with given fields: ctx, deploymentID
+func (_m *App) ReconcileDeviceCount(ctx context.Context, deploymentID string) (*model.DeviceCountReconciliation, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 *model.DeviceCountReconciliation
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceCountReconciliation); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeviceCountReconciliation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceImage provides a mock function with given fields: ctx, imageID, multipartUploadMsg
+func (_m *App) ReplaceImage(ctx context.Context, imageID string, multipartUploadMsg *model.MultipartUploadMsg) error {
+	ret := _m.Called(ctx, imageID, multipartUploadMsg)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.MultipartUploadMsg) error); ok {
+		r0 = rf(ctx, imageID, multipartUploadMsg)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplaceImageTags provides a mock function with given fields: ctx, imageID, tags
+func (_m *App) ReplaceImageTags(ctx context.Context, imageID string, tags model.Tags) error {
+	ret := _m.Called(ctx, imageID, tags)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Tags) error); ok {
+		r0 = rf(ctx, imageID, tags)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ReplaceReleaseTags provides a mock function with given fields: ctx, releaseName, tags
 func (_m *App) ReplaceReleaseTags(ctx context.Context, releaseName string, tags model.Tags) error {
 	ret := _m.Called(ctx, releaseName, tags)
@@ -777,6 +1610,48 @@ func (_m *App) ReplaceReleaseTags(ctx context.Context, releaseName string, tags
 	return r0
 }
 
+// ReportUploadPart provides a mock function with given fields: ctx, intentID, part
+func (_m *App) ReportUploadPart(ctx context.Context, intentID string, part model.UploadPart) error {
+	ret := _m.Called(ctx, intentID, part)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.UploadPart) error); ok {
+		r0 = rf(ctx, intentID, part)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *App) RestoreDeployment(ctx context.Context, deploymentID string) error {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RunExportJob provides a mock function with given fields: ctx, id
+func (_m *App) RunExportJob(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID, logs
 func (_m *App) SaveDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string, logs []model.LogMessage) error {
 	ret := _m.Called(ctx, deviceID, deploymentID, logs)
@@ -791,6 +1666,34 @@ func (_m *App) SaveDeviceDeploymentLog(ctx context.Context, deviceID string, dep
 	return r0
 }
 
+// SetLimit provides a mock function with given fields: ctx, limit
+func (_m *App) SetLimit(ctx context.Context, limit *model.Limit) error {
+	ret := _m.Called(ctx, limit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Limit) error); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetMaintenanceMode provides a mock function with given fields: ctx, enabled
+func (_m *App) SetMaintenanceMode(ctx context.Context, enabled bool) error {
+	ret := _m.Called(ctx, enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) error); ok {
+		r0 = rf(ctx, enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetStorageSettings provides a mock function with given fields: ctx, storageSettings
 func (_m *App) SetStorageSettings(ctx context.Context, storageSettings *model.StorageSettings) error {
 	ret := _m.Called(ctx, storageSettings)
@@ -805,6 +1708,99 @@ func (_m *App) SetStorageSettings(ctx context.Context, storageSettings *model.St
 	return r0
 }
 
+// StartDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *App) StartDeployment(ctx context.Context, deploymentID string) error {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StreamComplianceReport provides a mock function with given fields: ctx, from, to, write
+func (_m *App) StreamComplianceReport(ctx context.Context, from time.Time, to time.Time, write func(*model.DeploymentReport) error) error {
+	ret := _m.Called(ctx, from, to, write)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, func(*model.DeploymentReport) error) error); ok {
+		r0 = rf(ctx, from, to, write)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StreamDeviceStatusesForDeployment provides a mock function with given fields: ctx, deploymentID, write
+func (_m *App) StreamDeviceStatusesForDeployment(ctx context.Context, deploymentID string, write func(*model.DeviceDeployment) error) error {
+	ret := _m.Called(ctx, deploymentID, write)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func(*model.DeviceDeployment) error) error); ok {
+		r0 = rf(ctx, deploymentID, write)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StreamDevicesListForDeployment provides a mock function with given fields: ctx, query, write
+func (_m *App) StreamDevicesListForDeployment(ctx context.Context, query store.ListQuery, write func(*model.DeviceDeployment) error) error {
+	ret := _m.Called(ctx, query, write)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery, func(*model.DeviceDeployment) error) error); ok {
+		r0 = rf(ctx, query, write)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnknownArtifactDeviceTypes provides a mock function with given fields: ctx, artifactID
+func (_m *App) UnknownArtifactDeviceTypes(ctx context.Context, artifactID string) ([]string, error) {
+	ret := _m.Called(ctx, artifactID)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, artifactID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, artifactID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDeployment provides a mock function with given fields: ctx, deploymentID, patch
+func (_m *App) UpdateDeployment(ctx context.Context, deploymentID string, patch model.DeploymentPatch) error {
+	ret := _m.Called(ctx, deploymentID, patch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeploymentPatch) error); ok {
+		r0 = rf(ctx, deploymentID, patch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateDeploymentsWithArtifactName provides a mock function with given fields: ctx, artifactName
 func (_m *App) UpdateDeploymentsWithArtifactName(ctx context.Context, artifactName string) error {
 	ret := _m.Called(ctx, artifactName)
@@ -847,13 +1843,13 @@ func (_m *App) UpdateRelease(ctx context.Context, releaseName string, release mo
 	return r0
 }
 
-// UploadLink provides a mock function with given fields: ctx, expire, skipVerify
-func (_m *App) UploadLink(ctx context.Context, expire time.Duration, skipVerify bool) (*model.UploadLink, error) {
-	ret := _m.Called(ctx, expire, skipVerify)
+// UploadLink provides a mock function with given fields: ctx, expire, skipVerify, size
+func (_m *App) UploadLink(ctx context.Context, expire time.Duration, skipVerify bool, size int64) (*model.UploadLink, error) {
+	ret := _m.Called(ctx, expire, skipVerify, size)
 
 	var r0 *model.UploadLink
-	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, bool) *model.UploadLink); ok {
-		r0 = rf(ctx, expire, skipVerify)
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, bool, int64) *model.UploadLink); ok {
+		r0 = rf(ctx, expire, skipVerify, size)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.UploadLink)
@@ -861,8 +1857,31 @@ func (_m *App) UploadLink(ctx context.Context, expire time.Duration, skipVerify
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, time.Duration, bool) error); ok {
-		r1 = rf(ctx, expire, skipVerify)
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration, bool, int64) error); ok {
+		r1 = rf(ctx, expire, skipVerify, size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UploadPartLink provides a mock function with given fields: ctx, intentID, partNumber, expire
+func (_m *App) UploadPartLink(ctx context.Context, intentID string, partNumber int, expire time.Duration) (*model.Link, error) {
+	ret := _m.Called(ctx, intentID, partNumber, expire)
+
+	var r0 *model.Link
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, time.Duration) *model.Link); ok {
+		r0 = rf(ctx, intentID, partNumber, expire)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Link)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, time.Duration) error); ok {
+		r1 = rf(ctx, intentID, partNumber, expire)
 	} else {
 		r1 = ret.Error(1)
 	}
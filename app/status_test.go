@@ -83,13 +83,13 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 		fakeDeployment.Stats.Inc(model.DeviceDeploymentStatusInstalling)
 	}).Return(fakeDeployment.Stats, nil).Once()
 
-	db.On("FindDeploymentByID", ctx, fakeDeployment.Id).Return(
+	db.On("FindDeploymentByID", ctx, fakeDeployment.Id, true).Return(
 		fakeDeployment, nil).Once()
 
 	db.On("SetDeploymentStatus", ctx,
 		fakeDeployment.Id,
 		model.DeploymentStatusInProgress,
-		mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mock.AnythingOfType("time.Time")).Return(true, nil).Once()
 
 	ds := NewDeployments(&db, fs, 0, false)
 
@@ -99,9 +99,447 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 	db.On("GetDeviceDeployment", ctx,
 		fakeDeployment.Id, devId, false).Return(
 		nil, mongo.ErrStorageNotFound).Once()
+	db.On("GetDeviceDeployment", ctx,
+		fakeDeployment.Id, devId, true).Return(
+		nil, mongo.ErrStorageNotFound).Once()
 
 	err = ds.UpdateDeviceDeploymentStatus(ctx, fakeDeployment.Id, fakeDeviceDeployment.DeviceId, ddStatusNew)
 	assert.Equal(t, err, ErrStorageNotFound)
+
+	deleted := time.Now()
+	deletedDeviceDeployment := model.NewDeviceDeployment(devId, fakeDeployment.Id)
+	deletedDeviceDeployment.Deleted = &deleted
+	db.On("GetDeviceDeployment", ctx,
+		fakeDeployment.Id, devId, false).Return(
+		nil, mongo.ErrStorageNotFound).Once()
+	db.On("GetDeviceDeployment", ctx,
+		fakeDeployment.Id, devId, true).Return(
+		deletedDeviceDeployment, nil).Once()
+
+	err = ds.UpdateDeviceDeploymentStatus(ctx, fakeDeployment.Id, fakeDeviceDeployment.DeviceId, ddStatusNew)
+	assert.Equal(t, err, ErrDeploymentDeleted)
+}
+
+func TestUpdateDeviceDeploymentStatusRetry(t *testing.T) {
+	t.Parallel()
+
+	const devId = "somedevice"
+
+	testCases := map[string]struct {
+		retries  uint
+		attempts uint
+
+		callRetry bool
+		retried   bool
+		retryErr  error
+
+		deploymentStatus model.DeploymentStatus
+		err              error
+	}{
+		"ok, rescheduled": {
+			retries:          2,
+			attempts:         1,
+			callRetry:        true,
+			retried:          true,
+			deploymentStatus: model.DeploymentStatusPending,
+		},
+		"ok, no retries": {
+			deploymentStatus: model.DeploymentStatusFinished,
+		},
+		"ok, retries exhausted": {
+			retries:          2,
+			attempts:         2,
+			deploymentStatus: model.DeploymentStatusFinished,
+		},
+		"ok, concurrently rescheduled": {
+			retries:          2,
+			callRetry:        true,
+			deploymentStatus: model.DeploymentStatusFinished,
+		},
+		"error, rescheduling": {
+			retries:   2,
+			callRetry: true,
+			retryErr:  errors.New("connection refused"),
+			err:       errors.New("failed to reschedule the device deployment: connection refused"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			deployment, err := model.NewDeploymentFromConstructor(
+				&model.DeploymentConstructor{
+					Name:         "foo",
+					ArtifactName: "bar",
+					Devices:      []string{devId},
+					Retries:      tc.retries,
+				},
+			)
+			assert.NoError(t, err)
+			deployment.MaxDevices = 1
+			deployment.Stats.Set(model.DeviceDeploymentStatusInstalling, 1)
+
+			dd := model.NewDeviceDeployment(devId, deployment.Id)
+			dd.Status = model.DeviceDeploymentStatusInstalling
+			dd.Attempts = tc.attempts
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+
+			db.On("UpdateDeviceDeploymentStatus", ctx, devId, deployment.Id,
+				mock.AnythingOfType("model.DeviceDeploymentState"),
+				model.DeviceDeploymentStatusInstalling,
+			).Return(model.DeviceDeploymentStatusInstalling, nil).Once()
+			db.On("FindDeploymentByID", ctx, deployment.Id, true).
+				Return(deployment, nil).Once()
+			db.On("UpdateStatsInc", ctx, deployment.Id,
+				model.DeviceDeploymentStatusInstalling,
+				model.DeviceDeploymentStatusFailure,
+			).Return(model.Stats{model.DeviceDeploymentStatusFailureStr: 1}, nil).Once()
+			if tc.callRetry {
+				db.On("RetryDeviceDeployment", ctx, devId, deployment.Id, tc.retries).
+					Return(tc.retried, tc.retryErr).Once()
+			}
+			if tc.retried {
+				db.On("UpdateStatsInc", ctx, deployment.Id,
+					model.DeviceDeploymentStatusFailure,
+					model.DeviceDeploymentStatusPending,
+				).Return(model.Stats{model.DeviceDeploymentStatusPendingStr: 1}, nil).Once()
+			}
+			if tc.deploymentStatus == model.DeploymentStatusFinished {
+				// finishing recalculates the statistics
+				stats := model.Stats{model.DeviceDeploymentStatusFailureStr: 1}
+				db.On("AggregateDeviceDeploymentByStatus", ctx, deployment.Id).
+					Return(stats, nil).Once()
+				db.On("UpdateStats", ctx, deployment.Id, stats).
+					Return(nil).Once()
+			}
+			if tc.err == nil {
+				db.On("SetDeploymentStatus", ctx, deployment.Id,
+					tc.deploymentStatus, mock.AnythingOfType("time.Time"),
+				).Return(true, nil).Once()
+			}
+			if tc.err == nil && !tc.retried {
+				db.On("SaveLastDeviceDeploymentStatus", ctx,
+					mock.AnythingOfType("model.DeviceDeployment"),
+				).Return(nil).Once()
+			}
+
+			ds := NewDeployments(db, nil, 0, false)
+			err = ds.updateDeviceDeploymentStatus(ctx, dd, model.DeviceDeploymentState{
+				Status: model.DeviceDeploymentStatusFailure,
+			})
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUpdateDeviceDeploymentStatusConfirmation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		devId   = "somedevice"
+		timeout = time.Hour
+	)
+
+	testCases := map[string]struct {
+		timeout time.Duration
+		current model.DeviceDeploymentStatus
+		report  model.DeviceDeploymentStatus
+
+		status   model.DeviceDeploymentStatus
+		finished bool
+	}{
+		"ok, awaiting confirmation after reboot": {
+			timeout: timeout,
+			current: model.DeviceDeploymentStatusRebooting,
+			report:  model.DeviceDeploymentStatusSuccess,
+			status:  model.DeviceDeploymentStatusAwaitingConfirmation,
+		},
+		"ok, confirmed": {
+			timeout:  timeout,
+			current:  model.DeviceDeploymentStatusAwaitingConfirmation,
+			report:   model.DeviceDeploymentStatusSuccess,
+			status:   model.DeviceDeploymentStatusSuccess,
+			finished: true,
+		},
+		"ok, failed while awaiting confirmation": {
+			timeout:  timeout,
+			current:  model.DeviceDeploymentStatusAwaitingConfirmation,
+			report:   model.DeviceDeploymentStatusFailure,
+			status:   model.DeviceDeploymentStatusFailure,
+			finished: true,
+		},
+		"ok, success without reboot": {
+			timeout:  timeout,
+			current:  model.DeviceDeploymentStatusInstalling,
+			report:   model.DeviceDeploymentStatusSuccess,
+			status:   model.DeviceDeploymentStatusSuccess,
+			finished: true,
+		},
+		"ok, confirmation disabled": {
+			current:  model.DeviceDeploymentStatusRebooting,
+			report:   model.DeviceDeploymentStatusSuccess,
+			status:   model.DeviceDeploymentStatusSuccess,
+			finished: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			deployment, err := model.NewDeploymentFromConstructor(
+				&model.DeploymentConstructor{
+					Name:         "foo",
+					ArtifactName: "bar",
+					Devices:      []string{devId},
+				},
+			)
+			assert.NoError(t, err)
+			deployment.MaxDevices = 1
+			deployment.Stats.Set(tc.current, 1)
+
+			dd := model.NewDeviceDeployment(devId, deployment.Id)
+			dd.Status = tc.current
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+
+			db.On("UpdateDeviceDeploymentStatus", ctx, devId, deployment.Id,
+				mock.MatchedBy(func(state model.DeviceDeploymentState) bool {
+					if tc.status != model.DeviceDeploymentStatusAwaitingConfirmation {
+						return assert.Equal(t, tc.status, state.Status) &&
+							assert.Nil(t, state.ConfirmationDeadline)
+					}
+					return assert.Equal(t, tc.status, state.Status) &&
+						assert.NotNil(t, state.ConfirmationDeadline) &&
+						assert.WithinDuration(t, time.Now().Add(tc.timeout),
+							*state.ConfirmationDeadline, time.Minute)
+				}),
+				tc.current,
+			).Return(tc.current, nil).Once()
+			db.On("FindDeploymentByID", ctx, deployment.Id, true).
+				Return(deployment, nil).Once()
+			stats := model.NewDeviceDeploymentStats()
+			stats.Set(tc.status, 1)
+			db.On("UpdateStatsInc", ctx, deployment.Id, tc.current, tc.status).
+				Return(stats, nil).Once()
+			if tc.finished {
+				db.On("AggregateDeviceDeploymentByStatus", ctx, deployment.Id).
+					Return(stats, nil).Once()
+				db.On("UpdateStats", ctx, deployment.Id, stats).
+					Return(nil).Once()
+				db.On("SetDeploymentStatus", ctx, deployment.Id,
+					model.DeploymentStatusFinished, mock.AnythingOfType("time.Time"),
+				).Return(true, nil).Once()
+				db.On("SaveLastDeviceDeploymentStatus", ctx,
+					mock.AnythingOfType("model.DeviceDeployment"),
+				).Return(nil).Once()
+			}
+
+			ds := NewDeployments(db, nil, 0, false).
+				WithDeviceDeploymentConfirmation(tc.timeout)
+			err = ds.updateDeviceDeploymentStatus(ctx, dd, model.DeviceDeploymentState{
+				Status: tc.report,
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGetDeploymentForDeviceAwaitingConfirmation(t *testing.T) {
+	t.Parallel()
+
+	const devId = "somedevice"
+
+	testCases := map[string]struct {
+		installed string
+
+		status   model.DeviceDeploymentStatus
+		subState string
+	}{
+		"ok, running the installed artifact confirms the update": {
+			installed: "bar",
+			status:    model.DeviceDeploymentStatusSuccess,
+		},
+		"ok, running another artifact fails the update": {
+			installed: "baz",
+			status:    model.DeviceDeploymentStatusFailure,
+			subState:  rolledBackSubState,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			current := model.DeviceDeploymentStatusAwaitingConfirmation
+
+			deployment, err := model.NewDeploymentFromConstructor(
+				&model.DeploymentConstructor{
+					Name:         "foo",
+					ArtifactName: "bar",
+					Devices:      []string{devId},
+				},
+			)
+			assert.NoError(t, err)
+			deployment.MaxDevices = 1
+			deployment.Stats.Set(current, 1)
+
+			dd := model.NewDeviceDeployment(devId, deployment.Id)
+			dd.Status = current
+			dd.Image = &model.Image{
+				Id:           "image",
+				ArtifactMeta: &model.ArtifactMeta{Name: "bar"},
+			}
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+
+			db.On("FindAndClaimOldestActiveDeviceDeployment", ctx, devId).
+				Return(dd, current, nil).Once()
+			db.On("FindDeploymentByID", ctx, deployment.Id, false).
+				Return(deployment, nil).Once()
+			db.On("UpdateDeviceDeploymentStatus", ctx, devId, deployment.Id,
+				mock.MatchedBy(func(state model.DeviceDeploymentState) bool {
+					return assert.Equal(t, tc.status, state.Status) &&
+						assert.Equal(t, tc.subState, state.SubState)
+				}),
+				current,
+			).Return(current, nil).Once()
+			db.On("FindDeploymentByID", ctx, deployment.Id, true).
+				Return(deployment, nil).Once()
+			stats := model.NewDeviceDeploymentStats()
+			stats.Set(tc.status, 1)
+			db.On("UpdateStatsInc", ctx, deployment.Id, current, tc.status).
+				Return(stats, nil).Once()
+			db.On("AggregateDeviceDeploymentByStatus", ctx, deployment.Id).
+				Return(stats, nil).Once()
+			db.On("UpdateStats", ctx, deployment.Id, stats).
+				Return(nil).Once()
+			db.On("SetDeploymentStatus", ctx, deployment.Id,
+				model.DeploymentStatusFinished, mock.AnythingOfType("time.Time"),
+			).Return(true, nil).Once()
+			db.On("SaveLastDeviceDeploymentStatus", ctx,
+				mock.AnythingOfType("model.DeviceDeployment"),
+			).Return(nil).Once()
+
+			// the device is not given the same deployment again
+			ds := NewDeployments(db, nil, 0, false).
+				WithDeviceDeploymentConfirmation(time.Hour)
+			instructions, err := ds.GetDeploymentForDeviceWithCurrent(ctx, devId,
+				&model.DeploymentNextRequest{
+					DeviceProvides: &model.InstalledDeviceDeployment{
+						ArtifactName: tc.installed,
+						DeviceType:   "foo",
+					},
+				})
+			assert.NoError(t, err)
+			assert.Nil(t, instructions)
+		})
+	}
+}
+
+func TestUpdateDeviceDeploymentStatusRepeated(t *testing.T) {
+	t.Parallel()
+
+	const (
+		devId    = "somedevice"
+		interval = time.Minute
+	)
+	recently := time.Now().Add(-10 * time.Second)
+	longAgo := time.Now().Add(-2 * interval)
+
+	testCases := map[string]struct {
+		lastUpdated *time.Time
+		subState    string
+		report      model.DeviceDeploymentState
+
+		touch          bool
+		updateSubState bool
+	}{
+		"ok, updated recently": {
+			lastUpdated: &recently,
+			report: model.DeviceDeploymentState{
+				Status: model.DeviceDeploymentStatusDownloading,
+			},
+		},
+		"ok, same substate": {
+			lastUpdated: &recently,
+			subState:    "fetching",
+			report: model.DeviceDeploymentState{
+				Status:   model.DeviceDeploymentStatusDownloading,
+				SubState: "fetching",
+			},
+		},
+		"ok, updated long ago": {
+			lastUpdated: &longAgo,
+			report: model.DeviceDeploymentState{
+				Status: model.DeviceDeploymentStatusDownloading,
+			},
+			touch: true,
+		},
+		"ok, never updated": {
+			report: model.DeviceDeploymentState{
+				Status: model.DeviceDeploymentStatusDownloading,
+			},
+			touch: true,
+		},
+		"ok, new substate": {
+			lastUpdated: &recently,
+			subState:    "fetching",
+			report: model.DeviceDeploymentState{
+				Status:   model.DeviceDeploymentStatusDownloading,
+				SubState: "50%",
+			},
+			updateSubState: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			dd := model.NewDeviceDeployment(devId, "deployment")
+			dd.Status = model.DeviceDeploymentStatusDownloading
+			dd.SubState = tc.subState
+			dd.LastUpdated = tc.lastUpdated
+
+			// the stats are never looked at: any call to FindDeploymentByID
+			// or UpdateStatsInc fails the test
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.touch {
+				db.On("TouchDeviceDeployment", ctx, devId, dd.DeploymentId,
+					model.DeviceDeploymentStatusDownloading,
+				).Return(nil).Times(3)
+			}
+			if tc.updateSubState {
+				db.On("UpdateDeviceDeploymentStatus", ctx, devId, dd.DeploymentId,
+					tc.report, model.DeviceDeploymentStatusDownloading,
+				).Return(model.DeviceDeploymentStatusDownloading, nil).Times(3)
+			}
+
+			ds := NewDeployments(db, nil, 0, false).
+				WithDeviceDeploymentStatusDedup(interval)
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, ds.updateDeviceDeploymentStatus(ctx, dd, tc.report))
+			}
+		})
+	}
 }
 
 func TestGetDeploymentForDeviceWithCurrent(t *testing.T) {
@@ -140,17 +578,28 @@ func TestGetDeploymentForDeviceWithCurrent(t *testing.T) {
 		Size: 5,
 	}
 
+	// claimed from pending
 	fakeDeviceDeployment := model.NewDeviceDeployment(
 		devId, fakeDeployment.Id)
-	fakeDeviceDeployment.Status = model.DeviceDeploymentStatusPending
+	fakeDeviceDeployment.Status = model.DeviceDeploymentStatusDownloading
 
 	fs := &fs_mocks.ObjectStorage{}
 	db := mocks.DataStore{}
 
-	db.On("FindOldestActiveDeviceDeployment", ctx, devId).Return(
-		fakeDeviceDeployment, nil)
+	db.On("FindAndClaimOldestActiveDeviceDeployment", ctx, devId).Return(
+		fakeDeviceDeployment, model.DeviceDeploymentStatusPending, nil)
+	db.On("UpdateStatsInc", ctx,
+		fakeDeployment.Id,
+		model.DeviceDeploymentStatusPending,
+		model.DeviceDeploymentStatusDownloading).Run(func(args mock.Arguments) {
+		fakeDeployment.Stats.Inc(model.DeviceDeploymentStatusDownloading)
+	}).Return(fakeDeployment.Stats, nil).Once()
+	db.On("SetDeploymentStatus", ctx,
+		fakeDeployment.Id,
+		model.DeploymentStatusInProgress,
+		mock.AnythingOfType("time.Time")).Return(true, nil).Once()
 
-	db.On("FindDeploymentByID", ctx, fakeDeployment.Id).Return(
+	db.On("FindDeploymentByID", ctx, fakeDeployment.Id, false).Return(
 		fakeDeployment, nil).Once()
 
 	db.On("DeviceCountByDeployment", ctx, fakeDeployment.Id).Return(2, nil)
@@ -171,25 +620,26 @@ func TestGetDeploymentForDeviceWithCurrent(t *testing.T) {
 			return true
 		}),
 		mock.AnythingOfType("model.DeviceDeploymentStatus"),
-	).Return(model.DeviceDeploymentStatusPending, nil)
+	).Return(model.DeviceDeploymentStatusDownloading, nil)
 
 	db.On("UpdateStatsInc", ctx,
 		fakeDeployment.Id,
-		model.DeviceDeploymentStatusPending,
+		model.DeviceDeploymentStatusDownloading,
 		model.DeviceDeploymentStatusAlreadyInst).Run(func(args mock.Arguments) {
 		// fake updated stats
+		fakeDeployment.Stats.Set(model.DeviceDeploymentStatusDownloading, 0)
 		fakeDeployment.Stats.Inc(model.DeviceDeploymentStatusAlreadyInst)
 	}).Return(fakeDeployment.Stats, nil).Once()
 
 	// fake updated stats
 	fakeDeployment.Stats.Set(model.DeviceDeploymentStatusNoArtifact, 1)
-	db.On("FindDeploymentByID", ctx, fakeDeployment.Id).Return(
+	db.On("FindDeploymentByID", ctx, fakeDeployment.Id, true).Return(
 		fakeDeployment, nil)
 
 	db.On("SetDeploymentStatus", ctx,
 		fakeDeployment.Id,
 		model.DeploymentStatusFinished,
-		mock.AnythingOfType("time.Time")).Return(nil)
+		mock.AnythingOfType("time.Time")).Return(true, nil)
 
 	db.On("SaveDeviceDeploymentRequest", ctx,
 		mock.AnythingOfType("string"),
@@ -247,7 +697,13 @@ func TestDecommissionDevice(t *testing.T) {
 		insertDeviceDeploymentError                           error
 		updateStatsIncError                                   error
 		setDeploymentStatusError                              error
+		decommissionDeviceDeployments                         []model.DeviceDeployment
+		decommissionDeviceDeploymentsError                    error
+		decommissionStats                                     model.Stats
+		findActiveDeploymentsForDevice                        []*model.Deployment
+		aggregateStats                                        model.Stats
 
+		finished    bool
 		outputError error
 	}{
 		"ok": {
@@ -311,6 +767,88 @@ func TestDecommissionDevice(t *testing.T) {
 				Stats:       model.Stats{},
 			},
 		},
+		"ok, only device decommissioned": {
+			inputDeviceId:     "foo",
+			inputDeploymentId: "bar",
+
+			decommissionDeviceDeployments: []model.DeviceDeployment{{
+				DeploymentId: "bar",
+				Status:       model.DeviceDeploymentStatusPending,
+			}},
+			findDeploymentByIDDeployment: &model.Deployment{
+				Id:         "bar",
+				MaxDevices: 1,
+				Stats:      model.Stats{"pending": 1},
+			},
+			decommissionStats: model.Stats{"decommissioned": 1},
+			aggregateStats:    model.Stats{"decommissioned": 1},
+
+			finished: true,
+		},
+		"ok, other devices active": {
+			inputDeviceId:     "foo",
+			inputDeploymentId: "bar",
+
+			decommissionDeviceDeployments: []model.DeviceDeployment{{
+				DeploymentId: "bar",
+				Status:       model.DeviceDeploymentStatusPending,
+			}},
+			findDeploymentByIDDeployment: &model.Deployment{
+				Id:         "bar",
+				MaxDevices: 2,
+				Stats:      model.Stats{"pending": 2},
+			},
+			decommissionStats: model.Stats{"decommissioned": 1, "pending": 1},
+		},
+		"ok, newer deployment not picked up": {
+			inputDeviceId:     "foo",
+			inputDeploymentId: "bar",
+
+			findActiveDeploymentsForDevice: []*model.Deployment{{
+				DeviceList:  []string{"foo"},
+				Id:          "bar",
+				Created:     timePtr(time.Now()),
+				DeviceCount: intPtr(0),
+				MaxDevices:  1,
+				Stats:       model.Stats{},
+			}},
+			findDeploymentByIDDeployment: &model.Deployment{
+				Id:    "bar",
+				Stats: model.Stats{},
+			},
+			aggregateStats: model.Stats{"decommissioned": 1},
+
+			finished: true,
+		},
+		"error, other deployments still updated": {
+			inputDeviceId:     "foo",
+			inputDeploymentId: "bar",
+
+			decommissionDeviceDeployments: []model.DeviceDeployment{{
+				DeploymentId: "failing",
+				Status:       model.DeviceDeploymentStatusPending,
+			}, {
+				DeploymentId: "bar",
+				Status:       model.DeviceDeploymentStatusDownloading,
+			}},
+			findDeploymentByIDDeployment: &model.Deployment{
+				Id:         "bar",
+				MaxDevices: 1,
+				Stats:      model.Stats{"downloading": 1},
+			},
+			decommissionStats: model.Stats{"decommissioned": 1},
+			aggregateStats:    model.Stats{"decommissioned": 1},
+
+			finished:    true,
+			outputError: errors.New("failed when searching for deployment: foo"),
+		},
+		"DecommissionDeviceDeployments error": {
+			inputDeviceId: "foo",
+
+			decommissionDeviceDeploymentsError: errors.New("foo"),
+
+			outputError: errors.New("failed to decommission the device deployments: foo"),
+		},
 		"FindOldestActiveDeviceDeployment error": {
 			inputDeviceId:       "foo",
 			inputDeploymentId:   "bar",
@@ -360,9 +898,19 @@ func TestDecommissionDevice(t *testing.T) {
 			db.On("InsertDeviceDeployment", ctx, mock.AnythingOfType("*model.DeviceDeployment"), true).Return(
 				tc.insertDeviceDeploymentError)
 
-			db.On("FindDeploymentByID", ctx, tc.inputDeploymentId).Return(
+			db.On("FindDeploymentByID", ctx, tc.inputDeploymentId, true).Return(
 				tc.findDeploymentByIDDeployment, tc.findDeploymentByIDError)
 
+			db.On("FindDeploymentByID", ctx, "failing", true).Return(
+				nil, errors.New("foo"))
+
+			for _, dd := range tc.decommissionDeviceDeployments {
+				db.On("UpdateStatsInc", ctx, dd.DeploymentId, dd.Status,
+					model.DeviceDeploymentStatusDecommissioned).
+					Return(tc.decommissionStats, nil).
+					Once()
+			}
+
 			var stats model.Stats
 			if tc.findDeploymentByIDDeployment != nil {
 				stats = tc.findDeploymentByIDDeployment.Stats
@@ -382,20 +930,34 @@ func TestDecommissionDevice(t *testing.T) {
 				tc.inputDeploymentId,
 				model.DeploymentStatusFinished,
 				mock.AnythingOfType("time.Time")).
-				Return(tc.setDeploymentStatusError).
+				Return(true, tc.setDeploymentStatusError).
 				Once()
 
 			db.On("SetDeploymentStatus", ctx,
 				"pending",
 				model.DeploymentStatusPending,
 				mock.AnythingOfType("time.Time")).
-				Return(tc.setDeploymentStatusError).
+				Return(true, tc.setDeploymentStatusError).
 				Once()
 
 			db.On("SaveLastDeviceDeploymentStatus", ctx,
 				mock.AnythingOfType("model.DeviceDeployment"),
 			).Return(nil)
 
+			db.On("DecommissionDeviceDeployments", ctx, tc.inputDeviceId).
+				Return(tc.decommissionDeviceDeployments,
+					tc.decommissionDeviceDeploymentsError)
+
+			db.On("FindActiveDeploymentsForDevice", ctx, tc.inputDeviceId,
+				mock.AnythingOfType("*time.Time")).
+				Return(tc.findActiveDeploymentsForDevice, nil)
+
+			db.On("AggregateDeviceDeploymentByStatus", ctx, tc.inputDeploymentId).
+				Return(tc.aggregateStats, nil)
+
+			db.On("UpdateStats", ctx, tc.inputDeploymentId, tc.aggregateStats).
+				Return(nil)
+
 			ds := NewDeployments(&db, nil, 0, false)
 
 			err := ds.DecommissionDevice(ctx, tc.inputDeviceId)
@@ -404,6 +966,19 @@ func TestDecommissionDevice(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+			if tc.finished {
+				db.AssertCalled(t, "SetDeploymentStatus", ctx,
+					tc.inputDeploymentId,
+					model.DeploymentStatusFinished,
+					mock.AnythingOfType("time.Time"))
+			} else if tc.decommissionDeviceDeployments != nil {
+				db.AssertNotCalled(t, "UpdateStats", ctx,
+					tc.inputDeploymentId, mock.Anything)
+				db.AssertNotCalled(t, "SetDeploymentStatus", ctx,
+					tc.inputDeploymentId,
+					model.DeploymentStatusFinished,
+					mock.AnythingOfType("time.Time"))
+			}
 		})
 	}
 }
@@ -563,7 +1138,7 @@ func TestAbortDeviceDeployments(t *testing.T) {
 			db.On("InsertDeviceDeployment", ctx, mock.AnythingOfType("*model.DeviceDeployment"), true).Return(
 				tc.insertDeviceDeploymentError)
 
-			db.On("FindDeploymentByID", ctx, tc.inputDeploymentId).Return(
+			db.On("FindDeploymentByID", ctx, tc.inputDeploymentId, true).Return(
 				tc.findDeploymentByIDDeployment, tc.findDeploymentByIDError)
 
 			var stats model.Stats
@@ -591,14 +1166,14 @@ func TestAbortDeviceDeployments(t *testing.T) {
 				tc.inputDeploymentId,
 				status,
 				mock.AnythingOfType("time.Time")).
-				Return(tc.setDeploymentStatusError).
+				Return(true, tc.setDeploymentStatusError).
 				Once()
 
 			db.On("SetDeploymentStatus", ctx,
 				"pending",
 				model.DeploymentStatusPending,
 				mock.AnythingOfType("time.Time")).
-				Return(tc.setDeploymentStatusError).
+				Return(true, tc.setDeploymentStatusError).
 				Once()
 
 			db.On("SaveLastDeviceDeploymentStatus", ctx,
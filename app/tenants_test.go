@@ -18,12 +18,14 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	fs_mocks "github.com/mendersoftware/deployments/storage/mocks"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/mendersoftware/deployments/model"
 	mstore "github.com/mendersoftware/deployments/store/mocks"
 )
 
@@ -72,3 +74,105 @@ func TestProvisionTenant(t *testing.T) {
 		})
 	}
 }
+
+func TestCountActiveDeploymentsPerTenant(t *testing.T) {
+	t.Parallel()
+
+	counts := func() []model.TenantActiveDeployments {
+		return []model.TenantActiveDeployments{
+			{TenantID: "tenant1", ActiveCount: 2},
+			{TenantID: "tenant2", ActiveCount: 0},
+			{TenantID: "tenant3", ActiveCount: 1},
+		}
+	}
+	testCases := map[string]struct {
+		query model.TenantActiveDeploymentsQuery
+
+		limit    int
+		period   time.Duration
+		storeErr error
+
+		page *model.TenantActiveDeploymentsPage
+		err  string
+	}{
+		"ok": {
+			query: model.TenantActiveDeploymentsQuery{
+				After:     "tenant0",
+				Limit:     5,
+				RateLimit: 4,
+			},
+			limit:  5,
+			period: 250 * time.Millisecond,
+			page:   &model.TenantActiveDeploymentsPage{Tenants: counts()},
+		},
+		"ok, defaults": {
+			limit:  DefaultActiveDeploymentsCountLimit,
+			period: 100 * time.Millisecond,
+			page:   &model.TenantActiveDeploymentsPage{Tenants: counts()},
+		},
+		"ok, full page": {
+			query: model.TenantActiveDeploymentsQuery{
+				Limit: 3,
+			},
+			limit:  3,
+			period: 100 * time.Millisecond,
+			page: &model.TenantActiveDeploymentsPage{
+				Tenants: counts(),
+				Next:    "tenant3",
+			},
+		},
+		"ok, skip empty": {
+			query: model.TenantActiveDeploymentsQuery{
+				Limit:     3,
+				RateLimit: 10,
+				SkipEmpty: true,
+			},
+			limit:  3,
+			period: 100 * time.Millisecond,
+			page: &model.TenantActiveDeploymentsPage{
+				Tenants: []model.TenantActiveDeployments{
+					{TenantID: "tenant1", ActiveCount: 2},
+					{TenantID: "tenant3", ActiveCount: 1},
+				},
+				Next: "tenant3",
+			},
+		},
+		"error": {
+			query: model.TenantActiveDeploymentsQuery{
+				RateLimit: 10,
+			},
+			limit:    DefaultActiveDeploymentsCountLimit,
+			period:   100 * time.Millisecond,
+			storeErr: errors.New("connection failed"),
+			err:      "failed to count the active deployments: connection failed",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			db := &mstore.DataStore{}
+			defer db.AssertExpectations(t)
+			if tc.storeErr != nil {
+				db.On("CountActiveDeploymentsPerTenant",
+					ctx, tc.query.After, tc.limit, tc.period).
+					Return(nil, tc.storeErr)
+			} else {
+				db.On("CountActiveDeploymentsPerTenant",
+					ctx, tc.query.After, tc.limit, tc.period).
+					Return(counts(), nil)
+			}
+
+			d := NewDeployments(db, nil, 0, false)
+			res, err := d.CountActiveDeploymentsPerTenant(ctx, tc.query)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.page, res)
+			}
+		})
+	}
+}
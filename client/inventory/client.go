@@ -15,6 +15,7 @@
 package inventory
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -29,6 +30,7 @@ import (
 
 	dconfig "github.com/mendersoftware/deployments/config"
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 )
 
 const (
@@ -76,12 +78,19 @@ func NewClient() Client {
 	return &client{
 		baseURL:    baseURL,
 		httpClient: &http.Client{Timeout: timeout},
+		retryPolicy: utils.RetryPolicy{
+			MaxAttempts: config.Config.GetInt(dconfig.SettingInventoryRetryMaxAttempts),
+			BaseDelay: time.Duration(
+				config.Config.GetInt(dconfig.SettingInventoryRetryBaseDelay),
+			) * time.Millisecond,
+		},
 	}
 }
 
 type client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy utils.RetryPolicy
 }
 
 func (c *client) CheckHealth(ctx context.Context) error {
@@ -131,13 +140,14 @@ func (c *client) Search(
 	url := c.baseURL + repl.Replace(searchURL)
 
 	payload, _ := json.Marshal(searchParams)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(payload)))
-	if err != nil {
-		return nil, -1, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	rsp, err := c.httpClient.Do(req)
+	rsp, err := c.retryPolicy.DoHTTP(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return nil, -1, errors.Wrap(err, "search devices request failed")
 	}
@@ -176,14 +186,9 @@ func (c *client) GetDeviceGroups(ctx context.Context, tenantId, deviceId string)
 		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
 		defer cancel()
 	}
-	req, err := http.NewRequestWithContext(
-		ctx, "GET", url, nil,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	rsp, err := c.httpClient.Do(req)
+	rsp, err := c.retryPolicy.DoHTTP(ctx, c.httpClient, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", url, nil)
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "get device groups request failed")
 	}
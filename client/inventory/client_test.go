@@ -29,6 +29,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
 )
 
@@ -198,3 +199,84 @@ func TestGetDeviceGroups(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDeviceGroupsRetry(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		maxAttempts int
+		statuses    []int
+
+		attempts       int
+		expectedGroups []string
+		outError       error
+	}{
+		"ok, after transient errors": {
+			maxAttempts: 3,
+			statuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusBadGateway,
+				http.StatusOK,
+			},
+			attempts:       3,
+			expectedGroups: []string{"foo"},
+		},
+		"ko, attempts exhausted": {
+			maxAttempts: 2,
+			statuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusServiceUnavailable,
+				http.StatusOK,
+			},
+			attempts: 2,
+			outError: errors.New(
+				"get device groups request failed with unexpected status: 503"),
+		},
+		"ko, no retry on client error": {
+			maxAttempts: 3,
+			statuses:    []int{http.StatusBadRequest, http.StatusOK},
+			attempts:    1,
+			outError: errors.New(
+				"get device groups request failed with unexpected status: 400"),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					status := tc.statuses[attempts]
+					attempts++
+					w.WriteHeader(status)
+					if status == http.StatusOK {
+						_ = json.NewEncoder(w).Encode(model.DeviceGroups{
+							Groups: []string{"foo"},
+						})
+					}
+				},
+			))
+			defer srv.Close()
+			client := &client{
+				baseURL:    srv.URL,
+				httpClient: &http.Client{},
+				retryPolicy: utils.RetryPolicy{
+					MaxAttempts: tc.maxAttempts,
+					BaseDelay:   time.Millisecond,
+				},
+			}
+
+			groups, err := client.GetDeviceGroups(context.Background(), "foo", "bar")
+			if tc.outError != nil {
+				assert.EqualError(t, err, tc.outError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedGroups, groups)
+			}
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package reporting
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -27,6 +28,7 @@ import (
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 )
 
 const (
@@ -52,15 +54,18 @@ type Client interface {
 }
 
 type client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy utils.RetryPolicy
 }
 
-// NewClient returns a new reporting client
-func NewClient(baseURL string) Client {
+// NewClient returns a new reporting client; search requests failing with
+// transient errors are repeated according to retryPolicy.
+func NewClient(baseURL string, retryPolicy utils.RetryPolicy) Client {
 	return &client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: defaultTimeout},
+		baseURL:     baseURL,
+		httpClient:  &http.Client{Timeout: defaultTimeout},
+		retryPolicy: retryPolicy,
 	}
 }
 
@@ -107,13 +112,14 @@ func (c *client) Search(
 	url := c.baseURL + repl.Replace(uriInternalSearch)
 
 	payload, _ := json.Marshal(searchParams)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(payload)))
-	if err != nil {
-		return nil, -1, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	rsp, err := c.httpClient.Do(req)
+	rsp, err := c.retryPolicy.DoHTTP(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return nil, -1, errors.Wrap(err, "search devices request failed")
 	}
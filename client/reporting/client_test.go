@@ -29,6 +29,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
 )
 
@@ -90,7 +91,7 @@ func TestCheckHealth(t *testing.T) {
 		}
 	}
 	srv := httptest.NewServer(http.HandlerFunc(serveHTTP))
-	client := NewClient("").(*client)
+	client := NewClient("", utils.RetryPolicy{}).(*client)
 	client.baseURL = srv.URL
 	defer srv.Close()
 
@@ -188,7 +189,7 @@ func TestSearch(t *testing.T) {
 				}
 			}
 			srv := httptest.NewServer(http.HandlerFunc(serveHTTP))
-			client := NewClient("").(*client)
+			client := NewClient("", utils.RetryPolicy{}).(*client)
 			client.baseURL = srv.URL
 			defer srv.Close()
 
@@ -214,3 +215,83 @@ func TestSearch(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchRetry(t *testing.T) {
+	t.Parallel()
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := map[string]struct {
+		ctx         context.Context
+		maxAttempts int
+		statuses    []int
+
+		attempts int
+		outError error
+	}{
+		"ok, after transient errors": {
+			ctx:         context.Background(),
+			maxAttempts: 3,
+			statuses: []int{
+				http.StatusTooManyRequests,
+				http.StatusGatewayTimeout,
+				http.StatusOK,
+			},
+			attempts: 3,
+		},
+		"ko, attempts exhausted": {
+			ctx:         context.Background(),
+			maxAttempts: 2,
+			statuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusServiceUnavailable,
+				http.StatusOK,
+			},
+			attempts: 2,
+			outError: errors.New(
+				"search devices request failed with unexpected status: 503"),
+		},
+		"ko, context canceled": {
+			ctx:         canceledCtx,
+			maxAttempts: 3,
+			statuses:    []int{http.StatusOK},
+			outError:    context.Canceled,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					status := tc.statuses[attempts]
+					attempts++
+					w.Header().Add(hdrTotalCount, "0")
+					w.WriteHeader(status)
+					if status == http.StatusOK {
+						_ = json.NewEncoder(w).Encode([]model.InvDevice{})
+					}
+				},
+			))
+			defer srv.Close()
+			client := NewClient(srv.URL, utils.RetryPolicy{
+				MaxAttempts: tc.maxAttempts,
+				BaseDelay:   time.Millisecond,
+			})
+
+			devices, count, err := client.Search(tc.ctx, "foo", model.SearchParams{})
+			if tc.outError != nil {
+				assert.ErrorContains(t, err, tc.outError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, []model.InvDevice{}, devices)
+				assert.Equal(t, 0, count)
+			}
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
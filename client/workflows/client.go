@@ -32,6 +32,7 @@ import (
 
 	dconfig "github.com/mendersoftware/deployments/config"
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 )
 
 const (
@@ -40,6 +41,8 @@ const (
 	reindexReportingURL                = "/api/v1/workflow/reindex_reporting"
 	reindexReportingDeploymentURL      = "/api/v1/workflow/reindex_reporting_deployment"
 	reindexReportingDeploymentBatchURL = "/api/v1/workflow/reindex_reporting_deployment/batch"
+	exportDeploymentsURL               = "/api/v1/workflow/export_deployments"
+	workflowURL                        = "/api/v1/workflow/"
 	defaultTimeout                     = 5 * time.Second
 )
 
@@ -59,8 +62,12 @@ type Client interface {
 		multipartGenerateImageMsg *model.MultipartGenerateImageMsg,
 	) error
 	StartReindexReporting(c context.Context, device string) error
-	StartReindexReportingDeployment(c context.Context, device, deployment, id string) error
+	StartReindexReportingDeployment(c context.Context, device, deployment, id string,
+		attributes []model.DeviceAttribute) error
 	StartReindexReportingDeploymentBatch(c context.Context, info []DeviceDeploymentShortInfo) error
+	StartExportDeployments(ctx context.Context, jobID string) error
+	StartDeploymentFinished(ctx context.Context,
+		workflow, deploymentID string, stats model.Stats) error
 }
 
 // NewClient returns a new workflows client
@@ -69,12 +76,34 @@ func NewClient() Client {
 	return &client{
 		baseURL:    workflowsBaseURL,
 		httpClient: &http.Client{Timeout: defaultTimeout},
+		retryPolicy: utils.RetryPolicy{
+			MaxAttempts: config.Config.GetInt(dconfig.SettingWorkflowsRetryMaxAttempts),
+			BaseDelay: time.Duration(
+				config.Config.GetInt(dconfig.SettingWorkflowsRetryBaseDelay),
+			) * time.Millisecond,
+			Jitter:            true,
+			RetryServerErrors: true,
+		},
 	}
 }
 
 type client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy utils.RetryPolicy
+}
+
+// post sends the JSON payload to the workflows service, repeating the
+// request on network errors and 5xx responses.
+func (c *client) post(ctx context.Context, url string, payload []byte) (*http.Response, error) {
+	return c.retryPolicy.DoHTTP(ctx, c.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, errors.Wrap(err, "workflows: error preparing HTTP request")
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 }
 
 func (c *client) CheckHealth(ctx context.Context) error {
@@ -122,12 +151,7 @@ func (c *client) StartGenerateArtifact(
 	workflowsURL := c.baseURL + generateArtifactURL
 
 	payload, _ := json.Marshal(multipartGenerateImageMsg)
-	req, err := http.NewRequest("POST", workflowsURL, strings.NewReader(string(payload)))
-	if err != nil {
-		return err
-	}
-
-	res, err := c.httpClient.Do(req)
+	res, err := c.post(ctx, workflowsURL, payload)
 	if err != nil {
 		return errors.Wrapf(err, "failed to start workflow: generate_artifact")
 	}
@@ -161,18 +185,7 @@ func (c *client) StartReindexReporting(ctx context.Context, device string) error
 		Service:   ServiceDeployments,
 	}
 	payload, _ := json.Marshal(wflow)
-	req, err := http.NewRequestWithContext(ctx,
-		"POST",
-		c.baseURL+reindexReportingURL,
-		bytes.NewReader(payload),
-	)
-	if err != nil {
-		return errors.Wrap(err, "workflows: error preparing HTTP request")
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	rsp, err := c.httpClient.Do(req)
+	rsp, err := c.post(ctx, c.baseURL+reindexReportingURL, payload)
 	if err != nil {
 		return errors.Wrap(err, "workflows: failed to trigger reporting reindex")
 	}
@@ -195,7 +208,7 @@ func (c *client) StartReindexReporting(ctx context.Context, device string) error
 }
 
 func (c *client) StartReindexReportingDeployment(ctx context.Context,
-	device, deployment, id string) error {
+	device, deployment, id string, attributes []model.DeviceAttribute) error {
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
@@ -206,26 +219,16 @@ func (c *client) StartReindexReportingDeployment(ctx context.Context,
 		tenantID = ident.Tenant
 	}
 	wflow := ReindexDeploymentWorkflow{
-		RequestID:    requestid.FromContext(ctx),
-		TenantID:     tenantID,
-		DeviceID:     device,
-		DeploymentID: deployment,
-		ID:           id,
-		Service:      ServiceDeployments,
+		RequestID:        requestid.FromContext(ctx),
+		TenantID:         tenantID,
+		DeviceID:         device,
+		DeploymentID:     deployment,
+		ID:               id,
+		Service:          ServiceDeployments,
+		DeviceAttributes: attributes,
 	}
 	payload, _ := json.Marshal(wflow)
-	req, err := http.NewRequestWithContext(ctx,
-		"POST",
-		c.baseURL+reindexReportingDeploymentURL,
-		bytes.NewReader(payload),
-	)
-	if err != nil {
-		return errors.Wrap(err, "workflows: error preparing HTTP request")
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	rsp, err := c.httpClient.Do(req)
+	rsp, err := c.post(ctx, c.baseURL+reindexReportingDeploymentURL, payload)
 	if err != nil {
 		return errors.Wrap(err, "workflows: failed to trigger reporting reindex deployment")
 	}
@@ -271,20 +274,49 @@ func (c *client) StartReindexReportingDeploymentBatch(ctx context.Context,
 		}
 	}
 	payload, _ := json.Marshal(wflows)
-	req, err := http.NewRequestWithContext(ctx,
-		"POST",
-		c.baseURL+reindexReportingDeploymentBatchURL,
-		bytes.NewReader(payload),
-	)
+	rsp, err := c.post(ctx, c.baseURL+reindexReportingDeploymentBatchURL, payload)
 	if err != nil {
-		return errors.Wrap(err, "workflows: error preparing HTTP request")
+		return errors.Wrap(err, "workflows: failed to trigger reporting reindex deployment")
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 300 {
+		return nil
+	}
+
+	if rsp.StatusCode == http.StatusNotFound {
+		workflowURIparts := strings.Split(reindexReportingDeploymentURL, "/")
+		workflowName := workflowURIparts[len(workflowURIparts)-1]
+		return errors.New(`workflows: workflow "` + workflowName + `" not defined`)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return errors.Errorf(
+		"workflows: unexpected HTTP status from workflows service: %s",
+		rsp.Status,
+	)
+}
 
-	rsp, err := c.httpClient.Do(req)
+// StartExportDeployments starts the workflow running the export job; the
+// workflow calls back the internal API to perform the export.
+func (c *client) StartExportDeployments(ctx context.Context, jobID string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	tenantID := ""
+	if ident := identity.FromContext(ctx); ident != nil {
+		tenantID = ident.Tenant
+	}
+	wflow := ExportDeploymentsWorkflow{
+		RequestID: requestid.FromContext(ctx),
+		TenantID:  tenantID,
+		JobID:     jobID,
+	}
+	payload, _ := json.Marshal(wflow)
+	rsp, err := c.post(ctx, c.baseURL+exportDeploymentsURL, payload)
 	if err != nil {
-		return errors.Wrap(err, "workflows: failed to trigger reporting reindex deployment")
+		return errors.Wrap(err, "workflows: failed to trigger deployments export")
 	}
 	defer rsp.Body.Close()
 
@@ -293,7 +325,7 @@ func (c *client) StartReindexReportingDeploymentBatch(ctx context.Context,
 	}
 
 	if rsp.StatusCode == http.StatusNotFound {
-		workflowURIparts := strings.Split(reindexReportingDeploymentURL, "/")
+		workflowURIparts := strings.Split(exportDeploymentsURL, "/")
 		workflowName := workflowURIparts[len(workflowURIparts)-1]
 		return errors.New(`workflows: workflow "` + workflowName + `" not defined`)
 	}
@@ -303,3 +335,47 @@ func (c *client) StartReindexReportingDeploymentBatch(ctx context.Context,
 		rsp.Status,
 	)
 }
+
+// StartDeploymentFinished starts the given workflow with the final status
+// counters of a finished deployment.
+func (c *client) StartDeploymentFinished(
+	ctx context.Context,
+	workflow, deploymentID string,
+	stats model.Stats,
+) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	tenantID := ""
+	if ident := identity.FromContext(ctx); ident != nil {
+		tenantID = ident.Tenant
+	}
+	wflow := DeploymentFinishedWorkflow{
+		RequestID:    requestid.FromContext(ctx),
+		TenantID:     tenantID,
+		DeploymentID: deploymentID,
+		Stats:        stats,
+		Service:      ServiceDeployments,
+	}
+	payload, _ := json.Marshal(wflow)
+	rsp, err := c.post(ctx, c.baseURL+workflowURL+workflow, payload)
+	if err != nil {
+		return errors.Wrap(err, "workflows: failed to trigger deployment finished workflow")
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 300 {
+		return nil
+	}
+
+	if rsp.StatusCode == http.StatusNotFound {
+		return errors.New(`workflows: workflow "` + workflow + `" not defined`)
+	}
+
+	return errors.Errorf(
+		"workflows: unexpected HTTP status from workflows service: %s",
+		rsp.Status,
+	)
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/utils"
 	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
@@ -304,7 +305,7 @@ func TestReindexReporting(t *testing.T) {
 }
 
 func mockServerReindexDeployment(t *testing.T, tenant, device, deployment, id, reqid string,
-	code int) (*httptest.Server, error) {
+	attributes []model.DeviceAttribute, code int) (*httptest.Server, error) {
 	h := func(w http.ResponseWriter, r *http.Request) {
 		if code != http.StatusOK {
 			w.WriteHeader(code)
@@ -324,6 +325,7 @@ func mockServerReindexDeployment(t *testing.T, tenant, device, deployment, id, r
 		assert.Equal(t, deployment, request.DeploymentID)
 		assert.Equal(t, id, request.ID)
 		assert.Equal(t, ServiceDeployments, request.Service)
+		assert.Equal(t, attributes, request.DeviceAttributes)
 
 		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
 		w.WriteHeader(http.StatusOK)
@@ -344,6 +346,7 @@ func TestReindexDeploymentWorkflow(t *testing.T) {
 		deployment string
 		id         string
 		reqid      string
+		attributes []model.DeviceAttribute
 
 		code int
 
@@ -359,6 +362,21 @@ func TestReindexDeploymentWorkflow(t *testing.T) {
 
 			code: http.StatusOK,
 		},
+		{
+			name:       "ok, with device attributes",
+			tenant:     "tenant1",
+			device:     "device2",
+			deployment: "deployment3",
+			id:         "id4",
+			reqid:      "reqid1",
+			attributes: []model.DeviceAttribute{{
+				Name:  "device_type",
+				Scope: "inventory",
+				Value: "raspberrypi4",
+			}},
+
+			code: http.StatusOK,
+		},
 		{
 			name:   "404",
 			tenant: "tenant2",
@@ -383,7 +401,7 @@ func TestReindexDeploymentWorkflow(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			srv, err := mockServerReindexDeployment(t, tc.tenant, tc.device, tc.deployment,
-				tc.id, tc.reqid, tc.code)
+				tc.id, tc.reqid, tc.attributes, tc.code)
 			assert.NoError(t, err)
 
 			defer srv.Close()
@@ -400,7 +418,8 @@ func TestReindexDeploymentWorkflow(t *testing.T) {
 			client := NewClient().(*client)
 			client.baseURL = srv.URL
 
-			err = client.StartReindexReportingDeployment(ctx, tc.device, tc.deployment, tc.id)
+			err = client.StartReindexReportingDeployment(ctx,
+				tc.device, tc.deployment, tc.id, tc.attributes)
 			if tc.err != nil {
 				assert.EqualError(t, err, tc.err.Error())
 			} else {
@@ -554,3 +573,209 @@ func TestReindexDeploymentBatchWorkflow(t *testing.T) {
 		})
 	}
 }
+
+func TestStartWorkflowRetries(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		statuses []int
+
+		attempts int
+		err      string
+	}{
+		"ok, after server errors": {
+			statuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusInternalServerError,
+				http.StatusCreated,
+			},
+			attempts: 3,
+		},
+		"error, attempts exhausted": {
+			statuses: []int{
+				http.StatusBadGateway,
+				http.StatusBadGateway,
+				http.StatusBadGateway,
+			},
+			attempts: 3,
+			err: "workflows: unexpected HTTP status from workflows service: " +
+				"502 Bad Gateway",
+		},
+		"error, client error": {
+			statuses: []int{http.StatusBadRequest, http.StatusCreated},
+			attempts: 1,
+			err: "workflows: unexpected HTTP status from workflows service: " +
+				"400 Bad Request",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					body, err := io.ReadAll(r.Body)
+					assert.NoError(t, err)
+					assert.Contains(t, string(body), `"job_id":"job"`)
+					w.WriteHeader(tc.statuses[attempts])
+					attempts++
+				},
+			))
+			defer srv.Close()
+
+			client := NewClient().(*client)
+			client.baseURL = srv.URL
+			client.retryPolicy = utils.RetryPolicy{
+				MaxAttempts:       3,
+				BaseDelay:         time.Millisecond,
+				Jitter:            true,
+				RetryServerErrors: true,
+			}
+
+			err := client.StartExportDeployments(context.Background(), "job")
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
+
+func TestStartExportDeployments(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+
+		code int
+
+		err error
+	}{
+		{
+			name: "ok",
+			code: http.StatusCreated,
+		},
+		{
+			name: "404",
+			code: http.StatusNotFound,
+			err:  errors.New(`workflows: workflow "export_deployments" not defined`),
+		},
+		{
+			name: "500",
+			code: http.StatusInternalServerError,
+			err: errors.New(`workflows: unexpected HTTP status from workflows service: ` +
+				`500 Internal Server Error`),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					defer r.Body.Close()
+					assert.Equal(t, exportDeploymentsURL, r.URL.Path)
+
+					var request ExportDeploymentsWorkflow
+					err := json.NewDecoder(r.Body).Decode(&request)
+					assert.NoError(t, err)
+					assert.Equal(t, ExportDeploymentsWorkflow{
+						RequestID: "reqid",
+						TenantID:  "tenant",
+						JobID:     "job",
+					}, request)
+					w.WriteHeader(tc.code)
+				},
+			))
+			defer srv.Close()
+
+			ctx := requestid.WithContext(context.Background(), "reqid")
+			ctx = identity.WithContext(ctx, &identity.Identity{Tenant: "tenant"})
+
+			client := NewClient().(*client)
+			client.baseURL = srv.URL
+
+			err := client.StartExportDeployments(ctx, "job")
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStartDeploymentFinished(t *testing.T) {
+	t.Parallel()
+
+	stats := model.Stats{
+		model.DeviceDeploymentStatusSuccessStr: 2,
+		model.DeviceDeploymentStatusFailureStr: 1,
+	}
+	testCases := []struct {
+		name string
+
+		code int
+
+		err error
+	}{
+		{
+			name: "ok",
+			code: http.StatusCreated,
+		},
+		{
+			name: "404",
+			code: http.StatusNotFound,
+			err:  errors.New(`workflows: workflow "notify_finished" not defined`),
+		},
+		{
+			name: "500",
+			code: http.StatusInternalServerError,
+			err: errors.New(`workflows: unexpected HTTP status from workflows service: ` +
+				`500 Internal Server Error`),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					defer r.Body.Close()
+					assert.Equal(t, "/api/v1/workflow/notify_finished", r.URL.Path)
+
+					var request DeploymentFinishedWorkflow
+					err := json.NewDecoder(r.Body).Decode(&request)
+					assert.NoError(t, err)
+					assert.Equal(t, DeploymentFinishedWorkflow{
+						RequestID:    "reqid",
+						TenantID:     "tenant",
+						DeploymentID: "deployment",
+						Stats:        stats,
+						Service:      ServiceDeployments,
+					}, request)
+					w.WriteHeader(tc.code)
+				},
+			))
+			defer srv.Close()
+
+			ctx := requestid.WithContext(context.Background(), "reqid")
+			ctx = identity.WithContext(ctx, &identity.Identity{Tenant: "tenant"})
+
+			client := NewClient().(*client)
+			client.baseURL = srv.URL
+
+			err := client.StartDeploymentFinished(ctx, "notify_finished", "deployment", stats)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -44,6 +44,34 @@ func (_m *Client) CheckHealth(ctx context.Context) error {
 	return r0
 }
 
+// StartDeploymentFinished provides a mock function with given fields: ctx, workflow, deploymentID, stats
+func (_m *Client) StartDeploymentFinished(ctx context.Context, workflow string, deploymentID string, stats model.Stats) error {
+	ret := _m.Called(ctx, workflow, deploymentID, stats)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, model.Stats) error); ok {
+		r0 = rf(ctx, workflow, deploymentID, stats)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StartExportDeployments provides a mock function with given fields: ctx, jobID
+func (_m *Client) StartExportDeployments(ctx context.Context, jobID string) error {
+	ret := _m.Called(ctx, jobID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, jobID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // StartGenerateArtifact provides a mock function with given fields: ctx, multipartGenerateImageMsg
 func (_m *Client) StartGenerateArtifact(ctx context.Context, multipartGenerateImageMsg *model.MultipartGenerateImageMsg) error {
 	ret := _m.Called(ctx, multipartGenerateImageMsg)
@@ -72,13 +100,13 @@ func (_m *Client) StartReindexReporting(c context.Context, device string) error
 	return r0
 }
 
-// StartReindexReportingDeployment provides a mock function with given fields: c, device, deployment, id
-func (_m *Client) StartReindexReportingDeployment(c context.Context, device string, deployment string, id string) error {
-	ret := _m.Called(c, device, deployment, id)
+// StartReindexReportingDeployment provides a mock function with given fields: c, device, deployment, id, attributes
+func (_m *Client) StartReindexReportingDeployment(c context.Context, device string, deployment string, id string, attributes []model.DeviceAttribute) error {
+	ret := _m.Called(c, device, deployment, id, attributes)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
-		r0 = rf(c, device, deployment, id)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []model.DeviceAttribute) error); ok {
+		r0 = rf(c, device, deployment, id, attributes)
 	} else {
 		r0 = ret.Error(0)
 	}
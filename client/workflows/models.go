@@ -14,6 +14,8 @@
 
 package workflows
 
+import "github.com/mendersoftware/deployments/model"
+
 const (
 	ServiceDeployments = "deployments"
 )
@@ -32,4 +34,21 @@ type ReindexDeploymentWorkflow struct {
 	DeploymentID string `json:"deployment_id"`
 	ID           string `json:"id"`
 	Service      string `json:"service"`
+	// DeviceAttributes are the device's inventory attributes attached
+	// to the reporting event, if configured.
+	DeviceAttributes []model.DeviceAttribute `json:"device_attributes,omitempty"`
+}
+
+type ExportDeploymentsWorkflow struct {
+	RequestID string `json:"request_id"`
+	TenantID  string `json:"tenant_id"`
+	JobID     string `json:"job_id"`
+}
+
+type DeploymentFinishedWorkflow struct {
+	RequestID    string      `json:"request_id"`
+	TenantID     string      `json:"tenant_id"`
+	DeploymentID string      `json:"deployment_id"`
+	Stats        model.Stats `json:"stats"`
+	Service      string      `json:"service"`
 }
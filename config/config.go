@@ -24,6 +24,8 @@ import (
 	"github.com/mendersoftware/go-lib-micro/config"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
 )
 
 const (
@@ -48,6 +50,16 @@ const (
 	SettingStorageMaxGenerateSize        = SettingStorage + ".max_generate_data_size"
 	SettingStorageMaxGenerateSizeDefault = 512 * 1024 * 1024 // 512 MiB
 
+	// SettingStorageMultipartPartSize sets the part size (in bytes) of S3
+	// multipart uploads; 0 derives the smallest part size that fits
+	// max_image_size.
+	SettingStorageMultipartPartSize        = SettingStorage + ".multipart_part_size"
+	SettingStorageMultipartPartSizeDefault = 0
+	// SettingStorageMultipartConcurrency sets the number of parts of a
+	// multipart upload that are uploaded in parallel.
+	SettingStorageMultipartConcurrency        = SettingStorage + ".multipart_concurrency"
+	SettingStorageMultipartConcurrencyDefault = 1
+
 	SettingStorageProxyURI = SettingStorage + ".proxy_uri"
 
 	SettingStorageEnableDirectUpload        = SettingStorage + ".enable_direct_upload"
@@ -61,6 +73,20 @@ const (
 	SettingsStorageUploadExpireSeconds          = SettingStorage + ".upload_expire_seconds"
 	SettingsStorageUploadExpireSecondsDefault   = 3600
 
+	// SettingsStorageDownloadExpireMaxSeconds caps the validity of the
+	// artifact download links the management API may ask for.
+	SettingsStorageDownloadExpireMaxSeconds        = SettingStorage + ".download_expire_max_seconds"
+	SettingsStorageDownloadExpireMaxSecondsDefault = 24 * 60 * 60
+
+	// SettingStorageMaxConcurrentGetRequests limits the number of download
+	// links that are signed concurrently; 0 disables the limit.
+	SettingStorageMaxConcurrentGetRequests        = SettingStorage + ".max_concurrent_get_requests"
+	SettingStorageMaxConcurrentGetRequestsDefault = 0
+	// SettingStorageGetRequestsMaxWait sets how long (in milliseconds) a
+	// request may wait for the limit above before failing with 429.
+	SettingStorageGetRequestsMaxWait        = SettingStorage + ".get_requests_max_wait"
+	SettingStorageGetRequestsMaxWaitDefault = 1000
+
 	SettingsAws                       = "aws"
 	SettingAwsS3Region                = SettingsAws + ".region"
 	SettingAwsS3RegionDefault         = "us-east-1"
@@ -73,6 +99,12 @@ const (
 	SettingAwsUnsignedHeaders         = SettingsAws + ".unsigned_headers"
 	SettingAwsUnsignedHeadersDefault  = "Accept-Encoding"
 
+	// SettingAwsServerSideEncryption encrypts the uploaded artifacts at
+	// rest, with S3 managed keys (AES256) or with the KMS key
+	// SettingAwsSSEKMSKeyID (aws:kms).
+	SettingAwsServerSideEncryption = SettingsAws + ".server_side_encryption"
+	SettingAwsSSEKMSKeyID          = SettingsAws + ".sse_kms_key_id"
+
 	SettingsAwsTagArtifact        = SettingsAws + ".tag_artifact"
 	SettingsAwsTagArtifactDefault = false
 
@@ -101,9 +133,119 @@ const (
 	SettingDbUsername = "mongo_username"
 	SettingDbPassword = "mongo_password"
 
+	// SettingMongoConnectTimeout sets the timeout (in seconds) for
+	// establishing and validating the connection to the database.
+	SettingMongoConnectTimeout        = "mongo_connect_timeout"
+	SettingMongoConnectTimeoutDefault = 10
+
+	// SettingMongoQueryTimeout sets the timeout (in seconds) applied to
+	// the most frequent read queries.
+	SettingMongoQueryTimeout        = "mongo_query_timeout"
+	SettingMongoQueryTimeoutDefault = 10
+
+	// SettingDbName is the name of the default database; the tenant
+	// databases are named after it, suffixed with the tenant ID.
+	SettingDbName        = "mongo_dbname"
+	SettingDbNameDefault = "deployment_service"
+
+	// SettingMongoIndexBuildBackground builds the indexes of the device
+	// deployments in the background when migrating or provisioning a
+	// tenant, not to block the writes to the collection while building them.
+	// Ignored by MongoDB 4.2 and later.
+	SettingMongoIndexBuildBackground        = "mongo_index_build_background"
+	SettingMongoIndexBuildBackgroundDefault = false
+
 	SettingWorkflows        = "mender-workflows"
 	SettingWorkflowsDefault = "http://mender-workflows-server:8080"
 
+	// SettingWorkflowsRetryMaxAttempts sets the number of attempts for
+	// starting a workflow when the workflows service fails with a network
+	// error or a 5xx status.
+	SettingWorkflowsRetryMaxAttempts        = "workflows_retry_max_attempts"
+	SettingWorkflowsRetryMaxAttemptsDefault = 3
+
+	// SettingWorkflowsRetryBaseDelay sets the delay (in milliseconds)
+	// before the first retry; the delay doubles after each retry and is
+	// randomized to spread the retries of concurrent calls.
+	SettingWorkflowsRetryBaseDelay        = "workflows_retry_base_delay"
+	SettingWorkflowsRetryBaseDelayDefault = 200
+
+	// SettingDeploymentFinishedWorkflowEnable starts a workflow every time
+	// a deployment finishes, e.g. to notify external automation.
+	SettingDeploymentFinishedWorkflowEnable        = "deployment_finished_workflow_enable"
+	SettingDeploymentFinishedWorkflowEnableDefault = false
+	// SettingDeploymentFinishedWorkflow is the name of the workflow above.
+	SettingDeploymentFinishedWorkflow        = "deployment_finished_workflow"
+	SettingDeploymentFinishedWorkflowDefault = "deployment_finished"
+
+	// SettingAutoFinishDeployments finishes the deployments without a
+	// device count to reach once none of their device deployments is active.
+	SettingAutoFinishDeployments        = "auto_finish_deployments"
+	SettingAutoFinishDeploymentsDefault = false
+
+	// SettingConfigurationGenerationMaxConcurrent limits the number of
+	// configuration artifacts generated concurrently for the devices;
+	// 0 disables the limit.
+	SettingConfigurationGenerationMaxConcurrent        = "configuration_generation_max_concurrent"
+	SettingConfigurationGenerationMaxConcurrentDefault = 0
+	// SettingConfigurationGenerationMaxWait sets how long (in milliseconds)
+	// a device may wait for the limit above before failing with 503.
+	SettingConfigurationGenerationMaxWait        = "configuration_generation_max_wait"
+	SettingConfigurationGenerationMaxWaitDefault = 1000
+	// SettingConfigurationGenerationRetryAfter is the number of seconds the
+	// devices are asked to wait before retrying when the limit is reached.
+	SettingConfigurationGenerationRetryAfter        = "configuration_generation_retry_after_seconds"
+	SettingConfigurationGenerationRetryAfterDefault = 10
+
+	// SettingDownloadLinkTTL is the validity (in seconds) of the artifact
+	// download links handed out to the devices, unless the deployment sets
+	// its own.
+	SettingDownloadLinkTTL        = "download_link_ttl"
+	SettingDownloadLinkTTLDefault = 24 * 60 * 60
+	// SettingDownloadLinkMaxTTL caps the download link validity a
+	// deployment may ask for.
+	SettingDownloadLinkMaxTTL        = "download_link_max_ttl"
+	SettingDownloadLinkMaxTTLDefault = 7 * 24 * 60 * 60
+
+	// SettingDeviceDeploymentConfirmationTimeout, if set, has the devices
+	// reporting success right after rebooting await a confirming status
+	// report; the update fails unless confirmed within that many seconds.
+	SettingDeviceDeploymentConfirmationTimeout        = "device_deployment_confirmation_timeout"
+	SettingDeviceDeploymentConfirmationTimeoutDefault = 0
+
+	// SettingDeviceDeploymentStatusDedupInterval is the number of seconds
+	// during which a device repeating its status does not refresh the last
+	// update time of its deployment.
+	SettingDeviceDeploymentStatusDedupInterval        = "device_deployment_status_dedup_interval"
+	SettingDeviceDeploymentStatusDedupIntervalDefault = 60
+
+	// SettingDeviceDeploymentSubStateHistoryLength is the number of latest
+	// status reports kept in the substate history of a device deployment;
+	// 0 disables the history.
+	SettingDeviceDeploymentSubStateHistoryLength        = "device_deployment_substate_history_length"
+	SettingDeviceDeploymentSubStateHistoryLengthDefault = 20
+
+	// SettingArtifactVerificationKeys lists the PEM files of the public
+	// keys the artifacts may be signed with; the key verifying the
+	// signature of an uploaded artifact is stored with it.
+	SettingArtifactVerificationKeys = "artifact_verification_keys"
+
+	// SettingArtifactDeviceTypeCheck warns, when uploading an artifact,
+	// about the compatible device types no device of the tenant reports.
+	SettingArtifactDeviceTypeCheck        = "artifact_device_type_check"
+	SettingArtifactDeviceTypeCheckDefault = false
+
+	// SettingRequireCompatibleArtifact rejects the deployments with no
+	// artifact compatible with the device types of the target devices.
+	SettingRequireCompatibleArtifact        = "require_compatible_artifact"
+	SettingRequireCompatibleArtifactDefault = false
+
+	// SettingArtifactImportStaleTimeout is the number of seconds a running
+	// artifact import job may go without progress before the storage daemon
+	// marks it as failed.
+	SettingArtifactImportStaleTimeout        = "artifact_import_stale_timeout"
+	SettingArtifactImportStaleTimeoutDefault = 60 * 60
+
 	SettingMiddleware        = "middleware"
 	SettingMiddlewareDefault = EnvProd
 
@@ -116,10 +258,47 @@ const (
 	SettingInventoryTimeout        = "inventory_timeout"
 	SettingInventoryTimeoutDefault = 10
 
+	// SettingInventoryRetryMaxAttempts sets the number of attempts for
+	// querying the inventory service when it fails with transient errors.
+	SettingInventoryRetryMaxAttempts        = "inventory_retry_max_attempts"
+	SettingInventoryRetryMaxAttemptsDefault = 3
+
+	// SettingInventoryRetryBaseDelay sets the delay (in milliseconds)
+	// before the first retry; the delay doubles after each retry.
+	SettingInventoryRetryBaseDelay        = "inventory_retry_base_delay"
+	SettingInventoryRetryBaseDelayDefault = 200
+
+	// SettingInventoryGroupCacheTTL sets how long (in seconds) the devices
+	// resolved for a group deployment are cached; 0 disables the cache.
+	SettingInventoryGroupCacheTTL        = "inventory_group_cache_ttl"
+	SettingInventoryGroupCacheTTLDefault = 0
+	// SettingInventoryGroupCacheSize limits the number of cached groups.
+	SettingInventoryGroupCacheSize        = "inventory_group_cache_size"
+	SettingInventoryGroupCacheSizeDefault = 1000
+
+	// SettingReportingRetryMaxAttempts sets the number of attempts for
+	// querying the reporting service when it fails with transient errors.
+	SettingReportingRetryMaxAttempts        = "reporting_retry_max_attempts"
+	SettingReportingRetryMaxAttemptsDefault = 3
+
+	// SettingReportingRetryBaseDelay sets the delay (in milliseconds)
+	// before the first retry; the delay doubles after each retry.
+	SettingReportingRetryBaseDelay        = "reporting_retry_base_delay"
+	SettingReportingRetryBaseDelayDefault = 200
+
+	// SettingReportingDeviceAttributes lists the names of the inventory
+	// attributes attached to the reporting events emitted on device
+	// deployment status changes; empty disables the enrichment.
+	SettingReportingDeviceAttributes = "reporting_device_attributes"
+	// SettingReportingDeviceAttributesCacheTTL sets how long (in seconds)
+	// the attributes fetched for a device are cached.
+	SettingReportingDeviceAttributesCacheTTL        = "reporting_device_attributes_cache_ttl"
+	SettingReportingDeviceAttributesCacheTTLDefault = 60
+
 	// SettingPresignAlgorithm sets the algorithm used for signing
-	// downloadable URLs. This option is currently ignored.
+	// downloadable URLs: HMAC256 or HMAC512.
 	SettingPresignAlgorithm        = "presign.algorithm"
-	SettingPresignAlgorithmDefault = "HMAC256"
+	SettingPresignAlgorithmDefault = model.SignatureAlgorithmHMAC256
 
 	// SettingPresignSecret sets the secret for generating signed url.
 	// For HMAC type of algorithms the value must be a base64 encoded
@@ -150,6 +329,80 @@ const (
 	// migrations on the artifacts and releases collections.
 	SettingDisableNewReleasesFeature        = "disable_new_releases_feature"
 	SettingDisableNewReleasesFeatureDefault = false
+
+	// SettingDeletedDeploymentStatusResponse selects how to respond to a
+	// device reporting a status for a deployment that has been deleted:
+	// "notfound" responds with 404 Not Found, "ignore" accepts and discards
+	// the report and "gone" responds with 410 Gone.
+	SettingDeletedDeploymentStatusResponse        = "deleted_deployment_status_response"
+	SettingDeletedDeploymentStatusResponseDefault = DeletedDeploymentStatusResponseNotFound
+
+	// SettingDeletedDeploymentsRetention sets the number of days deleted
+	// deployments can be restored before the storage daemon purges them;
+	// 0 keeps deleted deployments indefinitely.
+	SettingDeletedDeploymentsRetention        = "deleted_deployments_retention_days"
+	SettingDeletedDeploymentsRetentionDefault = 30
+
+	// SettingDeviceDeploymentLogsRetention sets the number of days the
+	// logs of finished device deployments are kept before the storage
+	// daemon purges them; 0 keeps the logs indefinitely.
+	SettingDeviceDeploymentLogsRetention        = "device_deployment_logs_retention_days"
+	SettingDeviceDeploymentLogsRetentionDefault = 0
+
+	// SettingDuplicateDeviceIDs selects how to handle device IDs listed more
+	// than once when creating a deployment: "dedupe" drops the duplicates
+	// and warns about them in the response and "reject" responds with
+	// 400 Bad Request.
+	SettingDuplicateDeviceIDs        = "duplicate_device_ids"
+	SettingDuplicateDeviceIDsDefault = DuplicateDeviceIDsDedupe
+
+	// SettingDeleteAssignedArtifact selects how to delete an artifact
+	// assigned to devices in active deployments: "block" responds with
+	// 409 Conflict and "clear" removes the assignments, so the devices
+	// get another artifact of the release, if any, on their next request.
+	SettingDeleteAssignedArtifact        = "delete_assigned_artifact"
+	SettingDeleteAssignedArtifactDefault = DeleteAssignedArtifactBlock
+
+	// SettingMaintenanceMode enables the maintenance mode when the service
+	// starts, unless the mode stored in the database was set before: the
+	// device API responds with 503 Service Unavailable, while the management
+	// and internal APIs keep working.
+	SettingMaintenanceMode        = "maintenance_mode"
+	SettingMaintenanceModeDefault = false
+	// SettingMaintenanceModeCacheTTL sets how long (in seconds) the
+	// maintenance mode, shared by the instances through the database, is
+	// cached; toggling it takes effect within that time.
+	SettingMaintenanceModeCacheTTL        = "maintenance_mode_cache_ttl_seconds"
+	SettingMaintenanceModeCacheTTLDefault = 5
+	// SettingMaintenanceRetryAfter is the number of seconds the devices
+	// are told to wait before retrying during maintenance.
+	SettingMaintenanceRetryAfter        = "maintenance_retry_after_seconds"
+	SettingMaintenanceRetryAfterDefault = 300
+
+	// SettingCompressResponses enables the gzip compression of the
+	// management list responses for the clients accepting it.
+	SettingCompressResponses        = "compress_responses"
+	SettingCompressResponsesDefault = false
+	// SettingCompressResponsesMinSize is the size (in bytes) below which
+	// the responses are sent uncompressed.
+	SettingCompressResponsesMinSize        = "compress_responses_min_size"
+	SettingCompressResponsesMinSizeDefault = 1024
+)
+
+const (
+	DeletedDeploymentStatusResponseNotFound = "notfound"
+	DeletedDeploymentStatusResponseIgnore   = "ignore"
+	DeletedDeploymentStatusResponseGone     = "gone"
+)
+
+const (
+	DuplicateDeviceIDsDedupe = "dedupe"
+	DuplicateDeviceIDsReject = "reject"
+)
+
+const (
+	DeleteAssignedArtifactBlock = "block"
+	DeleteAssignedArtifactClear = "clear"
 )
 
 const (
@@ -183,6 +436,26 @@ func ValidateAwsAuth(c config.Reader) error {
 	return nil
 }
 
+// ValidateAwsServerSideEncryption validates the server-side encryption
+// mode, and that a KMS key is set for aws:kms encryption.
+func ValidateAwsServerSideEncryption(c config.Reader) error {
+	switch mode := c.GetString(SettingAwsServerSideEncryption); mode {
+	case "", model.ServerSideEncryptionAES256:
+		return nil
+	case model.ServerSideEncryptionKMS:
+		if c.GetString(SettingAwsSSEKMSKeyID) == "" {
+			return MissingOptionError(SettingAwsSSEKMSKeyID)
+		}
+		return nil
+	default:
+		return fmt.Errorf(
+			`setting "%s" (%s) must be one of "%s" or "%s"`,
+			SettingAwsServerSideEncryption, mode,
+			model.ServerSideEncryptionAES256, model.ServerSideEncryptionKMS,
+		)
+	}
+}
+
 // ValidateHttps validates configuration of SettingHttps section if provided.
 func ValidateHttps(c config.Reader) error {
 
@@ -218,6 +491,399 @@ func ValidateStorage(c config.Reader) error {
 	return nil
 }
 
+// ValidateDeletedDeploymentStatusResponse validates the response mode for status
+// reports of deleted deployments.
+func ValidateDeletedDeploymentStatusResponse(c config.Reader) error {
+	switch mode := c.GetString(SettingDeletedDeploymentStatusResponse); mode {
+	case DeletedDeploymentStatusResponseNotFound,
+		DeletedDeploymentStatusResponseIgnore,
+		DeletedDeploymentStatusResponseGone:
+		return nil
+	default:
+		return fmt.Errorf(
+			`setting "%s" (%s) must be one of "%s", "%s" or "%s"`,
+			SettingDeletedDeploymentStatusResponse, mode,
+			DeletedDeploymentStatusResponseNotFound,
+			DeletedDeploymentStatusResponseIgnore,
+			DeletedDeploymentStatusResponseGone,
+		)
+	}
+}
+
+// ValidatePresignAlgorithm checks that the URL signing algorithm is
+// supported.
+func ValidatePresignAlgorithm(c config.Reader) error {
+	alg := c.GetString(SettingPresignAlgorithm)
+	if !model.ValidSignatureAlgorithm(alg) {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be one of "%s" or "%s"`,
+			SettingPresignAlgorithm, alg,
+			model.SignatureAlgorithmHMAC256, model.SignatureAlgorithmHMAC512,
+		)
+	}
+	return nil
+}
+
+// ValidateDuplicateDeviceIDs validates the handling of duplicate device IDs
+// in new deployments.
+func ValidateDuplicateDeviceIDs(c config.Reader) error {
+	switch mode := c.GetString(SettingDuplicateDeviceIDs); mode {
+	case DuplicateDeviceIDsDedupe, DuplicateDeviceIDsReject:
+		return nil
+	default:
+		return fmt.Errorf(
+			`setting "%s" (%s) must be one of "%s" or "%s"`,
+			SettingDuplicateDeviceIDs, mode,
+			DuplicateDeviceIDsDedupe, DuplicateDeviceIDsReject,
+		)
+	}
+}
+
+// ValidateDeleteAssignedArtifact validates the handling of the deletion of
+// assigned artifacts.
+func ValidateDeleteAssignedArtifact(c config.Reader) error {
+	switch mode := c.GetString(SettingDeleteAssignedArtifact); mode {
+	case DeleteAssignedArtifactBlock, DeleteAssignedArtifactClear:
+		return nil
+	default:
+		return fmt.Errorf(
+			`setting "%s" (%s) must be one of "%s" or "%s"`,
+			SettingDeleteAssignedArtifact, mode,
+			DeleteAssignedArtifactBlock, DeleteAssignedArtifactClear,
+		)
+	}
+}
+
+// ValidateDeviceDeploymentLogsRetention checks that the retention period of
+// device deployment logs is not negative.
+func ValidateDeviceDeploymentLogsRetention(c config.Reader) error {
+	if c.GetInt(SettingDeviceDeploymentLogsRetention) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingDeviceDeploymentLogsRetention,
+			c.GetString(SettingDeviceDeploymentLogsRetention),
+		)
+	}
+	return nil
+}
+
+// ValidateMaintenanceRetryAfter checks that the devices are told to wait
+// before retrying during maintenance.
+func ValidateMaintenanceRetryAfter(c config.Reader) error {
+	if c.GetInt(SettingMaintenanceRetryAfter) <= 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be a positive integer`,
+			SettingMaintenanceRetryAfter,
+			c.GetString(SettingMaintenanceRetryAfter),
+		)
+	}
+	return nil
+}
+
+// ValidateMaintenanceModeCacheTTL checks the caching of the maintenance
+// mode.
+func ValidateMaintenanceModeCacheTTL(c config.Reader) error {
+	if c.GetInt(SettingMaintenanceModeCacheTTL) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingMaintenanceModeCacheTTL,
+			c.GetString(SettingMaintenanceModeCacheTTL),
+		)
+	}
+	return nil
+}
+
+// ValidateCompressResponses checks the minimum size of the compressed
+// responses.
+func ValidateCompressResponses(c config.Reader) error {
+	if c.GetInt(SettingCompressResponsesMinSize) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingCompressResponsesMinSize,
+			c.GetString(SettingCompressResponsesMinSize),
+		)
+	}
+	return nil
+}
+
+// ValidateDeletedDeploymentsRetention checks that the retention period of
+// deleted deployments is not negative.
+func ValidateDeletedDeploymentsRetention(c config.Reader) error {
+	if c.GetInt(SettingDeletedDeploymentsRetention) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingDeletedDeploymentsRetention,
+			c.GetString(SettingDeletedDeploymentsRetention),
+		)
+	}
+	return nil
+}
+
+// ValidateMongoTimeouts checks that the database timeouts are positive.
+func ValidateMongoTimeouts(c config.Reader) error {
+	for _, key := range []string{SettingMongoConnectTimeout, SettingMongoQueryTimeout} {
+		if c.GetInt(key) <= 0 {
+			return fmt.Errorf(
+				`setting "%s" (%s) must be a positive number of seconds`,
+				key, c.GetString(key),
+			)
+		}
+	}
+	return nil
+}
+
+// ValidateDbName checks that the database name is a valid MongoDB database
+// name and does not contain the separator of the tenant database names,
+// which would make it prefix the databases of other instances.
+func ValidateDbName(c config.Reader) error {
+	name := c.GetString(SettingDbName)
+	if name == "" || strings.ContainsAny(name, "-/\\. \"$") {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be a non-empty database name `+
+				`without any of the characters '-/\. "$'`,
+			SettingDbName, name,
+		)
+	}
+	return nil
+}
+
+// ValidateStorageGetRequestsLimit checks that the download link limits are
+// not negative.
+func ValidateStorageGetRequestsLimit(c config.Reader) error {
+	for _, key := range []string{
+		SettingStorageMaxConcurrentGetRequests,
+		SettingStorageGetRequestsMaxWait,
+	} {
+		if c.GetInt(key) < 0 {
+			return fmt.Errorf(
+				`setting "%s" (%s) must not be negative`,
+				key, c.GetString(key),
+			)
+		}
+	}
+	return nil
+}
+
+// ValidateConfigurationGenerationLimit checks that the configuration
+// artifact generation limits are not negative and that the devices are told
+// to wait before retrying.
+func ValidateConfigurationGenerationLimit(c config.Reader) error {
+	for _, key := range []string{
+		SettingConfigurationGenerationMaxConcurrent,
+		SettingConfigurationGenerationMaxWait,
+	} {
+		if c.GetInt(key) < 0 {
+			return fmt.Errorf(
+				`setting "%s" (%s) must not be negative`,
+				key, c.GetString(key),
+			)
+		}
+	}
+	if c.GetInt(SettingConfigurationGenerationRetryAfter) <= 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be positive`,
+			SettingConfigurationGenerationRetryAfter,
+			c.GetString(SettingConfigurationGenerationRetryAfter),
+		)
+	}
+	return nil
+}
+
+// ValidateDownloadLinkTTL checks that the default download link validities
+// are positive and within their maximum.
+func ValidateDownloadLinkTTL(c config.Reader) error {
+	ttl := c.GetInt(SettingDownloadLinkTTL)
+	if ttl <= 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be positive`,
+			SettingDownloadLinkTTL, c.GetString(SettingDownloadLinkTTL),
+		)
+	}
+	maxTTL := c.GetInt(SettingDownloadLinkMaxTTL)
+	if ttl > maxTTL {
+		return fmt.Errorf(
+			`setting "%s" (%d) must not exceed "%s" (%d)`,
+			SettingDownloadLinkTTL, ttl, SettingDownloadLinkMaxTTL, maxTTL,
+		)
+	}
+	expire := c.GetInt(SettingsStorageDownloadExpireSeconds)
+	if maxExpire := c.GetInt(SettingsStorageDownloadExpireMaxSeconds); expire > maxExpire {
+		return fmt.Errorf(
+			`setting "%s" (%d) must not exceed "%s" (%d)`,
+			SettingsStorageDownloadExpireSeconds, expire,
+			SettingsStorageDownloadExpireMaxSeconds, maxExpire,
+		)
+	}
+	return nil
+}
+
+// ValidateDeviceDeploymentSubStateHistoryLength checks that the substate
+// history length is not negative.
+func ValidateDeviceDeploymentSubStateHistoryLength(c config.Reader) error {
+	if c.GetInt(SettingDeviceDeploymentSubStateHistoryLength) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingDeviceDeploymentSubStateHistoryLength,
+			c.GetString(SettingDeviceDeploymentSubStateHistoryLength),
+		)
+	}
+	return nil
+}
+
+// ValidateDeviceDeploymentStatusDedupInterval checks that the status
+// de-duplication interval is not negative.
+func ValidateDeviceDeploymentStatusDedupInterval(c config.Reader) error {
+	if c.GetInt(SettingDeviceDeploymentStatusDedupInterval) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingDeviceDeploymentStatusDedupInterval,
+			c.GetString(SettingDeviceDeploymentStatusDedupInterval),
+		)
+	}
+	return nil
+}
+
+// ValidateDeviceDeploymentConfirmationTimeout checks that the confirmation
+// timeout is not negative.
+func ValidateDeviceDeploymentConfirmationTimeout(c config.Reader) error {
+	if c.GetInt(SettingDeviceDeploymentConfirmationTimeout) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingDeviceDeploymentConfirmationTimeout,
+			c.GetString(SettingDeviceDeploymentConfirmationTimeout),
+		)
+	}
+	return nil
+}
+
+// ValidateArtifactImportStaleTimeout checks that the stale import timeout
+// is positive.
+func ValidateArtifactImportStaleTimeout(c config.Reader) error {
+	if c.GetInt(SettingArtifactImportStaleTimeout) <= 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be positive`,
+			SettingArtifactImportStaleTimeout,
+			c.GetString(SettingArtifactImportStaleTimeout),
+		)
+	}
+	return nil
+}
+
+// ValidateStorageMultipart checks that the multipart part size respects the
+// S3 limits and that the upload concurrency is positive.
+func ValidateStorageMultipart(c config.Reader) error {
+	const (
+		minPartSize = 5 * 1024 * 1024
+		maxParts    = 10000
+	)
+	partSize := int64(c.GetInt(SettingStorageMultipartPartSize))
+	if partSize != 0 {
+		if partSize < minPartSize {
+			return fmt.Errorf(
+				`setting "%s" (%s) must be at least 5MiB (%d bytes)`,
+				SettingStorageMultipartPartSize,
+				c.GetString(SettingStorageMultipartPartSize),
+				minPartSize,
+			)
+		}
+		maxImageSize := int64(c.GetInt(SettingStorageMaxImageSize))
+		if partSize*maxParts < maxImageSize {
+			return fmt.Errorf(
+				`setting "%s" (%s) is too small to upload artifacts of `+
+					`"%s" (%d) bytes in at most %d parts`,
+				SettingStorageMultipartPartSize,
+				c.GetString(SettingStorageMultipartPartSize),
+				SettingStorageMaxImageSize, maxImageSize, maxParts,
+			)
+		}
+	}
+	if c.GetInt(SettingStorageMultipartConcurrency) < 1 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be at least 1`,
+			SettingStorageMultipartConcurrency,
+			c.GetString(SettingStorageMultipartConcurrency),
+		)
+	}
+	return nil
+}
+
+// ValidateDeploymentFinishedWorkflow checks the name of the workflow started
+// when deployments finish.
+func ValidateDeploymentFinishedWorkflow(c config.Reader) error {
+	if !c.GetBool(SettingDeploymentFinishedWorkflowEnable) {
+		return nil
+	}
+	name := c.GetString(SettingDeploymentFinishedWorkflow)
+	if name == "" || strings.ContainsAny(name, "/?#") {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be a workflow name`,
+			SettingDeploymentFinishedWorkflow, name,
+		)
+	}
+	return nil
+}
+
+// ValidateInventoryGroupCache checks the settings of the device group cache.
+func ValidateInventoryGroupCache(c config.Reader) error {
+	if c.GetInt(SettingInventoryGroupCacheTTL) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingInventoryGroupCacheTTL,
+			c.GetString(SettingInventoryGroupCacheTTL),
+		)
+	}
+	if c.GetInt(SettingInventoryGroupCacheSize) < 1 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must be at least 1`,
+			SettingInventoryGroupCacheSize,
+			c.GetString(SettingInventoryGroupCacheSize),
+		)
+	}
+	return nil
+}
+
+// ValidateReportingDeviceAttributes checks the settings of the device
+// attributes attached to the reporting events.
+func ValidateReportingDeviceAttributes(c config.Reader) error {
+	if c.GetInt(SettingReportingDeviceAttributesCacheTTL) < 0 {
+		return fmt.Errorf(
+			`setting "%s" (%s) must not be negative`,
+			SettingReportingDeviceAttributesCacheTTL,
+			c.GetString(SettingReportingDeviceAttributesCacheTTL),
+		)
+	}
+	return nil
+}
+
+// ValidateClientRetries checks the retry settings of the service clients.
+func ValidateClientRetries(c config.Reader) error {
+	for _, key := range []string{
+		SettingInventoryRetryMaxAttempts,
+		SettingReportingRetryMaxAttempts,
+		SettingWorkflowsRetryMaxAttempts,
+	} {
+		if c.GetInt(key) < 1 {
+			return fmt.Errorf(
+				`setting "%s" (%s) must be at least 1`,
+				key, c.GetString(key),
+			)
+		}
+	}
+	for _, key := range []string{
+		SettingInventoryRetryBaseDelay,
+		SettingReportingRetryBaseDelay,
+		SettingWorkflowsRetryBaseDelay,
+	} {
+		if c.GetInt(key) < 0 {
+			return fmt.Errorf(
+				`setting "%s" (%s) must not be negative`,
+				key, c.GetString(key),
+			)
+		}
+	}
+	return nil
+}
+
 // Generate error with missing required option message.
 func MissingOptionError(option string) error {
 	return fmt.Errorf("Required option: '%s'", option)
@@ -269,7 +935,35 @@ func Setup(configPath string) error {
 }
 
 var (
-	Validators = []config.Validator{ValidateAwsAuth, ValidateHttps, ValidateStorage}
+	Validators = []config.Validator{
+		ValidateAwsAuth,
+		ValidateAwsServerSideEncryption,
+		ValidateHttps,
+		ValidateStorage,
+		ValidateMongoTimeouts,
+		ValidateDbName,
+		ValidateDeletedDeploymentStatusResponse,
+		ValidateClientRetries,
+		ValidateDeletedDeploymentsRetention,
+		ValidateDeviceDeploymentLogsRetention,
+		ValidateStorageGetRequestsLimit,
+		ValidateStorageMultipart,
+		ValidateInventoryGroupCache,
+		ValidateReportingDeviceAttributes,
+		ValidateDuplicateDeviceIDs,
+		ValidateDeleteAssignedArtifact,
+		ValidatePresignAlgorithm,
+		ValidateDeploymentFinishedWorkflow,
+		ValidateMaintenanceRetryAfter,
+		ValidateMaintenanceModeCacheTTL,
+		ValidateCompressResponses,
+		ValidateConfigurationGenerationLimit,
+		ValidateDownloadLinkTTL,
+		ValidateDeviceDeploymentConfirmationTimeout,
+		ValidateArtifactImportStaleTimeout,
+		ValidateDeviceDeploymentStatusDedupInterval,
+		ValidateDeviceDeploymentSubStateHistoryLength,
+	}
 	// Aliases for deprecated configuration names to preserve backward compatibility.
 	Aliases = []struct {
 		Key   string
@@ -297,20 +991,77 @@ var (
 		{Key: SettingStorageMaxGenerateSize, Value: SettingStorageMaxGenerateSizeDefault},
 		{Key: SettingsStorageDownloadExpireSeconds,
 			Value: SettingsStorageDownloadExpireSecondsDefault},
+		{Key: SettingsStorageDownloadExpireMaxSeconds,
+			Value: SettingsStorageDownloadExpireMaxSecondsDefault},
 		{Key: SettingsStorageUploadExpireSeconds, Value: SettingsStorageUploadExpireSecondsDefault},
+		{Key: SettingStorageMaxConcurrentGetRequests,
+			Value: SettingStorageMaxConcurrentGetRequestsDefault},
+		{Key: SettingStorageGetRequestsMaxWait, Value: SettingStorageGetRequestsMaxWaitDefault},
+		{Key: SettingStorageMultipartPartSize, Value: SettingStorageMultipartPartSizeDefault},
+		{Key: SettingStorageMultipartConcurrency,
+			Value: SettingStorageMultipartConcurrencyDefault},
 		{Key: SettingMongo, Value: SettingMongoDefault},
 		{Key: SettingDbSSL, Value: SettingDbSSLDefault},
 		{Key: SettingDbSSLSkipVerify, Value: SettingDbSSLSkipVerifyDefault},
+		{Key: SettingMongoConnectTimeout, Value: SettingMongoConnectTimeoutDefault},
+		{Key: SettingMongoQueryTimeout, Value: SettingMongoQueryTimeoutDefault},
+		{Key: SettingDbName, Value: SettingDbNameDefault},
+		{Key: SettingMongoIndexBuildBackground, Value: SettingMongoIndexBuildBackgroundDefault},
 		{Key: SettingWorkflows, Value: SettingWorkflowsDefault},
+		{Key: SettingWorkflowsRetryMaxAttempts, Value: SettingWorkflowsRetryMaxAttemptsDefault},
+		{Key: SettingWorkflowsRetryBaseDelay, Value: SettingWorkflowsRetryBaseDelayDefault},
+		{Key: SettingDeploymentFinishedWorkflowEnable,
+			Value: SettingDeploymentFinishedWorkflowEnableDefault},
+		{Key: SettingDeploymentFinishedWorkflow, Value: SettingDeploymentFinishedWorkflowDefault},
+		{Key: SettingAutoFinishDeployments, Value: SettingAutoFinishDeploymentsDefault},
+		{Key: SettingConfigurationGenerationMaxConcurrent,
+			Value: SettingConfigurationGenerationMaxConcurrentDefault},
+		{Key: SettingConfigurationGenerationMaxWait,
+			Value: SettingConfigurationGenerationMaxWaitDefault},
+		{Key: SettingConfigurationGenerationRetryAfter,
+			Value: SettingConfigurationGenerationRetryAfterDefault},
+		{Key: SettingDownloadLinkTTL, Value: SettingDownloadLinkTTLDefault},
+		{Key: SettingDownloadLinkMaxTTL, Value: SettingDownloadLinkMaxTTLDefault},
+		{Key: SettingDeviceDeploymentConfirmationTimeout,
+			Value: SettingDeviceDeploymentConfirmationTimeoutDefault},
+		{Key: SettingDeviceDeploymentStatusDedupInterval,
+			Value: SettingDeviceDeploymentStatusDedupIntervalDefault},
+		{Key: SettingDeviceDeploymentSubStateHistoryLength,
+			Value: SettingDeviceDeploymentSubStateHistoryLengthDefault},
+		{Key: SettingArtifactDeviceTypeCheck, Value: SettingArtifactDeviceTypeCheckDefault},
+		{Key: SettingRequireCompatibleArtifact, Value: SettingRequireCompatibleArtifactDefault},
+		{Key: SettingArtifactImportStaleTimeout,
+			Value: SettingArtifactImportStaleTimeoutDefault},
 		{Key: SettingsAwsTagArtifact, Value: SettingsAwsTagArtifactDefault},
 		{Key: SettingInventoryAddr, Value: SettingInventoryAddrDefault},
 		{Key: SettingReportingAddr, Value: SettingReportingAddrDefault},
 		{Key: SettingInventoryTimeout, Value: SettingInventoryTimeoutDefault},
+		{Key: SettingInventoryRetryMaxAttempts, Value: SettingInventoryRetryMaxAttemptsDefault},
+		{Key: SettingInventoryRetryBaseDelay, Value: SettingInventoryRetryBaseDelayDefault},
+		{Key: SettingInventoryGroupCacheTTL, Value: SettingInventoryGroupCacheTTLDefault},
+		{Key: SettingInventoryGroupCacheSize, Value: SettingInventoryGroupCacheSizeDefault},
+		{Key: SettingReportingRetryMaxAttempts, Value: SettingReportingRetryMaxAttemptsDefault},
+		{Key: SettingReportingRetryBaseDelay, Value: SettingReportingRetryBaseDelayDefault},
+		{Key: SettingReportingDeviceAttributesCacheTTL,
+			Value: SettingReportingDeviceAttributesCacheTTLDefault},
 		{Key: SettingPresignAlgorithm, Value: SettingPresignAlgorithmDefault},
 		{Key: SettingPresignSecret, Value: SettingPresignSecretDefault},
 		{Key: SettingPresignExpireSeconds, Value: SettingPresignExpireSecondsDefault},
 		{Key: SettingPresignHost, Value: SettingPresignHostDefault},
 		{Key: SettingPresignScheme, Value: SettingPresignSchemeDefault},
 		{Key: SettingDisableNewReleasesFeature, Value: SettingDisableNewReleasesFeatureDefault},
+		{Key: SettingDeletedDeploymentStatusResponse,
+			Value: SettingDeletedDeploymentStatusResponseDefault},
+		{Key: SettingDeletedDeploymentsRetention,
+			Value: SettingDeletedDeploymentsRetentionDefault},
+		{Key: SettingDeviceDeploymentLogsRetention,
+			Value: SettingDeviceDeploymentLogsRetentionDefault},
+		{Key: SettingDuplicateDeviceIDs, Value: SettingDuplicateDeviceIDsDefault},
+		{Key: SettingDeleteAssignedArtifact, Value: SettingDeleteAssignedArtifactDefault},
+		{Key: SettingMaintenanceMode, Value: SettingMaintenanceModeDefault},
+		{Key: SettingMaintenanceModeCacheTTL, Value: SettingMaintenanceModeCacheTTLDefault},
+		{Key: SettingMaintenanceRetryAfter, Value: SettingMaintenanceRetryAfterDefault},
+		{Key: SettingCompressResponses, Value: SettingCompressResponsesDefault},
+		{Key: SettingCompressResponsesMinSize, Value: SettingCompressResponsesMinSizeDefault},
 	}
 )
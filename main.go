@@ -108,8 +108,45 @@ func doMain(args []string) {
 			Action: cmdPropagateReporting,
 		},
 		{
-			Name:  "storage-daemon",
-			Usage: "Start storage daemon cleaning up expired objects from storage",
+			Name: "rebuild-releases",
+			Usage: "Rebuild the releases from the artifacts, creating, updating " +
+				"and removing the releases out of sync",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant_id",
+					Usage: "Tenant ID (optional) - rebuild for just a single tenant.",
+				},
+				cli.UintFlag{
+					Name:  "rate-limit",
+					Usage: "`N`umber of tenant DBs processed per second",
+					Value: cliDefaultRateLimit,
+				},
+				cli.BoolFlag{
+					Name: "dry-run",
+					Usage: "Do not perform any modifications," +
+						" just scan and print the releases out of sync.",
+				},
+			},
+
+			Action: cmdRebuildReleases,
+		},
+		{
+			Name: "reconcile-device-counts",
+			Usage: "Recount the devices of the deployments, correcting the " +
+				"device counts which drifted from the device deployments",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant_id",
+					Usage: "Tenant ID (optional) - reconcile for just a single tenant.",
+				},
+			},
+
+			Action: cmdReconcileDeviceCounts,
+		},
+		{
+			Name: "storage-daemon",
+			Usage: "Start storage daemon cleaning up expired objects from storage " +
+				"and purging deleted deployments and old device deployment logs",
 			Flags: []cli.Flag{
 				cli.DurationFlag{
 					Name: "interval",
@@ -192,16 +229,20 @@ func migrate(tenant string, automigrate bool) error {
 		_ = dbClient.Disconnect(ctx)
 	}()
 
+	baseDb := config.Config.GetString(dconfig.SettingDbName)
 	dbVersion := mongo.DbVersion
 	if !automigrate {
 		dbVersion = mongo.DbMinimumVersion
 	}
 
+	opts := mongo.MigrationOptions{
+		BackgroundIndexBuild: config.Config.GetBool(dconfig.SettingMongoIndexBuildBackground),
+	}
 	if tenant != "" {
-		db := mstore.DbNameForTenant(tenant, mongo.DbName)
-		err = mongo.MigrateSingle(ctx, db, dbVersion, dbClient, automigrate)
+		db := mstore.DbNameForTenant(tenant, baseDb)
+		err = mongo.MigrateSingle(ctx, baseDb, db, dbVersion, dbClient, automigrate, opts)
 	} else {
-		err = mongo.Migrate(ctx, dbVersion, dbClient, automigrate)
+		err = mongo.Migrate(ctx, baseDb, dbVersion, dbClient, automigrate, opts)
 	}
 	if err != nil {
 		return cli.NewExitError(
@@ -222,8 +263,26 @@ func cmdStorageDaemon(args *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	database := mongo.NewDataStoreMongoWithClient(mgo)
-	app := app.NewDeployments(database, objectStorage, 0, false)
+	baseDb := config.Config.GetString(dconfig.SettingDbName)
+	database := mongo.NewDataStoreMongoWithClient(mgo).
+		WithDbName(baseDb).
+		WithQueryTimeout(
+			time.Duration(config.Config.GetInt(dconfig.SettingMongoQueryTimeout)) * time.Second,
+		).
+		WithSubStateHistoryLength(
+			config.Config.GetInt(dconfig.SettingDeviceDeploymentSubStateHistoryLength),
+		)
+	app := app.NewDeployments(database, objectStorage, 0, false).
+		WithDbName(baseDb).
+		WithDeletedDeploymentsRetention(deletedDeploymentsRetention(config.Config)).
+		WithDeviceDeploymentLogsRetention(deviceDeploymentLogsRetention(config.Config)).
+		WithDeviceDeploymentConfirmation(time.Duration(
+			config.Config.GetInt(dconfig.SettingDeviceDeploymentConfirmationTimeout),
+		) * time.Second).
+		WithArtifactImportStaleTimeout(time.Duration(
+			config.Config.GetInt(dconfig.SettingArtifactImportStaleTimeout),
+		) * time.Second)
+	app = withStatusUpdateClients(app, config.Config)
 	return app.CleanupExpiredUploads(
 		ctx,
 		args.Duration("interval"),
@@ -249,7 +308,8 @@ func cmdPropagateReporting(args *cli.Context) error {
 		_ = dbClient.Disconnect(context.Background())
 	}()
 
-	db := mongo.NewDataStoreMongoWithClient(dbClient)
+	baseDb := config.Config.GetString(dconfig.SettingDbName)
+	db := mongo.NewDataStoreMongoWithClient(dbClient).WithDbName(baseDb)
 
 	wflows := workflows.NewClient()
 
@@ -261,6 +321,7 @@ func cmdPropagateReporting(args *cli.Context) error {
 
 	err = propagateReporting(
 		db,
+		baseDb,
 		wflows,
 		args.String("tenant_id"),
 		requestPeriod,
@@ -274,6 +335,7 @@ func cmdPropagateReporting(args *cli.Context) error {
 
 func propagateReporting(
 	db store.DataStore,
+	baseDb string,
 	wflows workflows.Client,
 	tenant string,
 	requestPeriod time.Duration,
@@ -281,14 +343,14 @@ func propagateReporting(
 ) error {
 	l := log.NewEmpty()
 
-	dbs, err := selectDbs(db, tenant)
+	dbs, err := selectDbs(db, baseDb, tenant)
 	if err != nil {
 		return errors.Wrap(err, "aborting")
 	}
 
 	var errReturned error
 	for _, d := range dbs {
-		err := tryPropagateReportingForDb(db, wflows, d, requestPeriod, dryRun)
+		err := tryPropagateReportingForDb(db, baseDb, wflows, d, requestPeriod, dryRun)
 		if err != nil {
 			errReturned = err
 			l.Errorf("giving up on DB %s due to fatal error: %s", d, err.Error())
@@ -300,17 +362,17 @@ func propagateReporting(
 	return errReturned
 }
 
-func selectDbs(db store.DataStore, tenant string) ([]string, error) {
+func selectDbs(db store.DataStore, baseDb, tenant string) ([]string, error) {
 	l := log.NewEmpty()
 
 	var dbs []string
 
 	if tenant != "" {
-		l.Infof("propagating deployments history for user-specified tenant %s", tenant)
-		n := mstore.DbNameForTenant(tenant, mongo.DbName)
+		l.Infof("processing the DB of user-specified tenant %s", tenant)
+		n := mstore.DbNameForTenant(tenant, baseDb)
 		dbs = []string{n}
 	} else {
-		l.Infof("propagating deployments history for all tenants")
+		l.Infof("processing the DBs of all tenants")
 
 		// infer if we're in ST or MT
 		tdbs, err := db.GetTenantDbs()
@@ -319,8 +381,8 @@ func selectDbs(db store.DataStore, tenant string) ([]string, error) {
 		}
 
 		if len(tdbs) == 0 {
-			l.Infof("no tenant DBs found - will try the default database %s", mongo.DbName)
-			dbs = []string{mongo.DbName}
+			l.Infof("no tenant DBs found - will try the default database %s", baseDb)
+			dbs = []string{baseDb}
 		} else {
 			dbs = tdbs
 		}
@@ -331,6 +393,7 @@ func selectDbs(db store.DataStore, tenant string) ([]string, error) {
 
 func tryPropagateReportingForDb(
 	db store.DataStore,
+	baseDb string,
 	wflows workflows.Client,
 	dbname string,
 	requestPeriod time.Duration,
@@ -340,7 +403,7 @@ func tryPropagateReportingForDb(
 
 	l.Infof("propagating deployments data to reporting from DB: %s", dbname)
 
-	tenant := mstore.TenantFromDbName(dbname, mongo.DbName)
+	tenant := mstore.TenantFromDbName(dbname, baseDb)
 
 	ctx := context.Background()
 	if tenant != "" {
@@ -409,3 +472,133 @@ func reindexDeploymentsReporting(
 	}
 	return nil
 }
+
+func cmdRebuildReleases(args *cli.Context) error {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Second*30,
+	)
+	defer cancel()
+	dbClient, err := mongo.NewMongoClient(ctx, config.Config)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dbClient.Disconnect(context.Background())
+	}()
+
+	baseDb := config.Config.GetString(dconfig.SettingDbName)
+	db := mongo.NewDataStoreMongoWithClient(dbClient).WithDbName(baseDb)
+
+	var requestPeriod time.Duration
+	if rateLimit := args.Uint("rate-limit"); rateLimit > 0 {
+		requestPeriod = time.Second / time.Duration(rateLimit)
+	}
+
+	err = rebuildReleases(
+		db,
+		baseDb,
+		args.String("tenant_id"),
+		requestPeriod,
+		args.Bool("dry-run"),
+	)
+	if err != nil {
+		return cli.NewExitError(err, 7)
+	}
+	return nil
+}
+
+func cmdReconcileDeviceCounts(args *cli.Context) error {
+	ctx := context.Background()
+	dbClient, err := mongo.NewMongoClient(ctx, config.Config)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dbClient.Disconnect(ctx)
+	}()
+
+	baseDb := config.Config.GetString(dconfig.SettingDbName)
+	db := mongo.NewDataStoreMongoWithClient(dbClient).WithDbName(baseDb)
+	err = app.NewDeployments(db, nil, 0, false).
+		WithDbName(baseDb).
+		ReconcileDeviceCounts(ctx, args.String("tenant_id"))
+	if err != nil {
+		return cli.NewExitError(err, 7)
+	}
+	return nil
+}
+
+func rebuildReleases(
+	db store.DataStore,
+	baseDb string,
+	tenant string,
+	requestPeriod time.Duration,
+	dryRun bool,
+) error {
+	l := log.NewEmpty()
+
+	dbs, err := selectDbs(db, baseDb, tenant)
+	if err != nil {
+		return errors.Wrap(err, "aborting")
+	}
+
+	var (
+		errReturned error
+		throttle    <-chan time.Time
+	)
+	if requestPeriod > 0 {
+		ticker := time.NewTicker(requestPeriod)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	for i, d := range dbs {
+		if i > 0 && throttle != nil {
+			<-throttle
+		}
+		err := rebuildReleasesForDb(db, baseDb, d, dryRun)
+		if err != nil {
+			errReturned = err
+			l.Errorf("giving up on DB %s due to fatal error: %s", d, err.Error())
+			continue
+		}
+	}
+
+	l.Info("all DBs processed, exiting.")
+	return errReturned
+}
+
+func rebuildReleasesForDb(db store.DataStore, baseDb, dbname string, dryRun bool) error {
+	l := log.NewEmpty()
+
+	l.Infof("rebuilding releases from DB: %s", dbname)
+
+	ctx := context.Background()
+	if tenant := mstore.TenantFromDbName(dbname, baseDb); tenant != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Tenant: tenant,
+		})
+	}
+
+	result, err := db.RebuildReleases(ctx, dryRun)
+	if err != nil {
+		return errors.Wrap(err, "failed to rebuild releases")
+	}
+	if !dryRun {
+		l.Infof("Done with DB %s: %d releases created, %d updated, %d removed",
+			dbname, len(result.Created), len(result.Updated), len(result.Removed))
+		return nil
+	}
+	for _, name := range result.Created {
+		l.Infof("release %q is missing", name)
+	}
+	for _, name := range result.Updated {
+		l.Infof("release %q is out of sync with its artifacts", name)
+	}
+	for _, name := range result.Removed {
+		l.Infof("release %q has no artifacts", name)
+	}
+	l.Infof("Done with DB %s: %d releases to create, %d to update, %d to remove",
+		dbname, len(result.Created), len(result.Updated), len(result.Removed))
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+)
+
+// ArtifactDeletion is the audit record of a deleted artifact. The records
+// are only ever inserted, and outlive the artifact and its file.
+type ArtifactDeletion struct {
+	ID           string `json:"id" bson:"_id"`
+	ArtifactID   string `json:"artifact_id" bson:"artifact_id"`
+	ArtifactName string `json:"artifact_name" bson:"artifact_name"`
+	// StorageKey is the key of the artifact file in the object storage.
+	StorageKey string `json:"storage_key" bson:"storage_key"`
+	// DeletedBy is the subject of the identity deleting the artifact.
+	DeletedBy string    `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+	Deleted   time.Time `json:"deleted" bson:"deleted"`
+}
+
+// NewArtifactDeletion returns the record of the deletion of the image by
+// the identity in the context.
+func NewArtifactDeletion(ctx context.Context, image *Image) *ArtifactDeletion {
+	uid, _ := uuid.NewRandom()
+	deletion := &ArtifactDeletion{
+		ID:         uid.String(),
+		ArtifactID: image.Id,
+		StorageKey: image.ObjectPath(ctx),
+		Deleted:    time.Now(),
+	}
+	if image.ArtifactMeta != nil {
+		deletion.ArtifactName = image.ArtifactMeta.Name
+	}
+	if idty := identity.FromContext(ctx); idty != nil {
+		deletion.DeletedBy = idty.Subject
+	}
+	return deletion
+}
+
+// ArtifactDeletionsQuery selects a page of the artifact deletion records,
+// latest first.
+type ArtifactDeletionsQuery struct {
+	// ArtifactName, if set, only selects the deletions of this artifact.
+	ArtifactName string
+	Skip         int
+	Limit        int
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewArtifactDeletion(t *testing.T) {
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "user-id",
+		Tenant:  "tenant-id",
+		IsUser:  true,
+	})
+	image := &Image{
+		Id:           "artifact-id",
+		ArtifactMeta: &ArtifactMeta{Name: "artifact"},
+	}
+
+	deletion := NewArtifactDeletion(ctx, image)
+	assert.NotEmpty(t, deletion.ID)
+	assert.Equal(t, "artifact-id", deletion.ArtifactID)
+	assert.Equal(t, "artifact", deletion.ArtifactName)
+	assert.Equal(t, "tenant-id/artifact-id", deletion.StorageKey)
+	assert.Equal(t, "user-id", deletion.DeletedBy)
+	assert.WithinDuration(t, time.Now(), deletion.Deleted, time.Minute)
+
+	deletion = NewArtifactDeletion(context.Background(), &Image{Id: "artifact-id"})
+	assert.Equal(t, "artifact-id", deletion.StorageKey)
+	assert.Empty(t, deletion.ArtifactName)
+	assert.Empty(t, deletion.DeletedBy)
+}
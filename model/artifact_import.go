@@ -0,0 +1,84 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	ArtifactImportMaxRateLimit = 100
+)
+
+type ArtifactImportStatus string
+
+const (
+	ArtifactImportStatusRunning  ArtifactImportStatus = "running"
+	ArtifactImportStatusFinished ArtifactImportStatus = "finished"
+	ArtifactImportStatusFailed   ArtifactImportStatus = "failed"
+)
+
+// ArtifactImportRequest describes a bulk import of artifacts already
+// present in the object storage.
+type ArtifactImportRequest struct {
+	// Prefix of the object keys to scan for artifacts
+	Prefix string `json:"prefix"`
+
+	// RateLimit is the maximum number of artifacts imported per second
+	RateLimit int `json:"rate_limit,omitempty"`
+}
+
+func (r ArtifactImportRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Prefix, validation.Required, lengthIn1To4096),
+		validation.Field(&r.RateLimit, validation.Min(0),
+			validation.Max(ArtifactImportMaxRateLimit)),
+	)
+}
+
+// ArtifactImportJob tracks the progress of a bulk artifact import.
+type ArtifactImportJob struct {
+	ID       string               `json:"id" bson:"_id"`
+	TenantID string               `json:"-" bson:"tenant_id"`
+	Prefix   string               `json:"prefix" bson:"prefix"`
+	Status   ArtifactImportStatus `json:"status" bson:"status"`
+
+	// Total number of artifact objects found under the prefix so far
+	Total int `json:"total" bson:"total"`
+	// Imported, Skipped (already present) and Failed artifact counters
+	Imported int `json:"imported" bson:"imported"`
+	Skipped  int `json:"skipped" bson:"skipped"`
+	Failed   int `json:"failed" bson:"failed"`
+
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+
+	Created time.Time `json:"created" bson:"created"`
+	Updated time.Time `json:"updated" bson:"updated"`
+}
+
+func NewArtifactImportJob(prefix string) *ArtifactImportJob {
+	now := time.Now()
+	uid, _ := uuid.NewRandom()
+	return &ArtifactImportJob{
+		ID:      uid.String(),
+		Prefix:  prefix,
+		Status:  ArtifactImportStatusRunning,
+		Created: now,
+		Updated: now,
+	}
+}
@@ -42,6 +42,13 @@ var (
 		"The deployment for group constructor should have neither list of devices" +
 			" nor all_devices flag set",
 	)
+	ErrInvalidDeploymentWithFilterDefinitionConflict = errors.New(
+		"The deployment with a filter should have neither list of devices" +
+			" nor all_devices flag set, nor target a group",
+	)
+	ErrEmptyDeploymentPatch = errors.New(
+		"The deployment patch should set the name or the description",
+	)
 )
 
 type DeploymentStatus string
@@ -51,9 +58,16 @@ const (
 	DeploymentStatusFinished   DeploymentStatus = "finished"
 	DeploymentStatusInProgress DeploymentStatus = "inprogress"
 	DeploymentStatusPending    DeploymentStatus = "pending"
+	// DeploymentStatusPaused is the status of the deployments created
+	// without starting them; the devices get them only once started.
+	DeploymentStatusPaused DeploymentStatus = "paused"
 
 	DeploymentTypeSoftware      DeploymentType = "software"
 	DeploymentTypeConfiguration DeploymentType = "configuration"
+
+	// DeploymentMaxRetries caps the number of times a failed device
+	// deployment is rescheduled.
+	DeploymentMaxRetries = 10
 )
 
 func (stat DeploymentStatus) Validate() error {
@@ -61,6 +75,7 @@ func (stat DeploymentStatus) Validate() error {
 		DeploymentStatusFinished,
 		DeploymentStatusInProgress,
 		DeploymentStatusPending,
+		DeploymentStatusPaused,
 	).Validate(stat)
 }
 
@@ -88,8 +103,50 @@ type DeploymentConstructor struct {
 	// `already-installed` check
 	ForceInstallation bool `json:"force_installation,omitempty" bson:"force_installation"`
 
+	// Retries is the number of times a device deployment is rescheduled
+	// after failing, before the failure becomes final.
+	Retries uint `json:"retries,omitempty" bson:"retries,omitempty"`
+
+	// DownloadLinkTTL is the validity, in seconds, of the artifact download
+	// links handed out to the devices; the server default applies if unset.
+	DownloadLinkTTL uint `json:"download_link_ttl,omitempty" bson:"download_link_ttl,omitempty"`
+
 	// When set the deployment will be created for all accepted devices from a given group
-	Group string `json:"-" bson:"-"`
+	Group string `json:"-" bson:"group,omitempty"`
+
+	// ID, when set, is the ID of the deployment instead of a random one:
+	// creating the same deployment twice then conflicts.
+	ID string `json:"-" bson:"-"`
+
+	// ExpectedArtifacts, when set, is a precondition on the creation: at
+	// least one of the artifacts currently named ArtifactName must have one
+	// of these IDs; "*" matches any artifact.
+	ExpectedArtifacts []string `json:"-" bson:"-"`
+
+	// When set the deployment will be created for all accepted devices
+	// matching every predicate of the inventory filter; the filter is kept
+	// on the deployment for reference, the devices are resolved once, on
+	// creation.
+	Filter []FilterPredicate `json:"filter,omitempty" bson:"filter,omitempty"`
+
+	// Phases, when set, roll the deployment out in batches of devices
+	// following the schedule.
+	Phases DeploymentPhases `json:"phases,omitempty" bson:"phases,omitempty"`
+
+	// MaxConcurrent, when set, caps the number of devices updating at the
+	// same time; the other devices get the deployment as the updating ones
+	// finish.
+	MaxConcurrent uint `json:"max_concurrent,omitempty" bson:"max_concurrent,omitempty"`
+
+	// StartImmediately, when set to false, creates the deployment paused:
+	// no device gets it until it is started. Unset means true.
+	StartImmediately *bool `json:"start_immediately,omitempty" bson:"-"`
+
+	// RollbackOnFailurePercent, when set, aborts the deployment as soon as
+	// more than this percentage of its devices failed, and deploys back to
+	// the updated devices the artifact they had installed before.
+	//nolint:lll
+	RollbackOnFailurePercent uint `json:"rollback_on_failure_percent,omitempty" bson:"rollback_on_failure_percent,omitempty"`
 }
 
 // Validate checks structure according to valid tags
@@ -99,6 +156,10 @@ func (c DeploymentConstructor) Validate() error {
 		validation.Field(&c.Name, validation.Required, lengthIn1To4096),
 		validation.Field(&c.ArtifactName, validation.Required, lengthIn1To4096),
 		validation.Field(&c.Devices, validation.Each(validation.Required)),
+		validation.Field(&c.Retries, validation.Max(uint(DeploymentMaxRetries))),
+		validation.Field(&c.RollbackOnFailurePercent, validation.Max(uint(100))),
+		validation.Field(&c.Filter),
+		validation.Field(&c.Phases),
 	)
 }
 
@@ -107,7 +168,11 @@ func (c DeploymentConstructor) ValidateNew() error {
 		return err
 	}
 
-	if len(c.Group) == 0 {
+	if len(c.Filter) > 0 {
+		if len(c.Devices) > 0 || c.AllDevices || len(c.Group) > 0 {
+			return ErrInvalidDeploymentWithFilterDefinitionConflict
+		}
+	} else if len(c.Group) == 0 {
 		if len(c.Devices) == 0 && !c.AllDevices {
 			return ErrInvalidDeploymentDefinitionNoDevices
 		}
@@ -122,6 +187,38 @@ func (c DeploymentConstructor) ValidateNew() error {
 	return nil
 }
 
+// DeploymentPreview describes the devices a deployment would target.
+type DeploymentPreview struct {
+	// DeviceCount is the number of devices the deployment would target.
+	DeviceCount int `json:"device_count"`
+
+	// Devices is a sample of the IDs of the targeted devices.
+	Devices []string `json:"devices"`
+}
+
+// StartsPaused tells whether the deployment is to be created without
+// starting it.
+func (c DeploymentConstructor) StartsPaused() bool {
+	return c.StartImmediately != nil && !*c.StartImmediately
+}
+
+// RemoveDuplicateDevices drops the repeated device IDs from the device list,
+// keeping the first occurrence of each, and returns the number of IDs removed.
+func (c *DeploymentConstructor) RemoveDuplicateDevices() int {
+	seen := make(map[string]struct{}, len(c.Devices))
+	devices := c.Devices[:0]
+	for _, device := range c.Devices {
+		if _, ok := seen[device]; ok {
+			continue
+		}
+		seen[device] = struct{}{}
+		devices = append(devices, device)
+	}
+	removed := len(c.Devices) - len(devices)
+	c.Devices = devices
+	return removed
+}
+
 func (c DeploymentConstructor) Checksum() string {
 	json, err := json.Marshal(c)
 	if err == nil {
@@ -130,6 +227,62 @@ func (c DeploymentConstructor) Checksum() string {
 	return ""
 }
 
+// DeploymentRollback records the automatic rollback of a deployment.
+type DeploymentRollback struct {
+	// Triggered is when the failure threshold was crossed.
+	Triggered time.Time `json:"triggered" bson:"triggered"`
+
+	// Deployments are the follow-up deployments of the artifacts the
+	// updated devices had installed before, one per artifact.
+	Deployments []string `json:"deployments" bson:"deployments"`
+
+	// Finished is when the follow-up deployments were created; until
+	// then the rollback is retried.
+	Finished *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+}
+
+// DeploymentPatch holds the deployment fields editable after creation;
+// the fields left nil are not changed.
+type DeploymentPatch struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+func (p DeploymentPatch) Validate() error {
+	if p.Name == nil && p.Description == nil {
+		return ErrEmptyDeploymentPatch
+	}
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Name, validation.NilOrNotEmpty, lengthIn1To4096),
+		validation.Field(&p.Description, lengthLessThan4096),
+	)
+}
+
+// AbortedDeployments counts the deployments aborted at once and their
+// device deployments aborted along.
+type AbortedDeployments struct {
+	Deployments int   `json:"deployments"`
+	Devices     int64 `json:"devices"`
+}
+
+// DeploymentReport is the reporting view of a deployment: its status and
+// device status counters, without the list of targeted devices.
+type DeploymentReport struct {
+	ID           string           `json:"id" bson:"_id"`
+	Name         string           `json:"name" bson:"name"`
+	ArtifactName string           `json:"artifact_name" bson:"artifact_name"`
+	Type         DeploymentType   `json:"type" bson:"type"`
+	Status       DeploymentStatus `json:"status" bson:"status"`
+	Created      time.Time        `json:"created" bson:"created"`
+	Finished     *time.Time       `json:"finished,omitempty" bson:"finished,omitempty"`
+	DeviceCount  int              `json:"device_count" bson:"device_count"`
+	MaxDevices   int              `json:"max_devices" bson:"max_devices"`
+	Stats        Stats            `json:"statistics" bson:"stats"`
+	CreatedBy    string           `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	AbortedBy    string           `json:"aborted_by,omitempty" bson:"aborted_by,omitempty"`
+	Aborted      *time.Time       `json:"aborted,omitempty" bson:"aborted,omitempty"`
+}
+
 type DeploymentStatistics struct {
 	Status    Stats `json:"status" bson:"-"`
 	TotalSize int   `json:"total_size" bson:"total_size"`
@@ -142,12 +295,33 @@ type Deployment struct {
 	// Set the DeploymentConstructor checksum
 	DeploymentConstructorChecksum string `json:"-" bson:"deploymentconstructor_checksum,omitempty"`
 
+	// Description is a free-form text set after the creation.
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+
 	// Auto set on create, required
 	Created *time.Time `json:"created"`
 
+	// Started is when a deployment created paused was started.
+	Started *time.Time `json:"started,omitempty" bson:"started,omitempty"`
+
 	// Finished deployment time
 	Finished *time.Time `json:"finished,omitempty"`
 
+	// CreatedBy is the subject of the identity which created the
+	// deployment, if known.
+	CreatedBy string `json:"created_by,omitempty" bson:"created_by,omitempty"`
+
+	// AbortedBy and Aborted record who aborted the deployment, and when.
+	AbortedBy string     `json:"aborted_by,omitempty" bson:"aborted_by,omitempty"`
+	Aborted   *time.Time `json:"aborted,omitempty" bson:"aborted,omitempty"`
+
+	// Set when the deployment is deleted; deleted deployments can be
+	// restored until purged.
+	Deleted *time.Time `json:"deleted,omitempty" bson:"deleted,omitempty"`
+
+	// Rollback is set once the deployment crossed its failure threshold.
+	Rollback *DeploymentRollback `json:"rollback,omitempty" bson:"rollback,omitempty"`
+
 	// Deployment id, required
 	Id string `json:"id" bson:"_id"`
 
@@ -167,6 +341,10 @@ type Deployment struct {
 	// Active is true for unfinished deployments
 	Active bool `json:"-" bson:"active"`
 
+	// CurrentPhase is the index of the phase of a phased deployment
+	// in progress, -1 until the first phase starts.
+	CurrentPhase *int `json:"current_phase,omitempty" bson:"current_phase,omitempty"`
+
 	// Number of devices being part of the deployment
 	DeviceCount *int `json:"device_count" bson:"device_count"`
 
@@ -192,6 +370,39 @@ type Deployment struct {
 	Configuration deploymentConfiguration `json:"configuration,omitempty" bson:"configuration"`
 }
 
+// DeploymentSummary is the condensed representation of a deployment.
+type DeploymentSummary struct {
+	Id           string           `json:"id"`
+	Name         string           `json:"name"`
+	ArtifactName string           `json:"artifact_name"`
+	Type         DeploymentType   `json:"type"`
+	Status       DeploymentStatus `json:"status"`
+	Created      *time.Time       `json:"created"`
+	Finished     *time.Time       `json:"finished,omitempty"`
+	Deleted      *time.Time       `json:"deleted,omitempty"`
+	DeviceCount  *int             `json:"device_count"`
+}
+
+func (d *Deployment) Summary() DeploymentSummary {
+	summary := DeploymentSummary{
+		Id:          d.Id,
+		Type:        d.Type,
+		Status:      d.Status,
+		Created:     d.Created,
+		Finished:    d.Finished,
+		Deleted:     d.Deleted,
+		DeviceCount: d.DeviceCount,
+	}
+	if d.DeploymentConstructor != nil {
+		summary.Name = d.Name
+		summary.ArtifactName = d.ArtifactName
+	}
+	if summary.Type == "" {
+		summary.Type = DeploymentTypeSoftware
+	}
+	return summary
+}
+
 type DeploymentArtifactsUpdate struct {
 	// List of artifact id's targeted for deployments, optional
 	Artifacts []string `bson:"artifacts"`
@@ -223,8 +434,14 @@ func NewDeploymentFromConstructor(constructor *DeploymentConstructor) (*Deployme
 	deployment.DeploymentConstructor = constructor
 	if constructor != nil {
 		deployment.DeploymentConstructorChecksum = constructor.Checksum()
+		if constructor.ID != "" {
+			deployment.Id = constructor.ID
+		}
 	}
 	deployment.Status = DeploymentStatusPending
+	if constructor != nil && constructor.StartsPaused() {
+		deployment.Status = DeploymentStatusPaused
+	}
 
 	deviceCount := 0
 	deployment.DeviceCount = &deviceCount
@@ -232,6 +449,15 @@ func NewDeploymentFromConstructor(constructor *DeploymentConstructor) (*Deployme
 	return deployment, nil
 }
 
+// ActiveSince returns when the devices could first get the deployment: its
+// start if it was created paused, its creation otherwise.
+func (d *Deployment) ActiveSince() *time.Time {
+	if d.Started != nil {
+		return d.Started
+	}
+	return d.Created
+}
+
 // Validate checks structure validation rules
 func (d Deployment) Validate() error {
 	return validation.ValidateStruct(&d,
@@ -276,6 +502,7 @@ func (d *Deployment) IsNotPending() bool {
 	if d.Stats[DeviceDeploymentStatusDownloadingStr] > 0 ||
 		d.Stats[DeviceDeploymentStatusInstallingStr] > 0 ||
 		d.Stats[DeviceDeploymentStatusRebootingStr] > 0 ||
+		d.Stats[DeviceDeploymentStatusAwaitingConfirmationStr] > 0 ||
 		d.Stats[DeviceDeploymentStatusSuccessStr] > 0 ||
 		d.Stats[DeviceDeploymentStatusAlreadyInstStr] > 0 ||
 		d.Stats[DeviceDeploymentStatusFailureStr] > 0 ||
@@ -291,6 +518,11 @@ func (d *Deployment) IsNotPending() bool {
 	return false
 }
 
+// IsPhased tells whether the deployment is rolled out in phases.
+func (d *Deployment) IsPhased() bool {
+	return d.DeploymentConstructor != nil && len(d.Phases) > 0
+}
+
 func (d *Deployment) IsFinished() bool {
 	if d.Finished != nil ||
 		d.MaxDevices > 0 && ((d.Stats[DeviceDeploymentStatusAlreadyInstStr]+
@@ -305,6 +537,18 @@ func (d *Deployment) IsFinished() bool {
 	return false
 }
 
+// FailureThresholdCrossed tells whether more than RollbackOnFailurePercent
+// of the devices of the deployment failed; always false without threshold.
+func (d *Deployment) FailureThresholdCrossed() bool {
+	if d.DeploymentConstructor == nil ||
+		d.RollbackOnFailurePercent == 0 ||
+		d.MaxDevices <= 0 {
+		return false
+	}
+	failed := d.Stats[DeviceDeploymentStatusFailureStr]
+	return failed*100 > int(d.RollbackOnFailurePercent)*d.MaxDevices
+}
+
 func (d *Deployment) GetStatus() DeploymentStatus {
 	if d.IsFinished() {
 		return DeploymentStatusFinished
@@ -323,6 +567,7 @@ const (
 	StatusQueryInProgress
 	StatusQueryFinished
 	StatusQueryAborted
+	StatusQueryPaused
 
 	SortDirectionAscending  = "asc"
 	SortDirectionDescending = "desc"
@@ -336,6 +581,9 @@ type Query struct {
 	// match deployments by text by looking at deployment name and artifact name
 	SearchText string
 
+	// match deployments with the device in the device list
+	DeviceID string
+
 	// deployment type
 	Type DeploymentType
 
@@ -352,6 +600,9 @@ type Query struct {
 
 	// disable the counting
 	DisableCount bool
+
+	// include deleted deployments
+	IncludeDeleted bool
 }
 
 type DeploymentIDs struct {
@@ -370,3 +621,48 @@ type DeploymentStats struct {
 	ID    string `json:"id" bson:"_id"`
 	Stats Stats  `json:"stats" bson:"stats"`
 }
+
+// DeviceCountReconciliation is the outcome of recounting the devices of a
+// deployment.
+type DeviceCountReconciliation struct {
+	DeploymentID string `json:"deployment_id"`
+
+	// PreviousDeviceCount is the device count recorded before the
+	// reconciliation, nil if it was not set.
+	PreviousDeviceCount *int `json:"previous_device_count"`
+
+	// DeviceCount is the number of device deployments of the deployment.
+	DeviceCount int `json:"device_count"`
+
+	// Corrected tells whether the recorded device count was updated.
+	Corrected bool `json:"corrected"`
+}
+
+// TenantActiveDeployments is the number of active deployments of a tenant.
+type TenantActiveDeployments struct {
+	// TenantID is empty for the default database.
+	TenantID string `json:"tenant_id"`
+
+	ActiveCount int64 `json:"active_count"`
+}
+
+// TenantActiveDeploymentsQuery selects a page of the tenants, ordered by
+// tenant ID, to count the active deployments of.
+type TenantActiveDeploymentsQuery struct {
+	// After is the tenant the page starts after.
+	After string
+	Limit int
+	// RateLimit is the number of tenants counted per second at most.
+	RateLimit int
+	// SkipEmpty leaves out the tenants without active deployments.
+	SkipEmpty bool
+}
+
+// TenantActiveDeploymentsPage is a page of the tenants with the number of
+// their active deployments.
+type TenantActiveDeploymentsPage struct {
+	Tenants []TenantActiveDeployments `json:"tenants"`
+	// Next is the tenant to pass as after to get the next page, empty on
+	// the last page.
+	Next string `json:"next,omitempty"`
+}
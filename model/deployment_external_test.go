@@ -28,12 +28,22 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 
 	t.Parallel()
 
+	filter := []FilterPredicate{{
+		Scope:     "inventory",
+		Attribute: "device_type",
+		Type:      "$eq",
+		Value:     "foo",
+	}}
+
 	testCases := []struct {
 		InputName         string
 		InputArtifactName string
 		InputDevices      []string
 		InputAllDevices   bool
 		InputGroup        string
+		InputRetries      uint
+		InputFilter       []FilterPredicate
+		InputRollback     uint
 		IsValid           bool
 	}{
 		{
@@ -119,6 +129,82 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 			InputAllDevices:   true,
 			IsValid:           false,
 		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			InputRetries:      DeploymentMaxRetries,
+			IsValid:           true,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			InputRetries:      DeploymentMaxRetries + 1,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			InputRollback:     100,
+			IsValid:           true,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			InputRollback:     101,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputFilter:       filter,
+			IsValid:           true,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			InputFilter:       filter,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputAllDevices:   true,
+			InputFilter:       filter,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputGroup:        "foo",
+			InputFilter:       filter,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputFilter: []FilterPredicate{{
+				Scope:     "inventory",
+				Attribute: "device_type",
+				Type:      "$like",
+				Value:     "foo",
+			}},
+			IsValid: false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputFilter: []FilterPredicate{{
+				Scope: "inventory",
+				Type:  "$eq",
+				Value: "foo",
+			}},
+			IsValid: false,
+		},
 	}
 
 	for _, test := range testCases {
@@ -129,6 +215,9 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 		dep.Devices = test.InputDevices
 		dep.Group = test.InputGroup
 		dep.AllDevices = test.InputAllDevices
+		dep.Retries = test.InputRetries
+		dep.Filter = test.InputFilter
+		dep.RollbackOnFailurePercent = test.InputRollback
 
 		err := dep.ValidateNew()
 
@@ -156,6 +245,21 @@ func TestNewDeploymentFromConstructor(t *testing.T) {
 
 	assert.NotNil(t, dep)
 	assert.Equal(t, con, dep.DeploymentConstructor)
+	assert.Equal(t, DeploymentStatusPending, dep.Status)
+
+	start := true
+	dep, err = NewDeploymentFromConstructor(&DeploymentConstructor{
+		StartImmediately: &start,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DeploymentStatusPending, dep.Status)
+
+	start = false
+	dep, err = NewDeploymentFromConstructor(&DeploymentConstructor{
+		StartImmediately: &start,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DeploymentStatusPaused, dep.Status)
 }
 
 func TestDeploymentValidate(t *testing.T) {
@@ -244,6 +348,104 @@ func TestDeploymentConstructorChecksum(t *testing.T) {
 
 }
 
+func TestDeploymentConstructorRemoveDuplicateDevices(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		InputDevices []string
+		Devices      []string
+		Removed      int
+	}{
+		{
+			InputDevices: nil,
+			Devices:      nil,
+		},
+		{
+			InputDevices: []string{"a", "b", "c"},
+			Devices:      []string{"a", "b", "c"},
+		},
+		{
+			InputDevices: []string{"a", "b", "a", "c", "b", "a"},
+			Devices:      []string{"a", "b", "c"},
+			Removed:      3,
+		},
+	}
+
+	for i, test := range testCases {
+		t.Run(fmt.Sprintf("test #%d", i), func(t *testing.T) {
+			c := &DeploymentConstructor{Devices: test.InputDevices}
+			assert.Equal(t, test.Removed, c.RemoveDuplicateDevices())
+			assert.Equal(t, test.Devices, c.Devices)
+		})
+	}
+}
+
+func TestDeploymentFailureThresholdCrossed(t *testing.T) {
+	t.Parallel()
+
+	newDeployment := func(threshold uint, failed int) *Deployment {
+		return &Deployment{
+			DeploymentConstructor: &DeploymentConstructor{
+				RollbackOnFailurePercent: threshold,
+			},
+			MaxDevices: 8,
+			Stats:      Stats{DeviceDeploymentStatusFailureStr: failed},
+		}
+	}
+
+	assert.False(t, newDeployment(0, 8).FailureThresholdCrossed(),
+		"no threshold")
+	assert.False(t, newDeployment(25, 2).FailureThresholdCrossed(),
+		"at the threshold")
+	assert.True(t, newDeployment(25, 3).FailureThresholdCrossed(),
+		"above the threshold")
+	assert.False(t, newDeployment(100, 8).FailureThresholdCrossed(),
+		"every device failing does not exceed 100%")
+	assert.False(t, (&Deployment{MaxDevices: 8}).FailureThresholdCrossed(),
+		"without constructor")
+}
+
+func TestDeploymentPatchValidate(t *testing.T) {
+	t.Parallel()
+
+	str := func(s string) *string { return &s }
+	testCases := map[string]struct {
+		patch DeploymentPatch
+		err   string
+	}{
+		"ok, name": {
+			patch: DeploymentPatch{Name: str("NYC Production")},
+		},
+		"ok, clear description": {
+			patch: DeploymentPatch{Description: str("")},
+		},
+		"error, empty": {
+			err: ErrEmptyDeploymentPatch.Error(),
+		},
+		"error, empty name": {
+			patch: DeploymentPatch{Name: str(""), Description: str("foo")},
+			err:   "name: cannot be blank.",
+		},
+		"error, description too long": {
+			patch: DeploymentPatch{Description: str(string(make([]byte, 4097)))},
+			err:   "description: the length must be no more than 4096.",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.patch.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestDeploymentMarshalJSON(t *testing.T) {
 
 	t.Parallel()
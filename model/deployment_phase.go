@@ -0,0 +1,140 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPhasesBatchSizeTotal = errors.New(
+		"the batch sizes must add up to 100 percent",
+	)
+	ErrPhasesBatchSizeMissing = errors.New(
+		"only the last phase may omit the batch size",
+	)
+	ErrPhasesStartMissing = errors.New(
+		"only the first phase may omit the start time",
+	)
+	ErrPhasesStartOrder = errors.New(
+		"the phases must start in chronological order",
+	)
+)
+
+// DeploymentPhase is a step of a phased rollout: when the phase starts, the
+// next batch of the targeted devices, in the order of the device list, may
+// pick up the deployment.
+type DeploymentPhase struct {
+	// BatchSizePercent is the share of the targeted devices joining the
+	// deployment in this phase. The last phase may leave it unset to take
+	// all the remaining devices.
+	BatchSizePercent uint `json:"batch_size_percent,omitempty" bson:"batch_size_percent,omitempty"`
+
+	// StartTs is the time the phase starts. The first phase starts when
+	// the deployment is created unless set.
+	StartTs *time.Time `json:"start_ts,omitempty" bson:"start_ts,omitempty"`
+
+	// DeviceCount is the number of devices in the batch, computed when
+	// the deployment is created.
+	DeviceCount int `json:"device_count" bson:"device_count"`
+}
+
+func (p DeploymentPhase) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.BatchSizePercent, validation.Max(uint(100))),
+	)
+}
+
+// DeploymentPhases is the schedule of a phased rollout.
+type DeploymentPhases []DeploymentPhase
+
+func (phases DeploymentPhases) Validate() error {
+	var total uint
+	for i, phase := range phases {
+		if err := phase.Validate(); err != nil {
+			return errors.Wrapf(err, "phase %d", i)
+		}
+		last := i == len(phases)-1
+		if phase.BatchSizePercent == 0 && !last {
+			return ErrPhasesBatchSizeMissing
+		}
+		if i > 0 {
+			if phase.StartTs == nil {
+				return ErrPhasesStartMissing
+			}
+			prev := phases[i-1].StartTs
+			if prev != nil && !phase.StartTs.After(*prev) {
+				return ErrPhasesStartOrder
+			}
+		}
+		total += phase.BatchSizePercent
+	}
+	last := len(phases) - 1
+	if total > 100 ||
+		(len(phases) > 0 && phases[last].BatchSizePercent > 0 && total != 100) {
+		return ErrPhasesBatchSizeTotal
+	}
+	return nil
+}
+
+// Schedule completes the phases of a deployment created at the given time
+// and targeting the given number of devices: the first phase starts on
+// creation if unset, and the devices are split into batches, rounding up
+// so that no phase but the last ends up empty because of the rounding.
+func (phases DeploymentPhases) Schedule(created time.Time, devices int) {
+	if len(phases) == 0 {
+		return
+	}
+	if phases[0].StartTs == nil {
+		phases[0].StartTs = &created
+	}
+	var percent uint
+	allocated := 0
+	for i := range phases {
+		percent += phases[i].BatchSizePercent
+		upTo := (devices*int(percent) + 99) / 100
+		if upTo > devices || i == len(phases)-1 {
+			upTo = devices
+		}
+		phases[i].DeviceCount = upTo - allocated
+		allocated = upTo
+	}
+}
+
+// Active returns the index of the latest phase started at the given time,
+// or -1 if the rollout has not started yet.
+func (phases DeploymentPhases) Active(now time.Time) int {
+	active := -1
+	for i, phase := range phases {
+		if phase.StartTs != nil && phase.StartTs.After(now) {
+			break
+		}
+		active = i
+	}
+	return active
+}
+
+// DevicesUpTo returns the number of devices, from the start of the device
+// list, that may pick up the deployment once the phase has started.
+func (phases DeploymentPhases) DevicesUpTo(phase int) int {
+	count := 0
+	for i := 0; i <= phase && i < len(phases); i++ {
+		count += phases[i].DeviceCount
+	}
+	return count
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeploymentPhasesValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+	latest := now.Add(2 * time.Hour)
+
+	testCases := map[string]struct {
+		phases DeploymentPhases
+		err    error
+	}{
+		"ok": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 5},
+				{BatchSizePercent: 20, StartTs: &later},
+				{BatchSizePercent: 75, StartTs: &latest},
+			},
+		},
+		"ok, remaining devices in the last phase": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 5, StartTs: &now},
+				{StartTs: &later},
+			},
+		},
+		"error, batch sizes below 100 percent": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 5},
+				{BatchSizePercent: 20, StartTs: &later},
+			},
+			err: ErrPhasesBatchSizeTotal,
+		},
+		"error, batch sizes over 100 percent": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 50},
+				{BatchSizePercent: 60, StartTs: &later},
+			},
+			err: ErrPhasesBatchSizeTotal,
+		},
+		"error, batch size missing": {
+			phases: DeploymentPhases{
+				{},
+				{BatchSizePercent: 60, StartTs: &later},
+			},
+			err: ErrPhasesBatchSizeMissing,
+		},
+		"error, start time missing": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 10},
+				{},
+			},
+			err: ErrPhasesStartMissing,
+		},
+		"error, start times out of order": {
+			phases: DeploymentPhases{
+				{BatchSizePercent: 10, StartTs: &later},
+				{StartTs: &now},
+			},
+			err: ErrPhasesStartOrder,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.phases.Validate()
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	err := DeploymentPhases{{BatchSizePercent: 101}}.Validate()
+	assert.EqualError(t, err, "phase 0: batch_size_percent: must be no greater than 100.")
+}
+
+func TestDeploymentPhasesSchedule(t *testing.T) {
+	t.Parallel()
+
+	created := time.Now()
+	later := created.Add(time.Hour)
+	latest := created.Add(2 * time.Hour)
+	phases := DeploymentPhases{
+		{BatchSizePercent: 5},
+		{BatchSizePercent: 25, StartTs: &later},
+		{StartTs: &latest},
+	}
+
+	phases.Schedule(created, 10)
+	assert.Equal(t, &created, phases[0].StartTs)
+	// 5 percent of 10 devices rounds up to one device
+	assert.Equal(t, 1, phases[0].DeviceCount)
+	assert.Equal(t, 2, phases[1].DeviceCount)
+	assert.Equal(t, 7, phases[2].DeviceCount)
+
+	assert.Equal(t, -1, phases.Active(created.Add(-time.Second)))
+	assert.Equal(t, 0, phases.Active(created))
+	assert.Equal(t, 1, phases.Active(later.Add(time.Minute)))
+	assert.Equal(t, 2, phases.Active(latest))
+
+	assert.Equal(t, 0, phases.DevicesUpTo(-1))
+	assert.Equal(t, 1, phases.DevicesUpTo(0))
+	assert.Equal(t, 3, phases.DevicesUpTo(1))
+	assert.Equal(t, 10, phases.DevicesUpTo(2))
+}
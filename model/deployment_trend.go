@@ -0,0 +1,46 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// TrendGranularity is the width of the time buckets of a trend.
+type TrendGranularity string
+
+const (
+	TrendGranularityHour TrendGranularity = "hour"
+	TrendGranularityDay  TrendGranularity = "day"
+	// Weeks start on Monday.
+	TrendGranularityWeek TrendGranularity = "week"
+)
+
+func (g TrendGranularity) Validate() error {
+	return validation.In(
+		TrendGranularityHour,
+		TrendGranularityDay,
+		TrendGranularityWeek,
+	).Validate(g)
+}
+
+// TrendBucket holds the number of events which occurred in the time bucket
+// starting at Time.
+type TrendBucket struct {
+	Time  time.Time `json:"time" bson:"_id"`
+	Count int       `json:"count" bson:"count"`
+}
@@ -60,22 +60,29 @@ const (
 	// DeviceDeploymentStatusNew = (DeviceDeploymentStatusSuccess +
 	// DeviceDeploymentStatusNoArtifact) / 2
 
+	// DeviceDeploymentStatusAwaitingConfirmation is set instead of success
+	// on devices reporting success right after rebooting, when the update
+	// has to be confirmed by a further status report.
+	DeviceDeploymentStatusAwaitingConfirmation = (DeviceDeploymentStatusRebooting +
+		DeviceDeploymentStatusPending) / 2
+
 	DeviceDeploymentStatusActiveLow  = DeviceDeploymentStatusPauseBeforeInstall
 	DeviceDeploymentStatusActiveHigh = DeviceDeploymentStatusPending
 
-	DeviceDeploymentStatusFailureStr            = "failure"
-	DeviceDeploymentStatusAbortedStr            = "aborted"
-	DeviceDeploymentStatusPauseBeforeInstallStr = "pause_before_installing"
-	DeviceDeploymentStatusPauseBeforeCommitStr  = "pause_before_committing"
-	DeviceDeploymentStatusPauseBeforeRebootStr  = "pause_before_rebooting"
-	DeviceDeploymentStatusDownloadingStr        = "downloading"
-	DeviceDeploymentStatusInstallingStr         = "installing"
-	DeviceDeploymentStatusRebootingStr          = "rebooting"
-	DeviceDeploymentStatusPendingStr            = "pending"
-	DeviceDeploymentStatusSuccessStr            = "success"
-	DeviceDeploymentStatusNoArtifactStr         = "noartifact"
-	DeviceDeploymentStatusAlreadyInstStr        = "already-installed"
-	DeviceDeploymentStatusDecommissionedStr     = "decommissioned"
+	DeviceDeploymentStatusFailureStr              = "failure"
+	DeviceDeploymentStatusAbortedStr              = "aborted"
+	DeviceDeploymentStatusPauseBeforeInstallStr   = "pause_before_installing"
+	DeviceDeploymentStatusPauseBeforeCommitStr    = "pause_before_committing"
+	DeviceDeploymentStatusPauseBeforeRebootStr    = "pause_before_rebooting"
+	DeviceDeploymentStatusDownloadingStr          = "downloading"
+	DeviceDeploymentStatusInstallingStr           = "installing"
+	DeviceDeploymentStatusRebootingStr            = "rebooting"
+	DeviceDeploymentStatusPendingStr              = "pending"
+	DeviceDeploymentStatusSuccessStr              = "success"
+	DeviceDeploymentStatusNoArtifactStr           = "noartifact"
+	DeviceDeploymentStatusAlreadyInstStr          = "already-installed"
+	DeviceDeploymentStatusDecommissionedStr       = "decommissioned"
+	DeviceDeploymentStatusAwaitingConfirmationStr = "awaiting_confirmation"
 	// DeviceDeploymentStatusNew = "lorem-ipsum"
 )
 
@@ -105,6 +112,7 @@ var allStatuses = []DeviceDeploymentStatus{
 	DeviceDeploymentStatusNoArtifact,
 	DeviceDeploymentStatusAlreadyInst,
 	DeviceDeploymentStatusDecommissioned,
+	DeviceDeploymentStatusAwaitingConfirmation,
 	// DeviceDeploymentStatusNew
 }
 
@@ -136,6 +144,8 @@ func (stat DeviceDeploymentStatus) MarshalText() ([]byte, error) {
 		return []byte(DeviceDeploymentStatusAlreadyInstStr), nil
 	case DeviceDeploymentStatusDecommissioned:
 		return []byte(DeviceDeploymentStatusDecommissionedStr), nil
+	case DeviceDeploymentStatusAwaitingConfirmation:
+		return []byte(DeviceDeploymentStatusAwaitingConfirmationStr), nil
 	//case DeviceDeploymentStatusNew:
 	//	return []byte(DeviceDeploymentStatusNewStr), nil
 	case 0:
@@ -181,6 +191,8 @@ func (stat *DeviceDeploymentStatus) UnmarshalText(b []byte) error {
 		*stat = DeviceDeploymentStatusAlreadyInst
 	case DeviceDeploymentStatusDecommissionedStr:
 		*stat = DeviceDeploymentStatusDecommissioned
+	case DeviceDeploymentStatusAwaitingConfirmationStr:
+		*stat = DeviceDeploymentStatusAwaitingConfirmation
 	//case DeviceDeploymentStatusNewStr:
 	//	*stat = DeviceDeploymentStatusNew
 	default:
@@ -203,6 +215,8 @@ type DeviceDeploymentState struct {
 	SubState string `json:",omitempty" bson:",omitempty"`
 	// finish time
 	FinishTime *time.Time `json:",omitempty" bson:",omitempty"`
+	// time by which a device awaiting confirmation must confirm the update
+	ConfirmationDeadline *time.Time `json:",omitempty" bson:",omitempty"`
 }
 
 func (state DeviceDeploymentState) Validate() error {
@@ -251,6 +265,28 @@ type DeviceDeployment struct {
 
 	// Device reported substate
 	SubState string `json:"substate,omitempty" bson:"substate,omitempty"`
+
+	// SubStateHistory lists the latest statuses and substates reported by
+	// the device, from the oldest.
+	SubStateHistory []SubStateHistoryEntry `json:"substate_history,omitempty" bson:"substate_history,omitempty"`
+
+	// Attempts counts the retries of the deployment after failures
+	Attempts uint `json:"attempts,omitempty" bson:"attempts,omitempty"`
+
+	// ConfirmationDeadline is the time by which a device awaiting
+	// confirmation must confirm the update before it is marked as failed.
+	ConfirmationDeadline *time.Time `json:"confirmation_deadline,omitempty" bson:"confirmation_deadline,omitempty"`
+
+	// LastUpdated is the time of the latest status report of the device,
+	// including reports repeating the current status.
+	LastUpdated *time.Time `json:"last_updated,omitempty" bson:"last_updated,omitempty"`
+}
+
+// SubStateHistoryEntry records a status report of the device.
+type SubStateHistoryEntry struct {
+	Status    DeviceDeploymentStatus `json:"status" bson:"status"`
+	SubState  string                 `json:"substate,omitempty" bson:"substate,omitempty"`
+	Timestamp time.Time              `json:"timestamp" bson:"timestamp"`
 }
 
 func NewDeviceDeployment(deviceId, deploymentId string) *DeviceDeployment {
@@ -329,6 +365,15 @@ func (s Stats) Get(status DeviceDeploymentStatus) int {
 	return s[key]
 }
 
+// Active returns the number of device deployments not finished yet.
+func (s Stats) Active() int {
+	var count int
+	for _, status := range ActiveDeploymentStatuses() {
+		count += s.Get(status)
+	}
+	return count
+}
+
 func IsDeviceDeploymentStatusFinished(status DeviceDeploymentStatus) bool {
 	if status == DeviceDeploymentStatusFailure || status == DeviceDeploymentStatusSuccess ||
 		status == DeviceDeploymentStatusNoArtifact || status == DeviceDeploymentStatusAlreadyInst ||
@@ -345,6 +390,7 @@ func ActiveDeploymentStatuses() []DeviceDeploymentStatus {
 		DeviceDeploymentStatusDownloading,
 		DeviceDeploymentStatusInstalling,
 		DeviceDeploymentStatusRebooting,
+		DeviceDeploymentStatusAwaitingConfirmation,
 		DeviceDeploymentStatusPauseBeforeInstall,
 		DeviceDeploymentStatusPauseBeforeCommit,
 		DeviceDeploymentStatusPauseBeforeReboot,
@@ -140,12 +140,22 @@ func TestDeviceDeploymentStats(t *testing.T) {
 		DeviceDeploymentStatusDownloadingStr,
 		DeviceDeploymentStatusAlreadyInstStr,
 		DeviceDeploymentStatusAbortedStr,
+		DeviceDeploymentStatusAwaitingConfirmationStr,
 	}
 	for _, f := range must {
 		assert.Contains(t, ds, f, "stats must contain status '%v'", f)
 	}
 }
 
+func TestDeviceDeploymentStatusAwaitingConfirmation(t *testing.T) {
+	status := NewStatus(DeviceDeploymentStatusAwaitingConfirmationStr)
+	assert.Equal(t, DeviceDeploymentStatusAwaitingConfirmation, status)
+	assert.Equal(t, DeviceDeploymentStatusAwaitingConfirmationStr, status.String())
+	assert.True(t, status.Active())
+	assert.Contains(t, ActiveDeploymentStatuses(), status)
+	assert.False(t, IsDeviceDeploymentStatusFinished(status))
+}
+
 func TestDeviceDeploymentIsFinished(t *testing.T) {
 	tcs := []struct {
 		status   DeviceDeploymentStatus
@@ -14,8 +14,29 @@
 
 package model
 
+import "time"
+
 type DeviceDeploymentListItem struct {
 	Id         string            `json:"id"`
 	Deployment *Deployment       `json:"deployment"`
 	Device     *DeviceDeployment `json:"device"`
 }
+
+// DeviceDeploymentView is a device deployment with the name and status of
+// its deployment and the name of its artifact, looked up in one go.
+type DeviceDeploymentView struct {
+	ID               string                 `json:"id" bson:"_id"`
+	DeviceID         string                 `json:"device_id" bson:"deviceid"`
+	DeploymentID     string                 `json:"deployment_id" bson:"deploymentid"`
+	DeploymentName   string                 `json:"deployment_name" bson:"deployment_name"`
+	DeploymentStatus DeploymentStatus       `json:"deployment_status" bson:"deployment_status"`
+	ArtifactID       string                 `json:"artifact_id,omitempty" bson:"artifact_id,omitempty"`
+	ArtifactName     string                 `json:"artifact_name" bson:"artifact_name"`
+	Status           DeviceDeploymentStatus `json:"status" bson:"status"`
+	SubState         string                 `json:"substate,omitempty" bson:"substate,omitempty"`
+	Created          *time.Time             `json:"created" bson:"created"`
+	Started          *time.Time             `json:"started,omitempty" bson:"started,omitempty"`
+	Finished         *time.Time             `json:"finished,omitempty" bson:"finished,omitempty"`
+	IsLogAvailable   bool                   `json:"log" bson:"log"`
+	Attempts         uint                   `json:"attempts,omitempty" bson:"attempts,omitempty"`
+}
@@ -17,6 +17,7 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
@@ -27,8 +28,38 @@ import (
 var (
 	ErrInvalidDeploymentLog = errors.New("invalid deployment log")
 	ErrInvalidLogMessage    = errors.New("invalid log message")
+	ErrInvalidLogLevel      = errors.New("invalid log level")
 )
 
+// logLevelSeverity ranks the log levels reported by the devices; higher
+// is more severe.
+var logLevelSeverity = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    3,
+	"warn":      4,
+	"warning":   4,
+	"err":       5,
+	"error":     5,
+	"crit":      6,
+	"critical":  6,
+	"fatal":     6,
+	"alert":     7,
+	"emerg":     8,
+	"emergency": 8,
+	"panic":     8,
+}
+
+// ValidateLogLevel returns ErrInvalidLogLevel if level is not one of the
+// known log levels.
+func ValidateLogLevel(level string) error {
+	if _, ok := logLevelSeverity[strings.ToLower(level)]; !ok {
+		return errors.Wrapf(ErrInvalidLogLevel, "%q", level)
+	}
+	return nil
+}
+
 type LogMessage struct {
 	Timestamp *time.Time `json:"timestamp" valid:"required"`
 	Level     string     `json:"level" valid:"required"`
@@ -55,6 +86,17 @@ func (l *LogMessage) UnmarshalJSON(raw []byte) error {
 	return nil
 }
 
+// AtLeast reports whether the message is at least as severe as the given
+// level. Messages with an unknown level are always reported, as their
+// severity cannot be told.
+func (l LogMessage) AtLeast(level string) bool {
+	severity, ok := logLevelSeverity[strings.ToLower(l.Level)]
+	if !ok {
+		return true
+	}
+	return severity >= logLevelSeverity[strings.ToLower(level)]
+}
+
 func (l LogMessage) String() string {
 	return fmt.Sprintf("%s %s: %s", l.Timestamp.UTC().String(), l.Level, l.Message)
 }
@@ -63,6 +105,9 @@ type DeploymentLog struct {
 	// skip these 2 field when (un)marshaling to/from JSON
 	DeviceID     string `json:"-" valid:"required"`
 	DeploymentID string `json:"-" valid:"uuidv4,required"`
+	// Attempt is the retry attempt of the device deployment which
+	// produced the log, starting from zero.
+	Attempt uint `json:"-" bson:",omitempty"`
 
 	Messages []LogMessage `json:"messages" valid:"required"`
 }
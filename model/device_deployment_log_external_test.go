@@ -194,3 +194,26 @@ func TestUnmarshalLogMessage(t *testing.T) {
 	}
 
 }
+
+func TestLogMessageAtLeast(t *testing.T) {
+	testCases := []struct {
+		level    string
+		minLevel string
+		atLeast  bool
+	}{
+		{level: "error", minLevel: "warning", atLeast: true},
+		{level: "warning", minLevel: "warn", atLeast: true},
+		{level: "info", minLevel: "warning", atLeast: false},
+		{level: "DEBUG", minLevel: "Info", atLeast: false},
+		{level: "notice", minLevel: "info", atLeast: true},
+		{level: "unknown", minLevel: "panic", atLeast: true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.atLeast,
+			LogMessage{Level: tc.level}.AtLeast(tc.minLevel),
+			"%s >= %s", tc.level, tc.minLevel)
+	}
+
+	assert.NoError(t, ValidateLogLevel("Error"))
+	assert.ErrorIs(t, ValidateLogLevel("loud"), ErrInvalidLogLevel)
+}
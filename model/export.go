@@ -0,0 +1,65 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending  ExportStatus = "pending"
+	ExportStatusRunning  ExportStatus = "running"
+	ExportStatusFinished ExportStatus = "finished"
+	ExportStatusFailed   ExportStatus = "failed"
+)
+
+// ExportJob tracks the export of a tenant's deployments and device
+// deployments to Parquet files in the object storage.
+type ExportJob struct {
+	ID       string       `json:"id" bson:"_id"`
+	TenantID string       `json:"-" bson:"tenant_id"`
+	Status   ExportStatus `json:"status" bson:"status"`
+
+	// Prefix of the object keys of the exported files
+	Prefix string `json:"prefix" bson:"prefix"`
+	// Files lists the object keys of the files uploaded so far
+	Files []string `json:"files" bson:"files"`
+
+	// Number of exported deployments and device deployments
+	Deployments       int `json:"deployments" bson:"deployments"`
+	DeviceDeployments int `json:"device_deployments" bson:"device_deployments"`
+
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+
+	Created  time.Time  `json:"created" bson:"created"`
+	Updated  time.Time  `json:"updated" bson:"updated"`
+	Finished *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+}
+
+func NewExportJob() *ExportJob {
+	now := time.Now()
+	uid, _ := uuid.NewRandom()
+	return &ExportJob{
+		ID:      uid.String(),
+		Status:  ExportStatusPending,
+		Files:   []string{},
+		Created: now,
+		Updated: now,
+	}
+}
@@ -14,6 +14,10 @@
 
 package model
 
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
 type SearchParams struct {
 	Page      int               `json:"page"`
 	PerPage   int               `json:"per_page"`
@@ -27,3 +31,18 @@ type FilterPredicate struct {
 	Type      string      `json:"type" bson:"type"`
 	Value     interface{} `json:"value" bson:"value"`
 }
+
+var validFilterTypes = []interface{}{
+	"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$exists", "$in", "$nin", "$regex",
+}
+
+// Validate checks that the predicate is complete and uses one of the
+// operators supported by the inventory and reporting searches.
+func (f FilterPredicate) Validate() error {
+	return validation.ValidateStruct(&f,
+		validation.Field(&f.Scope, validation.Required),
+		validation.Field(&f.Attribute, validation.Required),
+		validation.Field(&f.Type, validation.Required, validation.In(validFilterTypes...)),
+		validation.Field(&f.Value, validation.NotNil),
+	)
+}
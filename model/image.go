@@ -16,6 +16,7 @@ package model
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"path"
 	"time"
@@ -31,6 +32,18 @@ import (
 
 const (
 	ArtifactFileSuffix = ".mender"
+
+	// ArtifactDependsDeviceType is the depends (and provides) key holding
+	// the device type.
+	ArtifactDependsDeviceType = "device_type"
+
+	ArtifactCompressionNone = "none"
+	ArtifactCompressionGzip = "gzip"
+	ArtifactCompressionLzma = "lzma"
+	ArtifactCompressionZstd = "zstd"
+	// ArtifactCompressionUnknown is the compression of the artifacts
+	// stored before the compression was recorded.
+	ArtifactCompressionUnknown = "unknown"
 )
 
 var (
@@ -40,6 +53,28 @@ var (
 
 type ProvidesIdx map[string]string
 
+func dependsValueMatches(value interface{}, provided string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == provided
+	case []string:
+		for _, item := range v {
+			if item == provided {
+				return true
+			}
+		}
+	case bson.A:
+		return dependsValueMatches([]interface{}(v), provided)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == provided {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func ImagePathFromContext(ctx context.Context, id string) string {
 	imgPath := id
 	if idty := identity.FromContext(ctx); idty != nil {
@@ -99,6 +134,16 @@ type ArtifactMeta struct {
 	// Flag that indicates if artifact is signed or not
 	Signed bool `json:"signed" bson:"signed"`
 
+	// Compression of the artifact payload, one of the
+	// ArtifactCompression values.
+	Compression string `json:"compression,omitempty" bson:"compression,omitempty"`
+
+	// SignerKeyFingerprint is the SHA-256 fingerprint of the configured
+	// verification key the artifact signature verifies with; empty if
+	// unsigned or signed with an unknown key.
+	//nolint:lll
+	SignerKeyFingerprint string `json:"signer_key_fingerprint,omitempty" bson:"signer_key_fingerprint,omitempty"`
+
 	// List of updates
 	Updates []Update `json:"updates" valid:"-"`
 
@@ -157,7 +202,7 @@ func (am *ArtifactMeta) Validate() error {
 	if am.Depends == nil {
 		am.Depends = make(map[string]interface{})
 	}
-	am.Depends["device_type"] = am.DeviceTypesCompatible
+	am.Depends[ArtifactDependsDeviceType] = am.DeviceTypesCompatible
 
 	return validation.ValidateStruct(am,
 		validation.Field(&am.Name, validation.Required, lengthIn1To4096),
@@ -190,6 +235,35 @@ type Image struct {
 
 	// Last modification time, including image upload time
 	Modified *time.Time `json:"modified" valid:"-"`
+
+	// Revision counts the replacements of the artifact file; each of them
+	// is stored under a new object key.
+	Revision int `json:"revision,omitempty" bson:"revision,omitempty" valid:"-"`
+
+	// Tags of the artifact, independent of the tags of its release.
+	Tags Tags `json:"tags,omitempty" bson:"tags,omitempty" valid:"-"`
+
+	// StorageKey is the full key of the artifact file of an image imported
+	// from an object already present in the storage; it applies until the
+	// file is replaced.
+	StorageKey string `json:"-" bson:"storage_key,omitempty" valid:"-"`
+}
+
+// ObjectID returns the key of the artifact file of the image revision,
+// relative to the tenant prefix.
+func (img Image) ObjectID() string {
+	if img.Revision == 0 {
+		return img.Id
+	}
+	return fmt.Sprintf("%s.%d", img.Id, img.Revision)
+}
+
+// ObjectPath returns the full key of the artifact file of the image revision.
+func (img Image) ObjectPath(ctx context.Context) string {
+	if img.StorageKey != "" && img.Revision == 0 {
+		return img.StorageKey
+	}
+	return ImagePathFromContext(ctx, img.ObjectID())
 }
 
 func (img Image) MarshalBSON() (b []byte, err error) {
@@ -247,6 +321,9 @@ type MultipartUploadMsg struct {
 	ArtifactID string
 	// reader pointing to the beginning of the artifact data
 	ArtifactReader io.Reader
+	// ChecksumSHA256, if set, is the expected hex encoded SHA256 digest
+	// of the artifact data; the artifact is rejected if it does not match
+	ChecksumSHA256 string
 }
 
 // MultipartGenerateImageMsg is a structure with fields extracted from the multipart/form-data
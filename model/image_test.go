@@ -14,7 +14,12 @@
 
 package model
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+)
 
 const (
 	validUUIDv4  = "d50eda0d-2cea-4de1-8d42-9cd3e7e8670d"
@@ -76,3 +81,42 @@ func TestValidateCorrectImage(t *testing.T) {
 		t.Errorf("%v", err)
 	}
 }
+
+func TestImageObjectPath(t *testing.T) {
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+
+	testCases := map[string]struct {
+		image Image
+		path  string
+	}{
+		"uploaded": {
+			image: Image{Id: validUUIDv4},
+			path:  "tenant/" + validUUIDv4,
+		},
+		"replaced": {
+			image: Image{Id: validUUIDv4, Revision: 2},
+			path:  "tenant/" + validUUIDv4 + ".2",
+		},
+		"imported": {
+			image: Image{Id: validUUIDv4, StorageKey: "import/release-1.mender"},
+			path:  "import/release-1.mender",
+		},
+		"imported, then replaced": {
+			image: Image{
+				Id:         validUUIDv4,
+				Revision:   1,
+				StorageKey: "import/release-1.mender",
+			},
+			path: "tenant/" + validUUIDv4 + ".1",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if path := tc.image.ObjectPath(ctx); path != tc.path {
+				t.Errorf("expected %q, got %q", tc.path, path)
+			}
+		})
+	}
+}
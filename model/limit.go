@@ -14,23 +14,104 @@
 
 package model
 
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 const (
 	LimitStorage = "storage"
+	// LimitDownloadBandwidth caps the rate, in bytes per second, at which
+	// the artifacts are streamed to the tenant's clients through the
+	// service, shared by all the concurrent downloads of the tenant.
+	// Presigned downloads from the storage are not affected.
+	LimitDownloadBandwidth = "download_bandwidth"
+	// LimitArtifactSize lowers, for the tenant, the maximum size in bytes
+	// of the uploaded artifacts.
+	LimitArtifactSize = "artifact_size"
+	// LimitReleases caps the number of releases of the tenant; uploading
+	// an artifact of a new release beyond it is refused.
+	LimitReleases = "releases"
+	// LimitArtifactProvidesKeys restricts, for the tenant, the keys the
+	// uploaded artifacts may provide; not set allows any key.
+	LimitArtifactProvidesKeys = "artifact_provides_keys"
+	// LimitArtifactDependsKeys restricts, for the tenant, the keys the
+	// uploaded artifacts may depend on; not set allows any key.
+	LimitArtifactDependsKeys = "artifact_depends_keys"
 )
 
 var (
-	ValidLimits = []string{LimitStorage}
+	ValidLimits = []string{
+		LimitStorage,
+		LimitDownloadBandwidth,
+		LimitArtifactSize,
+		LimitReleases,
+		LimitArtifactProvidesKeys,
+		LimitArtifactDependsKeys,
+	}
 )
 
 type Limit struct {
 	Name  string `bson:"_id"`
 	Value uint64 `bson:"value" json:"value"`
+	// Keys is the allowlist of the keys limits; a key ending with "*"
+	// allows all the keys starting with what precedes it.
+	Keys []string `bson:"keys,omitempty" json:"keys,omitempty"`
 }
 
 func (l Limit) IsLess(what uint64) bool {
 	return what < l.Value
 }
 
+// Lower returns the limit if it is set and lower than max, max otherwise;
+// a zero max means no maximum.
+func (l Limit) Lower(max int64) int64 {
+	if l.Value > 0 && (max <= 0 || l.Value < uint64(max)) {
+		return int64(l.Value)
+	}
+	return max
+}
+
+// AllowsKey returns true if the key is in the allowlist of the limit, or if
+// the limit has no allowlist.
+func (l Limit) AllowsKey(key string) bool {
+	if len(l.Keys) == 0 {
+		return true
+	}
+	for _, allowed := range l.Keys {
+		if strings.HasSuffix(allowed, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(allowed, "*")) {
+				return true
+			}
+		} else if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKeysLimit returns true if the named limit is an allowlist of keys
+// rather than a value.
+// ValidateLimitKeys checks the allowlist of a keys limit: the keys must not
+// be empty, and "*" may only end a prefix. A bare "*" would allow any key,
+// which is what an empty allowlist is for.
+func ValidateLimitKeys(keys []string) error {
+	for _, key := range keys {
+		prefix := strings.TrimSuffix(key, "*")
+		if prefix == "" {
+			return errors.Errorf("invalid key %q: must be a key or a prefix", key)
+		} else if strings.Contains(prefix, "*") {
+			return errors.Errorf("invalid key %q: \"*\" may only end a prefix", key)
+		}
+	}
+	return nil
+}
+
+func IsKeysLimit(name string) bool {
+	return name == LimitArtifactProvidesKeys || name == LimitArtifactDependsKeys
+}
+
 func IsValidLimit(name string) bool {
 	for _, n := range ValidLimits {
 		if name == n {
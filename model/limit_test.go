@@ -24,4 +24,43 @@ func TestValidLimit(t *testing.T) {
 	assert.False(t, IsValidLimit("foo"))
 	assert.False(t, IsValidLimit("bar"))
 	assert.True(t, IsValidLimit(LimitStorage))
+	assert.True(t, IsValidLimit(LimitDownloadBandwidth))
+	assert.True(t, IsValidLimit(LimitArtifactSize))
+	assert.True(t, IsValidLimit(LimitArtifactProvidesKeys))
+	assert.True(t, IsValidLimit(LimitArtifactDependsKeys))
+}
+
+func TestLimitLower(t *testing.T) {
+	assert.Equal(t, int64(100), Limit{}.Lower(100))
+	assert.Equal(t, int64(10), Limit{Value: 10}.Lower(100))
+	assert.Equal(t, int64(100), Limit{Value: 1000}.Lower(100))
+	assert.Equal(t, int64(10), Limit{Value: 10}.Lower(0))
+	assert.Equal(t, int64(0), Limit{}.Lower(0))
+}
+
+func TestLimitAllowsKey(t *testing.T) {
+	assert.True(t, Limit{}.AllowsKey("anything"))
+
+	limit := Limit{Keys: []string{"rootfs-image.version", "data-partition.*"}}
+	assert.True(t, limit.AllowsKey("rootfs-image.version"))
+	assert.True(t, limit.AllowsKey("data-partition.data.version"))
+	assert.False(t, limit.AllowsKey("rootfs-image.checksum"))
+	assert.False(t, limit.AllowsKey("data-partition"))
+
+	assert.True(t, IsKeysLimit(LimitArtifactProvidesKeys))
+	assert.True(t, IsKeysLimit(LimitArtifactDependsKeys))
+	assert.False(t, IsKeysLimit(LimitReleases))
+}
+
+func TestValidateLimitKeys(t *testing.T) {
+	assert.NoError(t, ValidateLimitKeys(nil))
+	assert.NoError(t, ValidateLimitKeys(
+		[]string{"rootfs-image.version", "data-partition.*"}))
+
+	assert.EqualError(t, ValidateLimitKeys([]string{"rootfs-image.version", ""}),
+		`invalid key "": must be a key or a prefix`)
+	assert.EqualError(t, ValidateLimitKeys([]string{"*"}),
+		`invalid key "*": must be a key or a prefix`)
+	assert.EqualError(t, ValidateLimitKeys([]string{"rootfs-*.version"}),
+		`invalid key "rootfs-*.version": "*" may only end a prefix`)
 }
@@ -18,8 +18,13 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
+// MaxUploadParts is the maximum number of parts of a multipart upload.
+const MaxUploadParts = 10000
+
 type Link struct {
 	Uri      string            `json:"uri" bson:"-"`
 	Expire   time.Time         `json:"expire,omitempty" bson:"expire"`
@@ -36,6 +41,61 @@ type UploadLink struct {
 	IssuedAt  time.Time  `json:"-" bson:"issued_ts"`
 	UpdatedTS time.Time  `json:"-" bson:"updated_ts"`
 	Status    LinkStatus `json:"-" bson:"status"`
+
+	// Multipart is set on resumable uploads, of which the parts are
+	// uploaded separately.
+	Multipart *MultipartUpload `json:"-" bson:"multipart,omitempty"`
+}
+
+// MultipartUpload is the object storage state of a resumable upload.
+type MultipartUpload struct {
+	// UploadID identifies the upload in the object storage.
+	UploadID string `bson:"upload_id"`
+	// Path is the object the parts are assembled into.
+	Path string `bson:"path"`
+	// Parts lists the parts reported as uploaded.
+	Parts []UploadPart `bson:"parts"`
+}
+
+// UploadPart is a part of a resumable upload stored in the object storage.
+type UploadPart struct {
+	PartNumber int    `json:"part_number" bson:"part_number"`
+	ETag       string `json:"etag" bson:"etag"`
+}
+
+func (p UploadPart) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.PartNumber,
+			validation.Required, validation.Min(1), validation.Max(MaxUploadParts)),
+		validation.Field(&p.ETag, validation.Required, lengthLessThan4096),
+	)
+}
+
+// UploadSession is the progress of an upload, as reported to the client
+// resuming it.
+type UploadSession struct {
+	ID        string       `json:"id"`
+	Status    LinkStatus   `json:"status"`
+	Expire    time.Time    `json:"expire"`
+	Multipart bool         `json:"multipart"`
+	Parts     []UploadPart `json:"parts"`
+}
+
+// NewUploadSession returns the progress of the upload.
+func NewUploadSession(link *UploadLink) *UploadSession {
+	session := &UploadSession{
+		ID:     link.ArtifactID,
+		Status: link.Status & LinkStatusProcessedMask,
+		Expire: link.Expire,
+		Parts:  []UploadPart{},
+	}
+	if link.Multipart != nil {
+		session.Multipart = true
+		if link.Multipart.Parts != nil {
+			session.Parts = link.Multipart.Parts
+		}
+	}
+	return session
 }
 
 type LinkStatus uint32
@@ -0,0 +1,39 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ListVersion summarizes the documents matching a list query: any document
+// added, modified or removed changes it.
+type ListVersion struct {
+	Count    int        `bson:"count"`
+	Modified *time.Time `bson:"modified"`
+}
+
+// ETag returns the weak entity tag of the list.
+func (v *ListVersion) ETag() string {
+	var modified int64
+	if v.Modified != nil {
+		modified = v.Modified.UnixNano()
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%d", v.Count, modified)
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListVersionETag(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := modified.Add(time.Millisecond)
+
+	etag := (&ListVersion{Count: 1, Modified: &modified}).ETag()
+	assert.Regexp(t, regexp.MustCompile(`^W/"[0-9a-f]+"$`), etag)
+
+	assert.Equal(t, etag, (&ListVersion{Count: 1, Modified: &modified}).ETag())
+	assert.NotEqual(t, etag, (&ListVersion{Count: 2, Modified: &modified}).ETag())
+	assert.NotEqual(t, etag, (&ListVersion{Count: 1, Modified: &later}).ETag())
+	assert.NotEqual(t, etag, (&ListVersion{Count: 1}).ETag())
+}
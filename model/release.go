@@ -39,6 +39,12 @@ var (
 			strconv.Itoa(TagsMaxUnique) +
 			") has been exceeded",
 	)
+	ErrNegativeArtifactsCount = errors.New(
+		"artifacts_count_min, artifacts_count_max: must not be negative",
+	)
+	ErrInvalidArtifactsCountRange = errors.New(
+		"artifacts_count_min: must not be greater than artifacts_count_max",
+	)
 )
 
 type Tags []Tag
@@ -188,6 +194,14 @@ type Release struct {
 	Notes          Notes      `json:"notes" bson:"notes,omitempty"`
 }
 
+// ReleasesRebuild lists, by name, the releases that had to be created,
+// updated or removed to match the artifacts they are derived from.
+type ReleasesRebuild struct {
+	Created []string
+	Updated []string
+	Removed []string
+}
+
 type ReleaseV1 struct {
 	Name           string     `json:"Name"`
 	Modified       *time.Time `json:"Modified,omitempty"`
@@ -222,6 +236,27 @@ type ReleaseOrImageFilter struct {
 	Page        int      `json:"page"`
 	PerPage     int      `json:"per_page"`
 	Sort        string   `json:"sort"`
+
+	// ArtifactsCountMin and ArtifactsCountMax, if set, bound the number
+	// of artifacts of the releases, inclusive.
+	ArtifactsCountMin *int `json:"artifacts_count_min,omitempty"`
+	ArtifactsCountMax *int `json:"artifacts_count_max,omitempty"`
+
+	// Provides, if set, restricts the artifacts to the ones providing all
+	// the given key/value pairs; it does not apply to releases.
+	Provides map[string]string `json:"provides,omitempty"`
+}
+
+func (f ReleaseOrImageFilter) Validate() error {
+	if (f.ArtifactsCountMin != nil && *f.ArtifactsCountMin < 0) ||
+		(f.ArtifactsCountMax != nil && *f.ArtifactsCountMax < 0) {
+		return ErrNegativeArtifactsCount
+	}
+	if f.ArtifactsCountMin != nil && f.ArtifactsCountMax != nil &&
+		*f.ArtifactsCountMin > *f.ArtifactsCountMax {
+		return ErrInvalidArtifactsCountRange
+	}
+	return nil
 }
 
 type DirectUploadMetadata struct {
@@ -252,3 +287,20 @@ type ReleasesDeleteError struct {
 	RequestID         string   `json:"request_id"`
 	ActiveDeployments []string `json:"active_deployments"`
 }
+
+// ReleaseRollout is the progress of a release across all the deployments
+// of its artifacts.
+type ReleaseRollout struct {
+	// Deployments is a page of the deployments of the release.
+	Deployments []DeploymentSummary `json:"deployments"`
+
+	ReleaseRolloutStats
+}
+
+// ReleaseRolloutStats sums up all the deployments of a release.
+type ReleaseRolloutStats struct {
+	DeploymentCount int `json:"deployment_count" bson:"deployment_count"`
+	DeviceCount     int `json:"device_count" bson:"device_count"`
+	// Statistics merges the device status counters of the deployments.
+	Statistics Stats `json:"statistics" bson:"statistics"`
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"sort"
+)
+
+// ReleaseGraph is the dependency graph of the artifacts of a release.
+type ReleaseGraph struct {
+	Nodes []ReleaseGraphNode `json:"nodes"`
+	Edges []ReleaseGraphEdge `json:"edges"`
+}
+
+// ReleaseGraphNode is an artifact of the release.
+type ReleaseGraphNode struct {
+	ID          string                 `json:"id"`
+	DeviceTypes []string               `json:"device_types"`
+	Provides    map[string]string      `json:"artifact_provides,omitempty"`
+	Depends     map[string]interface{} `json:"artifact_depends,omitempty"`
+}
+
+// ReleaseGraphEdge links the artifact providing what another artifact
+// depends on to the depending artifact.
+type ReleaseGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Keys are the depends keys satisfied by the provides, sorted.
+	Keys []string `json:"keys"`
+}
+
+// NewReleaseGraph computes the dependency graph of the release: there is an
+// edge from an artifact to another when they have a device type in common
+// and the provides of the first satisfy all the depends of the second,
+// the device type aside.
+func NewReleaseGraph(release *Release) *ReleaseGraph {
+	graph := &ReleaseGraph{
+		Nodes: make([]ReleaseGraphNode, 0, len(release.Artifacts)),
+		Edges: []ReleaseGraphEdge{},
+	}
+	for _, artifact := range release.Artifacts {
+		node := ReleaseGraphNode{ID: artifact.Id}
+		if artifact.ArtifactMeta != nil {
+			node.DeviceTypes = artifact.ArtifactMeta.DeviceTypesCompatible
+			node.Provides = artifact.ArtifactMeta.Provides
+			node.Depends = artifact.ArtifactMeta.Depends
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for _, from := range graph.Nodes {
+		for _, to := range graph.Nodes {
+			if from.ID == to.ID || !shareDeviceType(from.DeviceTypes, to.DeviceTypes) {
+				continue
+			}
+			if keys := satisfiedDepends(from.Provides, to.Depends); len(keys) > 0 {
+				graph.Edges = append(graph.Edges, ReleaseGraphEdge{
+					From: from.ID,
+					To:   to.ID,
+					Keys: keys,
+				})
+			}
+		}
+	}
+	return graph
+}
+
+// satisfiedDepends returns the depends keys, but the device type, if the
+// provides satisfy all of them.
+func satisfiedDepends(provides map[string]string, depends map[string]interface{}) []string {
+	var keys []string
+	for key, value := range depends {
+		if key == ArtifactDependsDeviceType {
+			continue
+		}
+		provided, ok := provides[key]
+		if !ok || !dependsValueMatches(value, provided) {
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func shareDeviceType(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNewReleaseGraph(t *testing.T) {
+	t.Parallel()
+
+	artifact := func(
+		id string,
+		deviceTypes []string,
+		provides map[string]string,
+		depends map[string]interface{},
+	) Image {
+		return Image{
+			Id: id,
+			ArtifactMeta: &ArtifactMeta{
+				Name:                  "release",
+				DeviceTypesCompatible: deviceTypes,
+				Provides:              provides,
+				Depends:               depends,
+			},
+		}
+	}
+	// full rootfs image, and a delta applying on top of it
+	full := artifact("full", []string{"rpi4"}, map[string]string{
+		"rootfs-image.checksum": "abc",
+		"rootfs-image.version":  "1.0",
+	}, map[string]interface{}{
+		"device_type": bson.A{"rpi4"},
+	})
+	delta := artifact("delta", []string{"rpi4"}, map[string]string{
+		"rootfs-image.checksum": "def",
+	}, map[string]interface{}{
+		"device_type":           bson.A{"rpi4"},
+		"rootfs-image.checksum": "abc",
+	})
+	// application update depending on any of the rootfs versions
+	app := artifact("app", []string{"rpi4", "rpi3"}, nil, map[string]interface{}{
+		"device_type":          []interface{}{"rpi4", "rpi3"},
+		"rootfs-image.version": []interface{}{"0.9", "1.0"},
+	})
+	// same provides as the full image, but for another device type
+	other := artifact("other", []string{"x86"}, map[string]string{
+		"rootfs-image.checksum": "abc",
+	}, nil)
+	// depends only partially satisfied by the full image
+	partial := artifact("partial", []string{"rpi4"}, nil, map[string]interface{}{
+		"rootfs-image.checksum": "abc",
+		"rootfs-image.version":  "2.0",
+	})
+
+	graph := NewReleaseGraph(&Release{
+		Name:      "release",
+		Artifacts: []Image{full, delta, app, other, partial},
+	})
+	assert.Equal(t, []ReleaseGraphNode{{
+		ID:          "full",
+		DeviceTypes: []string{"rpi4"},
+		Provides:    full.ArtifactMeta.Provides,
+		Depends:     full.ArtifactMeta.Depends,
+	}, {
+		ID:          "delta",
+		DeviceTypes: []string{"rpi4"},
+		Provides:    delta.ArtifactMeta.Provides,
+		Depends:     delta.ArtifactMeta.Depends,
+	}, {
+		ID:          "app",
+		DeviceTypes: []string{"rpi4", "rpi3"},
+		Depends:     app.ArtifactMeta.Depends,
+	}, {
+		ID:          "other",
+		DeviceTypes: []string{"x86"},
+		Provides:    other.ArtifactMeta.Provides,
+	}, {
+		ID:          "partial",
+		DeviceTypes: []string{"rpi4"},
+		Depends:     partial.ArtifactMeta.Depends,
+	}}, graph.Nodes)
+	assert.Equal(t, []ReleaseGraphEdge{{
+		From: "full",
+		To:   "delta",
+		Keys: []string{"rootfs-image.checksum"},
+	}, {
+		From: "full",
+		To:   "app",
+		Keys: []string{"rootfs-image.version"},
+	}}, graph.Edges)
+
+	empty := NewReleaseGraph(&Release{Name: "empty"})
+	assert.Equal(t, &ReleaseGraph{
+		Nodes: []ReleaseGraphNode{},
+		Edges: []ReleaseGraphEdge{},
+	}, empty)
+}
@@ -84,6 +84,26 @@ func TestReleaseNotesValidation(t *testing.T) {
 	assert.True(t, strings.HasPrefix(err.Error(), "invalid character '"))
 }
 
+func TestReleaseOrImageFilterValidate(t *testing.T) {
+	one, two, negative := 1, 2, -1
+	assert.NoError(t, ReleaseOrImageFilter{}.Validate())
+	assert.NoError(t, ReleaseOrImageFilter{
+		ArtifactsCountMin: &one,
+		ArtifactsCountMax: &one,
+	}.Validate())
+	assert.NoError(t, ReleaseOrImageFilter{ArtifactsCountMax: &two}.Validate())
+	assert.ErrorIs(t, ReleaseOrImageFilter{
+		ArtifactsCountMin: &two,
+		ArtifactsCountMax: &one,
+	}.Validate(), ErrInvalidArtifactsCountRange)
+	assert.ErrorIs(t, ReleaseOrImageFilter{
+		ArtifactsCountMin: &negative,
+	}.Validate(), ErrNegativeArtifactsCount)
+	assert.ErrorIs(t, ReleaseOrImageFilter{
+		ArtifactsCountMax: &negative,
+	}.Validate(), ErrNegativeArtifactsCount)
+}
+
 func TestConvertReleasesToV1(t *testing.T) {
 	now := time.Now()
 	releases := []Release{
@@ -0,0 +1,45 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReplacedArtifactFile is the file of a replaced revision of an artifact,
+// kept in the object storage as long as the devices of the deployments
+// created before the replacement may still be downloading it.
+type ReplacedArtifactFile struct {
+	ID         string `bson:"_id"`
+	ArtifactID string `bson:"artifact_id"`
+	// StorageKey is the key of the file in the object storage.
+	StorageKey string    `bson:"storage_key"`
+	Replaced   time.Time `bson:"replaced"`
+}
+
+// NewReplacedArtifactFile returns the record of the current file of the
+// image, replaced now.
+func NewReplacedArtifactFile(ctx context.Context, image *Image) *ReplacedArtifactFile {
+	uid, _ := uuid.NewRandom()
+	return &ReplacedArtifactFile{
+		ID:         uid.String(),
+		ArtifactID: image.Id,
+		StorageKey: image.ObjectPath(ctx),
+		Replaced:   time.Now(),
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStorageSettingsDeserialize(t *testing.T) {
@@ -104,3 +105,110 @@ func TestStorageSettingsDeserialize(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageSettingsValidate(t *testing.T) {
+	t.Parallel()
+	connectionString := "DefaultEndpointsProtocol=https;AccountName=foo"
+	testCases := []struct {
+		Name string
+
+		Settings StorageSettings
+
+		Fields map[string]string
+	}{{
+		Name: "ok",
+
+		Settings: StorageSettings{
+			Region: "eu-west-1",
+			Bucket: "artifacts",
+			Key:    "AKIAIOSFODNN7",
+			Secret: "wJalrXUtnFEMI",
+			Uri:    "https://s3.example.com",
+		},
+	}, {
+		Name: "ok/azure connection string",
+
+		Settings: StorageSettings{
+			Type:             StorageTypeAzure,
+			Bucket:           "artifacts",
+			ConnectionString: &connectionString,
+		},
+	}, {
+		Name: "error/bucket required",
+
+		Settings: StorageSettings{
+			Region: "eu-west-1",
+			Key:    "AKIAIOSFODNN7",
+			Secret: "wJalrXUtnFEMI",
+		},
+		Fields: map[string]string{"bucket": "cannot be blank"},
+	}, {
+		Name: "error/secret required with the key",
+
+		Settings: StorageSettings{
+			Type:             StorageTypeAzure,
+			Bucket:           "artifacts",
+			Key:              "account",
+			ConnectionString: &connectionString,
+		},
+		Fields: map[string]string{"secret": "cannot be blank"},
+	}, {
+		Name: "error/uri not a URL",
+
+		Settings: StorageSettings{
+			Region: "eu-west-1",
+			Bucket: "artifacts",
+			Key:    "AKIAIOSFODNN7",
+			Secret: "wJalrXUtnFEMI",
+			Uri:    "not a url",
+		},
+		Fields: map[string]string{"uri": "must be a valid URL"},
+	}, {
+		Name: "ok/kms encryption",
+
+		Settings: StorageSettings{
+			Region:               "eu-west-1",
+			Bucket:               "artifacts",
+			Key:                  "AKIAIOSFODNN7",
+			Secret:               "wJalrXUtnFEMI",
+			ServerSideEncryption: ServerSideEncryptionKMS,
+			SSEKMSKeyID:          "arn:aws:kms:eu-west-1:123456789012:key/artifacts",
+		},
+	}, {
+		Name: "error/kms key required",
+
+		Settings: StorageSettings{
+			Region:               "eu-west-1",
+			Bucket:               "artifacts",
+			Key:                  "AKIAIOSFODNN7",
+			Secret:               "wJalrXUtnFEMI",
+			ServerSideEncryption: ServerSideEncryptionKMS,
+		},
+		Fields: map[string]string{"sse_kms_key_id": "cannot be blank"},
+	}, {
+		Name: "error/unknown encryption",
+
+		Settings: StorageSettings{
+			Region:               "eu-west-1",
+			Bucket:               "artifacts",
+			Key:                  "AKIAIOSFODNN7",
+			Secret:               "wJalrXUtnFEMI",
+			ServerSideEncryption: "aws:kms:dsse",
+		},
+		Fields: map[string]string{"server_side_encryption": "must be a valid value"},
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.Settings.Validate()
+			if tc.Fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tc.Fields, validationErr.Fields)
+		})
+	}
+}
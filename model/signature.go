@@ -17,8 +17,10 @@ package model
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"net/http"
 	"time"
 
@@ -27,25 +29,51 @@ import (
 )
 
 const (
+	ParamAlgorithm = "x-men-algorithm"
 	ParamExpire    = "x-men-expire"
 	ParamSignature = "x-men-signature"
 	ParamTenantID  = "tenant_id"
 )
 
+// Signature algorithms supported for signing URLs. Links without the
+// algorithm parameter are signed with HMAC256.
+const (
+	SignatureAlgorithmHMAC256 = "HMAC256"
+	SignatureAlgorithmHMAC512 = "HMAC512"
+)
+
 var ErrLinkExpired = errors.New("URL expired")
 
+// ValidSignatureAlgorithm returns true if the algorithm is supported.
+func ValidSignatureAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case SignatureAlgorithmHMAC256, SignatureAlgorithmHMAC512:
+		return true
+	}
+	return false
+}
+
 type RequestSignature struct {
 	*http.Request
 	Secret []byte
+	// Algorithm is the algorithm used by PresignURL; defaults to HMAC256.
+	Algorithm string
 }
 
 func NewRequestSignature(req *http.Request, secret []byte) *RequestSignature {
 	return &RequestSignature{
-		Request: req,
-		Secret:  secret,
+		Request:   req,
+		Secret:    secret,
+		Algorithm: SignatureAlgorithmHMAC256,
 	}
 }
 
+// WithAlgorithm sets the algorithm used for signing the URL.
+func (sig *RequestSignature) WithAlgorithm(algorithm string) *RequestSignature {
+	sig.Algorithm = algorithm
+	return sig
+}
+
 func (sig *RequestSignature) SetExpire(expire time.Time) {
 	q := sig.URL.Query()
 	q.Set(ParamExpire, expire.UTC().Format(time.RFC3339))
@@ -66,6 +94,9 @@ func (sig *RequestSignature) Validate() error {
 	if err != nil {
 		return errors.Errorf("parameter '%s' is not a valid timestamp", ParamExpire)
 	}
+	if alg := q.Get(ParamAlgorithm); alg != "" && !ValidSignatureAlgorithm(alg) {
+		return errors.Errorf("parameter '%s' is not a supported algorithm", ParamAlgorithm)
+	}
 	if time.Now().After(ts) {
 		return ErrLinkExpired
 	}
@@ -73,12 +104,18 @@ func (sig *RequestSignature) Validate() error {
 }
 
 // PresignURL generates and assign the request signature parameter and returning
-// the resulting URL.
+// the resulting URL. The algorithm is embedded in the URL so that Verify
+// knows which one to use.
 func (sig *RequestSignature) PresignURL() string {
-	signature := sig.HMAC256()
+	algorithm := sig.Algorithm
+	if algorithm == "" {
+		algorithm = SignatureAlgorithmHMAC256
+	}
+	signature := sig.sign(algorithm)
 	signature64 := base64.RawURLEncoding.EncodeToString(signature)
 
 	q := sig.URL.Query()
+	q.Set(ParamAlgorithm, algorithm)
 	q.Set(ParamSignature, signature64)
 	sig.URL.RawQuery = q.Encode()
 	return sig.URL.String()
@@ -97,18 +134,54 @@ func (sig *RequestSignature) Bytes() []byte {
 	))
 }
 
+// Verify verifies the request signature with the algorithm named in the
+// URL, falling back to HMAC256 for links signed without one.
+func (sig *RequestSignature) Verify() bool {
+	algorithm := sig.URL.Query().Get(ParamAlgorithm)
+	if algorithm == "" {
+		algorithm = SignatureAlgorithmHMAC256
+	}
+	expected := sig.sign(algorithm)
+	if expected == nil {
+		return false
+	}
+	return sig.verify(expected)
+}
+
 // VerifyHMAC256 verifies the request signature with the parameter.
 func (sig *RequestSignature) VerifyHMAC256() bool {
+	return sig.verify(sig.HMAC256())
+}
+
+func (sig *RequestSignature) verify(expected []byte) bool {
 	//nolint:errcheck
 	q := sig.URL.Query()
 	sign, _ := base64.RawURLEncoding.
 		DecodeString(q.Get(ParamSignature))
-	return hmac.Equal(sig.HMAC256(), sign)
+	return hmac.Equal(expected, sign)
+}
+
+func (sig *RequestSignature) sign(algorithm string) []byte {
+	switch algorithm {
+	case SignatureAlgorithmHMAC256:
+		return sig.HMAC256()
+	case SignatureAlgorithmHMAC512:
+		return sig.HMAC512()
+	}
+	return nil
 }
 
-//nolint:errcheck
 func (sig *RequestSignature) HMAC256() []byte {
-	hash := hmac.New(sha256.New, sig.Secret)
-	hash.Write(sig.Bytes())
-	return hash.Sum(nil)
+	return sig.hmac(sha256.New)
+}
+
+func (sig *RequestSignature) HMAC512() []byte {
+	return sig.hmac(sha512.New)
+}
+
+//nolint:errcheck
+func (sig *RequestSignature) hmac(h func() hash.Hash) []byte {
+	mac := hmac.New(h, sig.Secret)
+	mac.Write(sig.Bytes())
+	return mac.Sum(nil)
 }
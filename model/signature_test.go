@@ -81,6 +81,19 @@ func TestRequestSignatureValidate(t *testing.T) {
 			return sig
 		}(),
 		Error: ErrLinkExpired,
+	}, {
+		Name: "error, unsupported algorithm",
+
+		Request: func() *RequestSignature {
+			req, _ := http.NewRequest(http.MethodGet, "https://localhost", nil)
+			sig := NewRequestSignature(req, []byte("test"))
+			sig.SetExpire(time.Now().Add(time.Hour))
+			sig.WithAlgorithm("MD5").PresignURL()
+			return sig
+		}(),
+		Error: errors.Errorf(
+			"parameter '%s' is not a supported algorithm", ParamAlgorithm,
+		),
 	}}
 	for i := range testCases {
 		tc := testCases[i]
@@ -96,3 +109,77 @@ func TestRequestSignatureValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestSignatureAlgorithms(t *testing.T) {
+	t.Parallel()
+	sign := func(algorithm string) *RequestSignature {
+		req, _ := http.NewRequest(
+			http.MethodGet, "https://localhost/download?tenant_id=123", nil,
+		)
+		sig := NewRequestSignature(req, []byte("test"))
+		if algorithm != "" {
+			sig.WithAlgorithm(algorithm)
+		}
+		sig.SetExpire(time.Now().Add(time.Hour))
+		sig.PresignURL()
+		return sig
+	}
+	// verify re-parses the signed URL as the handler receiving it would
+	verify := func(sig *RequestSignature, secret string) bool {
+		req, _ := http.NewRequest(http.MethodGet, sig.URL.String(), nil)
+		return NewRequestSignature(req, []byte(secret)).Verify()
+	}
+
+	for _, algorithm := range []string{
+		"",
+		SignatureAlgorithmHMAC256,
+		SignatureAlgorithmHMAC512,
+	} {
+		sig := sign(algorithm)
+		expected := algorithm
+		if expected == "" {
+			expected = SignatureAlgorithmHMAC256
+		}
+		assert.Equal(t, expected, sig.URL.Query().Get(ParamAlgorithm))
+		assert.NoError(t, sig.Validate(), algorithm)
+		assert.True(t, verify(sig, "test"), algorithm)
+		assert.False(t, verify(sig, "wrong"), algorithm)
+	}
+
+	t.Run("legacy link without algorithm", func(t *testing.T) {
+		sig := sign(SignatureAlgorithmHMAC256)
+		q := sig.URL.Query()
+		q.Del(ParamAlgorithm)
+		sig.URL.RawQuery = q.Encode()
+		assert.True(t, verify(sig, "test"))
+		assert.True(t, sig.VerifyHMAC256())
+	})
+
+	t.Run("mismatched algorithm", func(t *testing.T) {
+		sig := sign(SignatureAlgorithmHMAC512)
+		q := sig.URL.Query()
+		q.Set(ParamAlgorithm, SignatureAlgorithmHMAC256)
+		sig.URL.RawQuery = q.Encode()
+		assert.False(t, verify(sig, "test"))
+
+		sig = sign(SignatureAlgorithmHMAC256)
+		q = sig.URL.Query()
+		q.Set(ParamAlgorithm, SignatureAlgorithmHMAC512)
+		sig.URL.RawQuery = q.Encode()
+		assert.False(t, verify(sig, "test"))
+
+		sig = sign(SignatureAlgorithmHMAC512)
+		q = sig.URL.Query()
+		q.Del(ParamAlgorithm)
+		sig.URL.RawQuery = q.Encode()
+		assert.False(t, verify(sig, "test"))
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		sig := sign(SignatureAlgorithmHMAC256)
+		q := sig.URL.Query()
+		q.Set(ParamAlgorithm, "MD5")
+		sig.URL.RawQuery = q.Encode()
+		assert.False(t, verify(sig, "test"))
+	})
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import "time"
+
+// DeviceSoftware is the artifact a device received with its most recent
+// successful device deployment.
+type DeviceSoftware struct {
+	DeviceID           string     `json:"device_id" bson:"_id"`
+	ArtifactID         string     `json:"artifact_id" bson:"artifact_id"`
+	ArtifactName       string     `json:"artifact_name" bson:"artifact_name"`
+	DeploymentID       string     `json:"deployment_id" bson:"deployment_id"`
+	DeviceDeploymentID string     `json:"device_deployment_id" bson:"device_deployment_id"`
+	Finished           *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+}
+
+// SoftwareInventoryQuery selects a page of the fleet software inventory.
+type SoftwareInventoryQuery struct {
+	// ArtifactName, if set, only reports the devices running this artifact.
+	ArtifactName string
+	Skip         int
+	Limit        int
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/pkg/errors"
 )
 
@@ -34,6 +35,12 @@ const (
 	storageTypeStrAzure = "azure"
 )
 
+// Server-side encryption modes of the objects stored in s3.
+const (
+	ServerSideEncryptionAES256 = "AES256"
+	ServerSideEncryptionKMS    = "aws:kms"
+)
+
 func (typ *StorageType) UnmarshalText(b []byte) error {
 	switch {
 	case bytes.Equal(b, []byte(storageTypeStrS3)):
@@ -88,6 +95,12 @@ type StorageSettings struct {
 	ForcePathStyle bool `json:"force_path_style" bson:"force_path_style"`
 	// UseAccelerate (s3) enables AWS transfer acceleration.
 	UseAccelerate bool `json:"use_accelerate" bson:"use_accelerate"`
+	// ServerSideEncryption (s3) encrypts the uploaded objects at rest,
+	// either with S3 managed keys (AES256) or with a KMS key (aws:kms).
+	ServerSideEncryption string `json:"server_side_encryption,omitempty" bson:"server_side_encryption,omitempty"`
+	// SSEKMSKeyID (s3) is the ID of the KMS key used with aws:kms
+	// server-side encryption.
+	SSEKMSKeyID string `json:"sse_kms_key_id,omitempty" bson:"sse_kms_key_id,omitempty"`
 }
 
 func ParseStorageSettingsRequest(source io.Reader) (settings *StorageSettings, err error) {
@@ -134,9 +147,37 @@ var (
 	ruleLen3_2000 = validation.Length(3, 2000)
 )
 
-// Validate checks structure according to valid tags
+// ValidationError lists the invalid storage settings fields, by their JSON
+// name, with the reason each was rejected.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (err *ValidationError) Error() string {
+	return err.Unwrap().Error()
+}
+
+// Unwrap returns the field errors as validation.Errors.
+func (err *ValidationError) Unwrap() error {
+	errs := make(validation.Errors, len(err.Fields))
+	for field, reason := range err.Fields {
+		errs[field] = errors.New(reason)
+	}
+	return errs
+}
+
+// StorageSettingsValidationError is the response to storage settings
+// failing validation.
+type StorageSettingsValidationError struct {
+	Error     string            `json:"error"`
+	RequestID string            `json:"request_id"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// Validate checks structure according to valid tags; the fields failing
+// validation are returned as a *ValidationError.
 func (s StorageSettings) Validate() error {
-	return validation.ValidateStruct(&s,
+	err := validation.ValidateStruct(&s,
 		validation.Field(&s.Type, ruleStorageType),
 		validation.Field(&s.Region, validation.When(s.Type == StorageTypeS3,
 			validation.Required, ruleLen5_20,
@@ -147,11 +188,26 @@ func (s StorageSettings) Validate() error {
 			validation.Required, ruleLen5_50,
 		)),
 		validation.Field(&s.Secret, validation.When(
-			s.Type == StorageTypeS3 || s.ConnectionString == nil,
+			s.Type == StorageTypeS3 || s.ConnectionString == nil || s.Key != "",
 			validation.Required, ruleLen5_100,
 		)),
-		validation.Field(&s.Uri, ruleLen3_2000),
-		validation.Field(&s.ExternalUri, ruleLen3_2000),
+		validation.Field(&s.Uri, ruleLen3_2000, is.URL),
+		validation.Field(&s.ExternalUri, ruleLen3_2000, is.URL),
 		validation.Field(&s.Token, ruleLen5_100),
+		validation.Field(&s.ServerSideEncryption, validation.In(
+			ServerSideEncryptionAES256, ServerSideEncryptionKMS,
+		)),
+		validation.Field(&s.SSEKMSKeyID, validation.When(
+			s.ServerSideEncryption == ServerSideEncryptionKMS,
+			validation.Required,
+		)),
 	)
+	if errs, ok := err.(validation.Errors); ok {
+		fields := make(map[string]string, len(errs))
+		for field, fieldErr := range errs {
+			fields[field] = fieldErr.Error()
+		}
+		return &ValidationError{Fields: fields}
+	}
+	return err
 }
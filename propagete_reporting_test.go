@@ -24,6 +24,7 @@ import (
 	workflows_mocks "github.com/mendersoftware/deployments/client/workflows/mocks"
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
 	h "github.com/mendersoftware/deployments/utils/testing"
 )
 
@@ -132,7 +133,14 @@ func TestPropagateReporting(t *testing.T) {
 		t.Run(fmt.Sprintf("tc %s", k), func(t *testing.T) {
 			defer tc.workflowsMock.AssertExpectations(t)
 			defer tc.storeMock.AssertExpectations(t)
-			err := propagateReporting(tc.storeMock, tc.workflowsMock, tc.cmdTenant, time.Microsecond, tc.cmdDryRun)
+			err := propagateReporting(
+				tc.storeMock,
+				mongo.DbName,
+				tc.workflowsMock,
+				tc.cmdTenant,
+				time.Microsecond,
+				tc.cmdDryRun,
+			)
 			assert.NoError(t, err)
 		})
 	}
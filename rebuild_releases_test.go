@@ -0,0 +1,148 @@
+// Copyright 2024 Northern.tech AS
+//
+//	Licensed under the Apache License, Version 2.0 (the "License");
+//	you may not use this file except in compliance with the License.
+//	You may obtain a copy of the License at
+//
+//	    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	Unless required by applicable law or agreed to in writing, software
+//	distributed under the License is distributed on an "AS IS" BASIS,
+//	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	See the License for the specific language governing permissions and
+//	limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store/mocks"
+	"github.com/mendersoftware/deployments/store/mongo"
+)
+
+func tenantMatcher(tenant string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		id := identity.FromContext(ctx)
+		if tenant == "" {
+			return id == nil
+		}
+		return id != nil && id.Tenant == tenant
+	})
+}
+
+func TestRebuildReleases(t *testing.T) {
+	result := &model.ReleasesRebuild{
+		Created: []string{"missing"},
+		Updated: []string{"stale"},
+		Removed: []string{"orphan"},
+	}
+	cases := map[string]struct {
+		storeMock func() *mocks.DataStore
+
+		baseDb    string
+		cmdTenant string
+		cmdDryRun bool
+
+		err error
+	}{
+		"ok, default db": {
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").Return([]string{}, nil)
+				ds.On("RebuildReleases", tenantMatcher(""), false).
+					Return(result, nil)
+				return ds
+			},
+		},
+		"ok, dry-run": {
+			cmdDryRun: true,
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").Return([]string{}, nil)
+				ds.On("RebuildReleases", tenantMatcher(""), true).
+					Return(result, nil)
+				return ds
+			},
+		},
+		"ok, all tenants": {
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").
+					Return([]string{"deployment_service-tenant1", "deployment_service-tenant2"}, nil)
+				ds.On("RebuildReleases", tenantMatcher("tenant1"), false).
+					Return(result, nil)
+				ds.On("RebuildReleases", tenantMatcher("tenant2"), false).
+					Return(&model.ReleasesRebuild{}, nil)
+				return ds
+			},
+		},
+		"ok, custom database name": {
+			baseDb: "staging",
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").
+					Return([]string{"staging-tenant1"}, nil)
+				ds.On("RebuildReleases", tenantMatcher("tenant1"), false).
+					Return(result, nil)
+				return ds
+			},
+		},
+		"ok, single tenant": {
+			cmdTenant: "tenant1",
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("RebuildReleases", tenantMatcher("tenant1"), false).
+					Return(result, nil)
+				return ds
+			},
+		},
+		"error, tenant DBs": {
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").Return(nil, errors.New("connection refused"))
+				return ds
+			},
+			err: errors.New("aborting: failed to retrieve tenant DBs: connection refused"),
+		},
+		"error, rebuilding one tenant": {
+			storeMock: func() *mocks.DataStore {
+				ds := new(mocks.DataStore)
+				ds.On("GetTenantDbs").
+					Return([]string{"deployment_service-tenant1", "deployment_service-tenant2"}, nil)
+				ds.On("RebuildReleases", tenantMatcher("tenant1"), false).
+					Return(nil, errors.New("connection refused"))
+				// the other tenants are still processed
+				ds.On("RebuildReleases", tenantMatcher("tenant2"), false).
+					Return(result, nil)
+				return ds
+			},
+			err: errors.New("failed to rebuild releases: connection refused"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ds := tc.storeMock()
+			defer ds.AssertExpectations(t)
+
+			baseDb := tc.baseDb
+			if baseDb == "" {
+				baseDb = mongo.DbName
+			}
+			err := rebuildReleases(ds, baseDb, tc.cmdTenant, time.Microsecond, tc.cmdDryRun)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/mendersoftware/deployments/storage/manager"
 	"github.com/mendersoftware/deployments/storage/s3"
 	mstore "github.com/mendersoftware/deployments/store/mongo"
+	"github.com/mendersoftware/deployments/utils"
 )
 
 func SetupS3(ctx context.Context, defaultOptions *s3.Options) (storage.ObjectStorage, error) {
@@ -86,6 +88,9 @@ func SetupS3(ctx context.Context, defaultOptions *s3.Options) (storage.ObjectSto
 	if c.IsSet(dconfig.SettingAwsUnsignedHeaders) {
 		options.SetUnsignedHeaders(c.GetStringSlice(dconfig.SettingAwsUnsignedHeaders))
 	}
+	if sse := c.GetString(dconfig.SettingAwsServerSideEncryption); sse != "" {
+		options.SetServerSideEncryption(sse, c.GetString(dconfig.SettingAwsSSEKMSKeyID))
+	}
 
 	storage, err := s3.New(ctx, options)
 	return storage, err
@@ -132,16 +137,21 @@ func SetupBlobStorage(
 func SetupObjectStorage(ctx context.Context) (objManager storage.ObjectStorage, err error) {
 	c := config.Config
 
-	// Calculate s3 multipart buffer size: the minimum buffer size that
-	// covers the maximum image size aligned to multiple of 5MiB.
-	maxImageSize := c.GetInt64(dconfig.SettingStorageMaxImageSize)
-	bufferSize := (((maxImageSize - 1) /
-		(s3.MultipartMaxParts * s3.MultipartMinSize)) + 1) *
-		s3.MultipartMinSize
+	// Calculate s3 multipart buffer size: unless configured explicitly,
+	// the minimum buffer size that covers the maximum image size aligned
+	// to multiple of 5MiB.
+	bufferSize := c.GetInt64(dconfig.SettingStorageMultipartPartSize)
+	if bufferSize == 0 {
+		maxImageSize := c.GetInt64(dconfig.SettingStorageMaxImageSize)
+		bufferSize = (((maxImageSize - 1) /
+			(s3.MultipartMaxParts * s3.MultipartMinSize)) + 1) *
+			s3.MultipartMinSize
+	}
 	var (
 		s3Options = s3.NewOptions().
 				SetContentType(app.ArtifactContentType).
-				SetBufferSize(int(bufferSize))
+				SetBufferSize(int(bufferSize)).
+				SetUploadConcurrency(c.GetInt(dconfig.SettingStorageMultipartConcurrency))
 		azOptions = azblob.NewOptions().
 				SetContentType(app.ArtifactContentType)
 	)
@@ -160,7 +170,74 @@ func SetupObjectStorage(ctx context.Context) (objManager storage.ObjectStorage,
 	if err != nil {
 		return nil, err
 	}
-	return manager.New(ctx, defaultStorage, s3Options, azOptions)
+	objManager, err = manager.New(ctx, defaultStorage, s3Options, azOptions)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewRequestLimiter(
+		objManager,
+		c.GetInt(dconfig.SettingStorageMaxConcurrentGetRequests),
+		time.Duration(c.GetInt(dconfig.SettingStorageGetRequestsMaxWait))*time.Millisecond,
+	), nil
+}
+
+// deletedDeploymentsRetention returns the configured period during which
+// deleted deployments can be restored.
+func deletedDeploymentsRetention(c config.Reader) time.Duration {
+	return time.Duration(c.GetInt(dconfig.SettingDeletedDeploymentsRetention)) *
+		24 * time.Hour
+}
+
+// deviceDeploymentLogsRetention returns the configured period during which
+// the logs of finished device deployments are kept.
+func deviceDeploymentLogsRetention(c config.Reader) time.Duration {
+	return time.Duration(c.GetInt(dconfig.SettingDeviceDeploymentLogsRetention)) *
+		24 * time.Hour
+}
+
+// withStatusUpdateClients configures what follows the device deployment
+// status updates: reindexing the devices and finishing the deployments. Both
+// the server and the storage daemon update the status of device deployments.
+func withStatusUpdateClients(d *app.Deployments, c config.Reader) *app.Deployments {
+	d = d.WithAutoFinishDeployments(c.GetBool(dconfig.SettingAutoFinishDeployments))
+	if addr := c.GetString(dconfig.SettingReportingAddr); addr != "" {
+		reportingClient := reporting.NewClient(addr, utils.RetryPolicy{
+			MaxAttempts: c.GetInt(dconfig.SettingReportingRetryMaxAttempts),
+			BaseDelay: time.Duration(
+				c.GetInt(dconfig.SettingReportingRetryBaseDelay),
+			) * time.Millisecond,
+		})
+		d = d.WithReporting(reportingClient)
+		d = d.WithDeviceAttributesEnrichment(
+			c.GetStringSlice(dconfig.SettingReportingDeviceAttributes),
+			time.Duration(
+				c.GetInt(dconfig.SettingReportingDeviceAttributesCacheTTL),
+			)*time.Second,
+		)
+	}
+	if c.GetBool(dconfig.SettingDeploymentFinishedWorkflowEnable) {
+		d = d.WithDeploymentFinishedWorkflow(
+			c.GetString(dconfig.SettingDeploymentFinishedWorkflow),
+		)
+	}
+	return d
+}
+
+// artifactVerificationKeys loads the configured artifact signing keys.
+func artifactVerificationKeys(c config.Reader) ([]*app.ArtifactVerificationKey, error) {
+	var keys []*app.ArtifactVerificationKey
+	for _, keyPath := range c.GetStringSlice(dconfig.SettingArtifactVerificationKeys) {
+		keyPEM, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read artifact verification key")
+		}
+		key, err := app.NewArtifactVerificationKey(keyPEM)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load %s", keyPath)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
 }
 
 func RunServer(ctx context.Context) error {
@@ -173,7 +250,13 @@ func RunServer(ctx context.Context) error {
 		_ = dbClient.Disconnect(context.Background())
 	}()
 
-	ds := mstore.NewDataStoreMongoWithClient(dbClient)
+	ds := mstore.NewDataStoreMongoWithClient(dbClient).
+		WithDbName(c.GetString(dconfig.SettingDbName)).
+		WithQueryTimeout(time.Duration(c.GetInt(dconfig.SettingMongoQueryTimeout)) * time.Second).
+		WithSubStateHistoryLength(c.GetInt(dconfig.SettingDeviceDeploymentSubStateHistoryLength)).
+		WithMigrationOptions(mstore.MigrationOptions{
+			BackgroundIndexBuild: c.GetBool(dconfig.SettingMongoIndexBuildBackground),
+		})
 
 	// Storage Layer
 	objStore, err := SetupObjectStorage(ctx)
@@ -181,10 +264,59 @@ func RunServer(ctx context.Context) error {
 		return errors.WithMessage(err, "main: failed to setup storage client")
 	}
 
-	app := app.NewDeployments(ds, objStore, 0, false)
-	if addr := c.GetString(dconfig.SettingReportingAddr); addr != "" {
-		c := reporting.NewClient(addr)
-		app = app.WithReporting(c)
+	verificationKeys, err := artifactVerificationKeys(c)
+	if err != nil {
+		return errors.WithMessage(err, "main: failed to setup artifact verification")
+	}
+
+	app := app.NewDeployments(ds, objStore, 0, false).
+		WithDbName(c.GetString(dconfig.SettingDbName)).
+		WithArtifactVerificationKeys(verificationKeys...).
+		WithDeletedDeploymentsRetention(deletedDeploymentsRetention(c)).
+		WithArtifactDeviceTypeCheck(c.GetBool(dconfig.SettingArtifactDeviceTypeCheck)).
+		WithCompatibleArtifactCheck(c.GetBool(dconfig.SettingRequireCompatibleArtifact)).
+		WithMaxArtifactSize(c.GetInt64(dconfig.SettingStorageMaxImageSize)).
+		WithClearAssignedImages(
+			c.GetString(dconfig.SettingDeleteAssignedArtifact) ==
+				dconfig.DeleteAssignedArtifactClear,
+		).
+		WithConfigurationGenerationLimit(
+			c.GetInt(dconfig.SettingConfigurationGenerationMaxConcurrent),
+			time.Duration(
+				c.GetInt(dconfig.SettingConfigurationGenerationMaxWait),
+			)*time.Millisecond,
+		).
+		WithDeviceDeploymentStatusDedup(
+			time.Duration(
+				c.GetInt(dconfig.SettingDeviceDeploymentStatusDedupInterval),
+			)*time.Second,
+		).
+		WithDeviceDeploymentConfirmation(
+			time.Duration(
+				c.GetInt(dconfig.SettingDeviceDeploymentConfirmationTimeout),
+			)*time.Second,
+		).
+		WithDownloadLinkTTL(
+			time.Duration(c.GetInt(dconfig.SettingDownloadLinkTTL))*time.Second,
+			time.Duration(c.GetInt(dconfig.SettingDownloadLinkMaxTTL))*time.Second,
+		).
+		WithDownloadLinkMaxExpire(
+			time.Duration(
+				c.GetInt(dconfig.SettingsStorageDownloadExpireMaxSeconds),
+			)*time.Second,
+		).
+		WithGroupCache(
+			time.Duration(c.GetInt(dconfig.SettingInventoryGroupCacheTTL))*time.Second,
+			c.GetInt(dconfig.SettingInventoryGroupCacheSize),
+		).
+		WithMaintenanceModeCache(
+			time.Duration(c.GetInt(dconfig.SettingMaintenanceModeCacheTTL)) * time.Second,
+		)
+	app = withStatusUpdateClients(app, c)
+	if c.GetBool(dconfig.SettingMaintenanceMode) {
+		if err := app.InitMaintenanceMode(ctx, true); err != nil {
+			return errors.WithMessage(err, "main: failed to enable the maintenance mode")
+		}
 	}
 
 	// Setup API Router configuration
@@ -194,11 +326,28 @@ func RunServer(ctx context.Context) error {
 		SetPresignExpire(time.Second * expireSec).
 		SetPresignHostname(c.GetString(dconfig.SettingPresignHost)).
 		SetPresignScheme(c.GetString(dconfig.SettingPresignScheme)).
+		SetPresignAlgorithm(c.GetString(dconfig.SettingPresignAlgorithm)).
 		SetMaxImageSize(c.GetInt64(dconfig.SettingStorageMaxImageSize)).
 		SetMaxGenerateDataSize(c.GetInt64(dconfig.SettingStorageMaxGenerateSize)).
 		SetEnableDirectUpload(c.GetBool(dconfig.SettingStorageEnableDirectUpload)).
 		SetEnableDirectUploadSkipVerify(c.GetBool(dconfig.SettingStorageDirectUploadSkipVerify)).
-		SetDisableNewReleasesFeature(c.GetBool(dconfig.SettingDisableNewReleasesFeature))
+		SetDisableNewReleasesFeature(c.GetBool(dconfig.SettingDisableNewReleasesFeature)).
+		SetMaintenanceRetryAfter(
+			time.Duration(c.GetInt(dconfig.SettingMaintenanceRetryAfter)) * time.Second,
+		).
+		SetGenerateRetryAfter(
+			time.Duration(
+				c.GetInt(dconfig.SettingConfigurationGenerationRetryAfter),
+			) * time.Second,
+		).
+		SetDeletedDeploymentStatusResponse(
+			c.GetString(dconfig.SettingDeletedDeploymentStatusResponse),
+		).
+		SetDuplicateDeviceIDs(c.GetString(dconfig.SettingDuplicateDeviceIDs))
+	apiConf.SetCompressResponses(
+		c.GetBool(dconfig.SettingCompressResponses),
+		c.GetInt(dconfig.SettingCompressResponsesMinSize),
+	)
 	if key, err := base64.RawStdEncoding.DecodeString(
 		base64Repl.Replace(
 			c.GetString(dconfig.SettingPresignSecret),
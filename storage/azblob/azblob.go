@@ -61,6 +61,30 @@ func NewEmpty(ctx context.Context, opts ...*Options) (storage.ObjectStorage, err
 	return objStore, nil
 }
 
+// NewFromSettings initializes a new client using the StorageSettings of a
+// tenant instead of the settings provided with the Context.
+func NewFromSettings(
+	ctx context.Context,
+	settings *model.StorageSettings,
+	opts ...*Options,
+) (storage.ObjectStorage, error) {
+	objStore, err := NewEmpty(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := objStore.(*client)
+	ctx = storage.SettingsWithContext(ctx, settings)
+	c.DefaultClient, err = c.clientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.credentials, c.proxyURL, err = c.signParamsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func New(ctx context.Context, bucket string, opts ...*Options) (storage.ObjectStorage, error) {
 	var (
 		err    error
@@ -218,6 +242,48 @@ func (c *client) GetObject(
 	return out.Body, nil
 }
 
+func (c *client) GetObjectRange(
+	ctx context.Context,
+	objectPath string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, OpError{
+			Op:     OpGetObject,
+			Reason: err,
+		}
+	}
+	bc := azClient.NewBlockBlobClient(objectPath)
+	out, err := bc.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{
+			Offset: offset,
+			Count:  length,
+		},
+	})
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound) {
+		err = storage.ErrObjectNotFound
+	} else if bloberror.HasCode(err, bloberror.InvalidRange) {
+		err = storage.ErrInvalidRange
+	}
+	if err != nil {
+		return nil, OpError{
+			Op:     OpGetObject,
+			Reason: err,
+		}
+	}
+	if out.ContentLength != nil {
+		return objectReader{
+			ReadCloser: out.Body,
+			length:     *out.ContentLength,
+		}, nil
+	}
+	return out.Body, nil
+}
+
 func (c *client) PutObject(
 	ctx context.Context,
 	objectPath string,
@@ -320,6 +386,51 @@ func (c *client) StatObject(
 	}, nil
 }
 
+func (c *client) ListObjects(
+	ctx context.Context,
+	prefix string,
+	fn func([]storage.ObjectInfo) error,
+) error {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return OpError{
+			Op:     OpListObjects,
+			Reason: err,
+		}
+	}
+	pager := azClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return OpError{
+				Op:      OpListObjects,
+				Message: "failed to list blobs",
+				Reason:  err,
+			}
+		}
+		objects := make([]storage.ObjectInfo, 0, len(page.Segment.BlobItems))
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			info := storage.ObjectInfo{
+				Path: *item.Name,
+			}
+			if item.Properties != nil {
+				info.LastModified = item.Properties.LastModified
+				info.Size = item.Properties.ContentLength
+			}
+			objects = append(objects, info)
+		}
+		if err = fn(objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *client) buildSignedURL(
 	ctx context.Context,
 	method string,
@@ -479,10 +590,13 @@ func (c *client) DeleteRequest(
 	return link, nil
 }
 
+// PutRequest ignores the size: shared access signatures cannot restrict the
+// size of the blob.
 func (c *client) PutRequest(
 	ctx context.Context,
 	objectPath string,
 	duration time.Duration,
+	_ int64,
 ) (*model.Link, error) {
 	azClient, err := c.clientFromContext(ctx)
 	if err != nil {
@@ -512,3 +626,26 @@ func (c *client) PutRequest(
 	}
 	return link, nil
 }
+
+// Multipart uploads are only available with S3: the uncommitted blocks of
+// a blob cannot be assembled from client-provided identifiers.
+
+func (c *client) CreateMultipartUpload(context.Context, string) (string, error) {
+	return "", storage.ErrMultipartNotSupported
+}
+
+func (c *client) UploadPartRequest(
+	context.Context, string, string, int, time.Duration,
+) (*model.Link, error) {
+	return nil, storage.ErrMultipartNotSupported
+}
+
+func (c *client) CompleteMultipartUpload(
+	context.Context, string, string, []model.UploadPart,
+) error {
+	return storage.ErrMultipartNotSupported
+}
+
+func (c *client) AbortMultipartUpload(context.Context, string, string) error {
+	return storage.ErrMultipartNotSupported
+}
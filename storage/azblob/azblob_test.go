@@ -234,7 +234,7 @@ func TestObjectStorage(t *testing.T) {
 				}
 			}
 
-			link, err = c.PutRequest(ctx, subPrefix+"bar", time.Minute*5)
+			link, err = c.PutRequest(ctx, subPrefix+"bar", time.Minute*5, 0)
 			if assert.NoError(t, err) {
 				req, err := http.NewRequest(link.Method, link.Uri, strings.NewReader(blobContent))
 				if assert.NoError(t, err) {
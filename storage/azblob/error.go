@@ -46,6 +46,7 @@ const (
 	OpPutObject     = "PutObject"
 	OpDeleteObject  = "DeleteObject"
 	OpStatObject    = "StatObject"
+	OpListObjects   = "ListObjects"
 	OpGetRequest    = "GetRequest"
 	OpDeleteRequest = "DeleteRequest"
 	OpPutRequest    = "PutRequest"
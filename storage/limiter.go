@@ -0,0 +1,90 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+var (
+	ErrTooManyRequests = errors.New("too many concurrent requests to the object storage")
+)
+
+type requestLimiter struct {
+	ObjectStorage
+	sem     chan struct{}
+	maxWait time.Duration
+}
+
+// NewRequestLimiter wraps objStore such that at most maxConcurrent calls to
+// GetRequest are in flight at any time. Callers exceeding the limit are queued
+// for up to maxWait, after which ErrTooManyRequests is returned. A
+// maxConcurrent less than 1 disables the limit.
+func NewRequestLimiter(
+	objStore ObjectStorage,
+	maxConcurrent int,
+	maxWait time.Duration,
+) ObjectStorage {
+	if maxConcurrent < 1 {
+		return objStore
+	}
+	return &requestLimiter{
+		ObjectStorage: objStore,
+		sem:           make(chan struct{}, maxConcurrent),
+		maxWait:       maxWait,
+	}
+}
+
+func (l *requestLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.maxWait <= 0 {
+		return ErrTooManyRequests
+	}
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrTooManyRequests
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *requestLimiter) release() {
+	<-l.sem
+}
+
+func (l *requestLimiter) GetRequest(
+	ctx context.Context,
+	path string,
+	filename string,
+	duration time.Duration,
+) (*model.Link, error) {
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer l.release()
+	return l.ObjectStorage.GetRequest(ctx, path, filename, duration)
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// slowSigner counts the concurrent calls to GetRequest, each of which
+// blocks until unblock is closed.
+type slowSigner struct {
+	ObjectStorage
+	inFlight    int32
+	maxInFlight int32
+	unblock     chan struct{}
+}
+
+func (s *slowSigner) GetRequest(
+	ctx context.Context,
+	path string,
+	filename string,
+	duration time.Duration,
+) (*model.Link, error) {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, n) {
+			break
+		}
+	}
+	<-s.unblock
+	return &model.Link{Uri: "http://localhost/" + path}, nil
+}
+
+func TestRequestLimiterBurst(t *testing.T) {
+	t.Parallel()
+	const (
+		maxConcurrent = 4
+		burst         = 64
+	)
+	signer := &slowSigner{unblock: make(chan struct{})}
+	limiter := NewRequestLimiter(signer, maxConcurrent, time.Minute)
+
+	var (
+		wg     sync.WaitGroup
+		errCnt int32
+	)
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limiter.GetRequest(
+				context.Background(), "artifact", "artifact.mender", time.Minute,
+			)
+			if err != nil {
+				atomic.AddInt32(&errCnt, 1)
+			}
+		}()
+	}
+	// Give the burst the chance to saturate the limiter before letting
+	// the requests through.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&signer.inFlight) == maxConcurrent
+	}, time.Second*5, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 50)
+	close(signer.unblock)
+	wg.Wait()
+
+	assert.Equal(t, int32(maxConcurrent), atomic.LoadInt32(&signer.maxInFlight))
+	assert.Zero(t, atomic.LoadInt32(&errCnt))
+}
+
+func TestRequestLimiterSaturated(t *testing.T) {
+	t.Parallel()
+	signer := &slowSigner{unblock: make(chan struct{})}
+	defer close(signer.unblock)
+	limiter := NewRequestLimiter(signer, 1, time.Millisecond*10)
+
+	go func() {
+		_, _ = limiter.GetRequest(context.Background(), "a", "a", time.Minute)
+	}()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&signer.inFlight) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	_, err := limiter.GetRequest(context.Background(), "b", "b", time.Minute)
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.(*requestLimiter).maxWait = time.Minute
+	_, err = limiter.GetRequest(ctx, "c", "c", time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRequestLimiterDisabled(t *testing.T) {
+	t.Parallel()
+	signer := &slowSigner{}
+	assert.Same(t, ObjectStorage(signer), NewRequestLimiter(signer, 0, time.Second))
+}
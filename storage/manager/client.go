@@ -18,8 +18,12 @@ import (
 	"context"
 	"errors"
 	"io"
+	"reflect"
+	"sync"
 	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
+
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/storage/azblob"
@@ -30,9 +34,24 @@ var (
 	ErrInvalidProvider = errors.New("manager: invalid storage provider")
 )
 
+type newStorageFunc func(
+	ctx context.Context,
+	settings *model.StorageSettings,
+) (storage.ObjectStorage, error)
+
+// tenantStorage is the client of a tenant along with the settings it was
+// created from.
+type tenantStorage struct {
+	settings model.StorageSettings
+	storage.ObjectStorage
+}
+
 type client struct {
 	defaultStorage storage.ObjectStorage
-	providerMap    map[model.StorageType]storage.ObjectStorage
+	providerMap    map[model.StorageType]newStorageFunc
+
+	mutex   sync.RWMutex
+	tenants map[string]*tenantStorage
 }
 
 func New(
@@ -41,40 +60,72 @@ func New(
 	s3Options *s3.Options,
 	azOptions *azblob.Options,
 ) (storage.ObjectStorage, error) {
-	var err error
-	providerMap := make(map[model.StorageType]storage.ObjectStorage, 2)
-	providerMap[model.StorageTypeAzure], err = azblob.NewEmpty(ctx, azOptions)
-	if err != nil {
-		return nil, err
-	}
-	providerMap[model.StorageTypeS3], err = s3.NewEmpty(ctx, s3Options)
-	if err != nil {
-		return nil, err
+	providerMap := map[model.StorageType]newStorageFunc{
+		model.StorageTypeAzure: func(
+			ctx context.Context,
+			settings *model.StorageSettings,
+		) (storage.ObjectStorage, error) {
+			return azblob.NewFromSettings(ctx, settings, azOptions)
+		},
+		model.StorageTypeS3: func(
+			ctx context.Context,
+			settings *model.StorageSettings,
+		) (storage.ObjectStorage, error) {
+			return s3.NewFromSettings(ctx, settings, s3Options)
+		},
 	}
 
 	return &client{
 		defaultStorage: defaultStore,
 		providerMap:    providerMap,
+		tenants:        make(map[string]*tenantStorage),
 	}, nil
 }
 
+// clientFromContext returns the client of the storage settings in the
+// context, or the default client without settings. The clients are cached
+// by tenant until the settings of the tenant change; the returned context
+// no longer carries the settings, these being part of the client.
 func (c *client) clientFromContext(
 	ctx context.Context,
-) (objStore storage.ObjectStorage, err error) {
-	var ok bool
-	if settings, _ := storage.SettingsFromContext(ctx); settings != nil {
-		if objStore, ok = c.providerMap[settings.Type]; !ok {
-			err = ErrInvalidProvider
-		}
-	} else {
-		objStore = c.defaultStorage
+) (context.Context, storage.ObjectStorage, error) {
+	settings, _ := storage.SettingsFromContext(ctx)
+	if settings == nil {
+		return ctx, c.defaultStorage, nil
+	}
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
 	}
-	return objStore, err
+	ctx = storage.SettingsWithContext(ctx, nil)
+
+	c.mutex.RLock()
+	tenant, ok := c.tenants[tenantID]
+	c.mutex.RUnlock()
+	if ok && reflect.DeepEqual(tenant.settings, *settings) {
+		return ctx, tenant.ObjectStorage, nil
+	}
+
+	newStorage, ok := c.providerMap[settings.Type]
+	if !ok {
+		return ctx, nil, ErrInvalidProvider
+	}
+	objStore, err := newStorage(ctx, settings)
+	if err != nil {
+		return ctx, nil, err
+	}
+	c.mutex.Lock()
+	c.tenants[tenantID] = &tenantStorage{
+		settings:      *settings,
+		ObjectStorage: objStore,
+	}
+	c.mutex.Unlock()
+	return ctx, objStore, nil
 }
 
 func (c *client) HealthCheck(ctx context.Context) (err error) {
 	var objStore storage.ObjectStorage
-	objStore, err = c.clientFromContext(ctx)
+	ctx, objStore, err = c.clientFromContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -82,15 +133,27 @@ func (c *client) HealthCheck(ctx context.Context) (err error) {
 }
 
 func (c *client) GetObject(ctx context.Context, path string) (io.ReadCloser, error) {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return objStore.GetObject(ctx, path)
 }
 
+func (c *client) GetObjectRange(
+	ctx context.Context,
+	path string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objStore.GetObjectRange(ctx, path, offset, length)
+}
+
 func (c *client) PutObject(ctx context.Context, path string, src io.Reader) error {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -99,7 +162,7 @@ func (c *client) PutObject(ctx context.Context, path string, src io.Reader) erro
 }
 
 func (c *client) DeleteObject(ctx context.Context, path string) error {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -107,20 +170,32 @@ func (c *client) DeleteObject(ctx context.Context, path string) error {
 }
 
 func (c *client) StatObject(ctx context.Context, path string) (*storage.ObjectInfo, error) {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return objStore.StatObject(ctx, path)
 }
 
+func (c *client) ListObjects(
+	ctx context.Context,
+	prefix string,
+	fn func([]storage.ObjectInfo) error,
+) error {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return objStore.ListObjects(ctx, prefix, fn)
+}
+
 func (c *client) GetRequest(
 	ctx context.Context,
 	path string,
 	filename string,
 	duration time.Duration,
 ) (*model.Link, error) {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +207,7 @@ func (c *client) DeleteRequest(
 	path string,
 	duration time.Duration,
 ) (*model.Link, error) {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -143,10 +218,54 @@ func (c *client) PutRequest(
 	ctx context.Context,
 	path string,
 	duration time.Duration,
+	size int64,
+) (*model.Link, error) {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objStore.PutRequest(ctx, path, duration, size)
+}
+
+func (c *client) CreateMultipartUpload(ctx context.Context, path string) (string, error) {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return objStore.CreateMultipartUpload(ctx, path)
+}
+
+func (c *client) UploadPartRequest(
+	ctx context.Context,
+	path string,
+	uploadID string,
+	partNumber int,
+	duration time.Duration,
 ) (*model.Link, error) {
-	objStore, err := c.clientFromContext(ctx)
+	ctx, objStore, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return objStore.PutRequest(ctx, path, duration)
+	return objStore.UploadPartRequest(ctx, path, uploadID, partNumber, duration)
+}
+
+func (c *client) CompleteMultipartUpload(
+	ctx context.Context,
+	path string,
+	uploadID string,
+	parts []model.UploadPart,
+) error {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return objStore.CompleteMultipartUpload(ctx, path, uploadID, parts)
+}
+
+func (c *client) AbortMultipartUpload(ctx context.Context, path string, uploadID string) error {
+	ctx, objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return objStore.AbortMultipartUpload(ctx, path, uploadID)
 }
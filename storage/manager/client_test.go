@@ -0,0 +1,104 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+	"github.com/mendersoftware/deployments/storage/mocks"
+)
+
+func TestClientFromContext(t *testing.T) {
+	t.Parallel()
+
+	settings := func(bucket string) *model.StorageSettings {
+		return &model.StorageSettings{
+			Type:   model.StorageTypeS3,
+			Region: "eu-west-1",
+			Bucket: bucket,
+			Key:    "tenant-key",
+			Secret: "tenant-secret",
+		}
+	}
+	tenantContext := func(tenantID string, settings *model.StorageSettings) context.Context {
+		ctx := identity.WithContext(context.Background(), &identity.Identity{
+			Tenant: tenantID,
+		})
+		return storage.SettingsWithContext(ctx, settings)
+	}
+	// the clients get no settings with the context, these being theirs
+	withoutSettings := mock.MatchedBy(func(ctx context.Context) bool {
+		settings, _ := storage.SettingsFromContext(ctx)
+		return settings == nil
+	})
+
+	defaultStorage := &mocks.ObjectStorage{}
+	defer defaultStorage.AssertExpectations(t)
+	defaultStorage.On("DeleteObject", withoutSettings, "default").
+		Return(nil).
+		Twice()
+
+	var created []*model.StorageSettings
+	c := &client{
+		defaultStorage: defaultStorage,
+		providerMap: map[model.StorageType]newStorageFunc{
+			model.StorageTypeS3: func(
+				ctx context.Context,
+				settings *model.StorageSettings,
+			) (storage.ObjectStorage, error) {
+				if settings.Bucket == "invalid" {
+					return nil, errors.New("invalid settings")
+				}
+				created = append(created, settings)
+				objStore := &mocks.ObjectStorage{}
+				objStore.On("DeleteObject", withoutSettings, settings.Bucket).
+					Return(nil)
+				return objStore, nil
+			},
+		},
+		tenants: make(map[string]*tenantStorage),
+	}
+
+	assert.NoError(t, c.DeleteObject(context.Background(), "default"))
+	assert.NoError(t, c.DeleteObject(
+		storage.SettingsWithContext(context.Background(), nil), "default"),
+		"no settings",
+	)
+	assert.NoError(t, c.DeleteObject(tenantContext("tenant1", settings("bucket-1")), "bucket-1"))
+	assert.NoError(t, c.DeleteObject(tenantContext("tenant1", settings("bucket-1")), "bucket-1"),
+		"cached client")
+	assert.NoError(t, c.DeleteObject(tenantContext("tenant2", settings("bucket-1")), "bucket-1"),
+		"other tenant")
+	assert.NoError(t, c.DeleteObject(tenantContext("tenant1", settings("bucket-2")), "bucket-2"),
+		"settings changed")
+	assert.Equal(t, []*model.StorageSettings{
+		settings("bucket-1"), settings("bucket-1"), settings("bucket-2"),
+	}, created)
+
+	err := c.DeleteObject(tenantContext("tenant1", settings("invalid")), "invalid")
+	assert.EqualError(t, err, "invalid settings")
+	azSettings := settings("bucket-1")
+	azSettings.Type = model.StorageTypeAzure
+	err = c.DeleteObject(tenantContext("tenant1", azSettings), "bucket-1")
+	assert.ErrorIs(t, err, ErrInvalidProvider)
+}
@@ -34,6 +34,55 @@ type ObjectStorage struct {
 	mock.Mock
 }
 
+// AbortMultipartUpload provides a mock function with given fields: ctx, path, uploadID
+func (_m *ObjectStorage) AbortMultipartUpload(ctx context.Context, path string, uploadID string) error {
+	ret := _m.Called(ctx, path, uploadID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, path, uploadID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CompleteMultipartUpload provides a mock function with given fields: ctx, path, uploadID, parts
+func (_m *ObjectStorage) CompleteMultipartUpload(ctx context.Context, path string, uploadID string, parts []model.UploadPart) error {
+	ret := _m.Called(ctx, path, uploadID, parts)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []model.UploadPart) error); ok {
+		r0 = rf(ctx, path, uploadID, parts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateMultipartUpload provides a mock function with given fields: ctx, path
+func (_m *ObjectStorage) CreateMultipartUpload(ctx context.Context, path string) (string, error) {
+	ret := _m.Called(ctx, path)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, path)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, path)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteObject provides a mock function with given fields: ctx, path
 func (_m *ObjectStorage) DeleteObject(ctx context.Context, path string) error {
 	ret := _m.Called(ctx, path)
@@ -94,6 +143,29 @@ func (_m *ObjectStorage) GetObject(ctx context.Context, path string) (io.ReadClo
 	return r0, r1
 }
 
+// GetObjectRange provides a mock function with given fields: ctx, path, offset, length
+func (_m *ObjectStorage) GetObjectRange(ctx context.Context, path string, offset int64, length int64) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, path, offset, length)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, int64) io.ReadCloser); ok {
+		r0 = rf(ctx, path, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64, int64) error); ok {
+		r1 = rf(ctx, path, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRequest provides a mock function with given fields: ctx, path, filename, duration
 func (_m *ObjectStorage) GetRequest(ctx context.Context, path string, filename string, duration time.Duration) (*model.Link, error) {
 	ret := _m.Called(ctx, path, filename, duration)
@@ -131,6 +203,20 @@ func (_m *ObjectStorage) HealthCheck(ctx context.Context) error {
 	return r0
 }
 
+// ListObjects provides a mock function with given fields: ctx, prefix, fn
+func (_m *ObjectStorage) ListObjects(ctx context.Context, prefix string, fn func([]storage.ObjectInfo) error) error {
+	ret := _m.Called(ctx, prefix, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, func([]storage.ObjectInfo) error) error); ok {
+		r0 = rf(ctx, prefix, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // PutObject provides a mock function with given fields: ctx, path, src
 func (_m *ObjectStorage) PutObject(ctx context.Context, path string, src io.Reader) error {
 	ret := _m.Called(ctx, path, src)
@@ -145,13 +231,13 @@ func (_m *ObjectStorage) PutObject(ctx context.Context, path string, src io.Read
 	return r0
 }
 
-// PutRequest provides a mock function with given fields: ctx, path, duration
-func (_m *ObjectStorage) PutRequest(ctx context.Context, path string, duration time.Duration) (*model.Link, error) {
-	ret := _m.Called(ctx, path, duration)
+// PutRequest provides a mock function with given fields: ctx, path, duration, size
+func (_m *ObjectStorage) PutRequest(ctx context.Context, path string, duration time.Duration, size int64) (*model.Link, error) {
+	ret := _m.Called(ctx, path, duration, size)
 
 	var r0 *model.Link
-	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) *model.Link); ok {
-		r0 = rf(ctx, path, duration)
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration, int64) *model.Link); ok {
+		r0 = rf(ctx, path, duration, size)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Link)
@@ -159,8 +245,8 @@ func (_m *ObjectStorage) PutRequest(ctx context.Context, path string, duration t
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
-		r1 = rf(ctx, path, duration)
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration, int64) error); ok {
+		r1 = rf(ctx, path, duration, size)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -191,6 +277,29 @@ func (_m *ObjectStorage) StatObject(ctx context.Context, path string) (*storage.
 	return r0, r1
 }
 
+// UploadPartRequest provides a mock function with given fields: ctx, path, uploadID, partNumber, duration
+func (_m *ObjectStorage) UploadPartRequest(ctx context.Context, path string, uploadID string, partNumber int, duration time.Duration) (*model.Link, error) {
+	ret := _m.Called(ctx, path, uploadID, partNumber, duration)
+
+	var r0 *model.Link
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, time.Duration) *model.Link); ok {
+		r0 = rf(ctx, path, uploadID, partNumber, duration)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Link)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int, time.Duration) error); ok {
+		r1 = rf(ctx, path, uploadID, partNumber, duration)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewObjectStorage interface {
 	mock.TestingT
 	Cleanup(func())
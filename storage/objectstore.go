@@ -25,6 +25,11 @@ import (
 
 var (
 	ErrObjectNotFound = errors.New("object not found")
+	ErrInvalidRange   = errors.New("requested range not satisfiable")
+
+	ErrMultipartNotSupported = errors.New(
+		"multipart uploads are not supported by the storage provider",
+	)
 )
 
 // ObjectStorage allows to store and manage large files
@@ -33,17 +38,37 @@ var (
 type ObjectStorage interface {
 	HealthCheck(ctx context.Context) error
 	GetObject(ctx context.Context, path string) (io.ReadCloser, error)
+	// GetObjectRange returns length bytes of the object starting at offset.
+	GetObjectRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
 	PutObject(ctx context.Context, path string, src io.Reader) error
 	DeleteObject(ctx context.Context, path string) error
 	StatObject(ctx context.Context, path string) (*ObjectInfo, error)
+	// ListObjects calls fn with each page of the objects with keys
+	// starting with prefix, stopping at the first error fn returns.
+	ListObjects(ctx context.Context, prefix string, fn func([]ObjectInfo) error) error
 
 	// The following interface generates signed URLs.
 	GetRequest(ctx context.Context, path string, filename string,
 		duration time.Duration) (*model.Link, error)
 	DeleteRequest(ctx context.Context, path string,
 		duration time.Duration) (*model.Link, error)
+	// PutRequest signs the size of the upload into the URL, unless
+	// zero or not supported by the storage backend.
 	PutRequest(ctx context.Context, path string,
-		duration time.Duration) (*model.Link, error)
+		duration time.Duration, size int64) (*model.Link, error)
+
+	// The following interface manages multipart uploads, of which the
+	// parts are uploaded by the client using signed URLs.
+	CreateMultipartUpload(ctx context.Context, path string) (uploadID string, err error)
+	UploadPartRequest(ctx context.Context, path string, uploadID string,
+		partNumber int, duration time.Duration) (*model.Link, error)
+	// CompleteMultipartUpload assembles the parts into the object. It
+	// returns ErrObjectNotFound if the upload does not exist (anymore).
+	CompleteMultipartUpload(ctx context.Context, path string, uploadID string,
+		parts []model.UploadPart) error
+	// AbortMultipartUpload discards the uploaded parts. It returns
+	// ErrObjectNotFound if the upload does not exist (anymore).
+	AbortMultipartUpload(ctx context.Context, path string, uploadID string) error
 }
 
 type ObjectInfo struct {
@@ -0,0 +1,153 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsHttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// noSuchUpload maps the error of an unknown multipart upload.
+func noSuchUpload(err error) error {
+	var rspErr *awsHttp.ResponseError
+	if errors.As(err, &rspErr) &&
+		rspErr.Response.StatusCode == http.StatusNotFound {
+		return storage.ErrObjectNotFound
+	}
+	return err
+}
+
+func (s *SimpleStorageService) CreateMultipartUpload(
+	ctx context.Context,
+	path string,
+) (string, error) {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	sse, kmsKeyID := opts.serverSideEncryption()
+	rsp, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               opts.BucketName,
+		Key:                  aws.String(path),
+		ContentType:          s.contentType,
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	}, opts.options)
+	if err != nil {
+		return "", errors.WithMessage(err, "s3: error creating multipart upload")
+	}
+	return aws.ToString(rsp.UploadId), nil
+}
+
+// UploadPartRequest duration is limited to 7 days (AWS limitation)
+func (s *SimpleStorageService) UploadPartRequest(
+	ctx context.Context,
+	path string,
+	uploadID string,
+	partNumber int,
+	expireAfter time.Duration,
+) (*model.Link, error) {
+	expireAfter = capDurationToLimits(expireAfter).Truncate(time.Second)
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &s3.UploadPartInput{
+		Bucket:     opts.BucketName,
+		Key:        aws.String(path),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(int32(partNumber)),
+	}
+	signDate := time.Now()
+	req, err := s.presignClient.PresignUploadPart(
+		ctx,
+		params,
+		opts.presignOptions,
+		s3.WithPresignExpires(expireAfter),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return buildLink(req, signDate, expireAfter, opts.ProxyURI)
+}
+
+func (s *SimpleStorageService) CompleteMultipartUpload(
+	ctx context.Context,
+	path string,
+	uploadID string,
+	parts []model.UploadPart,
+) error {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	completedParts := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completedParts[i] = types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(int32(part.PartNumber)),
+		}
+	}
+	// Parts must be listed in ascending order
+	sort.Slice(completedParts, func(i, j int) bool {
+		return *completedParts[i].PartNumber < *completedParts[j].PartNumber
+	})
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   opts.BucketName,
+		Key:      aws.String(path),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: completedParts,
+		},
+	}, opts.options)
+	if err != nil {
+		return errors.WithMessage(noSuchUpload(err),
+			"s3: error completing multipart upload")
+	}
+	return nil
+}
+
+func (s *SimpleStorageService) AbortMultipartUpload(
+	ctx context.Context,
+	path string,
+	uploadID string,
+) error {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   opts.BucketName,
+		Key:      aws.String(path),
+		UploadId: aws.String(uploadID),
+	}, opts.options)
+	if err != nil {
+		return errors.WithMessage(noSuchUpload(err),
+			"s3: error aborting multipart upload")
+	}
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 
 	"github.com/mendersoftware/deployments/model"
@@ -35,8 +36,9 @@ const (
 	kib = 1024
 	mib = kib * 1024
 
-	DefaultBufferSize = 10 * mib
-	DefaultExpire     = 15 * time.Minute
+	DefaultBufferSize        = 10 * mib
+	DefaultUploadConcurrency = 1
+	DefaultExpire            = 15 * time.Minute
 )
 
 var (
@@ -66,6 +68,12 @@ type storageSettings struct {
 	ForcePathStyle bool
 	// UseAccelerate enables s3 Accelerate
 	UseAccelerate bool
+
+	// ServerSideEncryption is the encryption at rest of the uploaded
+	// objects (AES256 or aws:kms).
+	ServerSideEncryption *string
+	// SSEKMSKeyID is the KMS key used by aws:kms server-side encryption.
+	SSEKMSKeyID *string
 }
 
 func newFromParent(defaults *storageSettings, parent *model.StorageSettings) *storageSettings {
@@ -99,15 +107,42 @@ func newFromParent(defaults *storageSettings, parent *model.StorageSettings) *st
 	if parent.UseAccelerate != ret.UseAccelerate {
 		ret.UseAccelerate = parent.UseAccelerate
 	}
+	if parent.ServerSideEncryption != "" {
+		ret.ServerSideEncryption = &parent.ServerSideEncryption
+		ret.SSEKMSKeyID = nil
+		if parent.SSEKMSKeyID != "" {
+			ret.SSEKMSKeyID = &parent.SSEKMSKeyID
+		}
+	}
 	return ret
 }
 
 func (s storageSettings) Validate() error {
+	kms := s.ServerSideEncryption != nil &&
+		*s.ServerSideEncryption == model.ServerSideEncryptionKMS
 	return validation.ValidateStruct(&s,
 		validation.Field(&s.StaticCredentials),
+		validation.Field(&s.ServerSideEncryption, validation.In(
+			model.ServerSideEncryptionAES256,
+			model.ServerSideEncryptionKMS,
+		)),
+		validation.Field(&s.SSEKMSKeyID, validation.When(kms, validation.Required)),
 	)
 }
 
+// serverSideEncryption returns the encryption parameters of the object
+// uploads; the KMS key only applies to aws:kms encryption.
+func (s storageSettings) serverSideEncryption() (types.ServerSideEncryption, *string) {
+	if s.ServerSideEncryption == nil {
+		return "", nil
+	}
+	sse := types.ServerSideEncryption(*s.ServerSideEncryption)
+	if *s.ServerSideEncryption != model.ServerSideEncryptionKMS {
+		return sse, nil
+	}
+	return sse, s.SSEKMSKeyID
+}
+
 func (s storageSettings) options(opts *s3.Options) {
 	if s.StaticCredentials != nil {
 		opts.Credentials = *s.StaticCredentials
@@ -157,6 +192,10 @@ func (s *storageSettings) patch(setting *storageSettings) *storageSettings {
 	if setting.UseAccelerate != s.UseAccelerate {
 		s.UseAccelerate = setting.UseAccelerate
 	}
+	if setting.ServerSideEncryption != nil {
+		s.ServerSideEncryption = setting.ServerSideEncryption
+		s.SSEKMSKeyID = setting.SSEKMSKeyID
+	}
 	return s
 }
 
@@ -174,6 +213,10 @@ type Options struct {
 	// This implicitly sets the upper limit for upload size:
 	// BufferSize * 10000 (defaults to: 5MiB).
 	BufferSize *int
+	// UploadConcurrency sets the number of parts uploaded in parallel
+	// by multipart uploads. Each part holds a buffer of BufferSize
+	// bytes (defaults to: 1).
+	UploadConcurrency *int
 
 	// UnsignedHeaders forces the driver to skip the named headers from the
 	// being signed.
@@ -186,8 +229,10 @@ type Options struct {
 
 func NewOptions(opts ...*Options) *Options {
 	defaultBufferSize := DefaultBufferSize
+	defaultUploadConcurrency := DefaultUploadConcurrency
 	ret := &Options{
-		BufferSize: &defaultBufferSize,
+		BufferSize:        &defaultBufferSize,
+		UploadConcurrency: &defaultUploadConcurrency,
 	}
 	for _, opt := range opts {
 		ret.storageSettings.patch(&opt.storageSettings)
@@ -200,6 +245,9 @@ func NewOptions(opts ...*Options) *Options {
 		if opt.BufferSize != nil {
 			ret.BufferSize = opt.BufferSize
 		}
+		if opt.UploadConcurrency != nil {
+			ret.UploadConcurrency = opt.UploadConcurrency
+		}
 		if opt.UnsignedHeaders != nil {
 			ret.UnsignedHeaders = opt.UnsignedHeaders
 		}
@@ -214,6 +262,7 @@ func (opts Options) Validate() error {
 	return validation.ValidateStruct(&opts,
 		validation.Field(&opts.storageSettings),
 		validation.Field(&opts.BufferSize, validAtLeast5MiB),
+		validation.Field(&opts.UploadConcurrency, validation.NilOrNotEmpty, validation.Min(1)),
 	)
 }
 
@@ -271,6 +320,17 @@ func (opts *Options) SetUseAccelerate(useAccelerate bool) *Options {
 	return opts
 }
 
+// SetServerSideEncryption encrypts the uploaded objects at rest; the KMS
+// key ID is required with aws:kms encryption and ignored otherwise.
+func (opts *Options) SetServerSideEncryption(mode, kmsKeyID string) *Options {
+	opts.ServerSideEncryption = &mode
+	opts.SSEKMSKeyID = nil
+	if kmsKeyID != "" {
+		opts.SSEKMSKeyID = &kmsKeyID
+	}
+	return opts
+}
+
 func (opts *Options) SetDefaultExpire(defaultExpire time.Duration) *Options {
 	opts.DefaultExpire = &defaultExpire
 	return opts
@@ -281,6 +341,11 @@ func (opts *Options) SetBufferSize(bufferSize int) *Options {
 	return opts
 }
 
+func (opts *Options) SetUploadConcurrency(concurrency int) *Options {
+	opts.UploadConcurrency = &concurrency
+	return opts
+}
+
 func (opts *Options) SetUnsignedHeaders(unsignedHeaders []string) *Options {
 	opts.UnsignedHeaders = unsignedHeaders
 	return opts
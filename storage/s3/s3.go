@@ -22,6 +22,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -49,6 +53,11 @@ const (
 	// from /aws/signer/v4/internal/v4
 	paramAmzDate       = "X-Amz-Date"
 	paramAmzDateFormat = "20060102T150405Z"
+
+	hdrContentLength = "Content-Length"
+	// hdrAmzServerSideEncryption prefixes the server-side encryption
+	// headers, in canonical form.
+	hdrAmzServerSideEncryption = "X-Amz-Server-Side-Encryption"
 )
 
 var ErrClientEmpty = stderr.New("s3: storage client credentials not configured")
@@ -57,11 +66,12 @@ var ErrClientEmpty = stderr.New("s3: storage client credentials not configured")
 // Data layer for file storage.
 // Implements model.FileStorage interface
 type SimpleStorageService struct {
-	client        *s3.Client
-	presignClient *s3.PresignClient
-	settings      storageSettings
-	bufferSize    int
-	contentType   *string
+	client            *s3.Client
+	presignClient     *s3.PresignClient
+	settings          storageSettings
+	bufferSize        int
+	uploadConcurrency int
+	contentType       *string
 }
 
 type StaticCredentials struct {
@@ -123,9 +133,10 @@ func newClient(
 		client:        client,
 		presignClient: presignClient,
 
-		bufferSize:  *opt.BufferSize,
-		contentType: opt.ContentType,
-		settings:    opt.storageSettings,
+		bufferSize:        *opt.BufferSize,
+		uploadConcurrency: *opt.UploadConcurrency,
+		contentType:       opt.ContentType,
+		settings:          opt.storageSettings,
 	}, nil
 }
 
@@ -137,6 +148,24 @@ func NewEmpty(ctx context.Context, opts ...*Options) (storage.ObjectStorage, err
 	return newClient(ctx, false, opt)
 }
 
+// NewFromSettings initializes a new s3 client using the StorageSettings of a
+// tenant instead of the settings provided with the Context.
+func NewFromSettings(
+	ctx context.Context,
+	settings *model.StorageSettings,
+	opts ...*Options,
+) (storage.ObjectStorage, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	s3c, err := newClient(ctx, false, NewOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	s3c.settings = *newFromParent(&s3c.settings, settings)
+	return s3c, nil
+}
+
 func New(ctx context.Context, opts ...*Options) (storage.ObjectStorage, error) {
 	opt := NewOptions(opts...)
 
@@ -276,6 +305,45 @@ func (s *SimpleStorageService) GetObject(
 	}, nil
 }
 
+func (s *SimpleStorageService) GetObjectRange(
+	ctx context.Context,
+	path string,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := &s3.GetObjectInput{
+		Bucket: opts.BucketName,
+		Key:    aws.String(path),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+
+		RequestPayer: types.RequestPayerRequester,
+	}
+
+	out, err := s.client.GetObject(ctx, params, opts.options)
+	var rspErr *awsHttp.ResponseError
+	if errors.As(err, &rspErr) {
+		switch rspErr.Response.StatusCode {
+		case http.StatusNotFound:
+			err = storage.ErrObjectNotFound
+		case http.StatusRequestedRangeNotSatisfiable:
+			err = storage.ErrInvalidRange
+		}
+	}
+	if err != nil {
+		return nil, errors.WithMessage(
+			err,
+			"s3: failed to get object range",
+		)
+	}
+	return objectReader{
+		ReadCloser: out.Body,
+		length:     *out.ContentLength,
+	}, nil
+}
+
 // Delete removes deleted file from storage.
 // Noop if ID does not exist.
 func (s *SimpleStorageService) DeleteObject(ctx context.Context, path string) error {
@@ -342,6 +410,43 @@ func (s *SimpleStorageService) StatObject(
 	}, nil
 }
 
+// ListObjects calls fn with each page of the objects with keys starting
+// with the given prefix.
+func (s *SimpleStorageService) ListObjects(
+	ctx context.Context,
+	prefix string,
+	fn func([]storage.ObjectInfo) error,
+) error {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := &s3.ListObjectsV2Input{
+		Bucket: opts.BucketName,
+		Prefix: aws.String(prefix),
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, params)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, opts.options)
+		if err != nil {
+			return errors.WithMessage(err, "s3: error listing objects")
+		}
+		objects := make([]storage.ObjectInfo, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			objects = append(objects, storage.ObjectInfo{
+				Path:         aws.ToString(obj.Key),
+				LastModified: obj.LastModified,
+				Size:         obj.Size,
+			})
+		}
+		if err = fn(objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func fillBuffer(b []byte, r io.Reader) (int, error) {
 	var offset int
 	var err error
@@ -351,7 +456,9 @@ func fillBuffer(b []byte, r io.Reader) (int, error) {
 	return offset, err
 }
 
-// uploadMultipart uploads an artifact using the multipart API.
+// uploadMultipart uploads an artifact using the multipart API. Up to
+// s.uploadConcurrency parts are uploaded in parallel, each from a separate
+// buffer of len(buf) bytes.
 func (s *SimpleStorageService) uploadMultipart(
 	ctx context.Context,
 	buf []byte,
@@ -360,20 +467,19 @@ func (s *SimpleStorageService) uploadMultipart(
 ) error {
 	const maxPartNum = 10000
 	var partNum int32 = 1
-	var rspUpload *s3.UploadPartOutput
 	opts, err := s.optionsFromContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Pre-allocate 100 completed part (generous guesstimate)
-	completedParts := make([]types.CompletedPart, 0, 100)
-
 	// Initiate Multipart upload
+	sse, kmsKeyID := opts.serverSideEncryption()
 	createParams := &s3.CreateMultipartUploadInput{
-		Bucket:      opts.BucketName,
-		Key:         &objectPath,
-		ContentType: s.contentType,
+		Bucket:               opts.BucketName,
+		Key:                  &objectPath,
+		ContentType:          s.contentType,
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
 	}
 	rspCreate, err := s.client.CreateMultipartUpload(
 		ctx, createParams, opts.options,
@@ -381,51 +487,39 @@ func (s *SimpleStorageService) uploadMultipart(
 	if err != nil {
 		return err
 	}
-	uploadParams := &s3.UploadPartInput{
-		Bucket:     opts.BucketName,
-		Key:        &objectPath,
-		UploadId:   rspCreate.UploadId,
-		PartNumber: aws.Int32(partNum),
-	}
 
-	// Upload the first chunk already stored in buffer
-	r := bytes.NewReader(buf)
-	// Readjust upload parameters
-	uploadParams.Body = r
-	rspUpload, err = s.client.UploadPart(
-		ctx,
-		uploadParams,
-		opts.options,
-	)
-	if err != nil {
-		return err
-	}
-	completedParts = append(
-		completedParts,
-		types.CompletedPart{
-			ETag:       rspUpload.ETag,
-			PartNumber: aws.Int32(partNum),
-		},
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		uploadErr error
+		// Pre-allocate 100 completed part (generous guesstimate)
+		completedParts = make([]types.CompletedPart, 0, 100)
+		// buffers holds the buffers of the finished part uploads
+		buffers    = make(chan []byte, s.uploadConcurrency)
+		numBuffers = 1
 	)
-
-	// The following is loop is very similar to io.Copy except the
-	// destination is the s3 bucket.
-	for partNum++; partNum < maxPartNum; partNum++ {
-		// Read next chunk from stream (fill the whole buffer)
-		offset, eRead := fillBuffer(buf, artifact)
-		if offset > 0 {
-			r := bytes.NewReader(buf[:offset])
-			// Readjust upload parameters
-			uploadParams.PartNumber = aws.Int32(partNum)
-			uploadParams.Body = r
-			rspUpload, err = s.client.UploadPart(
-				ctx,
-				uploadParams,
-				opts.options,
-			)
-			if err != nil {
-				break
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	uploadPart := func(partNum int32, part []byte) {
+		defer wg.Done()
+		rspUpload, err := s.client.UploadPart(
+			partCtx,
+			&s3.UploadPartInput{
+				Bucket:     opts.BucketName,
+				Key:        &objectPath,
+				UploadId:   rspCreate.UploadId,
+				PartNumber: aws.Int32(partNum),
+				Body:       bytes.NewReader(part),
+			},
+			opts.options,
+		)
+		mu.Lock()
+		if err != nil {
+			if uploadErr == nil {
+				uploadErr = err
+				cancel()
 			}
+		} else {
 			completedParts = append(
 				completedParts,
 				types.CompletedPart{
@@ -433,6 +527,38 @@ func (s *SimpleStorageService) uploadMultipart(
 					PartNumber: aws.Int32(partNum),
 				},
 			)
+		}
+		mu.Unlock()
+		buffers <- part[:cap(part)]
+	}
+
+	// Upload the first chunk already stored in buffer
+	wg.Add(1)
+	go uploadPart(partNum, buf)
+
+	// The following is loop is very similar to io.Copy except the
+	// destination is the s3 bucket.
+	for partNum++; partNum < maxPartNum; partNum++ {
+		var part []byte
+		if numBuffers < s.uploadConcurrency {
+			select {
+			case part = <-buffers:
+			default:
+				part = make([]byte, len(buf))
+				numBuffers++
+			}
+		} else {
+			part = <-buffers
+		}
+		if partCtx.Err() != nil {
+			// One of the parts failed to upload
+			break
+		}
+		// Read next chunk from stream (fill the whole buffer)
+		offset, eRead := fillBuffer(part, artifact)
+		if offset > 0 {
+			wg.Add(1)
+			go uploadPart(partNum, part[:offset])
 		} else {
 			// Read did not return any bytes
 			break
@@ -442,7 +568,15 @@ func (s *SimpleStorageService) uploadMultipart(
 			break
 		}
 	}
+	wg.Wait()
+	if uploadErr != nil {
+		err = uploadErr
+	}
 	if err == nil || err == io.EOF {
+		// Parts must be listed in ascending order
+		sort.Slice(completedParts, func(i, j int) bool {
+			return *completedParts[i].PartNumber < *completedParts[j].PartNumber
+		})
 		// Complete upload
 		uploadParams := &s3.CompleteMultipartUploadInput{
 			Bucket:   opts.BucketName,
@@ -510,12 +644,15 @@ func (s *SimpleStorageService) PutObject(
 			return err
 		}
 		// Ordinary single-file upload
+		sse, kmsKeyID := opts.serverSideEncryption()
 		uploadParams := &s3.PutObjectInput{
-			Body:          r,
-			Bucket:        opts.BucketName,
-			Key:           &path,
-			ContentType:   s.contentType,
-			ContentLength: &l,
+			Body:                 r,
+			Bucket:               opts.BucketName,
+			Key:                  &path,
+			ContentType:          s.contentType,
+			ContentLength:        &l,
+			ServerSideEncryption: sse,
+			SSEKMSKeyId:          kmsKeyID,
 		}
 		_, err = s.client.PutObject(
 			ctx,
@@ -555,10 +692,15 @@ func buildLink(
 	}, nil
 }
 
+// PutRequest signs the Content-Length header into the URL if size is set,
+// so that the storage rejects uploads of any other size. The server-side
+// encryption headers are signed too; the signed headers are returned in the
+// link, for the client to send them along with the upload.
 func (s *SimpleStorageService) PutRequest(
 	ctx context.Context,
 	path string,
 	expireAfter time.Duration,
+	size int64,
 ) (*model.Link, error) {
 
 	expireAfter = capDurationToLimits(expireAfter).Truncate(time.Second)
@@ -567,10 +709,17 @@ func (s *SimpleStorageService) PutRequest(
 		return nil, err
 	}
 
+	sse, kmsKeyID := opts.serverSideEncryption()
 	params := &s3.PutObjectInput{
 		// Required
 		Bucket: opts.BucketName,
 		Key:    aws.String(path),
+
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	}
+	if size > 0 {
+		params.ContentLength = aws.Int64(size)
 	}
 
 	signDate := time.Now()
@@ -583,7 +732,23 @@ func (s *SimpleStorageService) PutRequest(
 	if err != nil {
 		return nil, err
 	}
-	return buildLink(req, signDate, expireAfter, opts.ProxyURI)
+	link, err := buildLink(req, signDate, expireAfter, opts.ProxyURI)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]string{}
+	if size > 0 {
+		header[hdrContentLength] = strconv.FormatInt(size, 10)
+	}
+	for key := range req.SignedHeader {
+		if strings.HasPrefix(http.CanonicalHeaderKey(key), hdrAmzServerSideEncryption) {
+			header[key] = req.SignedHeader.Get(key)
+		}
+	}
+	if len(header) > 0 {
+		link.Header = header
+	}
+	return link, nil
 }
 
 // GetRequest duration is limited to 7 days (AWS limitation)
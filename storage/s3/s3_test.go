@@ -25,6 +25,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -291,6 +292,116 @@ func newTestServerAndClient(
 	return sss, srv
 }
 
+func TestNewFromSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.StorageSettings{
+		Type:        model.StorageTypeS3,
+		Region:      "eu-north-1",
+		Bucket:      "tenant-bucket",
+		Key:         "tenant-key",
+		Secret:      "tenant-secret",
+		ExternalUri: "https://s3.tenant.example.com",
+	}
+	s3c, err := NewFromSettings(context.Background(), settings,
+		NewOptions().SetBucketName("default-bucket").SetRegion("us-east-1"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the settings of the tenant apply without settings in the context
+	link, err := s3c.PutRequest(context.Background(), "foo/bar", time.Minute, 0)
+	if assert.NoError(t, err) {
+		uri, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "tenant-bucket.s3.tenant.example.com", uri.Host)
+			assert.Equal(t, "/foo/bar", uri.Path)
+			assert.True(t, strings.HasPrefix(
+				uri.Query().Get("X-Amz-Credential"),
+				"tenant-key/",
+			))
+			assert.Contains(t, uri.Query().Get("X-Amz-Credential"), "/eu-north-1/")
+		}
+	}
+
+	_, err = NewFromSettings(context.Background(), &model.StorageSettings{})
+	assert.Error(t, err)
+}
+
+func TestPutRequestSize(t *testing.T) {
+	t.Parallel()
+
+	settings := &model.StorageSettings{
+		Type:   model.StorageTypeS3,
+		Region: "eu-north-1",
+		Bucket: "bucket",
+		Key:    "bucket-key",
+		Secret: "bucket-secret",
+	}
+	s3c, err := NewFromSettings(context.Background(), settings)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	link, err := s3c.PutRequest(context.Background(), "foo/bar", time.Minute, 1024)
+	if assert.NoError(t, err) {
+		uri, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Contains(t,
+				strings.Split(uri.Query().Get("X-Amz-SignedHeaders"), ";"),
+				"content-length",
+			)
+		}
+		assert.Equal(t, map[string]string{"Content-Length": "1024"}, link.Header)
+	}
+
+	link, err = s3c.PutRequest(context.Background(), "foo/bar", time.Minute, 0)
+	if assert.NoError(t, err) {
+		uri, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.NotContains(t,
+				strings.Split(uri.Query().Get("X-Amz-SignedHeaders"), ";"),
+				"content-length",
+			)
+		}
+		assert.Nil(t, link.Header)
+	}
+}
+
+func TestPutRequestServerSideEncryption(t *testing.T) {
+	t.Parallel()
+
+	const kmsKeyID = "arn:aws:kms:eu-west-1:123456789012:key/artifacts"
+	settings := &model.StorageSettings{
+		Type:                 model.StorageTypeS3,
+		Region:               "eu-north-1",
+		Bucket:               "bucket",
+		Key:                  "bucket-key",
+		Secret:               "bucket-secret",
+		ServerSideEncryption: model.ServerSideEncryptionKMS,
+		SSEKMSKeyID:          kmsKeyID,
+	}
+	s3c, err := NewFromSettings(context.Background(), settings)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	link, err := s3c.PutRequest(context.Background(), "foo/bar", time.Minute, 1024)
+	if assert.NoError(t, err) {
+		uri, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			signed := strings.Split(uri.Query().Get("X-Amz-SignedHeaders"), ";")
+			assert.Contains(t, signed, "x-amz-server-side-encryption")
+			assert.Contains(t, signed, "x-amz-server-side-encryption-aws-kms-key-id")
+		}
+		assert.Equal(t, map[string]string{
+			"Content-Length":                              "1024",
+			"X-Amz-Server-Side-Encryption":                model.ServerSideEncryptionKMS,
+			"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": kmsKeyID,
+		}, link.Header)
+	}
+}
+
 func TestGetObject(t *testing.T) {
 	t.Parallel()
 
@@ -381,3 +492,329 @@ func TestGetObject(t *testing.T) {
 		})
 	}
 }
+
+func TestGetObjectRange(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Name string
+
+		Offset int64
+		Length int64
+
+		Handler func(t *testing.T) http.HandlerFunc
+		Body    []byte
+		Error   assert.ErrorAssertionFunc
+	}
+
+	testCases := []testCase{{
+		Name: "ok",
+
+		Offset: 8,
+		Length: 9,
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/foo/bar", r.URL.Path)
+				assert.Equal(t, "bytes=8-16", r.Header.Get("Range"))
+
+				w.Header().Set("Content-Range", "bytes 8-16/17")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write([]byte("artifacts"))
+			}
+		},
+		Body: []byte("artifacts"),
+	}, {
+		Name: "error/range not satisfiable",
+
+		Offset: 100,
+		Length: 1,
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "bytes=100-100", r.Header.Get("Range"))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			}
+		},
+		Error: func(t assert.TestingT, err error, _ ...interface{}) bool {
+			return assert.ErrorIs(t, err, storage.ErrInvalidRange)
+		},
+	}, {
+		Name: "error/object not found",
+
+		Offset: 0,
+		Length: 1,
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+		Error: func(t assert.TestingT, err error, _ ...interface{}) bool {
+			return assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+		},
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			s3c, srv := newTestServerAndClient(tc.Handler(t))
+			defer srv.Close()
+			obj, err := s3c.GetObjectRange(
+				context.Background(), "foo/bar", tc.Offset, tc.Length,
+			)
+			if tc.Error != nil {
+				tc.Error(t, err)
+			} else if assert.NoError(t, err) {
+				b, _ := io.ReadAll(obj)
+				obj.Close()
+				assert.Equal(t, tc.Body, b)
+			}
+		})
+	}
+}
+
+func TestPutObjectMultipart(t *testing.T) {
+	t.Parallel()
+	const (
+		partSize    = MultipartMinSize
+		concurrency = 2
+		uploadID    = "upload"
+	)
+
+	type testCase struct {
+		Name string
+
+		Size       int
+		FailPartNo string
+
+		Error assert.ErrorAssertionFunc
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Size: partSize*3 + 123,
+	}, {
+		Name: "error/part upload failed",
+
+		Size:       partSize*3 + 123,
+		FailPartNo: "2",
+		Error:      assert.Error,
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu          sync.Mutex
+				inFlight    int
+				maxInFlight int
+				partSizes   = map[string]int{}
+				completed   []byte
+				aborted     bool
+			)
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				switch {
+				case r.Method == http.MethodPost && q.Has("uploads"):
+					fmt.Fprintf(w, "<InitiateMultipartUploadResult>"+
+						"<Bucket>bucket</Bucket><Key>foo/bar</Key>"+
+						"<UploadId>%s</UploadId>"+
+						"</InitiateMultipartUploadResult>", uploadID)
+
+				case r.Method == http.MethodPut && q.Has("partNumber"):
+					assert.Equal(t, uploadID, q.Get("uploadId"))
+					mu.Lock()
+					inFlight++
+					if inFlight > maxInFlight {
+						maxInFlight = inFlight
+					}
+					mu.Unlock()
+					n, _ := io.Copy(io.Discard, r.Body)
+					time.Sleep(time.Millisecond * 10)
+					mu.Lock()
+					inFlight--
+					partSizes[q.Get("partNumber")] = int(n)
+					mu.Unlock()
+					if q.Get("partNumber") == tc.FailPartNo {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					w.Header().Set("ETag", `"`+q.Get("partNumber")+`"`)
+
+				case r.Method == http.MethodPost && q.Has("uploadId"):
+					completed, _ = io.ReadAll(r.Body)
+					fmt.Fprint(w, "<CompleteMultipartUploadResult>"+
+						"<Bucket>bucket</Bucket><Key>foo/bar</Key>"+
+						"</CompleteMultipartUploadResult>")
+
+				case r.Method == http.MethodDelete && q.Has("uploadId"):
+					aborted = true
+					w.WriteHeader(http.StatusNoContent)
+
+				default:
+					assert.Failf(t, "unexpected request", "%s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}
+			s3c, srv := newTestServerAndClient(
+				http.HandlerFunc(handler),
+				NewOptions().
+					SetBufferSize(partSize).
+					SetUploadConcurrency(concurrency),
+			)
+			defer srv.Close()
+
+			err := s3c.PutObject(
+				context.Background(),
+				"foo/bar",
+				io.LimitReader(neverEndingReader{}, int64(tc.Size)),
+			)
+			assert.LessOrEqual(t, maxInFlight, concurrency)
+			if tc.Error != nil {
+				tc.Error(t, err)
+				assert.True(t, aborted, "multipart upload was not aborted")
+				assert.Nil(t, completed)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, map[string]int{
+					"1": partSize, "2": partSize, "3": partSize, "4": 123,
+				}, partSizes)
+				assert.False(t, aborted)
+				// Parts must be listed in ascending order
+				assert.Regexp(t,
+					`(?s)<PartNumber>1</PartNumber>.*<PartNumber>2</PartNumber>.*`+
+						`<PartNumber>3</PartNumber>.*<PartNumber>4</PartNumber>`,
+					string(completed),
+				)
+			}
+		})
+	}
+}
+
+type neverEndingReader struct{}
+
+func (neverEndingReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return len(b), nil
+}
+
+func TestOptionsUploadConcurrency(t *testing.T) {
+	t.Parallel()
+	opts := NewOptions(NewOptions().
+		SetBucketName("bucket").
+		SetRegion("region").
+		SetUploadConcurrency(0))
+	err := opts.Validate()
+	var verr validation.Errors
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Contains(t, verr, "UploadConcurrency")
+	}
+	assert.NoError(t, NewOptions(opts.SetUploadConcurrency(4)).Validate())
+}
+
+func TestServerSideEncryption(t *testing.T) {
+	t.Parallel()
+	const (
+		hdrSSE      = "X-Amz-Server-Side-Encryption"
+		hdrSSEKeyID = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+		kmsKeyID    = "arn:aws:kms:eu-west-1:123456789012:key/artifacts"
+	)
+
+	testCases := []struct {
+		Name string
+
+		Options  *Options
+		Settings *model.StorageSettings
+
+		SSE      string
+		SSEKeyID string
+	}{{
+		Name: "ok/no encryption",
+
+		Options: NewOptions(),
+	}, {
+		Name: "ok/kms",
+
+		Options:  NewOptions().SetServerSideEncryption("aws:kms", kmsKeyID),
+		SSE:      "aws:kms",
+		SSEKeyID: kmsKeyID,
+	}, {
+		Name: "ok/tenant settings",
+
+		Options: NewOptions().SetServerSideEncryption("aws:kms", kmsKeyID),
+		Settings: &model.StorageSettings{
+			Region:               "eu-west-1",
+			Bucket:               "tenant-bucket",
+			Key:                  "tenant-key",
+			Secret:               "tenant-secret",
+			ServerSideEncryption: model.ServerSideEncryptionAES256,
+		},
+		SSE: "AES256",
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			var uploads int
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				switch {
+				case r.Method == http.MethodHead:
+					assert.Empty(t, r.Header.Get(hdrSSE))
+					w.WriteHeader(http.StatusOK)
+					return
+				case r.Method == http.MethodPost && q.Has("uploads"):
+					fmt.Fprint(w, "<InitiateMultipartUploadResult>"+
+						"<Bucket>bucket</Bucket><Key>foo/bar</Key>"+
+						"<UploadId>upload</UploadId>"+
+						"</InitiateMultipartUploadResult>")
+				case r.Method == http.MethodPut:
+					_, _ = io.Copy(io.Discard, r.Body)
+				default:
+					assert.Failf(t, "unexpected request", "%s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				uploads++
+				assert.Equal(t, tc.SSE, r.Header.Get(hdrSSE))
+				assert.Equal(t, tc.SSEKeyID, r.Header.Get(hdrSSEKeyID))
+			}
+			s3c, srv := newTestServerAndClient(http.HandlerFunc(handler), tc.Options)
+			defer srv.Close()
+
+			ctx := context.Background()
+			if tc.Settings != nil {
+				ctx = storage.SettingsWithContext(ctx, tc.Settings)
+			}
+			err := s3c.PutObject(ctx, "foo/bar", strings.NewReader("artifact"))
+			assert.NoError(t, err)
+			_, err = s3c.CreateMultipartUpload(ctx, "foo/bar")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, uploads)
+
+			// the download links are signed without the encryption
+			// headers, S3 decrypts the objects transparently
+			link, err := s3c.GetRequest(ctx, "foo/bar", "", time.Minute)
+			if assert.NoError(t, err) {
+				assert.NotContains(t, strings.ToLower(link.Uri), "server-side-encryption")
+			}
+		})
+	}
+}
+
+func TestOptionsServerSideEncryption(t *testing.T) {
+	t.Parallel()
+	opts := NewOptions(NewOptions().
+		SetBucketName("bucket").
+		SetServerSideEncryption("aws:kms", ""))
+	err := opts.Validate()
+	var verr validation.Errors
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Contains(t, verr, "SSEKMSKeyID")
+	}
+	assert.Error(t, NewOptions(opts.SetServerSideEncryption("none", "")).Validate())
+	assert.NoError(t, NewOptions(opts.SetServerSideEncryption("AES256", "")).Validate())
+	assert.NoError(t, NewOptions(opts.SetServerSideEncryption("aws:kms", "key")).Validate())
+}
@@ -27,6 +27,22 @@ type DataStore interface {
 	Ping(ctx context.Context) error
 	//releases
 	GetReleases(ctx context.Context, filt *model.ReleaseOrImageFilter) ([]model.Release, int, error)
+	// GetReleasesListVersion returns the version of the release list
+	// matching the filter, or nil if it cannot be determined cheaply.
+	GetReleasesListVersion(
+		ctx context.Context,
+		filt *model.ReleaseOrImageFilter,
+	) (*model.ListVersion, error)
+	GetRelease(ctx context.Context, name string, artifactsSort string) (*model.Release, error)
+	GetReleaseForArtifact(ctx context.Context, artifactID string) (*model.Release, error)
+	// FindOrphanedImages returns a page of the images with no release of
+	// their artifact name, and the total number of such images.
+	FindOrphanedImages(ctx context.Context, skip, limit int) ([]*model.Image, int, error)
+	// FindImagesModifiedSince returns a page of the images modified at or
+	// after since, oldest first; if afterID is set, only the images modified
+	// at since with a greater ID are included.
+	FindImagesModifiedSince(ctx context.Context,
+		since time.Time, afterID string, skip, limit int) ([]*model.Image, error)
 	UpdateReleaseArtifacts(
 		ctx context.Context,
 		artifactToAdd *model.Image,
@@ -38,14 +54,23 @@ type DataStore interface {
 		artifactToEdit *model.Image,
 		releaseName string,
 	) error
+	// CountReleases returns the number of releases.
+	CountReleases(ctx context.Context) (int64, error)
 
 	//limits
 	GetLimit(ctx context.Context, name string) (*model.Limit, error)
+	SetLimit(ctx context.Context, limit *model.Limit) error
 
 	//storage settings
 	GetStorageSettings(ctx context.Context) (*model.StorageSettings, error)
 	SetStorageSettings(ctx context.Context, storageSettings *model.StorageSettings) error
 
+	// maintenance mode, shared by all the instances of the service
+	GetMaintenanceMode(ctx context.Context) (bool, error)
+	SetMaintenanceMode(ctx context.Context, enabled bool) error
+	// InitMaintenanceMode sets the maintenance mode unless it is set already.
+	InitMaintenanceMode(ctx context.Context, enabled bool) error
+
 	//tenants
 	ProvisionTenant(ctx context.Context, tenantId string) error
 
@@ -53,12 +78,45 @@ type DataStore interface {
 	Exists(ctx context.Context, id string) (bool, error)
 	Update(ctx context.Context, image *model.Image) (bool, error)
 	InsertImage(ctx context.Context, image *model.Image) error
+	// ReplaceImage replaces the image if it is still at the given
+	// revision; returns ErrNotFound otherwise.
+	ReplaceImage(ctx context.Context, image *model.Image, revision int) error
+	// UpdateImageReferences updates the copies of the image in its release
+	// and in the active device deployments, unless they are of a later
+	// revision already.
+	UpdateImageReferences(ctx context.Context, image *model.Image) error
+	ReplaceImageTags(ctx context.Context, imageID string, tags model.Tags) error
 	FindImageByID(ctx context.Context, id string) (*model.Image, error)
 	IsArtifactUnique(ctx context.Context, artifactName string,
 		deviceTypesCompatible []string) (bool, error)
 	DeleteImage(ctx context.Context, id string) error
 	ListImages(ctx context.Context, filt *model.ReleaseOrImageFilter) ([]*model.Image, int, error)
+	GetImagesListVersion(
+		ctx context.Context,
+		filt *model.ReleaseOrImageFilter,
+	) (*model.ListVersion, error)
 	DeleteImagesByNames(ctx context.Context, names []string) error
+	DeleteImagesByRelease(ctx context.Context, name string) ([]*model.Image, error)
+
+	// artifact deletion audit
+	InsertArtifactDeletion(ctx context.Context, deletion *model.ArtifactDeletion) error
+	ListArtifactDeletions(
+		ctx context.Context,
+		query model.ArtifactDeletionsQuery,
+	) ([]model.ArtifactDeletion, int, error)
+
+	// files of the replaced artifact revisions
+	InsertReplacedArtifactFile(ctx context.Context, file *model.ReplacedArtifactFile) error
+	FindReplacedArtifactFiles(ctx context.Context) ([]model.ReplacedArtifactFile, error)
+	DeleteReplacedArtifactFile(ctx context.Context, id string) error
+	// ExistActiveDeviceDeploymentsOfImage tells whether any active device
+	// deployment of a deployment created before the given time has the
+	// image assigned.
+	ExistActiveDeviceDeploymentsOfImage(
+		ctx context.Context,
+		imageID string,
+		createdBefore time.Time,
+	) (bool, error)
 
 	//artifact getter
 	ImagesByName(ctx context.Context,
@@ -67,16 +125,67 @@ type DataStore interface {
 		ids []string, deviceType string) (*model.Image, error)
 	ImageByNameAndDeviceType(ctx context.Context,
 		name, deviceType string) (*model.Image, error)
+	ImageByNameAndProvides(ctx context.Context,
+		name string, provides map[string]string) (*model.Image, error)
 
 	// upload intents
 	InsertUploadIntent(ctx context.Context, link *model.UploadLink) error
 	UpdateUploadIntentStatus(ctx context.Context, id string, from, to model.LinkStatus) error
 	FindUploadLinks(ctx context.Context, expired time.Time) (Iterator[model.UploadLink], error)
+	GetUploadIntent(ctx context.Context, id string) (*model.UploadLink, error)
+	SetUploadIntentPart(ctx context.Context, id string, part model.UploadPart) error
+
+	// artifact imports
+	InsertArtifactImportJob(ctx context.Context, job *model.ArtifactImportJob) error
+	UpdateArtifactImportJob(ctx context.Context, job *model.ArtifactImportJob) error
+	FindArtifactImportJobByID(ctx context.Context, id string) (*model.ArtifactImportJob, error)
+	FailStaleArtifactImportJobs(
+		ctx context.Context,
+		updatedBefore time.Time,
+		reason string,
+	) (int64, error)
+
+	// exports
+	InsertExportJob(ctx context.Context, job *model.ExportJob) error
+	UpdateExportJob(ctx context.Context, job *model.ExportJob) error
+	FindExportJobByID(ctx context.Context, id string) (*model.ExportJob, error)
+	// StartExportJob marks a pending or failed export job, or a running
+	// one not updated since staleBefore, as running; it returns
+	// ErrNotFound if there is no such job in either state.
+	StartExportJob(
+		ctx context.Context,
+		id string,
+		staleBefore time.Time,
+	) (*model.ExportJob, error)
+	// TouchExportJob updates the modification time of a running export
+	// job, so that it is not taken for interrupted.
+	TouchExportJob(ctx context.Context, id string) error
+	// IterateDeployments and IterateDeviceDeployments return all the
+	// documents, including deleted ones, sorted by creation time.
+	IterateDeployments(ctx context.Context) (Iterator[model.Deployment], error)
+	IterateDeviceDeployments(ctx context.Context) (Iterator[model.DeviceDeployment], error)
+	// FindDeploymentsCreatedBetween returns the reports of the deployments
+	// created in [from, to), oldest first, excluding the deleted ones.
+	// A zero limit returns all of them.
+	FindDeploymentsCreatedBetween(
+		ctx context.Context,
+		from, to time.Time,
+		skip, limit int,
+	) (Iterator[model.DeploymentReport], error)
+	// CountDeploymentsCreatedBetween counts the deployments selected by
+	// FindDeploymentsCreatedBetween.
+	CountDeploymentsCreatedBetween(ctx context.Context, from, to time.Time) (int, error)
 
 	//device deployment log
 	SaveDeviceDeploymentLog(ctx context.Context, log model.DeploymentLog) error
+	// GetDeviceDeploymentLog returns the page of the device deployment log
+	// selected by the query and the number of messages matching the query
+	// filters; it returns ErrNotFound if there is no such log.
 	GetDeviceDeploymentLog(ctx context.Context,
-		deviceID, deploymentID string) (*model.DeploymentLog, error)
+		query ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error)
+	// PurgeDeviceDeploymentLogs removes the logs of the device deployments
+	// finished before finishedBefore and returns the number of removed logs.
+	PurgeDeviceDeploymentLogs(ctx context.Context, finishedBefore time.Time) (int64, error)
 
 	// device deployments
 	InsertDeviceDeployment(ctx context.Context, deviceDeployment *model.DeviceDeployment,
@@ -87,6 +196,11 @@ type DataStore interface {
 		ctx context.Context,
 		deviceID string,
 	) (*model.DeviceDeployment, error)
+	FindOldestActiveDeviceDeploymentID(ctx context.Context, deviceID string) (string, error)
+	FindAndClaimOldestActiveDeviceDeployment(
+		ctx context.Context,
+		deviceID string,
+	) (*model.DeviceDeployment, model.DeviceDeploymentStatus, error)
 	FindLatestInactiveDeviceDeployment(
 		ctx context.Context,
 		deviceID string,
@@ -98,6 +212,27 @@ type DataStore interface {
 		state model.DeviceDeploymentState,
 		currentStatus model.DeviceDeploymentStatus,
 	) (model.DeviceDeploymentStatus, error)
+	// TouchDeviceDeployment only refreshes the last update time of the
+	// device deployment, if it still has the given status.
+	TouchDeviceDeployment(
+		ctx context.Context,
+		deviceID string,
+		deploymentID string,
+		status model.DeviceDeploymentStatus,
+	) error
+	RetryDeviceDeployment(
+		ctx context.Context,
+		deviceID string,
+		deploymentID string,
+		maxRetries uint,
+	) (bool, error)
+	// FindDeviceDeploymentsAwaitingConfirmation returns up to limit device
+	// deployments still awaiting confirmation at deadlineBefore.
+	FindDeviceDeploymentsAwaitingConfirmation(
+		ctx context.Context,
+		deadlineBefore time.Time,
+		limit int,
+	) ([]model.DeviceDeployment, error)
 	UpdateDeviceDeploymentLogAvailability(ctx context.Context,
 		deviceID string, deploymentID string, log bool) error
 	AssignArtifact(
@@ -108,17 +243,36 @@ type DataStore interface {
 	) error
 	AggregateDeviceDeploymentByStatus(ctx context.Context,
 		id string) (model.Stats, error)
-	GetDeviceStatusesForDeployment(ctx context.Context,
-		deploymentID string) ([]model.DeviceDeployment, error)
+	FindDeviceDeploymentCountsByDeploymentIDs(ctx context.Context,
+		ids []string) (map[string]model.Stats, error)
+	// IterateDeviceStatusesForDeployment returns a cursor over the device
+	// deployments of the deployment, with the fields of the CSV report only.
+	IterateDeviceStatusesForDeployment(ctx context.Context,
+		deploymentID string) (Iterator[model.DeviceDeployment], error)
 	GetDevicesListForDeployment(ctx context.Context,
 		query ListQuery) ([]model.DeviceDeployment, int, error)
+	// IterateDevicesListForDeployment is GetDevicesListForDeployment
+	// returning a cursor instead; a zero Limit means no limit.
+	IterateDevicesListForDeployment(ctx context.Context,
+		query ListQuery) (Iterator[model.DeviceDeployment], error)
 	GetDeviceDeploymentsForDevice(ctx context.Context,
 		query ListQueryDeviceDeployments) ([]model.DeviceDeployment, int, error)
+	// GetDeviceDeploymentViewsForDevice is GetDeviceDeploymentsForDevice
+	// with the deployment and artifact names joined in.
+	GetDeviceDeploymentViewsForDevice(ctx context.Context,
+		query ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error)
 	HasDeploymentForDevice(ctx context.Context,
 		deploymentID string, deviceID string) (bool, error)
-	AbortDeviceDeployments(ctx context.Context, deploymentID string) error
+	CountActiveDeploymentsByDevice(ctx context.Context, deviceID string) (int, error)
+	// AbortDeviceDeployments aborts the active device deployments of the
+	// deployment and returns how many were aborted.
+	AbortDeviceDeployments(ctx context.Context, deploymentID string) (int64, error)
 	DeleteDeviceDeploymentsHistory(ctx context.Context, deviceId string) error
-	DecommissionDeviceDeployments(ctx context.Context, deviceId string) error
+	// DecommissionDeviceDeployments marks the active device deployments of
+	// the device decommissioned and returns them with their previous status.
+	// On error, it still returns the ones decommissioned so far.
+	DecommissionDeviceDeployments(ctx context.Context,
+		deviceId string) ([]model.DeviceDeployment, error)
 	GetDeviceDeployment(ctx context.Context, deploymentID string,
 		deviceID string, includeDeleted bool) (*model.DeviceDeployment, error)
 	GetDeviceDeployments(
@@ -138,7 +292,31 @@ type DataStore interface {
 	// deployments
 	InsertDeployment(ctx context.Context, deployment *model.Deployment) error
 	DeleteDeployment(ctx context.Context, id string) error
-	FindDeploymentByID(ctx context.Context, id string) (*model.Deployment, error)
+	RestoreDeployment(ctx context.Context, id string, deletedAfter time.Time) error
+	// StartDeployment sets the paused deployment pending as of started,
+	// along with its phases rescheduled from then.
+	StartDeployment(ctx context.Context, id string, started time.Time,
+		phases model.DeploymentPhases) error
+	UpdateDeployment(ctx context.Context, id string, patch model.DeploymentPatch) error
+	PurgeDeletedDeployments(ctx context.Context, deletedBefore time.Time) (int64, error)
+	FindDeploymentByID(ctx context.Context,
+		id string, includeDeleted bool) (*model.Deployment, error)
+	GetDeploymentTargetDevices(ctx context.Context,
+		id string, skip, limit int) ([]string, int, error)
+	// GetFleetSoftwareInventory returns, for each device, the artifact of
+	// its latest successful device deployment, sorted by device ID.
+	GetFleetSoftwareInventory(ctx context.Context,
+		query model.SoftwareInventoryQuery) ([]model.DeviceSoftware, int, error)
+	GetDeploymentCreationTrend(ctx context.Context, from, to time.Time,
+		granularity model.TrendGranularity) ([]model.TrendBucket, error)
+	GetLargestDeployments(ctx context.Context, limit int) ([]*model.Deployment, error)
+	// CountDeploymentsByStatus returns the number of deployments in each
+	// status, omitting the statuses without any.
+	CountDeploymentsByStatus(ctx context.Context) (map[model.DeploymentStatus]int, error)
+	// FindStaleActiveDeployments returns up to limit active deployments
+	// created before olderThan with devices still pending or downloading.
+	FindStaleActiveDeployments(ctx context.Context,
+		olderThan time.Time, limit int) ([]*model.Deployment, error)
 	FindDeploymentStatsByIDs(ctx context.Context, ids ...string) ([]*model.DeploymentStats, error)
 	FindUnfinishedByID(ctx context.Context,
 		id string) (*model.Deployment, error)
@@ -148,24 +326,109 @@ type DataStore interface {
 		stateFrom,
 		stateTo model.DeviceDeploymentStatus,
 	) (model.Stats, error)
+	// ClaimDeploymentUpdateSlot moves one device deployment from pending to
+	// downloading in the statistics of the deployment, provided fewer than
+	// limit device deployments are updating; it returns false, and leaves
+	// the statistics unchanged, when the limit is reached.
+	ClaimDeploymentUpdateSlot(
+		ctx context.Context,
+		id string,
+		limit uint,
+	) (model.Stats, bool, error)
 	UpdateStats(ctx context.Context,
 		id string, stats model.Stats) error
 	Find(ctx context.Context,
 		query model.Query) ([]*model.Deployment, int64, error)
+	FindDeploymentsByArtifact(ctx context.Context,
+		name, id string, query model.Query) ([]*model.Deployment, int64, error)
+	// FindDeploymentsByGroup lists the deployments created for the group.
+	FindDeploymentsByGroup(ctx context.Context,
+		group string, query model.Query) ([]*model.Deployment, int64, error)
+	// GetReleaseRolloutStats sums up the deployments of the artifact name,
+	// the deleted ones only if includeDeleted is set.
+	GetReleaseRolloutStats(
+		ctx context.Context,
+		name string,
+		includeDeleted bool,
+	) (*model.ReleaseRolloutStats, error)
+	// SetDeploymentStatus updates the status of the deployment unless it
+	// is finished already; it returns whether the deployment was updated.
 	SetDeploymentStatus(
 		ctx context.Context,
 		id string,
 		status model.DeploymentStatus,
 		now time.Time,
+	) (bool, error)
+	// SetDeploymentAborted records the subject of the identity aborting
+	// the deployment, and the time.
+	SetDeploymentAborted(ctx context.Context, id string, abortedBy string, now time.Time) error
+	// StartDeploymentRollback records the rollback of the deployment and
+	// returns false if it was already recorded.
+	StartDeploymentRollback(ctx context.Context, id string, triggered time.Time) (bool, error)
+	// FinishDeploymentRollback records the follow-up deployments of the
+	// rollback of the deployment, and the time it finished.
+	FinishDeploymentRollback(
+		ctx context.Context,
+		id string,
+		deploymentIDs []string,
+		finished time.Time,
 	) error
+	// FindUnfinishedDeploymentRollbacks returns the deployments whose
+	// rollback was triggered before the time and did not finish.
+	FindUnfinishedDeploymentRollbacks(
+		ctx context.Context,
+		triggeredBefore time.Time,
+	) ([]*model.Deployment, error)
 	FindNewerActiveDeployment(ctx context.Context,
 		createdAfter *time.Time, deviceID string) (*model.Deployment, error)
 	FindNewerActiveDeployments(ctx context.Context,
 		createdAfter *time.Time, skip, limit int) ([]*model.Deployment, error)
+	// FindActiveDeploymentsForDevice returns the unfinished deployments
+	// the device is enrolled in, together with the ones created after
+	// createdAfter targeting the device it has not picked up yet.
+	FindActiveDeploymentsForDevice(ctx context.Context,
+		deviceID string, createdAfter *time.Time) ([]*model.Deployment, error)
+	// GetDeploymentDeviceIndex returns the position of the device in the
+	// device list of the deployment, or -1 if the device is not targeted.
+	GetDeploymentDeviceIndex(ctx context.Context, deploymentID, deviceID string) (int, error)
+	// SetDeploymentCurrentPhase records the progress of a phased
+	// deployment; the phase never goes back.
+	SetDeploymentCurrentPhase(ctx context.Context, deploymentID string, phase int) error
 	ExistUnfinishedByArtifactId(ctx context.Context, id string) (bool, error)
 	ExistUnfinishedByArtifactName(ctx context.Context, artifactName string) (bool, error)
+	// FindUnfinishedIDsByArtifactName returns the IDs of up to limit
+	// unfinished deployments of the artifact, oldest first.
+	FindUnfinishedIDsByArtifactName(
+		ctx context.Context,
+		artifactName string,
+		limit int,
+	) ([]string, error)
 	ExistByArtifactId(ctx context.Context, id string) (bool, error)
+	// ExistAssignedImageWithIDAndStatuses checks if the image is assigned
+	// to a device deployment in one of the statuses.
+	ExistAssignedImageWithIDAndStatuses(
+		ctx context.Context,
+		imageID string,
+		statuses []model.DeviceDeploymentStatus,
+	) (bool, error)
+	// ClearAssignedImage unassigns the image from the device deployments
+	// in the statuses, returning their number.
+	ClearAssignedImage(
+		ctx context.Context,
+		imageID string,
+		statuses []model.DeviceDeploymentStatus,
+	) (int64, error)
 	SetDeploymentDeviceCount(ctx context.Context, deploymentID string, count int) error
+	// ResetDeploymentDeviceCount sets the device count of the deployment
+	// to count if it is still previous; returns ErrNotFound if the
+	// deployment does not exist and ErrDeviceCountChanged if its device
+	// count changed meanwhile.
+	ResetDeploymentDeviceCount(
+		ctx context.Context,
+		deploymentID string,
+		previous *int,
+		count int,
+	) error
 	IncrementDeploymentDeviceCount(ctx context.Context, deploymentID string, increment int) error
 	IncrementDeploymentTotalSize(ctx context.Context, deploymentID string, increment int64) error
 	DeviceCountByDeployment(ctx context.Context, id string) (int, error)
@@ -177,6 +440,12 @@ type DataStore interface {
 	GetDeploymentIDsByArtifactNames(ctx context.Context, artifactNames []string) ([]string, error)
 
 	GetTenantDbs() ([]string, error)
+	// CountActiveDeploymentsPerTenant counts the active deployments in the
+	// first limit tenant databases after the one of the given tenant, in
+	// tenant ID order, waiting period between two databases; the default
+	// database is counted when there is no tenant database.
+	CountActiveDeploymentsPerTenant(ctx context.Context, after string, limit int,
+		period time.Duration) ([]model.TenantActiveDeployments, error)
 	SaveLastDeviceDeploymentStatus(
 		ctx context.Context,
 		deviceDeployment model.DeviceDeployment,
@@ -200,7 +469,13 @@ type DataStore interface {
 	ListReleaseTags(ctx context.Context) (model.Tags, error)
 	SaveUpdateTypes(ctx context.Context, updateTypes []string) error
 	GetUpdateTypes(ctx context.Context) ([]string, error)
+	// GetUpdateTypesWithCounts returns the number of releases using each
+	// update type.
+	GetUpdateTypesWithCounts(ctx context.Context) (map[string]int, error)
 	DeleteReleasesByNames(ctx context.Context, names []string) error
+	// RebuildReleases re-aggregates the artifacts into releases, fixing
+	// the releases out of sync unless dryRun is set.
+	RebuildReleases(ctx context.Context, dryRun bool) (*model.ReleasesRebuild, error)
 }
 
 var ErrNotFound = errors.New("document not found")
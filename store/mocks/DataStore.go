@@ -33,17 +33,24 @@ type DataStore struct {
 }
 
 // AbortDeviceDeployments provides a mock function with given fields: ctx, deploymentID
-func (_m *DataStore) AbortDeviceDeployments(ctx context.Context, deploymentID string) error {
+func (_m *DataStore) AbortDeviceDeployments(ctx context.Context, deploymentID string) (int64, error) {
 	ret := _m.Called(ctx, deploymentID)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
 		r0 = rf(ctx, deploymentID)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(int64)
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // AggregateDeviceDeploymentByStatus provides a mock function with given fields: ctx, id
@@ -83,18 +90,187 @@ func (_m *DataStore) AssignArtifact(ctx context.Context, deviceID string, deploy
 	return r0
 }
 
+// ClaimDeploymentUpdateSlot provides a mock function with given fields: ctx, id, limit
+func (_m *DataStore) ClaimDeploymentUpdateSlot(ctx context.Context, id string, limit uint) (model.Stats, bool, error) {
+	ret := _m.Called(ctx, id, limit)
+
+	var r0 model.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint) model.Stats); ok {
+		r0 = rf(ctx, id, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.Stats)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(context.Context, string, uint) bool); ok {
+		r1 = rf(ctx, id, limit)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, uint) error); ok {
+		r2 = rf(ctx, id, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ClearAssignedImage provides a mock function with given fields: ctx, imageID, statuses
+func (_m *DataStore) ClearAssignedImage(ctx context.Context, imageID string, statuses []model.DeviceDeploymentStatus) (int64, error) {
+	ret := _m.Called(ctx, imageID, statuses)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.DeviceDeploymentStatus) int64); ok {
+		r0 = rf(ctx, imageID, statuses)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []model.DeviceDeploymentStatus) error); ok {
+		r1 = rf(ctx, imageID, statuses)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountActiveDeploymentsByDevice provides a mock function with given fields: ctx, deviceID
+func (_m *DataStore) CountActiveDeploymentsByDevice(ctx context.Context, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountActiveDeploymentsPerTenant provides a mock function with given fields: ctx, after, limit, period
+func (_m *DataStore) CountActiveDeploymentsPerTenant(ctx context.Context, after string, limit int, period time.Duration) ([]model.TenantActiveDeployments, error) {
+	ret := _m.Called(ctx, after, limit, period)
+
+	var r0 []model.TenantActiveDeployments
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, time.Duration) []model.TenantActiveDeployments); ok {
+		r0 = rf(ctx, after, limit, period)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.TenantActiveDeployments)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, time.Duration) error); ok {
+		r1 = rf(ctx, after, limit, period)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountDeploymentsByStatus provides a mock function with given fields: ctx
+func (_m *DataStore) CountDeploymentsByStatus(ctx context.Context) (map[model.DeploymentStatus]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[model.DeploymentStatus]int
+	if rf, ok := ret.Get(0).(func(context.Context) map[model.DeploymentStatus]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[model.DeploymentStatus]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountDeploymentsCreatedBetween provides a mock function with given fields: ctx, from, to
+func (_m *DataStore) CountDeploymentsCreatedBetween(ctx context.Context, from time.Time, to time.Time) (int, error) {
+	ret := _m.Called(ctx, from, to)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time) int); ok {
+		r0 = rf(ctx, from, to)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountReleases provides a mock function with given fields: ctx
+func (_m *DataStore) CountReleases(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DecommissionDeviceDeployments provides a mock function with given fields: ctx, deviceId
-func (_m *DataStore) DecommissionDeviceDeployments(ctx context.Context, deviceId string) error {
+func (_m *DataStore) DecommissionDeviceDeployments(ctx context.Context, deviceId string) ([]model.DeviceDeployment, error) {
 	ret := _m.Called(ctx, deviceId)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+	var r0 []model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.DeviceDeployment); ok {
 		r0 = rf(ctx, deviceId)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeployment)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // DeleteDeployment provides a mock function with given fields: ctx, id
@@ -153,6 +329,29 @@ func (_m *DataStore) DeleteImagesByNames(ctx context.Context, names []string) er
 	return r0
 }
 
+// DeleteImagesByRelease provides a mock function with given fields: ctx, name
+func (_m *DataStore) DeleteImagesByRelease(ctx context.Context, name string) ([]*model.Image, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 []*model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*model.Image); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Image)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteReleasesByNames provides a mock function with given fields: ctx, names
 func (_m *DataStore) DeleteReleasesByNames(ctx context.Context, names []string) error {
 	ret := _m.Called(ctx, names)
@@ -167,6 +366,20 @@ func (_m *DataStore) DeleteReleasesByNames(ctx context.Context, names []string)
 	return r0
 }
 
+// DeleteReplacedArtifactFile provides a mock function with given fields: ctx, id
+func (_m *DataStore) DeleteReplacedArtifactFile(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeviceCountByDeployment provides a mock function with given fields: ctx, id
 func (_m *DataStore) DeviceCountByDeployment(ctx context.Context, id string) (int, error) {
 	ret := _m.Called(ctx, id)
@@ -188,6 +401,48 @@ func (_m *DataStore) DeviceCountByDeployment(ctx context.Context, id string) (in
 	return r0, r1
 }
 
+// ExistActiveDeviceDeploymentsOfImage provides a mock function with given fields: ctx, imageID, createdBefore
+func (_m *DataStore) ExistActiveDeviceDeploymentsOfImage(ctx context.Context, imageID string, createdBefore time.Time) (bool, error) {
+	ret := _m.Called(ctx, imageID, createdBefore)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(ctx, imageID, createdBefore)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, imageID, createdBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExistAssignedImageWithIDAndStatuses provides a mock function with given fields: ctx, imageID, statuses
+func (_m *DataStore) ExistAssignedImageWithIDAndStatuses(ctx context.Context, imageID string, statuses []model.DeviceDeploymentStatus) (bool, error) {
+	ret := _m.Called(ctx, imageID, statuses)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.DeviceDeploymentStatus) bool); ok {
+		r0 = rf(ctx, imageID, statuses)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []model.DeviceDeploymentStatus) error); ok {
+		r1 = rf(ctx, imageID, statuses)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ExistByArtifactId provides a mock function with given fields: ctx, id
 func (_m *DataStore) ExistByArtifactId(ctx context.Context, id string) (bool, error) {
 	ret := _m.Called(ctx, id)
@@ -272,6 +527,27 @@ func (_m *DataStore) Exists(ctx context.Context, id string) (bool, error) {
 	return r0, r1
 }
 
+// FailStaleArtifactImportJobs provides a mock function with given fields: ctx, updatedBefore, reason
+func (_m *DataStore) FailStaleArtifactImportJobs(ctx context.Context, updatedBefore time.Time, reason string) (int64, error) {
+	ret := _m.Called(ctx, updatedBefore, reason)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, string) int64); ok {
+		r0 = rf(ctx, updatedBefore, reason)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, string) error); ok {
+		r1 = rf(ctx, updatedBefore, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Find provides a mock function with given fields: ctx, query
 func (_m *DataStore) Find(ctx context.Context, query model.Query) ([]*model.Deployment, int64, error) {
 	ret := _m.Called(ctx, query)
@@ -302,22 +578,22 @@ func (_m *DataStore) Find(ctx context.Context, query model.Query) ([]*model.Depl
 	return r0, r1, r2
 }
 
-// FindDeploymentByID provides a mock function with given fields: ctx, id
-func (_m *DataStore) FindDeploymentByID(ctx context.Context, id string) (*model.Deployment, error) {
-	ret := _m.Called(ctx, id)
+// FindActiveDeploymentsForDevice provides a mock function with given fields: ctx, deviceID, createdAfter
+func (_m *DataStore) FindActiveDeploymentsForDevice(ctx context.Context, deviceID string, createdAfter *time.Time) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, deviceID, createdAfter)
 
-	var r0 *model.Deployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Deployment); ok {
-		r0 = rf(ctx, id)
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, *time.Time) []*model.Deployment); ok {
+		r0 = rf(ctx, deviceID, createdAfter)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Deployment)
+			r0 = ret.Get(0).([]*model.Deployment)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, string, *time.Time) error); ok {
+		r1 = rf(ctx, deviceID, createdAfter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -325,46 +601,46 @@ func (_m *DataStore) FindDeploymentByID(ctx context.Context, id string) (*model.
 	return r0, r1
 }
 
-// FindDeploymentStatsByIDs provides a mock function with given fields: ctx, ids
-func (_m *DataStore) FindDeploymentStatsByIDs(ctx context.Context, ids ...string) ([]*model.DeploymentStats, error) {
-	_va := make([]interface{}, len(ids))
-	for _i := range ids {
-		_va[_i] = ids[_i]
-	}
-	var _ca []interface{}
-	_ca = append(_ca, ctx)
-	_ca = append(_ca, _va...)
-	ret := _m.Called(_ca...)
+// FindAndClaimOldestActiveDeviceDeployment provides a mock function with given fields: ctx, deviceID
+func (_m *DataStore) FindAndClaimOldestActiveDeviceDeployment(ctx context.Context, deviceID string) (*model.DeviceDeployment, model.DeviceDeploymentStatus, error) {
+	ret := _m.Called(ctx, deviceID)
 
-	var r0 []*model.DeploymentStats
-	if rf, ok := ret.Get(0).(func(context.Context, ...string) []*model.DeploymentStats); ok {
-		r0 = rf(ctx, ids...)
+	var r0 *model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*model.DeploymentStats)
+			r0 = ret.Get(0).(*model.DeviceDeployment)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, ...string) error); ok {
-		r1 = rf(ctx, ids...)
+	var r1 model.DeviceDeploymentStatus
+	if rf, ok := ret.Get(1).(func(context.Context, string) model.DeviceDeploymentStatus); ok {
+		r1 = rf(ctx, deviceID)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(model.DeviceDeploymentStatus)
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, deviceID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// FindImageByID provides a mock function with given fields: ctx, id
-func (_m *DataStore) FindImageByID(ctx context.Context, id string) (*model.Image, error) {
+// FindArtifactImportJobByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindArtifactImportJobByID(ctx context.Context, id string) (*model.ArtifactImportJob, error) {
 	ret := _m.Called(ctx, id)
 
-	var r0 *model.Image
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Image); ok {
+	var r0 *model.ArtifactImportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ArtifactImportJob); ok {
 		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Image)
+			r0 = ret.Get(0).(*model.ArtifactImportJob)
 		}
 	}
 
@@ -378,22 +654,22 @@ func (_m *DataStore) FindImageByID(ctx context.Context, id string) (*model.Image
 	return r0, r1
 }
 
-// FindLatestInactiveDeviceDeployment provides a mock function with given fields: ctx, deviceID
-func (_m *DataStore) FindLatestInactiveDeviceDeployment(ctx context.Context, deviceID string) (*model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, deviceID)
+// FindDeploymentByID provides a mock function with given fields: ctx, id, includeDeleted
+func (_m *DataStore) FindDeploymentByID(ctx context.Context, id string, includeDeleted bool) (*model.Deployment, error) {
+	ret := _m.Called(ctx, id, includeDeleted)
 
-	var r0 *model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceDeployment); ok {
-		r0 = rf(ctx, deviceID)
+	var r0 *model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *model.Deployment); ok {
+		r0 = rf(ctx, id, includeDeleted)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.DeviceDeployment)
+			r0 = ret.Get(0).(*model.Deployment)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deviceID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, id, includeDeleted)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -401,22 +677,29 @@ func (_m *DataStore) FindLatestInactiveDeviceDeployment(ctx context.Context, dev
 	return r0, r1
 }
 
-// FindNewerActiveDeployment provides a mock function with given fields: ctx, createdAfter, deviceID
-func (_m *DataStore) FindNewerActiveDeployment(ctx context.Context, createdAfter *time.Time, deviceID string) (*model.Deployment, error) {
-	ret := _m.Called(ctx, createdAfter, deviceID)
+// FindDeploymentStatsByIDs provides a mock function with given fields: ctx, ids
+func (_m *DataStore) FindDeploymentStatsByIDs(ctx context.Context, ids ...string) ([]*model.DeploymentStats, error) {
+	_va := make([]interface{}, len(ids))
+	for _i := range ids {
+		_va[_i] = ids[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
-	var r0 *model.Deployment
-	if rf, ok := ret.Get(0).(func(context.Context, *time.Time, string) *model.Deployment); ok {
-		r0 = rf(ctx, createdAfter, deviceID)
+	var r0 []*model.DeploymentStats
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) []*model.DeploymentStats); ok {
+		r0 = rf(ctx, ids...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Deployment)
+			r0 = ret.Get(0).([]*model.DeploymentStats)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *time.Time, string) error); ok {
-		r1 = rf(ctx, createdAfter, deviceID)
+	if rf, ok := ret.Get(1).(func(context.Context, ...string) error); ok {
+		r1 = rf(ctx, ids...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -424,22 +707,82 @@ func (_m *DataStore) FindNewerActiveDeployment(ctx context.Context, createdAfter
 	return r0, r1
 }
 
-// FindNewerActiveDeployments provides a mock function with given fields: ctx, createdAfter, skip, limit
-func (_m *DataStore) FindNewerActiveDeployments(ctx context.Context, createdAfter *time.Time, skip int, limit int) ([]*model.Deployment, error) {
-	ret := _m.Called(ctx, createdAfter, skip, limit)
+// FindDeploymentsByArtifact provides a mock function with given fields: ctx, name, id, query
+func (_m *DataStore) FindDeploymentsByArtifact(ctx context.Context, name string, id string, query model.Query) ([]*model.Deployment, int64, error) {
+	ret := _m.Called(ctx, name, id, query)
 
 	var r0 []*model.Deployment
-	if rf, ok := ret.Get(0).(func(context.Context, *time.Time, int, int) []*model.Deployment); ok {
-		r0 = rf(ctx, createdAfter, skip, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, model.Query) []*model.Deployment); ok {
+		r0 = rf(ctx, name, id, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, model.Query) int64); ok {
+		r1 = rf(ctx, name, id, query)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, model.Query) error); ok {
+		r2 = rf(ctx, name, id, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FindDeploymentsByGroup provides a mock function with given fields: ctx, group, query
+func (_m *DataStore) FindDeploymentsByGroup(ctx context.Context, group string, query model.Query) ([]*model.Deployment, int64, error) {
+	ret := _m.Called(ctx, group, query)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Query) []*model.Deployment); ok {
+		r0 = rf(ctx, group, query)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*model.Deployment)
 		}
 	}
 
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.Query) int64); ok {
+		r1 = rf(ctx, group, query)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, model.Query) error); ok {
+		r2 = rf(ctx, group, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FindDeploymentsCreatedBetween provides a mock function with given fields: ctx, from, to, skip, limit
+func (_m *DataStore) FindDeploymentsCreatedBetween(ctx context.Context, from time.Time, to time.Time, skip int, limit int) (store.Iterator[model.DeploymentReport], error) {
+	ret := _m.Called(ctx, from, to, skip, limit)
+
+	var r0 store.Iterator[model.DeploymentReport]
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, int, int) store.Iterator[model.DeploymentReport]); ok {
+		r0 = rf(ctx, from, to, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.DeploymentReport])
+		}
+	}
+
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *time.Time, int, int) error); ok {
-		r1 = rf(ctx, createdAfter, skip, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, int, int) error); ok {
+		r1 = rf(ctx, from, to, skip, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -447,22 +790,22 @@ func (_m *DataStore) FindNewerActiveDeployments(ctx context.Context, createdAfte
 	return r0, r1
 }
 
-// FindOldestActiveDeviceDeployment provides a mock function with given fields: ctx, deviceID
-func (_m *DataStore) FindOldestActiveDeviceDeployment(ctx context.Context, deviceID string) (*model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, deviceID)
+// FindDeviceDeploymentCountsByDeploymentIDs provides a mock function with given fields: ctx, ids
+func (_m *DataStore) FindDeviceDeploymentCountsByDeploymentIDs(ctx context.Context, ids []string) (map[string]model.Stats, error) {
+	ret := _m.Called(ctx, ids)
 
-	var r0 *model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceDeployment); ok {
-		r0 = rf(ctx, deviceID)
+	var r0 map[string]model.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string]model.Stats); ok {
+		r0 = rf(ctx, ids)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.DeviceDeployment)
+			r0 = ret.Get(0).(map[string]model.Stats)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deviceID)
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, ids)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -470,16 +813,39 @@ func (_m *DataStore) FindOldestActiveDeviceDeployment(ctx context.Context, devic
 	return r0, r1
 }
 
-// FindUnfinishedByID provides a mock function with given fields: ctx, id
-func (_m *DataStore) FindUnfinishedByID(ctx context.Context, id string) (*model.Deployment, error) {
+// FindDeviceDeploymentsAwaitingConfirmation provides a mock function with given fields: ctx, deadlineBefore, limit
+func (_m *DataStore) FindDeviceDeploymentsAwaitingConfirmation(ctx context.Context, deadlineBefore time.Time, limit int) ([]model.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deadlineBefore, limit)
+
+	var r0 []model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.DeviceDeployment); ok {
+		r0 = rf(ctx, deadlineBefore, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, deadlineBefore, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExportJobByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindExportJobByID(ctx context.Context, id string) (*model.ExportJob, error) {
 	ret := _m.Called(ctx, id)
 
-	var r0 *model.Deployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Deployment); ok {
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ExportJob); ok {
 		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Deployment)
+			r0 = ret.Get(0).(*model.ExportJob)
 		}
 	}
 
@@ -493,22 +859,22 @@ func (_m *DataStore) FindUnfinishedByID(ctx context.Context, id string) (*model.
 	return r0, r1
 }
 
-// FindUploadLinks provides a mock function with given fields: ctx, expired
-func (_m *DataStore) FindUploadLinks(ctx context.Context, expired time.Time) (store.Iterator[model.UploadLink], error) {
-	ret := _m.Called(ctx, expired)
+// FindImageByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindImageByID(ctx context.Context, id string) (*model.Image, error) {
+	ret := _m.Called(ctx, id)
 
-	var r0 store.Iterator[model.UploadLink]
-	if rf, ok := ret.Get(0).(func(context.Context, time.Time) store.Iterator[model.UploadLink]); ok {
-		r0 = rf(ctx, expired)
+	var r0 *model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Image); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(store.Iterator[model.UploadLink])
+			r0 = ret.Get(0).(*model.Image)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
-		r1 = rf(ctx, expired)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -516,22 +882,22 @@ func (_m *DataStore) FindUploadLinks(ctx context.Context, expired time.Time) (st
 	return r0, r1
 }
 
-// GetDeploymentIDsByArtifactNames provides a mock function with given fields: ctx, artifactNames
-func (_m *DataStore) GetDeploymentIDsByArtifactNames(ctx context.Context, artifactNames []string) ([]string, error) {
-	ret := _m.Called(ctx, artifactNames)
+// FindImagesModifiedSince provides a mock function with given fields: ctx, since, afterID, skip, limit
+func (_m *DataStore) FindImagesModifiedSince(ctx context.Context, since time.Time, afterID string, skip int, limit int) ([]*model.Image, error) {
+	ret := _m.Called(ctx, since, afterID, skip, limit)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(context.Context, []string) []string); ok {
-		r0 = rf(ctx, artifactNames)
+	var r0 []*model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, string, int, int) []*model.Image); ok {
+		r0 = rf(ctx, since, afterID, skip, limit)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).([]*model.Image)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
-		r1 = rf(ctx, artifactNames)
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, string, int, int) error); ok {
+		r1 = rf(ctx, since, afterID, skip, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -539,13 +905,13 @@ func (_m *DataStore) GetDeploymentIDsByArtifactNames(ctx context.Context, artifa
 	return r0, r1
 }
 
-// GetDeviceDeployment provides a mock function with given fields: ctx, deploymentID, deviceID, includeDeleted
-func (_m *DataStore) GetDeviceDeployment(ctx context.Context, deploymentID string, deviceID string, includeDeleted bool) (*model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, deploymentID, deviceID, includeDeleted)
+// FindLatestInactiveDeviceDeployment provides a mock function with given fields: ctx, deviceID
+func (_m *DataStore) FindLatestInactiveDeviceDeployment(ctx context.Context, deviceID string) (*model.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceID)
 
 	var r0 *model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, bool) *model.DeviceDeployment); ok {
-		r0 = rf(ctx, deploymentID, deviceID, includeDeleted)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.DeviceDeployment)
@@ -553,8 +919,8 @@ func (_m *DataStore) GetDeviceDeployment(ctx context.Context, deploymentID strin
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, string, bool) error); ok {
-		r1 = rf(ctx, deploymentID, deviceID, includeDeleted)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -562,22 +928,22 @@ func (_m *DataStore) GetDeviceDeployment(ctx context.Context, deploymentID strin
 	return r0, r1
 }
 
-// GetDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID
-func (_m *DataStore) GetDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string) (*model.DeploymentLog, error) {
-	ret := _m.Called(ctx, deviceID, deploymentID)
+// FindNewerActiveDeployment provides a mock function with given fields: ctx, createdAfter, deviceID
+func (_m *DataStore) FindNewerActiveDeployment(ctx context.Context, createdAfter *time.Time, deviceID string) (*model.Deployment, error) {
+	ret := _m.Called(ctx, createdAfter, deviceID)
 
-	var r0 *model.DeploymentLog
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.DeploymentLog); ok {
-		r0 = rf(ctx, deviceID, deploymentID)
+	var r0 *model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, *time.Time, string) *model.Deployment); ok {
+		r0 = rf(ctx, createdAfter, deviceID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.DeploymentLog)
+			r0 = ret.Get(0).(*model.Deployment)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, deviceID, deploymentID)
+	if rf, ok := ret.Get(1).(func(context.Context, *time.Time, string) error); ok {
+		r1 = rf(ctx, createdAfter, deviceID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -585,22 +951,22 @@ func (_m *DataStore) GetDeviceDeploymentLog(ctx context.Context, deviceID string
 	return r0, r1
 }
 
-// GetDeviceDeployments provides a mock function with given fields: ctx, skip, limit, deviceID, active, includeDeleted
-func (_m *DataStore) GetDeviceDeployments(ctx context.Context, skip int, limit int, deviceID string, active *bool, includeDeleted bool) ([]model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, skip, limit, deviceID, active, includeDeleted)
+// FindNewerActiveDeployments provides a mock function with given fields: ctx, createdAfter, skip, limit
+func (_m *DataStore) FindNewerActiveDeployments(ctx context.Context, createdAfter *time.Time, skip int, limit int) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, createdAfter, skip, limit)
 
-	var r0 []model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, int, int, string, *bool, bool) []model.DeviceDeployment); ok {
-		r0 = rf(ctx, skip, limit, deviceID, active, includeDeleted)
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, *time.Time, int, int) []*model.Deployment); ok {
+		r0 = rf(ctx, createdAfter, skip, limit)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeployment)
+			r0 = ret.Get(0).([]*model.Deployment)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int, int, string, *bool, bool) error); ok {
-		r1 = rf(ctx, skip, limit, deviceID, active, includeDeleted)
+	if rf, ok := ret.Get(1).(func(context.Context, *time.Time, int, int) error); ok {
+		r1 = rf(ctx, createdAfter, skip, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -608,29 +974,73 @@ func (_m *DataStore) GetDeviceDeployments(ctx context.Context, skip int, limit i
 	return r0, r1
 }
 
-// GetDeviceDeploymentsForDevice provides a mock function with given fields: ctx, query
-func (_m *DataStore) GetDeviceDeploymentsForDevice(ctx context.Context, query store.ListQueryDeviceDeployments) ([]model.DeviceDeployment, int, error) {
-	ret := _m.Called(ctx, query)
+// FindOldestActiveDeviceDeployment provides a mock function with given fields: ctx, deviceID
+func (_m *DataStore) FindOldestActiveDeviceDeployment(ctx context.Context, deviceID string) (*model.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceID)
 
-	var r0 []model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeployments) []model.DeviceDeployment); ok {
-		r0 = rf(ctx, query)
+	var r0 *model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeployment)
+			r0 = ret.Get(0).(*model.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindOldestActiveDeviceDeploymentID provides a mock function with given fields: ctx, deviceID
+func (_m *DataStore) FindOldestActiveDeviceDeploymentID(ctx context.Context, deviceID string) (string, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindOrphanedImages provides a mock function with given fields: ctx, skip, limit
+func (_m *DataStore) FindOrphanedImages(ctx context.Context, skip int, limit int) ([]*model.Image, int, error) {
+	ret := _m.Called(ctx, skip, limit)
+
+	var r0 []*model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []*model.Image); ok {
+		r0 = rf(ctx, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Image)
 		}
 	}
 
 	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeployments) int); ok {
-		r1 = rf(ctx, query)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) int); ok {
+		r1 = rf(ctx, skip, limit)
 	} else {
 		r1 = ret.Get(1).(int)
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeployments) error); ok {
-		r2 = rf(ctx, query)
+	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
+		r2 = rf(ctx, skip, limit)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -638,22 +1048,557 @@ func (_m *DataStore) GetDeviceDeploymentsForDevice(ctx context.Context, query st
 	return r0, r1, r2
 }
 
-// GetDeviceStatusesForDeployment provides a mock function with given fields: ctx, deploymentID
-func (_m *DataStore) GetDeviceStatusesForDeployment(ctx context.Context, deploymentID string) ([]model.DeviceDeployment, error) {
-	ret := _m.Called(ctx, deploymentID)
+// FindReplacedArtifactFiles provides a mock function with given fields: ctx
+func (_m *DataStore) FindReplacedArtifactFiles(ctx context.Context) ([]model.ReplacedArtifactFile, error) {
+	ret := _m.Called(ctx)
 
-	var r0 []model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, string) []model.DeviceDeployment); ok {
-		r0 = rf(ctx, deploymentID)
+	var r0 []model.ReplacedArtifactFile
+	if rf, ok := ret.Get(0).(func(context.Context) []model.ReplacedArtifactFile); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ReplacedArtifactFile)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindStaleActiveDeployments provides a mock function with given fields: ctx, olderThan, limit
+func (_m *DataStore) FindStaleActiveDeployments(ctx context.Context, olderThan time.Time, limit int) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, olderThan, limit)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []*model.Deployment); ok {
+		r0 = rf(ctx, olderThan, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, olderThan, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUnfinishedByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindUnfinishedByID(ctx context.Context, id string) (*model.Deployment, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Deployment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUnfinishedDeploymentRollbacks provides a mock function with given fields: ctx, triggeredBefore
+func (_m *DataStore) FindUnfinishedDeploymentRollbacks(ctx context.Context, triggeredBefore time.Time) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, triggeredBefore)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*model.Deployment); ok {
+		r0 = rf(ctx, triggeredBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, triggeredBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUnfinishedIDsByArtifactName provides a mock function with given fields: ctx, artifactName, limit
+func (_m *DataStore) FindUnfinishedIDsByArtifactName(ctx context.Context, artifactName string, limit int) ([]string, error) {
+	ret := _m.Called(ctx, artifactName, limit)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []string); ok {
+		r0 = rf(ctx, artifactName, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, artifactName, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUploadLinks provides a mock function with given fields: ctx, expired
+func (_m *DataStore) FindUploadLinks(ctx context.Context, expired time.Time) (store.Iterator[model.UploadLink], error) {
+	ret := _m.Called(ctx, expired)
+
+	var r0 store.Iterator[model.UploadLink]
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) store.Iterator[model.UploadLink]); ok {
+		r0 = rf(ctx, expired)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.UploadLink])
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, expired)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishDeploymentRollback provides a mock function with given fields: ctx, id, deploymentIDs, finished
+func (_m *DataStore) FinishDeploymentRollback(ctx context.Context, id string, deploymentIDs []string, finished time.Time) error {
+	ret := _m.Called(ctx, id, deploymentIDs, finished)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, time.Time) error); ok {
+		r0 = rf(ctx, id, deploymentIDs, finished)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDeploymentCreationTrend provides a mock function with given fields: ctx, from, to, granularity
+func (_m *DataStore) GetDeploymentCreationTrend(ctx context.Context, from time.Time, to time.Time, granularity model.TrendGranularity) ([]model.TrendBucket, error) {
+	ret := _m.Called(ctx, from, to, granularity)
+
+	var r0 []model.TrendBucket
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, model.TrendGranularity) []model.TrendBucket); ok {
+		r0 = rf(ctx, from, to, granularity)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.TrendBucket)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, model.TrendGranularity) error); ok {
+		r1 = rf(ctx, from, to, granularity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentDeviceIndex provides a mock function with given fields: ctx, deploymentID, deviceID
+func (_m *DataStore) GetDeploymentDeviceIndex(ctx context.Context, deploymentID string, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deploymentID, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, deploymentID, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentIDsByArtifactNames provides a mock function with given fields: ctx, artifactNames
+func (_m *DataStore) GetDeploymentIDsByArtifactNames(ctx context.Context, artifactNames []string) ([]string, error) {
+	ret := _m.Called(ctx, artifactNames)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []string); ok {
+		r0 = rf(ctx, artifactNames)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, artifactNames)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentTargetDevices provides a mock function with given fields: ctx, id, skip, limit
+func (_m *DataStore) GetDeploymentTargetDevices(ctx context.Context, id string, skip int, limit int) ([]string, int, error) {
+	ret := _m.Called(ctx, id, skip, limit)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []string); ok {
+		r0 = rf(ctx, id, skip, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) int); ok {
+		r1 = rf(ctx, id, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, int, int) error); ok {
+		r2 = rf(ctx, id, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDeviceDeployment provides a mock function with given fields: ctx, deploymentID, deviceID, includeDeleted
+func (_m *DataStore) GetDeviceDeployment(ctx context.Context, deploymentID string, deviceID string, includeDeleted bool) (*model.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deploymentID, deviceID, includeDeleted)
+
+	var r0 *model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, bool) *model.DeviceDeployment); ok {
+		r0 = rf(ctx, deploymentID, deviceID, includeDeleted)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, bool) error); ok {
+		r1 = rf(ctx, deploymentID, deviceID, includeDeleted)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceDeploymentLog provides a mock function with given fields: ctx, query
+func (_m *DataStore) GetDeviceDeploymentLog(ctx context.Context, query store.ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeploymentLog
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeploymentLog) []model.DeploymentLog); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeploymentLog)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeploymentLog) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeploymentLog) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDeviceDeploymentViewsForDevice provides a mock function with given fields: ctx, query
+func (_m *DataStore) GetDeviceDeploymentViewsForDevice(ctx context.Context, query store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeviceDeploymentView
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeployments) []model.DeviceDeploymentView); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeploymentView)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeployments) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeployments) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDeviceDeployments provides a mock function with given fields: ctx, skip, limit, deviceID, active, includeDeleted
+func (_m *DataStore) GetDeviceDeployments(ctx context.Context, skip int, limit int, deviceID string, active *bool, includeDeleted bool) ([]model.DeviceDeployment, error) {
+	ret := _m.Called(ctx, skip, limit, deviceID, active, includeDeleted)
+
+	var r0 []model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string, *bool, bool) []model.DeviceDeployment); ok {
+		r0 = rf(ctx, skip, limit, deviceID, active, includeDeleted)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, string, *bool, bool) error); ok {
+		r1 = rf(ctx, skip, limit, deviceID, active, includeDeleted)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceDeploymentsForDevice provides a mock function with given fields: ctx, query
+func (_m *DataStore) GetDeviceDeploymentsForDevice(ctx context.Context, query store.ListQueryDeviceDeployments) ([]model.DeviceDeployment, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQueryDeviceDeployments) []model.DeviceDeployment); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeployment)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQueryDeviceDeployments) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQueryDeviceDeployments) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetDevicesListForDeployment provides a mock function with given fields: ctx, query
+func (_m *DataStore) GetDevicesListForDeployment(ctx context.Context, query store.ListQuery) ([]model.DeviceDeployment, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.DeviceDeployment); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeployment)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetFleetSoftwareInventory provides a mock function with given fields: ctx, query
+func (_m *DataStore) GetFleetSoftwareInventory(ctx context.Context, query model.SoftwareInventoryQuery) ([]model.DeviceSoftware, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.DeviceSoftware
+	if rf, ok := ret.Get(0).(func(context.Context, model.SoftwareInventoryQuery) []model.DeviceSoftware); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceSoftware)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.SoftwareInventoryQuery) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.SoftwareInventoryQuery) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetImagesListVersion provides a mock function with given fields: ctx, filt
+func (_m *DataStore) GetImagesListVersion(ctx context.Context, filt *model.ReleaseOrImageFilter) (*model.ListVersion, error) {
+	ret := _m.Called(ctx, filt)
+
+	var r0 *model.ListVersion
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ReleaseOrImageFilter) *model.ListVersion); ok {
+		r0 = rf(ctx, filt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ListVersion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ReleaseOrImageFilter) error); ok {
+		r1 = rf(ctx, filt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLargestDeployments provides a mock function with given fields: ctx, limit
+func (_m *DataStore) GetLargestDeployments(ctx context.Context, limit int) ([]*model.Deployment, error) {
+	ret := _m.Called(ctx, limit)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*model.Deployment); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLastDeviceDeploymentStatus provides a mock function with given fields: ctx, devicesIds
+func (_m *DataStore) GetLastDeviceDeploymentStatus(ctx context.Context, devicesIds []string) ([]model.DeviceDeploymentLastStatus, error) {
+	ret := _m.Called(ctx, devicesIds)
+
+	var r0 []model.DeviceDeploymentLastStatus
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.DeviceDeploymentLastStatus); ok {
+		r0 = rf(ctx, devicesIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeviceDeploymentLastStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, devicesIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLimit provides a mock function with given fields: ctx, name
+func (_m *DataStore) GetLimit(ctx context.Context, name string) (*model.Limit, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *model.Limit
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Limit); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Limit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMaintenanceMode provides a mock function with given fields: ctx
+func (_m *DataStore) GetMaintenanceMode(ctx context.Context) (bool, error) {
+	ret := _m.Called(ctx)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context) bool); ok {
+		r0 = rf(ctx)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeployment)
-		}
+		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, deploymentID)
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -661,52 +1606,45 @@ func (_m *DataStore) GetDeviceStatusesForDeployment(ctx context.Context, deploym
 	return r0, r1
 }
 
-// GetDevicesListForDeployment provides a mock function with given fields: ctx, query
-func (_m *DataStore) GetDevicesListForDeployment(ctx context.Context, query store.ListQuery) ([]model.DeviceDeployment, int, error) {
-	ret := _m.Called(ctx, query)
+// GetRelease provides a mock function with given fields: ctx, name, artifactsSort
+func (_m *DataStore) GetRelease(ctx context.Context, name string, artifactsSort string) (*model.Release, error) {
+	ret := _m.Called(ctx, name, artifactsSort)
 
-	var r0 []model.DeviceDeployment
-	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) []model.DeviceDeployment); ok {
-		r0 = rf(ctx, query)
+	var r0 *model.Release
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *model.Release); ok {
+		r0 = rf(ctx, name, artifactsSort)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeployment)
+			r0 = ret.Get(0).(*model.Release)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) int); ok {
-		r1 = rf(ctx, query)
-	} else {
-		r1 = ret.Get(1).(int)
-	}
-
-	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, store.ListQuery) error); ok {
-		r2 = rf(ctx, query)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, name, artifactsSort)
 	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
-// GetLastDeviceDeploymentStatus provides a mock function with given fields: ctx, devicesIds
-func (_m *DataStore) GetLastDeviceDeploymentStatus(ctx context.Context, devicesIds []string) ([]model.DeviceDeploymentLastStatus, error) {
-	ret := _m.Called(ctx, devicesIds)
+// GetReleaseForArtifact provides a mock function with given fields: ctx, artifactID
+func (_m *DataStore) GetReleaseForArtifact(ctx context.Context, artifactID string) (*model.Release, error) {
+	ret := _m.Called(ctx, artifactID)
 
-	var r0 []model.DeviceDeploymentLastStatus
-	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.DeviceDeploymentLastStatus); ok {
-		r0 = rf(ctx, devicesIds)
+	var r0 *model.Release
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Release); ok {
+		r0 = rf(ctx, artifactID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.DeviceDeploymentLastStatus)
+			r0 = ret.Get(0).(*model.Release)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
-		r1 = rf(ctx, devicesIds)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, artifactID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -714,22 +1652,22 @@ func (_m *DataStore) GetLastDeviceDeploymentStatus(ctx context.Context, devicesI
 	return r0, r1
 }
 
-// GetLimit provides a mock function with given fields: ctx, name
-func (_m *DataStore) GetLimit(ctx context.Context, name string) (*model.Limit, error) {
-	ret := _m.Called(ctx, name)
+// GetReleaseRolloutStats provides a mock function with given fields: ctx, name, includeDeleted
+func (_m *DataStore) GetReleaseRolloutStats(ctx context.Context, name string, includeDeleted bool) (*model.ReleaseRolloutStats, error) {
+	ret := _m.Called(ctx, name, includeDeleted)
 
-	var r0 *model.Limit
-	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Limit); ok {
-		r0 = rf(ctx, name)
+	var r0 *model.ReleaseRolloutStats
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *model.ReleaseRolloutStats); ok {
+		r0 = rf(ctx, name, includeDeleted)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*model.Limit)
+			r0 = ret.Get(0).(*model.ReleaseRolloutStats)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, name)
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, name, includeDeleted)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -767,6 +1705,29 @@ func (_m *DataStore) GetReleases(ctx context.Context, filt *model.ReleaseOrImage
 	return r0, r1, r2
 }
 
+// GetReleasesListVersion provides a mock function with given fields: ctx, filt
+func (_m *DataStore) GetReleasesListVersion(ctx context.Context, filt *model.ReleaseOrImageFilter) (*model.ListVersion, error) {
+	ret := _m.Called(ctx, filt)
+
+	var r0 *model.ListVersion
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ReleaseOrImageFilter) *model.ListVersion); ok {
+		r0 = rf(ctx, filt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ListVersion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ReleaseOrImageFilter) error); ok {
+		r1 = rf(ctx, filt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetStorageSettings provides a mock function with given fields: ctx
 func (_m *DataStore) GetStorageSettings(ctx context.Context) (*model.StorageSettings, error) {
 	ret := _m.Called(ctx)
@@ -836,6 +1797,52 @@ func (_m *DataStore) GetUpdateTypes(ctx context.Context) ([]string, error) {
 	return r0, r1
 }
 
+// GetUpdateTypesWithCounts provides a mock function with given fields: ctx
+func (_m *DataStore) GetUpdateTypesWithCounts(ctx context.Context) (map[string]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]int
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUploadIntent provides a mock function with given fields: ctx, id
+func (_m *DataStore) GetUploadIntent(ctx context.Context, id string) (*model.UploadLink, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.UploadLink
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.UploadLink); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UploadLink)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HasDeploymentForDevice provides a mock function with given fields: ctx, deploymentID, deviceID
 func (_m *DataStore) HasDeploymentForDevice(ctx context.Context, deploymentID string, deviceID string) (bool, error) {
 	ret := _m.Called(ctx, deploymentID, deviceID)
@@ -903,6 +1910,29 @@ func (_m *DataStore) ImageByNameAndDeviceType(ctx context.Context, name string,
 	return r0, r1
 }
 
+// ImageByNameAndProvides provides a mock function with given fields: ctx, name, provides
+func (_m *DataStore) ImageByNameAndProvides(ctx context.Context, name string, provides map[string]string) (*model.Image, error) {
+	ret := _m.Called(ctx, name, provides)
+
+	var r0 *model.Image
+	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]string) *model.Image); ok {
+		r0 = rf(ctx, name, provides)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Image)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, map[string]string) error); ok {
+		r1 = rf(ctx, name, provides)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ImagesByName provides a mock function with given fields: ctx, artifactName
 func (_m *DataStore) ImagesByName(ctx context.Context, artifactName string) ([]*model.Image, error) {
 	ret := _m.Called(ctx, artifactName)
@@ -954,6 +1984,48 @@ func (_m *DataStore) IncrementDeploymentTotalSize(ctx context.Context, deploymen
 	return r0
 }
 
+// InitMaintenanceMode provides a mock function with given fields: ctx, enabled
+func (_m *DataStore) InitMaintenanceMode(ctx context.Context, enabled bool) error {
+	ret := _m.Called(ctx, enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) error); ok {
+		r0 = rf(ctx, enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertArtifactDeletion provides a mock function with given fields: ctx, deletion
+func (_m *DataStore) InsertArtifactDeletion(ctx context.Context, deletion *model.ArtifactDeletion) error {
+	ret := _m.Called(ctx, deletion)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ArtifactDeletion) error); ok {
+		r0 = rf(ctx, deletion)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertArtifactImportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) InsertArtifactImportJob(ctx context.Context, job *model.ArtifactImportJob) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ArtifactImportJob) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // InsertDeployment provides a mock function with given fields: ctx, deployment
 func (_m *DataStore) InsertDeployment(ctx context.Context, deployment *model.Deployment) error {
 	ret := _m.Called(ctx, deployment)
@@ -982,6 +2054,20 @@ func (_m *DataStore) InsertDeviceDeployment(ctx context.Context, deviceDeploymen
 	return r0
 }
 
+// InsertExportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) InsertExportJob(ctx context.Context, job *model.ExportJob) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ExportJob) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // InsertImage provides a mock function with given fields: ctx, image
 func (_m *DataStore) InsertImage(ctx context.Context, image *model.Image) error {
 	ret := _m.Called(ctx, image)
@@ -1017,6 +2103,20 @@ func (_m *DataStore) InsertMany(ctx context.Context, deployment ...*model.Device
 	return r0
 }
 
+// InsertReplacedArtifactFile provides a mock function with given fields: ctx, file
+func (_m *DataStore) InsertReplacedArtifactFile(ctx context.Context, file *model.ReplacedArtifactFile) error {
+	ret := _m.Called(ctx, file)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ReplacedArtifactFile) error); ok {
+		r0 = rf(ctx, file)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // InsertUploadIntent provides a mock function with given fields: ctx, link
 func (_m *DataStore) InsertUploadIntent(ctx context.Context, link *model.UploadLink) error {
 	ret := _m.Called(ctx, link)
@@ -1039,17 +2139,139 @@ func (_m *DataStore) IsArtifactUnique(ctx context.Context, artifactName string,
 	if rf, ok := ret.Get(0).(func(context.Context, string, []string) bool); ok {
 		r0 = rf(ctx, artifactName, deviceTypesCompatible)
 	} else {
-		r0 = ret.Get(0).(bool)
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(ctx, artifactName, deviceTypesCompatible)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IterateDeployments provides a mock function with given fields: ctx
+func (_m *DataStore) IterateDeployments(ctx context.Context) (store.Iterator[model.Deployment], error) {
+	ret := _m.Called(ctx)
+
+	var r0 store.Iterator[model.Deployment]
+	if rf, ok := ret.Get(0).(func(context.Context) store.Iterator[model.Deployment]); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.Deployment])
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IterateDeviceDeployments provides a mock function with given fields: ctx
+func (_m *DataStore) IterateDeviceDeployments(ctx context.Context) (store.Iterator[model.DeviceDeployment], error) {
+	ret := _m.Called(ctx)
+
+	var r0 store.Iterator[model.DeviceDeployment]
+	if rf, ok := ret.Get(0).(func(context.Context) store.Iterator[model.DeviceDeployment]); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.DeviceDeployment])
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IterateDeviceStatusesForDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *DataStore) IterateDeviceStatusesForDeployment(ctx context.Context, deploymentID string) (store.Iterator[model.DeviceDeployment], error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 store.Iterator[model.DeviceDeployment]
+	if rf, ok := ret.Get(0).(func(context.Context, string) store.Iterator[model.DeviceDeployment]); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.DeviceDeployment])
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IterateDevicesListForDeployment provides a mock function with given fields: ctx, query
+func (_m *DataStore) IterateDevicesListForDeployment(ctx context.Context, query store.ListQuery) (store.Iterator[model.DeviceDeployment], error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 store.Iterator[model.DeviceDeployment]
+	if rf, ok := ret.Get(0).(func(context.Context, store.ListQuery) store.Iterator[model.DeviceDeployment]); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.Iterator[model.DeviceDeployment])
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, store.ListQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListArtifactDeletions provides a mock function with given fields: ctx, query
+func (_m *DataStore) ListArtifactDeletions(ctx context.Context, query model.ArtifactDeletionsQuery) ([]model.ArtifactDeletion, int, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.ArtifactDeletion
+	if rf, ok := ret.Get(0).(func(context.Context, model.ArtifactDeletionsQuery) []model.ArtifactDeletion); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ArtifactDeletion)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, model.ArtifactDeletionsQuery) int); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(int)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
-		r1 = rf(ctx, artifactName, deviceTypesCompatible)
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.ArtifactDeletionsQuery) error); ok {
+		r2 = rf(ctx, query)
 	} else {
-		r1 = ret.Error(1)
+		r2 = ret.Error(2)
 	}
 
-	return r0, r1
+	return r0, r1, r2
 }
 
 // ListImages provides a mock function with given fields: ctx, filt
@@ -1133,6 +2355,99 @@ func (_m *DataStore) ProvisionTenant(ctx context.Context, tenantId string) error
 	return r0
 }
 
+// PurgeDeletedDeployments provides a mock function with given fields: ctx, deletedBefore
+func (_m *DataStore) PurgeDeletedDeployments(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeviceDeploymentLogs provides a mock function with given fields: ctx, finishedBefore
+func (_m *DataStore) PurgeDeviceDeploymentLogs(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	ret := _m.Called(ctx, finishedBefore)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, finishedBefore)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, finishedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RebuildReleases provides a mock function with given fields: ctx, dryRun
+func (_m *DataStore) RebuildReleases(ctx context.Context, dryRun bool) (*model.ReleasesRebuild, error) {
+	ret := _m.Called(ctx, dryRun)
+
+	var r0 *model.ReleasesRebuild
+	if rf, ok := ret.Get(0).(func(context.Context, bool) *model.ReleasesRebuild); ok {
+		r0 = rf(ctx, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReleasesRebuild)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(ctx, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplaceImage provides a mock function with given fields: ctx, image, revision
+func (_m *DataStore) ReplaceImage(ctx context.Context, image *model.Image, revision int) error {
+	ret := _m.Called(ctx, image, revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Image, int) error); ok {
+		r0 = rf(ctx, image, revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplaceImageTags provides a mock function with given fields: ctx, imageID, tags
+func (_m *DataStore) ReplaceImageTags(ctx context.Context, imageID string, tags model.Tags) error {
+	ret := _m.Called(ctx, imageID, tags)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Tags) error); ok {
+		r0 = rf(ctx, imageID, tags)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ReplaceReleaseTags provides a mock function with given fields: ctx, releaseName, tags
 func (_m *DataStore) ReplaceReleaseTags(ctx context.Context, releaseName string, tags model.Tags) error {
 	ret := _m.Called(ctx, releaseName, tags)
@@ -1147,6 +2462,55 @@ func (_m *DataStore) ReplaceReleaseTags(ctx context.Context, releaseName string,
 	return r0
 }
 
+// ResetDeploymentDeviceCount provides a mock function with given fields: ctx, deploymentID, previous, count
+func (_m *DataStore) ResetDeploymentDeviceCount(ctx context.Context, deploymentID string, previous *int, count int) error {
+	ret := _m.Called(ctx, deploymentID, previous, count)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *int, int) error); ok {
+		r0 = rf(ctx, deploymentID, previous, count)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreDeployment provides a mock function with given fields: ctx, id, deletedAfter
+func (_m *DataStore) RestoreDeployment(ctx context.Context, id string, deletedAfter time.Time) error {
+	ret := _m.Called(ctx, id, deletedAfter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, id, deletedAfter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RetryDeviceDeployment provides a mock function with given fields: ctx, deviceID, deploymentID, maxRetries
+func (_m *DataStore) RetryDeviceDeployment(ctx context.Context, deviceID string, deploymentID string, maxRetries uint) (bool, error) {
+	ret := _m.Called(ctx, deviceID, deploymentID, maxRetries)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, uint) bool); ok {
+		r0 = rf(ctx, deviceID, deploymentID, maxRetries)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, uint) error); ok {
+		r1 = rf(ctx, deviceID, deploymentID, maxRetries)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SaveDeviceDeploymentLog provides a mock function with given fields: ctx, log
 func (_m *DataStore) SaveDeviceDeploymentLog(ctx context.Context, log model.DeploymentLog) error {
 	ret := _m.Called(ctx, log)
@@ -1203,6 +2567,34 @@ func (_m *DataStore) SaveUpdateTypes(ctx context.Context, updateTypes []string)
 	return r0
 }
 
+// SetDeploymentAborted provides a mock function with given fields: ctx, id, abortedBy, now
+func (_m *DataStore) SetDeploymentAborted(ctx context.Context, id string, abortedBy string, now time.Time) error {
+	ret := _m.Called(ctx, id, abortedBy, now)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) error); ok {
+		r0 = rf(ctx, id, abortedBy, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDeploymentCurrentPhase provides a mock function with given fields: ctx, deploymentID, phase
+func (_m *DataStore) SetDeploymentCurrentPhase(ctx context.Context, deploymentID string, phase int) error {
+	ret := _m.Called(ctx, deploymentID, phase)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) error); ok {
+		r0 = rf(ctx, deploymentID, phase)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetDeploymentDeviceCount provides a mock function with given fields: ctx, deploymentID, count
 func (_m *DataStore) SetDeploymentDeviceCount(ctx context.Context, deploymentID string, count int) error {
 	ret := _m.Called(ctx, deploymentID, count)
@@ -1218,12 +2610,47 @@ func (_m *DataStore) SetDeploymentDeviceCount(ctx context.Context, deploymentID
 }
 
 // SetDeploymentStatus provides a mock function with given fields: ctx, id, status, now
-func (_m *DataStore) SetDeploymentStatus(ctx context.Context, id string, status model.DeploymentStatus, now time.Time) error {
+func (_m *DataStore) SetDeploymentStatus(ctx context.Context, id string, status model.DeploymentStatus, now time.Time) (bool, error) {
 	ret := _m.Called(ctx, id, status, now)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeploymentStatus, time.Time) error); ok {
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeploymentStatus, time.Time) bool); ok {
 		r0 = rf(ctx, id, status, now)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.DeploymentStatus, time.Time) error); ok {
+		r1 = rf(ctx, id, status, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetLimit provides a mock function with given fields: ctx, limit
+func (_m *DataStore) SetLimit(ctx context.Context, limit *model.Limit) error {
+	ret := _m.Called(ctx, limit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Limit) error); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetMaintenanceMode provides a mock function with given fields: ctx, enabled
+func (_m *DataStore) SetMaintenanceMode(ctx context.Context, enabled bool) error {
+	ret := _m.Called(ctx, enabled)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) error); ok {
+		r0 = rf(ctx, enabled)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -1245,6 +2672,106 @@ func (_m *DataStore) SetStorageSettings(ctx context.Context, storageSettings *mo
 	return r0
 }
 
+// SetUploadIntentPart provides a mock function with given fields: ctx, id, part
+func (_m *DataStore) SetUploadIntentPart(ctx context.Context, id string, part model.UploadPart) error {
+	ret := _m.Called(ctx, id, part)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.UploadPart) error); ok {
+		r0 = rf(ctx, id, part)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StartDeployment provides a mock function with given fields: ctx, id, started, phases
+func (_m *DataStore) StartDeployment(ctx context.Context, id string, started time.Time, phases model.DeploymentPhases) error {
+	ret := _m.Called(ctx, id, started, phases)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, model.DeploymentPhases) error); ok {
+		r0 = rf(ctx, id, started, phases)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StartDeploymentRollback provides a mock function with given fields: ctx, id, triggered
+func (_m *DataStore) StartDeploymentRollback(ctx context.Context, id string, triggered time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, triggered)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(ctx, id, triggered)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, triggered)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartExportJob provides a mock function with given fields: ctx, id, staleBefore
+func (_m *DataStore) StartExportJob(ctx context.Context, id string, staleBefore time.Time) (*model.ExportJob, error) {
+	ret := _m.Called(ctx, id, staleBefore)
+
+	var r0 *model.ExportJob
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) *model.ExportJob); ok {
+		r0 = rf(ctx, id, staleBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExportJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, staleBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TouchDeviceDeployment provides a mock function with given fields: ctx, deviceID, deploymentID, status
+func (_m *DataStore) TouchDeviceDeployment(ctx context.Context, deviceID string, deploymentID string, status model.DeviceDeploymentStatus) error {
+	ret := _m.Called(ctx, deviceID, deploymentID, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, model.DeviceDeploymentStatus) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TouchExportJob provides a mock function with given fields: ctx, id
+func (_m *DataStore) TouchExportJob(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Update provides a mock function with given fields: ctx, image
 func (_m *DataStore) Update(ctx context.Context, image *model.Image) (bool, error) {
 	ret := _m.Called(ctx, image)
@@ -1266,6 +2793,34 @@ func (_m *DataStore) Update(ctx context.Context, image *model.Image) (bool, erro
 	return r0, r1
 }
 
+// UpdateArtifactImportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) UpdateArtifactImportJob(ctx context.Context, job *model.ArtifactImportJob) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ArtifactImportJob) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDeployment provides a mock function with given fields: ctx, id, patch
+func (_m *DataStore) UpdateDeployment(ctx context.Context, id string, patch model.DeploymentPatch) error {
+	ret := _m.Called(ctx, id, patch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeploymentPatch) error); ok {
+		r0 = rf(ctx, id, patch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateDeploymentsWithArtifactName provides a mock function with given fields: ctx, artifactName, artifactIDs
 func (_m *DataStore) UpdateDeploymentsWithArtifactName(ctx context.Context, artifactName string, artifactIDs []string) error {
 	ret := _m.Called(ctx, artifactName, artifactIDs)
@@ -1315,6 +2870,34 @@ func (_m *DataStore) UpdateDeviceDeploymentStatus(ctx context.Context, deviceID
 	return r0, r1
 }
 
+// UpdateExportJob provides a mock function with given fields: ctx, job
+func (_m *DataStore) UpdateExportJob(ctx context.Context, job *model.ExportJob) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ExportJob) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateImageReferences provides a mock function with given fields: ctx, image
+func (_m *DataStore) UpdateImageReferences(ctx context.Context, image *model.Image) error {
+	ret := _m.Called(ctx, image)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Image) error); ok {
+		r0 = rf(ctx, image)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateRelease provides a mock function with given fields: ctx, releaseName, release
 func (_m *DataStore) UpdateRelease(ctx context.Context, releaseName string, release model.ReleasePatch) error {
 	ret := _m.Called(ctx, releaseName, release)
@@ -51,6 +51,11 @@ const (
 	CollectionUploadIntents        = "uploads"
 	CollectionReleases             = "releases"
 	CollectionUpdateTypes          = "update_types"
+	CollectionArtifactImports      = "artifact_imports"
+	CollectionExports              = "exports"
+	CollectionArtifactDeletions    = "artifact_deletions"
+	CollectionReplacedArtifacts    = "replaced_artifacts"
+	CollectionMaintenanceMode      = "maintenance_mode"
 )
 
 const DefaultDocumentLimit = 20
@@ -107,6 +112,40 @@ var (
 	// Indexes 1.2.16
 	IndexNameDeploymentConstructorChecksum = "deployment_deploymentconstructor_checksum"
 
+	// Indexes 1.2.17
+	IndexNameDeviceDeploymentFinishedLog = "finished_log"
+
+	// Indexes 1.2.18
+	IndexNameDeviceDeploymentConfirmationDeadline = "confirmation_deadline"
+
+	// Indexes 1.2.19
+	IndexNameArtifactDeletionDeleted      = "deleted"
+	IndexNameArtifactDeletionArtifactName = "artifact_name_deleted"
+
+	// Indexes 1.2.20
+	IndexNameDeploymentArtifactNameCreated = "artifact_name_created"
+
+	// Indexes 1.2.21
+	IndexNameDeploymentDeviceCount = "device_count"
+
+	// Indexes 1.2.22
+	IndexNameDeviceDeploymentDeploymentIDCreated = "deploymentid_created_deviceid"
+
+	// Indexes 1.2.23
+	IndexNameDeploymentGroupsCreated = "group_created"
+
+	// Indexes 1.2.25
+	IndexNameImageTags = "image_tags"
+
+	// Indexes 1.2.26
+	IndexNameDeploymentDeviceListCreated = "device_list_created"
+
+	// Indexes 1.2.27
+	IndexNameImageModified = "modified"
+
+	// Indexes 1.2.28
+	IndexNameDeviceDeploymentAssignedImageActive = "active_image_id"
+
 	_false         = false
 	_true          = true
 	StorageIndexes = mongo.IndexModel{
@@ -336,6 +375,7 @@ var (
 	ErrConflictingDeployment = errors.New(
 		"an active deployment with the same parameter already exists",
 	)
+	ErrDeviceCountChanged = errors.New("the device count changed concurrently")
 )
 
 // Database keys
@@ -354,6 +394,9 @@ const (
 	StorageKeyUpdateType       = "meta_artifact.updates.typeinfo.type"
 	StorageKeyImageDescription = "meta.description"
 	StorageKeyImageModified    = "modified"
+	StorageKeyImageCompression = "meta_artifact.compression"
+	StorageKeyImageRevision    = "revision"
+	StorageKeyImageTags        = "tags"
 
 	// releases
 	StorageKeyReleaseName                      = "_id"
@@ -362,8 +405,11 @@ const (
 	StorageKeyReleaseNotes                     = "notes"
 	StorageKeyReleaseArtifacts                 = "artifacts"
 	StorageKeyReleaseArtifactsCount            = "artifacts_count"
+	StorageKeyReleaseArtifactsIndex            = StorageKeyReleaseArtifacts + ".$"
 	StorageKeyReleaseArtifactsIndexDescription = StorageKeyReleaseArtifacts + ".$." +
 		StorageKeyImageDescription
+	StorageKeyReleaseArtifactsIndexTags = StorageKeyReleaseArtifacts + ".$." +
+		StorageKeyImageTags
 	StorageKeyReleaseArtifactsDescription = StorageKeyReleaseArtifacts + "." +
 		StorageKeyImageDescription
 	StorageKeyReleaseArtifactsDeviceTypes = StorageKeyReleaseArtifacts + "." +
@@ -380,10 +426,13 @@ const (
 		StorageKeyImageProvidesIdx
 
 	StorageKeyDeviceDeploymentLogMessages = "messages"
+	StorageKeyDeviceDeploymentLogAttempt  = "attempt"
 
 	StorageKeyDeviceDeploymentAssignedImage   = "image"
 	StorageKeyDeviceDeploymentAssignedImageId = StorageKeyDeviceDeploymentAssignedImage +
 		"." + StorageKeyId
+	StorageKeyDeviceDeploymentAssignedImageRevision = StorageKeyDeviceDeploymentAssignedImage +
+		"." + StorageKeyImageRevision
 
 	StorageKeyDeviceDeploymentActive         = "active"
 	StorageKeyDeviceDeploymentCreated        = "created"
@@ -397,22 +446,44 @@ const (
 	StorageKeyDeviceDeploymentArtifact       = "image"
 	StorageKeyDeviceDeploymentRequest        = "request"
 	StorageKeyDeviceDeploymentDeleted        = "deleted"
+	StorageKeyDeviceDeploymentAttempts       = "attempts"
+
+	StorageKeyDeviceDeploymentConfirmationDeadline = "confirmation_deadline"
+	StorageKeyDeviceDeploymentLastUpdated          = "last_updated"
+	StorageKeyDeviceDeploymentSubStateHistory      = "substate_history"
+
+	StorageKeyArtifactDeletionArtifactName = "artifact_name"
+	StorageKeyArtifactDeletionDeleted      = "deleted"
 
 	StorageKeyDeploymentName                = "deploymentconstructor.name"
 	StorageKeyDeploymentArtifactName        = "deploymentconstructor.artifactname"
+	StorageKeyDeploymentGroup               = "deploymentconstructor.group"
 	StorageKeyDeploymentConstructorChecksum = "deploymentconstructor_checksum"
 	StorageKeyDeploymentStats               = "stats"
 	StorageKeyDeploymentActive              = "active"
 	StorageKeyDeploymentStatus              = "status"
 	StorageKeyDeploymentCreated             = "created"
+	StorageKeyDeploymentStarted             = "started"
+	StorageKeyDeploymentPhases              = "phases"
 	StorageKeyDeploymentDeviceList          = "device_list"
 	StorageKeyDeploymentStatsCreated        = "created"
 	StorageKeyDeploymentFinished            = "finished"
 	StorageKeyDeploymentArtifacts           = "artifacts"
+	StorageKeyDeploymentGroups              = "groups"
 	StorageKeyDeploymentDeviceCount         = "device_count"
 	StorageKeyDeploymentMaxDevices          = "max_devices"
 	StorageKeyDeploymentType                = "type"
 	StorageKeyDeploymentTotalSize           = "statistics.total_size"
+	StorageKeyDeploymentDeleted             = "deleted"
+	StorageKeyDeploymentDescription         = "description"
+	StorageKeyDeploymentRollback            = "rollback"
+	StorageKeyDeploymentRollbackDeployments = "rollback.deployments"
+	StorageKeyDeploymentRollbackTriggered   = "rollback.triggered"
+	StorageKeyDeploymentRollbackFinished    = "rollback.finished"
+	StorageKeyDeploymentCurrentPhase        = "current_phase"
+	StorageKeyDeploymentCreatedBy           = "created_by"
+	StorageKeyDeploymentAbortedBy           = "aborted_by"
+	StorageKeyDeploymentAborted             = "aborted"
 
 	StorageKeyStorageSettingsDefaultID      = "settings"
 	StorageKeyStorageSettingsBucket         = "bucket"
@@ -432,12 +503,73 @@ const (
 
 type DataStoreMongo struct {
 	client *mongo.Client
+	// dbName is the name of the default database; the tenant databases
+	// are named after it.
+	dbName string
+
+	// queryTimeout bounds the duration of the hot read paths;
+	// zero means no timeout.
+	queryTimeout time.Duration
+	// subStateHistoryLength is the number of status reports kept in the
+	// substate history of the device deployments; zero disables it.
+	subStateHistoryLength int
+	// migrationOptions apply to the databases of the provisioned tenants.
+	migrationOptions MigrationOptions
 }
 
 func NewDataStoreMongoWithClient(client *mongo.Client) *DataStoreMongo {
 	return &DataStoreMongo{
 		client: client,
+		dbName: DbName,
+	}
+}
+
+// WithDbName sets the name of the default database, which is also the
+// prefix of the tenant databases.
+func (db *DataStoreMongo) WithDbName(name string) *DataStoreMongo {
+	db.dbName = name
+	return db
+}
+
+// WithQueryTimeout sets the timeout applied to the frequent read queries.
+func (db *DataStoreMongo) WithQueryTimeout(timeout time.Duration) *DataStoreMongo {
+	db.queryTimeout = timeout
+	return db
+}
+
+// WithSubStateHistoryLength keeps the given number of latest status reports
+// in the substate history of the device deployments.
+func (db *DataStoreMongo) WithSubStateHistoryLength(length int) *DataStoreMongo {
+	db.subStateHistoryLength = length
+	return db
+}
+
+// WithMigrationOptions sets the options of the migrations applied to the
+// databases of the provisioned tenants.
+func (db *DataStoreMongo) WithMigrationOptions(opts MigrationOptions) *DataStoreMongo {
+	db.migrationOptions = opts
+	return db
+}
+
+// subStateHistoryPush returns the $push operand appending the status report
+// to the substate history, or nil if the history is disabled.
+func (db *DataStoreMongo) subStateHistoryPush(entry model.SubStateHistoryEntry) bson.D {
+	if db.subStateHistoryLength <= 0 {
+		return nil
+	}
+	return bson.D{{Key: StorageKeyDeviceDeploymentSubStateHistory, Value: bson.D{
+		{Key: "$each", Value: bson.A{entry}},
+		{Key: "$slice", Value: -db.subStateHistoryLength},
+	}}}
+}
+
+func (db *DataStoreMongo) withQueryTimeout(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, db.queryTimeout)
 }
 
 func NewMongoClient(ctx context.Context, c config.Reader) (*mongo.Client, error) {
@@ -469,8 +601,13 @@ func NewMongoClient(ctx context.Context, c config.Reader) (*mongo.Client, error)
 		clientOptions.SetTLSConfig(tlsConfig)
 	}
 
-	// Set 10s timeout
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	connectTimeout := c.GetInt(dconfig.SettingMongoConnectTimeout)
+	if connectTimeout <= 0 {
+		return nil, errors.Errorf("invalid %s: %d, must be a positive number of seconds",
+			dconfig.SettingMongoConnectTimeout, connectTimeout)
+	}
+	clientOptions.SetConnectTimeout(time.Duration(connectTimeout) * time.Second)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(connectTimeout)*time.Second)
 	defer cancel()
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
@@ -486,7 +623,7 @@ func NewMongoClient(ctx context.Context, c config.Reader) (*mongo.Client, error)
 }
 
 func (db *DataStoreMongo) Ping(ctx context.Context) error {
-	res := db.client.Database(DbName).RunCommand(ctx, bson.M{"ping": 1})
+	res := db.client.Database(db.dbName).RunCommand(ctx, bson.M{"ping": 1})
 	return res.Err()
 }
 
@@ -494,7 +631,7 @@ func (db *DataStoreMongo) setCurrentDbVersion(
 	ctx context.Context,
 ) error {
 	versions, err := migrate.GetMigrationInfo(
-		ctx, db.client, mstore.DbFromContext(ctx, DatabaseName))
+		ctx, db.client, mstore.DbFromContext(ctx, db.dbName))
 	if err != nil {
 		return errors.Wrap(err, "failed to list applied migrations")
 	}
@@ -509,7 +646,7 @@ func (db *DataStoreMongo) setCurrentDbVersion(
 	if currentDbVersion == nil {
 		currentDbVersion = map[string]*migrate.Version{}
 	}
-	currentDbVersion[mstore.DbFromContext(ctx, DatabaseName)] = &current
+	currentDbVersion[mstore.DbFromContext(ctx, db.dbName)] = &current
 	return nil
 }
 
@@ -517,18 +654,21 @@ func (db *DataStoreMongo) getCurrentDbVersion(
 	ctx context.Context,
 ) (*migrate.Version, error) {
 	if currentDbVersion == nil ||
-		currentDbVersion[mstore.DbFromContext(ctx, DatabaseName)] == nil {
+		currentDbVersion[mstore.DbFromContext(ctx, db.dbName)] == nil {
 		if err := db.setCurrentDbVersion(ctx); err != nil {
 			return nil, err
 		}
 	}
-	return currentDbVersion[mstore.DbFromContext(ctx, DatabaseName)], nil
+	return currentDbVersion[mstore.DbFromContext(ctx, db.dbName)], nil
 }
 
 func (db *DataStoreMongo) GetReleases(
 	ctx context.Context,
 	filt *model.ReleaseOrImageFilter,
 ) ([]model.Release, int, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
 	current, err := db.getCurrentDbVersion(ctx)
 	if err != nil {
 		return []model.Release{}, 0, err
@@ -645,7 +785,7 @@ func (db *DataStoreMongo) getReleases_1_2_14(
 		}}},
 	)
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	cursor, err := collImg.Aggregate(ctx, pipe)
@@ -706,33 +846,10 @@ func (db *DataStoreMongo) getReleases_1_2_15(
 	}
 	opts.SetProjection(projection)
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collReleases := database.Collection(CollectionReleases)
 
-	filter := bson.M{}
-	if filt != nil {
-		if filt.Name != "" {
-			filter[StorageKeyReleaseName] = bson.M{"$regex": primitive.Regex{
-				Pattern: regexp.QuoteMeta(filt.Name) + ".*",
-				Options: "i",
-			}}
-		}
-		if len(filt.Tags) > 0 {
-			filter[StorageKeyReleaseTags] = bson.M{"$in": filt.Tags}
-		}
-		if filt.Description != "" {
-			filter[StorageKeyReleaseArtifactsDescription] = bson.M{"$regex": primitive.Regex{
-				Pattern: ".*" + regexp.QuoteMeta(filt.Description) + ".*",
-				Options: "i",
-			}}
-		}
-		if filt.DeviceType != "" {
-			filter[StorageKeyReleaseArtifactsDeviceTypes] = filt.DeviceType
-		}
-		if filt.UpdateType != "" {
-			filter[StorageKeyReleaseArtifactsUpdateTypes] = filt.UpdateType
-		}
-	}
+	filter := releasesFilter(filt)
 	releases := []model.Release{}
 	cursor, err := collReleases.Find(ctx, filter, opts)
 	if err != nil {
@@ -755,10 +872,123 @@ func (db *DataStoreMongo) getReleases_1_2_15(
 	return releases, int(count), nil
 }
 
+func releasesFilter(filt *model.ReleaseOrImageFilter) bson.M {
+	filter := bson.M{}
+	if filt == nil {
+		return filter
+	}
+	if filt.Name != "" {
+		filter[StorageKeyReleaseName] = bson.M{"$regex": primitive.Regex{
+			Pattern: regexp.QuoteMeta(filt.Name) + ".*",
+			Options: "i",
+		}}
+	}
+	if len(filt.Tags) > 0 {
+		filter[StorageKeyReleaseTags] = bson.M{"$in": filt.Tags}
+	}
+	if filt.Description != "" {
+		filter[StorageKeyReleaseArtifactsDescription] = bson.M{"$regex": primitive.Regex{
+			Pattern: ".*" + regexp.QuoteMeta(filt.Description) + ".*",
+			Options: "i",
+		}}
+	}
+	if filt.DeviceType != "" {
+		filter[StorageKeyReleaseArtifactsDeviceTypes] = filt.DeviceType
+	}
+	if filt.UpdateType != "" {
+		filter[StorageKeyReleaseArtifactsUpdateTypes] = filt.UpdateType
+	}
+	if filt.ArtifactsCountMin != nil || filt.ArtifactsCountMax != nil {
+		count := bson.M{}
+		if filt.ArtifactsCountMin != nil {
+			count["$gte"] = *filt.ArtifactsCountMin
+		}
+		if filt.ArtifactsCountMax != nil {
+			count["$lte"] = *filt.ArtifactsCountMax
+		}
+		filter[StorageKeyReleaseArtifactsCount] = count
+	}
+	return filter
+}
+
+// GetReleasesListVersion returns the number of releases matching the filter
+// and the time the latest of them was modified, or nil if the releases are
+// not migrated to their own collection yet.
+func (db *DataStoreMongo) GetReleasesListVersion(
+	ctx context.Context,
+	filt *model.ReleaseOrImageFilter,
+) (*model.ListVersion, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	current, err := db.getCurrentDbVersion(ctx)
+	if err != nil {
+		return nil, err
+	} else if current == nil {
+		return nil, errors.New("couldn't get current database version")
+	}
+	target, err := migrate.NewVersion(DbVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get latest DB version")
+	}
+	if migrate.VersionIsLess(*current, *target) {
+		return nil, nil
+	}
+	collReleases := db.client.
+		Database(mstore.DbFromContext(ctx, db.dbName)).
+		Collection(CollectionReleases)
+	return listVersion(ctx, collReleases, releasesFilter(filt), StorageKeyReleaseModified)
+}
+
+// GetImagesListVersion returns the number of artifacts matching the filter
+// and the time the latest of them was modified.
+func (db *DataStoreMongo) GetImagesListVersion(
+	ctx context.Context,
+	filt *model.ReleaseOrImageFilter,
+) (*model.ListVersion, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	collImg := db.client.
+		Database(mstore.DbFromContext(ctx, db.dbName)).
+		Collection(CollectionImages)
+	return listVersion(ctx, collImg, imagesFilter(filt), StorageKeyImageModified)
+}
+
+func listVersion(
+	ctx context.Context,
+	collection *mongo.Collection,
+	filter bson.M,
+	modifiedKey string,
+) (*model.ListVersion, error) {
+	cursor, err := collection.Aggregate(ctx, []bson.D{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+			{Key: "modified", Value: bson.M{"$max": "$" + modifiedKey}},
+		}}},
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to get list version")
+	}
+	defer cursor.Close(ctx)
+
+	version := new(model.ListVersion)
+	if cursor.Next(ctx) {
+		if err = cursor.Decode(version); err != nil {
+			return nil, errors.WithMessage(err, "mongo: failed to decode list version")
+		}
+	} else if err = cursor.Err(); err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to get list version")
+	}
+	return version, nil
+}
+
 // limits
 func (db *DataStoreMongo) GetLimit(ctx context.Context, name string) (*model.Limit, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collLim := database.Collection(CollectionLimits)
 
 	limit := new(model.Limit)
@@ -773,11 +1003,25 @@ func (db *DataStoreMongo) GetLimit(ctx context.Context, name string) (*model.Lim
 	return limit, nil
 }
 
+// SetLimit creates or replaces the named limit.
+func (db *DataStoreMongo) SetLimit(ctx context.Context, limit *model.Limit) error {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collLim := database.Collection(CollectionLimits)
+
+	_, err := collLim.ReplaceOne(ctx,
+		bson.M{"_id": limit.Name},
+		limit,
+		mopts.Replace().SetUpsert(true),
+	)
+	return err
+}
+
 func (db *DataStoreMongo) ProvisionTenant(ctx context.Context, tenantId string) error {
 
-	dbname := mstore.DbNameForTenant(tenantId, DbName)
+	dbname := mstore.DbNameForTenant(tenantId, db.dbName)
 
-	return MigrateSingle(ctx, dbname, DbVersion, db.client, true)
+	return MigrateSingle(ctx, db.dbName, dbname, DbVersion, db.client, true,
+		db.migrationOptions)
 }
 
 //images
@@ -790,7 +1034,7 @@ func (db *DataStoreMongo) Exists(ctx context.Context, id string) (bool, error) {
 		return false, ErrImagesStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	if err := collImg.FindOne(ctx, bson.M{"_id": id}).
@@ -813,7 +1057,7 @@ func (db *DataStoreMongo) Update(ctx context.Context,
 		return false, err
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	// add special representation of artifact provides
@@ -831,6 +1075,176 @@ func (db *DataStoreMongo) Update(ctx context.Context,
 	return true, nil
 }
 
+// ReplaceImage replaces the image, provided it is still at the given
+// revision, together with its copies in the release and in the active
+// device deployments. Returns store.ErrNotFound if the image does not
+// exist at the revision.
+func (db *DataStoreMongo) ReplaceImage(
+	ctx context.Context,
+	image *model.Image,
+	revision int,
+) error {
+	if image == nil {
+		return ErrImagesStorageInvalidImage
+	}
+	if err := image.Validate(); err != nil {
+		return err
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	// add special representation of artifact provides
+	image.ArtifactMeta.ProvidesIdx = model.ProvidesIdx(image.ArtifactMeta.Provides)
+
+	query := bson.M{
+		StorageKeyId:            image.Id,
+		StorageKeyImageRevision: revision,
+	}
+	if revision == 0 {
+		query[StorageKeyImageRevision] = bson.M{"$exists": false}
+	}
+	res, err := collImg.ReplaceOne(ctx, query, image)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateImageReferences copies the image to its release and to the active
+// device deployments; the copies of a later revision are left untouched, so
+// that it can be retried after a partial failure.
+func (db *DataStoreMongo) UpdateImageReferences(
+	ctx context.Context,
+	image *model.Image,
+) error {
+	if image == nil || image.ArtifactMeta == nil {
+		return ErrImagesStorageInvalidImage
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	// matches the missing revision of the never replaced images too
+	earlierRevision := bson.M{"$not": bson.M{"$gte": image.Revision}}
+
+	collReleases := database.Collection(CollectionReleases)
+	_, err := collReleases.UpdateOne(ctx,
+		bson.M{
+			StorageKeyReleaseName: image.ArtifactMeta.Name,
+			StorageKeyReleaseArtifacts: bson.M{"$elemMatch": bson.M{
+				StorageKeyId:            image.Id,
+				StorageKeyImageRevision: earlierRevision,
+			}},
+		},
+		bson.M{"$set": bson.M{
+			StorageKeyReleaseArtifactsIndex: image,
+			StorageKeyReleaseModified:       time.Now(),
+		}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "updating the release artifact")
+	}
+
+	collDevs := database.Collection(CollectionDevices)
+	_, err = collDevs.UpdateMany(ctx,
+		bson.M{
+			StorageKeyDeviceDeploymentAssignedImageId:       image.Id,
+			StorageKeyDeviceDeploymentAssignedImageRevision: earlierRevision,
+			StorageKeyDeviceDeploymentActive:                true,
+		},
+		bson.M{"$set": bson.M{
+			StorageKeyDeviceDeploymentAssignedImage: image,
+		}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "updating the device deployments artifact")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) listImageTags(ctx context.Context) (model.Tags, error) {
+	tagKeys, err := db.client.
+		Database(mstore.DbFromContext(ctx, db.dbName)).
+		Collection(CollectionImages).
+		Distinct(ctx, StorageKeyImageTags, bson.D{})
+	if err != nil {
+		return nil, errors.WithMessage(err,
+			"mongo: failed to retrieve distinct artifact tags")
+	}
+	ret := make(model.Tags, 0, len(tagKeys))
+	for _, elem := range tagKeys {
+		if key, ok := elem.(string); ok {
+			ret = append(ret, model.Tag(key))
+		}
+	}
+	return ret, nil
+}
+
+// ReplaceImageTags sets the tags of the image and of its copy in the
+// release; the release tags are left untouched.
+func (db *DataStoreMongo) ReplaceImageTags(
+	ctx context.Context,
+	imageID string,
+	tags model.Tags,
+) error {
+	if len(tags) > model.TagsMaxUnique {
+		return model.ErrTooManyUniqueTags
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	if len(tags) > 0 {
+		inUseTags, err := db.listImageTags(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "mongo: failed to count in-use tags")
+		}
+		tagSet := make(map[model.Tag]struct{}, len(inUseTags))
+		for _, tagKey := range inUseTags {
+			tagSet[tagKey] = struct{}{}
+		}
+		for _, tag := range tags {
+			delete(tagSet, tag)
+		}
+		if len(tags)+len(tagSet) > model.TagsMaxUnique {
+			return model.ErrTooManyUniqueTags
+		}
+	}
+
+	now := time.Now()
+	var image model.Image
+	err := collImg.FindOneAndUpdate(ctx, bson.D{{
+		Key: StorageKeyId, Value: imageID,
+	}}, bson.D{{
+		Key: mongoOpSet,
+		Value: bson.D{
+			{Key: StorageKeyImageTags, Value: tags},
+			{Key: StorageKeyImageModified, Value: now},
+		},
+	}}).Decode(&image)
+	if err == mongo.ErrNoDocuments {
+		return store.ErrNotFound
+	} else if err != nil {
+		return errors.WithMessage(err, "mongo: failed to update artifact tags")
+	}
+
+	collReleases := database.Collection(CollectionReleases)
+	_, err = collReleases.UpdateOne(ctx,
+		bson.M{
+			StorageKeyReleaseName:        image.ArtifactMeta.Name,
+			StorageKeyReleaseArtifactsId: imageID,
+		},
+		bson.M{mongoOpSet: bson.M{
+			StorageKeyReleaseArtifactsIndexTags: tags,
+			StorageKeyReleaseModified:           now,
+		}},
+	)
+	if err != nil {
+		return errors.WithMessage(err, "mongo: failed to update the release artifact tags")
+	}
+	return nil
+}
+
 // ImageByNameAndDeviceType finds image with specified application name and target device type
 func (db *DataStoreMongo) ImageByNameAndDeviceType(ctx context.Context,
 	name, deviceType string) (*model.Image, error) {
@@ -853,7 +1267,7 @@ func (db *DataStoreMongo) ImageByNameAndDeviceType(ctx context.Context,
 	findOpts := mopts.FindOne()
 	findOpts.SetSort(bson.D{{Key: StorageKeyImageSize, Value: 1}})
 
-	dbName := mstore.DbFromContext(ctx, DatabaseName)
+	dbName := mstore.DbFromContext(ctx, db.dbName)
 	database := db.client.Database(dbName)
 	collImg := database.Collection(CollectionImages)
 
@@ -870,6 +1284,94 @@ func (db *DataStoreMongo) ImageByNameAndDeviceType(ctx context.Context,
 	return &image, nil
 }
 
+// ImageByNameAndProvides finds image with specified application name which
+// depends are satisfied by the provides of the device. If multiple entries
+// match, the one with the most specific depends is picked, followed by the
+// smallest one.
+func (db *DataStoreMongo) ImageByNameAndProvides(ctx context.Context,
+	name string, provides map[string]string) (*model.Image, error) {
+
+	if len(name) == 0 {
+		return nil, ErrImagesStorageInvalidArtifactName
+	}
+
+	deviceType := provides[model.ArtifactDependsDeviceType]
+	if len(deviceType) == 0 {
+		return nil, ErrImagesStorageInvalidDeviceType
+	}
+
+	providesArr := make(bson.A, 0, len(provides))
+	for key, value := range provides {
+		providesArr = append(providesArr, bson.D{
+			{Key: "k", Value: key},
+			{Key: "v", Value: value},
+		})
+	}
+	const fieldDependsCount = "depends_count"
+	depends := bson.D{{Key: "$objectToArray", Value: bson.D{
+		{Key: "$ifNull", Value: bson.A{"$" + StorageKeyImageDepends, bson.D{}}},
+	}}}
+	// each of the depends must be provided with the (or one of the)
+	// required values
+	dependsSatisfied := bson.D{{Key: "$allElementsTrue", Value: bson.A{
+		bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: depends},
+			{Key: "as", Value: "d"},
+			{Key: "in", Value: bson.D{{Key: "$gt", Value: bson.A{
+				bson.D{{Key: "$size", Value: bson.D{{Key: "$filter", Value: bson.D{
+					{Key: "input", Value: providesArr},
+					{Key: "as", Value: "p"},
+					{Key: "cond", Value: bson.D{{Key: "$and", Value: bson.A{
+						bson.D{{Key: "$eq", Value: bson.A{"$$p.k", "$$d.k"}}},
+						bson.D{{Key: "$in", Value: bson.A{"$$p.v", bson.D{
+							{Key: "$cond", Value: bson.A{
+								bson.D{{Key: "$isArray", Value: "$$d.v"}},
+								"$$d.v",
+								bson.A{"$$d.v"},
+							}},
+						}}}},
+					}}}},
+				}}}}},
+				0,
+			}}}},
+		}}},
+	}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: StorageKeyImageName, Value: name},
+			{Key: StorageKeyImageDeviceTypes, Value: deviceType},
+			{Key: "$expr", Value: dependsSatisfied},
+		}}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: fieldDependsCount, Value: bson.D{{Key: "$size", Value: depends}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: fieldDependsCount, Value: -1},
+			{Key: StorageKeyImageSize, Value: 1},
+		}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$project", Value: bson.D{{Key: fieldDependsCount, Value: 0}}}},
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	cursor, err := collImg.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		return nil, cursor.Err()
+	}
+	image := new(model.Image)
+	if err = cursor.Decode(image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
 // ImageByIdsAndDeviceType finds image with id from ids and target device type
 func (db *DataStoreMongo) ImageByIdsAndDeviceType(ctx context.Context,
 	ids []string, deviceType string) (*model.Image, error) {
@@ -887,7 +1389,7 @@ func (db *DataStoreMongo) ImageByIdsAndDeviceType(ctx context.Context,
 		{Key: StorageKeyImageDeviceTypes, Value: deviceType},
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	// If multiple entries matches, pick the smallest one
@@ -907,6 +1409,113 @@ func (db *DataStoreMongo) ImageByIdsAndDeviceType(ctx context.Context,
 	return &image, nil
 }
 
+// FindOrphanedImages finds the images whose artifact name has no release,
+// sorted by ID. A limit of 0 returns all of them.
+func (db *DataStoreMongo) FindOrphanedImages(
+	ctx context.Context,
+	skip, limit int,
+) ([]*model.Image, int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	results := []bson.D{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: int64(skip)}},
+	}
+	if limit > 0 {
+		results = append(results, bson.D{{Key: "$limit", Value: int64(limit)}})
+	}
+	pipe := []bson.D{
+		{{Key: "$project", Value: bson.M{
+			StorageKeyImageDependsIdx:  0,
+			StorageKeyImageProvidesIdx: 0,
+		}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: CollectionReleases},
+			{Key: "localField", Value: StorageKeyImageName},
+			{Key: "foreignField", Value: StorageKeyReleaseName},
+			{Key: "as", Value: "release"},
+		}}},
+		{{Key: "$match", Value: bson.M{"release": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"release": 0}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "results", Value: results},
+			{Key: "count", Value: []bson.D{
+				{{Key: "$count", Value: "count"}},
+			}},
+		}}},
+	}
+
+	cursor, err := collImg.Aggregate(ctx, pipe)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	result := struct {
+		Results []*model.Image        `bson:"results"`
+		Count   []struct{ Count int } `bson:"count"`
+	}{}
+	if !cursor.Next(ctx) {
+		return []*model.Image{}, 0, cursor.Err()
+	} else if err = cursor.Decode(&result); err != nil {
+		return nil, 0, err
+	} else if len(result.Count) == 0 {
+		return []*model.Image{}, 0, nil
+	}
+	return result.Results, result.Count[0].Count, nil
+}
+
+// FindImagesModifiedSince finds the images modified at or after since,
+// sorted by modification time, oldest first, then by ID. If afterID is set
+// the images modified at since up to afterID are skipped: (since, afterID)
+// is the position of the last image synchronized.
+func (db *DataStoreMongo) FindImagesModifiedSince(
+	ctx context.Context,
+	since time.Time,
+	afterID string,
+	skip, limit int,
+) ([]*model.Image, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	findOptions := mopts.Find().
+		SetProjection(bson.M{
+			StorageKeyImageDependsIdx:  0,
+			StorageKeyImageProvidesIdx: 0,
+		}).
+		SetSort(bson.D{
+			{Key: StorageKeyImageModified, Value: 1},
+			{Key: "_id", Value: 1},
+		}).
+		SetSkip(int64(skip))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+
+	filter := bson.M{
+		StorageKeyImageModified: bson.M{"$gte": since},
+	}
+	if afterID != "" {
+		filter = bson.M{"$or": bson.A{
+			bson.M{StorageKeyImageModified: bson.M{"$gt": since}},
+			bson.M{
+				StorageKeyImageModified: since,
+				"_id":                   bson.M{"$gt": afterID},
+			},
+		}}
+	}
+	cursor, err := collImg.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	images := []*model.Image{}
+	if err = cursor.All(ctx, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
 // ImagesByName finds images with specified artifact name
 func (db *DataStoreMongo) ImagesByName(
 	ctx context.Context, name string) ([]*model.Image, error) {
@@ -922,7 +1531,7 @@ func (db *DataStoreMongo) ImagesByName(
 		StorageKeyImageName: name,
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 	cursor, err := collImg.Find(ctx, query)
 	if err != nil {
@@ -967,7 +1576,7 @@ func (db *DataStoreMongo) InsertImage(ctx context.Context, image *model.Image) e
 		return err
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	// add special representation of artifact provides
@@ -992,7 +1601,7 @@ func (db *DataStoreMongo) InsertImage(ctx context.Context, image *model.Image) e
 
 func (db *DataStoreMongo) InsertUploadIntent(ctx context.Context, link *model.UploadLink) error {
 	collUploads := db.client.
-		Database(DatabaseName).
+		Database(db.dbName).
 		Collection(CollectionUploadIntents)
 	if idty := identity.FromContext(ctx); idty != nil {
 		link.TenantID = idty.Tenant
@@ -1007,7 +1616,7 @@ func (db *DataStoreMongo) UpdateUploadIntentStatus(
 	from, to model.LinkStatus,
 ) error {
 	collUploads := db.client.
-		Database(DatabaseName).
+		Database(db.dbName).
 		Collection(CollectionUploadIntents)
 	q := bson.D{
 		{Key: "_id", Value: id},
@@ -1038,14 +1647,87 @@ func (db *DataStoreMongo) UpdateUploadIntentStatus(
 	return nil
 }
 
-func (db *DataStoreMongo) FindUploadLinks(
+// GetUploadIntent returns the upload intent with the given ID, or
+// store.ErrNotFound if it does not exist.
+func (db *DataStoreMongo) GetUploadIntent(
 	ctx context.Context,
-	expiredAt time.Time,
-) (store.Iterator[model.UploadLink], error) {
+	id string,
+) (*model.UploadLink, error) {
 	collUploads := db.client.
-		Database(DatabaseName).
+		Database(db.dbName).
 		Collection(CollectionUploadIntents)
-
+	q := bson.D{{Key: "_id", Value: id}}
+	if idty := identity.FromContext(ctx); idty != nil {
+		q = append(q, bson.E{
+			Key:   StorageKeyTenantId,
+			Value: idty.Tenant,
+		})
+	}
+	var link model.UploadLink
+	err := collUploads.FindOne(ctx, q).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// SetUploadIntentPart records the part of a pending multipart upload,
+// replacing any part previously reported with the same number.
+func (db *DataStoreMongo) SetUploadIntentPart(
+	ctx context.Context,
+	id string,
+	part model.UploadPart,
+) error {
+	const keyParts = "multipart.parts"
+	collUploads := db.client.
+		Database(db.dbName).
+		Collection(CollectionUploadIntents)
+	q := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: model.LinkStatusPending},
+		{Key: "multipart", Value: bson.D{{Key: "$exists", Value: true}}},
+	}
+	if idty := identity.FromContext(ctx); idty != nil {
+		q = append(q, bson.E{
+			Key:   StorageKeyTenantId,
+			Value: idty.Tenant,
+		})
+	}
+	// The update pipeline drops the part with the same number, if any,
+	// and appends the new one in a single atomic operation.
+	update := bson.A{bson.D{{Key: "$set", Value: bson.D{
+		{Key: "updated_ts", Value: time.Now()},
+		{Key: keyParts, Value: bson.D{{Key: "$concatArrays", Value: bson.A{
+			bson.D{{Key: "$filter", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+					"$" + keyParts, bson.A{},
+				}}}},
+				{Key: "cond", Value: bson.D{{Key: "$ne", Value: bson.A{
+					"$$this.part_number", part.PartNumber,
+				}}}},
+			}}},
+			bson.A{bson.D{{Key: "$literal", Value: part}}},
+		}}}},
+	}}}}
+	res, err := collUploads.UpdateOne(ctx, q, update)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) FindUploadLinks(
+	ctx context.Context,
+	expiredAt time.Time,
+) (store.Iterator[model.UploadLink], error) {
+	collUploads := db.client.
+		Database(db.dbName).
+		Collection(CollectionUploadIntents)
+
 	q := bson.D{{
 		Key: "status",
 		Value: bson.D{{
@@ -1071,7 +1753,7 @@ func (db *DataStoreMongo) FindImageByID(ctx context.Context,
 		return nil, ErrImagesStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 	projection := bson.M{
 		StorageKeyImageDependsIdx:  0,
@@ -1104,7 +1786,7 @@ func (db *DataStoreMongo) IsArtifactUnique(ctx context.Context,
 		return false, ErrImagesStorageInvalidArtifactName
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	query := bson.M{
@@ -1156,7 +1838,7 @@ func (db *DataStoreMongo) DeleteImage(ctx context.Context, id string) error {
 		return ErrImagesStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
 	if res, err := collImg.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
@@ -1194,37 +1876,10 @@ func (db *DataStoreMongo) ListImages(
 	filt *model.ReleaseOrImageFilter,
 ) ([]*model.Image, int, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collImg := database.Collection(CollectionImages)
 
-	filters := bson.M{}
-	if filt != nil {
-		if filt.Name != "" {
-			filters[StorageKeyImageName] = bson.M{
-				"$regex": primitive.Regex{
-					Pattern: ".*" + regexp.QuoteMeta(filt.Name) + ".*",
-					Options: "i",
-				},
-			}
-		}
-		if filt.Description != "" {
-			filters[StorageKeyImageDescription] = bson.M{
-				"$regex": primitive.Regex{
-					Pattern: ".*" + regexp.QuoteMeta(filt.Description) + ".*",
-					Options: "i",
-				},
-			}
-		}
-		if filt.DeviceType != "" {
-			filters[StorageKeyImageDeviceTypes] = bson.M{
-				"$regex": primitive.Regex{
-					Pattern: ".*" + regexp.QuoteMeta(filt.DeviceType) + ".*",
-					Options: "i",
-				},
-			}
-		}
-
-	}
+	filters := imagesFilter(filt)
 
 	projection := bson.M{
 		StorageKeyImageDependsIdx:  0,
@@ -1271,8 +1926,52 @@ func (db *DataStoreMongo) ListImages(
 	return images, int(count), nil
 }
 
+func imagesFilter(filt *model.ReleaseOrImageFilter) bson.M {
+	filters := bson.M{}
+	if filt == nil {
+		return filters
+	}
+	if filt.Name != "" {
+		filters[StorageKeyImageName] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + regexp.QuoteMeta(filt.Name) + ".*",
+				Options: "i",
+			},
+		}
+	}
+	if filt.Description != "" {
+		filters[StorageKeyImageDescription] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + regexp.QuoteMeta(filt.Description) + ".*",
+				Options: "i",
+			},
+		}
+	}
+	if filt.DeviceType != "" {
+		filters[StorageKeyImageDeviceTypes] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + regexp.QuoteMeta(filt.DeviceType) + ".*",
+				Options: "i",
+			},
+		}
+	}
+	if len(filt.Provides) > 0 {
+		provides := make(bson.A, 0, len(filt.Provides))
+		for key, value := range filt.Provides {
+			provides = append(provides, bson.M{
+				"$elemMatch": bson.M{"key": key, "value": value},
+			})
+		}
+		filters[StorageKeyImageProvidesIdx] = bson.M{"$all": provides}
+	}
+	if len(filt.Tags) > 0 {
+		filters[StorageKeyImageTags] = bson.M{"$in": filt.Tags}
+	}
+	return filters
+}
+
 func (db *DataStoreMongo) DeleteImagesByNames(ctx context.Context, names []string) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionImages)
 	query := bson.M{
 		StorageKeyImageName: bson.M{
@@ -1283,7 +1982,61 @@ func (db *DataStoreMongo) DeleteImagesByNames(ctx context.Context, names []strin
 	return err
 }
 
+// DeleteImagesByRelease deletes all the artifacts of the release and
+// returns them, without their indexed provides and depends.
+func (db *DataStoreMongo) DeleteImagesByRelease(
+	ctx context.Context,
+	name string,
+) ([]*model.Image, error) {
+	if len(name) == 0 {
+		return nil, ErrImagesStorageInvalidArtifactName
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImg := database.Collection(CollectionImages)
+
+	findOptions := mopts.Find().SetProjection(bson.M{
+		StorageKeyImageDependsIdx:  0,
+		StorageKeyImageProvidesIdx: 0,
+	})
+	cursor, err := collImg.Find(ctx, bson.M{StorageKeyImageName: name}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var images []*model.Image
+	if err = cursor.All(ctx, &images); err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return images, nil
+	}
+
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = image.Id
+	}
+	if _, err = collImg.DeleteMany(ctx, bson.M{
+		StorageKeyId: bson.M{"$in": ids},
+	}); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
 // device deployment log
+
+// logAttemptFilter matches the logs of the given attempt; logs of the first
+// attempt are stored without the attempt field.
+func logAttemptFilter(attempt uint) interface{} {
+	if attempt == 0 {
+		return bson.D{{Key: "$in", Value: bson.A{nil, 0}}}
+	}
+	return attempt
+}
+
+// SaveDeviceDeploymentLog stores the log of a device deployment attempt. If
+// a log for the same attempt is already present, its messages are
+// overwritten; logs of other attempts are left untouched.
 func (db *DataStoreMongo) SaveDeviceDeploymentLog(ctx context.Context,
 	log model.DeploymentLog) error {
 
@@ -1291,7 +2044,7 @@ func (db *DataStoreMongo) SaveDeviceDeploymentLog(ctx context.Context,
 		return err
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collLogs := database.Collection(CollectionDeviceDeploymentLogs)
 
 	query := bson.D{
@@ -1299,10 +2052,11 @@ func (db *DataStoreMongo) SaveDeviceDeploymentLog(ctx context.Context,
 			Value: log.DeviceID},
 		{Key: StorageKeyDeviceDeploymentDeploymentID,
 			Value: log.DeploymentID},
+		{Key: StorageKeyDeviceDeploymentLogAttempt,
+			Value: logAttemptFilter(log.Attempt)},
 	}
 
-	// update log messages
-	// if the deployment log is already present than messages will be overwritten
+	// the attempt is copied from the query on insert
 	update := bson.D{
 		{Key: "$set", Value: bson.M{
 			StorageKeyDeviceDeploymentLogMessages: log.Messages,
@@ -1318,26 +2072,121 @@ func (db *DataStoreMongo) SaveDeviceDeploymentLog(ctx context.Context,
 	return nil
 }
 
+// GetDeviceDeploymentLog returns the logs of the device deployment ordered by
+// attempt, with the messages filtered and paged according to the query.
 func (db *DataStoreMongo) GetDeviceDeploymentLog(ctx context.Context,
-	deviceID, deploymentID string) (*model.DeploymentLog, error) {
+	q store.ListQueryDeviceDeploymentLog) ([]model.DeploymentLog, int, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	if err := q.Validate(); err != nil {
+		return nil, 0, err
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collLogs := database.Collection(CollectionDeviceDeploymentLogs)
 
 	query := bson.M{
-		StorageKeyDeviceDeploymentDeviceId:     deviceID,
-		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		StorageKeyDeviceDeploymentDeviceId:     q.DeviceID,
+		StorageKeyDeviceDeploymentDeploymentID: q.DeploymentID,
 	}
+	if q.Attempt != nil {
+		query[StorageKeyDeviceDeploymentLogAttempt] = logAttemptFilter(*q.Attempt)
+	}
+	opts := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeviceDeploymentLogAttempt, Value: 1}})
 
-	var depl model.DeploymentLog
-	if err := collLogs.FindOne(ctx, query).Decode(&depl); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, nil
-		}
-		return nil, err
+	cursor, err := collLogs.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var logs []model.DeploymentLog
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, 0, err
+	}
+	if len(logs) == 0 {
+		return nil, 0, store.ErrNotFound
+	}
+
+	logs, total := q.Page(logs)
+	return logs, total, nil
+}
+
+// purgeLogsBatchSize is the number of device deployments whose logs are
+// removed with a single query.
+const purgeLogsBatchSize = 100
+
+// PurgeDeviceDeploymentLogs removes the logs of the device deployments which
+// finished before finishedBefore, marks the logs of those device deployments
+// as no longer available, and returns the number of removed logs.
+func (db *DataStoreMongo) PurgeDeviceDeploymentLogs(
+	ctx context.Context,
+	finishedBefore time.Time,
+) (int64, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+	collLogs := database.Collection(CollectionDeviceDeploymentLogs)
+
+	// served by the partial index on finished device deployments with logs
+	filter := bson.D{
+		{Key: StorageKeyDeviceDeploymentFinished, Value: bson.D{
+			{Key: "$lt", Value: finishedBefore},
+		}},
+		{Key: StorageKeyDeviceDeploymentIsLogAvailable, Value: true},
+	}
+	opts := mopts.Find().
+		SetProjection(bson.D{
+			{Key: StorageKeyId, Value: 1},
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: 1},
+			{Key: StorageKeyDeviceDeploymentDeploymentID, Value: 1},
+		}).
+		SetBatchSize(purgeLogsBatchSize)
+	cursor, err := collDevs.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
 	}
+	defer cursor.Close(ctx)
 
-	return &depl, nil
+	var (
+		purged int64
+		ids    = make(bson.A, 0, purgeLogsBatchSize)
+		logs   = make(bson.A, 0, purgeLogsBatchSize)
+	)
+	purge := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		res, err := collLogs.DeleteMany(ctx, bson.D{{Key: "$or", Value: logs}})
+		if err != nil {
+			return err
+		}
+		purged += res.DeletedCount
+		_, err = collDevs.UpdateMany(ctx,
+			bson.D{{Key: StorageKeyId, Value: bson.D{{Key: "$in", Value: ids}}}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: StorageKeyDeviceDeploymentIsLogAvailable, Value: false},
+			}}},
+		)
+		ids, logs = ids[:0], logs[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var dd model.DeviceDeployment
+		if err := cursor.Decode(&dd); err != nil {
+			return purged, err
+		}
+		ids = append(ids, dd.Id)
+		logs = append(logs, bson.D{
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: dd.DeviceId},
+			{Key: StorageKeyDeviceDeploymentDeploymentID, Value: dd.DeploymentId},
+		})
+		if len(ids) == purgeLogsBatchSize {
+			if err := purge(); err != nil {
+				return purged, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return purged, err
+	}
+	return purged, purge()
 }
 
 // device deployments
@@ -1348,7 +2197,7 @@ func (db *DataStoreMongo) InsertDeviceDeployment(
 	deviceDeployment *model.DeviceDeployment,
 	incrementDeviceCount bool,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	c := database.Collection(CollectionDevices)
 
 	if deviceDeployment.Status != model.DeviceDeploymentStatusPending {
@@ -1401,7 +2250,7 @@ func (db *DataStoreMongo) InsertMany(ctx context.Context,
 		deviceCountIncrements[deployment.DeploymentId]++
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	if _, err := collDevs.InsertMany(ctx, list); err != nil {
@@ -1433,7 +2282,7 @@ func (db *DataStoreMongo) FindOldestActiveDeviceDeployment(
 		return nil, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	// Device should know only about deployments that are not finished
@@ -1463,6 +2312,138 @@ func (db *DataStoreMongo) FindOldestActiveDeviceDeployment(
 	return deployment, nil
 }
 
+// FindAndClaimOldestActiveDeviceDeployment atomically finds the oldest
+// deployment of the device that has not finished yet and, if still pending,
+// moves it to downloading. It returns the device deployment as updated
+// together with its status before the update, so that only the caller which
+// actually claimed it updates the deployment stats; nil if none is active.
+func (db *DataStoreMongo) FindAndClaimOldestActiveDeviceDeployment(
+	ctx context.Context,
+	deviceID string,
+) (*model.DeviceDeployment, model.DeviceDeploymentStatus, error) {
+
+	if len(deviceID) == 0 {
+		return nil, model.DeviceDeploymentStatusNull, ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentActive, Value: true},
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+	// the fields are set conditionally, within a single update pipeline,
+	// so that a device deployment already in progress is left untouched
+	isPending := bson.D{{Key: "$eq", Value: bson.A{
+		"$" + StorageKeyDeviceDeploymentStatus,
+		model.DeviceDeploymentStatusPending,
+	}}}
+	claim := func(key string, value interface{}) bson.E {
+		return bson.E{Key: key, Value: bson.D{{Key: "$cond", Value: bson.A{
+			isPending, value, "$" + key,
+		}}}}
+	}
+	now := time.Now().UTC()
+	set := bson.D{
+		claim(StorageKeyDeviceDeploymentStatus, model.DeviceDeploymentStatusDownloading),
+		claim(StorageKeyDeviceDeploymentStarted, now),
+		claim(StorageKeyDeviceDeploymentLastUpdated, now),
+	}
+	entry := model.SubStateHistoryEntry{
+		Status:    model.DeviceDeploymentStatusDownloading,
+		Timestamp: now,
+	}
+	if db.subStateHistoryLength > 0 {
+		// $push is not available in update pipelines
+		set = append(set, claim(StorageKeyDeviceDeploymentSubStateHistory,
+			bson.D{{Key: "$slice", Value: bson.A{
+				bson.D{{Key: "$concatArrays", Value: bson.A{
+					bson.D{{Key: "$ifNull", Value: bson.A{
+						"$" + StorageKeyDeviceDeploymentSubStateHistory, bson.A{},
+					}}},
+					bson.A{bson.D{{Key: "$literal", Value: entry}}},
+				}}},
+				-db.subStateHistoryLength,
+			}}},
+		))
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: set}}}
+	findOptions := mopts.FindOneAndUpdate().
+		SetSort(bson.D{{Key: StorageKeyDeviceDeploymentCreated, Value: 1}}).
+		SetReturnDocument(mopts.Before)
+
+	deviceDeployment := new(model.DeviceDeployment)
+	if err := collDevs.FindOneAndUpdate(ctx, query, update, findOptions).
+		Decode(deviceDeployment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.DeviceDeploymentStatusNull, nil
+		}
+		return nil, model.DeviceDeploymentStatusNull, err
+	}
+
+	old := deviceDeployment.Status
+	if old == model.DeviceDeploymentStatusPending {
+		deviceDeployment.Status = model.DeviceDeploymentStatusDownloading
+		deviceDeployment.Started = &now
+		deviceDeployment.LastUpdated = &now
+		if db.subStateHistoryLength > 0 {
+			history := append(deviceDeployment.SubStateHistory, entry)
+			if len(history) > db.subStateHistoryLength {
+				history = history[len(history)-db.subStateHistoryLength:]
+			}
+			deviceDeployment.SubStateHistory = history
+		}
+	}
+	return deviceDeployment, old, nil
+}
+
+// FindOldestActiveDeviceDeploymentID returns the ID of the deployment of the
+// oldest active device deployment of the device, or an empty string if
+// there is none; only the deployment ID is fetched from the database.
+func (db *DataStoreMongo) FindOldestActiveDeviceDeploymentID(
+	ctx context.Context,
+	deviceID string,
+) (string, error) {
+
+	if len(deviceID) == 0 {
+		return "", ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentActive, Value: true},
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+	findOptions := mopts.FindOne().
+		SetSort(bson.D{{Key: "created", Value: 1}}).
+		SetProjection(bson.D{
+			{Key: "_id", Value: 0},
+			{Key: StorageKeyDeviceDeploymentDeploymentID, Value: 1},
+		})
+
+	var res struct {
+		DeploymentID string `bson:"deploymentid"`
+	}
+	if err := collDevs.FindOne(ctx, query, findOptions).
+		Decode(&res); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return res.DeploymentID, nil
+}
+
 // FindLatestInactiveDeviceDeployment finds the latest device deployment
 // matching device id that has not finished yet.
 func (db *DataStoreMongo) FindLatestInactiveDeviceDeployment(
@@ -1475,7 +2456,7 @@ func (db *DataStoreMongo) FindLatestInactiveDeviceDeployment(
 		return nil, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	query := bson.D{
@@ -1522,7 +2503,7 @@ func (db *DataStoreMongo) UpdateDeviceDeploymentStatus(
 		return model.DeviceDeploymentStatusNull, ErrStorageInvalidInput
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	// Device should know only about deployments that are not finished
@@ -1535,9 +2516,11 @@ func (db *DataStoreMongo) UpdateDeviceDeploymentStatus(
 	}
 
 	// update status field
+	now := time.Now().UTC()
 	set := bson.M{
-		StorageKeyDeviceDeploymentStatus: ddState.Status,
-		StorageKeyDeviceDeploymentActive: ddState.Status.Active(),
+		StorageKeyDeviceDeploymentStatus:      ddState.Status,
+		StorageKeyDeviceDeploymentActive:      ddState.Status.Active(),
+		StorageKeyDeviceDeploymentLastUpdated: now,
 	}
 	// and finish time if provided
 	if ddState.FinishTime != nil {
@@ -1557,6 +2540,22 @@ func (db *DataStoreMongo) UpdateDeviceDeploymentStatus(
 	update := bson.D{
 		{Key: "$set", Value: set},
 	}
+	if push := db.subStateHistoryPush(model.SubStateHistoryEntry{
+		Status:    ddState.Status,
+		SubState:  ddState.SubState,
+		Timestamp: now,
+	}); push != nil {
+		update = append(update, bson.E{Key: "$push", Value: push})
+	}
+	// the deadline only applies while awaiting confirmation
+	if ddState.Status == model.DeviceDeploymentStatusAwaitingConfirmation &&
+		ddState.ConfirmationDeadline != nil {
+		set[StorageKeyDeviceDeploymentConfirmationDeadline] = ddState.ConfirmationDeadline
+	} else {
+		update = append(update, bson.E{Key: "$unset", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentConfirmationDeadline, Value: ""},
+		}})
+	}
 
 	var old model.DeviceDeployment
 
@@ -1572,64 +2571,193 @@ func (db *DataStoreMongo) UpdateDeviceDeploymentStatus(
 	return old.Status, nil
 }
 
-func (db *DataStoreMongo) UpdateDeviceDeploymentLogAvailability(ctx context.Context,
-	deviceID string, deploymentID string, log bool) error {
-
-	// Verify ID formatting
-	if len(deviceID) == 0 ||
-		len(deploymentID) == 0 {
+// TouchDeviceDeployment records a status report repeating the current
+// status of the device deployment: only the last update time changes.
+// Noop if the status changed meanwhile.
+func (db *DataStoreMongo) TouchDeviceDeployment(
+	ctx context.Context,
+	deviceID string,
+	deploymentID string,
+	status model.DeviceDeploymentStatus,
+) error {
+	if len(deviceID) == 0 || len(deploymentID) == 0 {
 		return ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
-	selector := bson.D{
+	query := bson.D{
 		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
 		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: deploymentID},
+		{Key: StorageKeyDeviceDeploymentStatus, Value: status},
 		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
 			{Key: "$exists", Value: false},
 		}},
 	}
-
-	update := bson.D{
-		{Key: "$set", Value: bson.M{
-			StorageKeyDeviceDeploymentIsLogAvailable: log}},
-	}
-
-	if res, err := collDevs.UpdateOne(ctx, selector, update); err != nil {
-		return err
-	} else if res.MatchedCount == 0 {
-		return ErrStorageNotFound
-	}
-
-	return nil
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: StorageKeyDeviceDeploymentLastUpdated, Value: time.Now().UTC()},
+	}}}
+	_, err := collDevs.UpdateOne(ctx, query, update)
+	return err
 }
 
-// SaveDeviceDeploymentRequest saves device deployment request
-// with the device deployment object
-func (db *DataStoreMongo) SaveDeviceDeploymentRequest(
+// FindDeviceDeploymentsAwaitingConfirmation returns up to limit device
+// deployments awaiting confirmation past their confirmation deadline.
+func (db *DataStoreMongo) FindDeviceDeploymentsAwaitingConfirmation(
 	ctx context.Context,
-	ID string,
-	request *model.DeploymentNextRequest,
-) error {
-
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	deadlineBefore time.Time,
+	limit int,
+) ([]model.DeviceDeployment, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
-	res, err := collDevs.UpdateOne(
-		ctx,
-		bson.D{{Key: StorageKeyId, Value: ID}},
-		bson.D{{Key: "$set", Value: bson.M{StorageKeyDeviceDeploymentRequest: request}}},
-	)
+	// served by the partial index on the confirmation deadline
+	filter := bson.D{
+		{Key: StorageKeyDeviceDeploymentStatus,
+			Value: model.DeviceDeploymentStatusAwaitingConfirmation},
+		{Key: StorageKeyDeviceDeploymentConfirmationDeadline, Value: bson.D{
+			{Key: "$lt", Value: deadlineBefore},
+		}},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+	opts := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeviceDeploymentConfirmationDeadline, Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := collDevs.Find(ctx, filter, opts)
 	if err != nil {
-		return err
-	} else if res.MatchedCount == 0 {
-		return ErrStorageNotFound
+		return nil, err
 	}
-	return nil
-}
-
+	deviceDeployments := []model.DeviceDeployment{}
+	if err := cursor.All(ctx, &deviceDeployments); err != nil {
+		return nil, err
+	}
+	return deviceDeployments, nil
+}
+
+// RetryDeviceDeployment reschedules a failed device deployment by resetting
+// it to pending and incrementing the attempt counter, as long as the counter
+// has not reached maxRetries. It returns false if the device deployment was
+// not rescheduled.
+func (db *DataStoreMongo) RetryDeviceDeployment(
+	ctx context.Context,
+	deviceID string,
+	deploymentID string,
+	maxRetries uint,
+) (bool, error) {
+
+	// Verify ID formatting
+	if len(deviceID) == 0 ||
+		len(deploymentID) == 0 {
+		return false, ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	// the attempts condition makes the update safe against concurrent
+	// reports, and also matches documents without the counter
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: deploymentID},
+		{Key: StorageKeyDeviceDeploymentStatus,
+			Value: model.DeviceDeploymentStatusFailure},
+		{Key: StorageKeyDeviceDeploymentAttempts, Value: bson.D{
+			{Key: "$not", Value: bson.D{{Key: "$gte", Value: maxRetries}}},
+		}},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentStatus,
+				Value: model.DeviceDeploymentStatusPending},
+			{Key: StorageKeyDeviceDeploymentActive, Value: true},
+		}},
+		{Key: "$unset", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentFinished, Value: ""},
+			{Key: StorageKeyDeviceDeploymentSubState, Value: ""},
+		}},
+		{Key: "$inc", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentAttempts, Value: 1},
+		}},
+	}
+	if push := db.subStateHistoryPush(model.SubStateHistoryEntry{
+		Status:    model.DeviceDeploymentStatusPending,
+		Timestamp: time.Now().UTC(),
+	}); push != nil {
+		update = append(update, bson.E{Key: "$push", Value: push})
+	}
+
+	res, err := collDevs.UpdateOne(ctx, query, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+func (db *DataStoreMongo) UpdateDeviceDeploymentLogAvailability(ctx context.Context,
+	deviceID string, deploymentID string, log bool) error {
+
+	// Verify ID formatting
+	if len(deviceID) == 0 ||
+		len(deploymentID) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	selector := bson.D{
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: deploymentID},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.M{
+			StorageKeyDeviceDeploymentIsLogAvailable: log}},
+	}
+
+	if res, err := collDevs.UpdateOne(ctx, selector, update); err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return ErrStorageNotFound
+	}
+
+	return nil
+}
+
+// SaveDeviceDeploymentRequest saves device deployment request
+// with the device deployment object
+func (db *DataStoreMongo) SaveDeviceDeploymentRequest(
+	ctx context.Context,
+	ID string,
+	request *model.DeploymentNextRequest,
+) error {
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	res, err := collDevs.UpdateOne(
+		ctx,
+		bson.D{{Key: StorageKeyId, Value: ID}},
+		bson.D{{Key: "$set", Value: bson.M{StorageKeyDeviceDeploymentRequest: request}}},
+	)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return ErrStorageNotFound
+	}
+	return nil
+}
+
 // AssignArtifact assigns artifact to the device deployment
 func (db *DataStoreMongo) AssignArtifact(
 	ctx context.Context,
@@ -1644,7 +2772,7 @@ func (db *DataStoreMongo) AssignArtifact(
 		return ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	selector := bson.D{
@@ -1677,7 +2805,7 @@ func (db *DataStoreMongo) AggregateDeviceDeploymentByStatus(ctx context.Context,
 		return nil, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	match := bson.D{
@@ -1722,43 +2850,97 @@ func (db *DataStoreMongo) AggregateDeviceDeploymentByStatus(ctx context.Context,
 	return raw, nil
 }
 
-// GetDeviceStatusesForDeployment retrieve device deployment statuses for a given deployment.
-func (db *DataStoreMongo) GetDeviceStatusesForDeployment(ctx context.Context,
-	deploymentID string) ([]model.DeviceDeployment, error) {
+// FindDeviceDeploymentCountsByDeploymentIDs counts the device deployments of
+// each of the given deployments by status using a single aggregation; every
+// requested deployment is present in the result, with zeroed Stats if it has
+// no device deployments.
+func (db *DataStoreMongo) FindDeviceDeploymentCountsByDeploymentIDs(
+	ctx context.Context,
+	ids []string,
+) (map[string]model.Stats, error) {
+	stats := make(map[string]model.Stats, len(ids))
+	for _, id := range ids {
+		stats[id] = model.NewDeviceDeploymentStats()
+	}
+	if len(ids) == 0 {
+		return stats, nil
+	}
 
-	statuses := []model.DeviceDeployment{}
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
-	query := bson.M{
-		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
-		StorageKeyDeviceDeploymentDeleted: bson.D{
-			{Key: "$exists", Value: false},
-		},
+	pipeline := []bson.D{
+		{{Key: "$match", Value: bson.M{
+			StorageKeyDeviceDeploymentDeploymentID: bson.M{"$in": ids},
+			StorageKeyDeviceDeploymentDeleted: bson.D{
+				{Key: "$exists", Value: false},
+			},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "deployment",
+					Value: "$" + StorageKeyDeviceDeploymentDeploymentID},
+				{Key: "status",
+					Value: "$" + StorageKeyDeviceDeploymentStatus},
+			}},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
 	}
-
-	cursor, err := collDevs.Find(ctx, query)
+	cursor, err := collDevs.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
-
-	if err = cursor.All(ctx, &statuses); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, nil
-		}
+	var results []struct {
+		ID struct {
+			Deployment string                       `bson:"deployment"`
+			Status     model.DeviceDeploymentStatus `bson:"status"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
-	return statuses, nil
+	for _, res := range results {
+		stats[res.ID.Deployment].Set(res.ID.Status, res.Count)
+	}
+	return stats, nil
 }
 
-func (db *DataStoreMongo) GetDevicesListForDeployment(ctx context.Context,
-	q store.ListQuery) ([]model.DeviceDeployment, int, error) {
-
-	statuses := []model.DeviceDeployment{}
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+// IterateDeviceStatusesForDeployment returns a cursor over the device
+// deployment statuses of the deployment, projected to the fields of the
+// CSV report.
+func (db *DataStoreMongo) IterateDeviceStatusesForDeployment(ctx context.Context,
+	deploymentID string) (store.Iterator[model.DeviceDeployment], error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		StorageKeyDeviceDeploymentDeleted: bson.D{
+			{Key: "$exists", Value: false},
+		},
+	}
+	artifactName := StorageKeyDeviceDeploymentAssignedImage + "." + StorageKeyImageName
+	projection := bson.M{
+		StorageKeyDeviceDeploymentDeviceId: 1,
+		StorageKeyDeviceDeploymentStatus:   1,
+		StorageKeyDeviceDeploymentSubState: 1,
+		StorageKeyDeviceDeploymentFinished: 1,
+		artifactName:                       1,
+	}
+	cur, err := collDevs.Find(ctx, query, mopts.Find().SetProjection(projection))
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to find device deployments")
+	}
+	return IteratorFromCursor[model.DeviceDeployment](cur), nil
+}
+
+// devicesListForDeploymentQuery builds the filter and the find options of
+// the list query; the limit is left to the caller.
+func devicesListForDeploymentQuery(
+	q store.ListQuery,
+) (bson.D, *mopts.FindOptions, error) {
 	query := bson.D{
 		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: q.DeploymentID},
 		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
@@ -1804,7 +2986,7 @@ func (db *DataStoreMongo) GetDevicesListForDeployment(ctx context.Context,
 			var status model.DeviceDeploymentStatus
 			err := status.UnmarshalText([]byte(*q.Status))
 			if err != nil {
-				return nil, -1, errors.Wrap(err, "invalid status query")
+				return nil, nil, errors.Wrap(err, "invalid status query")
 			}
 			query = append(query, bson.E{
 				Key: "status", Value: status,
@@ -1813,14 +2995,40 @@ func (db *DataStoreMongo) GetDevicesListForDeployment(ctx context.Context,
 	}
 
 	options := mopts.Find()
-	sortFieldQuery := bson.D{
-		{Key: StorageKeyDeviceDeploymentStatus, Value: 1},
-		{Key: StorageKeyDeviceDeploymentDeviceId, Value: 1},
+	switch q.Sort {
+	case store.ListQuerySortCreatedAsc, store.ListQuerySortCreatedDesc:
+		// matches IndexNameDeviceDeploymentDeploymentIDCreated
+		order := 1
+		if q.Sort == store.ListQuerySortCreatedDesc {
+			order = -1
+		}
+		options.SetSort(bson.D{
+			{Key: StorageKeyDeviceDeploymentCreated, Value: order},
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: order},
+		})
+	default:
+		options.SetSort(bson.D{
+			{Key: StorageKeyDeviceDeploymentStatus, Value: 1},
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: 1},
+		})
 	}
-	options.SetSort(sortFieldQuery)
 	if q.Skip > 0 {
 		options.SetSkip(int64(q.Skip))
 	}
+	return query, options, nil
+}
+
+func (db *DataStoreMongo) GetDevicesListForDeployment(ctx context.Context,
+	q store.ListQuery) ([]model.DeviceDeployment, int, error) {
+
+	statuses := []model.DeviceDeployment{}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query, options, err := devicesListForDeploymentQuery(q)
+	if err != nil {
+		return nil, -1, err
+	}
 	if q.Limit > 0 {
 		options.SetLimit(int64(q.Limit))
 	} else {
@@ -1847,82 +3055,58 @@ func (db *DataStoreMongo) GetDevicesListForDeployment(ctx context.Context,
 	return statuses, int(count), nil
 }
 
+// IterateDevicesListForDeployment returns a cursor over the device
+// deployments of the list query; a zero limit returns all of them.
+func (db *DataStoreMongo) IterateDevicesListForDeployment(ctx context.Context,
+	q store.ListQuery) (store.Iterator[model.DeviceDeployment], error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query, options, err := devicesListForDeploymentQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 {
+		options.SetLimit(int64(q.Limit))
+	}
+	// sorting a whole deployment may not fit the in-memory sort limit
+	options.SetAllowDiskUse(true)
+
+	cur, err := collDevs.Find(ctx, query, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to find device deployments")
+	}
+	return IteratorFromCursor[model.DeviceDeployment](cur), nil
+}
+
+// deviceDeploymentsSort lists the device deployments of a device from the
+// most recent; together with the device ID it matches
+// DeviceIDCreatedStatusIndex, walked backwards.
+var deviceDeploymentsSort = bson.D{
+	{Key: StorageKeyDeviceDeploymentCreated, Value: -1},
+	{Key: StorageKeyDeviceDeploymentStatus, Value: -1},
+}
+
 func (db *DataStoreMongo) GetDeviceDeploymentsForDevice(ctx context.Context,
 	q store.ListQueryDeviceDeployments) ([]model.DeviceDeployment, int, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
 
 	statuses := []model.DeviceDeployment{}
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
-	query := bson.D{}
-	if q.DeviceID != "" {
-		query = append(query, bson.E{
-			Key:   StorageKeyDeviceDeploymentDeviceId,
-			Value: q.DeviceID,
-		})
-	} else if len(q.IDs) > 0 {
-		query = append(query, bson.E{
-			Key: StorageKeyId,
-			Value: bson.D{{
-				Key:   "$in",
-				Value: q.IDs,
-			}},
-		})
+	query, err := deviceDeploymentsQuery(q)
+	if err != nil {
+		return nil, -1, err
 	}
-
-	if q.Status != nil {
-		if *q.Status == model.DeviceDeploymentStatusPauseStr {
-			query = append(query, bson.E{
-				Key: "status", Value: bson.D{{
-					Key:   "$gte",
-					Value: model.DeviceDeploymentStatusPauseBeforeInstall,
-				}, {
-					Key:   "$lte",
-					Value: model.DeviceDeploymentStatusPauseBeforeReboot,
-				}},
-			})
-		} else if *q.Status == model.DeviceDeploymentStatusActiveStr {
-			query = append(query, bson.E{
-				Key: "status", Value: bson.D{{
-					Key:   "$gte",
-					Value: model.DeviceDeploymentStatusPauseBeforeInstall,
-				}, {
-					Key:   "$lte",
-					Value: model.DeviceDeploymentStatusPending,
-				}},
-			})
-		} else if *q.Status == model.DeviceDeploymentStatusFinishedStr {
-			query = append(query, bson.E{
-				Key: "status", Value: bson.D{{
-					Key: "$in",
-					Value: []model.DeviceDeploymentStatus{
-						model.DeviceDeploymentStatusFailure,
-						model.DeviceDeploymentStatusAborted,
-						model.DeviceDeploymentStatusSuccess,
-						model.DeviceDeploymentStatusNoArtifact,
-						model.DeviceDeploymentStatusAlreadyInst,
-						model.DeviceDeploymentStatusDecommissioned,
-					},
-				}},
-			})
-		} else {
-			var status model.DeviceDeploymentStatus
-			err := status.UnmarshalText([]byte(*q.Status))
-			if err != nil {
-				return nil, -1, errors.Wrap(err, "invalid status query")
-			}
-			query = append(query, bson.E{
-				Key: "status", Value: status,
-			})
-		}
+	maxCount := maxCountDocuments
+	if len(q.IDs) == 0 && q.DeviceID == "" && q.DeviceIDPrefix != "" {
+		maxCount = store.DeviceIDPrefixMaxResults
 	}
 
 	options := mopts.Find()
-	sortFieldQuery := bson.D{
-		{Key: StorageKeyDeviceDeploymentCreated, Value: -1},
-		{Key: StorageKeyDeviceDeploymentStatus, Value: -1},
-	}
-	options.SetSort(sortFieldQuery)
+	options.SetSort(deviceDeploymentsSort)
 	if q.Skip > 0 {
 		options.SetSkip(int64(q.Skip))
 	}
@@ -1944,7 +3128,6 @@ func (db *DataStoreMongo) GetDeviceDeploymentsForDevice(ctx context.Context,
 		return nil, -1, err
 	}
 
-	maxCount := maxCountDocuments
 	countOptions := &mopts.CountOptions{
 		Limit: &maxCount,
 	}
@@ -1956,25 +3139,199 @@ func (db *DataStoreMongo) GetDeviceDeploymentsForDevice(ctx context.Context,
 	return statuses, int(count), nil
 }
 
-// Returns true if deployment of ID `deploymentID` is assigned to device with ID
-// `deviceID`, false otherwise. In case of errors returns false and an error
-// that occurred
-func (db *DataStoreMongo) HasDeploymentForDevice(ctx context.Context,
-	deploymentID string, deviceID string) (bool, error) {
+func (db *DataStoreMongo) GetDeviceDeploymentViewsForDevice(ctx context.Context,
+	q store.ListQueryDeviceDeployments) ([]model.DeviceDeploymentView, int, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
 
-	var dep model.DeviceDeployment
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
-	query := bson.D{
-		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: deploymentID},
-		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
-		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
-			{Key: "$exists", Value: false},
-		}},
+	query, err := deviceDeploymentsQuery(q)
+	if err != nil {
+		return nil, -1, err
+	}
+	maxCount := maxCountDocuments
+	if len(q.IDs) == 0 && q.DeviceID == "" && q.DeviceIDPrefix != "" {
+		maxCount = store.DeviceIDPrefixMaxResults
+	}
+	limit := int64(DefaultDocumentLimit)
+	if q.Limit > 0 {
+		limit = int64(q.Limit)
 	}
 
-	if err := collDevs.FindOne(ctx, query).Decode(&dep); err != nil {
+	first := func(field string) bson.D {
+		return bson.D{{Key: "$arrayElemAt", Value: bson.A{"$" + field, 0}}}
+	}
+	pipe := []bson.D{
+		{{Key: "$match", Value: query}},
+		{{Key: "$sort", Value: deviceDeploymentsSort}},
+	}
+	if q.Skip > 0 {
+		pipe = append(pipe, bson.D{{Key: "$skip", Value: int64(q.Skip)}})
+	}
+	pipe = append(pipe,
+		bson.D{{Key: "$limit", Value: limit}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: CollectionDeployments},
+			{Key: "localField", Value: StorageKeyDeviceDeploymentDeploymentID},
+			{Key: "foreignField", Value: StorageKeyId},
+			{Key: "as", Value: "deployment"},
+		}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: CollectionImages},
+			{Key: "localField", Value: StorageKeyDeviceDeploymentAssignedImageId},
+			{Key: "foreignField", Value: StorageKeyId},
+			{Key: "as", Value: "artifact"},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: 1},
+			{Key: StorageKeyDeviceDeploymentDeploymentID, Value: 1},
+			{Key: StorageKeyDeviceDeploymentStatus, Value: 1},
+			{Key: StorageKeyDeviceDeploymentSubState, Value: 1},
+			{Key: StorageKeyDeviceDeploymentCreated, Value: 1},
+			{Key: StorageKeyDeviceDeploymentStarted, Value: 1},
+			{Key: StorageKeyDeviceDeploymentFinished, Value: 1},
+			{Key: StorageKeyDeviceDeploymentIsLogAvailable, Value: 1},
+			{Key: StorageKeyDeviceDeploymentAttempts, Value: 1},
+			{Key: "deployment_name", Value: first("deployment." + StorageKeyDeploymentName)},
+			{Key: "deployment_status",
+				Value: first("deployment." + StorageKeyDeploymentStatus)},
+			{Key: "artifact_id", Value: "$" + StorageKeyDeviceDeploymentAssignedImageId},
+			// the artifact may be gone, or not assigned yet: fall back
+			// to the artifact name the deployment was created for
+			{Key: "artifact_name", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+				first("artifact." + StorageKeyImageName),
+				first("deployment." + StorageKeyDeploymentArtifactName),
+			}}}},
+		}}},
+	)
+
+	cursor, err := collDevs.Aggregate(ctx, pipe)
+	if err != nil {
+		return nil, -1, err
+	}
+	views := []model.DeviceDeploymentView{}
+	if err = cursor.All(ctx, &views); err != nil {
+		return nil, -1, err
+	}
+
+	count, err := collDevs.CountDocuments(ctx, query, &mopts.CountOptions{
+		Limit: &maxCount,
+	})
+	if err != nil {
+		return nil, -1, ErrDevicesCountFailed
+	}
+	return views, int(count), nil
+}
+
+func deviceDeploymentsQuery(q store.ListQueryDeviceDeployments) (bson.D, error) {
+	query := bson.D{}
+	if len(q.IDs) > 0 {
+		query = append(query, bson.E{
+			Key: StorageKeyId,
+			Value: bson.D{{
+				Key:   "$in",
+				Value: q.IDs,
+			}},
+		})
+	} else if q.DeviceID != "" {
+		query = append(query, bson.E{
+			Key:   StorageKeyDeviceDeploymentDeviceId,
+			Value: q.DeviceID,
+		})
+	} else if q.DeviceIDPrefix != "" {
+		// anchored, case-sensitive regular expressions can use the
+		// device ID indexes as a range scan
+		query = append(query, bson.E{
+			Key: StorageKeyDeviceDeploymentDeviceId,
+			Value: primitive.Regex{
+				Pattern: "^" + regexp.QuoteMeta(q.DeviceIDPrefix),
+			},
+		})
+	}
+
+	if q.CreatedAfter != nil || q.CreatedBefore != nil {
+		created := bson.D{}
+		if q.CreatedAfter != nil {
+			created = append(created, bson.E{Key: "$gte", Value: *q.CreatedAfter})
+		}
+		if q.CreatedBefore != nil {
+			created = append(created, bson.E{Key: "$lte", Value: *q.CreatedBefore})
+		}
+		query = append(query, bson.E{
+			Key: StorageKeyDeviceDeploymentCreated, Value: created,
+		})
+	}
+
+	if q.Status != nil {
+		if *q.Status == model.DeviceDeploymentStatusPauseStr {
+			query = append(query, bson.E{
+				Key: "status", Value: bson.D{{
+					Key:   "$gte",
+					Value: model.DeviceDeploymentStatusPauseBeforeInstall,
+				}, {
+					Key:   "$lte",
+					Value: model.DeviceDeploymentStatusPauseBeforeReboot,
+				}},
+			})
+		} else if *q.Status == model.DeviceDeploymentStatusActiveStr {
+			query = append(query, bson.E{
+				Key: "status", Value: bson.D{{
+					Key:   "$gte",
+					Value: model.DeviceDeploymentStatusPauseBeforeInstall,
+				}, {
+					Key:   "$lte",
+					Value: model.DeviceDeploymentStatusPending,
+				}},
+			})
+		} else if *q.Status == model.DeviceDeploymentStatusFinishedStr {
+			query = append(query, bson.E{
+				Key: "status", Value: bson.D{{
+					Key: "$in",
+					Value: []model.DeviceDeploymentStatus{
+						model.DeviceDeploymentStatusFailure,
+						model.DeviceDeploymentStatusAborted,
+						model.DeviceDeploymentStatusSuccess,
+						model.DeviceDeploymentStatusNoArtifact,
+						model.DeviceDeploymentStatusAlreadyInst,
+						model.DeviceDeploymentStatusDecommissioned,
+					},
+				}},
+			})
+		} else {
+			var status model.DeviceDeploymentStatus
+			err := status.UnmarshalText([]byte(*q.Status))
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid status query")
+			}
+			query = append(query, bson.E{
+				Key: "status", Value: status,
+			})
+		}
+	}
+	return query, nil
+}
+
+// Returns true if deployment of ID `deploymentID` is assigned to device with ID
+// `deviceID`, false otherwise. In case of errors returns false and an error
+// that occurred
+func (db *DataStoreMongo) HasDeploymentForDevice(ctx context.Context,
+	deploymentID string, deviceID string) (bool, error) {
+
+	var dep model.DeviceDeployment
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentDeploymentID, Value: deploymentID},
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+
+	if err := collDevs.FindOne(ctx, query).Decode(&dep); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return false, nil
 		} else {
@@ -1985,14 +3342,42 @@ func (db *DataStoreMongo) HasDeploymentForDevice(ctx context.Context,
 	return true, nil
 }
 
+// CountActiveDeploymentsByDevice returns the number of active, non-deleted
+// device deployments for the given device.
+func (db *DataStoreMongo) CountActiveDeploymentsByDevice(ctx context.Context,
+	deviceID string) (int, error) {
+
+	if len(deviceID) == 0 {
+		return 0, ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentActive, Value: true},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+
+	count, err := collDevs.CountDocuments(ctx, query)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count active device deployments")
+	}
+
+	return int(count), nil
+}
+
 func (db *DataStoreMongo) AbortDeviceDeployments(ctx context.Context,
-	deploymentId string) error {
+	deploymentId string) (int64, error) {
 
 	if len(deploymentId) == 0 {
-		return ErrStorageInvalidID
+		return 0, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 	selector := bson.M{
 		StorageKeyDeviceDeploymentDeploymentID: deploymentId,
@@ -2008,17 +3393,24 @@ func (db *DataStoreMongo) AbortDeviceDeployments(ctx context.Context,
 			StorageKeyDeviceDeploymentActive: false,
 		},
 	}
+	if push := db.subStateHistoryPush(model.SubStateHistoryEntry{
+		Status:    model.DeviceDeploymentStatusAborted,
+		Timestamp: time.Now().UTC(),
+	}); push != nil {
+		update["$push"] = push
+	}
 
-	if _, err := collDevs.UpdateMany(ctx, selector, update); err != nil {
-		return err
+	res, err := collDevs.UpdateMany(ctx, selector, update)
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return res.ModifiedCount, nil
 }
 
 func (db *DataStoreMongo) DeleteDeviceDeploymentsHistory(ctx context.Context,
 	deviceID string) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 	selector := bson.M{
 		StorageKeyDeviceDeploymentDeviceId: deviceID,
@@ -2039,21 +3431,24 @@ func (db *DataStoreMongo) DeleteDeviceDeploymentsHistory(ctx context.Context,
 		return err
 	}
 
-	database = db.client.Database(DatabaseName)
+	database = db.client.Database(db.dbName)
 	collDevs = database.Collection(CollectionDevicesLastStatus)
 	_, err := collDevs.DeleteMany(ctx, bson.M{StorageKeyDeviceDeploymentDeviceId: deviceID})
 
 	return err
 }
 
+// DecommissionDeviceDeployments decommissions the active device deployments
+// one at a time, so that each comes back with the status it had before and
+// the statistics of its deployment can be incremented.
 func (db *DataStoreMongo) DecommissionDeviceDeployments(ctx context.Context,
-	deviceId string) error {
+	deviceId string) ([]model.DeviceDeployment, error) {
 
 	if len(deviceId) == 0 {
-		return ErrStorageInvalidID
+		return nil, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 	selector := bson.M{
 		StorageKeyDeviceDeploymentDeviceId: deviceId,
@@ -2069,18 +3464,39 @@ func (db *DataStoreMongo) DecommissionDeviceDeployments(ctx context.Context,
 			StorageKeyDeviceDeploymentActive: false,
 		},
 	}
-
-	if _, err := collDevs.UpdateMany(ctx, selector, update); err != nil {
-		return err
+	if push := db.subStateHistoryPush(model.SubStateHistoryEntry{
+		Status:    model.DeviceDeploymentStatusDecommissioned,
+		Timestamp: time.Now().UTC(),
+	}); push != nil {
+		update["$push"] = push
 	}
 
-	return nil
+	opts := mopts.FindOneAndUpdate().
+		SetReturnDocument(mopts.Before).
+		SetProjection(bson.M{
+			StorageKeyDeviceDeploymentDeviceId:     1,
+			StorageKeyDeviceDeploymentDeploymentID: 1,
+			StorageKeyDeviceDeploymentStatus:       1,
+		})
+	var deviceDeployments []model.DeviceDeployment
+	for {
+		var deviceDeployment model.DeviceDeployment
+		err := collDevs.FindOneAndUpdate(ctx, selector, update, opts).
+			Decode(&deviceDeployment)
+		if err == mongo.ErrNoDocuments {
+			break
+		} else if err != nil {
+			return deviceDeployments, err
+		}
+		deviceDeployments = append(deviceDeployments, deviceDeployment)
+	}
+	return deviceDeployments, nil
 }
 
 func (db *DataStoreMongo) GetDeviceDeployment(ctx context.Context, deploymentID string,
 	deviceID string, includeDeleted bool) (*model.DeviceDeployment, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	filter := bson.M{
@@ -2116,7 +3532,7 @@ func (db *DataStoreMongo) GetDeviceDeployments(
 	includeDeleted bool,
 ) ([]model.DeviceDeployment, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	filter := bson.M{}
@@ -2166,11 +3582,41 @@ func (db *DataStoreMongo) EnsureIndexes(dbName string, collName string,
 	return err
 }
 
+// indexCreator is the part of mongo.IndexView creating the indexes.
+type indexCreator interface {
+	CreateMany(ctx context.Context, models []mongo.IndexModel,
+		opts ...*mopts.CreateIndexesOptions) ([]string, error)
+}
+
+// createIndexes creates the indexes in the background or in the foreground,
+// overriding the build mode in their options.
+func createIndexes(
+	ctx context.Context,
+	indexes indexCreator,
+	background bool,
+	models ...mongo.IndexModel,
+) error {
+	withMode := make([]mongo.IndexModel, len(models))
+	for i, model := range models {
+		options := mopts.Index()
+		if model.Options != nil {
+			copied := *model.Options
+			options = &copied
+		}
+		withMode[i] = mongo.IndexModel{
+			Keys:    model.Keys,
+			Options: options.SetBackground(background),
+		}
+	}
+	_, err := indexes.CreateMany(ctx, withMode)
+	return err
+}
+
 // return true if required indexing was set up
 func (db *DataStoreMongo) hasIndexing(ctx context.Context, client *mongo.Client) bool {
 
 	var idx bson.M
-	database := client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 	idxView := collDpl.Indexes()
 
@@ -2180,103 +3626,544 @@ func (db *DataStoreMongo) hasIndexing(ctx context.Context, client *mongo.Client)
 		return false
 	}
 
-	has := map[string]bool{}
-	for cursor.Next(ctx) {
-		if err = cursor.Decode(&idx); err != nil {
-			continue
-		}
-		if _, ok := idx["weights"]; ok {
-			// text index
-			for k := range idx["weights"].(bson.M) {
-				has[k] = true
-			}
-		} else {
-			for i := range idx["key"].(bson.M) {
-				has[i] = true
-			}
+	has := map[string]bool{}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&idx); err != nil {
+			continue
+		}
+		if _, ok := idx["weights"]; ok {
+			// text index
+			for k := range idx["weights"].(bson.M) {
+				has[k] = true
+			}
+		} else {
+			for i := range idx["key"].(bson.M) {
+				has[i] = true
+			}
+
+		}
+	}
+	if err != nil {
+		return false
+	}
+
+	for _, key := range StorageIndexes.Keys.(bson.D) {
+		_, ok := has[key.Key]
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Insert persists object
+func (db *DataStoreMongo) InsertDeployment(
+	ctx context.Context,
+	deployment *model.Deployment,
+) error {
+
+	if deployment == nil {
+		return ErrDeploymentStorageInvalidDeployment
+	}
+
+	if err := deployment.Validate(); err != nil {
+		return err
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	if _, err := collDpl.InsertOne(ctx, deployment); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrConflictingDeployment
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteDeployment marks the deployment as deleted; the document is kept
+// until purged with PurgeDeletedDeployments.
+// Noop on ID not found
+func (db *DataStoreMongo) DeleteDeployment(ctx context.Context, id string) error {
+
+	if len(id) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter := bson.M{
+		"_id":                       id,
+		StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{StorageKeyDeploymentDeleted: time.Now()}}
+	if _, err := collDpl.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreDeployment clears the deleted mark of a deployment deleted after
+// deletedAfter; returns store.ErrNotFound if there is no such deployment.
+func (db *DataStoreMongo) RestoreDeployment(
+	ctx context.Context,
+	id string,
+	deletedAfter time.Time,
+) error {
+
+	if len(id) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter := bson.M{
+		"_id":                       id,
+		StorageKeyDeploymentDeleted: bson.M{"$gt": deletedAfter},
+	}
+	update := bson.M{"$unset": bson.M{StorageKeyDeploymentDeleted: true}}
+	res, err := collDpl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// StartDeployment sets a paused deployment pending as of started, letting
+// its devices get it, and replaces its phases if any; returns
+// store.ErrNotFound if there is no such paused deployment.
+func (db *DataStoreMongo) StartDeployment(
+	ctx context.Context,
+	id string,
+	started time.Time,
+	phases model.DeploymentPhases,
+) error {
+	if len(id) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter := bson.M{
+		"_id":                       id,
+		StorageKeyDeploymentStatus:  model.DeploymentStatusPaused,
+		StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+	}
+	set := bson.M{
+		StorageKeyDeploymentStatus:  model.DeploymentStatusPending,
+		StorageKeyDeploymentStarted: started,
+	}
+	if len(phases) > 0 {
+		set[StorageKeyDeploymentPhases] = phases
+	}
+	update := bson.M{"$set": set}
+	res, err := collDpl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateDeployment sets the fields of the patch on the deployment.
+func (db *DataStoreMongo) UpdateDeployment(
+	ctx context.Context,
+	id string,
+	patch model.DeploymentPatch,
+) error {
+	if len(id) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	set := bson.M{}
+	if patch.Name != nil {
+		set[StorageKeyDeploymentName] = *patch.Name
+	}
+	if patch.Description != nil {
+		set[StorageKeyDeploymentDescription] = *patch.Description
+	}
+	filter := bson.M{
+		"_id":                       id,
+		StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+	}
+	res, err := collDpl.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeletedDeployments permanently removes the deployments deleted before
+// deletedBefore and returns the number of removed deployments.
+func (db *DataStoreMongo) PurgeDeletedDeployments(
+	ctx context.Context,
+	deletedBefore time.Time,
+) (int64, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter := bson.M{
+		StorageKeyDeploymentDeleted: bson.M{"$lt": deletedBefore},
+	}
+	ids, err := collDpl.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return 0, err
+	} else if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// the device deployments and logs go first, so that the deployments
+	// are still found by the next run if removing them fails
+	byDeployment := bson.M{
+		StorageKeyDeviceDeploymentDeploymentID: bson.M{"$in": ids},
+	}
+	_, err = database.Collection(CollectionDevices).DeleteMany(ctx, byDeployment)
+	if err != nil {
+		return 0, err
+	}
+	_, err = database.Collection(CollectionDeviceDeploymentLogs).
+		DeleteMany(ctx, byDeployment)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := collDpl.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// FindDeploymentByID returns the deployment with the given ID, or nil if
+// not found; deleted deployments are only returned if includeDeleted is set.
+func (db *DataStoreMongo) FindDeploymentByID(
+	ctx context.Context,
+	id string,
+	includeDeleted bool,
+) (*model.Deployment, error) {
+
+	if len(id) == 0 {
+		return nil, ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter := bson.M{"_id": id}
+	if !includeDeleted {
+		filter[StorageKeyDeploymentDeleted] = bson.M{"$exists": false}
+	}
+	deployment := new(model.Deployment)
+	if err := collDpl.FindOne(ctx, filter).
+		Decode(deployment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// GetDeploymentTargetDevices returns a page of the device list stored with
+// the deployment, along with the total number of targeted devices.
+func (db *DataStoreMongo) GetDeploymentTargetDevices(
+	ctx context.Context,
+	id string,
+	skip, limit int,
+) ([]string, int, error) {
+
+	if len(id) == 0 {
+		return nil, -1, ErrStorageInvalidID
+	}
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
 
-		}
+	deviceList := bson.M{"$ifNull": bson.A{"$" + StorageKeyDeploymentDeviceList, bson.A{}}}
+	pipeline := []bson.D{
+		{{Key: "$match", Value: bson.M{StorageKeyId: id}}},
+		{{Key: "$project", Value: bson.M{
+			"devices": bson.M{"$slice": bson.A{deviceList, skip, limit}},
+			"count":   bson.M{"$size": deviceList},
+		}}},
 	}
+	cursor, err := collDpl.Aggregate(ctx, pipeline)
 	if err != nil {
-		return false
+		return nil, -1, err
 	}
+	defer cursor.Close(ctx)
 
-	for _, key := range StorageIndexes.Keys.(bson.D) {
-		_, ok := has[key.Key]
-		if !ok {
-			return false
+	if !cursor.Next(ctx) {
+		if err = cursor.Err(); err != nil {
+			return nil, -1, err
 		}
+		return nil, -1, store.ErrNotFound
+	}
+	var res struct {
+		Devices []string `bson:"devices"`
+		Count   int      `bson:"count"`
+	}
+	if err = cursor.Decode(&res); err != nil {
+		return nil, -1, err
 	}
 
-	return true
+	return res.Devices, res.Count, nil
 }
 
-// Insert persists object
-func (db *DataStoreMongo) InsertDeployment(
+// GetFleetSoftwareInventory reports the artifact of the most recent
+// successful device deployment of every device. The device deployments are
+// walked in the order of the device ID, creation and status index, so that
+// the last one of each group is the latest.
+func (db *DataStoreMongo) GetFleetSoftwareInventory(
 	ctx context.Context,
-	deployment *model.Deployment,
-) error {
+	query model.SoftwareInventoryQuery,
+) ([]model.DeviceSoftware, int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
 
-	if deployment == nil {
-		return ErrDeploymentStorageInvalidDeployment
+	limit := query.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
 	}
-
-	if err := deployment.Validate(); err != nil {
-		return err
+	pipeline := []bson.D{
+		{{Key: "$match", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentStatus,
+				Value: model.DeviceDeploymentStatusSuccess},
+			{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+				{Key: "$exists", Value: false},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentDeviceId, Value: 1},
+			{Key: StorageKeyDeviceDeploymentCreated, Value: 1},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$" + StorageKeyDeviceDeploymentDeviceId},
+			{Key: "artifact_id", Value: bson.M{
+				"$last": "$" + StorageKeyDeviceDeploymentAssignedImageId,
+			}},
+			{Key: "artifact_name", Value: bson.M{
+				"$last": "$" + StorageKeyDeviceDeploymentAssignedImage +
+					"." + StorageKeyImageName,
+			}},
+			{Key: "deployment_id", Value: bson.M{
+				"$last": "$" + StorageKeyDeviceDeploymentDeploymentID,
+			}},
+			{Key: "device_deployment_id", Value: bson.M{"$last": "$" + StorageKeyId}},
+			{Key: "finished", Value: bson.M{
+				"$last": "$" + StorageKeyDeviceDeploymentFinished,
+			}},
+		}}},
 	}
+	if query.ArtifactName != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{
+			{Key: "artifact_name", Value: query.ArtifactName},
+		}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "results", Value: []bson.D{
+			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+			{{Key: "$skip", Value: int64(query.Skip)}},
+			{{Key: "$limit", Value: int64(limit)}},
+		}},
+		{Key: "count", Value: []bson.D{
+			{{Key: "$count", Value: "count"}},
+		}},
+	}}})
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
-	collDpl := database.Collection(CollectionDeployments)
+	opts := mopts.Aggregate().
+		SetHint(IndexDeploymentDeviceCreatedStatusName).
+		SetAllowDiskUse(true)
+	cursor, err := collDevs.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer cursor.Close(ctx)
 
-	if _, err := collDpl.InsertOne(ctx, deployment); err != nil {
-		if mongo.IsDuplicateKeyError(err) {
-			return ErrConflictingDeployment
-		}
-		return err
+	var res struct {
+		Results []model.DeviceSoftware `bson:"results"`
+		Count   []struct{ Count int }  `bson:"count"`
 	}
-	return nil
+	if !cursor.Next(ctx) {
+		return []model.DeviceSoftware{}, 0, cursor.Err()
+	} else if err = cursor.Decode(&res); err != nil {
+		return nil, -1, err
+	} else if len(res.Count) == 0 {
+		return []model.DeviceSoftware{}, 0, nil
+	}
+	return res.Results, res.Count[0].Count, nil
 }
 
-// Delete removed entry by ID
-// Noop on ID not found
-func (db *DataStoreMongo) DeleteDeployment(ctx context.Context, id string) error {
+// GetDeploymentCreationTrend counts the deployments created in [from, to)
+// grouped in buckets of the given granularity; buckets without deployments
+// are omitted.
+func (db *DataStoreMongo) GetDeploymentCreationTrend(
+	ctx context.Context,
+	from, to time.Time,
+	granularity model.TrendGranularity,
+) ([]model.TrendBucket, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
 
-	if len(id) == 0 {
-		return ErrStorageInvalidID
+	dateTrunc := bson.M{
+		"date": "$" + StorageKeyDeploymentCreated,
+		"unit": string(granularity),
+	}
+	if granularity == model.TrendGranularityWeek {
+		dateTrunc["startOfWeek"] = "monday"
+	}
+	pipeline := []bson.D{
+		{{Key: "$match", Value: bson.M{
+			StorageKeyDeploymentCreated: bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$dateTrunc": dateTrunc},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	opts := mopts.Aggregate().SetHint(IndexDeploymentCreatedName)
+	cursor, err := collDpl.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate deployments")
+	}
+	defer cursor.Close(ctx)
+
+	buckets := []model.TrendBucket{}
+	if err = cursor.All(ctx, &buckets); err != nil {
+		return nil, errors.Wrap(err, "failed to decode deployment trend")
 	}
+	return buckets, nil
+}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+// GetLargestDeployments returns up to limit deployments sorted by device
+// count, descending; the deployments with no device count yet sort last.
+func (db *DataStoreMongo) GetLargestDeployments(
+	ctx context.Context,
+	limit int,
+) ([]*model.Deployment, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
-	if _, err := collDpl.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
-		return err
+	filter := bson.M{StorageKeyDeploymentDeleted: bson.M{"$exists": false}}
+	opts := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeploymentDeviceCount, Value: -1}}).
+		SetLimit(int64(limit)).
+		SetHint(IndexNameDeploymentDeviceCount).
+		SetProjection(bson.M{StorageKeyDeploymentDeviceList: 0})
+	cursor, err := collDpl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find deployments")
 	}
+	defer cursor.Close(ctx)
 
-	return nil
+	deployments := []*model.Deployment{}
+	if err = cursor.All(ctx, &deployments); err != nil {
+		return nil, errors.Wrap(err, "failed to decode deployments")
+	}
+	return deployments, nil
 }
 
-func (db *DataStoreMongo) FindDeploymentByID(
+// CountDeploymentsByStatus counts the deployments, not deleted, in each
+// status; the statuses without deployments are omitted.
+func (db *DataStoreMongo) CountDeploymentsByStatus(
 	ctx context.Context,
-	id string,
-) (*model.Deployment, error) {
+) (map[model.DeploymentStatus]int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
 
-	if len(id) == 0 {
-		return nil, ErrStorageInvalidID
+	pipeline := []bson.D{
+		{{Key: "$match", Value: bson.M{
+			StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$" + StorageKeyDeploymentStatus,
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	opts := mopts.Aggregate().SetHint(IndexDeploymentStatus)
+	cursor, err := collDpl.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate deployments")
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		Status model.DeploymentStatus `bson:"_id"`
+		Count  int                    `bson:"count"`
 	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, errors.Wrap(err, "failed to decode deployment counts")
+	}
+	counts := make(map[model.DeploymentStatus]int, len(groups))
+	for _, group := range groups {
+		counts[group.Status] = group.Count
+	}
+	return counts, nil
+}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+// FindStaleActiveDeployments returns up to limit active deployments created
+// before olderThan which still have devices pending or downloading, oldest
+// first.
+func (db *DataStoreMongo) FindStaleActiveDeployments(
+	ctx context.Context,
+	olderThan time.Time,
+	limit int,
+) ([]*model.Deployment, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
-	deployment := new(model.Deployment)
-	if err := collDpl.FindOne(ctx, bson.M{"_id": id}).
-		Decode(deployment); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, nil
-		}
-		return nil, err
+	filter := bson.D{
+		{Key: StorageKeyDeploymentActive, Value: true},
+		{Key: StorageKeyDeploymentCreated, Value: bson.M{"$lt": olderThan}},
+		{Key: "$or", Value: bson.A{
+			bson.M{StorageKeyDeploymentStats + "." +
+				model.DeviceDeploymentStatusPendingStr: bson.M{"$gt": 0}},
+			bson.M{StorageKeyDeploymentStats + "." +
+				model.DeviceDeploymentStatusDownloadingStr: bson.M{"$gt": 0}},
+		}},
+	}
+	opts := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}).
+		SetLimit(int64(limit)).
+		SetHint(IndexDeploymentsActiveCreatedV2).
+		SetProjection(bson.M{
+			StorageKeyDeploymentConstructorChecksum: 0,
+			StorageKeyDeploymentDeviceList:          0,
+		})
+	cursor, err := collDpl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find deployments")
 	}
+	defer cursor.Close(ctx)
 
-	return deployment, nil
+	deployments := []*model.Deployment{}
+	if err = cursor.All(ctx, &deployments); err != nil {
+		return nil, errors.Wrap(err, "failed to decode deployments")
+	}
+	return deployments, nil
 }
 
 func (db *DataStoreMongo) FindDeploymentStatsByIDs(
@@ -2294,7 +4181,7 @@ func (db *DataStoreMongo) FindDeploymentStatsByIDs(
 		}
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	query := bson.M{
@@ -2336,7 +4223,7 @@ func (db *DataStoreMongo) FindUnfinishedByID(ctx context.Context,
 		return nil, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var deployment *model.Deployment
@@ -2360,7 +4247,7 @@ func (db *DataStoreMongo) IncrementDeploymentDeviceCount(
 	deploymentID string,
 	increment int,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collection := database.Collection(CollectionDeployments)
 
 	filter := bson.M{
@@ -2385,7 +4272,7 @@ func (db *DataStoreMongo) SetDeploymentDeviceCount(
 	deploymentID string,
 	count int,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collection := database.Collection(CollectionDeployments)
 
 	filter := bson.M{
@@ -2405,10 +4292,45 @@ func (db *DataStoreMongo) SetDeploymentDeviceCount(
 	return err
 }
 
+func (db *DataStoreMongo) ResetDeploymentDeviceCount(
+	ctx context.Context,
+	deploymentID string,
+	previous *int,
+	count int,
+) error {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collection := database.Collection(CollectionDeployments)
+
+	// compare and set: a concurrent increment must not be overwritten
+	filter := bson.M{"_id": deploymentID}
+	if previous != nil {
+		filter[StorageKeyDeploymentDeviceCount] = *previous
+	} else {
+		filter[StorageKeyDeploymentDeviceCount] = bson.M{"$eq": nil}
+	}
+	res, err := collection.UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{
+			StorageKeyDeploymentDeviceCount: count,
+		}},
+	)
+	if err != nil {
+		return err
+	} else if res.MatchedCount > 0 {
+		return nil
+	}
+	n, err := collection.CountDocuments(ctx, bson.M{"_id": deploymentID})
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrNotFound
+	}
+	return ErrDeviceCountChanged
+}
+
 func (db *DataStoreMongo) DeviceCountByDeployment(ctx context.Context,
 	id string) (int, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevices)
 
 	filter := bson.M{
@@ -2433,7 +4355,7 @@ func (db *DataStoreMongo) UpdateStats(ctx context.Context,
 		return ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	deployment, err := model.NewDeployment()
@@ -2484,7 +4406,7 @@ func (db *DataStoreMongo) UpdateStatsInc(ctx context.Context, id string,
 		return nil, nil
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var update bson.M
@@ -2526,34 +4448,257 @@ func (db *DataStoreMongo) UpdateStatsInc(ctx context.Context, id string,
 	return res.Stats, err
 }
 
+// ClaimDeploymentUpdateSlot moves one device deployment from pending to
+// downloading in the statistics of the deployment, in a single update
+// conditional on fewer than limit device deployments updating, so that
+// concurrent claims cannot exceed the limit.
+func (db *DataStoreMongo) ClaimDeploymentUpdateSlot(
+	ctx context.Context,
+	id string,
+	limit uint,
+) (model.Stats, bool, error) {
+	if len(id) == 0 {
+		return nil, false, ErrStorageInvalidID
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	var updating bson.A
+	for _, status := range model.ActiveDeploymentStatuses() {
+		if status == model.DeviceDeploymentStatusPending {
+			continue
+		}
+		updating = append(updating, bson.D{{Key: "$ifNull", Value: bson.A{
+			"$" + StorageKeyDeploymentStats + "." + status.String(), 0,
+		}}})
+	}
+	filter := bson.D{
+		{Key: StorageKeyId, Value: id},
+		{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{
+			bson.D{{Key: "$add", Value: updating}}, int64(limit),
+		}}}},
+	}
+	update := bson.M{
+		"$inc": bson.M{
+			"stats." + model.DeviceDeploymentStatusPending.String():     -1,
+			"stats." + model.DeviceDeploymentStatusDownloading.String(): 1,
+		},
+	}
+	var res struct {
+		Stats model.Stats `bson:"stats"`
+	}
+	err := collDpl.FindOneAndUpdate(ctx, filter, update,
+		mopts.FindOneAndUpdate().
+			SetReturnDocument(mopts.After).
+			SetProjection(bson.M{
+				StorageKeyDeploymentStats: 1,
+			}),
+	).Decode(&res)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, err := collDpl.CountDocuments(ctx, bson.D{{Key: StorageKeyId, Value: id}})
+		if err != nil {
+			return nil, false, err
+		} else if count == 0 {
+			return nil, false, ErrStorageInvalidID
+		}
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return res.Stats, true, nil
+}
+
 func (db *DataStoreMongo) IncrementDeploymentTotalSize(
 	ctx context.Context,
 	deploymentID string,
 	increment int64,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collection := database.Collection(CollectionDeployments)
 
 	filter := bson.M{
 		"_id": deploymentID,
 	}
-
-	update := bson.M{
-		"$inc": bson.M{
-			StorageKeyDeploymentTotalSize: increment,
-		},
+
+	update := bson.M{
+		"$inc": bson.M{
+			StorageKeyDeploymentTotalSize: increment,
+		},
+	}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (db *DataStoreMongo) Find(ctx context.Context,
+	match model.Query) ([]*model.Deployment, int64, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	query, err := db.findQuery(ctx, match)
+	if err != nil {
+		return nil, 0, err
+	}
+	return findDeployments(ctx, collDpl, query, match, db.findOptions(match))
+}
+
+// FindDeploymentsByArtifact lists the deployments, finished or not, that
+// either target the artifact name or were assigned the artifact ID. The
+// remaining query parameters filter and paginate the results as for Find.
+func (db *DataStoreMongo) FindDeploymentsByArtifact(
+	ctx context.Context,
+	name string,
+	id string,
+	match model.Query,
+) ([]*model.Deployment, int64, error) {
+	artifactq := []bson.M{}
+	if name != "" {
+		artifactq = append(artifactq, bson.M{StorageKeyDeploymentArtifactName: name})
+	}
+	if id != "" {
+		artifactq = append(artifactq, bson.M{StorageKeyDeploymentArtifacts: id})
+	}
+	if len(artifactq) == 0 {
+		return nil, 0, ErrImagesStorageInvalidArtifactName
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	query, err := db.findQuery(ctx, match)
+	if err != nil {
+		return nil, 0, err
+	}
+	query = bson.M{"$and": []bson.M{query, {"$or": artifactq}}}
+	options := db.findOptions(match).
+		SetProjection(bson.M{StorageKeyDeploymentDeviceList: 0})
+	return findDeployments(ctx, collDpl, query, match, options)
+}
+
+// FindDeploymentsByGroup lists the deployments, finished or not, created
+// for the device group. The single device deployments listing the groups of
+// their device are left out. The remaining query parameters filter and
+// paginate the results as for Find.
+func (db *DataStoreMongo) FindDeploymentsByGroup(
+	ctx context.Context,
+	group string,
+	match model.Query,
+) ([]*model.Deployment, int64, error) {
+	if group == "" {
+		return nil, 0, ErrStorageInvalidInput
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	query, err := db.findQuery(ctx, match)
+	if err != nil {
+		return nil, 0, err
+	}
+	query = bson.M{"$and": []bson.M{query, {StorageKeyDeploymentGroup: group}}}
+	options := db.findOptions(match).
+		SetProjection(bson.M{StorageKeyDeploymentDeviceList: 0})
+	return findDeployments(ctx, collDpl, query, match, options)
+}
+
+func (db *DataStoreMongo) GetReleaseRolloutStats(
+	ctx context.Context,
+	name string,
+	includeDeleted bool,
+) (*model.ReleaseRolloutStats, error) {
+	if name == "" {
+		return nil, ErrImagesStorageInvalidArtifactName
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	match := bson.D{
+		{Key: StorageKeyDeploymentArtifactName, Value: name},
+	}
+	if !includeDeleted {
+		match = append(match, bson.E{Key: StorageKeyDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}})
+	}
+	pipeline := []bson.D{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.D{
+			{Key: StorageKeyDeploymentDeviceCount, Value: bson.D{
+				{Key: "$ifNull", Value: bson.A{"$" + StorageKeyDeploymentDeviceCount, 0}},
+			}},
+			{Key: StorageKeyDeploymentStats, Value: bson.D{
+				{Key: "$objectToArray", Value: bson.D{
+					{Key: "$ifNull", Value: bson.A{"$" + StorageKeyDeploymentStats, bson.D{}}},
+				}},
+			}},
+		}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "totals", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "deployment_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "device_count", Value: bson.D{
+						{Key: "$sum", Value: "$" + StorageKeyDeploymentDeviceCount},
+					}},
+				}}},
+			}},
+			{Key: "statistics", Value: bson.A{
+				bson.D{{Key: "$unwind", Value: "$" + StorageKeyDeploymentStats}},
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: "$" + StorageKeyDeploymentStats + ".k"},
+					{Key: "count", Value: bson.D{
+						{Key: "$sum", Value: "$" + StorageKeyDeploymentStats + ".v"},
+					}},
+				}}},
+			}},
+		}}},
+	}
+	opts := mopts.Aggregate().SetHint(IndexNameDeploymentArtifactNameCreated)
+	cursor, err := collDpl.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate the deployments of the release")
 	}
+	defer cursor.Close(ctx)
 
-	_, err := collection.UpdateOne(ctx, filter, update)
-	return err
+	var res struct {
+		Totals     []model.ReleaseRolloutStats `bson:"totals"`
+		Statistics []struct {
+			Status string `bson:"_id"`
+			Count  int    `bson:"count"`
+		} `bson:"statistics"`
+	}
+	if cursor.Next(ctx) {
+		if err = cursor.Decode(&res); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the release rollout")
+		}
+	} else if err = cursor.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to aggregate the deployments of the release")
+	}
+	stats := &model.ReleaseRolloutStats{
+		Statistics: model.NewDeviceDeploymentStats(),
+	}
+	if len(res.Totals) > 0 {
+		stats.DeploymentCount = res.Totals[0].DeploymentCount
+		stats.DeviceCount = res.Totals[0].DeviceCount
+	}
+	for _, s := range res.Statistics {
+		stats.Statistics[s.Status] = s.Count
+	}
+	return stats, nil
 }
 
-func (db *DataStoreMongo) Find(ctx context.Context,
-	match model.Query) ([]*model.Deployment, int64, error) {
-
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
-	collDpl := database.Collection(CollectionDeployments)
-
+// findQuery builds the filter of the deployments matching the query.
+func (db *DataStoreMongo) findQuery(ctx context.Context, match model.Query) (bson.M, error) {
 	andq := []bson.M{}
 
 	// filter by IDs
@@ -2566,11 +4711,26 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 		andq = append(andq, tq)
 	}
 
+	if !match.IncludeDeleted {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+		})
+	}
+
+	// filter by device in the device list
+	if match.DeviceID != "" {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentDeviceList: bson.M{
+				"$in": []string{match.DeviceID},
+			},
+		})
+	}
+
 	// build deployment by name part of the query
 	if match.SearchText != "" {
 		// we must have indexing for text search
 		if !db.hasIndexing(ctx, db.client) {
-			return nil, 0, ErrDeploymentStorageCannotExecQuery
+			return nil, ErrDeploymentStorageCannotExecQuery
 		}
 
 		tq := bson.M{
@@ -2589,6 +4749,8 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 			status = model.DeploymentStatusPending
 		} else if match.Status == model.StatusQueryInProgress {
 			status = model.DeploymentStatusInProgress
+		} else if match.Status == model.StatusQueryPaused {
+			status = model.DeploymentStatusPaused
 		} else {
 			status = model.DeploymentStatusFinished
 		}
@@ -2633,8 +4795,16 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 		}
 	}
 
-	options := db.findOptions(match)
+	return query, nil
+}
 
+func findDeployments(
+	ctx context.Context,
+	collDpl *mongo.Collection,
+	query bson.M,
+	match model.Query,
+	options *mopts.FindOptions,
+) ([]*model.Deployment, int64, error) {
 	var deployments []*model.Deployment
 	cursor, err := collDpl.Find(ctx, query, options)
 	if err != nil {
@@ -2685,7 +4855,7 @@ func (db *DataStoreMongo) findOptions(match model.Query) *mopts.FindOptions {
 func (db *DataStoreMongo) FindNewerActiveDeployments(ctx context.Context,
 	createdAfter *time.Time, skip, limit int) ([]*model.Deployment, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	c := database.Collection(CollectionDeployments)
 
 	queryFilters := make([]bson.M, 0)
@@ -2715,18 +4885,22 @@ func (db *DataStoreMongo) FindNewerActiveDeployments(ctx context.Context,
 	return deployments, nil
 }
 
-// FindNewerActiveDeployment finds active deployments which were created
-// after createdAfter where deviceID is part of the device list.
+// FindNewerActiveDeployment finds active deployments which were created, or
+// started if created paused, after createdAfter where deviceID is part of
+// the device list; the paused deployments are left out.
 func (db *DataStoreMongo) FindNewerActiveDeployment(ctx context.Context,
 	createdAfter *time.Time, deviceID string) (*model.Deployment, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	c := database.Collection(CollectionDeployments)
 
 	findQuery := bson.D{
 		{Key: StorageKeyDeploymentActive, Value: true},
-		{Key: StorageKeyDeploymentCreated, Value: bson.M{"$gt": createdAfter}},
+		{Key: "$or", Value: activeSinceAfter(createdAfter)},
 		{Key: StorageKeyDeploymentDeviceList, Value: deviceID},
+		{Key: StorageKeyDeploymentStatus, Value: bson.M{
+			"$ne": model.DeploymentStatusPaused,
+		}},
 	}
 	findOptions := mopts.FindOne().
 		SetSort(bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}).
@@ -2749,6 +4923,118 @@ func (db *DataStoreMongo) FindNewerActiveDeployment(ctx context.Context,
 	return deployment, nil
 }
 
+// activeSinceAfter matches the deployments created, or started if created
+// paused, after the given time.
+func activeSinceAfter(after *time.Time) bson.A {
+	return bson.A{
+		bson.D{{Key: StorageKeyDeploymentCreated, Value: bson.M{"$gt": after}}},
+		bson.D{{Key: StorageKeyDeploymentStarted, Value: bson.M{"$gt": after}}},
+	}
+}
+
+// FindActiveDeploymentsForDevice finds the active deployments the device
+// has an active device deployment for, and the active deployments created,
+// or started, after createdAfter with the device in the device list, oldest
+// first. The paused deployments are left out.
+func (db *DataStoreMongo) FindActiveDeploymentsForDevice(ctx context.Context,
+	deviceID string, createdAfter *time.Time) ([]*model.Deployment, error) {
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+	collDpl := database.Collection(CollectionDeployments)
+
+	enrolled, err := collDevs.Distinct(ctx, StorageKeyDeviceDeploymentDeploymentID, bson.D{
+		{Key: StorageKeyDeviceDeploymentDeviceId, Value: deviceID},
+		{Key: StorageKeyDeviceDeploymentActive, Value: true},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get device deployments")
+	}
+
+	query := bson.D{
+		{Key: StorageKeyDeploymentActive, Value: true},
+		{Key: StorageKeyDeploymentStatus, Value: bson.M{
+			"$ne": model.DeploymentStatusPaused,
+		}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: enrolled}}}},
+			bson.D{
+				{Key: "$or", Value: activeSinceAfter(createdAfter)},
+				{Key: StorageKeyDeploymentDeviceList, Value: deviceID},
+			},
+		}},
+	}
+	findOptions := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}).
+		SetProjection(bson.M{
+			StorageKeyDeploymentConstructorChecksum: 0,
+			StorageKeyDeploymentDeviceList:          0,
+		})
+	cursor, err := collDpl.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deployments")
+	}
+	deployments := []*model.Deployment{}
+	if err := cursor.All(ctx, &deployments); err != nil {
+		return nil, errors.Wrap(err, "failed to get deployments")
+	}
+	return deployments, nil
+}
+
+// GetDeploymentDeviceIndex returns the position of the device in the device
+// list of the deployment, or -1 if the device is not in the list; the list
+// is not sent over the wire.
+func (db *DataStoreMongo) GetDeploymentDeviceIndex(
+	ctx context.Context,
+	deploymentID, deviceID string,
+) (int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	c := database.Collection(CollectionDeployments)
+
+	cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": deploymentID}}},
+		{{Key: "$project", Value: bson.M{
+			"index": bson.M{"$indexOfArray": bson.A{
+				"$" + StorageKeyDeploymentDeviceList, deviceID,
+			}},
+		}}},
+	})
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to look up the device in the deployment")
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return -1, errors.Wrap(err, "failed to look up the device in the deployment")
+		}
+		return -1, ErrStorageNotFound
+	}
+	var result struct {
+		Index int `bson:"index"`
+	}
+	if err := cursor.Decode(&result); err != nil {
+		return -1, errors.Wrap(err, "failed to decode the device index")
+	}
+	return result.Index, nil
+}
+
+// SetDeploymentCurrentPhase records the phase a phased deployment reached.
+func (db *DataStoreMongo) SetDeploymentCurrentPhase(
+	ctx context.Context,
+	deploymentID string,
+	phase int,
+) error {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collection := database.Collection(CollectionDeployments)
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": deploymentID},
+		bson.M{"$max": bson.M{StorageKeyDeploymentCurrentPhase: phase}},
+	)
+	return err
+}
+
 // SetDeploymentStatus simply sets the status field
 // optionally sets 'finished time' if deployment is indeed finished
 func (db *DataStoreMongo) SetDeploymentStatus(
@@ -2756,12 +5042,12 @@ func (db *DataStoreMongo) SetDeploymentStatus(
 	id string,
 	status model.DeploymentStatus,
 	now time.Time,
-) error {
+) (bool, error) {
 	if len(id) == 0 {
-		return ErrStorageInvalidID
+		return false, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var update bson.M
@@ -2782,13 +5068,221 @@ func (db *DataStoreMongo) SetDeploymentStatus(
 		}
 	}
 
-	res, err := collDpl.UpdateOne(ctx, bson.M{"_id": id}, update)
+	// a finished deployment stays finished, and finishes only once
+	res, err := collDpl.UpdateOne(ctx, bson.M{
+		"_id": id,
+		StorageKeyDeploymentStatus: bson.M{
+			"$ne": model.DeploymentStatusFinished,
+		},
+	}, update)
+	if err != nil {
+		return false, err
+	} else if res.MatchedCount > 0 {
+		return res.ModifiedCount > 0, nil
+	}
 
-	if res != nil && res.MatchedCount == 0 {
+	count, err := collDpl.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	} else if count == 0 {
+		return false, ErrStorageInvalidID
+	}
+	return false, nil
+}
+
+// SetDeploymentAborted records who aborted the deployment, and when.
+func (db *DataStoreMongo) SetDeploymentAborted(
+	ctx context.Context,
+	id string,
+	abortedBy string,
+	now time.Time,
+) error {
+	if len(id) == 0 {
 		return ErrStorageInvalidID
 	}
 
-	return err
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	set := bson.D{{Key: StorageKeyDeploymentAborted, Value: now}}
+	if abortedBy != "" {
+		set = append(set, bson.E{Key: StorageKeyDeploymentAbortedBy, Value: abortedBy})
+	}
+	res, err := collDpl.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: mongoOpSet, Value: set}},
+	)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return ErrStorageInvalidID
+	}
+	return nil
+}
+
+// StartDeploymentRollback records the rollback of the deployment, unless
+// already recorded: only the first of concurrent callers gets true.
+func (db *DataStoreMongo) StartDeploymentRollback(
+	ctx context.Context,
+	id string,
+	triggered time.Time,
+) (bool, error) {
+	if len(id) == 0 {
+		return false, ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	res, err := collDpl.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: StorageKeyDeploymentRollback, Value: bson.D{
+				{Key: "$exists", Value: false},
+			}},
+		},
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: StorageKeyDeploymentRollback, Value: model.DeploymentRollback{
+				Triggered:   triggered,
+				Deployments: []string{},
+			}},
+		}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// FinishDeploymentRollback records the follow-up deployments of the
+// rollback of the deployment, and the time it finished.
+func (db *DataStoreMongo) FinishDeploymentRollback(
+	ctx context.Context,
+	id string,
+	deploymentIDs []string,
+	finished time.Time,
+) error {
+	if len(id) == 0 {
+		return ErrStorageInvalidID
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	res, err := collDpl.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: StorageKeyDeploymentRollbackDeployments, Value: deploymentIDs},
+			{Key: StorageKeyDeploymentRollbackFinished, Value: finished},
+		}}},
+	)
+	if err != nil {
+		return err
+	} else if res.MatchedCount == 0 {
+		return ErrStorageInvalidID
+	}
+	return nil
+}
+
+// FindUnfinishedDeploymentRollbacks returns the deployments whose rollback
+// was triggered before triggeredBefore and did not finish.
+func (db *DataStoreMongo) FindUnfinishedDeploymentRollbacks(
+	ctx context.Context,
+	triggeredBefore time.Time,
+) ([]*model.Deployment, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	cursor, err := collDpl.Find(ctx, bson.D{
+		{Key: StorageKeyDeploymentRollbackTriggered, Value: bson.D{
+			{Key: "$lt", Value: triggeredBefore},
+		}},
+		{Key: StorageKeyDeploymentRollbackFinished, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	deployments := []*model.Deployment{}
+	if err = cursor.All(ctx, &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+func assignedImageQuery(
+	imageID string,
+	statuses []model.DeviceDeploymentStatus,
+) bson.D {
+	query := bson.D{
+		{Key: StorageKeyDeviceDeploymentAssignedImageId, Value: imageID},
+		{Key: StorageKeyDeviceDeploymentStatus, Value: bson.D{
+			{Key: "$in", Value: statuses},
+		}},
+		{Key: StorageKeyDeviceDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+	// the index on the assigned image only covers the active device
+	// deployments, and is used when the query says so
+	for _, status := range statuses {
+		if !status.Active() {
+			return query
+		}
+	}
+	return append(query, bson.E{Key: StorageKeyDeviceDeploymentActive, Value: true})
+}
+
+// ExistAssignedImageWithIDAndStatuses checks if the image is assigned to a
+// device deployment in one of the statuses.
+func (db *DataStoreMongo) ExistAssignedImageWithIDAndStatuses(
+	ctx context.Context,
+	imageID string,
+	statuses []model.DeviceDeploymentStatus,
+) (bool, error) {
+	if len(imageID) == 0 {
+		return false, ErrStorageInvalidID
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	var tmp interface{}
+	err := collDevs.FindOne(ctx,
+		assignedImageQuery(imageID, statuses),
+		mopts.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Decode(&tmp)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClearAssignedImage unassigns the image from the device deployments in the
+// statuses, returning their number.
+func (db *DataStoreMongo) ClearAssignedImage(
+	ctx context.Context,
+	imageID string,
+	statuses []model.DeviceDeploymentStatus,
+) (int64, error) {
+	if len(imageID) == 0 {
+		return 0, ErrStorageInvalidID
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	res, err := collDevs.UpdateMany(ctx,
+		assignedImageQuery(imageID, statuses),
+		bson.D{{Key: "$unset", Value: bson.D{
+			{Key: StorageKeyDeviceDeploymentAssignedImage, Value: ""},
+		}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
 }
 
 // ExistUnfinishedByArtifactId checks if there is an active deployment that uses
@@ -2800,7 +5294,7 @@ func (db *DataStoreMongo) ExistUnfinishedByArtifactId(ctx context.Context,
 		return false, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var tmp interface{}
@@ -2827,7 +5321,7 @@ func (db *DataStoreMongo) ExistUnfinishedByArtifactName(ctx context.Context,
 		return false, ErrImagesStorageInvalidArtifactName
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var tmp interface{}
@@ -2852,6 +5346,44 @@ func (db *DataStoreMongo) ExistUnfinishedByArtifactName(ctx context.Context,
 	return true, nil
 }
 
+// FindUnfinishedIDsByArtifactName returns the IDs of up to limit unfinished
+// deployments of the artifact, oldest first.
+func (db *DataStoreMongo) FindUnfinishedIDsByArtifactName(ctx context.Context,
+	artifactName string, limit int) ([]string, error) {
+
+	if len(artifactName) == 0 {
+		return nil, ErrImagesStorageInvalidArtifactName
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	query := bson.D{
+		{Key: StorageKeyDeploymentFinished, Value: nil},
+		{Key: StorageKeyDeploymentArtifactName, Value: artifactName},
+	}
+	findOptions := mopts.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := collDpl.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var deployments []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &deployments); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(deployments))
+	for i, deployment := range deployments {
+		ids[i] = deployment.ID
+	}
+	return ids, nil
+}
+
 // ExistByArtifactId check if there is any deployment that uses give artifact
 func (db *DataStoreMongo) ExistByArtifactId(ctx context.Context,
 	id string) (bool, error) {
@@ -2860,7 +5392,7 @@ func (db *DataStoreMongo) ExistByArtifactId(ctx context.Context,
 		return false, ErrStorageInvalidID
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	var tmp interface{}
@@ -2879,7 +5411,7 @@ func (db *DataStoreMongo) ExistByArtifactId(ctx context.Context,
 
 // Per-tenant storage settings
 func (db *DataStoreMongo) GetStorageSettings(ctx context.Context) (*model.StorageSettings, error) {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collection := database.Collection(CollectionStorageSettings)
 
 	settings := new(model.StorageSettings)
@@ -2902,7 +5434,7 @@ func (db *DataStoreMongo) SetStorageSettings(
 	storageSettings *model.StorageSettings,
 ) error {
 	var err error
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collection := database.Collection(CollectionStorageSettings)
 
 	filter := bson.M{
@@ -2924,7 +5456,7 @@ func (db *DataStoreMongo) UpdateDeploymentsWithArtifactName(
 	artifactName string,
 	artifactIDs []string,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	query := bson.D{
@@ -2946,7 +5478,7 @@ func (db *DataStoreMongo) GetDeploymentIDsByArtifactNames(
 	artifactNames []string,
 ) ([]string, error) {
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDpl := database.Collection(CollectionDeployments)
 
 	query := bson.M{
@@ -2984,5 +5516,72 @@ func (db *DataStoreMongo) GetDeploymentIDsByArtifactNames(
 }
 
 func (db *DataStoreMongo) GetTenantDbs() ([]string, error) {
-	return migrate.GetTenantDbs(context.Background(), db.client, mstore.IsTenantDb(DbName))
+	return migrate.GetTenantDbs(context.Background(), db.client, mstore.IsTenantDb(db.dbName))
+}
+
+// CountActiveDeploymentsPerTenant counts the active deployments in the first
+// limit tenant databases after the one of the given tenant, waiting period
+// between two databases not to load the server; the default database is
+// counted when there is no tenant database.
+func (db *DataStoreMongo) CountActiveDeploymentsPerTenant(
+	ctx context.Context,
+	after string,
+	limit int,
+	period time.Duration,
+) ([]model.TenantActiveDeployments, error) {
+	dbs, err := db.GetTenantDbs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve tenant DBs")
+	} else if len(dbs) == 0 {
+		if after != "" {
+			return []model.TenantActiveDeployments{}, nil
+		}
+		dbs = []string{db.dbName}
+	}
+	// the tenant databases sort in tenant ID order
+	sort.Strings(dbs)
+	if after != "" {
+		afterDb := mstore.DbNameForTenant(after, db.dbName)
+		i := sort.SearchStrings(dbs, afterDb)
+		if i < len(dbs) && dbs[i] == afterDb {
+			i++
+		}
+		dbs = dbs[i:]
+	}
+	if limit > 0 && len(dbs) > limit {
+		dbs = dbs[:limit]
+	}
+
+	var tc <-chan time.Time
+	if period > 0 {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		tc = ticker.C
+	}
+	counts := make([]model.TenantActiveDeployments, 0, len(dbs))
+	for i, dbName := range dbs {
+		if i > 0 && tc != nil {
+			select {
+			case <-tc:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		count, err := db.client.
+			Database(dbName).
+			Collection(CollectionDeployments).
+			CountDocuments(ctx, bson.D{
+				{Key: StorageKeyDeploymentActive, Value: true},
+				{Key: StorageKeyDeploymentDeleted, Value: bson.M{"$exists": false}},
+			})
+		if err != nil {
+			return nil, errors.Wrapf(err,
+				"failed to count the active deployments in %s", dbName)
+		}
+		counts = append(counts, model.TenantActiveDeployments{
+			TenantID:    mstore.TenantFromDbName(dbName, db.dbName),
+			ActiveCount: count,
+		})
+	}
+	return counts, nil
 }
@@ -0,0 +1,80 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// InsertArtifactDeletion appends the record to the artifact deletion audit;
+// the records are never updated nor removed.
+func (db *DataStoreMongo) InsertArtifactDeletion(
+	ctx context.Context,
+	deletion *model.ArtifactDeletion,
+) error {
+	if deletion == nil {
+		return ErrStorageInvalidInput
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDeletions := database.Collection(CollectionArtifactDeletions)
+	if _, err := collDeletions.InsertOne(ctx, deletion); err != nil {
+		return errors.Wrap(err, "mongo: failed to insert artifact deletion")
+	}
+	return nil
+}
+
+// ListArtifactDeletions returns a page of the artifact deletion records,
+// latest first, and the total number of records matching the query.
+func (db *DataStoreMongo) ListArtifactDeletions(
+	ctx context.Context,
+	query model.ArtifactDeletionsQuery,
+) ([]model.ArtifactDeletion, int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDeletions := database.Collection(CollectionArtifactDeletions)
+
+	filter := bson.D{}
+	if query.ArtifactName != "" {
+		filter = append(filter, bson.E{
+			Key: StorageKeyArtifactDeletionArtifactName, Value: query.ArtifactName,
+		})
+	}
+	count, err := collDeletions.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "mongo: failed to count artifact deletions")
+	}
+
+	opts := mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyArtifactDeletionDeleted, Value: -1}}).
+		SetSkip(int64(query.Skip))
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+	cursor, err := collDeletions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "mongo: failed to list artifact deletions")
+	}
+	deletions := []model.ArtifactDeletion{}
+	if err := cursor.All(ctx, &deletions); err != nil {
+		return nil, -1, errors.Wrap(err, "mongo: failed to list artifact deletions")
+	}
+	return deletions, int(count), nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+func TestArtifactDeletions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestArtifactDeletions in short mode.")
+	}
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Subject: "user-1",
+	})
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	images := []*model.Image{{
+		Id:        uuid.NewString(),
+		ImageMeta: &model.ImageMeta{},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"foo"},
+			Updates:               []model.Update{},
+		},
+	}, {
+		Id:        uuid.NewString(),
+		ImageMeta: &model.ImageMeta{},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-2",
+			DeviceTypesCompatible: []string{"foo"},
+			Updates:               []model.Update{},
+		},
+	}}
+	var deletions []*model.ArtifactDeletion
+	for _, image := range images {
+		require.NoError(t, ds.InsertImage(ctx, image))
+		require.NoError(t, ds.DeleteImage(ctx, image.Id))
+		deletion := model.NewArtifactDeletion(ctx, image)
+		deletion.Deleted = deletion.Deleted.Truncate(time.Millisecond).UTC()
+		require.NoError(t, ds.InsertArtifactDeletion(ctx, deletion))
+		deletions = append(deletions, deletion)
+		time.Sleep(time.Millisecond)
+	}
+
+	// the artifacts are gone, their deletion records are not
+	found, err := ds.FindImageByID(ctx, images[0].Id)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	res, count, err := ds.ListArtifactDeletions(ctx, model.ArtifactDeletionsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []model.ArtifactDeletion{*deletions[1], *deletions[0]}, res)
+
+	res, count, err = ds.ListArtifactDeletions(ctx, model.ArtifactDeletionsQuery{
+		ArtifactName: "release-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []model.ArtifactDeletion{*deletions[0]}, res)
+
+	res, count, err = ds.ListArtifactDeletions(ctx, model.ArtifactDeletionsQuery{
+		Skip:  1,
+		Limit: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []model.ArtifactDeletion{*deletions[0]}, res)
+
+	err = ds.InsertArtifactDeletion(ctx, nil)
+	assert.ErrorIs(t, err, ErrStorageInvalidInput)
+}
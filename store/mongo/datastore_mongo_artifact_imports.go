@@ -0,0 +1,138 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+// artifact import jobs are stored in the shared database, together with
+// the owning tenant ID, the same way as upload intents
+func artifactImportJobFilter(ctx context.Context, id string) bson.D {
+	tenantID := ""
+	if idty := identity.FromContext(ctx); idty != nil {
+		tenantID = idty.Tenant
+	}
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: StorageKeyTenantId, Value: tenantID},
+	}
+}
+
+func (db *DataStoreMongo) InsertArtifactImportJob(
+	ctx context.Context,
+	job *model.ArtifactImportJob,
+) error {
+	if job == nil {
+		return ErrStorageInvalidInput
+	}
+	collImports := db.client.
+		Database(db.dbName).
+		Collection(CollectionArtifactImports)
+	if idty := identity.FromContext(ctx); idty != nil {
+		job.TenantID = idty.Tenant
+	}
+	_, err := collImports.InsertOne(ctx, job)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to insert artifact import job")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) UpdateArtifactImportJob(
+	ctx context.Context,
+	job *model.ArtifactImportJob,
+) error {
+	if job == nil {
+		return ErrStorageInvalidInput
+	}
+	collImports := db.client.
+		Database(db.dbName).
+		Collection(CollectionArtifactImports)
+	job.Updated = time.Now()
+	res, err := collImports.UpdateOne(ctx,
+		artifactImportJobFilter(ctx, job.ID),
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: "status", Value: job.Status},
+			{Key: "total", Value: job.Total},
+			{Key: "imported", Value: job.Imported},
+			{Key: "skipped", Value: job.Skipped},
+			{Key: "failed", Value: job.Failed},
+			{Key: "error", Value: job.Error},
+			{Key: "updated", Value: job.Updated},
+		}}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to update artifact import job")
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) FindArtifactImportJobByID(
+	ctx context.Context,
+	id string,
+) (*model.ArtifactImportJob, error) {
+	collImports := db.client.
+		Database(db.dbName).
+		Collection(CollectionArtifactImports)
+
+	var job model.ArtifactImportJob
+	err := collImports.FindOne(ctx, artifactImportJobFilter(ctx, id)).
+		Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to get artifact import job")
+	}
+	return &job, nil
+}
+
+// FailStaleArtifactImportJobs marks the running import jobs of all the
+// tenants not updated since updatedBefore as failed with the given error.
+func (db *DataStoreMongo) FailStaleArtifactImportJobs(
+	ctx context.Context,
+	updatedBefore time.Time,
+	reason string,
+) (int64, error) {
+	collImports := db.client.
+		Database(db.dbName).
+		Collection(CollectionArtifactImports)
+	res, err := collImports.UpdateMany(ctx,
+		bson.D{
+			{Key: "status", Value: model.ArtifactImportStatusRunning},
+			{Key: "updated", Value: bson.D{{Key: "$lt", Value: updatedBefore}}},
+		},
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: "status", Value: model.ArtifactImportStatusFailed},
+			{Key: "error", Value: reason},
+			{Key: "updated", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "mongo: failed to update stale artifact import jobs")
+	}
+	return res.ModifiedCount, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+func TestArtifactImportJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestArtifactImportJobs in short mode.")
+	}
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	otherCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "other",
+	})
+
+	job := model.NewArtifactImportJob("import/")
+	err := ds.InsertArtifactImportJob(ctx, job)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant", job.TenantID)
+
+	job.Total = 3
+	job.Imported = 2
+	job.Failed = 1
+	job.Status = model.ArtifactImportStatusFinished
+	err = ds.UpdateArtifactImportJob(ctx, job)
+	assert.NoError(t, err)
+
+	res, err := ds.FindArtifactImportJobByID(ctx, job.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, job.ID, res.ID)
+		assert.Equal(t, "import/", res.Prefix)
+		assert.Equal(t, model.ArtifactImportStatusFinished, res.Status)
+		assert.Equal(t, 3, res.Total)
+		assert.Equal(t, 2, res.Imported)
+		assert.Equal(t, 1, res.Failed)
+	}
+
+	// jobs are not visible to other tenants
+	_, err = ds.FindArtifactImportJobByID(otherCtx, job.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+	err = ds.UpdateArtifactImportJob(otherCtx, job)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	_, err = ds.FindArtifactImportJobByID(ctx, "missing")
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestFailStaleArtifactImportJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFailStaleArtifactImportJobs in short mode.")
+	}
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	otherCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "other",
+	})
+
+	stale := model.NewArtifactImportJob("import/")
+	assert.NoError(t, ds.InsertArtifactImportJob(ctx, stale))
+	staleOther := model.NewArtifactImportJob("import/")
+	assert.NoError(t, ds.InsertArtifactImportJob(otherCtx, staleOther))
+	finished := model.NewArtifactImportJob("import/")
+	assert.NoError(t, ds.InsertArtifactImportJob(ctx, finished))
+	finished.Status = model.ArtifactImportStatusFinished
+	assert.NoError(t, ds.UpdateArtifactImportJob(ctx, finished))
+	// the update times are stored with millisecond precision
+	time.Sleep(10 * time.Millisecond)
+	updatedBefore := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	running := model.NewArtifactImportJob("import/")
+	assert.NoError(t, ds.InsertArtifactImportJob(ctx, running))
+	assert.NoError(t, ds.UpdateArtifactImportJob(ctx, running))
+
+	failed, err := ds.FailStaleArtifactImportJobs(
+		context.Background(), updatedBefore, "interrupted",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failed)
+
+	for _, tc := range []struct {
+		ctx    context.Context
+		job    *model.ArtifactImportJob
+		status model.ArtifactImportStatus
+	}{
+		{ctx: ctx, job: stale, status: model.ArtifactImportStatusFailed},
+		{ctx: otherCtx, job: staleOther, status: model.ArtifactImportStatusFailed},
+		{ctx: ctx, job: finished, status: model.ArtifactImportStatusFinished},
+		{ctx: ctx, job: running, status: model.ArtifactImportStatusRunning},
+	} {
+		res, err := ds.FindArtifactImportJobByID(tc.ctx, tc.job.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.status, res.Status)
+			if tc.status == model.ArtifactImportStatusFailed {
+				assert.Equal(t, "interrupted", res.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+// export jobs live in the shared database, next to the artifact import jobs
+func exportJobFilter(ctx context.Context, id string) bson.D {
+	tenantID := ""
+	if idty := identity.FromContext(ctx); idty != nil {
+		tenantID = idty.Tenant
+	}
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: StorageKeyTenantId, Value: tenantID},
+	}
+}
+
+func (db *DataStoreMongo) InsertExportJob(
+	ctx context.Context,
+	job *model.ExportJob,
+) error {
+	if job == nil {
+		return ErrStorageInvalidInput
+	}
+	collExports := db.client.
+		Database(db.dbName).
+		Collection(CollectionExports)
+	if idty := identity.FromContext(ctx); idty != nil {
+		job.TenantID = idty.Tenant
+	}
+	_, err := collExports.InsertOne(ctx, job)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to insert export job")
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) UpdateExportJob(
+	ctx context.Context,
+	job *model.ExportJob,
+) error {
+	if job == nil {
+		return ErrStorageInvalidInput
+	}
+	collExports := db.client.
+		Database(db.dbName).
+		Collection(CollectionExports)
+	job.Updated = time.Now()
+	res, err := collExports.UpdateOne(ctx,
+		exportJobFilter(ctx, job.ID),
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: "status", Value: job.Status},
+			{Key: "files", Value: job.Files},
+			{Key: "deployments", Value: job.Deployments},
+			{Key: "device_deployments", Value: job.DeviceDeployments},
+			{Key: "error", Value: job.Error},
+			{Key: "updated", Value: job.Updated},
+			{Key: "finished", Value: job.Finished},
+		}}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to update export job")
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) FindExportJobByID(
+	ctx context.Context,
+	id string,
+) (*model.ExportJob, error) {
+	collExports := db.client.
+		Database(db.dbName).
+		Collection(CollectionExports)
+
+	var job model.ExportJob
+	err := collExports.FindOne(ctx, exportJobFilter(ctx, id)).
+		Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to get export job")
+	}
+	return &job, nil
+}
+
+func (db *DataStoreMongo) StartExportJob(
+	ctx context.Context,
+	id string,
+	staleBefore time.Time,
+) (*model.ExportJob, error) {
+	collExports := db.client.
+		Database(db.dbName).
+		Collection(CollectionExports)
+
+	// a running job without recent updates was interrupted
+	filter := append(exportJobFilter(ctx, id), bson.E{
+		Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{
+				model.ExportStatusPending, model.ExportStatusFailed,
+			}}}}},
+			bson.D{
+				{Key: "status", Value: model.ExportStatusRunning},
+				{Key: "updated", Value: bson.D{{Key: "$lt", Value: staleBefore}}},
+			},
+		},
+	})
+	// a retried job starts over: the files are overwritten
+	update := bson.D{{Key: mongoOpSet, Value: bson.D{
+		{Key: "status", Value: model.ExportStatusRunning},
+		{Key: "files", Value: bson.A{}},
+		{Key: "deployments", Value: 0},
+		{Key: "device_deployments", Value: 0},
+		{Key: "error", Value: ""},
+		{Key: "updated", Value: time.Now()},
+	}}}
+	var job model.ExportJob
+	err := collExports.FindOneAndUpdate(ctx, filter, update,
+		mopts.FindOneAndUpdate().SetReturnDocument(mopts.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to start export job")
+	}
+	return &job, nil
+}
+
+func (db *DataStoreMongo) TouchExportJob(ctx context.Context, id string) error {
+	collExports := db.client.
+		Database(db.dbName).
+		Collection(CollectionExports)
+
+	filter := append(exportJobFilter(ctx, id), bson.E{
+		Key: "status", Value: model.ExportStatusRunning,
+	})
+	res, err := collExports.UpdateOne(ctx, filter,
+		bson.D{{Key: mongoOpSet, Value: bson.D{
+			{Key: "updated", Value: time.Now()},
+		}}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to update export job")
+	} else if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (db *DataStoreMongo) IterateDeployments(
+	ctx context.Context,
+) (store.Iterator[model.Deployment], error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	cur, err := collDpl.Find(ctx, bson.D{}, mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}).
+		SetProjection(bson.D{{Key: StorageKeyDeploymentDeviceList, Value: 0}}).
+		SetAllowDiskUse(true),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to find deployments")
+	}
+	return IteratorFromCursor[model.Deployment](cur), nil
+}
+
+// createdBetweenFilter selects the deployments, not deleted, created in
+// [from, to).
+func createdBetweenFilter(from, to time.Time) bson.D {
+	return bson.D{
+		{Key: StorageKeyDeploymentCreated, Value: bson.D{
+			{Key: "$gte", Value: from},
+			{Key: "$lt", Value: to},
+		}},
+		{Key: StorageKeyDeploymentDeleted, Value: bson.D{
+			{Key: "$exists", Value: false},
+		}},
+	}
+}
+
+func (db *DataStoreMongo) FindDeploymentsCreatedBetween(
+	ctx context.Context,
+	from, to time.Time,
+	skip, limit int,
+) (store.Iterator[model.DeploymentReport], error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	pipeline := []bson.D{
+		{{Key: "$match", Value: createdBetweenFilter(from, to)}},
+		{{Key: "$sort", Value: bson.D{{Key: StorageKeyDeploymentCreated, Value: 1}}}},
+	}
+	if skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: skip}})
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.D{
+		{Key: "name", Value: "$" + StorageKeyDeploymentName},
+		{Key: "artifact_name", Value: "$" + StorageKeyDeploymentArtifactName},
+		{Key: "type", Value: "$" + StorageKeyDeploymentType},
+		{Key: "status", Value: "$" + StorageKeyDeploymentStatus},
+		{Key: "created", Value: "$" + StorageKeyDeploymentCreated},
+		{Key: "finished", Value: "$" + StorageKeyDeploymentFinished},
+		{Key: "device_count", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			"$" + StorageKeyDeploymentDeviceCount, 0,
+		}}}},
+		{Key: "max_devices", Value: "$" + StorageKeyDeploymentMaxDevices},
+		{Key: "stats", Value: "$" + StorageKeyDeploymentStats},
+		{Key: "created_by", Value: "$" + StorageKeyDeploymentCreatedBy},
+		{Key: "aborted_by", Value: "$" + StorageKeyDeploymentAbortedBy},
+		{Key: "aborted", Value: "$" + StorageKeyDeploymentAborted},
+	}}})
+
+	// the results are streamed from the cursor: no need to hold large
+	// ranges in memory
+	cur, err := collDpl.Aggregate(ctx, pipeline,
+		mopts.Aggregate().SetHint(IndexDeploymentCreatedName),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to aggregate deployments")
+	}
+	return IteratorFromCursor[model.DeploymentReport](cur), nil
+}
+
+func (db *DataStoreMongo) IterateDeviceDeployments(
+	ctx context.Context,
+) (store.Iterator[model.DeviceDeployment], error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+
+	// there is no index on the creation time alone: let the server spill
+	// the sort to disk instead of failing on large collections
+	cur, err := collDevs.Find(ctx, bson.D{}, mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyDeviceDeploymentCreated, Value: 1}}).
+		SetAllowDiskUse(true),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to find device deployments")
+	}
+	return IteratorFromCursor[model.DeviceDeployment](cur), nil
+}
+
+func (db *DataStoreMongo) CountDeploymentsCreatedBetween(
+	ctx context.Context,
+	from, to time.Time,
+) (int, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	count, err := collDpl.CountDocuments(ctx, createdBetweenFilter(from, to),
+		mopts.Count().SetHint(IndexDeploymentCreatedName),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "mongo: failed to count deployments")
+	}
+	return int(count), nil
+}
@@ -0,0 +1,328 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
+)
+
+func TestExportJobs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestExportJobs in short mode.")
+	}
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "tenant",
+	})
+	otherCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "other",
+	})
+
+	job := model.NewExportJob()
+	job.Prefix = "tenant/exports/" + job.ID
+	err := ds.InsertExportJob(ctx, job)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant", job.TenantID)
+
+	// jobs are not visible to other tenants
+	_, err = ds.StartExportJob(otherCtx, job.ID, time.Now())
+	assert.Equal(t, store.ErrNotFound, err)
+	_, err = ds.FindExportJobByID(otherCtx, job.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	err = ds.TouchExportJob(ctx, job.ID)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	started, err := ds.StartExportJob(ctx, job.ID, time.Now().Add(-time.Hour))
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ExportStatusRunning, started.Status)
+	}
+	// a running job cannot be started twice
+	_, err = ds.StartExportJob(ctx, job.ID, time.Now().Add(-time.Hour))
+	assert.Equal(t, store.ErrNotFound, err)
+	assert.NoError(t, ds.TouchExportJob(ctx, job.ID))
+	_, err = ds.StartExportJob(ctx, job.ID, started.Updated)
+	assert.Equal(t, store.ErrNotFound, err)
+	// unless it was interrupted
+	restarted, err := ds.StartExportJob(ctx, job.ID, time.Now().Add(time.Second))
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ExportStatusRunning, restarted.Status)
+	}
+
+	job.Status = model.ExportStatusFailed
+	job.Files = []string{"exports/job/deployments/date=2024-01-01/part-00000.parquet"}
+	job.Deployments = 2
+	job.Error = "storage unavailable"
+	err = ds.UpdateExportJob(ctx, job)
+	assert.NoError(t, err)
+
+	res, err := ds.FindExportJobByID(ctx, job.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ExportStatusFailed, res.Status)
+		assert.Equal(t, job.Files, res.Files)
+		assert.Equal(t, 2, res.Deployments)
+		assert.Equal(t, "storage unavailable", res.Error)
+	}
+
+	// failed jobs are retried from scratch
+	started, err = ds.StartExportJob(ctx, job.ID, time.Now().Add(-time.Hour))
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ExportStatusRunning, started.Status)
+		assert.Empty(t, started.Files)
+		assert.Zero(t, started.Deployments)
+		assert.Empty(t, started.Error)
+	}
+}
+
+func TestIterateDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestIterateDeployments in short mode.")
+	}
+	const (
+		d1 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d1"
+		d2 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d2"
+	)
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for i, id := range []string{d2, d1} {
+		dpl := &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "name",
+				ArtifactName: "artifact",
+			},
+			Id:         id,
+			Created:    TimePtr(now.Add(-time.Duration(i) * time.Hour)),
+			DeviceList: []string{"dev1"},
+		}
+		require.NoError(t, ds.InsertDeployment(ctx, dpl))
+		dd := model.NewDeviceDeployment("dev1", id)
+		dd.Created = dpl.Created
+		require.NoError(t, ds.InsertDeviceDeployment(ctx, dd, false))
+	}
+
+	it, err := ds.IterateDeployments(ctx)
+	require.NoError(t, err)
+	var ids []string
+	for {
+		next, err := it.Next(ctx)
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		var dpl model.Deployment
+		require.NoError(t, it.Decode(&dpl))
+		assert.Empty(t, dpl.DeviceList)
+		ids = append(ids, dpl.Id)
+	}
+	assert.NoError(t, it.Close(ctx))
+	assert.Equal(t, []string{d1, d2}, ids)
+
+	itDevs, err := ds.IterateDeviceDeployments(ctx)
+	require.NoError(t, err)
+	ids = nil
+	for {
+		next, err := itDevs.Next(ctx)
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		var dd model.DeviceDeployment
+		require.NoError(t, itDevs.Decode(&dd))
+		ids = append(ids, dd.DeploymentId)
+	}
+	assert.NoError(t, itDevs.Close(ctx))
+	assert.Equal(t, []string{d1, d2}, ids)
+}
+
+func TestFindDeploymentsCreatedBetween(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindDeploymentsCreatedBetween in short mode.")
+	}
+	const (
+		d0 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d0"
+		d1 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d1"
+		d2 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d2"
+		d3 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d3"
+		d4 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d4"
+	)
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	// the query is hinted to the creation time index
+	require.NoError(t, ds.EnsureIndexes(
+		DatabaseName, CollectionDeployments, DeploymentCreatedIndex,
+	))
+
+	deviceCount := 2
+	for i, id := range []string{d0, d1, d2, d3, d4} {
+		dpl := &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "name-" + id,
+				ArtifactName: "artifact",
+			},
+			Id:          id,
+			Created:     TimePtr(now.Add(-time.Duration(i) * time.Hour)),
+			Status:      model.DeploymentStatusFinished,
+			Type:        model.DeploymentTypeSoftware,
+			DeviceList:  []string{"dev1", "dev2"},
+			DeviceCount: &deviceCount,
+			MaxDevices:  2,
+			Stats: model.Stats{
+				model.DeviceDeploymentStatusSuccessStr: 1,
+				model.DeviceDeploymentStatusFailureStr: 1,
+			},
+		}
+		require.NoError(t, ds.InsertDeployment(ctx, dpl))
+	}
+	require.NoError(t, ds.DeleteDeployment(ctx, d2))
+
+	collect := func(skip, limit int) []model.DeploymentReport {
+		it, err := ds.FindDeploymentsCreatedBetween(ctx,
+			now.Add(-4*time.Hour), now, skip, limit,
+		)
+		require.NoError(t, err)
+		defer it.Close(ctx)
+		var reports []model.DeploymentReport
+		for {
+			next, err := it.Next(ctx)
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			var report model.DeploymentReport
+			require.NoError(t, it.Decode(&report))
+			reports = append(reports, report)
+		}
+		return reports
+	}
+	ids := func(reports []model.DeploymentReport) []string {
+		var ids []string
+		for _, report := range reports {
+			ids = append(ids, report.ID)
+		}
+		return ids
+	}
+
+	// d0 is created at the end of the range, d2 is deleted
+	reports := collect(0, 0)
+	assert.Equal(t, []string{d4, d3, d1}, ids(reports))
+	if assert.NotEmpty(t, reports) {
+		assert.Equal(t, model.DeploymentReport{
+			ID:           d4,
+			Name:         "name-" + d4,
+			ArtifactName: "artifact",
+			Type:         model.DeploymentTypeSoftware,
+			Status:       model.DeploymentStatusFinished,
+			Created:      now.Add(-4 * time.Hour),
+			DeviceCount:  2,
+			MaxDevices:   2,
+			Stats: model.Stats{
+				model.DeviceDeploymentStatusSuccessStr: 1,
+				model.DeviceDeploymentStatusFailureStr: 1,
+			},
+		}, reports[0])
+	}
+
+	assert.Equal(t, []string{d3}, ids(collect(1, 1)))
+}
+
+func TestComplianceReportFields(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestComplianceReportFields in short mode.")
+	}
+	const (
+		d0 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d0"
+		d1 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d1"
+		d2 = "6d4f6e27-c3bb-438c-ad9c-d9de30e590d2"
+	)
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, ds.EnsureIndexes(
+		DatabaseName, CollectionDeployments, DeploymentCreatedIndex,
+	))
+
+	for i, id := range []string{d0, d1, d2} {
+		dpl := &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "name-" + id,
+				ArtifactName: "artifact",
+			},
+			Id:        id,
+			Created:   TimePtr(now.Add(-time.Duration(i+1) * time.Hour)),
+			Status:    model.DeploymentStatusInProgress,
+			CreatedBy: "creator",
+		}
+		require.NoError(t, ds.InsertDeployment(ctx, dpl))
+	}
+	require.NoError(t, ds.DeleteDeployment(ctx, d2))
+
+	aborted := now.Add(-time.Minute)
+	assert.NoError(t, ds.SetDeploymentAborted(ctx, d1, "aborter", aborted))
+	assert.Equal(t, ErrStorageInvalidID,
+		ds.SetDeploymentAborted(ctx, "missing", "aborter", aborted))
+	assert.Equal(t, ErrStorageInvalidID,
+		ds.SetDeploymentAborted(ctx, "", "aborter", aborted))
+
+	count, err := ds.CountDeploymentsCreatedBetween(ctx, now.Add(-3*time.Hour), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	it, err := ds.FindDeploymentsCreatedBetween(ctx, now.Add(-3*time.Hour), now, 0, 0)
+	require.NoError(t, err)
+	defer it.Close(ctx)
+	var reports []model.DeploymentReport
+	for {
+		next, err := it.Next(ctx)
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		var report model.DeploymentReport
+		require.NoError(t, it.Decode(&report))
+		reports = append(reports, report)
+	}
+	if assert.Len(t, reports, 2) {
+		assert.Equal(t, d1, reports[0].ID)
+		assert.Equal(t, "creator", reports[0].CreatedBy)
+		assert.Equal(t, "aborter", reports[0].AbortedBy)
+		if assert.NotNil(t, reports[0].Aborted) {
+			assert.Equal(t, aborted, reports[0].Aborted.UTC())
+		}
+		assert.Equal(t, d0, reports[1].ID)
+		assert.Equal(t, "creator", reports[1].CreatedBy)
+		assert.Empty(t, reports[1].AbortedBy)
+		assert.Nil(t, reports[1].Aborted)
+	}
+}
@@ -51,7 +51,7 @@ func (db *DataStoreMongo) SaveLastDeviceDeploymentStatus(
 		TenantId:               tenantId,
 	}
 
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevicesLastStatus)
 	var err error
 	replaceOptions := mopts.Replace()
@@ -64,7 +64,7 @@ func (db *DataStoreMongo) GetLastDeviceDeploymentStatus(
 	ctx context.Context,
 	devicesIds []string,
 ) ([]model.DeviceDeploymentLastStatus, error) {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionDevicesLastStatus)
 
 	tenantId := ""
@@ -0,0 +1,84 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maintenanceModeID is the ID of the single document holding the
+// maintenance mode in the shared database
+const maintenanceModeID = "maintenance_mode"
+
+type maintenanceMode struct {
+	Enabled bool `bson:"enabled"`
+}
+
+// GetMaintenanceMode returns whether the service is in maintenance mode;
+// it is not unless set.
+func (db *DataStoreMongo) GetMaintenanceMode(ctx context.Context) (bool, error) {
+	collMaintenance := db.client.
+		Database(db.dbName).
+		Collection(CollectionMaintenanceMode)
+
+	var mode maintenanceMode
+	err := collMaintenance.FindOne(ctx, bson.D{{Key: "_id", Value: maintenanceModeID}}).
+		Decode(&mode)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "mongo: failed to get the maintenance mode")
+	}
+	return mode.Enabled, nil
+}
+
+// SetMaintenanceMode enables or disables the maintenance mode.
+func (db *DataStoreMongo) SetMaintenanceMode(ctx context.Context, enabled bool) error {
+	collMaintenance := db.client.
+		Database(db.dbName).
+		Collection(CollectionMaintenanceMode)
+
+	_, err := collMaintenance.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: maintenanceModeID}},
+		bson.D{{Key: mongoOpSet, Value: bson.D{{Key: "enabled", Value: enabled}}}},
+		mopts.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to set the maintenance mode")
+	}
+	return nil
+}
+
+// InitMaintenanceMode sets the maintenance mode unless it is set already.
+func (db *DataStoreMongo) InitMaintenanceMode(ctx context.Context, enabled bool) error {
+	collMaintenance := db.client.
+		Database(db.dbName).
+		Collection(CollectionMaintenanceMode)
+
+	_, err := collMaintenance.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: maintenanceModeID}},
+		bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "enabled", Value: enabled}}}},
+		mopts.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo: failed to initialize the maintenance mode")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMaintenanceMode in short mode.")
+	}
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	// the mode is shared by all the tenants
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: "tenant"})
+
+	enabled, err := ds.GetMaintenanceMode(ctx)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	err = ds.SetMaintenanceMode(tenantCtx, true)
+	assert.NoError(t, err)
+	enabled, err = ds.GetMaintenanceMode(ctx)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	err = ds.SetMaintenanceMode(ctx, false)
+	assert.NoError(t, err)
+	enabled, err = ds.GetMaintenanceMode(tenantCtx)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	// initializing the mode does not override the one set
+	err = ds.InitMaintenanceMode(ctx, true)
+	assert.NoError(t, err)
+	enabled, err = ds.GetMaintenanceMode(ctx)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestInitMaintenanceMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestInitMaintenanceMode in short mode.")
+	}
+	db.Wipe()
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	err := ds.InitMaintenanceMode(ctx, true)
+	assert.NoError(t, err)
+	enabled, err := ds.GetMaintenanceMode(ctx)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	err = ds.InitMaintenanceMode(ctx, false)
+	assert.NoError(t, err)
+	enabled, err = ds.GetMaintenanceMode(ctx)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+}
@@ -15,6 +15,7 @@ package mongo
 
 import (
 	"context"
+	"reflect"
 	"time"
 
 	"github.com/pkg/errors"
@@ -35,7 +36,7 @@ func (db *DataStoreMongo) UpdateReleaseArtifactDescription(
 	artifactToEdit *model.Image,
 	releaseName string,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collReleases := database.Collection(CollectionReleases)
 
 	update := bson.M{
@@ -65,7 +66,7 @@ func (db *DataStoreMongo) UpdateReleaseArtifacts(
 	artifactToRemove *model.Image,
 	releaseName string,
 ) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collReleases := database.Collection(CollectionReleases)
 
 	opt := &mopts.UpdateOptions{}
@@ -115,10 +116,22 @@ func (db *DataStoreMongo) UpdateReleaseArtifacts(
 	return nil
 }
 
+// CountReleases returns the number of releases.
+func (db *DataStoreMongo) CountReleases(ctx context.Context) (int64, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReleases := database.Collection(CollectionReleases)
+
+	count, err := collReleases.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, errors.WithMessage(err, "mongo: failed to count releases")
+	}
+	return count, nil
+}
+
 func (db *DataStoreMongo) ListReleaseTags(ctx context.Context) (model.Tags, error) {
 	l := log.FromContext(ctx)
 	tagKeys, err := db.client.
-		Database(mstore.DbFromContext(ctx, DatabaseName)).
+		Database(mstore.DbFromContext(ctx, db.dbName)).
 		Collection(CollectionReleases).
 		Distinct(ctx, StorageKeyReleaseTags, bson.D{})
 	if err != nil {
@@ -149,7 +162,7 @@ func (db *DataStoreMongo) ReplaceReleaseTags(
 	}
 
 	collReleases := db.client.
-		Database(mstore.DbFromContext(ctx, DatabaseName)).
+		Database(mstore.DbFromContext(ctx, db.dbName)).
 		Collection(CollectionReleases)
 
 	// Check if added tags will exceed limits
@@ -174,8 +187,11 @@ func (db *DataStoreMongo) ReplaceReleaseTags(
 	res, err := collReleases.UpdateOne(ctx, bson.D{{
 		Key: StorageKeyReleaseName, Value: releaseName,
 	}}, bson.D{{
-		Key:   mongoOpSet,
-		Value: bson.D{{Key: StorageKeyReleaseTags, Value: tags}},
+		Key: mongoOpSet,
+		Value: bson.D{
+			{Key: StorageKeyReleaseTags, Value: tags},
+			{Key: StorageKeyReleaseModified, Value: time.Now()},
+		},
 	}})
 	if err != nil {
 		return errors.WithMessage(err, "mongo: failed to update release tags")
@@ -191,7 +207,7 @@ func (db *DataStoreMongo) UpdateRelease(
 	release model.ReleasePatch,
 ) error {
 	collReleases := db.client.
-		Database(mstore.DbFromContext(ctx, DatabaseName)).
+		Database(mstore.DbFromContext(ctx, db.dbName)).
 		Collection(CollectionReleases)
 
 	err := release.Validate()
@@ -216,6 +232,9 @@ func (db *DataStoreMongo) UpdateRelease(
 					{
 						Key: StorageKeyReleaseNotes, Value: release.Notes,
 					},
+					{
+						Key: StorageKeyReleaseModified, Value: time.Now(),
+					},
 				},
 			},
 		},
@@ -230,7 +249,7 @@ func (db *DataStoreMongo) UpdateRelease(
 
 // Save the possibly new update types
 func (db *DataStoreMongo) SaveUpdateTypes(ctx context.Context, updateTypes []string) error {
-	database := db.client.Database(DatabaseName)
+	database := db.client.Database(db.dbName)
 	c := database.Collection(CollectionUpdateTypes)
 
 	if len(updateTypes) < 1 {
@@ -262,7 +281,7 @@ func (db *DataStoreMongo) SaveUpdateTypes(ctx context.Context, updateTypes []str
 
 // Get the update types
 func (db *DataStoreMongo) GetUpdateTypes(ctx context.Context) ([]string, error) {
-	database := db.client.Database(DatabaseName)
+	database := db.client.Database(db.dbName)
 	c := database.Collection(CollectionUpdateTypes)
 
 	tenantId := ""
@@ -290,8 +309,64 @@ func (db *DataStoreMongo) GetUpdateTypes(ctx context.Context) ([]string, error)
 	}
 }
 
+// GetUpdateTypesWithCounts counts the releases containing at least one
+// artifact of each update type.
+func (db *DataStoreMongo) GetUpdateTypesWithCounts(
+	ctx context.Context,
+) (map[string]int, error) {
+	collReleases := db.client.
+		Database(mstore.DbFromContext(ctx, db.dbName)).
+		Collection(CollectionReleases)
+
+	const keyUpdateType = "update_type"
+	pipeline := []bson.D{
+		// matching on the indexed update type lets the planner use the
+		// sparse release_update_types index to skip the releases
+		// without any update type
+		{{Key: "$match", Value: bson.D{{
+			Key:   StorageKeyReleaseArtifactsUpdateTypes,
+			Value: bson.D{{Key: "$exists", Value: true}},
+		}}}},
+		{{Key: "$unwind", Value: "$" + StorageKeyReleaseArtifacts}},
+		{{Key: "$unwind", Value: "$" + StorageKeyReleaseArtifacts + ".meta_artifact.updates"}},
+		// one document per (release, update type) so that releases with
+		// several artifacts of the same type are counted once
+		{{Key: "$group", Value: bson.D{{
+			Key: "_id", Value: bson.D{
+				{Key: "release", Value: "$" + StorageKeyReleaseName},
+				{Key: keyUpdateType, Value: "$" + StorageKeyReleaseArtifactsUpdateTypes},
+			},
+		}}}},
+		{{Key: "$match", Value: bson.D{{
+			Key: "_id." + keyUpdateType, Value: bson.D{{Key: "$type", Value: "string"}},
+		}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$_id." + keyUpdateType},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cursor, err := collReleases.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.WithMessage(err,
+			"mongo: failed to aggregate release update types")
+	}
+	var results []struct {
+		UpdateType string `bson:"_id"`
+		Count      int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, errors.WithMessage(err,
+			"mongo: failed to decode release update types")
+	}
+	counts := make(map[string]int, len(results))
+	for _, result := range results {
+		counts[result.UpdateType] = result.Count
+	}
+	return counts, nil
+}
+
 func (db *DataStoreMongo) DeleteReleasesByNames(ctx context.Context, names []string) error {
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
 	collDevs := database.Collection(CollectionReleases)
 	query := bson.M{
 		StorageKeyReleaseName: bson.M{
@@ -301,3 +376,229 @@ func (db *DataStoreMongo) DeleteReleasesByNames(ctx context.Context, names []str
 	_, err := collDevs.DeleteMany(ctx, query)
 	return err
 }
+
+// RebuildReleases streams the artifacts ordered by name and compares every
+// group of artifacts sharing a name with the release of that name: missing
+// releases are created, releases with different artifacts are updated and
+// releases without artifacts are removed. Tags and notes are preserved.
+func (db *DataStoreMongo) RebuildReleases(
+	ctx context.Context,
+	dryRun bool,
+) (*model.ReleasesRebuild, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collImages := database.Collection(CollectionImages)
+	collReleases := database.Collection(CollectionReleases)
+
+	result := new(model.ReleasesRebuild)
+	seen := make(map[string]struct{})
+	rebuild := func(name string, artifacts []model.Image) error {
+		seen[name] = struct{}{}
+		var release model.Release
+		err := collReleases.FindOne(ctx,
+			bson.M{StorageKeyReleaseName: name},
+		).Decode(&release)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			result.Created = append(result.Created, name)
+		case err != nil:
+			return errors.WithMessage(err, "mongo: failed to get release")
+		case releaseArtifactsEqual(&release, artifacts):
+			return nil
+		default:
+			result.Updated = append(result.Updated, name)
+		}
+		if dryRun {
+			return nil
+		}
+		_, err = collReleases.UpdateOne(ctx,
+			bson.M{StorageKeyReleaseName: name},
+			bson.M{"$set": bson.M{
+				StorageKeyReleaseArtifacts:      artifacts,
+				StorageKeyReleaseArtifactsCount: len(artifacts),
+				StorageKeyReleaseModified:       time.Now(),
+			}},
+			mopts.Update().SetUpsert(true),
+		)
+		return errors.WithMessage(err, "mongo: failed to update release")
+	}
+
+	cursor, err := collImages.Find(ctx, bson.M{},
+		mopts.Find().SetSort(bson.D{
+			{Key: StorageKeyImageName, Value: 1},
+			{Key: StorageKeyId, Value: 1},
+		}),
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to list artifacts")
+	}
+	defer cursor.Close(ctx)
+	var (
+		name      string
+		artifacts []model.Image
+	)
+	for cursor.Next(ctx) {
+		var image model.Image
+		if err = cursor.Decode(&image); err != nil {
+			return nil, errors.WithMessage(err, "mongo: failed to decode artifact")
+		}
+		if image.ArtifactMeta == nil {
+			continue
+		}
+		if image.ArtifactMeta.Name != name && len(artifacts) > 0 {
+			if err = rebuild(name, artifacts); err != nil {
+				return nil, err
+			}
+			artifacts = nil
+		}
+		name = image.ArtifactMeta.Name
+		artifacts = append(artifacts, image)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to list artifacts")
+	}
+	if len(artifacts) > 0 {
+		if err = rebuild(name, artifacts); err != nil {
+			return nil, err
+		}
+	}
+
+	names, err := collReleases.Distinct(ctx, StorageKeyReleaseName, bson.M{})
+	if err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to list releases")
+	}
+	for _, elem := range names {
+		if name, ok := elem.(string); ok {
+			if _, ok := seen[name]; !ok {
+				result.Removed = append(result.Removed, name)
+			}
+		}
+	}
+	if len(result.Removed) > 0 && !dryRun {
+		if err = db.DeleteReleasesByNames(ctx, result.Removed); err != nil {
+			return nil, errors.WithMessage(err, "mongo: failed to remove releases")
+		}
+	}
+	return result, nil
+}
+
+// releaseArtifactsEqual returns true if the release holds exactly the given
+// artifacts, in any order.
+func releaseArtifactsEqual(release *model.Release, artifacts []model.Image) bool {
+	if release.ArtifactsCount != len(artifacts) ||
+		len(release.Artifacts) != len(artifacts) {
+		return false
+	}
+	byID := make(map[string]model.Image, len(release.Artifacts))
+	for _, artifact := range release.Artifacts {
+		byID[artifact.Id] = artifact
+	}
+	for _, artifact := range artifacts {
+		existing, ok := byID[artifact.Id]
+		if !ok || !reflect.DeepEqual(existing, artifact) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetRelease returns the release with the given name. If artifactsSort is
+// model.SortDirectionAscending or model.SortDirectionDescending, the
+// artifacts of the release are ordered by size accordingly; otherwise they
+// are returned in insertion order.
+func (db *DataStoreMongo) GetRelease(
+	ctx context.Context,
+	name string,
+	artifactsSort string,
+) (*model.Release, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReleases := database.Collection(CollectionReleases)
+
+	pipe := []bson.D{
+		{{Key: "$match", Value: bson.M{StorageKeyReleaseName: name}}},
+		{{Key: "$project", Value: bson.M{
+			StorageKeyReleaseImageDependsIdx:  0,
+			StorageKeyReleaseImageProvidesIdx: 0,
+		}}},
+	}
+	if artifactsSort == model.SortDirectionAscending ||
+		artifactsSort == model.SortDirectionDescending {
+		sortOrder := 1
+		if artifactsSort == model.SortDirectionDescending {
+			sortOrder = -1
+		}
+		pipe = append(pipe,
+			bson.D{{Key: "$unwind", Value: bson.M{
+				"path":                       "$" + StorageKeyReleaseArtifacts,
+				"preserveNullAndEmptyArrays": true,
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{
+				{Key: StorageKeyReleaseArtifacts + "." + StorageKeyImageSize,
+					Value: sortOrder},
+				{Key: StorageKeyReleaseArtifactsId, Value: 1},
+			}}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$" + StorageKeyReleaseName},
+				{Key: StorageKeyReleaseModified,
+					Value: bson.M{"$first": "$" + StorageKeyReleaseModified}},
+				{Key: StorageKeyReleaseArtifactsCount,
+					Value: bson.M{"$first": "$" + StorageKeyReleaseArtifactsCount}},
+				{Key: StorageKeyReleaseTags,
+					Value: bson.M{"$first": "$" + StorageKeyReleaseTags}},
+				{Key: StorageKeyReleaseNotes,
+					Value: bson.M{"$first": "$" + StorageKeyReleaseNotes}},
+				{Key: StorageKeyReleaseArtifacts,
+					Value: bson.M{"$push": "$" + StorageKeyReleaseArtifacts}},
+			}}},
+		)
+	}
+
+	cursor, err := collReleases.Aggregate(ctx, pipe)
+	if err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to get release")
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err = cursor.Err(); err != nil {
+			return nil, errors.WithMessage(err, "mongo: failed to get release")
+		}
+		return nil, store.ErrNotFound
+	}
+	release := new(model.Release)
+	if err = cursor.Decode(release); err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to decode release")
+	}
+	return release, nil
+}
+
+// GetReleaseForArtifact returns the release containing the artifact with the
+// given ID.
+func (db *DataStoreMongo) GetReleaseForArtifact(
+	ctx context.Context,
+	artifactID string,
+) (*model.Release, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReleases := database.Collection(CollectionReleases)
+
+	opts := mopts.FindOne().SetProjection(bson.M{
+		StorageKeyReleaseImageDependsIdx:  0,
+		StorageKeyReleaseImageProvidesIdx: 0,
+	})
+	release := new(model.Release)
+	err := collReleases.FindOne(ctx,
+		bson.M{StorageKeyReleaseArtifactsId: artifactID},
+		opts,
+	).Decode(release)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, store.ErrNotFound
+	} else if err != nil {
+		return nil, errors.WithMessage(err, "mongo: failed to get release")
+	}
+	return release, nil
+}
@@ -417,6 +417,7 @@ func TestGetReleases_1_2_15(t *testing.T) {
 		time.Sleep(time.Millisecond * 10)
 	}
 
+	one, two := 1, 2
 	testCases := map[string]struct {
 		releaseFilt *model.ReleaseOrImageFilter
 
@@ -662,6 +663,45 @@ func TestGetReleases_1_2_15(t *testing.T) {
 				},
 			},
 		},
+		"ok, artifacts count min": {
+			releaseFilt: &model.ReleaseOrImageFilter{
+				ArtifactsCountMin: &two,
+			},
+			releases: []model.Release{
+				{
+					Name: "App1 v1.0",
+					Artifacts: []model.Image{
+						*inputImgs[0],
+						*inputImgs[2],
+						*inputImgs[3],
+					},
+					ArtifactsCount: 3,
+				},
+				{
+					Name: "App2 v0.1",
+					Artifacts: []model.Image{
+						*inputImgs[1],
+						*inputImgs[4],
+					},
+					ArtifactsCount: 2,
+				},
+			},
+		},
+		"ok, artifacts count exactly one": {
+			releaseFilt: &model.ReleaseOrImageFilter{
+				ArtifactsCountMin: &one,
+				ArtifactsCountMax: &one,
+			},
+			releases: []model.Release{
+				{
+					Name: "App4 v2.0",
+					Artifacts: []model.Image{
+						*inputImgs[5],
+					},
+					ArtifactsCount: 1,
+				},
+			},
+		},
 		"ok, sort by tags asc": {
 			releaseFilt: &model.ReleaseOrImageFilter{
 				Sort: "tags:asc",
@@ -1103,6 +1143,7 @@ func TestReplaceReleaseTags(t *testing.T) {
 				tenantID = id.Tenant
 			}
 			err := MigrateSingle(ctx,
+				DbName,
 				ctxstore.DbNameForTenant(tenantID, DbName),
 				DbVersion,
 				client,
@@ -1269,6 +1310,7 @@ func TestUpdateRelease(t *testing.T) {
 				tenantID = id.Tenant
 			}
 			err := MigrateSingle(ctx,
+				DbName,
 				ctxstore.DbNameForTenant(tenantID, DbName),
 				DbVersion,
 				client,
@@ -1373,3 +1415,333 @@ func TestDeleteReleasesByNames(t *testing.T) {
 		})
 	}
 }
+
+func TestRebuildReleases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestRebuildReleases in short mode.")
+	}
+	db.Wipe()
+
+	newImage := func(id, name, deviceType string) *model.Image {
+		return &model.Image{
+			Id:        id,
+			ImageMeta: &model.ImageMeta{},
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  name,
+				DeviceTypesCompatible: []string{deviceType},
+			},
+			Modified: timePtr("2023-09-22T22:00:00+00:00"),
+		}
+	}
+	inSync := newImage("6d4f6e27-c3bb-438c-ad9c-d9de30e59d80", "in-sync", "foo")
+	missing := newImage("6d4f6e27-c3bb-438c-ad9c-d9de30e59d81", "missing", "foo")
+	stale1 := newImage("6d4f6e27-c3bb-438c-ad9c-d9de30e59d82", "stale", "foo")
+	stale2 := newImage("6d4f6e27-c3bb-438c-ad9c-d9de30e59d83", "stale", "bar")
+
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	for _, img := range []*model.Image{inSync, missing, stale1, stale2} {
+		if !assert.NoError(t, ds.InsertImage(ctx, img)) {
+			t.FailNow()
+		}
+	}
+	for _, img := range []*model.Image{inSync, stale1} {
+		err := ds.UpdateReleaseArtifacts(ctx, img, nil, img.ArtifactMeta.Name)
+		assert.NoError(t, err)
+	}
+	err := ds.ReplaceReleaseTags(ctx, "stale", model.Tags{"production"})
+	assert.NoError(t, err)
+	_, err = db.Client().Database(DatabaseName).
+		Collection(CollectionReleases).
+		InsertOne(ctx, &model.Release{Name: "orphan", ArtifactsCount: 1})
+	assert.NoError(t, err)
+
+	expected := &model.ReleasesRebuild{
+		Created: []string{"missing"},
+		Updated: []string{"stale"},
+		Removed: []string{"orphan"},
+	}
+
+	result, err := ds.RebuildReleases(ctx, true)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	_, count, err := ds.GetReleases(ctx, &model.ReleaseOrImageFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count, "dry-run must not modify the releases")
+
+	result, err = ds.RebuildReleases(ctx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	release, err := ds.GetRelease(ctx, "stale", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, release.ArtifactsCount)
+		assert.Len(t, release.Artifacts, 2)
+		assert.Equal(t, model.Tags{"production"}, release.Tags)
+	}
+	release, err = ds.GetRelease(ctx, "missing", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, release.ArtifactsCount)
+	}
+	_, err = ds.GetRelease(ctx, "orphan", "")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	result, err = ds.RebuildReleases(ctx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ReleasesRebuild{}, result)
+}
+
+func TestGetRelease(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetRelease in short mode.")
+	}
+	db.Wipe()
+
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	_, err := client.Database(ctxstore.DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionReleases).
+		InsertOne(ctx, &model.Release{
+			Name: "foo",
+			Artifacts: []model.Image{
+				{Id: "medium", Size: 2048},
+				{Id: "small", Size: 1024},
+				{Id: "large", Size: 4096},
+			},
+			ArtifactsCount: 3,
+			Tags:           model.Tags{"bar"},
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := map[string]struct {
+		name          string
+		artifactsSort string
+
+		artifactIDs []string
+		err         error
+	}{
+		"ok, insertion order": {
+			name:        "foo",
+			artifactIDs: []string{"medium", "small", "large"},
+		},
+		"ok, size descending": {
+			name:          "foo",
+			artifactsSort: model.SortDirectionDescending,
+			artifactIDs:   []string{"large", "medium", "small"},
+		},
+		"ok, size ascending": {
+			name:          "foo",
+			artifactsSort: model.SortDirectionAscending,
+			artifactIDs:   []string{"small", "medium", "large"},
+		},
+		"error, not found": {
+			name: "bar",
+			err:  store.ErrNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			release, err := ds.GetRelease(ctx, tc.name, tc.artifactsSort)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "foo", release.Name)
+			assert.Equal(t, 3, release.ArtifactsCount)
+			assert.Equal(t, model.Tags{"bar"}, release.Tags)
+			ids := make([]string, len(release.Artifacts))
+			for i, a := range release.Artifacts {
+				ids[i] = a.Id
+			}
+			assert.Equal(t, tc.artifactIDs, ids)
+		})
+	}
+}
+
+func TestGetReleaseForArtifact(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetReleaseForArtifact in short mode.")
+	}
+	db.Wipe()
+
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	_, err := client.Database(ctxstore.DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionReleases).
+		InsertMany(ctx, []interface{}{
+			&model.Release{
+				Name: "foo",
+				Artifacts: []model.Image{
+					{Id: "6d4f6e27-c3bb-438c-ad9c-d9de30e59d80"},
+					{Id: "6d4f6e27-c3bb-438c-ad9c-d9de30e59d81"},
+				},
+				ArtifactsCount: 2,
+			},
+			&model.Release{
+				Name: "bar",
+				Artifacts: []model.Image{
+					{Id: "6d4f6e27-c3bb-438c-ad9c-d9de30e59d82"},
+				},
+				ArtifactsCount: 1,
+			},
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for artifactID, releaseName := range map[string]string{
+		"6d4f6e27-c3bb-438c-ad9c-d9de30e59d80": "foo",
+		"6d4f6e27-c3bb-438c-ad9c-d9de30e59d81": "foo",
+		"6d4f6e27-c3bb-438c-ad9c-d9de30e59d82": "bar",
+	} {
+		release, err := ds.GetReleaseForArtifact(ctx, artifactID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, releaseName, release.Name)
+		}
+	}
+
+	_, err = ds.GetReleaseForArtifact(ctx, "6d4f6e27-c3bb-438c-ad9c-d9de30e59d83")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestCountReleases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestCountReleases in short mode.")
+	}
+	db.Wipe()
+
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	count, err := ds.CountReleases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	_, err = client.Database(ctxstore.DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionReleases).
+		InsertMany(ctx, []interface{}{
+			&model.Release{Name: "foo", ArtifactsCount: 2},
+			&model.Release{Name: "bar", ArtifactsCount: 1},
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	count, err = ds.CountReleases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestGetUpdateTypesWithCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetUpdateTypesWithCounts in short mode.")
+	}
+	db.Wipe()
+
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	counts, err := ds.GetUpdateTypesWithCounts(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	artifact := func(updateTypes ...string) model.Image {
+		updates := make([]model.Update, len(updateTypes))
+		for i := range updateTypes {
+			updates[i].TypeInfo.Type = &updateTypes[i]
+		}
+		return model.Image{
+			ArtifactMeta: &model.ArtifactMeta{Updates: updates},
+		}
+	}
+	_, err = client.Database(ctxstore.DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionReleases).
+		InsertMany(ctx, []interface{}{
+			&model.Release{Name: "foo", Artifacts: []model.Image{
+				artifact("rootfs-image"),
+				artifact("rootfs-image", "single-file"),
+			}},
+			&model.Release{Name: "bar", Artifacts: []model.Image{
+				artifact("rootfs-image"),
+			}},
+			&model.Release{Name: "baz", Artifacts: []model.Image{
+				artifact(),
+			}},
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	counts, err = ds.GetUpdateTypesWithCounts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"rootfs-image": 2,
+		"single-file":  1,
+	}, counts)
+}
+
+func TestFindOrphanedImages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindOrphanedImages in short mode.")
+	}
+	db.Wipe()
+
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	images := []*model.Image{}
+	for i, name := range []string{"release-1", "release-2", "release-2", "release-3"} {
+		image := &model.Image{
+			Id:        "6d4f6e27-c3bb-438c-ad9c-d9de30e59d8" + strconv.Itoa(i),
+			ImageMeta: &model.ImageMeta{},
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  name,
+				DeviceTypesCompatible: []string{"foo-" + strconv.Itoa(i)},
+				Updates:               []model.Update{},
+			},
+		}
+		assert.NoError(t, ds.InsertImage(ctx, image))
+		images = append(images, image)
+	}
+	// only release-2 has a release document
+	for _, image := range images[1:3] {
+		assert.NoError(t, ds.UpdateReleaseArtifacts(ctx, image, nil, image.ArtifactMeta.Name))
+	}
+
+	ids := func(images []*model.Image) []string {
+		ids := []string{}
+		for _, image := range images {
+			ids = append(ids, image.Id)
+		}
+		return ids
+	}
+
+	orphaned, count, err := ds.FindOrphanedImages(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{images[0].Id, images[3].Id}, ids(orphaned))
+
+	orphaned, count, err = ds.FindOrphanedImages(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{images[3].Id}, ids(orphaned))
+
+	// no orphans in another tenant
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: "acme"})
+	orphaned, count, err = ds.FindOrphanedImages(tenantCtx, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Empty(t, orphaned)
+}
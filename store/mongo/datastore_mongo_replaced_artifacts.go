@@ -0,0 +1,98 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	mstore "github.com/mendersoftware/go-lib-micro/store"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+const (
+	StorageKeyReplacedArtifactReplaced = "replaced"
+)
+
+// InsertReplacedArtifactFile queues the file of a replaced artifact revision
+// for deletion.
+func (db *DataStoreMongo) InsertReplacedArtifactFile(
+	ctx context.Context,
+	file *model.ReplacedArtifactFile,
+) error {
+	if file == nil {
+		return ErrStorageInvalidInput
+	}
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReplaced := database.Collection(CollectionReplacedArtifacts)
+	if _, err := collReplaced.InsertOne(ctx, file); err != nil {
+		return errors.Wrap(err, "mongo: failed to insert replaced artifact file")
+	}
+	return nil
+}
+
+// FindReplacedArtifactFiles returns the files of the replaced artifact
+// revisions queued for deletion, oldest first.
+func (db *DataStoreMongo) FindReplacedArtifactFiles(
+	ctx context.Context,
+) ([]model.ReplacedArtifactFile, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReplaced := database.Collection(CollectionReplacedArtifacts)
+	cursor, err := collReplaced.Find(ctx, bson.D{}, mopts.Find().
+		SetSort(bson.D{{Key: StorageKeyReplacedArtifactReplaced, Value: 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to find replaced artifact files")
+	}
+	files := []model.ReplacedArtifactFile{}
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, errors.Wrap(err, "mongo: failed to decode replaced artifact files")
+	}
+	return files, nil
+}
+
+// DeleteReplacedArtifactFile removes the file from the deletion queue.
+func (db *DataStoreMongo) DeleteReplacedArtifactFile(ctx context.Context, id string) error {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collReplaced := database.Collection(CollectionReplacedArtifacts)
+	if _, err := collReplaced.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return errors.Wrap(err, "mongo: failed to delete replaced artifact file")
+	}
+	return nil
+}
+
+// ExistActiveDeviceDeploymentsOfImage tells whether any active device
+// deployment of a deployment created before the given time has the image
+// assigned.
+func (db *DataStoreMongo) ExistActiveDeviceDeploymentsOfImage(
+	ctx context.Context,
+	imageID string,
+	createdBefore time.Time,
+) (bool, error) {
+	database := db.client.Database(mstore.DbFromContext(ctx, db.dbName))
+	collDevs := database.Collection(CollectionDevices)
+	count, err := collDevs.CountDocuments(ctx, bson.M{
+		StorageKeyDeviceDeploymentAssignedImageId: imageID,
+		StorageKeyDeviceDeploymentActive:          true,
+		StorageKeyDeviceDeploymentCreated:         bson.M{"$lt": createdBefore},
+	}, mopts.Count().SetLimit(1))
+	if err != nil {
+		return false, errors.Wrap(err, "mongo: failed to count device deployments")
+	}
+	return count > 0, nil
+}
@@ -17,6 +17,8 @@ package mongo
 import (
 	"context"
 	"io"
+	"path"
+	"strconv"
 	"testing"
 	"time"
 
@@ -264,6 +266,72 @@ func TestUpdateUploadIntentStatus(t *testing.T) {
 	})
 }
 
+func TestSetUploadIntentPart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSetUploadIntentPart in short mode.")
+	}
+	db.Wipe()
+
+	const tenantID = "123456789012345678901234"
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: tenantID,
+	})
+	ds := NewDataStoreMongoWithClient(db.Client())
+	multipart := &model.UploadLink{
+		ArtifactID: uuid.NewString(),
+		Link: model.Link{
+			Expire: time.Now().Add(time.Hour),
+		},
+		Status: model.LinkStatusPending,
+		Multipart: &model.MultipartUpload{
+			UploadID: "upload",
+			Path:     path.Join(tenantID, "artifact"),
+		},
+	}
+	single := &model.UploadLink{
+		ArtifactID: uuid.NewString(),
+		Status:     model.LinkStatusPending,
+	}
+	for _, link := range []*model.UploadLink{multipart, single} {
+		if !assert.NoError(t, ds.InsertUploadIntent(ctx, link)) {
+			t.FailNow()
+		}
+	}
+
+	for _, part := range []model.UploadPart{
+		{PartNumber: 2, ETag: `"b"`},
+		{PartNumber: 1, ETag: `"a"`},
+		{PartNumber: 2, ETag: "$retried"},
+	} {
+		err := ds.SetUploadIntentPart(ctx, multipart.ArtifactID, part)
+		assert.NoError(t, err)
+	}
+	link, err := ds.GetUploadIntent(ctx, multipart.ArtifactID)
+	if assert.NoError(t, err) && assert.NotNil(t, link.Multipart) {
+		assert.Equal(t, "upload", link.Multipart.UploadID)
+		assert.Equal(t, []model.UploadPart{
+			{PartNumber: 1, ETag: `"a"`},
+			{PartNumber: 2, ETag: "$retried"},
+		}, link.Multipart.Parts)
+	}
+
+	err = ds.SetUploadIntentPart(ctx, single.ArtifactID,
+		model.UploadPart{PartNumber: 1, ETag: `"a"`})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// other tenants cannot see the upload
+	_, err = ds.GetUploadIntent(context.Background(), multipart.ArtifactID)
+	assert.NoError(t, err)
+	otherCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "000000000000000000000000",
+	})
+	_, err = ds.GetUploadIntent(otherCtx, multipart.ArtifactID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	err = ds.SetUploadIntentPart(otherCtx, multipart.ArtifactID,
+		model.UploadPart{PartNumber: 3, ETag: `"c"`})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
 func TestFindNewerActiveDeployments(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestFindNewerActiveDeployments in short mode.")
@@ -487,7 +555,7 @@ func TestInsertDeploymentConflict(t *testing.T) {
 			ctx := context.Background()
 			ds := NewDataStoreMongoWithClient(client)
 
-			err := MigrateSingle(ctx, DbName, DbVersion, client, true)
+			err := MigrateSingle(ctx, DbName, DbName, DbVersion, client, true)
 			assert.Nil(t, err)
 
 			deployment1, _ := model.NewDeploymentFromConstructor(tc.constructor1)
@@ -576,7 +644,7 @@ func TestSetDeploymentDeviceCount(t *testing.T) {
 			err = ds.SetDeploymentDeviceCount(ctx, tc.deployment.Id, tc.count)
 			assert.Nil(t, err)
 
-			deployment, err := ds.FindDeploymentByID(ctx, tc.deployment.Id)
+			deployment, err := ds.FindDeploymentByID(ctx, tc.deployment.Id, false)
 			assert.Nil(t, err)
 			assert.NotNil(t, deployment)
 			assert.NotNil(t, deployment.DeviceCount)
@@ -586,6 +654,101 @@ func TestSetDeploymentDeviceCount(t *testing.T) {
 
 }
 
+func TestResetDeploymentDeviceCount(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestResetDeploymentDeviceCount in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	five := 5
+	now := time.Now()
+	deployment := &model.Deployment{
+		Id:          "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		DeviceCount: &five,
+		Created:     &now,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "name",
+			ArtifactName: "artifact",
+			Devices:      []string{"device-1"},
+		},
+	}
+	err := ds.InsertDeployment(ctx, deployment)
+	assert.NoError(t, err)
+
+	err = ds.ResetDeploymentDeviceCount(ctx, deployment.Id, &five, 3)
+	assert.NoError(t, err)
+
+	found, err := ds.FindDeploymentByID(ctx, deployment.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) && assert.NotNil(t, found.DeviceCount) {
+		assert.Equal(t, 3, *found.DeviceCount)
+	}
+
+	// incremented since it was read
+	err = ds.IncrementDeploymentDeviceCount(ctx, deployment.Id, 1)
+	assert.NoError(t, err)
+	three := 3
+	err = ds.ResetDeploymentDeviceCount(ctx, deployment.Id, &three, 3)
+	assert.ErrorIs(t, err, ErrDeviceCountChanged)
+
+	found, err = ds.FindDeploymentByID(ctx, deployment.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) && assert.NotNil(t, found.DeviceCount) {
+		assert.Equal(t, 4, *found.DeviceCount)
+	}
+
+	err = ds.ResetDeploymentDeviceCount(ctx, "d50eda0d-2cea-4de1-8d42-9cd3e7e86702", nil, 3)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestDeploymentPhases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentPhases in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	now := time.Now()
+	deployment := &model.Deployment{
+		Id:      "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		Created: &now,
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "name",
+			ArtifactName: "artifact",
+			Phases:       model.DeploymentPhases{{BatchSizePercent: 50}, {}},
+		},
+		DeviceList: []string{"device-1", "device-2", "device-3"},
+	}
+	assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+
+	index, err := ds.GetDeploymentDeviceIndex(ctx, deployment.Id, "device-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	index, err = ds.GetDeploymentDeviceIndex(ctx, deployment.Id, "device-4")
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+
+	_, err = ds.GetDeploymentDeviceIndex(ctx, "nonexistent", "device-1")
+	assert.Equal(t, ErrStorageNotFound, err)
+
+	assert.NoError(t, ds.SetDeploymentCurrentPhase(ctx, deployment.Id, 1))
+	// the phase never goes back
+	assert.NoError(t, ds.SetDeploymentCurrentPhase(ctx, deployment.Id, 0))
+
+	found, err := ds.FindDeploymentByID(ctx, deployment.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) && assert.NotNil(t, found.CurrentPhase) {
+		assert.Equal(t, 1, *found.CurrentPhase)
+		assert.Equal(t, deployment.Phases, found.Phases)
+	}
+}
+
 func TestSetStorageSettings(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestSetStorageSettings in short mode.")
@@ -841,6 +1004,202 @@ func TestFindOldestActiveDeviceDeployment(t *testing.T) {
 	}
 }
 
+func TestFindOldestActiveDeviceDeploymentID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindOldestActiveDeviceDeploymentID in short mode.")
+	}
+	db.Wipe()
+	const DeviceID = "1140bc78-b898-4b2a-a4a2-551cb7bd9ac8"
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now()
+	for _, depl := range []*model.DeviceDeployment{{
+		Id:           "0",
+		Created:      TimePtr(now.Add(-2 * time.Hour)),
+		Status:       model.DeviceDeploymentStatusSuccess,
+		DeviceId:     DeviceID,
+		DeploymentId: "finished",
+	}, {
+		Id:           "1",
+		Created:      TimePtr(now.Add(-time.Hour)),
+		Status:       model.DeviceDeploymentStatusDownloading,
+		DeviceId:     DeviceID,
+		DeploymentId: "oldest",
+		Active:       true,
+	}, {
+		Id:           "2",
+		Created:      TimePtr(now.Add(-time.Minute)),
+		Status:       model.DeviceDeploymentStatusPending,
+		DeviceId:     DeviceID,
+		DeploymentId: "newest",
+		Active:       true,
+	}} {
+		if err := ds.InsertDeviceDeployment(ctx, depl, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deploymentID, err := ds.FindOldestActiveDeviceDeploymentID(ctx, DeviceID)
+	assert.NoError(t, err)
+	assert.Equal(t, "oldest", deploymentID)
+
+	deploymentID, err = ds.FindOldestActiveDeviceDeploymentID(ctx, "other-device")
+	assert.NoError(t, err)
+	assert.Empty(t, deploymentID)
+
+	_, err = ds.FindOldestActiveDeviceDeploymentID(ctx, "")
+	assert.EqualError(t, err, ErrStorageInvalidID.Error())
+}
+
+func TestFindAndClaimOldestActiveDeviceDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindAndClaimOldestActiveDeviceDeployment in short mode.")
+	}
+	db.Wipe()
+	const DeviceID = "1140bc78-b898-4b2a-a4a2-551cb7bd9ac8"
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now()
+	for _, depl := range []*model.DeviceDeployment{{
+		Id:           "0",
+		Created:      TimePtr(now.Add(-2 * time.Hour)),
+		Status:       model.DeviceDeploymentStatusSuccess,
+		DeviceId:     DeviceID,
+		DeploymentId: "finished",
+	}, {
+		Id:           "1",
+		Created:      TimePtr(now.Add(-time.Hour)),
+		Status:       model.DeviceDeploymentStatusPending,
+		DeviceId:     DeviceID,
+		DeploymentId: "oldest",
+		Active:       true,
+	}, {
+		Id:           "2",
+		Created:      TimePtr(now.Add(-time.Minute)),
+		Status:       model.DeviceDeploymentStatusPending,
+		DeviceId:     DeviceID,
+		DeploymentId: "newest",
+		Active:       true,
+	}} {
+		if err := ds.InsertDeviceDeployment(ctx, depl, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first request claims the oldest pending device deployment
+	dd, old, err := ds.FindAndClaimOldestActiveDeviceDeployment(ctx, DeviceID)
+	if assert.NoError(t, err) && assert.NotNil(t, dd) {
+		assert.Equal(t, "oldest", dd.DeploymentId)
+		assert.Equal(t, model.DeviceDeploymentStatusPending, old)
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading, dd.Status)
+		assert.NotNil(t, dd.Started)
+	}
+	stored, err := ds.FindOldestActiveDeviceDeployment(ctx, DeviceID)
+	if assert.NoError(t, err) && assert.NotNil(t, stored) {
+		assert.Equal(t, "oldest", stored.DeploymentId)
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading, stored.Status)
+		assert.NotNil(t, stored.Started)
+	}
+
+	// a retry gets the same device deployment, claimed already
+	dd, old, err = ds.FindAndClaimOldestActiveDeviceDeployment(ctx, DeviceID)
+	if assert.NoError(t, err) && assert.NotNil(t, dd) {
+		assert.Equal(t, "oldest", dd.DeploymentId)
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading, old)
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading, dd.Status)
+	}
+	newest, err := ds.GetDeviceDeployment(ctx, "newest", DeviceID, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.DeviceDeploymentStatusPending, newest.Status)
+	}
+
+	dd, old, err = ds.FindAndClaimOldestActiveDeviceDeployment(ctx, "other-device")
+	assert.NoError(t, err)
+	assert.Nil(t, dd)
+	assert.Equal(t, model.DeviceDeploymentStatusNull, old)
+
+	_, _, err = ds.FindAndClaimOldestActiveDeviceDeployment(ctx, "")
+	assert.EqualError(t, err, ErrStorageInvalidID.Error())
+}
+
+func TestFindActiveDeploymentsForDevice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindActiveDeploymentsForDevice in short mode.")
+	}
+	db.Wipe()
+	const (
+		DeviceID    = "1140bc78-b898-4b2a-a4a2-551cb7bd9ac8"
+		Enrolled    = "a7c5e1d0-3f43-4c8e-9a3f-0d3b8f6e2a01"
+		Dynamic     = "a7c5e1d0-3f43-4c8e-9a3f-0d3b8f6e2a02"
+		Skipped     = "a7c5e1d0-3f43-4c8e-9a3f-0d3b8f6e2a03"
+		Finished    = "a7c5e1d0-3f43-4c8e-9a3f-0d3b8f6e2a04"
+		OtherTarget = "a7c5e1d0-3f43-4c8e-9a3f-0d3b8f6e2a05"
+	)
+
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	now := time.Now()
+	lastFinished := now.Add(-2 * time.Hour)
+
+	newDeployment := func(id string, created time.Time, status model.DeploymentStatus,
+		devices ...string) *model.Deployment {
+		return &model.Deployment{
+			Id:      id,
+			Created: TimePtr(created),
+			Status:  status,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         id,
+				ArtifactName: "artifact",
+			},
+			DeviceList: devices,
+		}
+	}
+	for _, deployment := range []*model.Deployment{
+		// enrolled before the last finished device deployment
+		newDeployment(Enrolled, now.Add(-3*time.Hour),
+			model.DeploymentStatusInProgress, DeviceID),
+		// targeting the device, yet to be picked up
+		newDeployment(Dynamic, now.Add(-time.Hour),
+			model.DeploymentStatusPending, DeviceID, "other-device"),
+		// older than the last finished device deployment
+		newDeployment(Skipped, now.Add(-150*time.Minute),
+			model.DeploymentStatusPending, DeviceID),
+		newDeployment(Finished, now.Add(-time.Minute),
+			model.DeploymentStatusFinished, DeviceID),
+		newDeployment(OtherTarget, now.Add(-time.Minute),
+			model.DeploymentStatusPending, "other-device"),
+	} {
+		if err := ds.InsertDeployment(ctx, deployment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.InsertDeviceDeployment(ctx, &model.DeviceDeployment{
+		Id:           "0",
+		Created:      TimePtr(now.Add(-3 * time.Hour)),
+		Status:       model.DeviceDeploymentStatusDownloading,
+		DeviceId:     DeviceID,
+		DeploymentId: Enrolled,
+		Active:       true,
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	deployments, err := ds.FindActiveDeploymentsForDevice(ctx, DeviceID, &lastFinished)
+	assert.NoError(t, err)
+	ids := make([]string, len(deployments))
+	for i, deployment := range deployments {
+		ids[i] = deployment.Id
+		assert.Nil(t, deployment.DeviceList)
+	}
+	assert.Equal(t, []string{Enrolled, Dynamic}, ids)
+
+	deployments, err = ds.FindActiveDeploymentsForDevice(ctx, "unknown", &lastFinished)
+	assert.NoError(t, err)
+	assert.Empty(t, deployments)
+}
+
 func TestFindLatestInactiveDeviceDeployment(t *testing.T) {
 	db.Wipe()
 	const (
@@ -1115,6 +1474,16 @@ func TestGetDeviceDeploymentsForDevice(t *testing.T) {
 			DeviceId:     deviceID,
 			DeploymentId: "d50eda0d-2cea-4de1-8d42-9cd3e7e86703",
 		},
+		{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e86704",
+			Created: func() *time.Time {
+				ret := now
+				return &ret
+			}(),
+			Status:       model.DeviceDeploymentStatusFailure,
+			DeviceId:     "d50edaff-2cea-4de1-8d42-9cd3e7e86700",
+			DeploymentId: "d50eda0d-2cea-4de1-8d42-9cd3e7e86703",
+		},
 	}
 	for _, deviceDeployment := range deviceDeployments {
 		assert.NoError(t, ds.InsertDeviceDeployment(ctx, deviceDeployment, true))
@@ -1154,6 +1523,39 @@ func TestGetDeviceDeploymentsForDevice(t *testing.T) {
 			},
 			resCount: 2,
 		},
+		"ok, device ID prefix": {
+			q: store.ListQueryDeviceDeployments{
+				DeviceIDPrefix: "d50eda",
+				Limit:          10,
+			},
+			res: []model.DeviceDeployment{
+				*deviceDeployments[0],
+				*deviceDeployments[1],
+				*deviceDeployments[2],
+				*deviceDeployments[3],
+			},
+			resCount: 4,
+		},
+		"ok, device ID prefix, single device": {
+			q: store.ListQueryDeviceDeployments{
+				DeviceIDPrefix: "d50eda0d",
+				Status:         str2ptr(model.DeviceDeploymentStatusFinishedStr),
+				Limit:          10,
+			},
+			res: []model.DeviceDeployment{
+				*deviceDeployments[1],
+				*deviceDeployments[2],
+			},
+			resCount: 2,
+		},
+		"ok, device ID prefix is not a pattern": {
+			q: store.ListQueryDeviceDeployments{
+				DeviceIDPrefix: "d50eda.d",
+				Limit:          10,
+			},
+			res:      []model.DeviceDeployment{},
+			resCount: 0,
+		},
 		"ok, status pause": {
 			q: store.ListQueryDeviceDeployments{
 				DeviceID: deviceID,
@@ -1228,6 +1630,30 @@ func TestGetDeviceDeploymentsForDevice(t *testing.T) {
 			},
 			resCount: 2,
 		},
+		"ok, created range": {
+			q: store.ListQueryDeviceDeployments{
+				DeviceID:      deviceID,
+				CreatedAfter:  timePtrAt(now.Add(30 * time.Minute)),
+				CreatedBefore: timePtrAt(now.Add(150 * time.Minute)),
+				Limit:         10,
+			},
+			res: []model.DeviceDeployment{
+				*deviceDeployments[1],
+				*deviceDeployments[2],
+			},
+			resCount: 2,
+		},
+		"ok, created after": {
+			q: store.ListQueryDeviceDeployments{
+				DeviceID:     deviceID,
+				CreatedAfter: timePtrAt(now.Add(150 * time.Minute)),
+				Limit:        10,
+			},
+			res: []model.DeviceDeployment{
+				*deviceDeployments[0],
+			},
+			resCount: 1,
+		},
 		"ok, no results": {
 			q: store.ListQueryDeviceDeployments{
 				DeviceID: deviceID,
@@ -1272,6 +1698,156 @@ func TestGetDeviceDeploymentsForDevice(t *testing.T) {
 	}
 }
 
+func timePtrAt(t time.Time) *time.Time {
+	return &t
+}
+
+// TestGetDeviceDeploymentsForDeviceIndex checks that listing the device
+// deployments of a device within a time range is served by the device ID,
+// creation and status index without sorting in memory.
+func TestGetDeviceDeploymentsForDeviceIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetDeviceDeploymentsForDeviceIndex in short mode.")
+	}
+	db.Wipe()
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	err := ds.EnsureIndexes(DatabaseName, CollectionDevices,
+		DeviceIDStatusIndexes, DeviceIDCreatedStatusIndex)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	now := time.Now()
+	query, err := deviceDeploymentsQuery(store.ListQueryDeviceDeployments{
+		DeviceID:      "d50eda0d-2cea-4de1-8d42-9cd3e7e86700",
+		CreatedAfter:  timePtrAt(now.Add(-24 * time.Hour)),
+		CreatedBefore: &now,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var explain struct {
+		QueryPlanner struct {
+			WinningPlan bson.M `bson:"winningPlan"`
+		} `bson:"queryPlanner"`
+	}
+	err = db.Client().Database(DatabaseName).RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: CollectionDevices},
+			{Key: "filter", Value: query},
+			{Key: "sort", Value: deviceDeploymentsSort},
+		}},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&explain)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// walk down the plan stages to the index scan
+	var stages []string
+	indexName := ""
+	plan := explain.QueryPlanner.WinningPlan
+	if queryPlan, ok := plan["queryPlan"].(bson.M); ok {
+		// slot based execution engine
+		plan = queryPlan
+	}
+	for stage := plan; stage != nil; {
+		name, _ := stage["stage"].(string)
+		stages = append(stages, name)
+		if name, ok := stage["indexName"].(string); ok {
+			indexName = name
+		}
+		next, _ := stage["inputStage"].(bson.M)
+		stage = next
+	}
+	assert.Equal(t, IndexDeploymentDeviceCreatedStatusName, indexName, "plan: %v", stages)
+	assert.NotContains(t, stages, "SORT")
+}
+
+func TestGetDeviceDeploymentViewsForDevice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetDeviceDeploymentViewsForDevice in short mode.")
+	}
+	db.Wipe()
+
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	const deviceID = "d50eda0d-2cea-4de1-8d42-9cd3e7e86700"
+	image := &model.Image{
+		Id:        "6d4f6e27-c3bb-438c-ad9c-d9de30e59d80",
+		ImageMeta: &model.ImageMeta{},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"foo"},
+			Updates:               []model.Update{},
+		},
+	}
+	assert.NoError(t, ds.InsertImage(ctx, image))
+	for i, id := range []string{
+		"d50eda0d-2cea-4de1-8d42-9cd3e7e86711",
+		"d50eda0d-2cea-4de1-8d42-9cd3e7e86712",
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment-" + strconv.Itoa(i),
+				ArtifactName: "release-" + strconv.Itoa(i+1),
+			},
+			Id:      id,
+			Created: &now,
+			Status:  model.DeploymentStatusInProgress,
+		}))
+	}
+	older := now.Add(-time.Hour)
+	deviceDeployments := []*model.DeviceDeployment{{
+		Id:           "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+		Created:      &now,
+		Status:       model.DeviceDeploymentStatusDownloading,
+		DeviceId:     deviceID,
+		DeploymentId: "d50eda0d-2cea-4de1-8d42-9cd3e7e86711",
+		Image:        image,
+	}, {
+		// no artifact assigned yet
+		Id:           "d50eda0d-2cea-4de1-8d42-9cd3e7e86702",
+		Created:      &older,
+		Status:       model.DeviceDeploymentStatusPending,
+		DeviceId:     deviceID,
+		DeploymentId: "d50eda0d-2cea-4de1-8d42-9cd3e7e86712",
+	}}
+	for _, deviceDeployment := range deviceDeployments {
+		assert.NoError(t, ds.InsertDeviceDeployment(ctx, deviceDeployment, true))
+	}
+
+	views, count, err := ds.GetDeviceDeploymentViewsForDevice(ctx,
+		store.ListQueryDeviceDeployments{DeviceID: deviceID, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	if assert.Len(t, views, 2) {
+		assert.Equal(t, "d50eda0d-2cea-4de1-8d42-9cd3e7e86701", views[0].ID)
+		assert.Equal(t, deviceID, views[0].DeviceID)
+		assert.Equal(t, "deployment-0", views[0].DeploymentName)
+		assert.Equal(t, model.DeploymentStatusInProgress, views[0].DeploymentStatus)
+		assert.Equal(t, image.Id, views[0].ArtifactID)
+		assert.Equal(t, "release-1", views[0].ArtifactName)
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading, views[0].Status)
+
+		assert.Equal(t, "deployment-1", views[1].DeploymentName)
+		assert.Empty(t, views[1].ArtifactID)
+		assert.Equal(t, "release-2", views[1].ArtifactName)
+		assert.Equal(t, model.DeviceDeploymentStatusPending, views[1].Status)
+	}
+
+	views, count, err = ds.GetDeviceDeploymentViewsForDevice(ctx,
+		store.ListQueryDeviceDeployments{DeviceID: deviceID, Limit: 1, Skip: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	if assert.Len(t, views, 1) {
+		assert.Equal(t, "d50eda0d-2cea-4de1-8d42-9cd3e7e86702", views[0].ID)
+	}
+}
+
 func TestGetDeviceDeployments(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestGetDeviceDeployments in short mode.")
@@ -1499,6 +2075,69 @@ func TestExistUnfinishedByArtifactName(t *testing.T) {
 	}
 }
 
+func TestFindUnfinishedIDsByArtifactName(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindUnfinishedIDsByArtifactName in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	now := time.Now()
+	older, newer := now.Add(-time.Hour), now.Add(-time.Minute)
+	collDep := client.Database(ctxstore.
+		DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionDeployments)
+	_, err := collDep.InsertMany(ctx, []interface{}{
+		&model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				ArtifactName: "foo",
+			},
+			Id:      "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+			Created: &newer,
+		},
+		&model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				ArtifactName: "foo",
+			},
+			Id:      "d1804903-5caa-4a73-a3ae-0efcc3205405",
+			Created: &older,
+		},
+		&model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				ArtifactName: "foo",
+			},
+			Id:       "e6ae0a7b-0b53-4d54-a8a7-f2a9e1fbd3e8",
+			Created:  &older,
+			Finished: &now,
+		},
+		&model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				ArtifactName: "bar",
+			},
+			Id:      "0aed59a4-4b0c-4c9a-a7a3-9a5a6f4a57a4",
+			Created: &older,
+		},
+	})
+	assert.NoError(t, err)
+
+	ids, err := ds.FindUnfinishedIDsByArtifactName(ctx, "foo", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"d1804903-5caa-4a73-a3ae-0efcc3205405",
+		"a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+	}, ids)
+
+	ids, err = ds.FindUnfinishedIDsByArtifactName(ctx, "foo", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d1804903-5caa-4a73-a3ae-0efcc3205405"}, ids)
+
+	_, err = ds.FindUnfinishedIDsByArtifactName(ctx, "", 1)
+	assert.EqualError(t, err, ErrImagesStorageInvalidArtifactName.Error())
+}
+
 func TestExistUnfinishedByArtifactId(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestExistUnfinishedByArtifactId in short mode.")
@@ -2133,6 +2772,7 @@ func TestSaveUpdateTypes(t *testing.T) {
 				tenantID = id.Tenant
 			}
 			err := MigrateSingle(ctx,
+				DbName,
 				ctxstore.DbNameForTenant(tenantID, DbName),
 				DbVersion,
 				client,
@@ -2418,6 +3058,64 @@ func TestDeleteImagesByNames(t *testing.T) {
 	}
 }
 
+func TestDeleteImagesByRelease(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeleteImagesByRelease in short mode.")
+	}
+
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+
+	collImages := client.Database(ctxstore.
+		DbFromContext(ctx, DatabaseName)).
+		Collection(CollectionImages)
+	_, err := collImages.InsertMany(ctx, []interface{}{
+		&model.Image{
+			Id: "1",
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "foo",
+				DeviceTypesCompatible: []string{"foo"},
+			},
+		},
+		&model.Image{
+			Id: "2",
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "foobar",
+				DeviceTypesCompatible: []string{"foo"},
+			},
+		},
+		&model.Image{
+			Id: "3",
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "foo",
+				DeviceTypesCompatible: []string{"bar"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = ds.DeleteImagesByRelease(ctx, "")
+	assert.EqualError(t, err, ErrImagesStorageInvalidArtifactName.Error())
+
+	images, err := ds.DeleteImagesByRelease(ctx, "foo")
+	assert.NoError(t, err)
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = image.Id
+	}
+	assert.ElementsMatch(t, []string{"1", "3"}, ids)
+
+	images, err = ds.DeleteImagesByRelease(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+
+	count, err := collImages.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
 func TestGetDeploymentIDsByArtifactNames(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestGetDeploymentIDsByArtifactNames in short mode.")
@@ -2486,3 +3184,49 @@ func TestGetDeploymentIDsByArtifactNames(t *testing.T) {
 		})
 	}
 }
+
+func TestTouchDeviceDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestTouchDeviceDeployment in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	now := time.Now()
+	dd := &model.DeviceDeployment{
+		Id:           uuid.NewString(),
+		Created:      &now,
+		Status:       model.DeviceDeploymentStatusDownloading,
+		DeviceId:     uuid.NewString(),
+		DeploymentId: uuid.NewString(),
+		Active:       true,
+	}
+	if !assert.NoError(t, ds.InsertDeviceDeployment(ctx, dd, false)) {
+		t.FailNow()
+	}
+
+	// a different status must not be touched
+	err := ds.TouchDeviceDeployment(ctx, dd.DeviceId, dd.DeploymentId,
+		model.DeviceDeploymentStatusInstalling)
+	assert.NoError(t, err)
+	res, err := ds.GetDeviceDeployment(ctx, dd.DeploymentId, dd.DeviceId, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, res.LastUpdated)
+
+	err = ds.TouchDeviceDeployment(ctx, dd.DeviceId, dd.DeploymentId, dd.Status)
+	assert.NoError(t, err)
+	res, err = ds.GetDeviceDeployment(ctx, dd.DeploymentId, dd.DeviceId, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.NotNil(t, res.LastUpdated) {
+		assert.WithinDuration(t, time.Now(), *res.LastUpdated, time.Minute)
+	}
+
+	err = ds.TouchDeviceDeployment(ctx, "", dd.DeploymentId, dd.Status)
+	assert.ErrorIs(t, err, ErrStorageInvalidID)
+}
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/mendersoftware/go-lib-micro/identity"
 	ctxstore "github.com/mendersoftware/go-lib-micro/store"
 	mstore "github.com/mendersoftware/go-lib-micro/store"
@@ -28,6 +29,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
 	. "github.com/mendersoftware/deployments/utils/pointers"
 )
 
@@ -207,11 +209,24 @@ func TestDeploymentStorageDelete(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 
-				count, err := collDep.CountDocuments(ctx,
-					bson.M{"_id": testCase.InputID})
+				count, err := collDep.CountDocuments(ctx, bson.M{
+					"_id":                       testCase.InputID,
+					StorageKeyDeploymentDeleted: bson.M{"$exists": false},
+				})
 				assert.NoError(t, err)
 				assert.Equal(t, 0, int(count))
 
+				dpl, err := store.FindDeploymentByID(ctx, testCase.InputID, false)
+				assert.NoError(t, err)
+				assert.Nil(t, dpl)
+				dpl, err = store.FindDeploymentByID(ctx, testCase.InputID, true)
+				assert.NoError(t, err)
+				if testCase.InputDeploymentsCollection != nil {
+					if assert.NotNil(t, dpl) {
+						assert.NotNil(t, dpl.Deleted)
+					}
+				}
+
 				if testCase.InputTenant != "" {
 					collDefaultDep := client.
 						Database(DatabaseName).
@@ -391,7 +406,7 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
-			deployment, err := store.FindDeploymentByID(ctx, testCase.InputID)
+			deployment, err := store.FindDeploymentByID(ctx, testCase.InputID, false)
 
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
@@ -406,7 +421,7 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 			// tenant is set, verify that deployment is not present in default DB
 			if testCase.InputTenant != "" {
 				deployment, err := store.FindDeploymentByID(context.Background(),
-					testCase.InputID)
+					testCase.InputID, false)
 				assert.Nil(t, deployment)
 				assert.Nil(t, err)
 			}
@@ -889,6 +904,45 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 	}
 }
 
+func TestClaimDeploymentUpdateSlot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestClaimDeploymentUpdateSlot in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	const deploymentID = "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	_, err := db.Client().Database(DatabaseName).
+		Collection(CollectionDeployments).
+		InsertOne(ctx, &model.Deployment{
+			Id: deploymentID,
+			Stats: model.Stats{
+				model.DeviceDeploymentStatusPendingStr:     3,
+				model.DeviceDeploymentStatusDownloadingStr: 1,
+				model.DeviceDeploymentStatusSuccessStr:     2,
+			},
+		})
+	assert.NoError(t, err)
+
+	stats, ok, err := ds.ClaimDeploymentUpdateSlot(ctx, deploymentID, 2)
+	if assert.NoError(t, err) && assert.True(t, ok) {
+		assert.Equal(t, 2, stats.Get(model.DeviceDeploymentStatusPending))
+		assert.Equal(t, 2, stats.Get(model.DeviceDeploymentStatusDownloading))
+	}
+
+	// at the limit: the stats are left unchanged
+	stats, ok, err = ds.ClaimDeploymentUpdateSlot(ctx, deploymentID, 2)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, stats)
+
+	_, _, err = ds.ClaimDeploymentUpdateSlot(ctx,
+		"b108ae14-bb4e-455f-9b40-2ef4bab97bb7", 2)
+	assert.ErrorIs(t, err, ErrStorageInvalidID)
+}
+
 func TestDeploymentStorageUpdateStats(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestDeploymentStorageUpdateStats in short mode.")
@@ -1660,7 +1714,9 @@ func TestDeploymentSetStatus(t *testing.T) {
 			_, err := collDep.InsertOne(ctx, deployment)
 			assert.NoError(t, err)
 
-			err = store.SetDeploymentStatus(ctx, id, tc.status, now)
+			updated, err := store.SetDeploymentStatus(ctx, id, tc.status, now)
+			assert.NoError(t, err)
+			assert.True(t, updated)
 
 			var deployment *model.Deployment
 			err = collDep.FindOne(ctx,
@@ -1672,12 +1728,1044 @@ func TestDeploymentSetStatus(t *testing.T) {
 			if tc.status == model.DeploymentStatusFinished {
 				// mongo trims time, no true equality
 				assert.WithinDuration(t, now, *deployment.Finished, time.Second)
+
+				// a finished deployment is not updated again
+				for _, status := range []model.DeploymentStatus{
+					model.DeploymentStatusFinished,
+					model.DeploymentStatusInProgress,
+				} {
+					updated, err = store.SetDeploymentStatus(ctx, id, status,
+						now.Add(time.Hour))
+					assert.NoError(t, err)
+					assert.False(t, updated)
+				}
+				err = collDep.FindOne(ctx, bson.M{"_id": id}).Decode(&deployment)
+				assert.NoError(t, err)
+				assert.Equal(t, model.DeploymentStatusFinished, deployment.Status)
+				assert.WithinDuration(t, now, *deployment.Finished, time.Second)
 			}
 
 			if tc.tenant != "" {
-				err := store.SetDeploymentStatus(context.Background(), id, tc.status, now)
+				_, err := store.SetDeploymentStatus(context.Background(), id, tc.status, now)
 				assert.EqualError(t, err, ErrStorageInvalidID.Error())
 			}
 		})
 	}
 }
+
+func TestDeploymentStorageGetTargetDevices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageGetTargetDevices in short mode.")
+	}
+
+	devices := []string{
+		"1c8e4f3c-a2a4-4d23-a1f6-9c5d63bbb0a1",
+		"2d1e1ab8-bd57-4d1b-a2f2-02f2c1f4c8b2",
+		"3a0c7e5d-8a71-4d6e-8d8d-4c49a6b5d7c3",
+	}
+	deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:         "NYC Production",
+		ArtifactName: "App 123",
+		Devices:      devices,
+	})
+	assert.NoError(t, err)
+	deployment.DeviceList = devices
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	err = ds.InsertDeployment(ctx, deployment)
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		id    string
+		skip  int
+		limit int
+
+		devices []string
+		count   int
+		err     error
+	}{
+		"ok, all": {
+			id:      deployment.Id,
+			limit:   10,
+			devices: devices,
+			count:   3,
+		},
+		"ok, page": {
+			id:      deployment.Id,
+			skip:    1,
+			limit:   1,
+			devices: devices[1:2],
+			count:   3,
+		},
+		"ok, past the end": {
+			id:      deployment.Id,
+			skip:    3,
+			limit:   10,
+			devices: []string{},
+			count:   3,
+		},
+		"error, not found": {
+			id:    "b532b01a-9313-404f-8d19-e7fcbe5cc347",
+			limit: 10,
+			err:   store.ErrNotFound,
+		},
+		"error, invalid id": {
+			err: ErrStorageInvalidID,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			res, count, err := ds.GetDeploymentTargetDevices(ctx, tc.id, tc.skip, tc.limit)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.devices, res)
+			assert.Equal(t, tc.count, count)
+		})
+	}
+}
+
+func TestDeploymentStorageGetCreationTrend(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageGetCreationTrend in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	err := ds.EnsureIndexes(DatabaseName, CollectionDeployments, DeploymentCreatedIndex)
+	assert.NoError(t, err)
+
+	// Friday 2024-03-01 to Monday 2024-03-11
+	day := func(d, h int) time.Time {
+		return time.Date(2024, 3, d, h, 0, 0, 0, time.UTC)
+	}
+	for _, created := range []time.Time{
+		day(1, 8), day(1, 8), day(1, 23),
+		day(3, 10),
+		day(4, 0),
+		day(11, 12),
+	} {
+		created := created
+		deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+			Name:         "NYC Production",
+			ArtifactName: "App 123",
+			Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		})
+		assert.NoError(t, err)
+		deployment.Created = &created
+		assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+	}
+
+	testCases := map[string]struct {
+		from, to    time.Time
+		granularity model.TrendGranularity
+
+		buckets []model.TrendBucket
+	}{
+		"day": {
+			from:        day(1, 0),
+			to:          day(12, 0),
+			granularity: model.TrendGranularityDay,
+			buckets: []model.TrendBucket{
+				{Time: day(1, 0), Count: 3},
+				{Time: day(3, 0), Count: 1},
+				{Time: day(4, 0), Count: 1},
+				{Time: day(11, 0), Count: 1},
+			},
+		},
+		"day, range excludes the upper bound": {
+			from:        day(1, 12),
+			to:          day(4, 0),
+			granularity: model.TrendGranularityDay,
+			buckets: []model.TrendBucket{
+				{Time: day(1, 0), Count: 1},
+				{Time: day(3, 0), Count: 1},
+			},
+		},
+		"hour": {
+			from:        day(1, 0),
+			to:          day(2, 0),
+			granularity: model.TrendGranularityHour,
+			buckets: []model.TrendBucket{
+				{Time: day(1, 8), Count: 2},
+				{Time: day(1, 23), Count: 1},
+			},
+		},
+		"week": {
+			from:        day(1, 0),
+			to:          day(12, 0),
+			granularity: model.TrendGranularityWeek,
+			buckets: []model.TrendBucket{
+				{Time: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), Count: 4},
+				{Time: day(4, 0), Count: 1},
+				{Time: day(11, 0), Count: 1},
+			},
+		},
+		"no deployments in range": {
+			from:        day(5, 0),
+			to:          day(10, 0),
+			granularity: model.TrendGranularityDay,
+			buckets:     []model.TrendBucket{},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			buckets, err := ds.GetDeploymentCreationTrend(ctx, tc.from, tc.to, tc.granularity)
+			assert.NoError(t, err)
+			assert.Len(t, buckets, len(tc.buckets))
+			for i := range buckets {
+				assert.True(t, tc.buckets[i].Time.Equal(buckets[i].Time),
+					"bucket %d: expected %s, got %s", i, tc.buckets[i].Time, buckets[i].Time)
+				assert.Equal(t, tc.buckets[i].Count, buckets[i].Count)
+			}
+		})
+	}
+}
+
+func TestDeploymentStorageRestoreAndPurge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageRestoreAndPurge in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	newDeployment := func() *model.Deployment {
+		deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+			Name:         "NYC Production",
+			ArtifactName: "App 123",
+			Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+		return deployment
+	}
+	kept := newDeployment()
+	restored := newDeployment()
+	expired := newDeployment()
+	assert.NoError(t, ds.DeleteDeployment(ctx, restored.Id))
+	assert.NoError(t, ds.DeleteDeployment(ctx, expired.Id))
+	for _, dpl := range []*model.Deployment{kept, expired} {
+		dd := model.NewDeviceDeployment("b532b01a-9313-404f-8d19-e7fcbe5cc347", dpl.Id)
+		assert.NoError(t, ds.InsertDeviceDeployment(ctx, dd, true))
+		now := time.Now()
+		assert.NoError(t, ds.SaveDeviceDeploymentLog(ctx, model.DeploymentLog{
+			DeviceID:     dd.DeviceId,
+			DeploymentID: dpl.Id,
+			Messages: []model.LogMessage{{
+				Level:     "error",
+				Message:   "failed",
+				Timestamp: &now,
+			}},
+		}))
+	}
+
+	// deleted deployments are hidden from lookups
+	deployments, count, err := ds.Find(ctx, model.Query{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	if assert.Len(t, deployments, 1) {
+		assert.Equal(t, kept.Id, deployments[0].Id)
+	}
+	_, count, err = ds.Find(ctx, model.Query{Limit: 10, IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// backdate the deletion of the expired deployment
+	collDpl := db.Client().Database(DatabaseName).Collection(CollectionDeployments)
+	_, err = collDpl.UpdateOne(ctx,
+		bson.M{"_id": expired.Id},
+		bson.M{"$set": bson.M{
+			StorageKeyDeploymentDeleted: time.Now().Add(-48 * time.Hour),
+		}},
+	)
+	assert.NoError(t, err)
+	deletedAfter := time.Now().Add(-24 * time.Hour)
+
+	err = ds.RestoreDeployment(ctx, expired.Id, deletedAfter)
+	assert.Equal(t, store.ErrNotFound, err)
+	err = ds.RestoreDeployment(ctx, kept.Id, deletedAfter)
+	assert.Equal(t, store.ErrNotFound, err)
+	err = ds.RestoreDeployment(ctx, "", deletedAfter)
+	assert.Equal(t, ErrStorageInvalidID, err)
+	err = ds.RestoreDeployment(ctx, restored.Id, deletedAfter)
+	assert.NoError(t, err)
+
+	dpl, err := ds.FindDeploymentByID(ctx, restored.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, dpl) {
+		assert.Nil(t, dpl.Deleted)
+	}
+
+	purged, err := ds.PurgeDeletedDeployments(ctx, deletedAfter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	dpl, err = ds.FindDeploymentByID(ctx, expired.Id, true)
+	assert.NoError(t, err)
+	assert.Nil(t, dpl)
+	_, count, err = ds.Find(ctx, model.Query{Limit: 10, IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// the device deployments and logs of the purged deployment are gone
+	database := db.Client().Database(DatabaseName)
+	for _, dpl := range []*model.Deployment{kept, expired} {
+		expected := int64(1)
+		if dpl == expired {
+			expected = 0
+		}
+		filter := bson.M{StorageKeyDeviceDeploymentDeploymentID: dpl.Id}
+		count, err = database.Collection(CollectionDevices).CountDocuments(ctx, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, count)
+		count, err = database.Collection(CollectionDeviceDeploymentLogs).
+			CountDocuments(ctx, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, count)
+	}
+}
+
+func TestDeploymentStorageStartDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageStartDeployment in short mode.")
+	}
+	const deviceID = "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	start := false
+	deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:             "NYC Production",
+		ArtifactName:     "App 123",
+		Devices:          []string{deviceID},
+		StartImmediately: &start,
+	})
+	assert.NoError(t, err)
+	deployment.DeviceList = []string{deviceID}
+	assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+
+	// the devices do not get the paused deployment
+	createdAfter := deployment.Created.Add(-time.Hour)
+	dpl, err := ds.FindNewerActiveDeployment(ctx, &createdAfter, deviceID)
+	assert.NoError(t, err)
+	assert.Nil(t, dpl)
+	deployments, err := ds.FindActiveDeploymentsForDevice(ctx, deviceID, &createdAfter)
+	assert.NoError(t, err)
+	assert.Empty(t, deployments)
+
+	deployments, count, err := ds.Find(ctx, model.Query{
+		Limit:  10,
+		Status: model.StatusQueryPaused,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	if assert.Len(t, deployments, 1) {
+		assert.Equal(t, deployment.Id, deployments[0].Id)
+		assert.True(t, deployments[0].Active)
+	}
+
+	// the device gets a deployment created after the paused one
+	other, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:         "NYC Hotfix",
+		ArtifactName: "App 124",
+		Devices:      []string{deviceID},
+	})
+	assert.NoError(t, err)
+	other.DeviceList = []string{deviceID}
+	created := deployment.Created.Add(time.Minute)
+	other.Created = &created
+	assert.NoError(t, ds.InsertDeployment(ctx, other))
+	dpl, err = ds.FindNewerActiveDeployment(ctx, &createdAfter, deviceID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, dpl) {
+		assert.Equal(t, other.Id, dpl.Id)
+	}
+
+	started := created.Add(time.Minute).UTC().Round(time.Millisecond)
+	err = ds.StartDeployment(ctx, "", started, nil)
+	assert.Equal(t, ErrStorageInvalidID, err)
+	err = ds.StartDeployment(ctx, deployment.Id, started, nil)
+	assert.NoError(t, err)
+	err = ds.StartDeployment(ctx, deployment.Id, started, nil)
+	assert.Equal(t, store.ErrNotFound, err)
+
+	// once started, the paused deployment is newer than the one delivered
+	dpl, err = ds.FindNewerActiveDeployment(ctx, other.Created, deviceID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, dpl) {
+		assert.Equal(t, deployment.Id, dpl.Id)
+		assert.Equal(t, model.DeploymentStatusPending, dpl.Status)
+		if assert.NotNil(t, dpl.Started) {
+			assert.True(t, started.Equal(*dpl.Started))
+		}
+	}
+	deployments, err = ds.FindActiveDeploymentsForDevice(ctx, deviceID, other.Created)
+	assert.NoError(t, err)
+	if assert.Len(t, deployments, 1) {
+		assert.Equal(t, deployment.Id, deployments[0].Id)
+	}
+	// and older than the deployments the device gets after it
+	dpl, err = ds.FindNewerActiveDeployment(ctx, &started, deviceID)
+	assert.NoError(t, err)
+	assert.Nil(t, dpl)
+}
+
+func TestDeploymentStorageUpdateDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageUpdateDeployment in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:         "NYC Prodcution",
+		ArtifactName: "App 123",
+		Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+
+	name := "NYC Production"
+	description := "fixes the typo"
+	err = ds.UpdateDeployment(ctx, "", model.DeploymentPatch{Name: &name})
+	assert.Equal(t, ErrStorageInvalidID, err)
+	err = ds.UpdateDeployment(ctx, deployment.Id,
+		model.DeploymentPatch{Name: &name})
+	assert.NoError(t, err)
+	err = ds.UpdateDeployment(ctx, deployment.Id,
+		model.DeploymentPatch{Description: &description})
+	assert.NoError(t, err)
+
+	dpl, err := ds.FindDeploymentByID(ctx, deployment.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, dpl) {
+		assert.Equal(t, name, dpl.Name)
+		assert.Equal(t, description, dpl.Description)
+		assert.Equal(t, deployment.ArtifactName, dpl.ArtifactName)
+	}
+
+	assert.NoError(t, ds.DeleteDeployment(ctx, deployment.Id))
+	err = ds.UpdateDeployment(ctx, deployment.Id,
+		model.DeploymentPatch{Name: &name})
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestDeploymentStorageRollback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageRollback in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:                     "NYC Production",
+		ArtifactName:             "App 123",
+		Devices:                  []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		RollbackOnFailurePercent: 10,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+
+	triggered := time.Now().UTC().Truncate(time.Millisecond)
+	_, err = ds.StartDeploymentRollback(ctx, "", triggered)
+	assert.Equal(t, ErrStorageInvalidID, err)
+	started, err := ds.StartDeploymentRollback(ctx, deployment.Id, triggered)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	// only once
+	started, err = ds.StartDeploymentRollback(ctx, deployment.Id, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, started)
+
+	// not retried right away
+	unfinished, err := ds.FindUnfinishedDeploymentRollbacks(ctx, triggered)
+	assert.NoError(t, err)
+	assert.Empty(t, unfinished)
+	unfinished, err = ds.FindUnfinishedDeploymentRollbacks(ctx, triggered.Add(time.Second))
+	assert.NoError(t, err)
+	if assert.Len(t, unfinished, 1) {
+		assert.Equal(t, deployment.Id, unfinished[0].Id)
+	}
+
+	finished := triggered.Add(time.Second)
+	err = ds.FinishDeploymentRollback(ctx, deployment.Id,
+		[]string{"a8f6d1c0-2b7e-4a4d-9d56-7e3c4f1b2a90"}, finished)
+	assert.NoError(t, err)
+	err = ds.FinishDeploymentRollback(ctx,
+		"a8f6d1c0-2b7e-4a4d-9d56-7e3c4f1b2a91", []string{}, finished)
+	assert.Equal(t, ErrStorageInvalidID, err)
+
+	unfinished, err = ds.FindUnfinishedDeploymentRollbacks(ctx, finished)
+	assert.NoError(t, err)
+	assert.Empty(t, unfinished)
+
+	dpl, err := ds.FindDeploymentByID(ctx, deployment.Id, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, dpl) && assert.NotNil(t, dpl.Rollback) {
+		assert.Equal(t, uint(10), dpl.RollbackOnFailurePercent)
+		assert.True(t, triggered.Equal(dpl.Rollback.Triggered))
+		assert.Equal(t,
+			[]string{"a8f6d1c0-2b7e-4a4d-9d56-7e3c4f1b2a90"},
+			dpl.Rollback.Deployments)
+		if assert.NotNil(t, dpl.Rollback.Finished) {
+			assert.True(t, finished.Equal(*dpl.Rollback.Finished))
+		}
+	}
+}
+
+func TestCountActiveDeploymentsPerTenant(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestCountActiveDeploymentsPerTenant in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	insertDeployment := func(ctx context.Context) *model.Deployment {
+		deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+			Name:         "NYC Production",
+			ArtifactName: "App 123",
+			Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, ds.InsertDeployment(ctx, deployment))
+		return deployment
+	}
+
+	// single tenant
+	insertDeployment(ctx)
+	counts, err := ds.CountActiveDeploymentsPerTenant(ctx, "", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantActiveDeployments{{ActiveCount: 1}}, counts)
+	counts, err = ds.CountActiveDeploymentsPerTenant(ctx, "tenant", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	const (
+		tenant1 = "5abcb6de7a673a0001287c71"
+		tenant2 = "5abcb6de7a673a0001287c72"
+	)
+	for _, tenantID := range []string{tenant1, tenant2} {
+		assert.NoError(t, ds.ProvisionTenant(ctx, tenantID))
+	}
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: tenant1})
+	insertDeployment(tenantCtx)
+	finished := insertDeployment(tenantCtx)
+	_, err = ds.SetDeploymentStatus(tenantCtx, finished.Id,
+		model.DeploymentStatusFinished, time.Now())
+	assert.NoError(t, err)
+	// deleted deployments are not counted
+	deleted := insertDeployment(tenantCtx)
+	assert.NoError(t, ds.DeleteDeployment(tenantCtx, deleted.Id))
+
+	counts, err = ds.CountActiveDeploymentsPerTenant(ctx, "", 0, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantActiveDeployments{
+		{TenantID: tenant1, ActiveCount: 1},
+		{TenantID: tenant2, ActiveCount: 0},
+	}, counts)
+
+	// pages of the tenants
+	counts, err = ds.CountActiveDeploymentsPerTenant(ctx, "", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantActiveDeployments{
+		{TenantID: tenant1, ActiveCount: 1},
+	}, counts)
+	counts, err = ds.CountActiveDeploymentsPerTenant(ctx, tenant1, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantActiveDeployments{
+		{TenantID: tenant2, ActiveCount: 0},
+	}, counts)
+	counts, err = ds.CountActiveDeploymentsPerTenant(ctx, tenant2, 1, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = ds.CountActiveDeploymentsPerTenant(canceledCtx, "", 0, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestFindDeploymentsByArtifact(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindDeploymentsByArtifact in short mode.")
+	}
+	const (
+		artifactID    = "aa0b1ab1-b2d9-4ae0-b7d1-75abe8fd8b2c"
+		otherArtifact = "bb0b1ab1-b2d9-4ae0-b7d1-75abe8fd8b2c"
+	)
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(
+		id, artifactName string,
+		artifacts []string,
+		age time.Duration,
+	) *model.Deployment {
+		created := now.Add(-age)
+		return &model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670" + id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: artifactName,
+			},
+			Artifacts:  artifacts,
+			Created:    &created,
+			DeviceList: []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		}
+	}
+	byName := newDeployment("1", "App 123", []string{artifactID}, time.Hour)
+	byID := newDeployment("2", "App 123 renamed", []string{otherArtifact, artifactID}, time.Minute)
+	deleted := newDeployment("3", "App 123", nil, 2*time.Hour)
+	deleted.Deleted = TimePtr(now)
+	for _, depl := range []*model.Deployment{
+		byName,
+		byID,
+		deleted,
+		newDeployment("4", "App 456", []string{otherArtifact}, time.Second),
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	deps, count, err := ds.FindDeploymentsByArtifact(ctx, "App 123", artifactID, model.Query{
+		Limit: 10,
+		Sort:  model.SortDirectionAscending,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), count)
+		if assert.Len(t, deps, 2) {
+			assert.Equal(t, byName.Id, deps[0].Id)
+			assert.Equal(t, byID.Id, deps[1].Id)
+			assert.Empty(t, deps[0].DeviceList, "device list was not projected away")
+		}
+	}
+
+	deps, count, err = ds.FindDeploymentsByArtifact(ctx, "App 123", "", model.Query{
+		Limit:          1,
+		IncludeDeleted: true,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), count)
+		if assert.Len(t, deps, 1) {
+			assert.Equal(t, byName.Id, deps[0].Id)
+		}
+	}
+
+	_, _, err = ds.FindDeploymentsByArtifact(ctx, "", "", model.Query{})
+	assert.ErrorIs(t, err, ErrImagesStorageInvalidArtifactName)
+}
+
+func TestFindDeploymentsByGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindDeploymentsByGroup in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(id string, group string, age time.Duration) *model.Deployment {
+		created := now.Add(-age)
+		depl := &model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670" + id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: "App 123",
+				Group:        group,
+			},
+			Created:    &created,
+			DeviceList: []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		}
+		if group != "" {
+			depl.Groups = []string{group}
+		}
+		return depl
+	}
+	older := newDeployment("1", "prod", time.Hour)
+	newer := newDeployment("2", "prod", time.Minute)
+	deleted := newDeployment("3", "prod", 2*time.Hour)
+	deleted.Deleted = TimePtr(now)
+	// a single device deployment lists the groups of its device
+	singleDevice := newDeployment("6", "", time.Second)
+	singleDevice.Groups = []string{"prod"}
+	for _, depl := range []*model.Deployment{
+		older,
+		newer,
+		deleted,
+		newDeployment("4", "staging", time.Second),
+		newDeployment("5", "", time.Second),
+		singleDevice,
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	deps, count, err := ds.FindDeploymentsByGroup(ctx, "prod", model.Query{
+		Limit: 10,
+		Sort:  model.SortDirectionDescending,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), count)
+		if assert.Len(t, deps, 2) {
+			assert.Equal(t, newer.Id, deps[0].Id)
+			assert.Equal(t, older.Id, deps[1].Id)
+			assert.Empty(t, deps[0].DeviceList, "device list was not projected away")
+		}
+	}
+
+	deps, count, err = ds.FindDeploymentsByGroup(ctx, "missing", model.Query{Limit: 10})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), count)
+		assert.Empty(t, deps)
+	}
+
+	_, _, err = ds.FindDeploymentsByGroup(ctx, "", model.Query{})
+	assert.ErrorIs(t, err, ErrStorageInvalidInput)
+}
+
+func TestDeploymentStorageFindByDeviceID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindByDeviceID in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(id string, devices []string, age time.Duration) *model.Deployment {
+		created := now.Add(-age)
+		return &model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670" + id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: "App 123",
+			},
+			Created:    &created,
+			DeviceList: devices,
+		}
+	}
+	older := newDeployment("1", []string{"device-1", "device-2"}, time.Hour)
+	newer := newDeployment("2", []string{"device-1"}, time.Minute)
+	deleted := newDeployment("3", []string{"device-1"}, 2*time.Hour)
+	deleted.Deleted = TimePtr(now)
+	for _, depl := range []*model.Deployment{
+		older,
+		newer,
+		deleted,
+		newDeployment("4", []string{"device-2"}, time.Second),
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	deps, count, err := ds.Find(ctx, model.Query{
+		DeviceID: "device-1",
+		Limit:    10,
+		Sort:     model.SortDirectionDescending,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), count)
+		if assert.Len(t, deps, 2) {
+			assert.Equal(t, newer.Id, deps[0].Id)
+			assert.Equal(t, older.Id, deps[1].Id)
+		}
+	}
+
+	deps, count, err = ds.Find(ctx, model.Query{DeviceID: "device-3", Limit: 10})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), count)
+		assert.Empty(t, deps)
+	}
+}
+
+func TestFindStaleActiveDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindStaleActiveDeployments in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	m := &migration_1_2_10{client: db.Client(), db: DatabaseName}
+	assert.NoError(t, m.Up(m.Version()))
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(
+		id string,
+		status model.DeploymentStatus,
+		stats model.Stats,
+		age time.Duration,
+	) *model.Deployment {
+		created := now.Add(-age)
+		return &model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670" + id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: "App 123",
+			},
+			Created:    &created,
+			Status:     status,
+			Stats:      stats,
+			DeviceList: []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		}
+	}
+	pending := newDeployment("1", model.DeploymentStatusPending,
+		model.Stats{model.DeviceDeploymentStatusPendingStr: 2}, 48*time.Hour)
+	downloading := newDeployment("2", model.DeploymentStatusInProgress,
+		model.Stats{model.DeviceDeploymentStatusDownloadingStr: 1}, 30*time.Hour)
+	for _, depl := range []*model.Deployment{
+		downloading,
+		pending,
+		// installing devices are progressing
+		newDeployment("3", model.DeploymentStatusInProgress,
+			model.Stats{model.DeviceDeploymentStatusInstallingStr: 1}, 48*time.Hour),
+		// too recent
+		newDeployment("4", model.DeploymentStatusPending,
+			model.Stats{model.DeviceDeploymentStatusPendingStr: 1}, time.Hour),
+		// finished
+		newDeployment("5", model.DeploymentStatusFinished,
+			model.Stats{model.DeviceDeploymentStatusPendingStr: 1}, 48*time.Hour),
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	deps, err := ds.FindStaleActiveDeployments(ctx, now.Add(-24*time.Hour), 10)
+	if assert.NoError(t, err) && assert.Len(t, deps, 2) {
+		assert.Equal(t, pending.Id, deps[0].Id)
+		assert.Equal(t, downloading.Id, deps[1].Id)
+		assert.Empty(t, deps[0].DeviceList, "device list was not projected away")
+	}
+
+	deps, err = ds.FindStaleActiveDeployments(ctx, now.Add(-24*time.Hour), 1)
+	if assert.NoError(t, err) && assert.Len(t, deps, 1) {
+		assert.Equal(t, pending.Id, deps[0].Id)
+	}
+}
+
+func TestGetReleaseRolloutStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetReleaseRolloutStats in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	m := &migration_1_2_20{client: db.Client(), db: DatabaseName}
+	assert.NoError(t, m.Up(m.Version()))
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(
+		id, artifactName string,
+		deviceCount int,
+		stats model.Stats,
+	) *model.Deployment {
+		created := now.Add(-time.Minute)
+		return &model.Deployment{
+			Id: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670" + id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: artifactName,
+			},
+			Created:     &created,
+			DeviceCount: &deviceCount,
+			Stats:       stats,
+		}
+	}
+	deleted := newDeployment("3", "release-1", 5, model.Stats{
+		model.DeviceDeploymentStatusSuccessStr: 5,
+	})
+	deleted.Deleted = TimePtr(now)
+	for _, depl := range []*model.Deployment{
+		newDeployment("1", "release-1", 3, model.Stats{
+			model.DeviceDeploymentStatusSuccessStr: 2,
+			model.DeviceDeploymentStatusFailureStr: 1,
+		}),
+		newDeployment("2", "release-1", 2, model.Stats{
+			model.DeviceDeploymentStatusSuccessStr:     1,
+			model.DeviceDeploymentStatusDownloadingStr: 1,
+		}),
+		deleted,
+		newDeployment("4", "release-2", 1, model.Stats{
+			model.DeviceDeploymentStatusPendingStr: 1,
+		}),
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	stats, err := ds.GetReleaseRolloutStats(ctx, "release-1", false)
+	if assert.NoError(t, err) {
+		expected := model.NewDeviceDeploymentStats()
+		expected[model.DeviceDeploymentStatusSuccessStr] = 3
+		expected[model.DeviceDeploymentStatusFailureStr] = 1
+		expected[model.DeviceDeploymentStatusDownloadingStr] = 1
+		assert.Equal(t, &model.ReleaseRolloutStats{
+			DeploymentCount: 2,
+			DeviceCount:     5,
+			Statistics:      expected,
+		}, stats)
+	}
+
+	stats, err = ds.GetReleaseRolloutStats(ctx, "release-1", true)
+	if assert.NoError(t, err) {
+		expected := model.NewDeviceDeploymentStats()
+		expected[model.DeviceDeploymentStatusSuccessStr] = 8
+		expected[model.DeviceDeploymentStatusFailureStr] = 1
+		expected[model.DeviceDeploymentStatusDownloadingStr] = 1
+		assert.Equal(t, &model.ReleaseRolloutStats{
+			DeploymentCount: 3,
+			DeviceCount:     10,
+			Statistics:      expected,
+		}, stats)
+	}
+
+	stats, err = ds.GetReleaseRolloutStats(ctx, "release-3", false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &model.ReleaseRolloutStats{
+			Statistics: model.NewDeviceDeploymentStats(),
+		}, stats)
+	}
+
+	_, err = ds.GetReleaseRolloutStats(ctx, "", false)
+	assert.ErrorIs(t, err, ErrImagesStorageInvalidArtifactName)
+}
+
+func TestCountDeploymentsByStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestCountDeploymentsByStatus in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: "tenant"})
+	for _, c := range []context.Context{ctx, tenantCtx} {
+		assert.NoError(t, ds.EnsureIndexes(
+			mstore.DbFromContext(c, DatabaseName),
+			CollectionDeployments,
+			DeploymentStatusIndex,
+		))
+	}
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	newDeployment := func(status model.DeploymentStatus) *model.Deployment {
+		id, _ := uuid.NewRandom()
+		return &model.Deployment{
+			Id: id.String(),
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment",
+				ArtifactName: "release-1",
+			},
+			Created:    &now,
+			Status:     status,
+			DeviceList: []string{"device"},
+		}
+	}
+	deleted := newDeployment(model.DeploymentStatusFinished)
+	deleted.Deleted = TimePtr(now)
+	for _, depl := range []*model.Deployment{
+		newDeployment(model.DeploymentStatusPending),
+		newDeployment(model.DeploymentStatusPending),
+		newDeployment(model.DeploymentStatusInProgress),
+		newDeployment(model.DeploymentStatusFinished),
+		deleted,
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+	assert.NoError(t, ds.InsertDeployment(tenantCtx,
+		newDeployment(model.DeploymentStatusPaused)))
+
+	counts, err := ds.CountDeploymentsByStatus(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[model.DeploymentStatus]int{
+		model.DeploymentStatusPending:    2,
+		model.DeploymentStatusInProgress: 1,
+		model.DeploymentStatusFinished:   1,
+	}, counts)
+
+	counts, err = ds.CountDeploymentsByStatus(tenantCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[model.DeploymentStatus]int{
+		model.DeploymentStatusPaused: 1,
+	}, counts)
+}
+
+func TestGetLargestDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetLargestDeployments in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	m := &migration_1_2_21{client: db.Client(), db: DatabaseName}
+	assert.NoError(t, m.Up(m.Version()))
+
+	const (
+		idSmall     = "a7d1fbd0-5b8a-4d0e-9ad2-bc4a8f1b9c01"
+		idUncounted = "a7d1fbd0-5b8a-4d0e-9ad2-bc4a8f1b9c02"
+		idLargest   = "a7d1fbd0-5b8a-4d0e-9ad2-bc4a8f1b9c03"
+		idDeleted   = "a7d1fbd0-5b8a-4d0e-9ad2-bc4a8f1b9c04"
+		idLarge     = "a7d1fbd0-5b8a-4d0e-9ad2-bc4a8f1b9c05"
+	)
+	now := time.Now().UTC().Round(time.Millisecond)
+	count := func(n int) *int { return &n }
+	newDeployment := func(id string, deviceCount *int) *model.Deployment {
+		return &model.Deployment{
+			Id: id,
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment " + id,
+				ArtifactName: "release-1",
+			},
+			Created:     &now,
+			DeviceCount: deviceCount,
+			DeviceList:  []string{"device"},
+		}
+	}
+	deleted := newDeployment(idDeleted, count(1000))
+	deleted.Deleted = TimePtr(now)
+	for _, depl := range []*model.Deployment{
+		newDeployment(idSmall, count(3)),
+		newDeployment(idUncounted, nil),
+		newDeployment(idLargest, count(250)),
+		deleted,
+		newDeployment(idLarge, count(40)),
+	} {
+		assert.NoError(t, ds.InsertDeployment(ctx, depl))
+	}
+
+	deployments, err := ds.GetLargestDeployments(ctx, 10)
+	if assert.NoError(t, err) {
+		ids := make([]string, len(deployments))
+		for i, depl := range deployments {
+			ids[i] = depl.Id
+			assert.Empty(t, depl.DeviceList)
+		}
+		assert.Equal(t, []string{idLargest, idLarge, idSmall, idUncounted}, ids)
+	}
+
+	deployments, err = ds.GetLargestDeployments(ctx, 2)
+	if assert.NoError(t, err) && assert.Len(t, deployments, 2) {
+		assert.Equal(t, idLargest, deployments[0].Id)
+		assert.Equal(t, idLarge, deployments[1].Id)
+	}
+}
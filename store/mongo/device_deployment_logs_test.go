@@ -27,6 +27,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/mendersoftware/deployments/model"
+	dstore "github.com/mendersoftware/deployments/store"
 )
 
 func parseTime(t *testing.T, value string) *time.Time {
@@ -273,16 +274,22 @@ func TestGetDeviceDeploymentLog(t *testing.T) {
 			ctx = context.Background()
 		}
 
-		dlog, err := store.GetDeviceDeploymentLog(ctx,
-			testCase.InputDeviceID, testCase.InputDeploymentID)
+		dlogs, total, err := store.GetDeviceDeploymentLog(ctx,
+			dstore.ListQueryDeviceDeploymentLog{
+				DeviceID:     testCase.InputDeviceID,
+				DeploymentID: testCase.InputDeploymentID,
+			})
 		if testCase.OutputError != nil {
 			assert.EqualError(t, err, testCase.OutputError.Error())
+		} else if testCase.InputDeploymentLog == nil {
+			assert.Equal(t, dstore.ErrNotFound, err)
+			assert.Empty(t, dlogs)
 		} else {
 			assert.NoError(t, err)
+			assert.Equal(t, len(testCase.InputDeploymentLog.Messages), total)
 
-			if testCase.InputDeploymentLog == nil {
-				assert.Nil(t, dlog)
-			} else {
+			if assert.Len(t, dlogs, 1) {
+				dlog := dlogs[0]
 				assert.Equal(t, testCase.InputDeploymentID, dlog.DeploymentID)
 				assert.Equal(t, testCase.InputDeviceID, dlog.DeviceID)
 				// message timestamp is a pointer, so we cannot use assert.EqualValues()
@@ -299,3 +306,167 @@ func TestGetDeviceDeploymentLog(t *testing.T) {
 	}
 	db.Wipe()
 }
+
+func TestDeviceDeploymentLogAttempts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeviceDeploymentLogAttempts in short mode.")
+	}
+	db.Wipe()
+
+	const (
+		deviceID     = "123"
+		deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	)
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	message := func(msg string) []model.LogMessage {
+		return []model.LogMessage{{
+			Level:     "error",
+			Message:   msg,
+			Timestamp: parseTime(t, "2006-01-02T15:04:05-07:00"),
+		}}
+	}
+
+	for _, dl := range []model.DeploymentLog{{
+		DeviceID:     deviceID,
+		DeploymentID: deploymentID,
+		Messages:     message("first attempt failed"),
+	}, {
+		DeviceID:     deviceID,
+		DeploymentID: deploymentID,
+		Attempt:      1,
+		Messages:     message("second attempt failed"),
+	}, {
+		// overwrites the log of the second attempt only
+		DeviceID:     deviceID,
+		DeploymentID: deploymentID,
+		Attempt:      1,
+		Messages:     message("second attempt failed again"),
+	}} {
+		assert.NoError(t, store.SaveDeviceDeploymentLog(ctx, dl))
+	}
+
+	query := dstore.ListQueryDeviceDeploymentLog{
+		DeviceID:     deviceID,
+		DeploymentID: deploymentID,
+	}
+	dlogs, _, err := store.GetDeviceDeploymentLog(ctx, query)
+	assert.NoError(t, err)
+	if assert.Len(t, dlogs, 2) {
+		assert.Equal(t, uint(0), dlogs[0].Attempt)
+		assert.Equal(t, "first attempt failed", dlogs[0].Messages[0].Message)
+		assert.Equal(t, uint(1), dlogs[1].Attempt)
+		assert.Equal(t, "second attempt failed again", dlogs[1].Messages[0].Message)
+	}
+
+	for attempt, expected := range []string{
+		"first attempt failed",
+		"second attempt failed again",
+	} {
+		a := uint(attempt)
+		query.Attempt = &a
+		dlogs, _, err = store.GetDeviceDeploymentLog(ctx, query)
+		assert.NoError(t, err)
+		if assert.Len(t, dlogs, 1) {
+			assert.Equal(t, a, dlogs[0].Attempt)
+			assert.Equal(t, expected, dlogs[0].Messages[0].Message)
+		}
+	}
+
+	a := uint(2)
+	query.Attempt = &a
+	dlogs, _, err = store.GetDeviceDeploymentLog(ctx, query)
+	assert.Equal(t, dstore.ErrNotFound, err)
+	assert.Empty(t, dlogs)
+
+	// pages through the messages of all attempts
+	query.Attempt = nil
+	query.Skip, query.Limit = 1, 1
+	dlogs, total, err := store.GetDeviceDeploymentLog(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	if assert.Len(t, dlogs, 2) {
+		assert.Empty(t, dlogs[0].Messages)
+		if assert.Len(t, dlogs[1].Messages, 1) {
+			assert.Equal(t, "second attempt failed again", dlogs[1].Messages[0].Message)
+		}
+	}
+}
+
+func TestPurgeDeviceDeploymentLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestPurgeDeviceDeploymentLogs in short mode.")
+	}
+	db.Wipe()
+
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	collDevs := db.Client().Database(DbName).Collection(CollectionDevices)
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	old := now.Add(-48 * time.Hour)
+	cutoff := now.Add(-24 * time.Hour)
+	deviceDeployments := []*model.DeviceDeployment{{
+		// finished before the cutoff
+		Id:             "dd1",
+		DeviceId:       "device1",
+		DeploymentId:   deploymentID,
+		Finished:       &old,
+		IsLogAvailable: true,
+	}, {
+		// finished after the cutoff
+		Id:             "dd2",
+		DeviceId:       "device2",
+		DeploymentId:   deploymentID,
+		Finished:       &now,
+		IsLogAvailable: true,
+	}, {
+		// not finished yet
+		Id:             "dd3",
+		DeviceId:       "device3",
+		DeploymentId:   deploymentID,
+		IsLogAvailable: true,
+	}}
+	for _, dd := range deviceDeployments {
+		_, err := collDevs.InsertOne(ctx, dd)
+		assert.NoError(t, err)
+		for attempt := uint(0); attempt < 2; attempt++ {
+			assert.NoError(t, store.SaveDeviceDeploymentLog(ctx, model.DeploymentLog{
+				DeviceID:     dd.DeviceId,
+				DeploymentID: deploymentID,
+				Attempt:      attempt,
+				Messages: []model.LogMessage{{
+					Level:     "error",
+					Message:   "failed",
+					Timestamp: &old,
+				}},
+			}))
+		}
+	}
+
+	purged, err := store.PurgeDeviceDeploymentLogs(ctx, cutoff)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	for _, dd := range deviceDeployments {
+		_, _, err := store.GetDeviceDeploymentLog(ctx, dstore.ListQueryDeviceDeploymentLog{
+			DeviceID:     dd.DeviceId,
+			DeploymentID: deploymentID,
+		})
+		var stored model.DeviceDeployment
+		assert.NoError(t, collDevs.FindOne(ctx, bson.M{"_id": dd.Id}).Decode(&stored))
+		if dd.Id == "dd1" {
+			assert.Equal(t, dstore.ErrNotFound, err)
+			assert.False(t, stored.IsLogAvailable)
+		} else {
+			assert.NoError(t, err)
+			assert.True(t, stored.IsLogAvailable)
+		}
+	}
+
+	// nothing left to purge
+	purged, err = store.PurgeDeviceDeploymentLogs(ctx, cutoff)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+}
@@ -350,6 +350,63 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 	}
 }
 
+func TestFindDeviceDeploymentsAwaitingConfirmation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindDeviceDeploymentsAwaitingConfirmation in short mode.")
+	}
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	expired := model.NewDeviceDeployment("expired", deploymentID)
+	pending := model.NewDeviceDeployment("pending", deploymentID)
+	confirmed := model.NewDeviceDeployment("confirmed", deploymentID)
+	assert.NoError(t, ds.InsertMany(ctx, expired, pending, confirmed))
+
+	for _, update := range []struct {
+		deviceID string
+		state    model.DeviceDeploymentState
+	}{
+		{"expired", model.DeviceDeploymentState{
+			Status:               model.DeviceDeploymentStatusAwaitingConfirmation,
+			ConfirmationDeadline: &past,
+		}},
+		{"pending", model.DeviceDeploymentState{
+			Status:               model.DeviceDeploymentStatusAwaitingConfirmation,
+			ConfirmationDeadline: &future,
+		}},
+		{"confirmed", model.DeviceDeploymentState{
+			Status:               model.DeviceDeploymentStatusAwaitingConfirmation,
+			ConfirmationDeadline: &past,
+		}},
+		{"confirmed", model.DeviceDeploymentState{
+			Status:     model.DeviceDeploymentStatusSuccess,
+			FinishTime: &now,
+		}},
+	} {
+		_, err := ds.UpdateDeviceDeploymentStatus(ctx, update.deviceID, deploymentID,
+			update.state, model.DeviceDeploymentStatusRebooting)
+		assert.NoError(t, err)
+	}
+
+	dds, err := ds.FindDeviceDeploymentsAwaitingConfirmation(ctx, now, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, dds, 1) {
+		assert.Equal(t, "expired", dds[0].DeviceId)
+		assert.True(t, dds[0].Active)
+	}
+
+	dd, err := ds.GetDeviceDeployment(ctx, deploymentID, "confirmed", false)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeviceDeploymentStatusSuccess, dd.Status)
+	assert.Nil(t, dd.ConfirmationDeadline)
+}
+
 func TestUpdateDeviceDeploymentStatusStarted(t *testing.T) {
 
 	if testing.Short() {
@@ -469,6 +526,99 @@ func TestUpdateDeviceDeploymentStatusStarted(t *testing.T) {
 	}
 }
 
+func TestUpdateDeviceDeploymentStatusSubStateHistory(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestUpdateDeviceDeploymentStatusSubStateHistory in short mode.")
+	}
+
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client()).
+		WithSubStateHistoryLength(2)
+
+	dd := model.NewDeviceDeployment("456", deploymentID)
+	err := store.InsertMany(ctx, dd)
+	assert.NoError(t, err)
+
+	reports := []model.DeviceDeploymentState{
+		{Status: model.DeviceDeploymentStatusDownloading, SubState: "fetching"},
+		{Status: model.DeviceDeploymentStatusInstalling, SubState: "ArtifactInstall_Enter"},
+		{Status: model.DeviceDeploymentStatusInstalling, SubState: "ArtifactInstall_Leave"},
+	}
+	for _, report := range reports {
+		_, err = store.UpdateDeviceDeploymentStatus(ctx,
+			"456", deploymentID, report, model.DeviceDeploymentStatusNull)
+		assert.NoError(t, err)
+	}
+
+	stored, err := store.GetDeviceDeployment(ctx, deploymentID, "456", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "ArtifactInstall_Leave", stored.SubState)
+	if assert.Len(t, stored.SubStateHistory, 2) {
+		assert.Equal(t, model.DeviceDeploymentStatusInstalling,
+			stored.SubStateHistory[0].Status)
+		assert.Equal(t, "ArtifactInstall_Enter", stored.SubStateHistory[0].SubState)
+		assert.Equal(t, "ArtifactInstall_Leave", stored.SubStateHistory[1].SubState)
+		assert.False(t, stored.SubStateHistory[1].Timestamp.Before(
+			stored.SubStateHistory[0].Timestamp))
+	}
+
+	// the history is disabled with a zero length
+	store = NewDataStoreMongoWithClient(db.Client())
+	dd = model.NewDeviceDeployment("567", deploymentID)
+	err = store.InsertMany(ctx, dd)
+	assert.NoError(t, err)
+	_, err = store.UpdateDeviceDeploymentStatus(ctx,
+		"567", deploymentID, reports[0], model.DeviceDeploymentStatusNull)
+	assert.NoError(t, err)
+	stored, err = store.GetDeviceDeployment(ctx, deploymentID, "567", false)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.SubStateHistory)
+}
+
+func TestSubStateHistoryClaimAndAbort(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestSubStateHistoryClaimAndAbort in short mode.")
+	}
+
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client()).
+		WithSubStateHistoryLength(2)
+
+	dd := model.NewDeviceDeployment("456", deploymentID)
+	err := store.InsertMany(ctx, dd)
+	assert.NoError(t, err)
+
+	claimed, _, err := store.FindAndClaimOldestActiveDeviceDeployment(ctx, "456")
+	assert.NoError(t, err)
+	if assert.NotNil(t, claimed) && assert.Len(t, claimed.SubStateHistory, 1) {
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading,
+			claimed.SubStateHistory[0].Status)
+	}
+	// claiming a device deployment in progress records nothing
+	_, _, err = store.FindAndClaimOldestActiveDeviceDeployment(ctx, "456")
+	assert.NoError(t, err)
+
+	_, err = store.AbortDeviceDeployments(ctx, deploymentID)
+	assert.NoError(t, err)
+
+	stored, err := store.GetDeviceDeployment(ctx, deploymentID, "456", false)
+	assert.NoError(t, err)
+	if assert.Len(t, stored.SubStateHistory, 2) {
+		assert.Equal(t, model.DeviceDeploymentStatusDownloading,
+			stored.SubStateHistory[0].Status)
+		assert.Equal(t, model.DeviceDeploymentStatusAborted,
+			stored.SubStateHistory[1].Status)
+	}
+}
+
 func TestUpdateDeviceDeploymentLogAvailability(t *testing.T) {
 
 	if testing.Short() {
@@ -691,6 +841,31 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 				model.DeviceDeploymentStatusFailureStr: 1,
 			}),
 		},
+		{
+			// a finished deployment: every device reached a final status
+			InputDeploymentID: "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+			InputDeviceDeployment: []*model.DeviceDeployment{
+				newDeviceDeploymentWithStatus(t, "123", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusSuccess),
+				newDeviceDeploymentWithStatus(t, "234", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusSuccess),
+				newDeviceDeploymentWithStatus(t, "345", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusFailure),
+				newDeviceDeploymentWithStatus(t, "456", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusAborted),
+				newDeviceDeploymentWithStatus(t, "567", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusNoArtifact),
+				newDeviceDeploymentWithStatus(t, "678", "8a6b9a35-8d4a-4b54-9d0f-3e0e7a9c1f27",
+					model.DeviceDeploymentStatusAlreadyInst),
+			},
+			OutputStats: newTestStats(model.Stats{
+				model.DeviceDeploymentStatusSuccessStr:     2,
+				model.DeviceDeploymentStatusFailureStr:     1,
+				model.DeviceDeploymentStatusAbortedStr:     1,
+				model.DeviceDeploymentStatusNoArtifactStr:  1,
+				model.DeviceDeploymentStatusAlreadyInstStr: 1,
+			}),
+		},
 	}
 
 	for testCaseNumber, testCase := range testCases {
@@ -742,96 +917,275 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 	}
 }
 
-func TestGetDeviceStatusesForDeployment(t *testing.T) {
+func TestFindDeviceDeploymentCountsByDeploymentIDs(t *testing.T) {
 	if testing.Short() {
-		t.Skip("skipping GetDeviceStatusesForDeployment in short mode.")
+		t.Skip("skipping TestFindDeviceDeploymentCountsByDeploymentIDs in short mode.")
 	}
 
-	input := []*model.DeviceDeployment{}
-
-	dds := []struct {
-		did   string
-		depid string
-	}{
-		{"device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"},
-		{"device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"},
-		{"device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"},
-		{"device0004", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"},
-		{"device0005", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"},
-	}
-
-	for _, dd := range dds {
-		newdd := model.NewDeviceDeployment(dd.did, dd.depid)
-		input = append(input, newdd)
-	}
+	const (
+		deploymentA = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+		deploymentB = "ee13ea8b-a6d3-4d4c-99a6-bcfcaebc7ec3"
+		deploymentC = "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+	)
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	deleted := newDeviceDeploymentWithStatus(t, "345", deploymentA,
+		model.DeviceDeploymentStatusFailure)
+	deleted.Deleted = TimePtr(time.Now())
+	err := ds.InsertMany(ctx,
+		newDeviceDeploymentWithStatus(t, "123", deploymentA,
+			model.DeviceDeploymentStatusFailure),
+		newDeviceDeploymentWithStatus(t, "234", deploymentA,
+			model.DeviceDeploymentStatusSuccess),
+		newDeviceDeploymentWithStatus(t, "456", deploymentA,
+			model.DeviceDeploymentStatusSuccess),
+		deleted,
+		newDeviceDeploymentWithStatus(t, "123", deploymentB,
+			model.DeviceDeploymentStatusPending),
+		newDeviceDeploymentWithStatus(t, "123", deploymentC,
+			model.DeviceDeploymentStatusPending),
+	)
+	assert.NoError(t, err)
 
 	testCases := map[string]struct {
-		caseId string
-		tenant string
+		ids []string
 
-		inputDeploymentId string
-		outputStatuses    []*model.DeviceDeployment
+		stats map[string]model.Stats
 	}{
-		"existing deployments 1": {
-			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
-			outputStatuses:    input[:3],
-		},
-		"existing deployments 2": {
-			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
-			outputStatuses:    input[3:],
+		"ok": {
+			ids: []string{deploymentA, deploymentB},
+			stats: map[string]model.Stats{
+				deploymentA: newTestStats(model.Stats{
+					model.DeviceDeploymentStatusFailureStr: 1,
+					model.DeviceDeploymentStatusSuccessStr: 2,
+				}),
+				deploymentB: newTestStats(model.Stats{
+					model.DeviceDeploymentStatusPendingStr: 1,
+				}),
+			},
 		},
-		"nonexistent deployment": {
-			inputDeploymentId: "aaaaaaaa-9ec2-4312-a7fa-cff24cc7397b",
-			outputStatuses:    []*model.DeviceDeployment{},
+		"ok, deployment without device deployments": {
+			ids: []string{deploymentC, "2e6a3e8a-7b1f-4b8e-9d8c-3e0f6a0c7d21"},
+			stats: map[string]model.Stats{
+				deploymentC: newTestStats(model.Stats{
+					model.DeviceDeploymentStatusPendingStr: 1,
+				}),
+				"2e6a3e8a-7b1f-4b8e-9d8c-3e0f6a0c7d21": newTestStats(model.Stats{}),
+			},
 		},
-		"tenant, existing deployments": {
-			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
-			tenant:            "acme",
-			outputStatuses:    input[3:],
+		"ok, no ids": {
+			stats: map[string]model.Stats{},
 		},
 	}
 
-	for testCaseName, tc := range testCases {
-		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			stats, err := ds.FindDeviceDeploymentCountsByDeploymentIDs(ctx, tc.ids)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.stats, stats)
+		})
+	}
+}
 
-			// setup db - once for all cases
-			db.Wipe()
+func TestIterateDeviceStatusesForDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping IterateDeviceStatusesForDeployment in short mode.")
+	}
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	finished := time.Now().UTC().Truncate(time.Millisecond)
+	installed := model.NewDeviceDeployment("device0001", deploymentID)
+	installed.Status = model.DeviceDeploymentStatusSuccess
+	installed.SubState = "done"
+	installed.Finished = &finished
+	installed.Image = &model.Image{
+		Id: "image-1",
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"arm6"},
+		},
+	}
+	pending := model.NewDeviceDeployment("device0002", deploymentID)
+	deleted := model.NewDeviceDeployment("device0003", deploymentID)
+	deleted.Deleted = &finished
+	other := model.NewDeviceDeployment("device0004",
+		"30b3e62c-9ec2-4312-a7fa-cff24cc7397b")
+	err := ds.InsertMany(ctx, installed, pending, deleted, other)
+	if !assert.NoError(t, err) {
+		return
+	}
 
-			client := db.Client()
-			store := NewDataStoreMongoWithClient(client)
+	it, err := ds.IterateDeviceStatusesForDeployment(ctx, deploymentID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer it.Close(ctx)
+	statuses := map[string]model.DeviceDeployment{}
+	for {
+		next, err := it.Next(ctx)
+		if !assert.NoError(t, err) || !next {
+			break
+		}
+		var dd model.DeviceDeployment
+		if assert.NoError(t, it.Decode(&dd)) {
+			statuses[dd.DeviceId] = dd
+		}
+	}
+	if assert.Len(t, statuses, 2) {
+		dd := statuses["device0001"]
+		assert.Equal(t, model.DeviceDeploymentStatusSuccess, dd.Status)
+		assert.Equal(t, "done", dd.SubState)
+		if assert.NotNil(t, dd.Finished) {
+			assert.True(t, finished.Equal(*dd.Finished))
+		}
+		if assert.NotNil(t, dd.Image) && assert.NotNil(t, dd.Image.ArtifactMeta) {
+			assert.Equal(t, "release-1", dd.Image.ArtifactMeta.Name)
+			assert.Empty(t, dd.Image.ArtifactMeta.DeviceTypesCompatible)
+		}
+		assert.Equal(t, model.DeviceDeploymentStatusPending,
+			statuses["device0002"].Status)
+	}
+}
 
-			ctx := context.Background()
-			if tc.tenant != "" {
-				ctx = identity.WithContext(ctx, &identity.Identity{
-					Tenant: tc.tenant,
-				})
+func TestIterateDevicesListForDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping IterateDevicesListForDeployment in short mode.")
+	}
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	installed := model.NewDeviceDeployment("device0001", deploymentID)
+	installed.Status = model.DeviceDeploymentStatusSuccess
+	installed.Image = &model.Image{
+		Id: "image-1",
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"arm6"},
+		},
+	}
+	pending := model.NewDeviceDeployment("device0002", deploymentID)
+	failed := model.NewDeviceDeployment("device0003", deploymentID)
+	failed.Status = model.DeviceDeploymentStatusFailure
+	other := model.NewDeviceDeployment("device0004",
+		"30b3e62c-9ec2-4312-a7fa-cff24cc7397b")
+	err := ds.InsertMany(ctx, installed, pending, failed, other)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	list := func(q store.ListQuery) []model.DeviceDeployment {
+		it, err := ds.IterateDevicesListForDeployment(ctx, q)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		defer it.Close(ctx)
+		var deviceDeployments []model.DeviceDeployment
+		for {
+			next, err := it.Next(ctx)
+			if !assert.NoError(t, err) || !next {
+				break
 			}
+			var dd model.DeviceDeployment
+			if assert.NoError(t, it.Decode(&dd)) {
+				deviceDeployments = append(deviceDeployments, dd)
+			}
+		}
+		return deviceDeployments
+	}
+	deviceIDs := func(deviceDeployments []model.DeviceDeployment) []string {
+		ids := make([]string, len(deviceDeployments))
+		for i, dd := range deviceDeployments {
+			ids[i] = dd.DeviceId
+		}
+		return ids
+	}
 
-			err := store.InsertMany(ctx, input...)
-			assert.NoError(t, err)
+	// no limit: the whole deployment, sorted by status, full documents
+	deviceDeployments := list(store.ListQuery{DeploymentID: deploymentID})
+	assert.Equal(t,
+		[]string{"device0003", "device0002", "device0001"},
+		deviceIDs(deviceDeployments))
+	if assert.Len(t, deviceDeployments, 3) &&
+		assert.NotNil(t, deviceDeployments[2].Image) {
+		assert.Equal(t, []string{"arm6"},
+			deviceDeployments[2].Image.ArtifactMeta.DeviceTypesCompatible)
+	}
 
-			statuses, err := store.GetDeviceStatusesForDeployment(ctx,
-				tc.inputDeploymentId)
-			assert.NoError(t, err)
+	status := model.DeviceDeploymentStatusFinishedStr
+	assert.Equal(t,
+		[]string{"device0003"},
+		deviceIDs(list(store.ListQuery{
+			DeploymentID: deploymentID,
+			Status:       &status,
+			Sort:         store.ListQuerySortCreatedAsc,
+			Skip:         1,
+			Limit:        1,
+		})))
+}
 
-			assert.Equal(t, len(tc.outputStatuses), len(statuses))
-			for i, out := range tc.outputStatuses {
-				assert.Equal(t, out.DeviceId, statuses[i].DeviceId)
-				assert.Equal(t, out.DeploymentId, statuses[i].DeploymentId)
-			}
+func TestAssignedImageWithIDAndStatuses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestAssignedImageWithIDAndStatuses in short mode.")
+	}
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
 
-			if tc.tenant != "" {
-				// deployment statuses are present in tenant's
-				// DB, verify that listing from default DB
-				// yields empty list
-				statuses, err := store.GetDeviceStatusesForDeployment(
-					context.Background(),
-					tc.inputDeploymentId)
-				assert.NoError(t, err)
-				assert.Len(t, statuses, 0)
-			}
-		})
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	image := &model.Image{
+		Id: "image-1",
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "release-1",
+			DeviceTypesCompatible: []string{"arm6"},
+		},
+	}
+	var deviceDeployments []*model.DeviceDeployment
+	for i, status := range []model.DeviceDeploymentStatus{
+		model.DeviceDeploymentStatusDownloading,
+		model.DeviceDeploymentStatusInstalling,
+		model.DeviceDeploymentStatusSuccess,
+	} {
+		dd := model.NewDeviceDeployment(fmt.Sprintf("device000%d", i), deploymentID)
+		dd.Status = status
+		dd.Image = image
+		deviceDeployments = append(deviceDeployments, dd)
 	}
+	if !assert.NoError(t, ds.InsertMany(ctx, deviceDeployments...)) {
+		return
+	}
+	activeStatuses := model.ActiveDeploymentStatuses()
+
+	assigned, err := ds.ExistAssignedImageWithIDAndStatuses(ctx, image.Id, activeStatuses)
+	assert.NoError(t, err)
+	assert.True(t, assigned)
+	assigned, err = ds.ExistAssignedImageWithIDAndStatuses(ctx, "image-2", activeStatuses)
+	assert.NoError(t, err)
+	assert.False(t, assigned)
+
+	cleared, err := ds.ClearAssignedImage(ctx, image.Id, activeStatuses)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), cleared)
+
+	assigned, err = ds.ExistAssignedImageWithIDAndStatuses(ctx, image.Id, activeStatuses)
+	assert.NoError(t, err)
+	assert.False(t, assigned)
+	// the finished device deployment keeps its image
+	assigned, err = ds.ExistAssignedImageWithIDAndStatuses(ctx, image.Id,
+		[]model.DeviceDeploymentStatus{model.DeviceDeploymentStatusSuccess})
+	assert.NoError(t, err)
+	assert.True(t, assigned)
 }
 
 func TestGetDevicesListForDeployment(t *testing.T) {
@@ -901,10 +1255,12 @@ func TestGetDevicesListForDeployment(t *testing.T) {
 		status: model.DeviceDeploymentStatusDecommissioned,
 	}}
 	input := make([]model.DeviceDeployment, len(dds))
+	// strip timezone and monotonic time (lost when writing to db)
+	created := time.Now().UTC().Round(time.Millisecond)
 	for i, dd := range dds {
 		newdd := model.NewDeviceDeployment(dd.did, dd.depid)
-		// strip timezone and monotonic time (lost when writing to db)
-		notz := newdd.Created.UTC().Round(time.Millisecond)
+		// created in the order of the input
+		notz := created.Add(time.Duration(i) * time.Second)
 		newdd.Created = &notz
 		newdd.Status = dd.status
 		input[i] = *newdd
@@ -967,6 +1323,40 @@ func TestGetDevicesListForDeployment(t *testing.T) {
 				input[13],
 			},
 		},
+		"sort by creation, ascending": {
+			inputListQuery: store.ListQuery{
+				DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+				Sort:         store.ListQuerySortCreatedAsc,
+			},
+			outputStatuses: input[1:],
+		},
+		"sort by creation, descending + limit": {
+			inputListQuery: store.ListQuery{
+				DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+				Sort:         store.ListQuerySortCreatedDesc,
+				Limit:        3,
+			},
+			outputStatuses: []model.DeviceDeployment{
+				input[13],
+				input[12],
+				input[11],
+			},
+		},
+		"sort by creation, descending + status filter": {
+			inputListQuery: store.ListQuery{
+				DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+				Sort:         store.ListQuerySortCreatedDesc,
+				Status: func() *string {
+					s := "pause"
+					return &s
+				}(),
+			},
+			outputStatuses: []model.DeviceDeployment{
+				input[5],
+				input[4],
+				input[3],
+			},
+		},
 		"nonexistent deployment": {
 			inputListQuery: store.ListQuery{
 				DeploymentID: "aaaaaaaa-9ec2-4312-a7fa-cff24cc7397b",
@@ -1182,6 +1572,175 @@ func TestHasDeploymentForDevice(t *testing.T) {
 	}
 }
 
+func TestCountActiveDeploymentsByDevice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestCountActiveDeploymentsByDevice in short mode.")
+	}
+
+	now := time.Now()
+	active := model.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+	another := model.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b")
+	inactive := model.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397c")
+	inactive.Active = false
+	inactive.Status = model.DeviceDeploymentStatusSuccess
+	deleted := model.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397d")
+	deleted.Deleted = &now
+	other := model.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+
+	input := []*model.DeviceDeployment{active, another, inactive, deleted, other}
+
+	testCases := map[string]struct {
+		deviceID string
+		tenant   string
+
+		count int
+		err   error
+	}{
+		"ok": {
+			deviceID: "device0001",
+			count:    2,
+		},
+		"ok, other device": {
+			deviceID: "device0002",
+			count:    1,
+		},
+		"ok, no deployments": {
+			deviceID: "device0003",
+			count:    0,
+		},
+		"ok, tenant": {
+			deviceID: "device0001",
+			tenant:   "acme",
+			count:    2,
+		},
+		"ko, empty device id": {
+			err: ErrStorageInvalidID,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			store := NewDataStoreMongoWithClient(db.Client())
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			err := store.InsertMany(ctx, input...)
+			assert.NoError(t, err)
+
+			count, err := store.CountActiveDeploymentsByDevice(ctx, tc.deviceID)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.count, count)
+			}
+		})
+	}
+}
+
+func TestRetryDeviceDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestRetryDeviceDeployment in short mode.")
+	}
+
+	const deploymentID = "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	now := time.Now()
+
+	testCases := map[string]struct {
+		status   model.DeviceDeploymentStatus
+		attempts uint
+		deleted  bool
+
+		deviceID   string
+		maxRetries uint
+
+		retried bool
+		err     error
+	}{
+		"ok, first retry": {
+			status:     model.DeviceDeploymentStatusFailure,
+			deviceID:   "device0001",
+			maxRetries: 2,
+			retried:    true,
+		},
+		"ok, last retry": {
+			status:     model.DeviceDeploymentStatusFailure,
+			attempts:   1,
+			deviceID:   "device0001",
+			maxRetries: 2,
+			retried:    true,
+		},
+		"ok, retries exhausted": {
+			status:     model.DeviceDeploymentStatusFailure,
+			attempts:   2,
+			deviceID:   "device0001",
+			maxRetries: 2,
+		},
+		"ok, not failed": {
+			status:     model.DeviceDeploymentStatusSuccess,
+			deviceID:   "device0001",
+			maxRetries: 2,
+		},
+		"ok, deleted": {
+			status:     model.DeviceDeploymentStatusFailure,
+			deleted:    true,
+			deviceID:   "device0001",
+			maxRetries: 2,
+		},
+		"ko, empty device id": {
+			err: ErrStorageInvalidID,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db.Wipe()
+
+			store := NewDataStoreMongoWithClient(db.Client())
+			ctx := context.Background()
+
+			dd := model.NewDeviceDeployment("device0001", deploymentID)
+			dd.Status = tc.status
+			dd.Active = false
+			dd.Finished = &now
+			dd.Attempts = tc.attempts
+			if tc.deleted {
+				dd.Deleted = &now
+			}
+			err := store.InsertMany(ctx, dd)
+			assert.NoError(t, err)
+
+			retried, err := store.RetryDeviceDeployment(ctx,
+				tc.deviceID, deploymentID, tc.maxRetries)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.retried, retried)
+
+			res, err := store.GetDeviceDeployment(ctx, deploymentID, "device0001", true)
+			assert.NoError(t, err)
+			if tc.retried {
+				assert.Equal(t, model.DeviceDeploymentStatusPending, res.Status)
+				assert.True(t, res.Active)
+				assert.Nil(t, res.Finished)
+				assert.Equal(t, tc.attempts+1, res.Attempts)
+			} else {
+				assert.Equal(t, tc.status, res.Status)
+				assert.Equal(t, tc.attempts, res.Attempts)
+			}
+		})
+	}
+}
+
 func TestAbortDeviceDeployments(t *testing.T) {
 
 	if testing.Short() {
@@ -1231,7 +1790,7 @@ func TestAbortDeviceDeployments(t *testing.T) {
 			err := store.InsertMany(context.Background(), testCase.InputDeviceDeployment...)
 			assert.NoError(t, err)
 
-			err = store.AbortDeviceDeployments(context.Background(), testCase.InputDeploymentID)
+			_, err = store.AbortDeviceDeployments(context.Background(), testCase.InputDeploymentID)
 
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
@@ -1294,7 +1853,8 @@ func TestDecommissionDeviceDeployments(t *testing.T) {
 		InputDeviceId         string
 		InputDeviceDeployment []*model.DeviceDeployment
 
-		OutputError error
+		OutputDeviceDeployments []model.DeviceDeployment
+		OutputError             error
 	}{
 		"null device id": {
 			OutputError: ErrStorageInvalidID,
@@ -1302,6 +1862,17 @@ func TestDecommissionDeviceDeployments(t *testing.T) {
 		"all correct": {
 			InputDeviceId:         "foo",
 			InputDeviceDeployment: input,
+			OutputDeviceDeployments: []model.DeviceDeployment{{
+				Id:           input[0].Id,
+				DeviceId:     "foo",
+				DeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+				Status:       model.DeviceDeploymentStatusPending,
+			}},
+			OutputError: nil,
+		},
+		"no active device deployments": {
+			InputDeviceId:         "baz",
+			InputDeviceDeployment: input,
 			OutputError:           nil,
 		},
 	}
@@ -1319,12 +1890,15 @@ func TestDecommissionDeviceDeployments(t *testing.T) {
 			err := store.InsertMany(ctx, testCase.InputDeviceDeployment...)
 			assert.NoError(t, err)
 
-			err = store.DecommissionDeviceDeployments(ctx, testCase.InputDeviceId)
+			deviceDeployments, err := store.DecommissionDeviceDeployments(
+				ctx, testCase.InputDeviceId,
+			)
 
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, testCase.OutputDeviceDeployments, deviceDeployments)
 			}
 
 			if testCase.InputDeviceDeployment != nil {
@@ -1439,3 +2013,108 @@ func TestSaveDeviceDeploymentRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFleetSoftwareInventory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetFleetSoftwareInventory in short mode.")
+	}
+	db.Wipe()
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	err := ds.EnsureIndexes(DatabaseName, CollectionDevices, DeviceIDCreatedStatusIndex)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	const (
+		device1 = "1c8e4f3c-a2a4-4d23-a1f6-9c5d63bbb0a1"
+		device2 = "2d1e1ab8-bd57-4d1b-a2f2-02f2c1f4c8b2"
+		device3 = "3e2f2bc9-ce68-4e2c-b3a3-13a3d2a5d9c3"
+	)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	newDeviceDeployment := func(
+		deviceID string,
+		hours int,
+		status model.DeviceDeploymentStatus,
+		artifactName string,
+	) *model.DeviceDeployment {
+		dd := model.NewDeviceDeployment(deviceID, "d50eda0d-2cea-4de1-8d42-9cd3e7e8670"+
+			strconv.Itoa(hours%10))
+		created := start.Add(time.Duration(hours) * time.Hour)
+		dd.Created = &created
+		dd.Status = status
+		dd.Active = status.Active()
+		dd.Image = &model.Image{
+			Id:           "artifact-" + artifactName,
+			ArtifactMeta: &model.ArtifactMeta{Name: artifactName},
+		}
+		return dd
+	}
+	device1Latest := newDeviceDeployment(
+		device1, 2, model.DeviceDeploymentStatusSuccess, "release-2",
+	)
+	device2Latest := newDeviceDeployment(
+		device2, 1, model.DeviceDeploymentStatusSuccess, "release-1",
+	)
+	deleted := newDeviceDeployment(
+		device3, 1, model.DeviceDeploymentStatusSuccess, "release-1",
+	)
+	deleted.Deleted = &start
+	err = ds.InsertMany(ctx,
+		newDeviceDeployment(device1, 0, model.DeviceDeploymentStatusSuccess, "release-0"),
+		newDeviceDeployment(device1, 1, model.DeviceDeploymentStatusSuccess, "release-1"),
+		device1Latest,
+		// failed and pending deployments do not change the software
+		newDeviceDeployment(device1, 3, model.DeviceDeploymentStatusFailure, "release-3"),
+		newDeviceDeployment(device1, 4, model.DeviceDeploymentStatusPending, "release-3"),
+		newDeviceDeployment(device2, 0, model.DeviceDeploymentStatusSuccess, "release-0"),
+		device2Latest,
+		deleted,
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := func(dd *model.DeviceDeployment) model.DeviceSoftware {
+		return model.DeviceSoftware{
+			DeviceID:           dd.DeviceId,
+			ArtifactID:         dd.Image.Id,
+			ArtifactName:       dd.Image.ArtifactMeta.Name,
+			DeploymentID:       dd.DeploymentId,
+			DeviceDeploymentID: dd.Id,
+		}
+	}
+
+	inventory, count, err := ds.GetFleetSoftwareInventory(ctx, model.SoftwareInventoryQuery{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []model.DeviceSoftware{
+			expected(device1Latest),
+			expected(device2Latest),
+		}, inventory)
+	}
+
+	inventory, count, err = ds.GetFleetSoftwareInventory(ctx, model.SoftwareInventoryQuery{
+		Skip:  1,
+		Limit: 1,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []model.DeviceSoftware{expected(device2Latest)}, inventory)
+	}
+
+	inventory, count, err = ds.GetFleetSoftwareInventory(ctx, model.SoftwareInventoryQuery{
+		ArtifactName: "release-1",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []model.DeviceSoftware{expected(device2Latest)}, inventory)
+	}
+
+	inventory, count, err = ds.GetFleetSoftwareInventory(ctx, model.SoftwareInventoryQuery{
+		ArtifactName: "release-0",
+	})
+	if assert.NoError(t, err) {
+		assert.Zero(t, count)
+		assert.Empty(t, inventory)
+	}
+}
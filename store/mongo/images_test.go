@@ -16,7 +16,9 @@ package mongo
 
 import (
 	"context"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +27,7 @@ import (
 	"github.com/mendersoftware/go-lib-micro/identity"
 
 	"github.com/mendersoftware/deployments/model"
+	"github.com/mendersoftware/deployments/store"
 )
 
 func TestImagesStorageImageByNameAndDeviceType(t *testing.T) {
@@ -186,6 +189,122 @@ func TestImagesStorageImageByNameAndDeviceType(t *testing.T) {
 	}
 }
 
+func TestImagesStorageImageByNameAndProvides(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestImagesStorageImageByNameAndProvides in short mode.")
+	}
+
+	newImage := func(size int64, depends map[string]interface{}) *model.Image {
+		return &model.Image{
+			Id: uuid.NewString(),
+			ImageMeta: &model.ImageMeta{
+				Description: "description",
+			},
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "release-1",
+				DeviceTypesCompatible: []string{"foo"},
+				Depends:               depends,
+				Updates:               []model.Update{},
+			},
+			Size: size,
+		}
+	}
+	generic := newImage(100, nil)
+	rootfsV1 := newImage(200, map[string]interface{}{
+		"rootfs-image.version": []interface{}{"v1", "v1.1"},
+	})
+	rootfsV2 := newImage(50, map[string]interface{}{
+		"rootfs-image.version": "v2",
+	})
+
+	ctx := context.Background()
+	db.Wipe()
+	store := NewDataStoreMongoWithClient(db.Client())
+	for _, image := range []*model.Image{generic, rootfsV1, rootfsV2} {
+		err := store.InsertImage(ctx, image)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		name     string
+		provides map[string]string
+
+		imageID string
+		err     error
+	}{
+		"device type only": {
+			name:     "release-1",
+			provides: map[string]string{"device_type": "foo"},
+			imageID:  generic.Id,
+		},
+		"most specific match": {
+			name: "release-1",
+			provides: map[string]string{
+				"device_type":          "foo",
+				"rootfs-image.version": "v1.1",
+			},
+			imageID: rootfsV1.Id,
+		},
+		"fall back to generic artifact": {
+			name: "release-1",
+			provides: map[string]string{
+				"device_type":          "foo",
+				"rootfs-image.version": "v3",
+			},
+			imageID: generic.Id,
+		},
+		"device type incompatible": {
+			name: "release-1",
+			provides: map[string]string{
+				"device_type":          "bar",
+				"rootfs-image.version": "v1",
+			},
+		},
+		"name not found": {
+			name: "release-2",
+			provides: map[string]string{
+				"device_type":          "foo",
+				"rootfs-image.version": "v1",
+			},
+		},
+		"name validation error": {
+			provides: map[string]string{
+				"device_type":          "foo",
+				"rootfs-image.version": "v1",
+			},
+			err: ErrImagesStorageInvalidArtifactName,
+		},
+		"dev type validation error": {
+			name:     "release-1",
+			provides: map[string]string{"rootfs-image.version": "v1"},
+			err:      ErrImagesStorageInvalidDeviceType,
+		},
+		"empty provides": {
+			name: "release-1",
+			err:  ErrImagesStorageInvalidDeviceType,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			img, err := store.ImageByNameAndProvides(ctx, tc.name, tc.provides)
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			if tc.imageID == "" {
+				assert.Nil(t, img)
+			} else if assert.NotNil(t, img) {
+				assert.Equal(t, tc.imageID, img.Id)
+			}
+		})
+	}
+}
+
 func TestIsArtifactUnique(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestIsArtifactUnique in short mode.")
@@ -348,6 +467,168 @@ func TestArtifactUpdate(t *testing.T) {
 	assert.Equal(t, img.ImageMeta.Description, imgFromDB.ImageMeta.Description)
 }
 
+func TestArtifactReplace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestArtifactReplace in short mode.")
+	}
+
+	img := &model.Image{
+		Id: "a3719bc6-62af-4d65-b781-effa992048ba",
+		ImageMeta: &model.ImageMeta{
+			Description: "description",
+		},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "app1-v1.0",
+			DeviceTypesCompatible: []string{"foo"},
+			Updates:               []model.Update{},
+		},
+		Size: 1,
+	}
+
+	ctx := context.Background()
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+
+	err := ds.InsertImage(ctx, img)
+	assert.NoError(t, err)
+	err = ds.UpdateReleaseArtifacts(ctx, img, nil, img.ArtifactMeta.Name)
+	assert.NoError(t, err)
+	collDevs := client.Database(DatabaseName).Collection(CollectionDevices)
+	_, err = collDevs.InsertMany(ctx, []interface{}{
+		bson.M{"_id": "active", "image": img, "active": true},
+		bson.M{"_id": "finished", "image": img, "active": false},
+	})
+	assert.NoError(t, err)
+
+	replacement := *img
+	replacement.Revision = 1
+	replacement.Size = 42
+	err = ds.ReplaceImage(ctx, &replacement, 0)
+	assert.NoError(t, err)
+
+	// the image was replaced already
+	err = ds.ReplaceImage(ctx, &replacement, 0)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	err = ds.UpdateImageReferences(ctx, &replacement)
+	assert.NoError(t, err)
+	// retrying is harmless, and an earlier revision does not overwrite
+	err = ds.UpdateImageReferences(ctx, &replacement)
+	assert.NoError(t, err)
+	err = ds.UpdateImageReferences(ctx, img)
+	assert.NoError(t, err)
+
+	imgFromDB, err := ds.FindImageByID(ctx, img.Id)
+	assert.NoError(t, err)
+	if assert.NotNil(t, imgFromDB) {
+		assert.Equal(t, 1, imgFromDB.Revision)
+		assert.Equal(t, int64(42), imgFromDB.Size)
+	}
+
+	var release model.Release
+	err = client.Database(DatabaseName).Collection(CollectionReleases).
+		FindOne(ctx, bson.M{"_id": img.ArtifactMeta.Name}).
+		Decode(&release)
+	assert.NoError(t, err)
+	if assert.Len(t, release.Artifacts, 1) {
+		assert.Equal(t, 1, release.Artifacts[0].Revision)
+		assert.Equal(t, int64(42), release.Artifacts[0].Size)
+	}
+
+	for id, revision := range map[string]int{"active": 1, "finished": 0} {
+		var dev struct {
+			Image model.Image `bson:"image"`
+		}
+		err = collDevs.FindOne(ctx, bson.M{"_id": id}).Decode(&dev)
+		assert.NoError(t, err)
+		assert.Equal(t, revision, dev.Image.Revision, id)
+	}
+}
+
+func TestReplaceImageTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestReplaceImageTags in short mode.")
+	}
+
+	img := &model.Image{
+		Id: "a3719bc6-62af-4d65-b781-effa992048ba",
+		ImageMeta: &model.ImageMeta{
+			Description: "description",
+		},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "app1-v1.0",
+			DeviceTypesCompatible: []string{"foo"},
+			Updates:               []model.Update{},
+		},
+		Size: 1,
+	}
+	other := &model.Image{
+		Id: "a5a3ab4f-c9a8-4d2b-a9e8-6d1e4c7d3e8f",
+		ImageMeta: &model.ImageMeta{
+			Description: "description",
+		},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "app1-v1.0",
+			DeviceTypesCompatible: []string{"bar"},
+			Updates:               []model.Update{},
+		},
+		Size: 1,
+	}
+
+	ctx := context.Background()
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+
+	for _, i := range []*model.Image{img, other} {
+		err := ds.InsertImage(ctx, i)
+		assert.NoError(t, err)
+		err = ds.UpdateReleaseArtifacts(ctx, i, nil, i.ArtifactMeta.Name)
+		assert.NoError(t, err)
+	}
+	err := ds.ReplaceReleaseTags(ctx, img.ArtifactMeta.Name, model.Tags{"release"})
+	assert.NoError(t, err)
+
+	err = ds.ReplaceImageTags(ctx, img.Id, model.Tags{"qa-approved", "beta"})
+	assert.NoError(t, err)
+
+	err = ds.ReplaceImageTags(ctx, "e6e8ec46-a8a1-4bf0-97aa-1b2b4e1f4c6d",
+		model.Tags{"qa-approved"})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	tooMany := make(model.Tags, model.TagsMaxUnique)
+	for i := range tooMany {
+		tooMany[i] = model.Tag("tag" + strconv.Itoa(i))
+	}
+	err = ds.ReplaceImageTags(ctx, other.Id, tooMany)
+	assert.ErrorIs(t, err, model.ErrTooManyUniqueTags)
+
+	images, count, err := ds.ListImages(ctx, &model.ReleaseOrImageFilter{
+		Tags: []string{"qa-approved"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, img.Id, images[0].Id)
+		assert.Equal(t, model.Tags{"qa-approved", "beta"}, images[0].Tags)
+	}
+
+	var release model.Release
+	err = client.Database(DatabaseName).Collection(CollectionReleases).
+		FindOne(ctx, bson.M{"_id": img.ArtifactMeta.Name}).
+		Decode(&release)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Tags{"release"}, release.Tags)
+	for _, artifact := range release.Artifacts {
+		if artifact.Id == img.Id {
+			assert.Equal(t, model.Tags{"qa-approved", "beta"}, artifact.Tags)
+		} else {
+			assert.Empty(t, artifact.Tags)
+		}
+	}
+}
+
 func TestListImages(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestListImages in short mode.")
@@ -567,3 +848,252 @@ func TestListImages(t *testing.T) {
 		})
 	}
 }
+
+func TestGetImagesListVersion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestGetImagesListVersion in short mode.")
+	}
+	db.Wipe()
+
+	ctx := context.Background()
+	ds := NewDataStoreMongoWithClient(db.Client())
+
+	version, err := ds.GetImagesListVersion(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ListVersion{}, version)
+
+	for i, img := range []*model.Image{{
+		Id:        "6d4f6e27-c3bb-438c-ad9c-d9de30e59d80",
+		ImageMeta: &model.ImageMeta{},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "App1 v1.0",
+			DeviceTypesCompatible: []string{"foo"},
+		},
+		Modified: timePtr("2010-09-22T22:00:00+00:00"),
+	}, {
+		Id:        "6d4f6e27-c3bb-438c-ad9c-d9de30e59d81",
+		ImageMeta: &model.ImageMeta{},
+		ArtifactMeta: &model.ArtifactMeta{
+			Name:                  "App2 v0.1",
+			DeviceTypesCompatible: []string{"bar"},
+		},
+		Modified: timePtr("2010-09-22T23:00:00+00:00"),
+	}} {
+		if !assert.NoError(t, ds.InsertImage(ctx, img), "image %d", i) {
+			t.FailNow()
+		}
+	}
+
+	version, err = ds.GetImagesListVersion(ctx, nil)
+	if assert.NoError(t, err) && assert.NotNil(t, version.Modified) {
+		assert.Equal(t, 2, version.Count)
+		assert.True(t, timePtr("2010-09-22T23:00:00+00:00").Equal(*version.Modified))
+	}
+
+	version, err = ds.GetImagesListVersion(ctx, &model.ReleaseOrImageFilter{DeviceType: "foo"})
+	if assert.NoError(t, err) && assert.NotNil(t, version.Modified) {
+		assert.Equal(t, 1, version.Count)
+		assert.True(t, timePtr("2010-09-22T22:00:00+00:00").Equal(*version.Modified))
+	}
+}
+
+func TestFindImagesModifiedSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestFindImagesModifiedSince in short mode.")
+	}
+	db.Wipe()
+	ds := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	// inserted out of order, the last two modified at the same time
+	offsets := []time.Duration{-time.Minute, -time.Hour, 0, 0}
+	ids := make([]string, len(offsets))
+	for i, offset := range offsets {
+		ids[i] = "6d4f6e27-c3bb-438c-ad9c-d9de30e59d8" + strconv.Itoa(i)
+		modified := now.Add(offset)
+		err := ds.InsertImage(ctx, &model.Image{
+			Id:        ids[i],
+			ImageMeta: &model.ImageMeta{},
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "release-" + strconv.Itoa(i),
+				DeviceTypesCompatible: []string{"foo"},
+				Updates:               []model.Update{},
+			},
+			Modified: &modified,
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	imageIDs := func(images []*model.Image) []string {
+		ids := []string{}
+		for _, image := range images {
+			ids = append(ids, image.Id)
+		}
+		return ids
+	}
+
+	images, err := ds.FindImagesModifiedSince(ctx, time.Time{}, "", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ids[1], ids[0], ids[2], ids[3]}, imageIDs(images))
+
+	images, err = ds.FindImagesModifiedSince(ctx, now.Add(-time.Minute), "", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ids[2], ids[3]}, imageIDs(images))
+	if assert.Len(t, images, 2) {
+		assert.True(t, now.Equal(*images[1].Modified))
+	}
+
+	// resuming after the first of the images modified at the same time
+	images, err = ds.FindImagesModifiedSince(ctx, now, ids[2], 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ids[3]}, imageIDs(images))
+	images, err = ds.FindImagesModifiedSince(ctx, now, ids[3], 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+	images, err = ds.FindImagesModifiedSince(ctx, now.Add(-time.Hour), ids[1], 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ids[0], ids[2], ids[3]}, imageIDs(images))
+
+	images, err = ds.FindImagesModifiedSince(ctx, now.Add(time.Millisecond), "", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+}
+
+func TestListImagesProvides(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestListImagesProvides in short mode.")
+	}
+
+	newImage := func(name string, provides map[string]string) *model.Image {
+		return &model.Image{
+			Id:        uuid.NewString(),
+			ImageMeta: &model.ImageMeta{},
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  name,
+				DeviceTypesCompatible: []string{"foo"},
+				Provides:              provides,
+				Updates:               []model.Update{},
+			},
+		}
+	}
+	v30 := newImage("release-3.0", map[string]string{
+		"rootfs-image.version":  "3.0",
+		"rootfs-image.checksum": "aaa",
+	})
+	v31 := newImage("release-3.1", map[string]string{
+		"rootfs-image.version":  "3.1",
+		"rootfs-image.checksum": "bbb",
+	})
+	app := newImage("app-3.1", map[string]string{
+		"data-partition.app.version": "3.1",
+	})
+
+	ctx := context.Background()
+	db.Wipe()
+	store := NewDataStoreMongoWithClient(db.Client())
+	for _, image := range []*model.Image{v30, v31, app} {
+		err := store.InsertImage(ctx, image)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		provides map[string]string
+
+		imageIDs []string
+	}{
+		"single pair": {
+			provides: map[string]string{"rootfs-image.version": "3.1"},
+			imageIDs: []string{v31.Id},
+		},
+		"all pairs must match": {
+			provides: map[string]string{
+				"rootfs-image.version":  "3.1",
+				"rootfs-image.checksum": "bbb",
+			},
+			imageIDs: []string{v31.Id},
+		},
+		"key and value from different pairs": {
+			provides: map[string]string{
+				"rootfs-image.version":  "3.1",
+				"rootfs-image.checksum": "aaa",
+			},
+		},
+		"value under another key": {
+			provides: map[string]string{"rootfs-image.checksum": "3.1"},
+		},
+	}
+	for name := range testCases {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			images, count, err := store.ListImages(ctx, &model.ReleaseOrImageFilter{
+				Provides: tc.provides,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.imageIDs), count)
+			ids := make([]string, len(images))
+			for i, image := range images {
+				ids[i] = image.Id
+			}
+			assert.ElementsMatch(t, tc.imageIDs, ids)
+		})
+	}
+}
+
+func TestReplacedArtifactFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestReplacedArtifactFiles in short mode.")
+	}
+
+	const imageID = "a3719bc6-62af-4d65-b781-effa992048ba"
+	replaced := time.Now().Add(-time.Hour).Truncate(time.Millisecond).UTC()
+
+	ctx := context.Background()
+	db.Wipe()
+	client := db.Client()
+	ds := NewDataStoreMongoWithClient(client)
+
+	files, err := ds.FindReplacedArtifactFiles(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	file := model.ReplacedArtifactFile{
+		ID:         "d1a8c5a2-4c55-4b0b-9d0b-54c8e0c6b5a1",
+		ArtifactID: imageID,
+		StorageKey: imageID,
+		Replaced:   replaced,
+	}
+	err = ds.InsertReplacedArtifactFile(ctx, &file)
+	assert.NoError(t, err)
+	files, err = ds.FindReplacedArtifactFiles(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ReplacedArtifactFile{file}, files)
+
+	collDevs := client.Database(DatabaseName).Collection(CollectionDevices)
+	_, err = collDevs.InsertMany(ctx, []interface{}{
+		bson.M{"_id": "before", "image": bson.M{"_id": imageID}, "active": true,
+			"created": replaced.Add(-time.Minute)},
+		bson.M{"_id": "after", "image": bson.M{"_id": imageID}, "active": true,
+			"created": replaced.Add(time.Minute)},
+	})
+	assert.NoError(t, err)
+	inUse, err := ds.ExistActiveDeviceDeploymentsOfImage(ctx, imageID, replaced)
+	assert.NoError(t, err)
+	assert.True(t, inUse)
+
+	_, err = collDevs.UpdateOne(ctx, bson.M{"_id": "before"},
+		bson.M{"$set": bson.M{"active": false}})
+	assert.NoError(t, err)
+	inUse, err = ds.ExistActiveDeviceDeploymentsOfImage(ctx, imageID, replaced)
+	assert.NoError(t, err)
+	assert.False(t, inUse, "only created after the replacement")
+
+	err = ds.DeleteReplacedArtifactFile(ctx, file.ID)
+	assert.NoError(t, err)
+	files, err = ds.FindReplacedArtifactFiles(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
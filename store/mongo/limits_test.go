@@ -92,3 +92,38 @@ func TestGetLimit(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, lim3OtherTenant, *lim)
 }
+
+func TestSetLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSetLimit in short mode.")
+	}
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "foo",
+	})
+	db := getDb(ctx)
+
+	err := db.SetLimit(ctx, &model.Limit{
+		Name:  model.LimitDownloadBandwidth,
+		Value: 1024,
+	})
+	assert.NoError(t, err)
+
+	// the second call replaces the value
+	err = db.SetLimit(ctx, &model.Limit{
+		Name:  model.LimitDownloadBandwidth,
+		Value: 2048,
+	})
+	assert.NoError(t, err)
+
+	lim, err := db.GetLimit(ctx, model.LimitDownloadBandwidth)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limit{
+		Name:  model.LimitDownloadBandwidth,
+		Value: 2048,
+	}, *lim)
+
+	// the limit is set for the tenant only
+	_, err = db.GetLimit(context.Background(), model.LimitDownloadBandwidth)
+	assert.EqualError(t, err, ErrLimitNotFound.Error())
+}
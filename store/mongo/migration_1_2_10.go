@@ -27,8 +27,9 @@ import (
 )
 
 type migration_1_2_10 struct {
-	client *mongo.Client
-	db     string
+	client     *mongo.Client
+	db         string
+	background bool
 }
 
 const IndexDeploymentsActiveCreatedV2 = "active_created:v2"
@@ -40,9 +41,10 @@ func (m *migration_1_2_10) Up(from migrate.Version) (err error) {
 	//           created in the parent collection because of the
 	//           renameCollection operation (removed).
 	db := m.client.Database(m.db)
-	_, err = db.Collection(CollectionDevices).
-		Indexes().
-		CreateOne(ctx, IndexDeviceDeploymentsActiveCreatedModel)
+	err = createIndexes(ctx,
+		db.Collection(CollectionDevices).Indexes(),
+		m.background,
+		IndexDeviceDeploymentsActiveCreatedModel)
 	if err != nil {
 		return errors.Wrapf(err, "failed to create index '%s'",
 			IndexDeviceDeploymentsActiveCreated)
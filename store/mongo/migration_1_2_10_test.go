@@ -46,6 +46,7 @@ func Test_migration_1_2_10_Up(t *testing.T) {
 		Setup: func(db *mongo.Database) {
 			err := MigrateSingle(
 				context.Background(),
+				DbName,
 				db.Name(),
 				"1.2.9",
 				db.Client(),
@@ -26,10 +26,13 @@ import (
 type migration_1_2_14 struct {
 	client *mongo.Client
 	db     string
+	// baseDb is the default database; the upload intents are
+	// stored only there.
+	baseDb string
 }
 
 func (m *migration_1_2_14) Up(from migrate.Version) error {
-	if m.db != DatabaseName {
+	if m.db != m.baseDb {
 		return nil
 	}
 	ctx := context.Background()
@@ -42,6 +42,7 @@ func TestMigration1dot2dot14(t *testing.T) {
 		migration := &migration_1_2_14{
 			client: mgoClient,
 			db:     DatabaseName,
+			baseDb: DatabaseName,
 		}
 		migrator := migrate.SimpleMigrator{
 			Client:      mgoClient,
@@ -95,6 +96,7 @@ func TestMigration1dot2dot14(t *testing.T) {
 		migration := &migration_1_2_14{
 			client: mgoClient,
 			db:     databaseName,
+			baseDb: DatabaseName,
 		}
 		migrator := migrate.SimpleMigrator{
 			Client:      mgoClient,
@@ -0,0 +1,63 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration_1_2_19 indexes the artifact deletion audit records, listed
+// latest first, optionally for a single artifact name.
+type migration_1_2_19 struct {
+	client *mongo.Client
+	db     string
+}
+
+func (m *migration_1_2_19) Up(from migrate.Version) error {
+	ctx := context.Background()
+	idxDeletions := m.client.
+		Database(m.db).
+		Collection(CollectionArtifactDeletions).
+		Indexes()
+
+	_, err := idxDeletions.CreateMany(ctx, []mongo.IndexModel{{
+		Keys: bson.D{
+			{Key: StorageKeyArtifactDeletionDeleted, Value: -1},
+		},
+		Options: mopts.Index().
+			SetName(IndexNameArtifactDeletionDeleted),
+	}, {
+		Keys: bson.D{
+			{Key: StorageKeyArtifactDeletionArtifactName, Value: 1},
+			{Key: StorageKeyArtifactDeletionDeleted, Value: -1},
+		},
+		Options: mopts.Index().
+			SetName(IndexNameArtifactDeletionArtifactName),
+	}})
+	if err != nil {
+		return fmt.Errorf("mongo(1.2.19): failed to create indexes: %w", err)
+	}
+	return nil
+}
+
+func (m *migration_1_2_19) Version() migrate.Version {
+	return migrate.MakeVersion(1, 2, 19)
+}
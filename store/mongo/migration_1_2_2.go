@@ -14,13 +14,16 @@
 package mongo
 
 import (
+	"context"
+
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type migration_1_2_2 struct {
-	client *mongo.Client
-	db     string
+	client     *mongo.Client
+	db         string
+	background bool
 }
 
 // Up drops index with len(name) > 70 chars in the 'deployments' collection
@@ -30,8 +33,10 @@ func (m *migration_1_2_2) Up(from migrate.Version) error {
 	// new indexes exists
 
 	// create the 'short' index
+	ctx := context.Background()
 	storage := NewDataStoreMongoWithClient(m.client)
-	if err := storage.EnsureIndexes(m.db, CollectionDevices,
+	idxDevices := m.client.Database(m.db).Collection(CollectionDevices).Indexes()
+	if err := createIndexes(ctx, idxDevices, m.background,
 		StatusIndexes,
 		DeviceIDStatusIndexes,
 		DeploymentIdIndexes); err != nil {
@@ -0,0 +1,76 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// migration_1_2_24 marks the compression of the artifacts stored before it
+// was recorded as unknown, in the images and in the releases.
+type migration_1_2_24 struct {
+	client *mongo.Client
+	db     string
+}
+
+func (m *migration_1_2_24) Up(from migrate.Version) error {
+	ctx := context.Background()
+	db := m.client.Database(m.db)
+	missing := bson.D{{Key: "$exists", Value: false}}
+
+	_, err := db.Collection(CollectionImages).UpdateMany(ctx,
+		bson.D{{Key: StorageKeyImageCompression, Value: missing}},
+		bson.D{{Key: "$set", Value: bson.D{{
+			Key:   StorageKeyImageCompression,
+			Value: model.ArtifactCompressionUnknown,
+		}}}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo(1.2.24): failed to update the images")
+	}
+
+	_, err = db.Collection(CollectionReleases).UpdateMany(ctx,
+		bson.D{{Key: StorageKeyReleaseArtifacts, Value: bson.D{{
+			Key:   "$elemMatch",
+			Value: bson.D{{Key: StorageKeyImageCompression, Value: missing}},
+		}}}},
+		bson.D{{Key: "$set", Value: bson.D{{
+			Key: StorageKeyReleaseArtifacts + ".$[artifact]." +
+				StorageKeyImageCompression,
+			Value: model.ArtifactCompressionUnknown,
+		}}}},
+		mopts.Update().SetArrayFilters(mopts.ArrayFilters{
+			Filters: []interface{}{
+				bson.D{{Key: "artifact." + StorageKeyImageCompression, Value: missing}},
+			},
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "mongo(1.2.24): failed to update the releases")
+	}
+	return nil
+}
+
+func (m *migration_1_2_24) Version() migrate.Version {
+	return migrate.MakeVersion(1, 2, 24)
+}
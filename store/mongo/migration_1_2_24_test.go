@@ -0,0 +1,89 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	mopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+func TestMigration_1_2_24(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMigration_1_2_24 in short mode.")
+	}
+
+	db.Wipe()
+	c := db.Client()
+	ctx := context.TODO()
+	database := c.Database(DbName)
+
+	newImage := func(id, compression string) model.Image {
+		return model.Image{
+			Id: id,
+			ArtifactMeta: &model.ArtifactMeta{
+				Name:                  "foo",
+				DeviceTypesCompatible: []string{"foo"},
+				Compression:           compression,
+			},
+		}
+	}
+	legacy := newImage("0cb87b3d-4f08-420b-b004-4347c07f70f6", "")
+	recorded := newImage("0cb87b3d-4f08-420b-b004-4347c07f70f7",
+		model.ArtifactCompressionZstd)
+	_, err := database.Collection(CollectionImages).
+		InsertMany(ctx, []interface{}{legacy, recorded})
+	assert.NoError(t, err)
+	_, err = database.Collection(CollectionReleases).InsertOne(ctx, model.Release{
+		Name:      "foo",
+		Artifacts: []model.Image{legacy, recorded},
+	})
+	assert.NoError(t, err)
+
+	mnew := &migration_1_2_24{
+		client: c,
+		db:     DbName,
+	}
+	err = mnew.Up(migrate.MakeVersion(1, 2, 24))
+	assert.NoError(t, err)
+
+	var images []model.Image
+	cursor, err := database.Collection(CollectionImages).Find(ctx, bson.M{},
+		mopts.Find().SetSort(bson.M{StorageKeyId: 1}))
+	if assert.NoError(t, err) {
+		assert.NoError(t, cursor.All(ctx, &images))
+	}
+	if assert.Len(t, images, 2) {
+		assert.Equal(t, model.ArtifactCompressionUnknown, images[0].Compression)
+		assert.Equal(t, model.ArtifactCompressionZstd, images[1].Compression)
+	}
+
+	var release model.Release
+	err = database.Collection(CollectionReleases).
+		FindOne(ctx, bson.M{StorageKeyReleaseName: "foo"}).
+		Decode(&release)
+	if assert.NoError(t, err) && assert.Len(t, release.Artifacts, 2) {
+		assert.Equal(t, model.ArtifactCompressionUnknown,
+			release.Artifacts[0].Compression)
+		assert.Equal(t, model.ArtifactCompressionZstd,
+			release.Artifacts[1].Compression)
+	}
+}
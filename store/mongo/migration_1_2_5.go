@@ -21,13 +21,13 @@ import (
 )
 
 type migration_1_2_5 struct {
-	client *mongo.Client
-	db     string
+	client     *mongo.Client
+	db         string
+	background bool
 }
 
 func (m *migration_1_2_5) Up(from migrate.Version) error {
 	ctx := context.Background()
-	storage := NewDataStoreMongoWithClient(m.client)
 	coll := m.client.Database(m.db).Collection(CollectionDevices)
 	// Drop IndexDeploymentDeviceStatusesName and
 	// IndexDeploymentDeviceDeploymentIdName if they exist.
@@ -55,7 +55,7 @@ func (m *migration_1_2_5) Up(from migrate.Version) error {
 			return err
 		}
 	}
-	return storage.EnsureIndexes(m.db, CollectionDevices,
+	return createIndexes(ctx, coll.Indexes(), m.background,
 		DeviceIDCreatedStatusIndex,
 		DeploymentIdIndexes,
 	)
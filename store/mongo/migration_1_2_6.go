@@ -25,8 +25,9 @@ import (
 )
 
 type migration_1_2_6 struct {
-	client *mongo.Client
-	db     string
+	client     *mongo.Client
+	db         string
+	background bool
 }
 
 // Up replaces all devicedeployment documents status field with an enumerated type
@@ -63,9 +64,8 @@ func (m *migration_1_2_6) Up(from migrate.Version) error {
 	}
 	// TODO: Undo DeploymentIdIndexes ?
 
-	iw := coll.Indexes()
-	_, err := iw.CreateOne(ctx, DeviceDeploymentIdStatus)
-	return err
+	return createIndexes(ctx, coll.Indexes(), m.background,
+		DeviceDeploymentIdStatus)
 }
 
 func (m *migration_1_2_6) Version() migrate.Version {
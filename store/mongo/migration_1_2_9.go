@@ -25,8 +25,9 @@ import (
 )
 
 type migration_1_2_9 struct {
-	client *mongo.Client
-	db     string
+	client     *mongo.Client
+	db         string
+	background bool
 }
 
 func (m *migration_1_2_9) Up(from migrate.Version) (err error) {
@@ -67,7 +68,7 @@ func (m *migration_1_2_9) Up(from migrate.Version) (err error) {
 	}
 	_, err = collDev.BulkWrite(ctx, bulkReqs)
 	if err == nil {
-		err = storage.EnsureIndexes(m.db, CollectionDevices,
+		err = createIndexes(ctx, collDev.Indexes(), m.background,
 			IndexDeviceDeploymentsActiveCreatedModel,
 		)
 	}
@@ -24,25 +24,53 @@ import (
 )
 
 const (
-	DbVersion        = "1.2.16"
+	DbVersion        = "1.2.28"
 	DbMinimumVersion = "1.2.16"
 	DbName           = "deployment_service"
 )
 
+// MigrationOptions tune how the migrations are applied.
+type MigrationOptions struct {
+	// BackgroundIndexBuild builds the indexes of the device deployments
+	// collection in the background, not to block the writes to it until
+	// done, instead of in the foreground. Affects the indexes created by
+	// the migrations 1.2.2 (device ID and status, deployment ID), 1.2.5
+	// (device ID, creation and status), 1.2.6 (deployment ID and status),
+	// 1.2.9 and 1.2.10 (active by device ID), 1.2.17 (finished with
+	// log), 1.2.18 (confirmation deadline), 1.2.22 (deployment ID and
+	// creation) and 1.2.28 (active by assigned image).
+	//
+	// MongoDB 4.2 and later ignore the option: their index builds only
+	// lock the collection at their start and end.
+	BackgroundIndexBuild bool
+}
+
+func mergeMigrationOptions(opts ...MigrationOptions) MigrationOptions {
+	var merged MigrationOptions
+	for _, opt := range opts {
+		merged.BackgroundIndexBuild = opt.BackgroundIndexBuild
+	}
+	return merged
+}
+
+// Migrate applies the migrations to all the tenant databases prefixed with
+// baseDb or, in the absence of those, to the baseDb database itself.
 func Migrate(ctx context.Context,
+	baseDb string,
 	version string,
 	client *mongo.Client,
-	automigrate bool) error {
+	automigrate bool,
+	opts ...MigrationOptions) error {
 
 	l := log.FromContext(ctx)
 
-	dbs, err := migrate.GetTenantDbs(ctx, client, ctx_store.IsTenantDb(DbName))
+	dbs, err := migrate.GetTenantDbs(ctx, client, ctx_store.IsTenantDb(baseDb))
 	if err != nil {
 		return errors.Wrap(err, "failed go retrieve tenant DBs")
 	}
 
 	if len(dbs) == 0 {
-		dbs = []string{DbName}
+		dbs = []string{baseDb}
 	}
 
 	if automigrate {
@@ -52,7 +80,7 @@ func Migrate(ctx context.Context,
 	}
 
 	for _, d := range dbs {
-		err := MigrateSingle(ctx, d, version, client, automigrate)
+		err := MigrateSingle(ctx, baseDb, d, version, client, automigrate, opts...)
 		if err != nil {
 			return err
 		}
@@ -61,11 +89,15 @@ func Migrate(ctx context.Context,
 	return nil
 }
 
+// MigrateSingle applies the migrations to the db database, which is either
+// baseDb or one of its tenant databases.
 func MigrateSingle(ctx context.Context,
+	baseDb string,
 	db string,
 	version string,
 	client *mongo.Client,
-	automigrate bool) error {
+	automigrate bool,
+	opts ...MigrationOptions) error {
 	l := log.FromContext(ctx)
 
 	l.Infof("migrating %s", db)
@@ -81,14 +113,32 @@ func MigrateSingle(ctx context.Context,
 		Automigrate: automigrate,
 	}
 
-	migrations := []migrate.Migration{
+	err = m.Apply(ctx, *ver, newMigrations(baseDb, db, client,
+		mergeMigrationOptions(opts...)))
+	if err != nil {
+		return errors.Wrap(err, "failed to apply migrations")
+	}
+
+	return nil
+}
+
+// newMigrations returns the migrations of the db database, in order.
+func newMigrations(
+	baseDb string,
+	db string,
+	client *mongo.Client,
+	opts MigrationOptions,
+) []migrate.Migration {
+	background := opts.BackgroundIndexBuild
+	return []migrate.Migration{
 		&migration_1_2_1{
 			client: client,
 			db:     db,
 		},
 		&migration_1_2_2{
-			client: client,
-			db:     db,
+			client:     client,
+			db:         db,
+			background: background,
 		},
 		&migration_1_2_3{
 			client: client,
@@ -99,24 +149,28 @@ func MigrateSingle(ctx context.Context,
 			db:     db,
 		},
 		&migration_1_2_5{
-			client: client,
-			db:     db,
+			client:     client,
+			db:         db,
+			background: background,
 		},
 		&migration_1_2_6{
-			client: client,
-			db:     db,
+			client:     client,
+			db:         db,
+			background: background,
 		},
 		&migration_1_2_7{
 			client: client,
 			db:     db,
 		},
 		&migration_1_2_9{
-			client: client,
-			db:     db,
+			client:     client,
+			db:         db,
+			background: background,
 		},
 		&migration_1_2_10{
-			client: client,
-			db:     db,
+			client:     client,
+			db:         db,
+			background: background,
 		},
 		&migration_1_2_11{
 			client: client,
@@ -129,6 +183,7 @@ func MigrateSingle(ctx context.Context,
 		&migration_1_2_14{
 			client: client,
 			db:     db,
+			baseDb: baseDb,
 		},
 		&migration_1_2_15{
 			client: client,
@@ -138,12 +193,57 @@ func MigrateSingle(ctx context.Context,
 			client: client,
 			db:     db,
 		},
+		&migration_1_2_17{
+			client:     client,
+			db:         db,
+			background: background,
+		},
+		&migration_1_2_18{
+			client:     client,
+			db:         db,
+			background: background,
+		},
+		&migration_1_2_19{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_20{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_21{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_22{
+			client:     client,
+			db:         db,
+			background: background,
+		},
+		&migration_1_2_23{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_24{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_25{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_26{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_27{
+			client: client,
+			db:     db,
+		},
+		&migration_1_2_28{
+			client:     client,
+			db:         db,
+			background: background,
+		},
 	}
-
-	err = m.Apply(ctx, *ver, migrations)
-	if err != nil {
-		return errors.Wrap(err, "failed to apply migrations")
-	}
-
-	return nil
 }
@@ -16,13 +16,18 @@ package mongo
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
+	ctxstore "github.com/mendersoftware/go-lib-micro/store"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	mgopts "go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mendersoftware/deployments/model"
 )
 
 func TestMigration_1_2_15(t *testing.T) {
@@ -62,3 +67,137 @@ func TestMigration_1_2_15(t *testing.T) {
 		assert.ErrorAs(t, err, &srvErr)
 	})
 }
+
+func TestMigrateCustomDbName(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestMigrateCustomDbName in short mode.")
+	}
+	db.Wipe()
+
+	const (
+		baseDb   = "deployments_staging"
+		tenantID = "123456789012345678901234"
+	)
+	ctx := context.Background()
+	client := db.Client()
+
+	// the tenant databases of the default instance are left alone
+	err := MigrateSingle(ctx,
+		DbName,
+		ctxstore.DbNameForTenant("tenant", DbName),
+		DbVersion,
+		client,
+		true,
+	)
+	assert.NoError(t, err)
+
+	err = Migrate(ctx, baseDb, DbVersion, client, true)
+	assert.NoError(t, err)
+	info, err := migrate.GetMigrationInfo(ctx, client, baseDb)
+	if assert.NoError(t, err) && assert.NotEmpty(t, info) {
+		assert.Equal(t, DbVersion, info[0].Version.String())
+	}
+
+	ds := NewDataStoreMongoWithClient(client).WithDbName(baseDb)
+	err = ds.ProvisionTenant(ctx, tenantID)
+	assert.NoError(t, err)
+
+	dbs, err := ds.GetTenantDbs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{baseDb + "-" + tenantID}, dbs)
+
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	deployment, err := model.NewDeploymentFromConstructor(&model.DeploymentConstructor{
+		Name:         "name",
+		ArtifactName: "artifact",
+		Devices:      []string{"device-1"},
+	})
+	assert.NoError(t, err)
+	err = ds.InsertDeployment(tenantCtx, deployment)
+	assert.NoError(t, err)
+	count, err := client.Database(baseDb+"-"+tenantID).
+		Collection(CollectionDeployments).
+		CountDocuments(ctx, bson.D{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// the upload intents index is created in the custom base database
+	cur, err := client.Database(baseDb).
+		Collection(CollectionUploadIntents).
+		Indexes().
+		List(ctx)
+	if assert.NoError(t, err) {
+		var indexes []struct {
+			Name string `bson:"name"`
+		}
+		assert.NoError(t, cur.All(ctx, &indexes))
+		var names []string
+		for _, idx := range indexes {
+			names = append(names, idx.Name)
+		}
+		assert.Contains(t, names, "UploadExpire")
+	}
+}
+
+type recordingIndexCreator struct {
+	models []mongo.IndexModel
+}
+
+func (r *recordingIndexCreator) CreateMany(
+	ctx context.Context,
+	models []mongo.IndexModel,
+	opts ...*mgopts.CreateIndexesOptions,
+) ([]string, error) {
+	r.models = append(r.models, models...)
+	return nil, nil
+}
+
+func TestCreateIndexesBuildMode(t *testing.T) {
+	for _, background := range []bool{true, false} {
+		indexes := &recordingIndexCreator{}
+		err := createIndexes(context.Background(), indexes, background,
+			StatusIndexes,
+			DeviceDeploymentIdStatus,
+			mongo.IndexModel{Keys: bson.D{{Key: "finished", Value: 1}}},
+		)
+		assert.NoError(t, err)
+		if assert.Len(t, indexes.models, 3) {
+			for _, model := range indexes.models {
+				if assert.NotNil(t, model.Options) &&
+					assert.NotNil(t, model.Options.Background) {
+					assert.Equal(t, background, *model.Options.Background)
+				}
+			}
+			assert.Equal(t, StatusIndexes.Options.Name, indexes.models[0].Options.Name)
+			assert.Equal(t, StatusIndexes.Keys, indexes.models[0].Keys)
+		}
+	}
+	// the shared index models are left untouched
+	assert.False(t, *StatusIndexes.Options.Background)
+	assert.Nil(t, DeviceDeploymentIdStatus.Options.Background)
+
+	assert.False(t, mergeMigrationOptions().BackgroundIndexBuild)
+	assert.True(t, mergeMigrationOptions(MigrationOptions{BackgroundIndexBuild: true}).
+		BackgroundIndexBuild)
+}
+
+func TestMigrationsBuildMode(t *testing.T) {
+	for _, background := range []bool{true, false} {
+		migrations := newMigrations(DbName, DbName, nil, MigrationOptions{
+			BackgroundIndexBuild: background,
+		})
+		var indexing int
+		for _, m := range migrations {
+			field := reflect.ValueOf(m).Elem().FieldByName("background")
+			if !field.IsValid() {
+				continue
+			}
+			indexing++
+			assert.Equal(t, background, field.Bool(),
+				"migration %s", m.Version())
+		}
+		// 1.2.2, 1.2.5, 1.2.6, 1.2.9, 1.2.10, 1.2.17, 1.2.18, 1.2.22
+		// and 1.2.28
+		assert.Equal(t, 9, indexing)
+	}
+}
@@ -20,11 +20,20 @@ import (
 	"github.com/mendersoftware/deployments/model"
 )
 
+// Sort orders of the devices listed for a deployment.
+const (
+	ListQuerySortStatus      = "status"
+	ListQuerySortCreatedAsc  = "created:asc"
+	ListQuerySortCreatedDesc = "created:desc"
+)
+
 type ListQuery struct {
 	Skip         int
 	Limit        int
 	DeploymentID string
 	Status       *string
+	// Sort is one of the ListQuerySort* orders; empty sorts by status.
+	Sort string
 }
 
 func (l ListQuery) Validate() error {
@@ -34,6 +43,13 @@ func (l ListQuery) Validate() error {
 	if l.DeploymentID == "" {
 		return errors.New("deployment_id: cannot be blank")
 	}
+	switch l.Sort {
+	case "", ListQuerySortStatus, ListQuerySortCreatedAsc, ListQuerySortCreatedDesc:
+	default:
+		return errors.New("sort: must be one of \"" + ListQuerySortStatus +
+			"\", \"" + ListQuerySortCreatedAsc +
+			"\" or \"" + ListQuerySortCreatedDesc + "\"")
+	}
 	if l.Status != nil {
 		if *l.Status == model.DeviceDeploymentStatusPauseStr ||
 			*l.Status == model.DeviceDeploymentStatusActiveStr ||
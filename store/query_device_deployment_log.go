@@ -0,0 +1,101 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"errors"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// ListQueryDeviceDeploymentLog selects the messages of a device deployment
+// log.
+type ListQueryDeviceDeploymentLog struct {
+	DeviceID     string
+	DeploymentID string
+	// Attempt selects the log of a single attempt; all attempts if nil.
+	Attempt *uint
+	// MinLevel skips the messages less severe than the given level.
+	MinLevel string
+	// Skip and Limit page through the messages of the selected attempts
+	// as if they were a single log; zero Limit means no limit.
+	Skip  int
+	Limit int
+}
+
+func (q ListQueryDeviceDeploymentLog) Validate() error {
+	if q.DeviceID == "" {
+		return errors.New("device_id: cannot be blank")
+	}
+	if q.DeploymentID == "" {
+		return errors.New("deployment_id: cannot be blank")
+	}
+	if q.MinLevel != "" {
+		if err := model.ValidateLogLevel(q.MinLevel); err != nil {
+			return err
+		}
+	}
+	if q.Skip < 0 {
+		return errors.New("skip: must be a non-negative integer")
+	}
+	if q.Limit < 0 {
+		return errors.New("limit: must be a non-negative integer")
+	}
+	return nil
+}
+
+// Page filters the messages of the logs by level and returns the page
+// selected by the query together with the number of messages matching the
+// level. The order of the messages is preserved; logs left without
+// messages keep their attempt, so that an empty page can be told from a
+// missing log.
+func (q ListQueryDeviceDeploymentLog) Page(
+	logs []model.DeploymentLog,
+) ([]model.DeploymentLog, int) {
+	var (
+		total int
+		paged = make([]model.DeploymentLog, len(logs))
+	)
+	for i, dlog := range logs {
+		messages := dlog.Messages
+		if q.MinLevel != "" {
+			messages = make([]model.LogMessage, 0, len(dlog.Messages))
+			for _, msg := range dlog.Messages {
+				if msg.AtLeast(q.MinLevel) {
+					messages = append(messages, msg)
+				}
+			}
+		}
+		// the window of the page relative to this log
+		start := q.Skip - total
+		end := len(messages)
+		if q.Limit > 0 && q.Skip+q.Limit-total < end {
+			end = q.Skip + q.Limit - total
+		}
+		total += len(messages)
+		if start < 0 {
+			start = 0
+		}
+		if end < start {
+			end = start
+		}
+		if start > len(messages) {
+			start, end = len(messages), len(messages)
+		}
+		dlog.Messages = messages[start:end]
+		paged[i] = dlog
+	}
+	return paged, total
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/deployments/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQueryDeviceDeploymentLogValidate(t *testing.T) {
+	valid := ListQueryDeviceDeploymentLog{
+		DeviceID:     "device",
+		DeploymentID: "d50eda0d-2cea-4de1-8d42-9cd3e7e86701",
+	}
+	testCases := map[string]struct {
+		query func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog
+		err   error
+	}{
+		"ok": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.MinLevel = "Warning"
+				q.Skip = 10
+				q.Limit = 10
+				return q
+			},
+		},
+		"device ID": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.DeviceID = ""
+				return q
+			},
+			err: errors.New("device_id: cannot be blank"),
+		},
+		"deployment ID": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.DeploymentID = ""
+				return q
+			},
+			err: errors.New("deployment_id: cannot be blank"),
+		},
+		"level": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.MinLevel = "loud"
+				return q
+			},
+			err: errors.New(`"loud": invalid log level`),
+		},
+		"skip": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.Skip = -1
+				return q
+			},
+			err: errors.New("skip: must be a non-negative integer"),
+		},
+		"limit": {
+			query: func(q ListQueryDeviceDeploymentLog) ListQueryDeviceDeploymentLog {
+				q.Limit = -1
+				return q
+			},
+			err: errors.New("limit: must be a non-negative integer"),
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.query(valid).Validate()
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestListQueryDeviceDeploymentLogPage(t *testing.T) {
+	ts := time.Now()
+	message := func(level, msg string) model.LogMessage {
+		return model.LogMessage{Timestamp: &ts, Level: level, Message: msg}
+	}
+	logs := []model.DeploymentLog{{
+		Messages: []model.LogMessage{
+			message("info", "1"),
+			message("error", "2"),
+			message("debug", "3"),
+		},
+	}, {
+		Attempt: 1,
+		Messages: []model.LogMessage{
+			message("warning", "4"),
+			message("custom", "5"),
+			message("info", "6"),
+		},
+	}}
+	messages := func(logs []model.DeploymentLog) [][]string {
+		res := make([][]string, len(logs))
+		for i, dlog := range logs {
+			res[i] = []string{}
+			for _, msg := range dlog.Messages {
+				res[i] = append(res[i], msg.Message)
+			}
+		}
+		return res
+	}
+
+	testCases := map[string]struct {
+		query    ListQueryDeviceDeploymentLog
+		messages [][]string
+		total    int
+	}{
+		"all": {
+			messages: [][]string{{"1", "2", "3"}, {"4", "5", "6"}},
+			total:    6,
+		},
+		"min level": {
+			query:    ListQueryDeviceDeploymentLog{MinLevel: "WARN"},
+			messages: [][]string{{"2"}, {"4", "5"}},
+			total:    3,
+		},
+		"across attempts": {
+			query:    ListQueryDeviceDeploymentLog{Skip: 2, Limit: 2},
+			messages: [][]string{{"3"}, {"4"}},
+			total:    6,
+		},
+		"second attempt": {
+			query:    ListQueryDeviceDeploymentLog{Skip: 4, Limit: 10},
+			messages: [][]string{{}, {"5", "6"}},
+			total:    6,
+		},
+		"min level and range": {
+			query:    ListQueryDeviceDeploymentLog{MinLevel: "info", Skip: 1, Limit: 2},
+			messages: [][]string{{"2"}, {"4"}},
+			total:    5,
+		},
+		"offset beyond the log": {
+			query:    ListQueryDeviceDeploymentLog{Skip: 10, Limit: 5},
+			messages: [][]string{{}, {}},
+			total:    6,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			paged, total := tc.query.Page(logs)
+			assert.Equal(t, tc.messages, messages(paged))
+			assert.Equal(t, tc.total, total)
+			if assert.Len(t, paged, 2) {
+				assert.Equal(t, uint(1), paged[1].Attempt)
+			}
+		})
+	}
+	// the input is left untouched
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4", "5", "6"}}, messages(logs))
+}
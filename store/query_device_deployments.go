@@ -16,25 +16,57 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/mendersoftware/deployments/model"
 )
 
+const (
+	// DeviceIDPrefixMinLength is the shortest device ID prefix accepted:
+	// shorter prefixes match too many devices to be useful.
+	DeviceIDPrefixMinLength = 6
+	// DeviceIDPrefixMaxResults caps the device deployments which can be
+	// paged through when matching by device ID prefix.
+	DeviceIDPrefixMaxResults = 1000
+)
+
 type ListQueryDeviceDeployments struct {
 	Skip     int
 	Limit    int
 	DeviceID string
-	Status   *string
-	IDs      []string
+	// DeviceIDPrefix matches the device deployments of all the devices
+	// whose ID starts with the prefix.
+	DeviceIDPrefix string
+	Status         *string
+	IDs            []string
+	// CreatedAfter and CreatedBefore restrict the device deployments to
+	// the ones created within the (inclusive) time range.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
 
 func (l ListQueryDeviceDeployments) Validate() error {
 	if l.Limit <= 0 {
 		return errors.New("limit: must be a positive integer")
 	}
-	if l.DeviceID == "" && len(l.IDs) == 0 {
+	if l.DeviceID == "" && l.DeviceIDPrefix == "" && len(l.IDs) == 0 {
 		return errors.New("device_id: cannot be blank")
 	}
+	if l.DeviceIDPrefix != "" {
+		if len(l.DeviceIDPrefix) < DeviceIDPrefixMinLength {
+			return fmt.Errorf("device_id_prefix: the length must be at least %d",
+				DeviceIDPrefixMinLength)
+		}
+		if l.Skip+l.Limit > DeviceIDPrefixMaxResults {
+			return fmt.Errorf("device_id_prefix: cannot page beyond the first %d results",
+				DeviceIDPrefixMaxResults)
+		}
+	}
+	if l.CreatedAfter != nil && l.CreatedBefore != nil &&
+		l.CreatedAfter.After(*l.CreatedBefore) {
+		return errors.New("created_after: must not be later than created_before")
+	}
 	if l.Status != nil {
 		if *l.Status == model.DeviceDeploymentStatusPauseStr ||
 			*l.Status == model.DeviceDeploymentStatusActiveStr ||
@@ -17,12 +17,15 @@ package store
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/mendersoftware/deployments/model"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestListQueryDeviceDeploymentsValidate(t *testing.T) {
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	createdBefore := createdAfter.AddDate(0, 3, 0)
 	testCases := map[string]struct {
 		query *ListQueryDeviceDeployments
 		err   error
@@ -40,6 +43,28 @@ func TestListQueryDeviceDeploymentsValidate(t *testing.T) {
 			},
 			err: errors.New("device_id: cannot be blank"),
 		},
+		"device ID prefix": {
+			query: &ListQueryDeviceDeployments{
+				Limit:          20,
+				Skip:           DeviceIDPrefixMaxResults - 20,
+				DeviceIDPrefix: "d50eda",
+			},
+		},
+		"device ID prefix, too short": {
+			query: &ListQueryDeviceDeployments{
+				Limit:          1,
+				DeviceIDPrefix: "d50ed",
+			},
+			err: errors.New("device_id_prefix: the length must be at least 6"),
+		},
+		"device ID prefix, beyond the cap": {
+			query: &ListQueryDeviceDeployments{
+				Limit:          20,
+				Skip:           DeviceIDPrefixMaxResults,
+				DeviceIDPrefix: "d50eda",
+			},
+			err: errors.New("device_id_prefix: cannot page beyond the first 1000 results"),
+		},
 		"status": {
 			query: &ListQueryDeviceDeployments{
 				Limit:    1,
@@ -76,6 +101,31 @@ func TestListQueryDeviceDeploymentsValidate(t *testing.T) {
 				Status:   str2ptr(model.DeviceDeploymentStatusFinishedStr),
 			},
 		},
+		"created range": {
+			query: &ListQueryDeviceDeployments{
+				Limit:         1,
+				DeviceID:      "dummy",
+				CreatedAfter:  &createdAfter,
+				CreatedBefore: &createdBefore,
+			},
+		},
+		"created range, empty": {
+			query: &ListQueryDeviceDeployments{
+				Limit:         1,
+				DeviceID:      "dummy",
+				CreatedAfter:  &createdAfter,
+				CreatedBefore: &createdAfter,
+			},
+		},
+		"created range, inverted": {
+			query: &ListQueryDeviceDeployments{
+				Limit:         1,
+				DeviceID:      "dummy",
+				CreatedAfter:  &createdBefore,
+				CreatedBefore: &createdAfter,
+			},
+			err: errors.New("created_after: must not be later than created_before"),
+		},
 	}
 
 	for name, tc := range testCases {
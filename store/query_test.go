@@ -73,6 +73,21 @@ func TestListQueryValidate(t *testing.T) {
 				Status:       str2ptr(model.DeviceDeploymentStatusPendingStr),
 			},
 		},
+		"sort": {
+			query: &ListQuery{
+				Limit:        1,
+				DeploymentID: "dummy",
+				Sort:         "deviceid",
+			},
+			err: errors.New(`sort: must be one of "status", "created:asc" or "created:desc"`),
+		},
+		"sort, created": {
+			query: &ListQuery{
+				Limit:        1,
+				DeploymentID: "dummy",
+				Sort:         ListQuerySortCreatedDesc,
+			},
+		},
 		"status, finished": {
 			query: &ListQuery{
 				Limit:        1,
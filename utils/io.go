@@ -15,8 +15,11 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"io"
+
+	"golang.org/x/time/rate"
 )
 
 var ErrStreamTooLarge = errors.New("read too many bytes")
@@ -93,3 +96,64 @@ func (l *limitedReader) Read(p []byte) (n int, err error) {
 func (l *limitedReader) Count() int64 {
 	return l.N
 }
+
+type throttledReader struct {
+	ctx     context.Context
+	R       io.Reader // underlying reader
+	limiter *rate.Limiter
+}
+
+// ThrottleReader returns a reader limiting the throughput of r to the rate,
+// in bytes per second, of the limiter, which may be shared by several
+// readers to limit their combined throughput. Reads are split into chunks
+// of at most the limiter's burst so that the pace stays smooth. Waiting is
+// interrupted with the context error when ctx is done. A nil limiter
+// returns r unchanged.
+func ThrottleReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &throttledReader{
+		ctx:     ctx,
+		R:       r,
+		limiter: limiter,
+	}
+}
+
+// NewBandwidthLimiter returns a limiter for ThrottleReader allowing
+// bytesPerSecond, in chunks of a tenth of the rate.
+func NewBandwidthLimiter(bytesPerSecond int) *rate.Limiter {
+	limiter := rate.NewLimiter(0, 1)
+	SetBandwidthLimit(limiter, bytesPerSecond)
+	return limiter
+}
+
+// SetBandwidthLimit updates the rate of a limiter returned by
+// NewBandwidthLimiter.
+func SetBandwidthLimit(limiter *rate.Limiter, bytesPerSecond int) {
+	burst := bytesPerSecond / 10
+	if burst < 1 {
+		burst = 1
+	}
+	limiter.SetLimit(rate.Limit(bytesPerSecond))
+	limiter.SetBurst(burst)
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if burst := t.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.R.Read(p)
+	// the burst may shrink meanwhile if the limit is changed
+	for wait := n; wait > 0; {
+		chunk := wait
+		if burst := t.limiter.Burst(); chunk > burst {
+			chunk = burst
+		}
+		if werr := t.limiter.WaitN(t.ctx, chunk); werr != nil {
+			return n, werr
+		}
+		wait -= chunk
+	}
+	return n, err
+}
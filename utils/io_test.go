@@ -15,10 +15,15 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"io"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
 )
 
 type NoopReader struct{}
@@ -50,3 +55,60 @@ func TestLimitedReader(t *testing.T) {
 	assert.Equal(t, 0, n)
 	assert.Equal(t, io.ErrUnexpectedEOF, err)
 }
+
+func TestThrottleReader(t *testing.T) {
+	t.Parallel()
+
+	const bandwidth = 64 * 1024
+	data := bytes.Repeat([]byte{'x'}, bandwidth/2)
+
+	start := time.Now()
+	b, err := io.ReadAll(ThrottleReader(context.Background(), bytes.NewReader(data),
+		NewBandwidthLimiter(bandwidth)))
+	elapsed := time.Since(start)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+	// half of the rate, less the initial burst of a tenth, takes at least
+	// 400ms to read
+	assert.GreaterOrEqual(t, elapsed, 390*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+
+	// readers sharing a limiter share the bandwidth
+	limiter := NewBandwidthLimiter(bandwidth)
+	start = time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := io.ReadAll(ThrottleReader(context.Background(),
+				bytes.NewReader(data[:bandwidth/4]), limiter))
+			assert.NoError(t, err)
+			assert.Len(t, b, bandwidth/4)
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, time.Since(start), 390*time.Millisecond)
+
+	r := bytes.NewReader(data)
+	assert.Equal(t, r, ThrottleReader(context.Background(), r, nil),
+		"a nil limiter must not throttle")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = io.ReadAll(ThrottleReader(ctx, bytes.NewReader(data),
+		NewBandwidthLimiter(bandwidth)))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSetBandwidthLimit(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewBandwidthLimiter(1024)
+	assert.Equal(t, rate.Limit(1024), limiter.Limit())
+	assert.Equal(t, 102, limiter.Burst())
+
+	SetBandwidthLimit(limiter, 5)
+	assert.Equal(t, rate.Limit(5), limiter.Limit())
+	assert.Equal(t, 1, limiter.Burst())
+}
@@ -105,17 +105,28 @@ func (p *RESTView) RenderEmptySuccessResponse(w rest.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (p *RESTView) RenderDeploymentLog(w rest.ResponseWriter, dlog model.DeploymentLog) {
+// RenderDeploymentLog renders the deployment logs as plain text. If logs of
+// multiple attempts are given, each is preceded by a line naming the attempt;
+// logs without messages are left out.
+func (p *RESTView) RenderDeploymentLog(w rest.ResponseWriter, dlogs ...model.DeploymentLog) {
 	h, _ := w.(http.ResponseWriter)
 
 	h.Header().Set("Content-Type", "text/plain")
 	h.WriteHeader(http.StatusOK)
 
-	for _, m := range dlog.Messages {
-		as := m.String()
-		_, _ = h.Write([]byte(as))
-		if !strings.HasSuffix(as, "\n") {
-			_, _ = h.Write([]byte("\n"))
+	for _, dlog := range dlogs {
+		if len(dlog.Messages) == 0 {
+			continue
+		}
+		if len(dlogs) > 1 {
+			_, _ = fmt.Fprintf(h, "=== attempt %d ===\n", dlog.Attempt+1)
+		}
+		for _, m := range dlog.Messages {
+			as := m.String()
+			_, _ = h.Write([]byte(as))
+			if !strings.HasSuffix(as, "\n") {
+				_, _ = h.Write([]byte("\n"))
+			}
 		}
 	}
 }
@@ -185,19 +185,54 @@ func TestRenderDeploymentLog(t *testing.T) {
 	}
 
 	tcs := []struct {
-		Log  model.DeploymentLog
+		Log  []model.DeploymentLog
 		Body string
 	}{
 		{
 			// all correct
-			Log: model.DeploymentLog{
+			Log: []model.DeploymentLog{{
 				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
 				DeviceID:     "device-id-1",
 				Messages:     messages,
-			},
+			}},
 			Body: `2006-01-02 22:04:05 +0000 UTC notice: foo
 2006-01-02 22:04:05 +0000 UTC debug: zed zed zed
 2006-01-02 22:04:05 +0000 UTC info: bar bar bar
+`,
+		},
+		{
+			// multiple attempts
+			Log: []model.DeploymentLog{{
+				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+				DeviceID:     "device-id-1",
+				Messages:     messages[:1],
+			}, {
+				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+				DeviceID:     "device-id-1",
+				Attempt:      1,
+				Messages:     messages[1:],
+			}},
+			Body: `=== attempt 1 ===
+2006-01-02 22:04:05 +0000 UTC notice: foo
+=== attempt 2 ===
+2006-01-02 22:04:05 +0000 UTC debug: zed zed zed
+2006-01-02 22:04:05 +0000 UTC info: bar bar bar
+`,
+		},
+		{
+			// attempt without messages in the page
+			Log: []model.DeploymentLog{{
+				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+				DeviceID:     "device-id-1",
+				Messages:     []model.LogMessage{},
+			}, {
+				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+				DeviceID:     "device-id-1",
+				Attempt:      1,
+				Messages:     messages[2:],
+			}},
+			Body: `=== attempt 2 ===
+2006-01-02 22:04:05 +0000 UTC info: bar bar bar
 `,
 		},
 	}
@@ -205,7 +240,7 @@ func TestRenderDeploymentLog(t *testing.T) {
 	for _, tc := range tcs {
 		router, err := rest.MakeRouter(rest.Get("/test", func(w rest.ResponseWriter, r *rest.Request) {
 			view := &RESTView{}
-			view.RenderDeploymentLog(w, tc.Log)
+			view.RenderDeploymentLog(w, tc.Log...)
 		}))
 
 		assert.NoError(t, err)
@@ -0,0 +1,121 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+var errTemporaryHTTPStatus = errors.New("temporary HTTP error status")
+
+// RetryPolicy configures how an operation failing with a transient error
+// is repeated: up to MaxAttempts times in total, waiting BaseDelay before
+// the second attempt and doubling the delay after each further one.
+// The zero value makes a single attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// Jitter picks each delay at random between half and the full value,
+	// so that the clients failing together do not retry together.
+	Jitter bool
+	// RetryServerErrors makes DoHTTP repeat the requests failing with any
+	// 5xx status, not only the ones IsTemporaryHTTPStatus reports.
+	RetryServerErrors bool
+}
+
+// Do calls fn until it succeeds, fails with an error which is not
+// temporary, the attempts are exhausted or the context is done; it returns
+// the error of the last attempt.
+func (p RetryPolicy) Do(ctx context.Context, fn func() (temporary bool, err error)) error {
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		temporary, err := fn()
+		if err == nil || !temporary || attempt >= p.MaxAttempts {
+			return err
+		}
+		timer := time.NewTimer(p.wait(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// DoHTTP sends the request built by newRequest, repeating it on network
+// errors and transient error responses. Once the attempts are exhausted,
+// the last error response is returned to the caller like any other.
+func (p RetryPolicy) DoHTTP(
+	ctx context.Context,
+	client *http.Client,
+	newRequest func() (*http.Request, error),
+) (*http.Response, error) {
+	var rsp *http.Response
+	err := p.Do(ctx, func() (bool, error) {
+		if rsp != nil {
+			rsp.Body.Close()
+			rsp = nil
+		}
+		req, err := newRequest()
+		if err != nil {
+			return false, err
+		}
+		rsp, err = client.Do(req)
+		if err != nil {
+			return true, err
+		} else if p.isTemporaryHTTPStatus(rsp.StatusCode) {
+			return true, errTemporaryHTTPStatus
+		}
+		return false, nil
+	})
+	if err == errTemporaryHTTPStatus {
+		err = nil
+	}
+	return rsp, err
+}
+
+func (p RetryPolicy) wait(delay time.Duration) time.Duration {
+	if !p.Jitter || delay < 2 {
+		return delay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+func (p RetryPolicy) isTemporaryHTTPStatus(code int) bool {
+	if p.RetryServerErrors && code >= http.StatusInternalServerError {
+		return true
+	}
+	return IsTemporaryHTTPStatus(code)
+}
+
+// IsTemporaryHTTPStatus tells whether a request failing with the given
+// status code is worth repeating.
+func IsTemporaryHTTPStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	testCases := map[string]struct {
+		policy RetryPolicy
+		errors []error
+		cancel bool
+
+		attempts int
+		err      error
+	}{
+		"ok, first attempt": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			errors:   []error{nil},
+			attempts: 1,
+		},
+		"ok, after transient errors": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			errors:   []error{errTransient, errTransient, nil},
+			attempts: 3,
+		},
+		"error, attempts exhausted": {
+			policy:   RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			errors:   []error{errTransient, errTransient, nil},
+			attempts: 2,
+			err:      errTransient,
+		},
+		"error, permanent": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			errors:   []error{errPermanent, nil},
+			attempts: 1,
+			err:      errPermanent,
+		},
+		"error, zero value": {
+			errors:   []error{errTransient, nil},
+			attempts: 1,
+			err:      errTransient,
+		},
+		"error, context canceled": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour},
+			errors:   []error{errTransient, nil},
+			cancel:   true,
+			attempts: 1,
+			err:      errTransient,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				cancel()
+			}
+
+			attempts := 0
+			err := tc.policy.Do(ctx, func() (bool, error) {
+				err := tc.errors[attempts]
+				attempts++
+				return err == errTransient, err
+			})
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
+
+func TestRetryPolicyDoHTTP(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		policy   RetryPolicy
+		statuses []int
+
+		attempts int
+		status   int
+	}{
+		"ok, after temporary status": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			attempts: 2,
+			status:   http.StatusOK,
+		},
+		"error, server error not retried": {
+			policy:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusInternalServerError,
+		},
+		"ok, server error retried": {
+			policy: RetryPolicy{
+				MaxAttempts:       3,
+				BaseDelay:         time.Millisecond,
+				Jitter:            true,
+				RetryServerErrors: true,
+			},
+			statuses: []int{
+				http.StatusInternalServerError,
+				http.StatusBadGateway,
+				http.StatusCreated,
+			},
+			attempts: 3,
+			status:   http.StatusCreated,
+		},
+		"error, client error not retried": {
+			policy: RetryPolicy{
+				MaxAttempts:       3,
+				BaseDelay:         time.Millisecond,
+				RetryServerErrors: true,
+			},
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusBadRequest,
+		},
+		"error, attempts exhausted": {
+			policy: RetryPolicy{
+				MaxAttempts:       2,
+				BaseDelay:         time.Millisecond,
+				RetryServerErrors: true,
+			},
+			statuses: []int{
+				http.StatusInternalServerError,
+				http.StatusInternalServerError,
+				http.StatusOK,
+			},
+			attempts: 2,
+			status:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tc.statuses[attempts])
+					attempts++
+				},
+			))
+			defer srv.Close()
+
+			ctx := context.Background()
+			rsp, err := tc.policy.DoHTTP(ctx, srv.Client(), func() (*http.Request, error) {
+				return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			})
+			if assert.NoError(t, err) {
+				rsp.Body.Close()
+				assert.Equal(t, tc.status, rsp.StatusCode)
+			}
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	t.Parallel()
+
+	delay := 100 * time.Millisecond
+	assert.Equal(t, delay, RetryPolicy{}.wait(delay))
+	policy := RetryPolicy{Jitter: true}
+	for i := 0; i < 100; i++ {
+		wait := policy.wait(delay)
+		assert.GreaterOrEqual(t, wait, delay/2)
+		assert.LessOrEqual(t, wait, delay)
+	}
+}